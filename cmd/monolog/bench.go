@@ -0,0 +1,167 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/bench"
+	"github.com/rizkyandriawan/monolog/internal/cli"
+	"github.com/rizkyandriawan/monolog/internal/config"
+	"github.com/rizkyandriawan/monolog/internal/engine"
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+func runBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+
+	internal := fs.Bool("internal", false, "Benchmark the in-process produce path (ProduceRaw -> AppendRaw -> Fetch)")
+	backends := fs.String("backends", strings.Join(store.Backends(), ","), "Comma-separated storage backends to benchmark")
+	batchSizes := fs.String("batch-sizes", "1,10,100,1000", "Comma-separated records per batch")
+	codecs := fs.String("codecs", strings.Join(kafkaproto.AvailableCodecNames(), ","), "Comma-separated compression codecs")
+	configFile := fs.String("config", "", "Config file whose compression settings (levels, dictionaries, disabled codecs) to benchmark with")
+	valueSize := fs.Int("value-size", 256, "Bytes per record value")
+	benchTime := fs.Duration("benchtime", time.Second, "How long to run each case")
+	cpuProfile := fs.String("cpuprofile", "", "Write a CPU profile of the whole run to this file")
+	memProfile := fs.String("memprofile", "", "Write a heap profile to this file after the run")
+	output := cli.OutputFlag(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: monolog bench --internal [options]")
+		fmt.Fprintln(os.Stderr, "\nRuns every backend x batch size x codec combination and reports the cost of")
+		fmt.Fprintln(os.Stderr, "producing one batch and fetching it back. Compare runs across storage changes;")
+		fmt.Fprintln(os.Stderr, "profile with --cpuprofile and `go tool pprof`.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	format, err := cli.ParseFormat(*output)
+	if err != nil {
+		cli.Fail(cli.FormatTable, &cli.UsageError{Err: err})
+	}
+	if !*internal {
+		fs.Usage()
+		cli.Fail(format, cli.Usagef("bench requires --internal"))
+	}
+
+	if *configFile != "" {
+		cfg, err := config.Load(*configFile)
+		if err != nil {
+			cli.Fail(format, fmt.Errorf("load config: %w", err))
+		}
+		if err := engine.ConfigureCodecs(cfg.Compression); err != nil {
+			cli.Fail(format, err)
+		}
+	}
+
+	cases, err := benchCases(*backends, *batchSizes, *codecs, *valueSize)
+	if err != nil {
+		cli.Fail(format, &cli.UsageError{Err: err})
+	}
+	if *benchTime <= 0 {
+		cli.Fail(format, cli.Usagef("benchtime must be positive"))
+	}
+
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			cli.Fail(format, fmt.Errorf("create cpu profile: %w", err))
+		}
+		defer f.Close()
+		if err := pprof.StartCPUProfile(f); err != nil {
+			cli.Fail(format, fmt.Errorf("start cpu profile: %w", err))
+		}
+	}
+
+	var progress func(bench.Result)
+	if format == cli.FormatTable {
+		fmt.Fprintf(os.Stderr, "running %d cases, about %s each\n", len(cases), *benchTime)
+		progress = func(r bench.Result) {
+			fmt.Fprintf(os.Stderr, "  %s: %d ns/op\n", r.Name, r.NsPerOp)
+		}
+	}
+	results, runErr := bench.Run(cases, *benchTime, progress)
+
+	if *cpuProfile != "" {
+		pprof.StopCPUProfile()
+	}
+	if *memProfile != "" {
+		if err := writeHeapProfile(*memProfile); err != nil {
+			cli.Fail(format, err)
+		}
+	}
+
+	cli.Render(os.Stdout, format, results, func() *cli.Table {
+		t := cli.NewTable("BACKEND", "BATCH", "CODEC", "BATCH BYTES", "NS/OP", "RECORDS/S", "MB/S", "ALLOCS/OP", "B/OP")
+		for _, r := range results {
+			t.AddRow(r.Backend, r.BatchSize, r.Codec, r.BatchBytes, r.NsPerOp,
+				strconv.FormatFloat(r.RecordsPerSec, 'f', 0, 64),
+				strconv.FormatFloat(r.MBPerSec, 'f', 2, 64),
+				r.AllocsPerOp, r.BytesPerOp)
+		}
+		return t
+	})
+	if runErr != nil {
+		cli.Fail(format, runErr)
+	}
+}
+
+// benchCases parses the bench flags into the cases to run
+func benchCases(backends, batchSizes, codecs string, valueSize int) ([]bench.Case, error) {
+	known := make(map[string]bool)
+	for _, name := range store.Backends() {
+		known[name] = true
+	}
+	var names []string
+	for _, name := range strings.Split(backends, ",") {
+		name = strings.TrimSpace(name)
+		if !known[name] {
+			return nil, fmt.Errorf("unknown storage backend %q (available: %s)", name, strings.Join(store.Backends(), ", "))
+		}
+		names = append(names, name)
+	}
+
+	var sizes []int
+	for _, v := range strings.Split(batchSizes, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid batch size %q", v)
+		}
+		sizes = append(sizes, n)
+	}
+
+	var ids []int8
+	for _, v := range strings.Split(codecs, ",") {
+		id, err := kafkaproto.ParseCodec(strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+		if !kafkaproto.CodecAvailable(id) {
+			return nil, fmt.Errorf("%w: %s", kafkaproto.ErrCodecUnavailable, kafkaproto.CodecName(id))
+		}
+		ids = append(ids, id)
+	}
+
+	if valueSize <= 0 {
+		return nil, fmt.Errorf("value size must be positive")
+	}
+	return bench.Cases(names, sizes, ids, valueSize), nil
+}
+
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create heap profile: %w", err)
+	}
+	defer f.Close()
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		return fmt.Errorf("write heap profile: %w", err)
+	}
+	return nil
+}
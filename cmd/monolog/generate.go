@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/cli"
+	"github.com/rizkyandriawan/monolog/internal/generate"
+	"github.com/rizkyandriawan/monolog/pkg/client"
+)
+
+// headerFlags collects repeated -header name=template flags
+type headerFlags map[string]string
+
+func (h headerFlags) String() string { return "" }
+
+func (h headerFlags) Set(v string) error {
+	name, tmpl, ok := strings.Cut(v, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("header must be name=template")
+	}
+	h[name] = tmpl
+	return nil
+}
+
+func runGenerate(args []string) {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	target := fs.String("target", "http://localhost:8080", "HTTP address of the instance to produce to")
+	token := fs.String("token", os.Getenv("MONOLOG_AUTH_TOKEN"), "API token for the target")
+	specFile := fs.String("spec", "", "JSON spec file; flags override its fields")
+	count := fs.Int("count", 0, "Messages to generate")
+	seed := fs.Int64("seed", 0, "Random seed, to reproduce a run (default: random)")
+	seqStart := fs.Int64("seq-start", 0, "First value of {{seq}}")
+	key := fs.String("key", "", "Key template, e.g. 'user-{{mod seq 1000}}' (default: no key)")
+	value := fs.String("value", "", "Value template, e.g. '{\"name\":\"{{name}}\",\"pad\":\"{{pad}}\"}' (default: random text)")
+	headers := headerFlags{}
+	fs.Var(headers, "header", "Header as name=template (repeatable)")
+	sizeDist := fs.String("size", "", "Value size distribution: fixed, uniform, normal or exponential")
+	sizeMin := fs.Int("size-min", 0, "Smallest value size in bytes")
+	sizeMax := fs.Int("size-max", 0, "Largest value size in bytes")
+	sizeMean := fs.Int("size-mean", 0, "Mean value size in bytes (fixed, normal, exponential)")
+	sizeStdDev := fs.Int("size-stddev", 0, "Value size standard deviation in bytes (normal)")
+	spread := fs.Duration("spread", 0, "Spread timestamps over this window ending now (default: append time)")
+	order := fs.String("order", "", "Timestamp order within the window: even or random")
+	print := fs.Bool("print", false, "Write the messages to stdout as JSON lines instead of producing them")
+	output := cli.OutputFlag(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: monolog generate [options] <topic>")
+		fmt.Fprintln(os.Stderr, "\nProduces synthetic messages from templates, to seed topics for load tests.")
+		fmt.Fprintln(os.Stderr, "Template functions: seq, mod, add, pad, uuid, hex, int, float, bool, pick,")
+		fmt.Fprintln(os.Stderr, "name, first_name, last_name, email, word, sentence, city, country, ip,")
+		fmt.Fprintln(os.Stderr, "timestamp, unixmilli.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	format, err := cli.ParseFormat(*output)
+	if err != nil {
+		cli.Fail(cli.FormatTable, &cli.UsageError{Err: err})
+	}
+	if (!*print && fs.NArg() != 1) || fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(cli.ExitUsage)
+	}
+
+	var spec client.GenerateSpec
+	if *specFile != "" {
+		data, err := os.ReadFile(*specFile)
+		if err != nil {
+			cli.Fail(format, fmt.Errorf("read spec: %w", err))
+		}
+		if err := json.Unmarshal(data, &spec); err != nil {
+			cli.Fail(format, cli.Usagef("read spec: %v", err))
+		}
+	}
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if set["count"] {
+		spec.Count = *count
+	}
+	if set["seed"] {
+		spec.Seed = *seed
+	}
+	if set["seq-start"] {
+		spec.SeqStart = *seqStart
+	}
+	if set["key"] {
+		spec.Key = *key
+	}
+	if set["value"] {
+		spec.Value = *value
+	}
+	if len(headers) > 0 {
+		if spec.Headers == nil {
+			spec.Headers = map[string]string{}
+		}
+		for name, tmpl := range headers {
+			spec.Headers[name] = tmpl
+		}
+	}
+	if set["size"] || set["size-min"] || set["size-max"] || set["size-mean"] || set["size-stddev"] {
+		if spec.Size == nil {
+			spec.Size = &client.GenerateSize{}
+		}
+		if set["size"] {
+			spec.Size.Distribution = *sizeDist
+		}
+		if set["size-min"] {
+			spec.Size.Min = *sizeMin
+		}
+		if set["size-max"] {
+			spec.Size.Max = *sizeMax
+		}
+		if set["size-mean"] {
+			spec.Size.Mean = *sizeMean
+		}
+		if set["size-stddev"] {
+			spec.Size.StdDev = *sizeStdDev
+		}
+	}
+	if set["spread"] || set["order"] {
+		if spec.Timestamps == nil {
+			spec.Timestamps = &client.GenerateTimestamps{}
+		}
+		if set["spread"] {
+			spec.Timestamps.Spread = spread.String()
+		}
+		if set["order"] {
+			spec.Timestamps.Order = *order
+		}
+	}
+
+	if *print {
+		if err := printGenerated(spec); err != nil {
+			cli.Fail(format, err)
+		}
+		return
+	}
+
+	c := client.New(*target, client.WithToken(*token))
+	result, err := c.Generate(context.Background(), fs.Arg(0), spec)
+	if err != nil {
+		cli.Fail(format, err)
+	}
+	cli.Render(os.Stdout, format, result, func() *cli.Table {
+		t := cli.NewTable("TOPIC", "OFFSET", "COUNT", "BYTES", "SEED")
+		t.AddRow(fs.Arg(0), result.Offset, result.Count, result.Bytes, result.Seed)
+		return t
+	})
+}
+
+// printGenerated generates spec's messages locally and writes them to
+// stdout, one JSON object per line
+func printGenerated(spec client.GenerateSpec) error {
+	local := generate.Spec{
+		Count:    spec.Count,
+		Seed:     spec.Seed,
+		SeqStart: spec.SeqStart,
+		Key:      spec.Key,
+		Value:    spec.Value,
+		Headers:  spec.Headers,
+	}
+	if s := spec.Size; s != nil {
+		local.Size = &generate.SizeSpec{Distribution: s.Distribution, Min: s.Min, Max: s.Max, Mean: s.Mean, StdDev: s.StdDev}
+	}
+	if t := spec.Timestamps; t != nil {
+		local.Timestamps = &generate.TimestampSpec{Spread: t.Spread, End: t.End, Order: t.Order}
+	}
+	gen, err := generate.New(local)
+	if err != nil {
+		return &cli.UsageError{Err: err}
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	enc := json.NewEncoder(w)
+	for {
+		rec, ok, err := gen.Next()
+		if err != nil {
+			return &cli.UsageError{Err: err}
+		}
+		if !ok {
+			break
+		}
+		line := struct {
+			Timestamp *time.Time        `json:"timestamp,omitempty"`
+			Key       string            `json:"key,omitempty"`
+			Value     string            `json:"value"`
+			Headers   map[string]string `json:"headers,omitempty"`
+		}{Key: string(rec.Key), Value: string(rec.Value)}
+		if rec.Timestamp != 0 {
+			ts := time.UnixMilli(rec.Timestamp).UTC()
+			line.Timestamp = &ts
+		}
+		for k, v := range rec.Headers {
+			if line.Headers == nil {
+				line.Headers = make(map[string]string, len(rec.Headers))
+			}
+			line.Headers[k] = string(v)
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "seed: %d\n", gen.Seed())
+	return w.Flush()
+}
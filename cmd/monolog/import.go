@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/capture"
+	"github.com/rizkyandriawan/monolog/internal/cli"
+	"github.com/rizkyandriawan/monolog/internal/config"
+	"github.com/rizkyandriawan/monolog/pkg/client"
+)
+
+// importRetries is how many times a chunk is sent again after the upload
+// broke off before the import is given up
+const importRetries = 3
+
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+
+	target := fs.String("target", "http://localhost:8080", "HTTP address of the instance to import into")
+	token := fs.String("token", os.Getenv("MONOLOG_AUTH_TOKEN"), "API token for the target")
+	scrub := fs.String("scrub", "", "YAML file of scrub rules applied before anything is sent (see capture.scrub)")
+	chunkSize := fs.Int("chunk-size", 4<<20, "Bytes sent per request, at most the target's produce.import_chunk_size")
+	output := cli.OutputFlag(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `Usage: monolog import [options] <topic> <file>
+
+Uploads newline-delimited JSON, in the format the HTTP export writes, into
+topic through the resumable import API. A file of "-" reads stdin.`)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	format, err := cli.ParseFormat(*output)
+	if err != nil {
+		cli.Fail(cli.FormatTable, &cli.UsageError{Err: err})
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(cli.ExitUsage)
+	}
+	if *chunkSize <= 0 {
+		cli.Fail(format, &cli.UsageError{Err: errors.New("-chunk-size must be positive")})
+	}
+
+	var in io.Reader = os.Stdin
+	if name := fs.Arg(1); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			cli.Fail(format, fmt.Errorf("open import: %w", err))
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var scrubber *capture.Scrubber
+	if *scrub != "" {
+		rules, err := config.LoadScrub(*scrub)
+		if err != nil {
+			cli.Fail(format, &cli.UsageError{Err: fmt.Errorf("scrub rules: %w", err)})
+		}
+		if scrubber, err = capture.NewScrubber(rules); err != nil {
+			cli.Fail(format, &cli.UsageError{Err: fmt.Errorf("scrub rules: %w", err)})
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	up := &importUpload{c: client.New(*target, client.WithToken(*token)), scrub: scrubber, chunkSize: *chunkSize, hash: sha256.New()}
+	start := time.Now()
+	imp, err := up.run(ctx, fs.Arg(0), in)
+	if err != nil {
+		if up.id != "" {
+			err = fmt.Errorf("%w (import %s stopped at byte %d)", err, up.id, up.offset)
+		}
+		cli.Fail(format, fmt.Errorf("import failed: %w", err))
+	}
+
+	result := struct {
+		*client.Import
+		ScrubbedLocally int64  `json:"scrubbed_locally,omitempty"` // messages the -scrub rules changed
+		Duration        string `json:"duration"`
+	}{imp, up.scrubbed, time.Since(start).Round(time.Millisecond).String()}
+	cli.Render(os.Stdout, format, result, func() *cli.Table {
+		t := cli.NewTable("ID", "TOPIC", "MESSAGES", "SCRUBBED", "BYTES", "FIRST", "LAST", "DURATION")
+		t.AddRow(imp.ID, imp.Topic, imp.Messages, up.scrubbed+imp.Scrubbed, imp.Offset, imp.First, imp.Last, result.Duration)
+		return t
+	})
+}
+
+// importUpload sends an import file in chunks, scrubbing each line first
+// when scrub rules were given
+type importUpload struct {
+	c         *client.Client
+	scrub     *capture.Scrubber
+	chunkSize int
+	hash      hash.Hash // of everything sent
+
+	id       string
+	offset   int64 // bytes the server has applied
+	line     int
+	scrubbed int64
+}
+
+func (u *importUpload) run(ctx context.Context, topic string, in io.Reader) (*client.Import, error) {
+	imp, err := u.c.StartImport(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+	u.id = imp.ID
+
+	r := bufio.NewReader(in)
+	var buf []byte
+	for {
+		line, readErr := r.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return nil, readErr
+		}
+		if len(line) > 0 {
+			u.line++
+			if line, err = u.scrubLine(line); err != nil {
+				return nil, err
+			}
+			buf = append(buf, line...)
+		}
+		for len(buf) >= u.chunkSize || (readErr == io.EOF && len(buf) > 0) {
+			n := min(len(buf), u.chunkSize)
+			if err := u.send(ctx, buf[:n]); err != nil {
+				return nil, err
+			}
+			buf = buf[n:]
+		}
+		if readErr == io.EOF {
+			break
+		}
+	}
+	return u.c.CompleteImport(ctx, u.id, hex.EncodeToString(u.hash.Sum(nil)))
+}
+
+// send uploads one chunk. When the upload breaks off, the session says
+// whether the chunk got through before the chunk is sent again.
+func (u *importUpload) send(ctx context.Context, chunk []byte) error {
+	sum := sha256.Sum256(chunk)
+	end := u.offset + int64(len(chunk))
+	var err error
+	for attempt := 0; attempt <= importRetries; attempt++ {
+		_, err = u.c.ImportChunk(ctx, u.id, u.offset, chunk, hex.EncodeToString(sum[:]))
+		if err == nil {
+			break
+		}
+		var apiErr *client.Error
+		if errors.As(err, &apiErr) || ctx.Err() != nil {
+			return err // refused, not lost on the way
+		}
+		imp, getErr := u.c.GetImport(ctx, u.id)
+		if getErr != nil {
+			continue
+		}
+		if imp.Offset == end {
+			err = nil
+			break
+		}
+		if imp.Offset != u.offset {
+			return fmt.Errorf("import is at byte %d, expected %d or %d: %w", imp.Offset, u.offset, end, err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	u.hash.Write(chunk)
+	u.offset = end
+	return nil
+}
+
+// scrubLine applies the scrub rules to the key and value of one line,
+// keeping its other fields. A line that is not a JSON object fails the
+// import rather than being sent unscrubbed.
+func (u *importUpload) scrubLine(line []byte) ([]byte, error) {
+	if u.scrub == nil || len(bytes.TrimSpace(line)) == 0 {
+		return line, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return nil, fmt.Errorf("line %d: %w", u.line, err)
+	}
+	var key, value *string
+	if err := unmarshalField(fields, "key", &key); err != nil {
+		return nil, fmt.Errorf("line %d: %w", u.line, err)
+	}
+	if err := unmarshalField(fields, "value", &value); err != nil {
+		return nil, fmt.Errorf("line %d: %w", u.line, err)
+	}
+
+	var k, v []byte
+	if key != nil && *key != "" {
+		k = []byte(*key)
+	}
+	if value != nil {
+		v = []byte(*value)
+	}
+	k, v, changed := u.scrub.Message(k, v)
+	if !changed {
+		return line, nil
+	}
+	u.scrubbed++
+	if k != nil {
+		fields["key"], _ = json.Marshal(string(k))
+	}
+	if v != nil {
+		fields["value"], _ = json.Marshal(string(v))
+	}
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", u.line, err)
+	}
+	return append(out, '\n'), nil
+}
+
+// unmarshalField decodes fields[name] into v, leaving v alone when absent
+func unmarshalField(fields map[string]json.RawMessage, name string, v interface{}) error {
+	raw, ok := fields[name]
+	if !ok {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
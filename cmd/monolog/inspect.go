@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rizkyandriawan/monolog/internal/cli"
+	sqlitestore "github.com/rizkyandriawan/monolog/internal/store"
+)
+
+func runInspect(args []string) {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+
+	topic := fs.String("topic", "", "Dump stored rows of this topic instead of listing topics")
+	offset := fs.Int64("offset", 0, "First offset to dump (with --topic)")
+	count := fs.Int("count", 10, "Rows to dump (with --topic)")
+	groups := fs.Bool("groups", false, "List committed consumer group offsets")
+	output := cli.OutputFlag(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: monolog inspect [options] <data-dir>")
+		fmt.Fprintln(os.Stderr, "\nReads a sqlite data directory read-only, without the server and without taking")
+		fmt.Fprintln(os.Stderr, "its lock. Lists topics with their offsets and message counts by default.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	format, err := cli.ParseFormat(*output)
+	if err != nil {
+		cli.Fail(cli.FormatTable, &cli.UsageError{Err: err})
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(cli.ExitUsage)
+	}
+	if *count <= 0 {
+		cli.Fail(format, cli.Usagef("count must be positive"))
+	}
+
+	insp, err := sqlitestore.OpenInspector(fs.Arg(0))
+	if err != nil {
+		cli.Fail(format, err)
+	}
+	defer insp.Close()
+	if insp.Immutable {
+		fmt.Fprintln(os.Stderr, "warning: database opened immutable; writes still in monolog.db-wal are not shown")
+	}
+
+	ctx := context.Background()
+	switch {
+	case *topic != "":
+		records, err := insp.Dump(ctx, *topic, *offset, *count)
+		cli.Render(os.Stdout, format, records, func() *cli.Table {
+			t := cli.NewTable("OFFSET", "LAST", "TIMESTAMP", "CODEC", "BYTES", "KEY", "VALUE")
+			for _, r := range records {
+				t.AddRow(r.Offset, r.LastOffset, time.UnixMilli(r.Timestamp).Format(time.RFC3339Nano),
+					r.Codec, len(r.Value), preview(r.Key), preview(r.Value))
+			}
+			return t
+		})
+		if err != nil {
+			insp.Close()
+			cli.Fail(format, err)
+		}
+
+	case *groups:
+		offsets, err := insp.GroupOffsets(ctx)
+		if err != nil {
+			insp.Close()
+			cli.Fail(format, err)
+		}
+		cli.Render(os.Stdout, format, offsets, func() *cli.Table {
+			t := cli.NewTable("GROUP", "TOPIC", "PARTITION", "OFFSET")
+			for _, o := range offsets {
+				t.AddRow(o.Group, o.Topic, o.Partition, o.Offset)
+			}
+			return t
+		})
+
+	default:
+		topics, err := insp.Topics(ctx)
+		if err != nil {
+			insp.Close()
+			cli.Fail(format, err)
+		}
+		cli.Render(os.Stdout, format, topics, func() *cli.Table {
+			t := cli.NewTable("TOPIC", "EARLIEST", "LATEST", "MAX STORED", "ROWS", "MESSAGES", "BYTES", "NOTE")
+			for _, tp := range topics {
+				note := ""
+				switch {
+				case tp.Orphaned:
+					note = "no topic row"
+				case tp.MaxStored > tp.LatestOffset:
+					note = "stored past latest_offset"
+				}
+				t.AddRow(tp.Name, tp.Earliest, tp.LatestOffset, tp.MaxStored, tp.Rows, tp.Messages, tp.Bytes, note)
+			}
+			return t
+		})
+	}
+}
+
+// preview shows up to 40 bytes of b as text, or as hex when it is binary
+func preview(b []byte) string {
+	const max = 40
+	if b == nil {
+		return "-"
+	}
+	short := b
+	if len(short) > max {
+		short = short[:max]
+	}
+	s := string(short)
+	if !utf8.ValidString(s) {
+		s = "0x" + hex.EncodeToString(short)
+	} else {
+		for _, r := range s {
+			if r < 0x20 || r == 0x7f {
+				s = "0x" + hex.EncodeToString(short)
+				break
+			}
+		}
+	}
+	if len(b) > max {
+		s += "..."
+	}
+	return s
+}
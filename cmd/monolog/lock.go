@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// lockOwner is written into the data directory's lock file by the
+// instance holding it, so another one can say who is in the way
+type lockOwner struct {
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	Since     time.Time `json:"since"`
+	Version   string    `json:"version"`
+	KafkaAddr string    `json:"kafka_addr,omitempty"`
+}
+
+// dataLockedError reports a data directory another instance holds
+type dataLockedError struct {
+	dataDir string
+	owner   *lockOwner // nil if the holder wrote no metadata
+}
+
+func (e *dataLockedError) Error() string {
+	if e.owner == nil {
+		return fmt.Sprintf("another monolog instance is using data directory %s", e.dataDir)
+	}
+	o := e.owner
+	msg := fmt.Sprintf("data directory %s is in use by monolog %s (pid %d on %s", e.dataDir, o.Version, o.PID, o.Host)
+	if o.KafkaAddr != "" {
+		msg += ", kafka " + o.KafkaAddr
+	}
+	return msg + fmt.Sprintf(") since %s (%s ago)",
+		o.Since.Local().Format(time.RFC3339), time.Since(o.Since).Round(time.Second))
+}
+
+// Backoff between attempts while waiting for the data lock
+const (
+	lockRetryMin = 250 * time.Millisecond
+	lockRetryMax = 5 * time.Second
+)
+
+// acquireDataLock acquires an exclusive lock on the data directory and
+// records this process as its owner. Returns the lock file handle (must
+// be kept open) or a *dataLockedError if another instance holds it.
+func acquireDataLock(dataDir, kafkaAddr string) (*os.File, error) {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("create data dir: %w", err)
+	}
+
+	lockPath := filepath.Join(dataDir, ".lock")
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file: %w", err)
+	}
+
+	// Try to acquire exclusive lock (non-blocking)
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		owner := readLockOwner(f)
+		f.Close()
+		return nil, &dataLockedError{dataDir: dataDir, owner: owner}
+	}
+
+	// Owner metadata is only advisory; the flock is what excludes others
+	host, _ := os.Hostname()
+	owner := lockOwner{PID: os.Getpid(), Host: host, Since: time.Now(), Version: version, KafkaAddr: kafkaAddr}
+	if err := writeLockOwner(f, owner); err != nil {
+		log.Printf("[store] recording lock owner failed: %v", err)
+	}
+
+	return f, nil
+}
+
+// waitForDataLock acquires the data lock, retrying with backoff for up to
+// wait while another instance holds it
+func waitForDataLock(dataDir, kafkaAddr string, wait time.Duration) (*os.File, error) {
+	deadline := time.Now().Add(wait)
+	delay := lockRetryMin
+	logged := false
+	for {
+		f, err := acquireDataLock(dataDir, kafkaAddr)
+		var locked *dataLockedError
+		if err == nil || !errors.As(err, &locked) || time.Now().Add(delay).After(deadline) {
+			return f, err
+		}
+		if !logged {
+			log.Printf("[store] %v; waiting up to %s for it to be released", err, wait)
+			logged = true
+		}
+		time.Sleep(delay)
+		delay = min(delay*2, lockRetryMax)
+	}
+}
+
+// readLockOwner reads the owner metadata of a lock file, or nil if there
+// is none
+func readLockOwner(f *os.File) *lockOwner {
+	data, err := io.ReadAll(io.NewSectionReader(f, 0, 1<<16))
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	var owner lockOwner
+	if err := json.Unmarshal(data, &owner); err != nil || owner.PID == 0 {
+		return nil
+	}
+	return &owner
+}
+
+func writeLockOwner(f *os.File, owner lockOwner) error {
+	data, err := json.Marshal(owner)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.WriteAt(append(data, '\n'), 0); err != nil {
+		return err
+	}
+	return f.Sync()
+}
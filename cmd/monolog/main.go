@@ -3,39 +3,24 @@ package main
 import (
 	"flag"
 	"fmt"
+	"log"
 	"os"
 	"os/signal"
-	"path/filepath"
+	"runtime/debug"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/rizkyandriawan/monolog/internal/cli"
 	"github.com/rizkyandriawan/monolog/internal/config"
 	"github.com/rizkyandriawan/monolog/internal/engine"
+	"github.com/rizkyandriawan/monolog/internal/ids"
+	"github.com/rizkyandriawan/monolog/internal/redact"
 	"github.com/rizkyandriawan/monolog/internal/server"
-	"github.com/rizkyandriawan/monolog/internal/store"
+	_ "github.com/rizkyandriawan/monolog/internal/store" // registers the SQLite backends
+	"github.com/rizkyandriawan/monolog/pkg/store"
 )
 
-// acquireDataLock acquires an exclusive lock on the data directory
-// Returns the lock file handle (must be kept open) or error if already locked
-func acquireDataLock(dataDir string) (*os.File, error) {
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		return nil, fmt.Errorf("create data dir: %w", err)
-	}
-
-	lockPath := filepath.Join(dataDir, ".lock")
-	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0600)
-	if err != nil {
-		return nil, fmt.Errorf("open lock file: %w", err)
-	}
-
-	// Try to acquire exclusive lock (non-blocking)
-	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
-		f.Close()
-		return nil, fmt.Errorf("another monolog instance is using data directory %s", dataDir)
-	}
-
-	return f, nil
-}
-
 var (
 	version = "0.2.0"
 	commit  = "none"
@@ -44,20 +29,38 @@ var (
 func main() {
 	if len(os.Args) < 2 {
 		printUsage()
-		os.Exit(1)
+		os.Exit(cli.ExitUsage)
 	}
 
 	switch os.Args[1] {
 	case "serve":
 		runServe(os.Args[2:])
+	case "replay":
+		runReplay(os.Args[2:])
+	case "import":
+		runImport(os.Args[2:])
+	case "top":
+		runTop(os.Args[2:])
+	case "bench":
+		runBench(os.Args[2:])
+	case "inspect":
+		runInspect(os.Args[2:])
+	case "offsets":
+		runOffsets(os.Args[2:])
+	case "report":
+		runReport(os.Args[2:])
+	case "secrets":
+		runSecrets(os.Args[2:])
+	case "generate":
+		runGenerate(os.Args[2:])
 	case "version":
-		fmt.Printf("monolog %s (%s)\n", version, commit)
+		runVersion(os.Args[2:])
 	case "help", "-h", "--help":
 		printUsage()
 	default:
 		fmt.Fprintf(os.Stderr, "unknown command: %s\n", os.Args[1])
 		printUsage()
-		os.Exit(1)
+		os.Exit(cli.ExitUsage)
 	}
 }
 
@@ -69,10 +72,43 @@ Usage:
 
 Commands:
   serve     Start the Monolog server
+  replay    Replay a traffic capture into a running instance
+  import    Upload an NDJSON export into a topic, scrubbing it on the way
+  top       Live terminal dashboard of a running instance
+  bench     Benchmark the storage produce path in-process (--internal)
+  inspect   Read a data directory offline, without the server
+  offsets   Export or import a consumer group's committed offsets
+  report    Summarize recorded latency, throughput, lag and errors as markdown or HTML
+  secrets   Create master keys, encrypt config secrets and rotate the key
+  generate  Produce synthetic messages from templates to seed load tests
   version   Print version information
   help      Print this help message
 
-Run 'monolog serve --help' for serve options.`)
+Run 'monolog <command> --help' for command options. Client commands accept
+--output json|table and exit with:
+  0  success
+  1  unclassified error
+  2  usage error (bad flags, arguments or request)
+  3  not found
+  4  authentication failed
+  5  server error
+  6  server unreachable`)
+}
+
+func runVersion(args []string) {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	output := cli.OutputFlag(fs)
+	fs.Parse(args)
+	format, err := cli.ParseFormat(*output)
+	if err != nil {
+		cli.Fail(cli.FormatTable, &cli.UsageError{Err: err})
+	}
+
+	if format == cli.FormatJSON {
+		cli.Render(os.Stdout, format, map[string]string{"version": version, "commit": commit}, nil)
+		return
+	}
+	fmt.Printf("monolog %s (%s)\n", version, commit)
 }
 
 func runServe(args []string) {
@@ -81,9 +117,23 @@ func runServe(args []string) {
 	configFile := fs.String("config", "", "Path to config file (YAML)")
 	kafkaAddr := fs.String("kafka-addr", ":9092", "Kafka protocol listen address")
 	httpAddr := fs.String("http-addr", ":8080", "HTTP API listen address")
+	singlePort := fs.String("single-port", "", "Serve Kafka and HTTP on this one address (overrides -kafka-addr and -http-addr)")
+	basePath := fs.String("base-path", "", "URL prefix for the HTTP API and UI when behind a reverse proxy (e.g. /monolog)")
+	noUI := fs.Bool("no-ui", false, "Disable the web UI and serve only the API")
+	virtualBrokers := fs.Int("virtual-brokers", 0, "Advertise this many Kafka brokers on consecutive ports from the Kafka address")
+	kafkaPurge := fs.Bool("kafka-purge", false, "Enable the MonologPurge Kafka API so test clients can delete topic data")
+	crashFaults := fs.Bool("crash-faults", false, "Enable /api/admin/crash so crash-recovery tests can make the broker kill itself")
+	leaderNotAvailable := fs.Bool("leader-not-available", false, "Report topics that do not exist yet as LEADER_NOT_AVAILABLE in Metadata, like a broker auto-creating them")
 	dataDir := fs.String("data-dir", "./data", "Data directory for storage")
 	logLevel := fs.String("log-level", "info", "Log level (debug, info, warn, error)")
-	backend := fs.String("storage", "", "Storage backend: sqlite or sqlite:memory")
+	logUnsafe := fs.Bool("log-unsafe", false, "Log record contents unredacted; tokens stay masked (local debugging only)")
+	storageBackend := fs.String("storage", "", "Storage backend ("+strings.Join(store.Backends(), ", ")+")")
+	capturePath := fs.String("capture", "", "Record produced traffic to this replay file")
+	follow := fs.String("follow", "", "Replicate topics from the monolog at this HTTP address, serving them read-only")
+	tlsAuto := fs.Bool("tls-auto", false, "Serve Kafka and HTTP over TLS with a CA and certificate generated in the data directory (CA at /api/ca.pem)")
+	deterministicSeed := fs.Uint64("deterministic-seed", 0, "Derive generated IDs from this seed and run the clock from a fixed start, so identical runs produce identical bytes (tests only)")
+	memoryLimit := fs.Int64("memory-limit", 0, "Soft limit in bytes on the Go runtime's memory; the garbage collector works harder near it (0 = GOMEMLIMIT or none)")
+	waitForLock := fs.Duration("wait-for-lock", 0, "If another instance holds the data directory, retry with backoff for up to this long instead of exiting")
 
 	fs.Parse(args)
 
@@ -101,21 +151,84 @@ func runServe(args []string) {
 	if *httpAddr != ":8080" || cfg.Server.HTTPAddr == "" {
 		cfg.Server.HTTPAddr = *httpAddr
 	}
+	if *singlePort != "" {
+		cfg.Server.SinglePort = *singlePort
+	}
+	if *basePath != "" {
+		cfg.Server.BasePath = *basePath
+	}
+	if *noUI {
+		cfg.Server.DisableUI = true
+	}
+	if *virtualBrokers != 0 {
+		cfg.Server.VirtualBrokers = *virtualBrokers
+	}
+	if *kafkaPurge {
+		cfg.Server.KafkaPurge = true
+	}
+	if *crashFaults {
+		cfg.Server.CrashFaults = true
+	}
+	if *leaderNotAvailable {
+		cfg.Topics.ColdStart.LeaderNotAvailable = true
+	}
+	if cfg.Server.SinglePort != "" {
+		// Kafka clients must be advertised the shared port too
+		cfg.Server.KafkaAddr = cfg.Server.SinglePort
+		cfg.Server.HTTPAddr = cfg.Server.SinglePort
+	}
 	if *dataDir != "./data" || cfg.Storage.DataDir == "" {
 		cfg.Storage.DataDir = *dataDir
 	}
 	if *logLevel != "info" || cfg.Logging.Level == "" {
 		cfg.Logging.Level = *logLevel
 	}
-	if *backend != "" {
-		cfg.Storage.Backend = *backend
+	if *logUnsafe {
+		cfg.Logging.Unsafe = true
+	}
+	if *storageBackend != "" {
+		cfg.Storage.Backend = *storageBackend
+	}
+	if *capturePath != "" {
+		cfg.Capture.Path = *capturePath
+	}
+	if *follow != "" {
+		cfg.Follower.Primary = *follow
+	}
+	if *tlsAuto {
+		cfg.Security.TLS.Auto = true
+	}
+	if *memoryLimit != 0 {
+		cfg.Memory.Limit = *memoryLimit
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "deterministic-seed" {
+			cfg.Deterministic.Enabled = true
+			cfg.Deterministic.Seed = *deterministicSeed
+		}
+	})
+
+	log.SetOutput(redact.NewWriter(os.Stderr))
+	redact.SetUnsafe(cfg.Logging.Unsafe)
+	if cfg.Logging.Unsafe {
+		fmt.Fprintln(os.Stderr, "warning: unsafe logging is on; record contents and credentials are logged unredacted")
 	}
 
-	// Acquire data directory lock (except for memory mode)
+	if cfg.Memory.Limit > 0 {
+		debug.SetMemoryLimit(cfg.Memory.Limit)
+		log.Printf("[engine] soft memory limit %d MiB", cfg.Memory.Limit>>20)
+	}
+
+	if err := engine.ConfigureCodecs(cfg.Compression); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid compression config: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Acquire data directory lock (except for in-memory backends)
 	var lockFile *os.File
-	if cfg.Storage.Backend != "sqlite:memory" {
+	if !store.IsInMemory(cfg.Storage.Backend) {
 		var err error
-		lockFile, err = acquireDataLock(cfg.Storage.DataDir)
+		lockFile, err = waitForDataLock(cfg.Storage.DataDir, cfg.Server.KafkaAddr, *waitForLock)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "failed to acquire data lock: %v\n", err)
 			os.Exit(1)
@@ -123,63 +236,85 @@ func runServe(args []string) {
 		defer lockFile.Close()
 	}
 
-	// Initialize store based on backend
-	var topicStore store.TopicStoreInterface
-	var groupStore store.GroupStoreInterface
-	var closer func() error
-
-	storageBackend := cfg.Storage.Backend
-	switch {
-	case storageBackend == "sqlite" || storageBackend == "sqlite:disk":
-		fmt.Printf("Using SQLite storage backend (disk)\n")
-		sqliteDB, err := store.OpenSQLite(cfg.Storage.DataDir, "disk")
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to open sqlite store: %v\n", err)
-			os.Exit(1)
-		}
-		closer = sqliteDB.Close
-		topicStore = store.NewSQLiteTopicStore(sqliteDB)
-		groupStore = store.NewSQLiteGroupStore(sqliteDB)
+	if err := server.PrepareAutoTLS(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set up tls: %v\n", err)
+		os.Exit(1)
+	}
 
-	case storageBackend == "sqlite:memory":
-		fmt.Printf("Using SQLite storage backend (in-memory)\n")
-		sqliteDB, err := store.OpenSQLite(cfg.Storage.DataDir, "memory")
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to open sqlite store: %v\n", err)
-			os.Exit(1)
-		}
-		closer = sqliteDB.Close
-		topicStore = store.NewSQLiteTopicStore(sqliteDB)
-		groupStore = store.NewSQLiteGroupStore(sqliteDB)
+	// Before the store opens, as it gives topics their IDs
+	if d := cfg.Deterministic; d.Enabled {
+		ids.Seed(d.Seed)
+		log.Printf("[engine] deterministic mode: seed %d, clock from %s stepping %s", d.Seed, d.Start.Format(time.RFC3339), d.Step)
+	}
 
-	default:
-		fmt.Fprintf(os.Stderr, "unknown storage backend: %s (use 'sqlite' or 'sqlite:memory')\n", storageBackend)
+	// Open the registered storage backend
+	backend, err := store.Open(cfg.Storage.Backend, cfg.Storage)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open storage: %v\n", err)
 		os.Exit(1)
 	}
-	defer closer()
+	defer backend.Close()
+	fmt.Printf("Using storage backend %s\n", cfg.Storage.Backend)
 
 	// Initialize engine
-	eng := engine.New(cfg, topicStore, groupStore)
+	eng := engine.New(cfg, backend.Topics, backend.Groups)
+	if cfg.Capture.Path != "" {
+		if err := eng.StartCapture(cfg.Capture.Path); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to start capture: %v\n", err)
+			os.Exit(1)
+		}
+	}
 	eng.Start()
 	defer eng.Stop()
 
 	// Start servers
-	kafkaSrv := server.NewKafkaServer(cfg, eng)
-	httpSrv := server.NewHTTPServer(cfg, eng)
+	server.Version = version
+	kafkaSrv, err := server.NewKafkaServer(cfg, eng)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create kafka server: %v\n", err)
+		os.Exit(1)
+	}
+	httpSrv, err := server.NewHTTPServer(cfg, eng, kafkaSrv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create http server: %v\n", err)
+		os.Exit(1)
+	}
 
-	go func() {
-		fmt.Printf("Kafka server listening on %s\n", cfg.Server.KafkaAddr)
-		if err := kafkaSrv.ListenAndServe(); err != nil {
-			fmt.Fprintf(os.Stderr, "kafka server error: %v\n", err)
+	tlsNote := ""
+	if cfg.Security.TLS.Enabled {
+		tlsNote = " (TLS)"
+	}
+	var portMux *server.PortMux
+	if cfg.Server.SinglePort != "" {
+		tlsConfig, err := server.LoadTLS(cfg.Security.TLS)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
 		}
-	}()
-
-	go func() {
-		fmt.Printf("HTTP server listening on %s\n", cfg.Server.HTTPAddr)
-		if err := httpSrv.ListenAndServe(); err != nil {
-			fmt.Fprintf(os.Stderr, "http server error: %v\n", err)
+		portMux, err = server.ListenPortMux(cfg.Server.SinglePort, tlsConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to listen on %s: %v\n", cfg.Server.SinglePort, err)
+			os.Exit(1)
 		}
-	}()
+		fmt.Printf("Kafka and HTTP listening on %s (single port)%s\n", cfg.Server.SinglePort, tlsNote)
+		go portMux.Serve()
+		go kafkaSrv.Serve(portMux.Kafka())
+		go httpSrv.Serve(portMux.HTTP())
+	} else {
+		go func() {
+			fmt.Printf("Kafka server listening on %s%s\n", cfg.Server.KafkaAddr, tlsNote)
+			if err := kafkaSrv.ListenAndServe(); err != nil {
+				fmt.Fprintf(os.Stderr, "kafka server error: %v\n", err)
+			}
+		}()
+
+		go func() {
+			fmt.Printf("HTTP server listening on %s%s\n", cfg.Server.HTTPAddr, tlsNote)
+			if err := httpSrv.ListenAndServe(); err != nil {
+				fmt.Fprintf(os.Stderr, "http server error: %v\n", err)
+			}
+		}()
+	}
 
 	// Wait for shutdown signal
 	sigCh := make(chan os.Signal, 1)
@@ -189,4 +324,7 @@ func runServe(args []string) {
 	fmt.Println("\nShutting down...")
 	kafkaSrv.Close()
 	httpSrv.Close()
+	if portMux != nil {
+		portMux.Close()
+	}
 }
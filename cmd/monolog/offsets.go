@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/cli"
+	"github.com/rizkyandriawan/monolog/pkg/client"
+)
+
+func runOffsets(args []string) {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, `Usage:
+  monolog offsets export [options] <group> [file]
+  monolog offsets import [options] <file>
+  monolog offsets reset [options] <group> <file>
+
+export writes the group's committed offsets as a JSON checkpoint (to stdout
+when no file is given); import commits a checkpoint's offsets to a group on
+the target instance; reset sets the offsets listed in a CSV file of
+topic,partition,offset lines, where offset may instead be an RFC 3339 time.`)
+	}
+	if len(args) < 1 {
+		usage()
+		os.Exit(cli.ExitUsage)
+	}
+
+	switch args[0] {
+	case "export":
+		runOffsetsExport(args[1:])
+	case "import":
+		runOffsetsImport(args[1:])
+	case "reset":
+		runOffsetsReset(args[1:])
+	case "help", "-h", "--help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown offsets command: %s\n", args[0])
+		usage()
+		os.Exit(cli.ExitUsage)
+	}
+}
+
+func runOffsetsExport(args []string) {
+	fs := flag.NewFlagSet("offsets export", flag.ExitOnError)
+	target := fs.String("target", "http://localhost:8080", "HTTP address of the instance to export from")
+	token := fs.String("token", os.Getenv("MONOLOG_AUTH_TOKEN"), "API token for the target")
+	output := cli.OutputFlag(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: monolog offsets export [options] <group> [file]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	format, err := cli.ParseFormat(*output)
+	if err != nil {
+		cli.Fail(cli.FormatTable, &cli.UsageError{Err: err})
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		os.Exit(cli.ExitUsage)
+	}
+
+	c := client.New(*target, client.WithToken(*token))
+	checkpoint, err := c.ExportOffsets(context.Background(), fs.Arg(0))
+	if err != nil {
+		cli.Fail(format, err)
+	}
+
+	if fs.NArg() == 1 {
+		writeCheckpoint(os.Stdout, checkpoint)
+		return
+	}
+	f, err := os.Create(fs.Arg(1))
+	if err != nil {
+		cli.Fail(format, err)
+	}
+	writeCheckpoint(f, checkpoint)
+	if err := f.Close(); err != nil {
+		cli.Fail(format, err)
+	}
+
+	cli.Render(os.Stdout, format, checkpoint, func() *cli.Table {
+		return offsetsTable(checkpoint.Offsets, nil)
+	})
+}
+
+func runOffsetsImport(args []string) {
+	fs := flag.NewFlagSet("offsets import", flag.ExitOnError)
+	target := fs.String("target", "http://localhost:8080", "HTTP address of the instance to import into")
+	token := fs.String("token", os.Getenv("MONOLOG_AUTH_TOKEN"), "API token for the target")
+	group := fs.String("group", "", "Group to commit the offsets to (default: the group the checkpoint was exported from)")
+	skipMissing := fs.Bool("skip-missing", false, "Leave out topics the target does not have instead of failing")
+	output := cli.OutputFlag(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: monolog offsets import [options] <file>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	format, err := cli.ParseFormat(*output)
+	if err != nil {
+		cli.Fail(cli.FormatTable, &cli.UsageError{Err: err})
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(cli.ExitUsage)
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		cli.Fail(format, fmt.Errorf("read checkpoint: %w", err))
+	}
+	var checkpoint client.OffsetCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		cli.Fail(format, cli.Usagef("read checkpoint: %v", err))
+	}
+	if *group == "" {
+		*group = checkpoint.Group
+	}
+	if *group == "" {
+		cli.Fail(format, cli.Usagef("checkpoint names no group; pass -group"))
+	}
+
+	c := client.New(*target, client.WithToken(*token))
+	result, err := c.ImportOffsets(context.Background(), *group, &checkpoint, *skipMissing)
+	if err != nil {
+		cli.Fail(format, fmt.Errorf("import failed: %w", err))
+	}
+	cli.Render(os.Stdout, format, result, func() *cli.Table {
+		return offsetsTable(result.Imported, result.Skipped)
+	})
+}
+
+func runOffsetsReset(args []string) {
+	fs := flag.NewFlagSet("offsets reset", flag.ExitOnError)
+	target := fs.String("target", "http://localhost:8080", "HTTP address of the instance holding the group")
+	token := fs.String("token", os.Getenv("MONOLOG_AUTH_TOKEN"), "API token for the target")
+	generation := fs.Int("generation", -1, "Refuse the reset if the group is no longer at this generation (-1: any)")
+	execute := fs.Bool("execute", false, "Commit the offsets; without it the reset is only planned and shown")
+	output := cli.OutputFlag(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: monolog offsets reset [options] <group> <file>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	format, err := cli.ParseFormat(*output)
+	if err != nil {
+		cli.Fail(cli.FormatTable, &cli.UsageError{Err: err})
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(cli.ExitUsage)
+	}
+
+	f, err := os.Open(fs.Arg(1))
+	if err != nil {
+		cli.Fail(format, fmt.Errorf("read reset file: %w", err))
+	}
+	reset, err := readResetFile(f)
+	f.Close()
+	if err != nil {
+		cli.Fail(format, cli.Usagef("read reset file: %v", err))
+	}
+	reset.Generation = int32(*generation)
+	reset.DryRun = !*execute
+
+	c := client.New(*target, client.WithToken(*token))
+	result, err := c.ResetOffsets(context.Background(), fs.Arg(0), reset)
+	if err != nil {
+		cli.Fail(format, fmt.Errorf("reset failed: %w", err))
+	}
+	cli.Render(os.Stdout, format, result, func() *cli.Table {
+		status := "committed"
+		if result.DryRun {
+			status = "planned (pass -execute to commit)"
+		}
+		return resetTable(result, status)
+	})
+}
+
+// readResetFile reads topic,partition,offset lines, as written by
+// kafka-consumer-groups --reset-offsets --export. An offset that is not a
+// number is read as an RFC 3339 time.
+func readResetFile(r io.Reader) (*client.OffsetReset, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 3
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+	lines, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	reset := &client.OffsetReset{Offsets: map[string]int64{}, Timestamps: map[string]int64{}}
+	for _, l := range lines {
+		partition, err := strconv.ParseInt(l[1], 10, 32)
+		if err != nil || partition < 0 {
+			return nil, fmt.Errorf("invalid partition %q", l[1])
+		}
+		key := l[0]
+		if partition != 0 {
+			key += ":" + l[1]
+		}
+		if offset, err := strconv.ParseInt(l[2], 10, 64); err == nil {
+			reset.Offsets[key] = offset
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, l[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid offset %q: want a number or an RFC 3339 time", l[2])
+		}
+		reset.Timestamps[key] = at.UnixMilli()
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("no offsets listed")
+	}
+	return reset, nil
+}
+
+// resetTable lists a reset's new offsets by topic beside the ones they replace
+func resetTable(result *client.OffsetResetResult, status string) *cli.Table {
+	topics := make([]string, 0, len(result.Offsets))
+	for topic := range result.Offsets {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	t := cli.NewTable("TOPIC", "PREVIOUS", "OFFSET", "STATUS")
+	for _, topic := range topics {
+		var previous interface{} = "-"
+		if p, ok := result.Previous[topic]; ok {
+			previous = p
+		}
+		t.AddRow(topic, previous, result.Offsets[topic], status)
+	}
+	return t
+}
+
+func writeCheckpoint(w io.Writer, checkpoint *client.OffsetCheckpoint) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(checkpoint)
+}
+
+// offsetsTable lists committed offsets by topic, followed by skipped topics
+func offsetsTable(offsets map[string]int64, skipped []string) *cli.Table {
+	topics := make([]string, 0, len(offsets))
+	for topic := range offsets {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	t := cli.NewTable("TOPIC", "OFFSET", "STATUS")
+	for _, topic := range topics {
+		t.AddRow(topic, offsets[topic], "committed")
+	}
+	for _, topic := range skipped {
+		t.AddRow(topic, "-", "skipped (no such topic)")
+	}
+	return t
+}
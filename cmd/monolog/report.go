@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/cli"
+	"github.com/rizkyandriawan/monolog/internal/engine"
+	sqlitestore "github.com/rizkyandriawan/monolog/internal/store"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// reportBuckets is how many rows the history table splits the window into
+const reportBuckets = 24
+
+func runReport(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+
+	since := fs.Duration("since", 24*time.Hour, "Report on this much history before --to")
+	from := fs.String("from", "", "Start of the window, RFC 3339 (overrides --since)")
+	to := fs.String("to", "", "End of the window, RFC 3339 (default now)")
+	format := fs.String("format", "markdown", "Report format: markdown or html")
+	out := fs.String("out", "", "Write the report to this file instead of stdout")
+
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: monolog report [options] <data-dir>")
+		fmt.Fprintln(os.Stderr, "\nSummarizes the metrics a broker recorded in a sqlite data directory (see")
+		fmt.Fprintln(os.Stderr, "metrics.interval) into a latency, throughput, lag and error report, read-only")
+		fmt.Fprintln(os.Stderr, "and without the server.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(cli.ExitUsage)
+	}
+	if *format != "markdown" && *format != "html" {
+		cli.Fail(cli.FormatTable, cli.Usagef("unknown report format %q (want markdown or html)", *format))
+	}
+
+	end := time.Now()
+	if *to != "" {
+		t, err := time.Parse(time.RFC3339, *to)
+		if err != nil {
+			cli.Fail(cli.FormatTable, cli.Usagef("bad --to: %v", err))
+		}
+		end = t
+	}
+	start := end.Add(-*since)
+	if *from != "" {
+		t, err := time.Parse(time.RFC3339, *from)
+		if err != nil {
+			cli.Fail(cli.FormatTable, cli.Usagef("bad --from: %v", err))
+		}
+		start = t
+	}
+	if !start.Before(end) {
+		cli.Fail(cli.FormatTable, cli.Usagef("the window must end after it starts"))
+	}
+
+	insp, err := sqlitestore.OpenInspector(fs.Arg(0))
+	if err != nil {
+		cli.Fail(cli.FormatTable, err)
+	}
+	samples, err := insp.Metrics(context.Background(), start, end)
+	insp.Close()
+	if err != nil {
+		cli.Fail(cli.FormatTable, err)
+	}
+	if insp.Immutable {
+		fmt.Fprintln(os.Stderr, "warning: database opened immutable; samples still in monolog.db-wal are not included")
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			cli.Fail(cli.FormatTable, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	r := buildReport(fs.Arg(0), start, end, samples)
+	if *format == "html" {
+		err = htmlReport.Execute(w, r)
+	} else {
+		err = markdownReport.Execute(w, r)
+	}
+	if err != nil {
+		cli.Fail(cli.FormatTable, err)
+	}
+}
+
+// report is what the report templates render
+type report struct {
+	DataDir    string
+	From, To   time.Time
+	Samples    int
+	Latency    []latencyRow
+	Throughput []throughputRow
+	History    []historyRow
+	Groups     []groupRow
+	Errors     []errorRow
+	ErrorTotal int64
+}
+
+// latencyRow summarizes the p99s recorded for one operation
+type latencyRow struct {
+	Name          string
+	Samples       int
+	P50, P99, Max float64
+}
+
+type throughputRow struct {
+	Name      string
+	Avg, Peak float64
+}
+
+// historyRow covers one slice of the window
+type historyRow struct {
+	Start      time.Time
+	ProduceP99 float64 // highest recorded in the slice, -1 when none was
+	FetchP99   float64
+	MessagesIn float64 // average rate
+	MaxLag     float64
+	Errors     int64
+}
+
+type groupRow struct {
+	Group     string
+	Max, Last float64
+}
+
+type errorRow struct {
+	Kind  string
+	Count int64
+}
+
+func buildReport(dataDir string, from, to time.Time, samples []store.MetricSample) *report {
+	r := &report{DataDir: dataDir, From: from, To: to, Samples: len(samples)}
+
+	values := make(map[string][]float64)
+	groups := make(map[string]*groupRow)
+	errs := make(map[string]int64)
+	for _, s := range samples {
+		switch s.Name {
+		case engine.MetricConsumerLag:
+			g := groups[s.Label]
+			if g == nil {
+				g = &groupRow{Group: s.Label}
+				groups[s.Label] = g
+			}
+			g.Max = max(g.Max, s.Value)
+			g.Last = s.Value
+		case engine.MetricErrors:
+			errs[s.Label] += int64(s.Value)
+			r.ErrorTotal += int64(s.Value)
+		default:
+			values[s.Name] = append(values[s.Name], s.Value)
+		}
+	}
+
+	for _, m := range []struct{ name, metric string }{
+		{"Produce", engine.MetricProduceLatency},
+		{"Fetch", engine.MetricFetchLatency},
+	} {
+		v := values[m.metric]
+		if len(v) == 0 {
+			continue
+		}
+		sort.Float64s(v)
+		r.Latency = append(r.Latency, latencyRow{
+			Name: m.name, Samples: len(v),
+			P50: percentile(v, 50), P99: percentile(v, 99), Max: v[len(v)-1],
+		})
+	}
+	for _, m := range []struct{ name, metric string }{
+		{"Produce requests/s", engine.MetricProduceRate},
+		{"Fetch requests/s", engine.MetricFetchRate},
+		{"Messages in/s", engine.MetricMessagesIn},
+	} {
+		v := values[m.metric]
+		if len(v) == 0 {
+			continue
+		}
+		row := throughputRow{Name: m.name}
+		for _, x := range v {
+			row.Avg += x
+			row.Peak = max(row.Peak, x)
+		}
+		row.Avg /= float64(len(v))
+		r.Throughput = append(r.Throughput, row)
+	}
+
+	for _, g := range groups {
+		r.Groups = append(r.Groups, *g)
+	}
+	sort.Slice(r.Groups, func(i, j int) bool { return r.Groups[i].Group < r.Groups[j].Group })
+	for kind, n := range errs {
+		r.Errors = append(r.Errors, errorRow{Kind: kind, Count: n})
+	}
+	sort.Slice(r.Errors, func(i, j int) bool { return r.Errors[i].Count > r.Errors[j].Count })
+
+	r.History = history(from, to, samples)
+	return r
+}
+
+// history splits [from, to) into reportBuckets slices and summarizes the
+// samples in each, leaving out slices nothing was recorded in
+func history(from, to time.Time, samples []store.MetricSample) []historyRow {
+	width := to.Sub(from) / reportBuckets
+	if width <= 0 {
+		width = to.Sub(from)
+	}
+	type bucket struct {
+		row     historyRow
+		in      float64
+		inCount int
+		seen    bool
+	}
+	buckets := make([]bucket, reportBuckets)
+	for i := range buckets {
+		buckets[i].row = historyRow{Start: from.Add(time.Duration(i) * width), ProduceP99: -1, FetchP99: -1}
+	}
+	for _, s := range samples {
+		i := int(s.Time.Sub(from) / width)
+		if i < 0 || i >= reportBuckets {
+			continue
+		}
+		b := &buckets[i]
+		b.seen = true
+		switch s.Name {
+		case engine.MetricProduceLatency:
+			b.row.ProduceP99 = max(b.row.ProduceP99, s.Value)
+		case engine.MetricFetchLatency:
+			b.row.FetchP99 = max(b.row.FetchP99, s.Value)
+		case engine.MetricMessagesIn:
+			b.in += s.Value
+			b.inCount++
+		case engine.MetricConsumerLag:
+			b.row.MaxLag = max(b.row.MaxLag, s.Value)
+		case engine.MetricErrors:
+			b.row.Errors += int64(s.Value)
+		}
+	}
+
+	var rows []historyRow
+	for _, b := range buckets {
+		if !b.seen {
+			continue
+		}
+		if b.inCount > 0 {
+			b.row.MessagesIn = b.in / float64(b.inCount)
+		}
+		rows = append(rows, b.row)
+	}
+	return rows
+}
+
+// percentile returns the p-th percentile (0-100) of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	return sorted[int(p/100*float64(len(sorted)-1))]
+}
+
+var reportFuncs = map[string]any{
+	"time": func(t time.Time) string { return t.Format(time.RFC3339) },
+	"num":  func(v float64) string { return fmt.Sprintf("%.1f", v) },
+	"ms": func(v float64) string {
+		if v < 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.2f", v)
+	},
+	"md": func(s string) string { return strings.ReplaceAll(s, "|", `\|`) },
+}
+
+var markdownReport = template.Must(template.New("markdown").Funcs(reportFuncs).Parse(
+	`# Monolog report
+
+- Data directory: ` + "`{{.DataDir}}`" + `
+- Window: {{time .From}} to {{time .To}}
+- Samples: {{.Samples}}
+{{if not .Samples}}
+No metrics were recorded in this window. The broker records them every
+metrics.interval while it runs.
+{{else}}
+## Latency (p99 per sample, ms)
+
+| Operation | Samples | p50 | p99 | Max |
+|---|---:|---:|---:|---:|
+{{range .Latency}}| {{.Name}} | {{.Samples}} | {{ms .P50}} | {{ms .P99}} | {{ms .Max}} |
+{{end}}
+## Throughput
+
+| Rate | Average | Peak |
+|---|---:|---:|
+{{range .Throughput}}| {{.Name}} | {{num .Avg}} | {{num .Peak}} |
+{{end}}
+## Consumer lag
+
+{{if .Groups}}| Group | Max | Last |
+|---|---:|---:|
+{{range .Groups}}| {{md .Group}} | {{num .Max}} | {{num .Last}} |
+{{end}}{{else}}No group had committed offsets.
+{{end}}
+## Errors
+
+{{if .Errors}}| Kind | Count |
+|---|---:|
+{{range .Errors}}| {{.Kind}} | {{.Count}} |
+{{end}}| **Total** | **{{.ErrorTotal}}** |
+{{else}}No failed requests.
+{{end}}
+## History
+
+| From | Produce p99 ms | Fetch p99 ms | Messages in/s | Max lag | Errors |
+|---|---:|---:|---:|---:|---:|
+{{range .History}}| {{time .Start}} | {{ms .ProduceP99}} | {{ms .FetchP99}} | {{num .MessagesIn}} | {{num .MaxLag}} | {{.Errors}} |
+{{end}}{{end}}`))
+
+var htmlReport = htmltemplate.Must(htmltemplate.New("html").Funcs(reportFuncs).Parse(
+	`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Monolog report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; }
+td.n { text-align: right; font-variant-numeric: tabular-nums; }
+</style>
+</head>
+<body>
+<h1>Monolog report</h1>
+<ul>
+<li>Data directory: <code>{{.DataDir}}</code></li>
+<li>Window: {{time .From}} to {{time .To}}</li>
+<li>Samples: {{.Samples}}</li>
+</ul>
+{{if not .Samples}}
+<p>No metrics were recorded in this window. The broker records them every metrics.interval while it runs.</p>
+{{else}}
+<h2>Latency (p99 per sample, ms)</h2>
+<table>
+<tr><th>Operation</th><th>Samples</th><th>p50</th><th>p99</th><th>Max</th></tr>
+{{range .Latency}}<tr><td>{{.Name}}</td><td class="n">{{.Samples}}</td><td class="n">{{ms .P50}}</td><td class="n">{{ms .P99}}</td><td class="n">{{ms .Max}}</td></tr>
+{{end}}</table>
+<h2>Throughput</h2>
+<table>
+<tr><th>Rate</th><th>Average</th><th>Peak</th></tr>
+{{range .Throughput}}<tr><td>{{.Name}}</td><td class="n">{{num .Avg}}</td><td class="n">{{num .Peak}}</td></tr>
+{{end}}</table>
+<h2>Consumer lag</h2>
+{{if .Groups}}<table>
+<tr><th>Group</th><th>Max</th><th>Last</th></tr>
+{{range .Groups}}<tr><td>{{.Group}}</td><td class="n">{{num .Max}}</td><td class="n">{{num .Last}}</td></tr>
+{{end}}</table>
+{{else}}<p>No group had committed offsets.</p>
+{{end}}
+<h2>Errors</h2>
+{{if .Errors}}<table>
+<tr><th>Kind</th><th>Count</th></tr>
+{{range .Errors}}<tr><td>{{.Kind}}</td><td class="n">{{.Count}}</td></tr>
+{{end}}<tr><th>Total</th><th class="n">{{.ErrorTotal}}</th></tr>
+</table>
+{{else}}<p>No failed requests.</p>
+{{end}}
+<h2>History</h2>
+<table>
+<tr><th>From</th><th>Produce p99 ms</th><th>Fetch p99 ms</th><th>Messages in/s</th><th>Max lag</th><th>Errors</th></tr>
+{{range .History}}<tr><td>{{time .Start}}</td><td class="n">{{ms .ProduceP99}}</td><td class="n">{{ms .FetchP99}}</td><td class="n">{{num .MessagesIn}}</td><td class="n">{{num .MaxLag}}</td><td class="n">{{.Errors}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
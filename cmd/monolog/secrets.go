@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rizkyandriawan/monolog/internal/cli"
+	"github.com/rizkyandriawan/monolog/internal/config"
+	"github.com/rizkyandriawan/monolog/pkg/client"
+)
+
+func runSecrets(args []string) {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, `Usage:
+  monolog secrets keygen [file]
+  monolog secrets encrypt [options] [value]
+  monolog secrets rotate [options]
+
+keygen creates a master key; encrypt prints a value encrypted under the
+master key, to paste into the config file; rotate re-encrypts the config
+file's secrets under a new master key and has a running server reload them.`)
+	}
+	if len(args) < 1 {
+		usage()
+		os.Exit(cli.ExitUsage)
+	}
+
+	switch args[0] {
+	case "keygen":
+		runSecretsKeygen(args[1:])
+	case "encrypt":
+		runSecretsEncrypt(args[1:])
+	case "rotate":
+		runSecretsRotate(args[1:])
+	case "help", "-h", "--help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown secrets command: %s\n", args[0])
+		usage()
+		os.Exit(cli.ExitUsage)
+	}
+}
+
+func runSecretsKeygen(args []string) {
+	fs := flag.NewFlagSet("secrets keygen", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: monolog secrets keygen [file]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(cli.ExitUsage)
+	}
+
+	key, err := config.GenerateMasterKey()
+	if err != nil {
+		cli.Fail(cli.FormatTable, err)
+	}
+	if fs.NArg() == 0 {
+		fmt.Println(hex.EncodeToString(key))
+		return
+	}
+	if err := writeKeyFile(fs.Arg(0), key); err != nil {
+		cli.Fail(cli.FormatTable, err)
+	}
+}
+
+func runSecretsEncrypt(args []string) {
+	fs := flag.NewFlagSet("secrets encrypt", flag.ExitOnError)
+	keyFile := fs.String("key-file", "", "Master key file (default: MONOLOG_MASTER_KEY or MONOLOG_MASTER_KEY_FILE)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: monolog secrets encrypt [options] [value]  (reads the value from stdin when omitted)")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(cli.ExitUsage)
+	}
+
+	cfg := &config.Config{Security: config.SecurityConfig{MasterKeyFile: *keyFile}}
+	key, err := cfg.MasterKey()
+	if err != nil {
+		cli.Fail(cli.FormatTable, err)
+	}
+	if key == nil {
+		cli.Fail(cli.FormatTable, &cli.UsageError{Err: config.ErrNoMasterKey})
+	}
+
+	value := fs.Arg(0)
+	if fs.NArg() == 0 {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			cli.Fail(cli.FormatTable, fmt.Errorf("read value: %w", err))
+		}
+		value = strings.TrimRight(line, "\r\n")
+	}
+	encrypted, err := config.EncryptSecret(key, value)
+	if err != nil {
+		cli.Fail(cli.FormatTable, err)
+	}
+	fmt.Println(encrypted)
+}
+
+func runSecretsRotate(args []string) {
+	fs := flag.NewFlagSet("secrets rotate", flag.ExitOnError)
+	configFile := fs.String("config", "", "Config file whose secrets to re-encrypt (required)")
+	newKeyFile := fs.String("new-key-file", "", "Read the new master key from this file instead of generating one; required when the current key comes from MONOLOG_MASTER_KEY, and written to if it does not exist")
+	target := fs.String("target", "", "HTTP address of a running server to reload the secrets into (default: none)")
+	token := fs.String("token", os.Getenv("MONOLOG_AUTH_TOKEN"), "API token for the target")
+	output := cli.OutputFlag(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: monolog secrets rotate [options]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	format, err := cli.ParseFormat(*output)
+	if err != nil {
+		cli.Fail(cli.FormatTable, &cli.UsageError{Err: err})
+	}
+	if *configFile == "" || fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(cli.ExitUsage)
+	}
+
+	// Loading the config proves the current key decrypts it
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		cli.Fail(format, fmt.Errorf("load config: %w", err))
+	}
+	oldKey, err := cfg.MasterKey()
+	if err != nil {
+		cli.Fail(format, err)
+	}
+	if oldKey == nil {
+		cli.Fail(format, &cli.UsageError{Err: config.ErrNoMasterKey})
+	}
+	keyFile := cfg.MasterKeyFile()
+	if keyFile == "" && *newKeyFile == "" {
+		cli.Fail(format, cli.Usagef("the master key comes from MONOLOG_MASTER_KEY; pass -new-key-file to say where the new key goes"))
+	}
+
+	newKey, err := newMasterKey(*newKeyFile)
+	if err != nil {
+		cli.Fail(format, err)
+	}
+
+	data, err := os.ReadFile(*configFile)
+	if err != nil {
+		cli.Fail(format, err)
+	}
+	rotated, n, err := config.RotateSecrets(data, oldKey, newKey)
+	if err != nil {
+		cli.Fail(format, fmt.Errorf("rotate %s: %w", *configFile, err))
+	}
+
+	// Replace the key before the config: if we stop in between, the
+	// config still decrypts with the old key, kept next to the new one
+	if keyFile != "" {
+		if err := backupFile(keyFile); err != nil {
+			cli.Fail(format, err)
+		}
+		if err := writeKeyFile(keyFile, newKey); err != nil {
+			cli.Fail(format, err)
+		}
+	}
+	info, err := os.Stat(*configFile)
+	if err != nil {
+		cli.Fail(format, err)
+	}
+	if err := writeFileAtomic(*configFile, rotated, info.Mode().Perm()); err != nil {
+		cli.Fail(format, err)
+	}
+
+	result := struct {
+		Config   string `json:"config"`
+		Rotated  int    `json:"rotated"`
+		KeyFile  string `json:"key_file"`
+		Reloaded bool   `json:"reloaded"`
+	}{Config: *configFile, Rotated: n, KeyFile: keyFile}
+	if keyFile == "" {
+		result.KeyFile = *newKeyFile
+	}
+
+	if *target != "" {
+		if keyFile == "" {
+			fmt.Fprintln(os.Stderr, "not reloading: the server reads its key from MONOLOG_MASTER_KEY; set it to the new key and restart")
+		} else {
+			c := client.New(*target, client.WithToken(*token))
+			if err := c.ReloadSecrets(context.Background()); err != nil {
+				cli.Fail(format, fmt.Errorf("secrets rotated, but the server did not reload them: %w", err))
+			}
+			result.Reloaded = true
+		}
+	}
+
+	cli.Render(os.Stdout, format, result, func() *cli.Table {
+		t := cli.NewTable("CONFIG", "ROTATED", "KEY FILE", "RELOADED")
+		t.AddRow(result.Config, result.Rotated, result.KeyFile, result.Reloaded)
+		return t
+	})
+}
+
+// newMasterKey reads the key in path, or generates one and writes it there
+// when path does not exist. With no path it only generates one.
+func newMasterKey(path string) ([]byte, error) {
+	if path != "" {
+		key, err := config.ReadMasterKey(path)
+		if err == nil || !os.IsNotExist(err) {
+			return key, err
+		}
+	}
+	key, err := config.GenerateMasterKey()
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		if err := writeKeyFile(path, key); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+func writeKeyFile(path string, key []byte) error {
+	return writeFileAtomic(path, []byte(hex.EncodeToString(key)+"\n"), 0600)
+}
+
+// backupFile copies path to path.old
+func backupFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path+".old", data, 0600)
+}
+
+// writeFileAtomic replaces path with data through a rename, so readers see
+// either the old or the new contents
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
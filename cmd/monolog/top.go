@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rizkyandriawan/monolog/internal/cli"
+	"github.com/rizkyandriawan/monolog/pkg/client"
+)
+
+// topSnapshot is one poll of the server, as shown by `monolog top`
+type topSnapshot struct {
+	Time        time.Time            `json:"time"`
+	Connections int                  `json:"connections"`
+	Pending     int                  `json:"pending"`
+	Topics      []topTopic           `json:"topics"`
+	Groups      []topGroup           `json:"groups"`
+	Errors      []client.RecentError `json:"recent_errors"`
+}
+
+type topTopic struct {
+	Name         string  `json:"name"`
+	LatestOffset int64   `json:"latest_offset"`
+	Rate         float64 `json:"messages_per_sec"`
+}
+
+type topGroup struct {
+	ID      string `json:"id"`
+	State   string `json:"state"`
+	Members int    `json:"members"`
+	Lag     int64  `json:"lag"` // summed over the group's committed topics
+}
+
+func runTop(args []string) {
+	fs := flag.NewFlagSet("top", flag.ExitOnError)
+
+	target := fs.String("target", "http://localhost:8080", "HTTP address of the instance to watch")
+	token := fs.String("token", os.Getenv("MONOLOG_AUTH_TOKEN"), "API token for the target")
+	interval := fs.Duration("interval", 2*time.Second, "Refresh interval")
+	output := cli.OutputFlag(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: monolog top [options]")
+		fmt.Fprintln(os.Stderr, "\nWith --output json, prints a single snapshot (rates measured over one interval) and exits.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	format, err := cli.ParseFormat(*output)
+	if err != nil {
+		cli.Fail(cli.FormatTable, &cli.UsageError{Err: err})
+	}
+	if *interval <= 0 {
+		cli.Fail(format, cli.Usagef("interval must be positive"))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	c := client.New(*target, client.WithToken(*token))
+
+	// Fail before taking over the terminal if the server is unusable
+	prev, err := pollTop(ctx, c, nil)
+	if err != nil {
+		cli.Fail(format, err)
+	}
+
+	if format == cli.FormatJSON {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(*interval):
+		}
+		snap, err := pollTop(ctx, c, prev)
+		if err != nil {
+			cli.Fail(format, err)
+		}
+		cli.Render(os.Stdout, format, snap, nil)
+		return
+	}
+
+	screen, err := tcell.NewScreen()
+	if err == nil {
+		err = screen.Init()
+	}
+	if err != nil {
+		cli.Fail(format, fmt.Errorf("init terminal: %w", err))
+	}
+	defer screen.Fini()
+
+	events := make(chan tcell.Event)
+	go func() {
+		for {
+			ev := screen.PollEvent()
+			if ev == nil {
+				return
+			}
+			events <- ev
+		}
+	}()
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	snap, pollErr := prev, error(nil)
+	drawTop(screen, *target, snap, pollErr)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			next, err := pollTop(ctx, c, snap)
+			if err == nil {
+				snap = next
+			}
+			pollErr = err
+
+		case ev := <-events:
+			switch ev := ev.(type) {
+			case *tcell.EventKey:
+				if ev.Key() == tcell.KeyEscape || ev.Key() == tcell.KeyCtrlC || ev.Rune() == 'q' {
+					return
+				}
+			case *tcell.EventResize:
+				screen.Sync()
+			}
+		}
+		drawTop(screen, *target, snap, pollErr)
+	}
+}
+
+// pollTop gathers a snapshot; rates are computed against prev when given
+func pollTop(ctx context.Context, c *client.Client, prev *topSnapshot) (*topSnapshot, error) {
+	stats, err := c.Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	topics, err := c.ListTopics(ctx)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := c.ListGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &topSnapshot{
+		Time:        time.Now(),
+		Connections: stats.Connections,
+		Pending:     stats.Pending,
+		Errors:      stats.RecentErrors,
+	}
+
+	latest := make(map[string]int64, len(topics))
+	previous := make(map[string]int64)
+	if prev != nil {
+		for _, t := range prev.Topics {
+			previous[t.Name] = t.LatestOffset
+		}
+	}
+	for _, t := range topics {
+		latest[t.Name] = t.LatestOffset
+		tt := topTopic{Name: t.Name, LatestOffset: t.LatestOffset}
+		if before, ok := previous[t.Name]; ok {
+			if elapsed := snap.Time.Sub(prev.Time).Seconds(); elapsed > 0 {
+				tt.Rate = float64(t.LatestOffset-before) / elapsed
+			}
+		}
+		snap.Topics = append(snap.Topics, tt)
+	}
+	sort.Slice(snap.Topics, func(i, j int) bool { return snap.Topics[i].Name < snap.Topics[j].Name })
+
+	for _, g := range groups {
+		tg := topGroup{ID: g.ID, State: g.State, Members: g.Members}
+		if full, err := c.GetGroup(ctx, g.ID); err == nil {
+			for topic, committed := range full.Offsets {
+				if end, ok := latest[topic]; ok && committed >= 0 && end+1 > committed {
+					tg.Lag += end + 1 - committed
+				}
+			}
+		}
+		snap.Groups = append(snap.Groups, tg)
+	}
+	sort.Slice(snap.Groups, func(i, j int) bool { return snap.Groups[i].ID < snap.Groups[j].ID })
+
+	return snap, nil
+}
+
+// drawTop renders a snapshot: a status line, then topic, group and error
+// sections stacked until the screen runs out
+func drawTop(screen tcell.Screen, target string, snap *topSnapshot, pollErr error) {
+	screen.Clear()
+	width, height := screen.Size()
+
+	header := tcell.StyleDefault.Reverse(true)
+	bold := tcell.StyleDefault.Bold(true)
+	dim := tcell.StyleDefault.Dim(true)
+	red := tcell.StyleDefault.Foreground(tcell.ColorRed)
+
+	y := 0
+	line := func(style tcell.Style, format string, args ...interface{}) {
+		if y >= height {
+			return
+		}
+		text := []rune(fmt.Sprintf(format, args...))
+		for x := 0; x < width; x++ {
+			r := ' '
+			if x < len(text) {
+				r = text[x]
+			}
+			screen.SetContent(x, y, r, nil, style)
+		}
+		y++
+	}
+
+	line(header, " monolog top  %s  topics %d  groups %d  connections %d  pending fetches %d  (q to quit)",
+		target, len(snap.Topics), len(snap.Groups), snap.Connections, snap.Pending)
+	if pollErr != nil {
+		line(red, " refresh failed: %v (showing data from %s)", pollErr, snap.Time.Format("15:04:05"))
+	}
+
+	y++
+	line(bold, " %-40s %15s %12s", "TOPIC", "LATEST OFFSET", "MSG/S")
+	for _, t := range snap.Topics {
+		line(tcell.StyleDefault, " %-40s %15d %12.1f", t.Name, t.LatestOffset, t.Rate)
+	}
+	if len(snap.Topics) == 0 {
+		line(dim, " no topics")
+	}
+
+	y++
+	line(bold, " %-40s %-22s %8s %12s", "GROUP", "STATE", "MEMBERS", "LAG")
+	for _, g := range snap.Groups {
+		line(tcell.StyleDefault, " %-40s %-22s %8d %12d", g.ID, g.State, g.Members, g.Lag)
+	}
+	if len(snap.Groups) == 0 {
+		line(dim, " no consumer groups")
+	}
+
+	y++
+	line(bold, " RECENT ERRORS")
+	for _, e := range snap.Errors {
+		line(red, " %s  %-20s %s", e.Time.Local().Format("15:04:05"), e.Source, e.Message)
+	}
+	if len(snap.Errors) == 0 {
+		line(dim, " none")
+	}
+
+	screen.Show()
+}
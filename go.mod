@@ -5,14 +5,22 @@ go 1.23.0
 toolchain go1.24.12
 
 require (
+	github.com/gdamore/tcell/v2 v2.8.1
+	github.com/klauspost/compress v1.18.3
 	github.com/mattn/go-sqlite3 v1.14.33
+	github.com/pierrec/lz4/v4 v4.1.25
 	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/klauspost/compress v1.18.3 // indirect
+	github.com/gdamore/encoding v1.0.1 // indirect
 	github.com/kr/pretty v0.3.1 // indirect
-	github.com/pierrec/lz4/v4 v4.1.25 // indirect
+	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
+	github.com/mattn/go-runewidth v0.0.16 // indirect
+	github.com/rivo/uniseg v0.4.3 // indirect
 	github.com/rogpeppe/go-internal v1.13.1 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	golang.org/x/term v0.28.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 )
@@ -0,0 +1,65 @@
+package bench
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math/rand"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// buildBatch encodes a v2 record batch of n records with valueSize-byte
+// values, compressed with codec, the way a Kafka producer would send it.
+// Values are half random and half repeated so codecs have something to
+// compress without the result being trivially small.
+func buildBatch(n, valueSize int, codec int8) ([]byte, error) {
+	rng := rand.New(rand.NewSource(int64(n)*31 + int64(valueSize)))
+	value := make([]byte, valueSize)
+
+	var records []byte
+	for i := 0; i < n; i++ {
+		rng.Read(value[:valueSize/2])
+		for j := valueSize / 2; j < valueSize; j++ {
+			value[j] = 'a' + byte(j%26)
+		}
+		key := []byte(fmt.Sprintf("key-%d", i))
+
+		var rec []byte
+		rec = append(rec, 0) // attributes
+		rec = binary.AppendVarint(rec, 0)
+		rec = binary.AppendVarint(rec, int64(i))
+		rec = binary.AppendVarint(rec, int64(len(key)))
+		rec = append(rec, key...)
+		rec = binary.AppendVarint(rec, int64(len(value)))
+		rec = append(rec, value...)
+		rec = binary.AppendVarint(rec, 0) // headers
+
+		records = binary.AppendVarint(records, int64(len(rec)))
+		records = append(records, rec...)
+	}
+
+	body, err := kafkaproto.Compress(records, codec)
+	if err != nil {
+		return nil, fmt.Errorf("compress %s: %w", kafkaproto.CodecName(codec), err)
+	}
+
+	now := time.Now().UnixMilli()
+	batch := make([]byte, kafkaproto.RecordBatchHeaderSize, kafkaproto.RecordBatchHeaderSize+len(body))
+	binary.BigEndian.PutUint32(batch[8:12], uint32(kafkaproto.RecordBatchHeaderSize-12+len(body)))
+	batch[16] = 2 // magic
+	binary.BigEndian.PutUint16(batch[21:23], uint16(int16(codec)&kafkaproto.BatchAttrCodecMask))
+	binary.BigEndian.PutUint32(batch[23:27], uint32(n-1))
+	binary.BigEndian.PutUint64(batch[27:35], uint64(now))
+	binary.BigEndian.PutUint64(batch[35:43], uint64(now))
+	binary.BigEndian.PutUint64(batch[43:51], ^uint64(0)) // no producer ID
+	binary.BigEndian.PutUint16(batch[51:53], ^uint16(0))
+	binary.BigEndian.PutUint32(batch[53:57], ^uint32(0))
+	binary.BigEndian.PutUint32(batch[57:61], uint32(n))
+	batch = append(batch, body...)
+	binary.BigEndian.PutUint32(batch[17:21], crc32.Checksum(batch[21:], castagnoli))
+	return batch, nil
+}
@@ -0,0 +1,227 @@
+// Package bench is monolog's in-process produce path benchmark suite. Each
+// case pushes raw record batches through Engine.ProduceRaw into a storage
+// backend and reads them back with Fetch, so the cost of a storage change
+// shows up without a network in the way. Run measures the cases for
+// monolog bench; the same cases run under go test -bench as
+// BenchmarkProduceFetch.
+package bench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+	"github.com/rizkyandriawan/monolog/internal/engine"
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// Case is one backend, batch size and codec combination
+type Case struct {
+	Backend   string
+	BatchSize int // records per produced batch
+	Codec     int8
+	ValueSize int // bytes per record value
+}
+
+// Name returns the case's benchmark name, e.g. ProduceFetch/sqlite/batch=100/zstd
+func (c Case) Name() string {
+	return fmt.Sprintf("ProduceFetch/%s/batch=%d/%s", c.Backend, c.BatchSize, kafkaproto.CodecName(c.Codec))
+}
+
+// Result is the measured cost of one case
+type Result struct {
+	Name          string  `json:"name"`
+	Backend       string  `json:"backend"`
+	BatchSize     int     `json:"batch_size"`
+	Codec         string  `json:"codec"`
+	BatchBytes    int     `json:"batch_bytes"` // encoded size of one batch
+	Iterations    int     `json:"iterations"`
+	NsPerOp       int64   `json:"ns_per_op"` // one produce plus one fetch of the batch
+	RecordsPerSec float64 `json:"records_per_sec"`
+	MBPerSec      float64 `json:"mb_per_sec"`
+	AllocsPerOp   int64   `json:"allocs_per_op"`
+	BytesPerOp    int64   `json:"bytes_per_op"`
+}
+
+// Cases expands every combination of backends, batch sizes and codecs
+func Cases(backends []string, batchSizes []int, codecs []int8, valueSize int) []Case {
+	var cases []Case
+	for _, backend := range backends {
+		for _, size := range batchSizes {
+			for _, codec := range codecs {
+				cases = append(cases, Case{Backend: backend, BatchSize: size, Codec: codec, ValueSize: valueSize})
+			}
+		}
+	}
+	return cases
+}
+
+// target is a case ready to run: an engine over a fresh backend and the
+// batch to produce
+type target struct {
+	c       Case
+	eng     *engine.Engine
+	batch   []byte
+	cleanup func()
+}
+
+const benchTopic = "bench"
+
+// setup opens c's backend in a fresh temporary data directory, removed by
+// cleanup, and creates the topic to produce to
+func setup(c Case) (*target, error) {
+	batch, err := buildBatch(c.BatchSize, c.ValueSize, c.Codec)
+	if err != nil {
+		return nil, fmt.Errorf("build batch: %w", err)
+	}
+	eng, cleanup, err := openEngine(c.Backend)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", c.Backend, err)
+	}
+	if err := eng.CreateTopic(context.Background(), benchTopic); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("create topic: %w", err)
+	}
+	return &target{c: c, eng: eng, batch: batch, cleanup: cleanup}, nil
+}
+
+// op produces the batch and fetches it back: one operation of the case
+func (t *target) op(ctx context.Context) error {
+	offset, err := t.eng.ProduceRaw(ctx, benchTopic, t.batch, t.c.Codec, t.c.BatchSize)
+	if err != nil {
+		return fmt.Errorf("produce: %w", err)
+	}
+	records, err := t.eng.Fetch(ctx, benchTopic, offset, 1)
+	if err != nil {
+		return fmt.Errorf("fetch: %w", err)
+	}
+	if len(records) != 1 || len(records[0].Value) != len(t.batch) {
+		return fmt.Errorf("fetch at %d returned %d records", offset, len(records))
+	}
+	return nil
+}
+
+// Run measures each case for about benchTime, calling progress (if set)
+// with every result as it completes. As go test -bench does, it grows the
+// number of operations until a run lasts benchTime and reports that run.
+func Run(cases []Case, benchTime time.Duration, progress func(Result)) ([]Result, error) {
+	// Engine and store log every topic and error; keep the report readable
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(out)
+
+	var results []Result
+	for _, c := range cases {
+		res, err := measure(c, benchTime)
+		if err != nil {
+			return results, fmt.Errorf("%s: %w", c.Name(), err)
+		}
+		results = append(results, res)
+		if progress != nil {
+			progress(res)
+		}
+	}
+	return results, nil
+}
+
+// maxOps caps the operations in one run, as go test -bench caps b.N
+const maxOps = 1e9
+
+func measure(c Case, benchTime time.Duration) (Result, error) {
+	t, err := setup(c)
+	if err != nil {
+		return Result{}, err
+	}
+	defer t.cleanup()
+
+	ctx := context.Background()
+	if err := t.op(ctx); err != nil { // warm up
+		return Result{}, err
+	}
+
+	var before, after runtime.MemStats
+	n := 1
+	for {
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+		for i := 0; i < n; i++ {
+			if err := t.op(ctx); err != nil {
+				return Result{}, err
+			}
+		}
+		elapsed := time.Since(start)
+		runtime.ReadMemStats(&after)
+
+		if elapsed >= benchTime || n >= maxOps {
+			res := Result{
+				Name:        c.Name(),
+				Backend:     c.Backend,
+				BatchSize:   c.BatchSize,
+				Codec:       kafkaproto.CodecName(c.Codec),
+				BatchBytes:  len(t.batch),
+				Iterations:  n,
+				NsPerOp:     elapsed.Nanoseconds() / int64(n),
+				AllocsPerOp: int64(after.Mallocs-before.Mallocs) / int64(n),
+				BytesPerOp:  int64(after.TotalAlloc-before.TotalAlloc) / int64(n),
+			}
+			if secs := elapsed.Seconds(); secs > 0 {
+				res.RecordsPerSec = float64(n*c.BatchSize) / secs
+				res.MBPerSec = float64(len(t.batch)*n) / 1e6 / secs
+			}
+			return res, nil
+		}
+		n = nextOps(n, elapsed, benchTime)
+	}
+}
+
+// nextOps predicts how many operations the next run needs to last
+// benchTime, overshooting a little and growing at most a hundredfold
+func nextOps(n int, elapsed, benchTime time.Duration) int {
+	next := n * 100
+	if perOp := elapsed.Nanoseconds() / int64(n); perOp > 0 {
+		next = min(next, int(benchTime.Nanoseconds()/perOp*6/5))
+	}
+	return min(max(next, n+1), maxOps)
+}
+
+// openEngine opens backend with default settings and wraps it in an
+// engine without background schedulers
+func openEngine(backend string) (*engine.Engine, func(), error) {
+	cfg := config.Default()
+	cfg.Storage.Backend = backend
+
+	dir := ""
+	if !store.IsInMemory(backend) {
+		var err error
+		dir, err = os.MkdirTemp("", "monolog-bench-")
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg.Storage.DataDir = dir
+	}
+
+	opened, err := store.Open(backend, cfg.Storage)
+	if err != nil {
+		if dir != "" {
+			os.RemoveAll(dir)
+		}
+		return nil, nil, err
+	}
+
+	eng := engine.New(cfg, opened.Topics, opened.Groups)
+	cleanup := func() {
+		eng.Stop()
+		opened.Close()
+		if dir != "" {
+			os.RemoveAll(dir)
+		}
+	}
+	return eng, cleanup, nil
+}
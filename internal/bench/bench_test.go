@@ -0,0 +1,49 @@
+package bench
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// BenchmarkProduceFetch runs every backend, batch size and codec case, as
+// monolog bench --internal does with its defaults
+func BenchmarkProduceFetch(b *testing.B) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(out)
+
+	var codecs []int8
+	for _, name := range kafkaproto.AvailableCodecNames() {
+		id, err := kafkaproto.ParseCodec(name)
+		if err != nil {
+			b.Fatal(err)
+		}
+		codecs = append(codecs, id)
+	}
+
+	for _, c := range Cases(store.Backends(), []int{1, 10, 100, 1000}, codecs, 256) {
+		b.Run(strings.TrimPrefix(c.Name(), "ProduceFetch/"), func(b *testing.B) {
+			t, err := setup(c)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer t.cleanup()
+
+			ctx := context.Background()
+			b.SetBytes(int64(len(t.batch)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := t.op(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
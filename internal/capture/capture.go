@@ -0,0 +1,210 @@
+// Package capture records produced traffic to a replay file and plays it
+// back, preserving the original timing between produce calls.
+//
+// A capture file is JSON lines: one Entry per produce call, in the order
+// the produce calls completed.
+package capture
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Entry is one captured produce call. Raw holds a Kafka v2 record batch
+// exactly as it was produced; otherwise Records holds plain messages.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Topic   string    `json:"topic"`
+	Raw     []byte    `json:"raw,omitempty"`
+	Codec   int8      `json:"codec,omitempty"`
+	Count   int       `json:"count"`
+	Records []Record  `json:"records,omitempty"`
+}
+
+// Record is a plain captured message
+type Record struct {
+	Key   []byte `json:"key,omitempty"`
+	Value []byte `json:"value"`
+}
+
+// ============================================================================
+// Recorder
+// ============================================================================
+
+// Recorder appends entries to a capture file
+type Recorder struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	w       *bufio.Writer
+	enc     *json.Encoder
+	started time.Time
+	entries int64
+}
+
+// NewRecorder opens path for appending and starts recording
+func NewRecorder(path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open capture file: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	return &Recorder{
+		path:    path,
+		file:    f,
+		w:       w,
+		enc:     json.NewEncoder(w),
+		started: time.Now(),
+	}, nil
+}
+
+// Record writes one entry. Entries are flushed immediately so a crash
+// loses at most the entry being written.
+func (r *Recorder) Record(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return fmt.Errorf("capture closed")
+	}
+	if err := r.enc.Encode(e); err != nil {
+		return err
+	}
+	r.entries++
+	return r.w.Flush()
+}
+
+// Status describes an active recording
+type Status struct {
+	Path    string    `json:"path"`
+	Started time.Time `json:"started"`
+	Entries int64     `json:"entries"`
+}
+
+// Status returns the recording's path and progress
+func (r *Recorder) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return Status{Path: r.path, Started: r.started, Entries: r.entries}
+}
+
+// Close flushes and closes the capture file
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.w.Flush()
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	r.file = nil
+	return err
+}
+
+// ============================================================================
+// Replay
+// ============================================================================
+
+// Sink receives replayed produce calls
+type Sink interface {
+	AppendRaw(topic string, data []byte, codec int8, count int) error
+	Append(topic string, records []Record) error
+}
+
+// ReplayOptions controls playback
+type ReplayOptions struct {
+	// Speed scales the original gaps between entries: 2 plays twice as
+	// fast, 0.5 at half speed. Zero or negative replays without delays.
+	Speed float64
+
+	// Topics restricts playback to the listed topics (empty = all)
+	Topics []string
+
+	// Scrub rewrites entries before they are produced (nil = as captured)
+	Scrub *Scrubber
+}
+
+// ReplayStats summarizes a replay
+type ReplayStats struct {
+	Entries  int64         `json:"entries"`
+	Records  int64         `json:"records"`
+	Scrubbed int64         `json:"scrubbed,omitempty"` // records changed by scrubbing
+	Duration time.Duration `json:"duration"`
+}
+
+// Replay reads entries from r and produces them into sink, sleeping the
+// scaled original gap between consecutive entries
+func Replay(ctx context.Context, r io.Reader, sink Sink, opts ReplayOptions) (ReplayStats, error) {
+	var stats ReplayStats
+	start := time.Now()
+
+	topics := make(map[string]bool, len(opts.Topics))
+	for _, t := range opts.Topics {
+		topics[t] = true
+	}
+
+	dec := json.NewDecoder(bufio.NewReader(r))
+	var first time.Time
+	for {
+		var e Entry
+		if err := dec.Decode(&e); err == io.EOF {
+			stats.Duration = time.Since(start)
+			return stats, nil
+		} else if err != nil {
+			return stats, fmt.Errorf("entry %d: %w", stats.Entries, err)
+		}
+		if len(topics) > 0 && !topics[e.Topic] {
+			continue
+		}
+
+		if opts.Speed > 0 {
+			if first.IsZero() {
+				first = e.Time
+			}
+			due := start.Add(time.Duration(float64(e.Time.Sub(first)) / opts.Speed))
+			if wait := time.Until(due); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return stats, ctx.Err()
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		if opts.Scrub != nil {
+			n, err := opts.Scrub.Entry(&e)
+			if err != nil {
+				return stats, fmt.Errorf("entry %d (%s): scrub: %w", stats.Entries, e.Topic, err)
+			}
+			stats.Scrubbed += int64(n)
+		}
+
+		var err error
+		if e.Raw != nil {
+			err = sink.AppendRaw(e.Topic, e.Raw, e.Codec, e.Count)
+		} else {
+			err = sink.Append(e.Topic, e.Records)
+		}
+		if err != nil {
+			return stats, fmt.Errorf("entry %d (%s): %w", stats.Entries, e.Topic, err)
+		}
+		stats.Entries++
+		stats.Records += int64(e.Count)
+	}
+}
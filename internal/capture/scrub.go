@@ -0,0 +1,235 @@
+package capture
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+)
+
+// ============================================================================
+// Scrubbing
+//
+// A Scrubber rewrites messages on their way from a capture file into a
+// store: it drops headers, hashes keys, masks JSON fields and redacts
+// patterns in values, in that order. Kafka record batches are decoded,
+// scrubbed and re-encoded with their original codec; batches nothing
+// matched in are passed through byte-for-byte.
+// ============================================================================
+
+// maskedValue replaces the contents of a masked JSON field
+const maskedValue = "***"
+
+// defaultRedaction replaces a redacted match when a rule sets none
+const defaultRedaction = "[REDACTED]"
+
+// Scrubber applies a config.ScrubConfig to replayed entries
+type Scrubber struct {
+	mask     map[string]bool
+	hashKeys bool
+	salt     []byte
+	redact   []redaction
+	stripAll bool
+	strip    map[string]bool
+}
+
+type redaction struct {
+	re      *regexp.Regexp
+	replace []byte
+}
+
+// NewScrubber compiles scrub rules. It returns nil when cfg scrubs nothing.
+func NewScrubber(cfg config.ScrubConfig) (*Scrubber, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	s := &Scrubber{
+		mask:     make(map[string]bool, len(cfg.MaskFields)),
+		hashKeys: cfg.HashKeys,
+		salt:     []byte(cfg.HashSalt),
+		strip:    make(map[string]bool, len(cfg.StripHeaders)),
+	}
+	for _, f := range cfg.MaskFields {
+		s.mask[f] = true
+	}
+	for _, h := range cfg.StripHeaders {
+		if h == "*" {
+			s.stripAll = true
+		}
+		s.strip[h] = true
+	}
+	for i, r := range cfg.Redact {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redact rule %d: %w", i, err)
+		}
+		replace := r.Replace
+		if replace == "" {
+			replace = defaultRedaction
+		}
+		s.redact = append(s.redact, redaction{re: re, replace: []byte(replace)})
+	}
+	return s, nil
+}
+
+// Entry scrubs a captured entry in place and returns how many of its
+// records were changed. An entry that cannot be decoded is an error
+// rather than being replayed unscrubbed.
+func (s *Scrubber) Entry(e *Entry) (int, error) {
+	if e.Raw == nil {
+		changed := 0
+		for i, r := range e.Records {
+			key, value, ok := s.Message(r.Key, r.Value)
+			if ok {
+				e.Records[i] = Record{Key: key, Value: value}
+				changed++
+			}
+		}
+		return changed, nil
+	}
+
+	batches, err := kafkaproto.SplitRecordBatches(e.Raw)
+	if err != nil {
+		return 0, err
+	}
+	var out []byte
+	changed := 0
+	for i, b := range batches {
+		raw, n, err := s.batch(b)
+		if err != nil {
+			return 0, fmt.Errorf("batch %d: %w", i, err)
+		}
+		out = append(out, raw...)
+		changed += n
+	}
+	if changed > 0 {
+		e.Raw = out
+	}
+	return changed, nil
+}
+
+// Message scrubs a plain message. It reports whether any rule changed it.
+func (s *Scrubber) Message(key, value []byte) ([]byte, []byte, bool) {
+	key, value, _, ok := s.scrub(key, value, nil)
+	return key, value, ok
+}
+
+// batch scrubs one record batch, re-encoding it only if a record changed
+func (s *Scrubber) batch(b *kafkaproto.RecordBatch) ([]byte, int, error) {
+	if b.Attributes&kafkaproto.BatchAttrControl != 0 {
+		return b.RawRecords, 0, nil // transaction markers carry no user data
+	}
+	records, err := b.DecodeRecords()
+	if err != nil {
+		return nil, 0, err
+	}
+	changed := 0
+	for i, r := range records {
+		key, value, headers, ok := s.scrub(r.Key, r.Value, r.Headers)
+		if ok {
+			records[i].Key, records[i].Value, records[i].Headers = key, value, headers
+			changed++
+		}
+	}
+	if changed == 0 {
+		return b.RawRecords, 0, nil
+	}
+	raw, err := b.EncodeRecords(records)
+	return raw, changed, err
+}
+
+// scrub applies every rule to one message
+func (s *Scrubber) scrub(key, value []byte, headers []kafkaproto.RecordHeader) ([]byte, []byte, []kafkaproto.RecordHeader, bool) {
+	changed := false
+
+	if len(headers) > 0 && (s.stripAll || len(s.strip) > 0) {
+		kept := headers[:0:0]
+		for _, h := range headers {
+			if !s.stripAll && !s.strip[h.Key] {
+				kept = append(kept, h)
+			}
+		}
+		if len(kept) != len(headers) {
+			headers = kept
+			changed = true
+		}
+	}
+
+	if s.hashKeys && key != nil {
+		sum := sha256.Sum256(append(append([]byte{}, s.salt...), key...))
+		key = []byte(hex.EncodeToString(sum[:]))
+		changed = true
+	}
+
+	if len(s.mask) > 0 {
+		if masked, ok := s.maskJSON(value); ok {
+			value = masked
+			changed = true
+		}
+	}
+
+	for _, r := range s.redact {
+		if value == nil {
+			break
+		}
+		if redacted := r.re.ReplaceAll(value, r.replace); !bytes.Equal(redacted, value) {
+			value = redacted
+			changed = true
+		}
+	}
+
+	return key, value, headers, changed
+}
+
+// maskJSON masks fields of a JSON value. Values that are not a JSON object
+// or array, or hold none of the fields, are left alone.
+func (s *Scrubber) maskJSON(value []byte) ([]byte, bool) {
+	trimmed := bytes.TrimSpace(value)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return value, false
+	}
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return value, false
+	}
+	if !s.maskValue(v) {
+		return value, false
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return value, false
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), true
+}
+
+func (s *Scrubber) maskValue(v interface{}) bool {
+	changed := false
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			if s.mask[k] {
+				t[k] = maskedValue
+				changed = true
+			} else if s.maskValue(child) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, child := range t {
+			if s.maskValue(child) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/rizkyandriawan/monolog/pkg/client"
+)
+
+// Exit codes returned by every subcommand
+const (
+	ExitOK          = 0 // success
+	ExitError       = 1 // unclassified failure
+	ExitUsage       = 2 // bad flags, arguments or request
+	ExitNotFound    = 3 // topic, group or file does not exist
+	ExitAuth        = 4 // missing or rejected credentials
+	ExitServer      = 5 // the server answered with an internal error
+	ExitUnavailable = 6 // the server could not be reached
+)
+
+// UsageError marks an error caused by how the command was invoked
+type UsageError struct {
+	Err error
+}
+
+func (e *UsageError) Error() string { return e.Err.Error() }
+func (e *UsageError) Unwrap() error { return e.Err }
+
+// Usagef returns a UsageError with a formatted message
+func Usagef(format string, args ...interface{}) error {
+	return &UsageError{Err: fmt.Errorf(format, args...)}
+}
+
+// ExitCode maps err to the documented exit code
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	var usageErr *UsageError
+	if errors.As(err, &usageErr) {
+		return ExitUsage
+	}
+
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.StatusCode == http.StatusNotFound:
+			return ExitNotFound
+		case apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden:
+			return ExitAuth
+		case apiErr.StatusCode == http.StatusServiceUnavailable:
+			return ExitUnavailable
+		case apiErr.StatusCode >= 500:
+			return ExitServer
+		default:
+			return ExitUsage
+		}
+	}
+
+	if errors.Is(err, os.ErrNotExist) {
+		return ExitNotFound
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ExitUnavailable
+	}
+	return ExitError
+}
+
+// Fail reports err on stderr in the requested format and exits with its
+// exit code
+func Fail(format Format, err error) {
+	code := ExitCode(err)
+	if format == FormatJSON {
+		json.NewEncoder(os.Stderr).Encode(map[string]interface{}{
+			"error":     err.Error(),
+			"exit_code": code,
+		})
+	} else {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	}
+	os.Exit(code)
+}
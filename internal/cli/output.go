@@ -0,0 +1,81 @@
+// Package cli holds the output formatting and exit code conventions shared
+// by monolog's subcommands, so every command composes into scripts the same
+// way.
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Format selects how a command prints its result
+type Format string
+
+// Supported output formats
+const (
+	FormatTable Format = "table"
+	FormatJSON  Format = "json"
+)
+
+// ParseFormat validates an --output value
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatTable, FormatJSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown output format %q (want json or table)", s)
+	}
+}
+
+// OutputFlag registers --output (and its -o shorthand) on fs
+func OutputFlag(fs *flag.FlagSet) *string {
+	output := fs.String("output", string(FormatTable), "Output format: json or table")
+	fs.StringVar(output, "o", string(FormatTable), "Shorthand for --output")
+	return output
+}
+
+// Table is a simple column-aligned table
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
+
+// NewTable creates a table with the given column headers
+func NewTable(header ...string) *Table {
+	return &Table{Header: header}
+}
+
+// AddRow appends a row, formatting each column with %v
+func (t *Table) AddRow(cols ...interface{}) {
+	row := make([]string, len(cols))
+	for i, c := range cols {
+		row[i] = fmt.Sprint(c)
+	}
+	t.Rows = append(t.Rows, row)
+}
+
+// Write prints the table with aligned columns
+func (t *Table) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if len(t.Header) > 0 {
+		fmt.Fprintln(tw, strings.Join(t.Header, "\t"))
+	}
+	for _, row := range t.Rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// Render prints v as indented JSON, or the table built by table otherwise
+func Render(w io.Writer, format Format, v interface{}, table func() *Table) error {
+	if format == FormatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	return table().Write(w)
+}
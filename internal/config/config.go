@@ -1,7 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -12,27 +15,101 @@ type Config struct {
 	Storage   StorageConfig   `yaml:"storage"`
 	Topics    TopicsConfig    `yaml:"topics"`
 	Limits    LimitsConfig    `yaml:"limits"`
+	Produce   ProduceConfig   `yaml:"produce"`
 	Scheduler SchedulerConfig `yaml:"scheduler"`
 	Retention RetentionConfig `yaml:"retention"`
 	Groups    GroupsConfig    `yaml:"groups"`
 	Security  SecurityConfig  `yaml:"security"`
+	Capture   CaptureConfig   `yaml:"capture"`
+	Alerts    AlertsConfig    `yaml:"alerts"`
+	Waits     WaitsConfig     `yaml:"waits"`
+	Metrics   MetricsConfig   `yaml:"metrics"`
+	Crash     CrashConfig     `yaml:"crash"`
+	Clock     ClockConfig     `yaml:"clock"`
+	Deterministic DeterministicConfig `yaml:"deterministic"`
+	Compression CompressionConfig `yaml:"compression"`
 	Logging   LoggingConfig   `yaml:"logging"`
+	Compat    CompatConfig    `yaml:"compat"`
+	Views     []ViewConfig    `yaml:"views"`
+	Follower  FollowerConfig  `yaml:"follower"`
+	Memory    MemoryConfig    `yaml:"memory"`
+
+	// Path is the file the config was loaded from, "" for defaults only
+	Path string `yaml:"-"`
 }
 
 type ServerConfig struct {
-	KafkaAddr string `yaml:"kafka_addr"`
-	HTTPAddr  string `yaml:"http_addr"`
+	KafkaAddr  string `yaml:"kafka_addr"`
+	HTTPAddr   string `yaml:"http_addr"`
+	SinglePort string `yaml:"single_port"` // serve Kafka and HTTP on this one address instead
+	BasePath   string `yaml:"base_path"`   // URL prefix for the HTTP API and UI, e.g. /monolog
+	DisableUI  bool   `yaml:"disable_ui"`  // serve /api only, with a minimal index page at /
+
+	// VirtualBrokers advertises this many Kafka brokers, each on its own
+	// port counting up from kafka_addr, all backed by the one engine
+	VirtualBrokers int `yaml:"virtual_brokers"`
+
+	// KafkaPurge enables the MonologPurge Kafka API, letting any Kafka
+	// client delete topic data; meant for test setups
+	KafkaPurge bool `yaml:"kafka_purge"`
+
+	// CrashFaults enables /api/admin/crash, which arms the broker to kill
+	// itself mid-append or mid-fetch; meant for crash-recovery tests
+	CrashFaults bool `yaml:"crash_faults"`
 }
 
 type StorageConfig struct {
-	Backend    string        `yaml:"backend"` // "sqlite" or "sqlite:memory"
+	Backend    string        `yaml:"backend"` // registered backend name, e.g. "sqlite" or "sqlite:memory"
 	DataDir    string        `yaml:"data_dir"`
 	SyncWrites bool          `yaml:"sync_writes"`
 	GCInterval time.Duration `yaml:"gc_interval"`
+	RefreshInterval time.Duration `yaml:"refresh_interval"` // how often to check for writes by other processes; 0 disables
+	CacheSize  int64         `yaml:"cache_size"` // bytes of SQLite page cache per database; 0 keeps SQLite's default
+	Watchdog   DiskWatchdogConfig `yaml:"watchdog"`
+	Scrub      StorageScrubConfig `yaml:"scrub"`
+}
+
+// StorageScrubConfig paces the background scrubber, which reads every
+// stored record back and checks it against its checksum
+type StorageScrubConfig struct {
+	Interval  time.Duration `yaml:"interval"`   // rest after a pass over every topic before the next; 0 runs passes only on demand
+	BatchSize int           `yaml:"batch_size"` // records read at a time
+	Pause     time.Duration `yaml:"pause"`      // rest between reads, so the scrubber yields to produces and fetches
+}
+
+// DiskWatchdogConfig sets free-space thresholds for the data directory
+type DiskWatchdogConfig struct {
+	Interval             time.Duration `yaml:"interval"`               // 0 disables the watchdog
+	RetentionFreePercent float64       `yaml:"retention_free_percent"` // force retention below this much free space
+	ReadOnlyFreePercent  float64       `yaml:"read_only_free_percent"` // reject produces below this much free space
 }
 
 type TopicsConfig struct {
-	AutoCreate bool `yaml:"auto_create"`
+	AutoCreate bool                   `yaml:"auto_create"`
+	Presets    map[string]TopicPreset `yaml:"presets"` // selected with the monolog.preset topic config
+	ColdStart  ColdStartConfig        `yaml:"cold_start"`
+}
+
+// ColdStartConfig makes Metadata answer for topics that do not exist yet
+// the way a real broker's auto-create flow does, for clients such as
+// librdkafka that cache UNKNOWN_TOPIC_OR_PARTITION
+type ColdStartConfig struct {
+	// LeaderNotAvailable reports missing and just auto-created topics as
+	// LEADER_NOT_AVAILABLE, which clients retry, instead of
+	// UNKNOWN_TOPIC_OR_PARTITION
+	LeaderNotAvailable bool `yaml:"leader_not_available"`
+
+	// Window is how long after it is first asked about a topic that is
+	// still missing is reported as LEADER_NOT_AVAILABLE (0 = forever)
+	Window time.Duration `yaml:"window"`
+}
+
+// TopicPreset bundles the settings a topic is created with. Topics always
+// have a single partition, so presets carry no partition count.
+type TopicPreset struct {
+	Retention       time.Duration `yaml:"retention"`         // how long messages are kept (0 = retention.max_age)
+	CleanupPolicy   string        `yaml:"cleanup_policy"`    // delete, or compact to exempt the topic from time-based retention
+	MaxMessageBytes int           `yaml:"max_message_bytes"` // largest record or batch accepted (0 = limits.max_message_size)
 }
 
 type LimitsConfig struct {
@@ -40,6 +117,26 @@ type LimitsConfig struct {
 	MaxMessageSize  int `yaml:"max_message_size"`
 	MaxFetchBytes   int `yaml:"max_fetch_bytes"`
 	MaxTopics       int `yaml:"max_topics"`
+
+	RequestTimeout time.Duration `yaml:"request_timeout"` // per-request deadline for storage work (0 = none)
+
+	ConnectionQuota int64 `yaml:"connection_quota"` // bytes per second one Kafka connection may send (0 = unlimited)
+
+	FetchConcurrency int `yaml:"fetch_concurrency"` // partitions one Fetch request reads at once (1 = one at a time)
+
+	// MinFetchInterval throttles a connection whose fetches come back
+	// empty more often than this, as clients with max.wait.ms=0 do in a
+	// tight loop (0 = no minimum)
+	MinFetchInterval time.Duration `yaml:"min_fetch_interval"`
+	MaxFetchWait     time.Duration `yaml:"max_fetch_wait"` // cap on the max.wait.ms a Fetch may ask for (0 = no cap)
+}
+
+// ProduceConfig tunes batching of HTTP-produced messages
+type ProduceConfig struct {
+	Linger          time.Duration `yaml:"linger"`            // extra wait to collect a batch (0 = group commit only)
+	MaxBatchRecords int           `yaml:"max_batch_records"` // records per shared append
+	ImportRate      int           `yaml:"import_rate"`       // records per second all HTTP imports together may append (0 = unlimited)
+	ImportChunkSize int           `yaml:"import_chunk_size"` // largest chunk of an HTTP import upload, in bytes
 }
 
 type SchedulerConfig struct {
@@ -53,25 +150,293 @@ type RetentionConfig struct {
 }
 
 type GroupsConfig struct {
-	SessionTimeout    time.Duration `yaml:"session_timeout"`
+	SessionTimeout    time.Duration `yaml:"session_timeout"`     // used when a member sets none
+	MinSessionTimeout time.Duration `yaml:"min_session_timeout"` // group.min.session.timeout.ms
+	MaxSessionTimeout time.Duration `yaml:"max_session_timeout"` // group.max.session.timeout.ms
 	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+
+	// HeartbeatFlushInterval is how often heartbeats, kept in memory, are
+	// written to storage; 0 writes each one as it arrives
+	HeartbeatFlushInterval time.Duration `yaml:"heartbeat_flush_interval"`
+
+	// CommitStallThreshold flags a group with members as stalled once it
+	// has gone this long without committing; 0 disables the check
+	CommitStallThreshold time.Duration `yaml:"commit_stall_threshold"`
+
+	// InitialRebalanceDelay holds the first rebalance of an empty group
+	// this long, and as long again each time another member joins, up to
+	// the rebalance timeout, so consumers starting together land in one
+	// generation; group.initial.rebalance.delay.ms. 0 disables.
+	InitialRebalanceDelay time.Duration `yaml:"initial_rebalance_delay"`
 }
 
 type SecurityConfig struct {
-	Enabled bool      `yaml:"enabled"`
-	Token   string    `yaml:"token"`
-	TLS     TLSConfig `yaml:"tls"`
+	Enabled       bool                `yaml:"enabled"`
+	Token         string              `yaml:"token"`
+	// AdminToken is the bearer token of admin endpoints that touch the
+	// filesystem, such as starting a capture; they are refused while it
+	// is empty, whether or not security is enabled
+	AdminToken    string              `yaml:"admin_token"`
+	// Users binds SASL PLAIN usernames to passwords of their own. With
+	// users set, a Kafka client authenticates as a username only with that
+	// user's password; without them any username goes with the token, so
+	// the principal is whatever the client claims.
+	Users         map[string]string   `yaml:"users"`
+	TLS           TLSConfig           `yaml:"tls"`
+	IPRules       ListenerIPRules     `yaml:"ip_rules"`
+	Impersonation ImpersonationConfig `yaml:"impersonation"`
+	ACLs          ACLConfig           `yaml:"acls"`
+
+	// ConnectionsMaxReauth is how long a SASL session lasts, like Kafka's
+	// connections.max.reauth.ms (KIP-368). Clients are told the lifetime
+	// and re-authenticate before it ends; a connection that sends anything
+	// else after its session expired is closed. 0 lets sessions last as
+	// long as their connections.
+	ConnectionsMaxReauth time.Duration `yaml:"connections_max_reauth"`
+
+	// MasterKeyFile holds the key encrypted tokens are decrypted with
+	MasterKeyFile string `yaml:"master_key_file"`
+}
+
+// ImpersonationConfig lets clients holding a super-user token act as
+// another principal by sending it as the SASL PLAIN authorization identity.
+// It needs Users: without them any token holder names its own principal.
+type ImpersonationConfig struct {
+	SuperuserTokens []string `yaml:"superuser_tokens"` // SASL passwords that may set an authorization identity
+	Allow           []string `yaml:"allow"`            // principals that may be assumed; "*" allows any
+}
+
+// ACLConfig turns on authorization of Kafka requests against the ACL
+// bindings managed with CreateAcls/DeleteAcls. It needs Users, like
+// impersonation, so principals are bound to passwords.
+type ACLConfig struct {
+	Enabled    bool     `yaml:"enabled"`
+	SuperUsers []string `yaml:"super_users"` // principals allowed everything, e.g. "User:admin"
+
+	// AllowIfNoACL allows access to a resource no ACL names, like Kafka's
+	// allow.everyone.if.no.acl.found; otherwise such access is denied
+	AllowIfNoACL bool `yaml:"allow_if_no_acl"`
+}
+
+// ListenerIPRules holds CIDR rules per listener
+type ListenerIPRules struct {
+	Kafka IPRules `yaml:"kafka" json:"kafka"`
+	HTTP  IPRules `yaml:"http" json:"http"`
+}
+
+// IPRules lists allowed and denied CIDRs (or bare IPs).
+// Deny wins; an empty allow list admits any address not denied.
+type IPRules struct {
+	Allow []string `yaml:"allow" json:"allow"`
+	Deny  []string `yaml:"deny" json:"deny"`
 }
 
 type TLSConfig struct {
 	Enabled  bool   `yaml:"enabled"`
 	CertFile string `yaml:"cert_file"`
 	KeyFile  string `yaml:"key_file"`
+
+	// Auto generates a CA and a server certificate under <data_dir>/tls
+	// and serves TLS with them, for local testing; the CA is served at
+	// /api/ca.pem
+	Auto  bool     `yaml:"auto"`
+	Hosts []string `yaml:"hosts"` // names the generated certificate covers besides localhost and this host
+}
+
+// CaptureConfig records produced traffic to a replay file from startup
+type CaptureConfig struct {
+	Path  string      `yaml:"path"` // capture file (empty = disabled)
+	Dir   string      `yaml:"dir"`  // where captures started over HTTP are written (empty = <data_dir>/captures)
+	Scrub ScrubConfig `yaml:"scrub"` // applied to everything replayed into this instance
+}
+
+// ScrubConfig rewrites replayed messages so sensitive production data
+// never reaches the store
+type ScrubConfig struct {
+	MaskFields   []string     `yaml:"mask_fields"`   // JSON object fields in values, at any depth, replaced with "***"
+	HashKeys     bool         `yaml:"hash_keys"`     // replace keys with their hex SHA-256, so equal keys stay equal
+	HashSalt     string       `yaml:"hash_salt"`     // mixed into key hashes against dictionary attacks
+	Redact       []RedactRule `yaml:"redact"`        // regular expressions replaced in values
+	StripHeaders []string     `yaml:"strip_headers"` // header names to drop; "*" drops them all
+}
+
+// RedactRule replaces every match of Pattern in a value with Replace
+type RedactRule struct {
+	Pattern string `yaml:"pattern"`
+	Replace string `yaml:"replace"` // default "[REDACTED]"; may use $1-style group references
+}
+
+// Enabled reports whether any scrubbing is configured
+func (c ScrubConfig) Enabled() bool {
+	return len(c.MaskFields) > 0 || c.HashKeys || len(c.Redact) > 0 || len(c.StripHeaders) > 0
+}
+
+// LoadScrub reads scrub rules from a YAML file holding a ScrubConfig
+func LoadScrub(path string) (ScrubConfig, error) {
+	var c ScrubConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// AlertsConfig defines alert rules evaluated on a schedule
+type AlertsConfig struct {
+	Interval time.Duration `yaml:"interval"`
+	Webhook  string        `yaml:"webhook"` // POSTed when an alert fires or resolves (empty = log only)
+	Rules    []AlertRule   `yaml:"rules"`
+}
+
+// WaitsConfig bounds the wait hooks registered over HTTP, which make the
+// broker call out to the webhooks they name
+type WaitsConfig struct {
+	Webhooks   []string      `yaml:"webhooks"`    // hosts, or host:port, wait hooks may call (empty = wait hooks refused)
+	MaxHooks   int           `yaml:"max_hooks"`   // hooks waiting at once
+	MaxTimeout time.Duration `yaml:"max_timeout"` // longest a hook may wait
+}
+
+// MetricsConfig controls the metrics history kept in the data directory
+// for `monolog report` and where else samples are sent
+type MetricsConfig struct {
+	Interval  time.Duration `yaml:"interval"`  // how often to record a sample of every metric; 0 disables
+	Retention time.Duration `yaml:"retention"` // samples older than this are deleted
+	StatsD    StatsDConfig  `yaml:"statsd"`
+}
+
+// StatsDConfig sends every metrics sample to a StatsD or DogStatsD agent
+// over UDP
+type StatsDConfig struct {
+	Addr      string   `yaml:"addr"`      // host:port of the agent (empty = disabled)
+	Prefix    string   `yaml:"prefix"`    // prepended to every metric name
+	DogStatsD bool     `yaml:"dogstatsd"` // send labels and Tags as DogStatsD tags instead of folding labels into names
+	Tags      []string `yaml:"tags"`      // added to every metric, e.g. "env:dev"; DogStatsD only
+}
+
+// AlertRule fires when Metric compares to Threshold with Op for at least For
+type AlertRule struct {
+	Name      string        `yaml:"name"`
+	Metric    string        `yaml:"metric"` // consumer_lag, disk_used_percent, error_rate, produce_latency_p99_ms, fetch_latency_p99_ms
+	Op        string        `yaml:"op"`     // >, >=, <, <=
+	Threshold float64       `yaml:"threshold"`
+	For       time.Duration `yaml:"for"` // how long the condition must hold before firing
+}
+
+// ViewConfig defines a materialized view: a table kept up to date with a
+// topic, with a column per JSON path extracted from message values
+type ViewConfig struct {
+	Name    string       `yaml:"name" json:"name"`
+	Topic   string       `yaml:"topic" json:"topic"`
+	Columns []ViewColumn `yaml:"columns" json:"columns"`
+}
+
+// ViewColumn is a column of a view and the JSON path in the message value
+// it is extracted from
+type ViewColumn struct {
+	Name string `yaml:"name" json:"name"`
+	Path string `yaml:"path" json:"path"` // SQLite JSON path, e.g. $.user.id
+}
+
+// FollowerConfig makes this instance a read replica of topics on another
+// monolog, the primary, pulling their messages over its HTTP API
+type FollowerConfig struct {
+	Primary  string        `yaml:"primary"`  // HTTP address of the primary, e.g. http://primary:8080; empty disables follower mode
+	Token    string        `yaml:"token"`    // API token for the primary
+	Topics   []string      `yaml:"topics"`   // topics to replicate (empty = every topic on the primary)
+	Interval time.Duration `yaml:"interval"` // how often to pull from the primary
+}
+
+// CrashConfig controls what is kept when a request or scheduler panics
+type CrashConfig struct {
+	DumpDir string `yaml:"dump_dir"` // write one file per recovered panic here (empty = log only)
+}
+
+// ClockConfig skews the broker's clock, for testing clients against a
+// broker whose time disagrees with theirs. It moves the timestamps the
+// broker assigns and the retention clock, not logs or timeouts.
+type ClockConfig struct {
+	Skew  time.Duration `yaml:"skew"`  // fixed offset, e.g. -5m for a broker running behind
+	Drift time.Duration `yaml:"drift"` // gained per hour of real time; negative to lose time
+}
+
+// DeterministicConfig makes a run reproducible, for golden tests and
+// replays that compare bytes: identifiers come from a seeded generator and
+// the broker's clock reads start, then advances by step on every stamp a
+// request takes, in place of real time. Skew and drift still apply on top.
+type DeterministicConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Seed    uint64        `yaml:"seed"`
+	Start   time.Time     `yaml:"start"` // the clock's first reading
+	Step    time.Duration `yaml:"step"`
+}
+
+// MemoryConfig bounds the memory the process uses, for sizing containers
+type MemoryConfig struct {
+	// Limit is a soft limit in bytes on the Go runtime's memory: past it
+	// the garbage collector runs harder rather than letting the heap grow.
+	// It is not a hard cap. 0 leaves the GOMEMLIMIT environment variable,
+	// if any, in charge.
+	Limit int64 `yaml:"limit"`
+}
+
+// CompressionConfig tunes the codecs monolog compresses and decodes batches
+// with, and turns off ones the deployment does not want
+type CompressionConfig struct {
+	Disabled []string               `yaml:"disabled"` // codec names, e.g. [snappy]
+	Codecs   map[string]CodecConfig `yaml:"codecs"`   // settings by codec name
+
+	// Accept lists the codecs producers may send; empty accepts every
+	// codec that is available. Batches in any other codec are rejected
+	// with UNSUPPORTED_COMPRESSION_TYPE.
+	Accept []string `yaml:"accept"`
+
+	// Validate decodes every produced batch before storing it, rejecting
+	// ones whose records do not decompress with the codec they declare
+	Validate bool `yaml:"validate"`
+
+	// Dictionaries trains zstd dictionaries for topics created with
+	// monolog.zstd.dictionary=true
+	Dictionaries DictionaryConfig `yaml:"dictionaries"`
+}
+
+// DictionaryConfig controls dictionary training
+type DictionaryConfig struct {
+	TrainInterval time.Duration `yaml:"train_interval"` // how often to retrain on recent messages
+	Samples       int           `yaml:"samples"`        // recent messages to train on
+	MinSamples    int           `yaml:"min_samples"`    // new messages needed before (re)training
+	MaxSize       int           `yaml:"max_size"`       // dictionary size cap in bytes
+}
+
+// CodecConfig are one codec's settings
+type CodecConfig struct {
+	Level      int    `yaml:"level"`      // gzip 1-9, lz4 1-9, zstd 1-22; 0 = codec default
+	Dictionary string `yaml:"dictionary"` // zstd only: dictionary file producers compress with, used to decode
 }
 
 type LoggingConfig struct {
 	Level  string `yaml:"level"`
 	Format string `yaml:"format"`
+
+	// Unsafe logs record contents in full instead of their size. Tokens
+	// stay masked. For debugging on a local machine only.
+	Unsafe bool `yaml:"unsafe"`
+}
+
+// CompatConfig holds workarounds for particular Kafka clients
+type CompatConfig struct {
+	// Clients maps a client software name, as sent in ApiVersions v3+, or
+	// the client ID of clients that send none, to its workarounds
+	Clients map[string]ClientCompat `yaml:"clients"`
+}
+
+// ClientCompat are the workarounds one client gets
+type ClientCompat struct {
+	MaxVersions          map[string]int16 `yaml:"max_versions"`            // API name -> highest version advertised to the client
+	SkipMemberIDRequired bool             `yaml:"skip_member_id_required"` // admit JoinGroup v4+ without a member ID instead of MEMBER_ID_REQUIRED
+	FillEmptyGroupData   bool             `yaml:"fill_empty_group_data"`   // send empty consumer subscriptions and assignments as encoded empty ones
 }
 
 // Default returns a Config with sensible defaults
@@ -86,15 +451,43 @@ func Default() *Config {
 			DataDir:    "./data",
 			SyncWrites: false,
 			GCInterval: 5 * time.Minute,
+			RefreshInterval: 30 * time.Second,
+			Watchdog: DiskWatchdogConfig{
+				Interval:             10 * time.Second,
+				RetentionFreePercent: 10,
+				ReadOnlyFreePercent:  5,
+			},
+			Scrub: StorageScrubConfig{
+				Interval:  6 * time.Hour,
+				BatchSize: 500,
+				Pause:     50 * time.Millisecond,
+			},
 		},
 		Topics: TopicsConfig{
 			AutoCreate: true,
+			Presets: map[string]TopicPreset{
+				"queue":     {Retention: time.Hour, CleanupPolicy: "delete"},
+				"changelog": {CleanupPolicy: "compact"},
+				"metrics":   {Retention: 15 * time.Minute, CleanupPolicy: "delete", MaxMessageBytes: 64 << 10},
+			},
+			ColdStart: ColdStartConfig{
+				Window: 30 * time.Second,
+			},
 		},
 		Limits: LimitsConfig{
 			MaxConnections: 100,
 			MaxMessageSize: 1 << 20, // 1MB
 			MaxFetchBytes:  10 << 20, // 10MB
 			MaxTopics:      100,
+			RequestTimeout: 30 * time.Second,
+			FetchConcurrency: 8,
+			MinFetchInterval: 10 * time.Millisecond,
+			MaxFetchWait:     30 * time.Second,
+		},
+		Produce: ProduceConfig{
+			Linger:          0,
+			MaxBatchRecords: 1000,
+			ImportChunkSize: 8 << 20, // 8MB
 		},
 		Scheduler: SchedulerConfig{
 			TickInterval: 100 * time.Millisecond,
@@ -105,16 +498,58 @@ func Default() *Config {
 			CheckInterval: 1 * time.Minute,
 		},
 		Groups: GroupsConfig{
-			SessionTimeout:    30 * time.Second,
-			HeartbeatInterval: 3 * time.Second,
+			SessionTimeout:         30 * time.Second,
+			MinSessionTimeout:      6 * time.Second,
+			MaxSessionTimeout:      30 * time.Minute,
+			HeartbeatInterval:      3 * time.Second,
+			HeartbeatFlushInterval: 5 * time.Second,
+			CommitStallThreshold:   2 * time.Minute,
 		},
 		Security: SecurityConfig{
 			Enabled: false,
 		},
+		Alerts: AlertsConfig{
+			Interval: 30 * time.Second,
+		},
+		Waits: WaitsConfig{
+			MaxHooks:   100,
+			MaxTimeout: time.Hour,
+		},
+		Metrics: MetricsConfig{
+			Interval:  10 * time.Second,
+			Retention: 7 * 24 * time.Hour,
+			StatsD: StatsDConfig{
+				Prefix: "monolog.",
+			},
+		},
+		Compression: CompressionConfig{
+			Dictionaries: DictionaryConfig{
+				TrainInterval: time.Hour,
+				Samples:       2000,
+				MinSamples:    100,
+				MaxSize:       16 << 10,
+			},
+		},
+		Follower: FollowerConfig{
+			Interval: time.Second,
+		},
+		Deterministic: DeterministicConfig{
+			Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			Step:  time.Millisecond,
+		},
 		Logging: LoggingConfig{
 			Level:  "info",
 			Format: "text",
 		},
+		Compat: CompatConfig{
+			Clients: map[string]ClientCompat{
+				"sarama": {
+					MaxVersions:          map[string]int16{"JoinGroup": 3},
+					SkipMemberIDRequired: true,
+					FillEmptyGroupData:   true,
+				},
+			},
+		},
 	}
 }
 
@@ -133,30 +568,109 @@ func Load(path string) (*Config, error) {
 		}
 	}
 
+	cfg.Path = path
+
 	// Override from environment
-	cfg.loadFromEnv()
+	if err := cfg.loadFromEnv(); err != nil {
+		return nil, err
+	}
 
+	if err := cfg.decryptSecrets(); err != nil {
+		return nil, err
+	}
 	return cfg, nil
 }
 
-func (c *Config) loadFromEnv() {
+func (c *Config) loadFromEnv() error {
 	if v := os.Getenv("MONOLOG_KAFKA_ADDR"); v != "" {
 		c.Server.KafkaAddr = v
 	}
 	if v := os.Getenv("MONOLOG_HTTP_ADDR"); v != "" {
 		c.Server.HTTPAddr = v
 	}
+	if v := os.Getenv("MONOLOG_SINGLE_PORT"); v != "" {
+		c.Server.SinglePort = v
+	}
+	if v := os.Getenv("MONOLOG_BASE_PATH"); v != "" {
+		c.Server.BasePath = v
+	}
+	if v := os.Getenv("MONOLOG_DISABLE_UI"); v == "true" || v == "1" {
+		c.Server.DisableUI = true
+	}
+	if v := os.Getenv("MONOLOG_VIRTUAL_BROKERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.Server.VirtualBrokers = n
+		}
+	}
+	if v := os.Getenv("MONOLOG_KAFKA_PURGE"); v == "true" || v == "1" {
+		c.Server.KafkaPurge = true
+	}
+	if v := os.Getenv("MONOLOG_CRASH_FAULTS"); v == "true" || v == "1" {
+		c.Server.CrashFaults = true
+	}
+	if v := os.Getenv("MONOLOG_LEADER_NOT_AVAILABLE"); v == "true" || v == "1" {
+		c.Topics.ColdStart.LeaderNotAvailable = true
+	}
 	if v := os.Getenv("MONOLOG_DATA_DIR"); v != "" {
 		c.Storage.DataDir = v
 	}
 	if v := os.Getenv("MONOLOG_STORAGE_BACKEND"); v != "" {
 		c.Storage.Backend = v
 	}
+	if v := os.Getenv("MONOLOG_FOLLOW"); v != "" {
+		c.Follower.Primary = v
+	}
+	if v := os.Getenv("MONOLOG_MEMORY_LIMIT"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			c.Memory.Limit = n
+		}
+	}
+	if v := os.Getenv("MONOLOG_DETERMINISTIC_SEED"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("MONOLOG_DETERMINISTIC_SEED: %q is not a seed: want an unsigned integer", v)
+		}
+		c.Deterministic.Enabled = true
+		c.Deterministic.Seed = n
+	}
+	if v := os.Getenv("MONOLOG_CRASH_DUMP_DIR"); v != "" {
+		c.Crash.DumpDir = v
+	}
+	if v := os.Getenv("MONOLOG_STATSD_ADDR"); v != "" {
+		c.Metrics.StatsD.Addr = v
+	}
 	if v := os.Getenv("MONOLOG_LOG_LEVEL"); v != "" {
 		c.Logging.Level = v
 	}
+	if v := os.Getenv("MONOLOG_LOG_UNSAFE"); v == "true" || v == "1" {
+		c.Logging.Unsafe = true
+	}
+	if v := os.Getenv("MONOLOG_KAFKA_ALLOW"); v != "" {
+		c.Security.IPRules.Kafka.Allow = splitList(v)
+	}
+	if v := os.Getenv("MONOLOG_HTTP_ALLOW"); v != "" {
+		c.Security.IPRules.HTTP.Allow = splitList(v)
+	}
+	if v := os.Getenv("MONOLOG_TLS_AUTO"); v == "true" || v == "1" {
+		c.Security.TLS.Auto = true
+	}
 	if v := os.Getenv("MONOLOG_AUTH_TOKEN"); v != "" {
 		c.Security.Token = v
 		c.Security.Enabled = true
 	}
+	if v := os.Getenv("MONOLOG_ADMIN_TOKEN"); v != "" {
+		c.Security.AdminToken = v
+	}
+	return nil
+}
+
+// splitList splits a comma-separated env value, dropping empty entries
+func splitList(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
 }
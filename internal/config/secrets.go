@@ -0,0 +1,251 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ============================================================================
+// Encrypted secrets
+//
+// Tokens in the config file may be stored encrypted as enc:v1:<base64>,
+// AES-256-GCM under a master key kept outside the file: MONOLOG_MASTER_KEY
+// holds the key itself, MONOLOG_MASTER_KEY_FILE or security.master_key_file
+// name a file holding it. The key is 32 bytes, written as hex or base64.
+// ============================================================================
+
+// secretPrefix marks an encrypted config value
+const secretPrefix = "enc:v1:"
+
+// MasterKeySize is the length of a master key in bytes
+const MasterKeySize = 32
+
+// ErrNoMasterKey is returned when the config holds encrypted values but no
+// master key was supplied
+var ErrNoMasterKey = errors.New("encrypted secrets need a master key (MONOLOG_MASTER_KEY, MONOLOG_MASTER_KEY_FILE or security.master_key_file)")
+
+// IsEncrypted reports whether a config value is an encrypted secret
+func IsEncrypted(v string) bool {
+	return strings.HasPrefix(v, secretPrefix)
+}
+
+// GenerateMasterKey returns a new random master key
+func GenerateMasterKey() ([]byte, error) {
+	key := make([]byte, MasterKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ParseMasterKey decodes a hex or base64 master key
+func ParseMasterKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if key, err := hex.DecodeString(s); err == nil && len(key) == MasterKeySize {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && len(key) == MasterKeySize {
+		return key, nil
+	}
+	return nil, fmt.Errorf("master key must be %d bytes, hex or base64 encoded", MasterKeySize)
+}
+
+// ReadMasterKey reads a master key from a file
+func ReadMasterKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParseMasterKey(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// MasterKeyFile returns the file the master key is read from, or "" when
+// it comes from MONOLOG_MASTER_KEY or is not configured
+func (c *Config) MasterKeyFile() string {
+	if os.Getenv("MONOLOG_MASTER_KEY") != "" {
+		return ""
+	}
+	if v := os.Getenv("MONOLOG_MASTER_KEY_FILE"); v != "" {
+		return v
+	}
+	return c.Security.MasterKeyFile
+}
+
+// MasterKey returns the configured master key, or nil if there is none
+func (c *Config) MasterKey() ([]byte, error) {
+	if v := os.Getenv("MONOLOG_MASTER_KEY"); v != "" {
+		key, err := ParseMasterKey(v)
+		if err != nil {
+			return nil, fmt.Errorf("MONOLOG_MASTER_KEY: %w", err)
+		}
+		return key, nil
+	}
+	if path := c.MasterKeyFile(); path != "" {
+		return ReadMasterKey(path)
+	}
+	return nil, nil
+}
+
+// EncryptSecret encrypts a config value under key
+func EncryptSecret(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return secretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSecret decrypts an encrypted config value. Values that are not
+// encrypted are returned as they are.
+func DecryptSecret(key []byte, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if key == nil {
+		return "", ErrNoMasterKey
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, secretPrefix))
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("malformed encrypted value: too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", errors.New("cannot decrypt value: wrong master key or corrupted value")
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// secrets returns the config fields that may hold encrypted values
+func (c *Config) secrets() map[string]*string {
+	fields := map[string]*string{
+		"security.token":       &c.Security.Token,
+		"security.admin_token": &c.Security.AdminToken,
+		"follower.token":       &c.Follower.Token,
+	}
+	for i := range c.Security.Impersonation.SuperuserTokens {
+		fields[fmt.Sprintf("security.impersonation.superuser_tokens[%d]", i)] = &c.Security.Impersonation.SuperuserTokens[i]
+	}
+	return fields
+}
+
+// decryptSecrets replaces encrypted secrets with their plaintext. The
+// master key is only needed, and only read, when there is one.
+func (c *Config) decryptSecrets() error {
+	var key []byte
+	decrypt := func(name, value string) (string, error) {
+		if !IsEncrypted(value) {
+			return value, nil
+		}
+		if key == nil {
+			var err error
+			if key, err = c.MasterKey(); err != nil {
+				return "", err
+			}
+			if key == nil {
+				return "", ErrNoMasterKey
+			}
+		}
+		plaintext, err := DecryptSecret(key, value)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", name, err)
+		}
+		return plaintext, nil
+	}
+
+	for name, field := range c.secrets() {
+		plaintext, err := decrypt(name, *field)
+		if err != nil {
+			return err
+		}
+		*field = plaintext
+	}
+	for user, password := range c.Security.Users {
+		plaintext, err := decrypt("security.users."+user, password)
+		if err != nil {
+			return err
+		}
+		c.Security.Users[user] = plaintext
+	}
+	return nil
+}
+
+// RotateSecrets re-encrypts every encrypted value in a YAML config document
+// from oldKey to newKey. Values are replaced where they stand, so the rest
+// of the file, formatting and comments included, is left untouched. It
+// returns the rewritten document and how many values it rotated.
+func RotateSecrets(data, oldKey, newKey []byte) ([]byte, int, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, 0, err
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	rotated := 0
+	var walk func(n *yaml.Node) error
+	walk = func(n *yaml.Node) error {
+		if n.Kind == yaml.ScalarNode && IsEncrypted(n.Value) {
+			plaintext, err := DecryptSecret(oldKey, n.Value)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", n.Line, err)
+			}
+			encrypted, err := EncryptSecret(newKey, plaintext)
+			if err != nil {
+				return err
+			}
+			// Encrypted values are plain base64, so they appear verbatim
+			// whether or not they are quoted
+			line := lines[n.Line-1]
+			col := n.Column - 1
+			i := strings.Index(line[col:], n.Value)
+			if i < 0 {
+				return fmt.Errorf("line %d: encrypted value must be on a single line", n.Line)
+			}
+			lines[n.Line-1] = line[:col+i] + encrypted + line[col+i+len(n.Value):]
+			rotated++
+		}
+		for _, child := range n.Content {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(&doc); err != nil {
+		return nil, 0, err
+	}
+	return []byte(strings.Join(lines, "")), rotated, nil
+}
@@ -0,0 +1,292 @@
+package engine
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// ============================================================================
+// ACLs
+//
+// ACL bindings allow or deny a principal an operation on topics, groups,
+// the cluster or transactional IDs, the way Kafka's AclAuthorizer does:
+// a matching DENY wins over any ALLOW, an ALLOW of READ, WRITE, DELETE or
+// ALTER also allows DESCRIBE, and ALLOW of ALTER_CONFIGS also allows
+// DESCRIBE_CONFIGS. Bindings are kept in the topic store when it can keep
+// them and cached in memory for authorization.
+// ============================================================================
+
+// ClusterResource is the name of the one cluster resource
+const ClusterResource = "kafka-cluster"
+
+// Wildcards in ACL bindings
+const (
+	ACLWildcardResource  = "*"      // literal resource name matching every name
+	ACLWildcardPrincipal = "User:*" // every principal
+	ACLWildcardHost      = "*"      // every host
+)
+
+// ACLFilter selects ACL bindings. Nil strings and the Any codes match
+// everything; pattern type MATCH selects every binding that applies to
+// ResourceName.
+type ACLFilter struct {
+	ResourceType int8
+	ResourceName *string
+	PatternType  int8
+	Principal    *string
+	Host         *string
+	Operation    int8
+	Permission   int8
+}
+
+// Authorizer checks requests against the ACL bindings
+type Authorizer struct {
+	engine *Engine
+	config config.ACLConfig
+
+	mu       sync.RWMutex
+	bindings []store.ACLBinding
+}
+
+// NewAuthorizer creates an Authorizer with no bindings
+func NewAuthorizer(engine *Engine, cfg config.ACLConfig) *Authorizer {
+	return &Authorizer{engine: engine, config: cfg}
+}
+
+// load reads the stored bindings. Run once from New.
+func (a *Authorizer) load(ctx context.Context) {
+	as, ok := a.engine.topicStore.(store.ACLStore)
+	if !ok {
+		return
+	}
+	bindings, err := as.ACLs(ctx)
+	if err != nil {
+		log.Printf("[engine] loading ACLs failed: %v", err)
+		return
+	}
+	a.bindings = bindings
+}
+
+// ACLsEnabled reports whether requests are authorized against ACLs
+func (e *Engine) ACLsEnabled() bool {
+	return e.authorizer.config.Enabled
+}
+
+// Authorize reports whether principal, connected from host, may perform
+// operation on the named resource. Everything is allowed while ACLs are
+// disabled.
+func (e *Engine) Authorize(principal, host string, operation, resourceType int8, name string) bool {
+	a := e.authorizer
+	if !a.config.Enabled || slices.Contains(a.config.SuperUsers, principal) {
+		return true
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	found, allowed := false, false
+	for _, b := range a.bindings {
+		if b.ResourceType != resourceType || !appliesTo(b, name) {
+			continue
+		}
+		found = true
+		if !matchesPrincipal(b, principal, host) {
+			continue
+		}
+		switch b.Permission {
+		case kafkaproto.AclPermissionDeny:
+			if b.Operation == operation || b.Operation == kafkaproto.AclOperationAll {
+				return false
+			}
+		case kafkaproto.AclPermissionAllow:
+			if implies(b.Operation, operation) {
+				allowed = true
+			}
+		}
+	}
+	if !found {
+		return a.config.AllowIfNoACL
+	}
+	return allowed
+}
+
+// ValidateACL checks that a binding to create names a concrete resource
+// type, pattern type, operation and permission
+func ValidateACL(b store.ACLBinding) error {
+	switch {
+	case b.ResourceType < kafkaproto.AclResourceTopic || b.ResourceType > kafkaproto.AclResourceTransactionalID:
+		return fmt.Errorf("%w: resource type %d", ErrInvalidACL, b.ResourceType)
+	case b.PatternType != kafkaproto.AclPatternLiteral && b.PatternType != kafkaproto.AclPatternPrefixed:
+		return fmt.Errorf("%w: pattern type %d", ErrInvalidACL, b.PatternType)
+	case b.Operation < kafkaproto.AclOperationAll || b.Operation > kafkaproto.AclOperationIdempotentWrite:
+		return fmt.Errorf("%w: operation %d", ErrInvalidACL, b.Operation)
+	case b.Permission != kafkaproto.AclPermissionAllow && b.Permission != kafkaproto.AclPermissionDeny:
+		return fmt.Errorf("%w: permission type %d", ErrInvalidACL, b.Permission)
+	case b.ResourceName == "":
+		return fmt.Errorf("%w: empty resource name", ErrInvalidACL)
+	case b.ResourceType == kafkaproto.AclResourceCluster && b.ResourceName != ClusterResource:
+		return fmt.Errorf("%w: the cluster resource is named %s", ErrInvalidACL, ClusterResource)
+	case !strings.Contains(b.Principal, ":"):
+		return fmt.Errorf("%w: principal %q is not of the form Type:name", ErrInvalidACL, b.Principal)
+	case b.Host == "":
+		return fmt.Errorf("%w: empty host", ErrInvalidACL)
+	}
+	return nil
+}
+
+// CreateACLs validates and adds bindings; ones that already exist are
+// left alone
+func (e *Engine) CreateACLs(ctx context.Context, bindings []store.ACLBinding) error {
+	for _, b := range bindings {
+		if err := ValidateACL(b); err != nil {
+			return err
+		}
+	}
+	a := e.authorizer
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if as, ok := e.topicStore.(store.ACLStore); ok {
+		if err := as.AddACLs(ctx, bindings); err != nil {
+			return err
+		}
+	}
+	for _, b := range bindings {
+		if !slices.Contains(a.bindings, b) {
+			a.bindings = append(a.bindings, b)
+		}
+	}
+	return nil
+}
+
+// DescribeACLs returns the bindings filter selects, sorted by resource
+func (e *Engine) DescribeACLs(filter ACLFilter) []store.ACLBinding {
+	a := e.authorizer
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	matched := []store.ACLBinding{}
+	for _, b := range a.bindings {
+		if filter.matches(b) {
+			matched = append(matched, b)
+		}
+	}
+	slices.SortFunc(matched, compareACLs)
+	return matched
+}
+
+// DeleteACLs deletes the bindings each filter selects and returns them,
+// per filter
+func (e *Engine) DeleteACLs(ctx context.Context, filters []ACLFilter) ([][]store.ACLBinding, error) {
+	a := e.authorizer
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	deleted := make([][]store.ACLBinding, len(filters))
+	var all []store.ACLBinding
+	for i, f := range filters {
+		deleted[i] = []store.ACLBinding{}
+		for _, b := range a.bindings {
+			if f.matches(b) {
+				deleted[i] = append(deleted[i], b)
+				if !slices.Contains(all, b) {
+					all = append(all, b)
+				}
+			}
+		}
+	}
+	if len(all) == 0 {
+		return deleted, nil
+	}
+
+	if as, ok := e.topicStore.(store.ACLStore); ok {
+		if err := as.DeleteACLs(ctx, all); err != nil {
+			return nil, err
+		}
+	}
+	a.bindings = slices.DeleteFunc(a.bindings, func(b store.ACLBinding) bool {
+		return slices.Contains(all, b)
+	})
+	return deleted, nil
+}
+
+// compareACLs orders bindings by resource, then principal, host,
+// operation and permission
+func compareACLs(a, b store.ACLBinding) int {
+	return cmp.Or(
+		cmp.Compare(a.ResourceType, b.ResourceType),
+		cmp.Compare(a.ResourceName, b.ResourceName),
+		cmp.Compare(a.PatternType, b.PatternType),
+		cmp.Compare(a.Principal, b.Principal),
+		cmp.Compare(a.Host, b.Host),
+		cmp.Compare(a.Operation, b.Operation),
+		cmp.Compare(a.Permission, b.Permission),
+	)
+}
+
+// matches reports whether the filter selects b
+func (f ACLFilter) matches(b store.ACLBinding) bool {
+	if f.ResourceType != kafkaproto.AclResourceAny && f.ResourceType != b.ResourceType {
+		return false
+	}
+	switch f.PatternType {
+	case kafkaproto.AclPatternAny:
+		if f.ResourceName != nil && *f.ResourceName != b.ResourceName {
+			return false
+		}
+	case kafkaproto.AclPatternMatch:
+		if f.ResourceName != nil && !appliesTo(b, *f.ResourceName) {
+			return false
+		}
+	default:
+		if f.PatternType != b.PatternType || (f.ResourceName != nil && *f.ResourceName != b.ResourceName) {
+			return false
+		}
+	}
+	return (f.Principal == nil || *f.Principal == b.Principal) &&
+		(f.Host == nil || *f.Host == b.Host) &&
+		(f.Operation == kafkaproto.AclOperationAny || f.Operation == b.Operation) &&
+		(f.Permission == kafkaproto.AclPermissionAny || f.Permission == b.Permission)
+}
+
+// appliesTo reports whether b's resource pattern covers the named resource
+func appliesTo(b store.ACLBinding, name string) bool {
+	switch b.PatternType {
+	case kafkaproto.AclPatternLiteral:
+		return b.ResourceName == name || b.ResourceName == ACLWildcardResource
+	case kafkaproto.AclPatternPrefixed:
+		return strings.HasPrefix(name, b.ResourceName)
+	}
+	return false
+}
+
+// matchesPrincipal reports whether b applies to principal connecting
+// from host
+func matchesPrincipal(b store.ACLBinding, principal, host string) bool {
+	return (b.Principal == principal || b.Principal == ACLWildcardPrincipal) &&
+		(b.Host == host || b.Host == ACLWildcardHost)
+}
+
+// implies reports whether allowing granted also allows operation
+func implies(granted, operation int8) bool {
+	if granted == operation || granted == kafkaproto.AclOperationAll {
+		return true
+	}
+	switch operation {
+	case kafkaproto.AclOperationDescribe:
+		return granted == kafkaproto.AclOperationRead || granted == kafkaproto.AclOperationWrite ||
+			granted == kafkaproto.AclOperationDelete || granted == kafkaproto.AclOperationAlter
+	case kafkaproto.AclOperationDescribeConfigs:
+		return granted == kafkaproto.AclOperationAlterConfigs
+	}
+	return false
+}
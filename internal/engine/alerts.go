@@ -0,0 +1,351 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+)
+
+// Metrics alert rules can watch
+const (
+	MetricConsumerLag     = "consumer_lag"           // highest lag of any group on any topic
+	MetricDiskUsedPercent = "disk_used_percent"      // filesystem holding the data dir
+	MetricErrorRate       = "error_rate"             // failed requests per second since the last evaluation
+	MetricProduceLatency  = "produce_latency_p99_ms" // over the last 1024 produces
+	MetricFetchLatency    = "fetch_latency_p99_ms"   // over the last 1024 fetches
+	MetricStalledGroups   = "stalled_committers"     // groups with members and no commit past groups.commit_stall_threshold
+	MetricCorruptRecords  = "corrupt_records"        // stored rows the scrubber found not matching their checksums
+)
+
+var alertMetrics = map[string]bool{
+	MetricConsumerLag:     true,
+	MetricDiskUsedPercent: true,
+	MetricErrorRate:       true,
+	MetricProduceLatency:  true,
+	MetricFetchLatency:    true,
+	MetricStalledGroups:   true,
+	MetricCorruptRecords:  true,
+}
+
+// Alert states
+const (
+	AlertPending = "pending" // condition holds, waiting out the rule's For
+	AlertFiring  = "firing"
+)
+
+// Alert is a rule whose condition currently holds
+type Alert struct {
+	Rule      string     `json:"rule"`
+	Metric    string     `json:"metric"`
+	Op        string     `json:"op"`
+	Threshold float64    `json:"threshold"`
+	Value     float64    `json:"value"`
+	State     string     `json:"state"`
+	Since     time.Time  `json:"since"` // when the condition started holding
+	FiredAt   *time.Time `json:"fired_at,omitempty"`
+}
+
+// AlertManager evaluates alert rules on a timer, logging and POSTing to
+// the configured webhook when an alert fires or resolves
+type AlertManager struct {
+	engine   *Engine
+	config   config.AlertsConfig
+	rules    []config.AlertRule
+	client   *http.Client
+	ticker   *time.Ticker
+	stopChan chan struct{}
+	monitor  loopMonitor
+
+	mu       sync.Mutex
+	active   map[string]*Alert
+	lastEval time.Time
+	lastErrs int64
+}
+
+// NewAlertManager creates a new AlertManager. Rules with an unknown
+// metric or operator, or the name of an earlier rule, are logged and
+// skipped. An unnamed rule is named by its position, rule-1 for the first.
+func NewAlertManager(engine *Engine, cfg config.AlertsConfig) *AlertManager {
+	m := &AlertManager{
+		engine:   engine,
+		config:   cfg,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		stopChan: make(chan struct{}),
+		active:   make(map[string]*Alert),
+	}
+	names := make(map[string]bool)
+	for i, r := range cfg.Rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		if names[r.Name] {
+			log.Printf("[alerts] ignoring rule %q: an earlier rule has that name", r.Name)
+			continue
+		}
+		names[r.Name] = true
+		if !alertMetrics[r.Metric] {
+			log.Printf("[alerts] ignoring rule %q: unknown metric %q", r.Name, r.Metric)
+			continue
+		}
+		if _, ok := compare(r.Op, 0, 0); !ok {
+			log.Printf("[alerts] ignoring rule %q: unknown operator %q", r.Name, r.Op)
+			continue
+		}
+		m.rules = append(m.rules, r)
+	}
+	return m
+}
+
+// Start starts the scheduler
+func (m *AlertManager) Start() {
+	if len(m.rules) == 0 || m.config.Interval <= 0 {
+		return
+	}
+	m.lastEval = time.Now()
+	m.lastErrs = m.engine.ErrorCount()
+	m.ticker = time.NewTicker(m.config.Interval)
+	m.monitor.start(m.config.Interval)
+	go m.loop()
+}
+
+// Stop stops the scheduler
+func (m *AlertManager) Stop() {
+	if m.ticker != nil {
+		m.ticker.Stop()
+	}
+	m.monitor.stop()
+	select {
+	case <-m.stopChan:
+	default:
+		close(m.stopChan)
+	}
+}
+
+func (m *AlertManager) loop() {
+	for {
+		select {
+		case <-m.ticker.C:
+			m.engine.safely("alert manager", m.Evaluate)
+			m.monitor.tick()
+		case <-m.stopChan:
+			return
+		}
+	}
+}
+
+// Rules returns the rules being evaluated
+func (m *AlertManager) Rules() []config.AlertRule {
+	return m.rules
+}
+
+// Active returns pending and firing alerts, sorted by rule name
+func (m *AlertManager) Active() []Alert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	alerts := make([]Alert, 0, len(m.active))
+	for _, a := range m.active {
+		alerts = append(alerts, *a)
+	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].Rule < alerts[j].Rule })
+	return alerts
+}
+
+// Evaluate checks every rule against current metrics once
+func (m *AlertManager) Evaluate() {
+	metrics := m.collect()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, r := range m.rules {
+		value, known := metrics[r.Metric]
+		holds, _ := compare(r.Op, value, r.Threshold)
+		a := m.active[r.Name]
+
+		if !known || !holds {
+			if a != nil {
+				delete(m.active, r.Name)
+				if a.State == AlertFiring {
+					a.Value = value
+					log.Printf("[alerts] resolved %s: %s = %.2f", r.Name, r.Metric, value)
+					m.notify("resolved", *a)
+				}
+			}
+			continue
+		}
+
+		if a == nil {
+			a = &Alert{Rule: r.Name, Metric: r.Metric, Op: r.Op, Threshold: r.Threshold, State: AlertPending, Since: now}
+			m.active[r.Name] = a
+		}
+		a.Value = value
+		if a.State == AlertPending && now.Sub(a.Since) >= r.For {
+			a.State = AlertFiring
+			fired := now
+			a.FiredAt = &fired
+			log.Printf("[alerts] FIRING %s: %s = %.2f (%s %.2f)", r.Name, r.Metric, value, r.Op, r.Threshold)
+			m.notify("firing", *a)
+		}
+	}
+}
+
+// collect gathers the current value of every metric that can be measured
+func (m *AlertManager) collect() map[string]float64 {
+	e := m.engine
+	metrics := make(map[string]float64)
+
+	metrics[MetricConsumerLag] = float64(e.MaxConsumerLag())
+	metrics[MetricStalledGroups] = float64(len(e.StalledCommitters()))
+	corrupt := 0
+	for _, n := range e.scrubber.CorruptCounts() {
+		corrupt += n
+	}
+	metrics[MetricCorruptRecords] = float64(corrupt)
+
+	if used, err := diskUsedPercent(e.config.Storage.DataDir); err == nil {
+		metrics[MetricDiskUsedPercent] = used
+	}
+
+	now := time.Now()
+	errs := e.ErrorCount()
+	m.mu.Lock()
+	if elapsed := now.Sub(m.lastEval).Seconds(); elapsed > 0 {
+		metrics[MetricErrorRate] = float64(errs-m.lastErrs) / elapsed
+	}
+	m.lastEval, m.lastErrs = now, errs
+	m.mu.Unlock()
+
+	if p99, ok := e.produceLatency.Percentile(99); ok {
+		metrics[MetricProduceLatency] = float64(p99) / float64(time.Millisecond)
+	}
+	if p99, ok := e.fetchLatency.Percentile(99); ok {
+		metrics[MetricFetchLatency] = float64(p99) / float64(time.Millisecond)
+	}
+	return metrics
+}
+
+// notify POSTs an alert transition to the webhook without blocking evaluation
+func (m *AlertManager) notify(status string, a Alert) {
+	if m.config.Webhook == "" {
+		return
+	}
+	body, _ := json.Marshal(map[string]interface{}{"status": status, "alert": a})
+	go func() {
+		resp, err := m.client.Post(m.config.Webhook, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.Printf("[alerts] webhook failed for %s: %v", a.Rule, err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("[alerts] webhook for %s returned %s", a.Rule, resp.Status)
+		}
+	}()
+}
+
+// compare applies op; ok is false for an unknown operator
+func compare(op string, value, threshold float64) (holds, ok bool) {
+	switch op {
+	case ">":
+		return value > threshold, true
+	case ">=":
+		return value >= threshold, true
+	case "<":
+		return value < threshold, true
+	case "<=":
+		return value <= threshold, true
+	}
+	return false, false
+}
+
+// diskUsedPercent returns how full the filesystem holding path is
+func diskUsedPercent(path string) (float64, error) {
+	free, total, err := diskSpace(path)
+	if err != nil || total == 0 {
+		return 0, err
+	}
+	return float64(total-free) / float64(total) * 100, nil
+}
+
+// --- Engine metrics ---
+
+// Kinds of failed request counted for the error breakdown
+const (
+	ErrorKindProduce     = "produce"     // storage rejected an append
+	ErrorKindFetch       = "fetch"       // storage failed a read
+	ErrorKindRequest     = "request"     // a Kafka request handler failed
+	ErrorKindUnsupported = "unsupported" // a Kafka request for an API key monolog does not serve
+)
+
+// CountError records a failed client request of the given kind for the
+// error_rate metric and the error breakdown
+func (e *Engine) CountError(kind string) {
+	atomic.AddInt64(&e.errorCount, 1)
+	n, _ := e.errorKinds.LoadOrStore(kind, new(int64))
+	atomic.AddInt64(n.(*int64), 1)
+}
+
+// ErrorCount returns the number of failed requests since startup
+func (e *Engine) ErrorCount() int64 {
+	return atomic.LoadInt64(&e.errorCount)
+}
+
+// ErrorCounts returns the number of failed requests since startup by kind
+func (e *Engine) ErrorCounts() map[string]int64 {
+	counts := make(map[string]int64)
+	e.errorKinds.Range(func(k, v any) bool {
+		counts[k.(string)] = atomic.LoadInt64(v.(*int64))
+		return true
+	})
+	return counts
+}
+
+// MaxConsumerLag returns the highest lag any group has on any topic it
+// has committed offsets for
+func (e *Engine) MaxConsumerLag() int64 {
+	var max int64
+	for _, lag := range e.GroupLags() {
+		if lag > max {
+			max = lag
+		}
+	}
+	return max
+}
+
+// GroupLags returns, for each group with committed offsets, its highest
+// lag on any topic it has committed offsets for
+func (e *Engine) GroupLags() map[string]int64 {
+	lags := make(map[string]int64)
+	for _, id := range e.ListGroups() {
+		group, ok := e.GetGroup(id)
+		if !ok {
+			continue
+		}
+		for tp, committed := range group.Offsets {
+			latest, err := e.LatestOffset(tp.Topic)
+			if err != nil || committed < 0 {
+				continue
+			}
+			lag := latest + 1 - committed
+			if cur, seen := lags[id]; !seen || lag > cur {
+				lags[id] = lag
+			}
+		}
+	}
+	return lags
+}
+
+// Alerts returns the alert manager
+func (e *Engine) Alerts() *AlertManager {
+	return e.alerts
+}
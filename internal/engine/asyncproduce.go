@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/ids"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// ============================================================================
+// Async Produce
+//
+// An async produce hands its records to the produce batcher and returns a
+// token at once, so HTTP clients are not held up by a slow storage backend.
+// The outcome is kept under the token for asyncProduceRetention after the
+// append finishes, for the client to poll. Outcomes live in memory only: a
+// restart forgets them, though appends accepted before a clean stop are
+// still written.
+// ============================================================================
+
+// Async produce states
+const (
+	AsyncPending = "pending"
+	AsyncDone    = "done"
+	AsyncFailed  = "failed"
+)
+
+// asyncProduceRetention is how long the outcome of an async produce can be
+// polled after its append finishes
+const asyncProduceRetention = 10 * time.Minute
+
+// MaxPendingAsyncProduces caps the async produces waiting on their appends;
+// past it new ones are turned away with ErrProduceBacklog
+const MaxPendingAsyncProduces = 10000
+
+// ProduceStatus is the outcome of an async produce as it stands
+type ProduceStatus struct {
+	Token     string     `json:"token"`
+	Topic     string     `json:"topic"`
+	Records   int        `json:"records"`
+	Status    string     `json:"status"`           // pending, done or failed
+	Offset    *int64     `json:"offset,omitempty"` // offset of the first record, once done
+	Error     string     `json:"error,omitempty"`
+	Accepted  time.Time  `json:"accepted"`
+	Completed *time.Time `json:"completed,omitempty"`
+}
+
+// AsyncProduces tracks async produces until their outcomes expire
+type AsyncProduces struct {
+	engine  *Engine
+	mu      sync.Mutex
+	byToken map[string]*ProduceStatus
+	done    []*ProduceStatus // finished, oldest first
+	pending int
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+// NewAsyncProduces creates an empty AsyncProduces
+func NewAsyncProduces(engine *Engine) *AsyncProduces {
+	return &AsyncProduces{engine: engine, byToken: make(map[string]*ProduceStatus)}
+}
+
+// Stop turns away new async produces and waits for the appends already
+// accepted, so a clean shutdown does not drop them
+func (a *AsyncProduces) Stop() {
+	a.mu.Lock()
+	a.stopped = true
+	a.mu.Unlock()
+	a.wg.Wait()
+}
+
+// Submit queues records for topic and returns the pending status of the
+// produce without waiting for the append
+func (a *AsyncProduces) Submit(topic string, records []store.Record) (ProduceStatus, error) {
+	if err := a.engine.checkWritable(topic); err != nil {
+		return ProduceStatus{}, err
+	}
+	if err := a.engine.checkRecordsSize(topic, records); err != nil {
+		return ProduceStatus{}, err
+	}
+
+	st := &ProduceStatus{
+		Token:    ids.Hex(16),
+		Topic:    topic,
+		Records:  len(records),
+		Status:   AsyncPending,
+		Accepted: time.Now(),
+	}
+
+	a.mu.Lock()
+	if a.stopped {
+		a.mu.Unlock()
+		return ProduceStatus{}, ErrShuttingDown
+	}
+	if a.pending >= MaxPendingAsyncProduces {
+		a.mu.Unlock()
+		return ProduceStatus{}, fmt.Errorf("%w: %d async produces pending", ErrProduceBacklog, a.pending)
+	}
+	a.expire(st.Accepted)
+	a.byToken[st.Token] = st
+	a.pending++
+	a.wg.Add(1)
+	accepted := *st
+	a.mu.Unlock()
+
+	go a.run(st, records)
+	return accepted, nil
+}
+
+func (a *AsyncProduces) run(st *ProduceStatus, records []store.Record) {
+	defer a.wg.Done()
+	// Not tied to the engine's context: an accepted produce is written
+	// even if the engine starts stopping meanwhile
+	offset, err := a.engine.ProduceBatched(context.Background(), st.Topic, records)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	st.Completed = &now
+	if err != nil {
+		log.Printf("[engine] async produce %s to %s failed: %v", st.Token, st.Topic, err)
+		st.Status, st.Error = AsyncFailed, err.Error()
+	} else {
+		st.Status, st.Offset = AsyncDone, &offset
+	}
+	a.pending--
+	a.done = append(a.done, st)
+}
+
+// expire forgets outcomes older than asyncProduceRetention. Called with
+// a.mu held.
+func (a *AsyncProduces) expire(now time.Time) {
+	n := 0
+	for n < len(a.done) && now.Sub(*a.done[n].Completed) > asyncProduceRetention {
+		delete(a.byToken, a.done[n].Token)
+		n++
+	}
+	a.done = a.done[n:]
+}
+
+// Get returns the status of the async produce with token
+func (a *AsyncProduces) Get(token string) (ProduceStatus, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.expire(time.Now())
+	st, ok := a.byToken[token]
+	if !ok {
+		return ProduceStatus{}, fmt.Errorf("%w: %s", ErrUnknownProduceToken, token)
+	}
+	return *st, nil
+}
+
+// AsyncProduces returns the engine's async produces
+func (e *Engine) AsyncProduces() *AsyncProduces {
+	return e.asyncProduces
+}
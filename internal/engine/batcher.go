@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// ProduceBatcher coalesces small produce calls into shared store appends.
+//
+// Requests for a topic queue up while an append for that topic is in
+// flight and are written together by the next append (group commit), so
+// an idle broker adds no latency while a busy one amortizes the SQLite
+// transaction across many HTTP requests. An optional linger delays the
+// first append of a burst to collect more records.
+type ProduceBatcher struct {
+	topicStore store.TopicStoreInterface
+	linger     time.Duration
+	maxRecords int
+
+	mu     sync.Mutex
+	topics map[string]*topicBatch
+}
+
+type topicBatch struct {
+	pending  []*batchedProduce
+	records  int
+	flushing bool
+}
+
+type batchedProduce struct {
+	records []store.Record
+	done    chan batchResult
+}
+
+type batchResult struct {
+	offset int64
+	err    error
+}
+
+// NewProduceBatcher creates a new ProduceBatcher
+func NewProduceBatcher(topicStore store.TopicStoreInterface, linger time.Duration, maxRecords int) *ProduceBatcher {
+	if maxRecords <= 0 {
+		maxRecords = 1000
+	}
+	return &ProduceBatcher{
+		topicStore: topicStore,
+		linger:     linger,
+		maxRecords: maxRecords,
+		topics:     make(map[string]*topicBatch),
+	}
+}
+
+// Submit appends records as part of a shared batch and returns the offset
+// of the first record, exactly as an individual Append would
+func (b *ProduceBatcher) Submit(ctx context.Context, topic string, records []store.Record) (int64, error) {
+	req := &batchedProduce{records: records, done: make(chan batchResult, 1)}
+
+	b.mu.Lock()
+	tb, ok := b.topics[topic]
+	if !ok {
+		tb = &topicBatch{}
+		b.topics[topic] = tb
+	}
+	tb.pending = append(tb.pending, req)
+	tb.records += len(records)
+	if !tb.flushing {
+		tb.flushing = true
+		go b.flushLoop(topic, tb)
+	}
+	b.mu.Unlock()
+
+	// The shared append goes ahead regardless; a caller that gives up
+	// only stops waiting for it
+	select {
+	case res := <-req.done:
+		return res.offset, res.err
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func (b *ProduceBatcher) flushLoop(topic string, tb *topicBatch) {
+	if b.linger > 0 {
+		b.mu.Lock()
+		full := tb.records >= b.maxRecords
+		b.mu.Unlock()
+		if !full {
+			time.Sleep(b.linger)
+		}
+	}
+
+	for {
+		b.mu.Lock()
+		if len(tb.pending) == 0 {
+			tb.flushing = false
+			delete(b.topics, topic)
+			b.mu.Unlock()
+			return
+		}
+
+		// Take whole requests up to maxRecords (always at least one)
+		n, count := 0, 0
+		for n < len(tb.pending) && (n == 0 || count+len(tb.pending[n].records) <= b.maxRecords) {
+			count += len(tb.pending[n].records)
+			n++
+		}
+		batch := tb.pending[:n:n]
+		tb.pending = tb.pending[n:]
+		tb.records -= count
+		b.mu.Unlock()
+
+		b.flush(topic, batch, count)
+	}
+}
+
+func (b *ProduceBatcher) flush(topic string, batch []*batchedProduce, count int) {
+	records := make([]store.Record, 0, count)
+	for _, req := range batch {
+		records = append(records, req.records...)
+	}
+
+	// Shared by every producer in the batch, so no one request's
+	// deadline applies
+	base, err := b.topicStore.Append(context.Background(), topic, records)
+
+	// Hand each request the offset of its own first record
+	next := base
+	for _, req := range batch {
+		req.done <- batchResult{offset: next, err: err}
+		next += int64(len(req.records))
+	}
+}
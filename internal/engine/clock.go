@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock is the time the broker stamps messages with and runs retention
+// on. It is the real time unless a skew is set, which offsets it by a
+// fixed amount plus a drift that grows from the moment the skew was set.
+// A deterministic clock replaces real time with a logical one.
+type Clock struct {
+	mu    sync.RWMutex
+	real  func() time.Time // time.Now unless deterministic
+	peek  func() time.Time // real, without advancing a deterministic clock
+	skew  time.Duration
+	drift time.Duration // gained per hour of real time
+	since time.Time     // when the drift started accumulating
+}
+
+// ClockStatus describes the broker clock's skew
+type ClockStatus struct {
+	Skew   string    `json:"skew"`   // fixed offset
+	Drift  string    `json:"drift"`  // gained per hour
+	Offset string    `json:"offset"` // total offset from real time right now
+	Now    time.Time `json:"now"`    // the skewed time
+}
+
+// NewClock creates a clock with the given skew
+func NewClock(skew, drift time.Duration) *Clock {
+	c := &Clock{real: time.Now, peek: time.Now}
+	c.Set(skew, drift)
+	return c
+}
+
+// Deterministic replaces real time under the clock with a logical time
+// that reads start first and advances by step on every reading by Now, so
+// a run that makes the same requests stamps the same times. Peek reads the
+// last stamp without advancing it.
+func (c *Clock) Deterministic(start time.Time, step time.Duration) {
+	var readings atomic.Int64
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.real = func() time.Time {
+		return start.Add(time.Duration(readings.Add(1)-1) * step)
+	}
+	c.peek = func() time.Time {
+		return start.Add(time.Duration(max(readings.Load()-1, 0)) * step)
+	}
+	c.since = start
+}
+
+func (c *Clock) realNow() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.real()
+}
+
+func (c *Clock) peekNow() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.peek()
+}
+
+// Set replaces the skew. Drift accumulates from now.
+func (c *Clock) Set(skew, drift time.Duration) {
+	now := c.peekNow()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.skew, c.drift, c.since = skew, drift, now
+}
+
+// Now returns the broker's current time, for stamping what a request
+// writes. It advances a deterministic clock.
+func (c *Clock) Now() time.Time {
+	now := c.realNow()
+	return now.Add(c.offset(now))
+}
+
+// Peek returns the broker's current time without advancing a
+// deterministic clock, for background work whose timing would otherwise
+// shift the stamps requests get
+func (c *Clock) Peek() time.Time {
+	now := c.peekNow()
+	return now.Add(c.offset(now))
+}
+
+func (c *Clock) offset(now time.Time) time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	offset := c.skew
+	if c.drift != 0 {
+		offset += time.Duration(float64(c.drift) * float64(now.Sub(c.since)) / float64(time.Hour))
+	}
+	return offset
+}
+
+// Status returns the current skew
+func (c *Clock) Status() ClockStatus {
+	now := c.peekNow()
+	offset := c.offset(now)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return ClockStatus{
+		Skew:   c.skew.String(),
+		Drift:  c.drift.String(),
+		Offset: offset.Round(time.Millisecond).String(),
+		Now:    now.Add(offset),
+	}
+}
+
+// Clock returns the broker clock
+func (e *Engine) Clock() *Clock {
+	return e.clock
+}
+
+// Now returns the broker's current time, skewed if a skew is set
+func (e *Engine) Now() time.Time {
+	return e.clock.Now()
+}
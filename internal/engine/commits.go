@@ -0,0 +1,160 @@
+package engine
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// CommitTracker records how often each consumer group commits, so
+// consumers that keep fetching but stop committing show up before their
+// lag does. An interval is the time between two commits of the same topic
+// by the same group; a group's distribution pools all of its topics.
+type CommitTracker struct {
+	start time.Time
+
+	mu      sync.Mutex
+	groups  map[string]*groupCommits
+	version uint64
+}
+
+type groupCommits struct {
+	commits    int64
+	lastCommit time.Time
+	lastTopic  map[string]time.Time
+	intervals  LatencyTracker
+}
+
+// CommitStats summarizes a group's commit intervals
+type CommitStats struct {
+	Commits    int64      `json:"commits"` // since startup
+	LastCommit *time.Time `json:"last_commit,omitempty"`
+	P50Ms      *float64   `json:"interval_p50_ms,omitempty"`
+	P99Ms      *float64   `json:"interval_p99_ms,omitempty"`
+	MaxMs      *float64   `json:"interval_max_ms,omitempty"`
+	Stalled    bool       `json:"stalled"`
+	StalledFor string     `json:"stalled_for,omitempty"`
+}
+
+// NewCommitTracker creates a new CommitTracker
+func NewCommitTracker() *CommitTracker {
+	return &CommitTracker{
+		start:  time.Now(),
+		groups: make(map[string]*groupCommits),
+	}
+}
+
+// Observe records a commit by group for topic
+func (t *CommitTracker) Observe(group, topic string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	g, ok := t.groups[group]
+	if !ok {
+		g = &groupCommits{lastTopic: make(map[string]time.Time)}
+		t.groups[group] = g
+	}
+	if prev, ok := g.lastTopic[topic]; ok {
+		g.intervals.Observe(at.Sub(prev))
+	}
+	g.lastTopic[topic] = at
+	g.lastCommit = at
+	g.commits++
+	t.version++
+}
+
+// Forget drops a deleted group's history
+func (t *CommitTracker) Forget(group string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.groups, group)
+	t.version++
+}
+
+// lastCommit returns when group last committed, or the zero time
+func (t *CommitTracker) lastCommit(group string) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if g, ok := t.groups[group]; ok {
+		return g.lastCommit
+	}
+	return time.Time{}
+}
+
+// CommitsVersion returns a counter that changes with every commit
+func (e *Engine) CommitsVersion() uint64 {
+	e.commits.mu.Lock()
+	defer e.commits.mu.Unlock()
+	return e.commits.version
+}
+
+// CommitStats returns a group's commit interval distribution and whether
+// it is stalled: it has members but has not committed for longer than
+// groups.commit_stall_threshold. Before a group's first commit the clock
+// starts when the group was created or the broker started, whichever is
+// later. A zero threshold disables stall detection.
+func (e *Engine) CommitStats(groupID string) CommitStats {
+	var stats CommitStats
+	t := e.commits
+
+	t.mu.Lock()
+	if g, ok := t.groups[groupID]; ok {
+		stats.Commits = g.commits
+		last := g.lastCommit
+		stats.LastCommit = &last
+		if p50, ok := g.intervals.Percentile(50); ok {
+			stats.P50Ms = durationMs(p50)
+			p99, _ := g.intervals.Percentile(99)
+			stats.P99Ms = durationMs(p99)
+			max, _ := g.intervals.Percentile(100)
+			stats.MaxMs = durationMs(max)
+		}
+	}
+	t.mu.Unlock()
+
+	if stalled, idle := e.commitStalled(groupID); stalled {
+		stats.Stalled = true
+		stats.StalledFor = idle.Round(time.Second).String()
+	}
+	return stats
+}
+
+// commitStalled reports whether a group with members has gone longer than
+// the stall threshold without committing, and for how long it has
+func (e *Engine) commitStalled(groupID string) (bool, time.Duration) {
+	threshold := e.config.Groups.CommitStallThreshold
+	if threshold <= 0 {
+		return false, 0
+	}
+	group, ok := e.GetGroup(groupID)
+	if !ok || len(group.Members) == 0 {
+		return false, 0
+	}
+
+	since := e.commits.lastCommit(groupID)
+	if since.IsZero() {
+		since = e.commits.start
+		if group.CreatedAt.After(since) {
+			since = group.CreatedAt
+		}
+	}
+	idle := time.Since(since)
+	return idle > threshold, idle
+}
+
+// StalledCommitters returns the groups that have stopped committing, sorted
+func (e *Engine) StalledCommitters() []string {
+	stalled := make([]string, 0)
+	for _, id := range e.ListGroups() {
+		if ok, _ := e.commitStalled(id); ok {
+			stalled = append(stalled, id)
+		}
+	}
+	sort.Strings(stalled)
+	return stalled
+}
+
+func durationMs(d time.Duration) *float64 {
+	ms := float64(d) / float64(time.Millisecond)
+	return &ms
+}
@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+)
+
+// ConfigureCodecs applies the compression config to the codec registry:
+// disabled codecs are removed and the rest get their settings. It affects
+// the whole process, so it runs once at startup.
+func ConfigureCodecs(cfg config.CompressionConfig) error {
+	for _, name := range cfg.Disabled {
+		id, err := kafkaproto.ParseCodec(name)
+		if err != nil {
+			return fmt.Errorf("compression.disabled: %w", err)
+		}
+		if id == kafkaproto.CompressionNone {
+			return fmt.Errorf("compression.disabled: uncompressed batches cannot be disabled")
+		}
+		kafkaproto.DisableCodec(id)
+	}
+	for _, name := range cfg.Accept {
+		if _, err := kafkaproto.ParseCodec(name); err != nil {
+			return fmt.Errorf("compression.accept: %w", err)
+		}
+	}
+	for name, codecCfg := range cfg.Codecs {
+		id, err := kafkaproto.ParseCodec(name)
+		if err != nil {
+			return fmt.Errorf("compression.codecs: %w", err)
+		}
+		if !kafkaproto.CodecAvailable(id) {
+			// Settings for a codec that is disabled or not linked are moot
+			continue
+		}
+		settings := kafkaproto.CodecSettings{Level: codecCfg.Level}
+		if codecCfg.Dictionary != "" {
+			if settings.Dictionary, err = os.ReadFile(codecCfg.Dictionary); err != nil {
+				return fmt.Errorf("compression.codecs.%s: %w", name, err)
+			}
+		}
+		if err := kafkaproto.ConfigureCodec(id, settings); err != nil {
+			return fmt.Errorf("compression.codecs.%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// CheckBatches vets record batches a client produced before they are
+// stored as they are: each must match its CRC and be in a codec that is
+// available and accepted, and with compression.validate on its records
+// must decode. Data that is not v2 record batches is not checked.
+func (e *Engine) CheckBatches(data []byte) error {
+	if len(data) <= kafkaproto.RecordBatchHeaderSize || data[16] != 2 {
+		return nil
+	}
+	validate := e.config.Compression.Validate
+	batches, err := kafkaproto.SplitRecordBatches(data)
+	if err != nil {
+		if validate {
+			return fmt.Errorf("%w: %v", ErrCorruptBatch, err)
+		}
+		return nil
+	}
+	for _, b := range batches {
+		if !b.ValidCRC() {
+			return fmt.Errorf("%w: batch at offset %d fails its CRC", ErrCorruptBatch, b.BaseOffset)
+		}
+		// The store gives a batch as many offsets as it declares records;
+		// a last offset delta that disagrees would skip or reuse some
+		if n := b.RecordCount(); n <= 0 || n != int(b.LastOffsetDelta)+1 {
+			return fmt.Errorf("%w: batch at offset %d declares %d records but a last offset delta of %d", ErrCorruptBatch, b.BaseOffset, n, b.LastOffsetDelta)
+		}
+		if !e.codecAccepted(b.Codec) {
+			return fmt.Errorf("%w: %s", ErrUnsupportedCompression, kafkaproto.CodecName(b.Codec))
+		}
+		if validate {
+			if _, err := b.DecodeRecords(); err != nil {
+				return fmt.Errorf("%w: %s batch: %v", ErrCorruptBatch, kafkaproto.CodecName(b.Codec), err)
+			}
+		}
+	}
+	return nil
+}
+
+// codecAccepted reports whether producers may send batches in a codec.
+// Uncompressed batches are always accepted.
+func (e *Engine) codecAccepted(id int8) bool {
+	if id == kafkaproto.CompressionNone {
+		return true
+	}
+	if !kafkaproto.CodecAvailable(id) {
+		return false
+	}
+	accept := e.config.Compression.Accept
+	if len(accept) == 0 {
+		return true
+	}
+	for _, name := range accept {
+		if accepted, err := kafkaproto.ParseCodec(name); err == nil && accepted == id {
+			return true
+		}
+	}
+	return false
+}
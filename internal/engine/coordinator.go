@@ -0,0 +1,761 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+	"github.com/rizkyandriawan/monolog/internal/ids"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// Group coordinator errors, mapped to Kafka error codes by the server
+var (
+	ErrInvalidSessionTimeout     = errors.New("invalid session timeout")
+	ErrMemberIDRequired          = errors.New("member id required")
+	ErrUnknownMemberID           = errors.New("unknown member id")
+	ErrRebalanceInProgress       = errors.New("rebalance in progress")
+	ErrIllegalGeneration         = errors.New("illegal generation")
+	ErrInconsistentGroupProtocol = errors.New("inconsistent group protocol")
+	ErrGroupActive               = errors.New("group has active members")
+	ErrCoordinatorNotAvailable   = errors.New("coordinator not available")
+)
+
+// Coordinator group states
+const (
+	GroupEmpty               = "empty"
+	GroupPreparingRebalance  = "preparing_rebalance"
+	GroupCompletingRebalance = "completing_rebalance"
+	GroupStable              = "stable"
+)
+
+// GroupProtocol is an assignment protocol a member supports
+type GroupProtocol struct {
+	Name     string
+	Metadata []byte
+}
+
+// JoinRequest is a member's request to join (or rejoin) a group
+type JoinRequest struct {
+	GroupID          string
+	MemberID         string
+	ClientID         string
+	ProtocolType     string
+	Protocols        []GroupProtocol
+	SessionTimeout   time.Duration
+	RebalanceTimeout time.Duration
+
+	// RequireKnownMemberID makes a join with an empty member ID fail with
+	// ErrMemberIDRequired and an assigned ID (JoinGroup v4+)
+	RequireKnownMemberID bool
+}
+
+// JoinResult is the outcome of a completed rebalance for one member
+type JoinResult struct {
+	MemberID   string
+	Generation int32
+	Protocol   string
+	LeaderID   string
+	Members    []JoinedMember // only populated for the leader
+}
+
+// JoinedMember is a member as seen by the group leader
+type JoinedMember struct {
+	ID       string
+	Metadata []byte
+}
+
+// GroupCoordinator runs the consumer group rebalance protocol. A join
+// starts a rebalance that completes once every known member has rejoined
+// or the longest rebalance timeout elapses; members that did not rejoin by
+// then are removed. The first rebalance of an empty group is held for
+// groups.initial_rebalance_delay to gather members starting together.
+// Membership is written through to the group store.
+type GroupCoordinator struct {
+	groupStore store.GroupStoreInterface
+	config     config.GroupsConfig
+	events     *EventBus
+	heartbeats *HeartbeatFlusher // nil writes every heartbeat through
+
+	mu     sync.Mutex
+	groups map[string]*coordGroup
+}
+
+type coordGroup struct {
+	id            string
+	state         string
+	generation    int32
+	protocolType  string
+	protocol      string
+	leader        string
+	members       map[string]*coordMember
+	pending       map[string]time.Time // assigned member IDs awaiting their first join
+	rebalanceSeq  int                  // invalidates timers of earlier rebalances
+	rebalanceTmr  *time.Timer
+	initialJoin   bool // the join phase is held by the initial rebalance delay
+	joinedInDelay bool // a member joined since the delay was last extended
+	syncWaiters   map[string]chan syncOutcome
+	assignments   map[string][]byte
+}
+
+type coordMember struct {
+	id               string
+	clientID         string
+	protocols        []GroupProtocol
+	sessionTimeout   time.Duration
+	rebalanceTimeout time.Duration
+	joinedAt         time.Time
+	lastHeartbeat    time.Time
+	joinWaiter       chan joinOutcome // set while waiting for the rebalance to complete
+}
+
+type joinOutcome struct {
+	result *JoinResult
+	err    error
+}
+
+type syncOutcome struct {
+	assignment []byte
+	err        error
+}
+
+// persistCtx is used for the coordinator's store writes. Group state lives
+// in memory and the store is its write-behind copy, so a write must not be
+// abandoned along with the request that triggered it.
+var persistCtx = context.Background()
+
+// newMemberID returns a member ID the way Kafka brokers make them: the
+// client ID followed by a random UUID, so IDs never collide across groups
+// or restarts and say nothing about when the member joined
+func newMemberID(clientID string) string {
+	b := ids.UUID()
+	return fmt.Sprintf("%s-%x-%x-%x-%x-%x", clientID, b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// persisted logs a store write that failed and returns it as
+// ErrCoordinatorNotAvailable. Requests write to the store before they
+// change the coordinator's state and fail with the error, so the client
+// retries; background work such as session expiry only logs it, leaving
+// the store's copy of the group behind until a later write succeeds.
+func persisted(groupID, op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	log.Printf("[engine] group %s: %s not persisted: %v", groupID, op, err)
+	return fmt.Errorf("%w: group %s: %s not persisted: %v", ErrCoordinatorNotAvailable, groupID, op, err)
+}
+
+// NewGroupCoordinator creates a new GroupCoordinator
+func NewGroupCoordinator(groupStore store.GroupStoreInterface, cfg config.GroupsConfig, events *EventBus) *GroupCoordinator {
+	return &GroupCoordinator{
+		groupStore: groupStore,
+		config:     cfg,
+		events:     events,
+		groups:     make(map[string]*coordGroup),
+	}
+}
+
+// group returns the coordinator state for id, creating it from the store.
+// Caller must hold c.mu.
+func (c *GroupCoordinator) group(id string) *coordGroup {
+	if g, ok := c.groups[id]; ok {
+		return g
+	}
+	g := &coordGroup{
+		id:          id,
+		state:       GroupEmpty,
+		members:     make(map[string]*coordMember),
+		pending:     make(map[string]time.Time),
+		syncWaiters: make(map[string]chan syncOutcome),
+	}
+	if sg, ok := c.groupStore.GetGroup(id); ok {
+		g.generation = sg.Generation
+	}
+	c.groups[id] = g
+	return g
+}
+
+// Join adds or refreshes a member and blocks until the resulting
+// rebalance completes
+func (c *GroupCoordinator) Join(ctx context.Context, req JoinRequest) (*JoinResult, error) {
+	if min := c.config.MinSessionTimeout; min > 0 && req.SessionTimeout < min {
+		return nil, fmt.Errorf("%w: %s below minimum %s", ErrInvalidSessionTimeout, req.SessionTimeout, min)
+	}
+	if max := c.config.MaxSessionTimeout; max > 0 && req.SessionTimeout > max {
+		return nil, fmt.Errorf("%w: %s above maximum %s", ErrInvalidSessionTimeout, req.SessionTimeout, max)
+	}
+	if req.RebalanceTimeout <= 0 {
+		req.RebalanceTimeout = req.SessionTimeout
+	}
+
+	c.mu.Lock()
+	g := c.group(req.GroupID)
+
+	if len(g.members) > 0 && (req.ProtocolType != g.protocolType || !g.supportsCommon(req.MemberID, req.Protocols)) {
+		c.mu.Unlock()
+		return nil, ErrInconsistentGroupProtocol
+	}
+
+	now := time.Now()
+	memberID := req.MemberID
+	if memberID == "" {
+		memberID = newMemberID(req.ClientID)
+		if req.RequireKnownMemberID {
+			// The client must rejoin with this ID within its session timeout
+			g.pending[memberID] = now.Add(req.SessionTimeout)
+			c.mu.Unlock()
+			return &JoinResult{MemberID: memberID, Generation: -1}, ErrMemberIDRequired
+		}
+	} else if _, known := g.members[memberID]; !known {
+		if _, pending := g.pending[memberID]; !pending {
+			c.mu.Unlock()
+			return nil, ErrUnknownMemberID
+		}
+	}
+
+	var metadata []byte
+	if len(req.Protocols) > 0 {
+		metadata = req.Protocols[0].Metadata
+	}
+	_, err := c.groupStore.GetOrCreateGroup(persistCtx, g.id)
+	if err == nil {
+		err = c.groupStore.AddMember(persistCtx, g.id, memberID, req.ClientID, int32(req.SessionTimeout/time.Millisecond), metadata)
+	}
+	if err := persisted(g.id, "join of "+memberID, err); err != nil {
+		c.mu.Unlock()
+		return nil, err
+	}
+	delete(g.pending, memberID)
+
+	m, ok := g.members[memberID]
+	if !ok {
+		m = &coordMember{id: memberID, joinedAt: now}
+		g.members[memberID] = m
+		g.joinedInDelay = g.initialJoin
+		log.Printf("[engine] group %s: member %s joined (client %q)", g.id, memberID, req.ClientID)
+		c.events.Publish(Event{Type: EventMemberJoined, Group: g.id, Member: memberID})
+	}
+	m.clientID = req.ClientID
+	m.protocols = req.Protocols
+	m.sessionTimeout = req.SessionTimeout
+	m.rebalanceTimeout = req.RebalanceTimeout
+	m.lastHeartbeat = now
+	if m.joinWaiter != nil {
+		m.joinWaiter <- joinOutcome{err: ErrRebalanceInProgress} // superseded by this join
+	}
+	ch := make(chan joinOutcome, 1)
+	m.joinWaiter = ch
+	g.protocolType = req.ProtocolType
+
+	if g.state != GroupPreparingRebalance {
+		c.prepareRebalance(g)
+	}
+	c.maybeCompleteJoin(g)
+	c.mu.Unlock()
+
+	// A member whose client went away stays until its session expires,
+	// as it would had it crashed
+	select {
+	case out := <-ch:
+		return out.result, out.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Sync delivers the leader's assignments. Followers block until the
+// leader has synced or a new rebalance starts.
+func (c *GroupCoordinator) Sync(ctx context.Context, groupID, memberID string, generation int32, assignments map[string][]byte) ([]byte, error) {
+	c.mu.Lock()
+	g, ok := c.groups[groupID]
+	if !ok {
+		c.mu.Unlock()
+		return nil, ErrUnknownMemberID
+	}
+	m, ok := g.members[memberID]
+	if !ok {
+		c.mu.Unlock()
+		return nil, ErrUnknownMemberID
+	}
+	if generation != g.generation {
+		c.mu.Unlock()
+		return nil, ErrIllegalGeneration
+	}
+	m.lastHeartbeat = time.Now()
+
+	switch g.state {
+	case GroupPreparingRebalance:
+		c.mu.Unlock()
+		return nil, ErrRebalanceInProgress
+
+	case GroupStable:
+		assignment := g.assignments[memberID]
+		c.mu.Unlock()
+		return assignment, nil
+	}
+
+	// Completing: the leader's sync finishes the rebalance
+	if memberID == g.leader {
+		for id, a := range assignments {
+			if _, ok := g.members[id]; !ok {
+				continue
+			}
+			if err := persisted(g.id, "assignment of "+id, c.groupStore.SetMemberAssignment(persistCtx, g.id, id, a)); err != nil {
+				c.mu.Unlock()
+				return nil, err
+			}
+		}
+		g.assignments = make(map[string][]byte, len(assignments))
+		for id, a := range assignments {
+			if _, ok := g.members[id]; ok {
+				g.assignments[id] = a
+			}
+		}
+		c.setState(g, GroupStable)
+		for id, ch := range g.syncWaiters {
+			ch <- syncOutcome{assignment: g.assignments[id]}
+			delete(g.syncWaiters, id)
+		}
+		assignment := g.assignments[memberID]
+		c.mu.Unlock()
+		return assignment, nil
+	}
+
+	ch := make(chan syncOutcome, 1)
+	g.syncWaiters[memberID] = ch
+	c.mu.Unlock()
+
+	select {
+	case out := <-ch:
+		return out.assignment, out.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Heartbeat refreshes a member's session. ErrRebalanceInProgress tells
+// the member to rejoin; ErrIllegalGeneration fences a replaced member.
+func (c *GroupCoordinator) Heartbeat(groupID, memberID string, generation int32) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g, ok := c.groups[groupID]
+	if !ok {
+		return ErrUnknownMemberID
+	}
+	m, ok := g.members[memberID]
+	if !ok {
+		return ErrUnknownMemberID
+	}
+	if generation != g.generation {
+		return ErrIllegalGeneration
+	}
+	now := time.Now()
+	if c.heartbeats != nil {
+		c.heartbeats.Record(groupID, memberID, now)
+	} else if err := persisted(groupID, "heartbeat of "+memberID, c.groupStore.UpdateHeartbeat(persistCtx, groupID, memberID)); err != nil {
+		return err
+	}
+	m.lastHeartbeat = now
+
+	if g.state == GroupPreparingRebalance {
+		return ErrRebalanceInProgress
+	}
+	return nil
+}
+
+// ValidateCommit fences offset commits. Commits without member info
+// (generation < 0) are only accepted while the group has no members;
+// otherwise the member must belong to the current generation, and commits
+// are refused while the leader is still computing assignments.
+func (c *GroupCoordinator) ValidateCommit(groupID, memberID string, generation int32) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g, ok := c.groups[groupID]
+	if generation < 0 && (!ok || len(g.members) == 0) {
+		return nil
+	}
+	if !ok {
+		return ErrIllegalGeneration
+	}
+	if g.state == GroupCompletingRebalance {
+		return ErrRebalanceInProgress
+	}
+	m, ok := g.members[memberID]
+	if !ok {
+		return ErrUnknownMemberID
+	}
+	if generation != g.generation {
+		return ErrIllegalGeneration
+	}
+	m.lastHeartbeat = time.Now()
+	return nil
+}
+
+// WithInactiveGroup runs fn while the group has no members, holding off
+// joins until it returns, so what fn writes cannot race a rebalance. With
+// generation >= 0 the group must also still be at that generation. fn is
+// passed the group's current generation.
+func (c *GroupCoordinator) WithInactiveGroup(groupID string, generation int32, fn func(current int32) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var current int32
+	if g, ok := c.groups[groupID]; ok {
+		if len(g.members) > 0 || len(g.pending) > 0 {
+			return fmt.Errorf("%w: %s", ErrGroupActive, groupID)
+		}
+		current = g.generation
+	} else if sg, ok := c.groupStore.GetGroup(groupID); ok {
+		current = sg.Generation
+	}
+	if generation >= 0 && generation != current {
+		return fmt.Errorf("%w: group %s is at generation %d, not %d", ErrIllegalGeneration, groupID, current, generation)
+	}
+	return fn(current)
+}
+
+// Leave removes a member and rebalances the rest of the group
+func (c *GroupCoordinator) Leave(groupID, memberID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g, ok := c.groups[groupID]
+	if !ok {
+		return ErrUnknownMemberID
+	}
+	if _, ok := g.pending[memberID]; ok {
+		delete(g.pending, memberID)
+		return nil
+	}
+	if _, ok := g.members[memberID]; !ok {
+		return ErrUnknownMemberID
+	}
+	if err := persisted(g.id, "removal of "+memberID, c.groupStore.RemoveMember(persistCtx, g.id, memberID)); err != nil {
+		return err
+	}
+
+	c.removeMember(g, memberID, LeaveRequested)
+	c.membershipChanged(g)
+	return nil
+}
+
+// Expire removes members whose session timeout has elapsed since their
+// last heartbeat and returns them as "group/member"
+func (c *GroupCoordinator) Expire() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	var expired []string
+	for _, g := range c.groups {
+		for id, deadline := range g.pending {
+			if now.After(deadline) {
+				delete(g.pending, id)
+			}
+		}
+
+		changed := false
+		for id, m := range g.members {
+			// Members waiting in JoinGroup are bounded by the rebalance timeout instead
+			if m.joinWaiter != nil || now.Sub(m.lastHeartbeat) <= m.sessionTimeout {
+				continue
+			}
+			persisted(g.id, "removal of "+id, c.groupStore.RemoveMember(persistCtx, g.id, id))
+			c.removeMember(g, id, LeaveSession)
+			expired = append(expired, g.id+"/"+id)
+			changed = true
+		}
+		if changed {
+			c.membershipChanged(g)
+		}
+	}
+	return expired
+}
+
+// State returns a group's coordinator state and generation
+func (c *GroupCoordinator) State(groupID string) (string, int32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g, ok := c.groups[groupID]
+	if !ok {
+		return GroupEmpty, 0
+	}
+	return g.state, g.generation
+}
+
+// Forget drops all coordinator state for a deleted group. Members still
+// waiting are told they are unknown so they rejoin from scratch.
+func (c *GroupCoordinator) Forget(groupID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g, ok := c.groups[groupID]
+	if !ok {
+		return
+	}
+	g.rebalanceSeq++
+	if g.rebalanceTmr != nil {
+		g.rebalanceTmr.Stop()
+	}
+	for _, m := range g.members {
+		if m.joinWaiter != nil {
+			m.joinWaiter <- joinOutcome{err: ErrUnknownMemberID}
+		}
+	}
+	for _, ch := range g.syncWaiters {
+		ch <- syncOutcome{err: ErrUnknownMemberID}
+	}
+	delete(c.groups, groupID)
+}
+
+// --- internals (caller holds c.mu) ---
+
+// membershipChanged rebalances after a member left or expired
+func (c *GroupCoordinator) membershipChanged(g *coordGroup) {
+	switch {
+	case len(g.members) == 0:
+		g.rebalanceSeq++
+		if g.rebalanceTmr != nil {
+			g.rebalanceTmr.Stop()
+		}
+		g.initialJoin = false
+		c.setState(g, GroupEmpty)
+		g.leader = ""
+	case g.state == GroupPreparingRebalance:
+		c.maybeCompleteJoin(g)
+	default:
+		c.prepareRebalance(g)
+	}
+}
+
+// setState moves g to state, notifying watchers of the transition
+func (c *GroupCoordinator) setState(g *coordGroup, state string) {
+	if g.state == state {
+		return
+	}
+	g.state = state
+	c.events.Publish(Event{Type: EventGroupState, Group: g.id, State: state, Generation: g.generation})
+}
+
+// removeMember drops a member from the coordinator, ending its pending
+// join or sync. Callers remove it from the store.
+func (c *GroupCoordinator) removeMember(g *coordGroup, memberID, reason string) {
+	m := g.members[memberID]
+	if m != nil && m.joinWaiter != nil {
+		m.joinWaiter <- joinOutcome{err: ErrUnknownMemberID}
+	}
+	if ch, ok := g.syncWaiters[memberID]; ok {
+		ch <- syncOutcome{err: ErrUnknownMemberID}
+		delete(g.syncWaiters, memberID)
+	}
+	delete(g.members, memberID)
+	log.Printf("[engine] group %s: member %s removed (%s)", g.id, memberID, reason)
+	c.events.Publish(Event{Type: EventMemberLeft, Group: g.id, Member: memberID, Reason: reason})
+}
+
+// prepareRebalance starts a rebalance: pending syncs are aborted and the
+// join phase ends after the longest member rebalance timeout, or for an
+// empty group once the initial rebalance delay runs out
+func (c *GroupCoordinator) prepareRebalance(g *coordGroup) {
+	wasEmpty := g.state == GroupEmpty
+	c.setState(g, GroupPreparingRebalance)
+	for id, ch := range g.syncWaiters {
+		ch <- syncOutcome{err: ErrRebalanceInProgress}
+		delete(g.syncWaiters, id)
+	}
+
+	var timeout time.Duration
+	for _, m := range g.members {
+		if m.rebalanceTimeout > timeout {
+			timeout = m.rebalanceTimeout
+		}
+	}
+
+	g.rebalanceSeq++
+	seq := g.rebalanceSeq
+	if g.rebalanceTmr != nil {
+		g.rebalanceTmr.Stop()
+	}
+	if delay := c.config.InitialRebalanceDelay; wasEmpty && delay > 0 {
+		g.initialJoin = true
+		wait := min(delay, timeout)
+		c.delayInitialJoin(g, seq, wait, timeout-wait)
+		return
+	}
+	g.rebalanceTmr = time.AfterFunc(timeout, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if g.rebalanceSeq == seq && g.state == GroupPreparingRebalance {
+			c.completeJoin(g)
+		}
+	})
+}
+
+// delayInitialJoin holds the first join phase of an empty group for wait,
+// then completes it unless members kept joining, in which case it waits
+// again, by the initial delay or what is left of the rebalance timeout
+func (c *GroupCoordinator) delayInitialJoin(g *coordGroup, seq int, wait, remaining time.Duration) {
+	g.joinedInDelay = false
+	g.rebalanceTmr = time.AfterFunc(wait, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if g.rebalanceSeq != seq || g.state != GroupPreparingRebalance {
+			return
+		}
+		if g.joinedInDelay && remaining > 0 {
+			next := min(c.config.InitialRebalanceDelay, remaining)
+			c.delayInitialJoin(g, seq, next, remaining-next)
+			return
+		}
+		c.completeJoin(g)
+	})
+}
+
+// maybeCompleteJoin completes the join phase once every member has
+// rejoined, unless the initial rebalance delay is holding it
+func (c *GroupCoordinator) maybeCompleteJoin(g *coordGroup) {
+	if g.state != GroupPreparingRebalance || g.initialJoin {
+		return
+	}
+	for _, m := range g.members {
+		if m.joinWaiter == nil {
+			return
+		}
+	}
+	c.completeJoin(g)
+}
+
+// completeJoin ends the join phase: members that did not rejoin are
+// removed, the generation advances and every joined member gets its result
+func (c *GroupCoordinator) completeJoin(g *coordGroup) {
+	g.rebalanceSeq++
+	if g.rebalanceTmr != nil {
+		g.rebalanceTmr.Stop()
+	}
+	g.initialJoin = false
+
+	for id, m := range g.members {
+		if m.joinWaiter == nil {
+			persisted(g.id, "removal of "+id, c.groupStore.RemoveMember(persistCtx, g.id, id))
+			c.removeMember(g, id, LeaveRebalance)
+		}
+	}
+	// Drop members persisted by an earlier run that never rejoined
+	if sg, ok := c.groupStore.GetGroup(g.id); ok {
+		var stale []string
+		for id := range sg.Members {
+			if _, ok := g.members[id]; !ok {
+				stale = append(stale, id)
+			}
+		}
+		for _, id := range stale {
+			persisted(g.id, "removal of "+id, c.groupStore.RemoveMember(persistCtx, g.id, id))
+		}
+	}
+
+	if len(g.members) == 0 {
+		c.setState(g, GroupEmpty)
+		g.leader = ""
+		return
+	}
+
+	if gen, err := c.groupStore.IncrementGeneration(persistCtx, g.id); err == nil {
+		g.generation = gen
+	} else {
+		persisted(g.id, "new generation", err)
+		g.generation++
+	}
+
+	if _, ok := g.members[g.leader]; !ok {
+		g.leader = ""
+		for id, m := range g.members {
+			if g.leader == "" || m.joinedAt.Before(g.members[g.leader].joinedAt) {
+				g.leader = id
+			}
+		}
+	}
+	g.protocol = g.selectProtocol()
+	g.assignments = nil
+	c.setState(g, GroupCompletingRebalance)
+	log.Printf("[engine] group %s: generation %d with %d members, leader %s", g.id, g.generation, len(g.members), g.leader)
+
+	var members []JoinedMember
+	for id, m := range g.members {
+		members = append(members, JoinedMember{ID: id, Metadata: m.metadataFor(g.protocol)})
+	}
+	for id, m := range g.members {
+		result := &JoinResult{
+			MemberID:   id,
+			Generation: g.generation,
+			Protocol:   g.protocol,
+			LeaderID:   g.leader,
+		}
+		if id == g.leader {
+			result.Members = members
+		}
+		m.joinWaiter <- joinOutcome{result: result}
+		m.joinWaiter = nil
+	}
+}
+
+// supportsCommon reports whether protocols shares at least one protocol
+// with every other member of the group
+func (g *coordGroup) supportsCommon(memberID string, protocols []GroupProtocol) bool {
+	for _, p := range protocols {
+		common := true
+		for id, m := range g.members {
+			if id != memberID && !m.supports(p.Name) {
+				common = false
+				break
+			}
+		}
+		if common {
+			return true
+		}
+	}
+	return false
+}
+
+// selectProtocol picks the leader's most preferred protocol that every
+// member supports
+func (g *coordGroup) selectProtocol() string {
+	leader := g.members[g.leader]
+	for _, p := range leader.protocols {
+		all := true
+		for _, m := range g.members {
+			if !m.supports(p.Name) {
+				all = false
+				break
+			}
+		}
+		if all {
+			return p.Name
+		}
+	}
+	return ""
+}
+
+func (m *coordMember) supports(name string) bool {
+	for _, p := range m.protocols {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *coordMember) metadataFor(name string) []byte {
+	for _, p := range m.protocols {
+		if p.Name == name {
+			return p.Metadata
+		}
+	}
+	return nil
+}
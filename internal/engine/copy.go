@@ -0,0 +1,173 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// ============================================================================
+// Copy
+//
+// Copies a range of one topic's messages into another, server-side, so
+// replaying what a bad deploy mishandled is one call instead of an export
+// and an import. Messages keep their keys, values, headers and timestamps;
+// they get new offsets in the destination.
+// ============================================================================
+
+// copyPageSize is how many stored records a copy reads, and how many
+// messages it appends, at a time
+const copyPageSize = 500
+
+// CopyRequest selects the messages a copy moves. The offset and time
+// ranges combine; times are append times, as in exports.
+type CopyRequest struct {
+	Destination string
+	StartOffset int64        // first source offset to consider
+	EndOffset   int64        // source offset to stop before; 0 or less copies to the end of the log
+	From, To    time.Time    // append time range [From, To); zero for unbounded
+	Match       MessageMatch // only messages matching are copied
+	Limit       int          // copy at most this many messages; 0 for no limit
+	DryRun      bool         // count what would be copied without writing
+}
+
+// CopyResult reports what a copy did
+type CopyResult struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Scanned     int    `json:"scanned"`      // source messages looked at
+	Copied      int    `json:"copied"`       // messages appended to the destination, or that would be on a dry run
+	FirstOffset int64  `json:"first_offset"` // destination offset of the first copied message; -1 if none
+	NextOffset  int64  `json:"next_offset"`  // source offset to pass as the start of a copy resuming this one
+	DryRun      bool   `json:"dry_run,omitempty"`
+}
+
+// CopyRange copies the messages of topic that req selects to
+// req.Destination, which is created like on produce if it does not exist.
+// The source's end is taken when the copy starts, so messages appended
+// meanwhile are left for a later copy.
+func (e *Engine) CopyRange(ctx context.Context, topic string, req CopyRequest) (*CopyResult, error) {
+	if req.Destination == "" {
+		return nil, fmt.Errorf("%w: destination is required", ErrInvalidCopy)
+	}
+	if req.Destination == topic {
+		return nil, fmt.Errorf("%w: destination must differ from the source", ErrInvalidCopy)
+	}
+	if req.StartOffset < 0 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidOffset, req.StartOffset)
+	}
+	if !e.topicStore.TopicExists(topic) {
+		return nil, fmt.Errorf("%w: %s", store.ErrTopicNotFound, topic)
+	}
+	latest, err := e.LatestOffset(topic)
+	if err != nil {
+		return nil, err
+	}
+	end := latest + 1
+	if req.EndOffset > 0 {
+		end = min(end, req.EndOffset)
+	}
+	from, to := int64(0), int64(math.MaxInt64)
+	if !req.From.IsZero() {
+		from = req.From.UnixMilli()
+	}
+	if !req.To.IsZero() {
+		to = req.To.UnixMilli()
+	}
+
+	result := &CopyResult{Source: topic, Destination: req.Destination, FirstOffset: -1, DryRun: req.DryRun}
+	var batch []store.Record
+	flush := func() error {
+		if len(batch) == 0 || req.DryRun {
+			batch = batch[:0]
+			return nil
+		}
+		offset, err := e.appendCopied(ctx, req.Destination, batch)
+		if err != nil {
+			return err
+		}
+		if result.FirstOffset < 0 {
+			result.FirstOffset = offset
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	cursor := req.StartOffset
+scan:
+	for cursor < end {
+		records, err := e.Fetch(ctx, topic, cursor, copyPageSize)
+		if err != nil {
+			return result, err
+		}
+		if len(records) == 0 {
+			break
+		}
+		for _, rec := range records {
+			inRange := rec.Timestamp >= from && rec.Timestamp < to
+			for _, msg := range storedMessages(topic, rec) {
+				if msg.Offset < cursor {
+					continue
+				}
+				if msg.Offset >= end {
+					break scan
+				}
+				if req.Limit > 0 && result.Copied == req.Limit {
+					break scan
+				}
+				result.Scanned++
+				cursor = msg.Offset + 1
+				if !inRange || !req.Match.Matches(msg) {
+					continue
+				}
+				result.Copied++
+				batch = append(batch, store.Record{Timestamp: msg.Timestamp.UnixMilli(), Key: msg.Key, Value: msg.Value, Headers: headerMap(msg.Headers)})
+			}
+			// A batch may end in offsets taken by transaction markers
+			cursor = max(cursor, min(rec.LastOffset+1, end))
+		}
+		if len(batch) >= copyPageSize {
+			if err := flush(); err != nil {
+				return result, err
+			}
+		}
+	}
+	result.NextOffset = cursor
+	if err := flush(); err != nil {
+		return result, err
+	}
+	if result.Copied > 0 && !req.DryRun {
+		log.Printf("[engine] copied %d of %d messages from %s to %s", result.Copied, result.Scanned, topic, req.Destination)
+	}
+	return result, nil
+}
+
+// appendCopied appends copied messages. Only record batches keep headers
+// in storage, so messages that have any are appended as an uncompressed
+// batch.
+func (e *Engine) appendCopied(ctx context.Context, topic string, records []store.Record) (int64, error) {
+	headers := false
+	for _, r := range records {
+		headers = headers || len(r.Headers) > 0
+	}
+	if !headers {
+		return e.Produce(ctx, topic, records)
+	}
+	batch := make([]kafkaproto.Record, len(records))
+	for i, r := range records {
+		batch[i] = kafkaproto.Record{Offset: int64(i), Timestamp: r.Timestamp, Key: r.Key, Value: r.Value}
+		for k, v := range r.Headers {
+			batch[i].Headers = append(batch[i].Headers, kafkaproto.RecordHeader{Key: k, Value: v})
+		}
+	}
+	data, err := kafkaproto.NewRecordBatch(batch, kafkaproto.CompressionNone, nil)
+	if err != nil {
+		return 0, err
+	}
+	return e.ProduceRaw(ctx, topic, data, kafkaproto.CompressionNone, len(records))
+}
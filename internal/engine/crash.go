@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// maxCrashDumps caps the dump files one process writes, so a request that
+// panics on every retry cannot fill the disk
+const maxCrashDumps = 100
+
+// ReportPanic logs a recovered panic with its stack and context (key/value
+// pairs), counts it, and writes a crash dump when crash.dump_dir is set.
+// Callers recover themselves and decide how to carry on.
+func (e *Engine) ReportPanic(source string, value interface{}, stack []byte, kv ...string) {
+	n := atomic.AddInt64(&e.panicCount, 1)
+
+	var ctx strings.Builder
+	for i := 0; i+1 < len(kv); i += 2 {
+		fmt.Fprintf(&ctx, " %s=%q", kv[i], kv[i+1])
+	}
+	log.Printf("[engine] recovered panic in %s:%s: %v\n%s", source, ctx.String(), value, stack)
+
+	if e.config.Crash.DumpDir == "" || n > maxCrashDumps {
+		return
+	}
+	if err := writeCrashDump(e.config.Crash.DumpDir, n, source, value, stack, kv); err != nil {
+		log.Printf("[engine] failed to write crash dump: %v", err)
+	}
+}
+
+// PanicCount returns the number of panics recovered since startup
+func (e *Engine) PanicCount() int64 {
+	return atomic.LoadInt64(&e.panicCount)
+}
+
+// safely runs fn, reporting and swallowing a panic so a background loop
+// survives one bad pass
+func (e *Engine) safely(source string, fn func()) {
+	defer func() {
+		if v := recover(); v != nil {
+			e.ReportPanic(source, v, debug.Stack())
+		}
+	}()
+	fn()
+}
+
+func writeCrashDump(dir string, n int64, source string, value interface{}, stack []byte, kv []string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	path := filepath.Join(dir, fmt.Sprintf("crash-%s-%d.txt", now.Format("20060102T150405Z"), n))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "time: %s\n", now.Format(time.RFC3339Nano))
+	fmt.Fprintf(&b, "source: %s\n", source)
+	for i := 0; i+1 < len(kv); i += 2 {
+		fmt.Fprintf(&b, "%s: %s\n", kv[i], kv[i+1])
+	}
+	fmt.Fprintf(&b, "panic: %v\n\n%s", value, stack)
+
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	log.Printf("[engine] wrote crash dump %s", path)
+	return nil
+}
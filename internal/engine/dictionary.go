@@ -0,0 +1,353 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// ============================================================================
+// Trained zstd dictionaries
+//
+// Topics created with monolog.zstd.dictionary=true get a zstd dictionary
+// trained on a sample of their recent messages, retrained as new ones
+// arrive. Records produced over HTTP to such a topic are stored as one
+// record batch per request, compressed with the topic's current
+// dictionary. Dictionaries are kept in the store so batches written with
+// an older one stay readable; Kafka consumers, which do not have them, are
+// sent those batches re-encoded without compression.
+// ============================================================================
+
+// ErrDictionariesUnsupported is returned when the storage backend cannot
+// keep dictionaries
+var ErrDictionariesUnsupported = errors.New("storage backend does not support compression dictionaries")
+
+// ErrTooFewSamples is returned when a topic has too few messages to train
+// a dictionary on
+var ErrTooFewSamples = errors.New("too few messages to train a dictionary")
+
+// Dictionaries tracks the dictionary each topic currently compresses with
+type Dictionaries struct {
+	mu      sync.RWMutex
+	current map[string]*topicDictionary
+	trained map[string]int64 // topic's latest offset when it was last trained
+}
+
+type topicDictionary struct {
+	info  store.Dictionary
+	codec kafkaproto.Codec
+	size  int // bytes of dictionary data, which info no longer holds
+}
+
+// NewDictionaries creates an empty Dictionaries
+func NewDictionaries() *Dictionaries {
+	return &Dictionaries{
+		current: make(map[string]*topicDictionary),
+		trained: make(map[string]int64),
+	}
+}
+
+// use makes a dictionary decodable and, being the newest, the one its
+// topic compresses with
+func (d *Dictionaries) use(dict store.Dictionary) error {
+	if err := kafkaproto.AddZstdDictionary(dict.Data); err != nil {
+		return err
+	}
+	codec, err := kafkaproto.NewZstdDictCodec(dict.Data)
+	if err != nil {
+		return err
+	}
+	size := len(dict.Data)
+	dict.Data = nil
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.current[dict.Topic] = &topicDictionary{info: dict, codec: codec, size: size}
+	return nil
+}
+
+func (d *Dictionaries) get(topic string) *topicDictionary {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.current[topic]
+}
+
+func (d *Dictionaries) forget(topic string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.current, topic)
+	delete(d.trained, topic)
+}
+
+// dictionaryEnabled reports whether a topic compresses with trained
+// dictionaries
+func (e *Engine) dictionaryEnabled(topic string) bool {
+	return e.topicConfig(topic, ConfigZstdDictionary) == "true"
+}
+
+// loadDictionaries makes every stored dictionary decodable. Run once from
+// New, before anything is read.
+func (e *Engine) loadDictionaries(ctx context.Context) {
+	ds, ok := e.topicStore.(store.DictionaryStore)
+	if !ok {
+		return
+	}
+	dicts, err := ds.Dictionaries(ctx)
+	if err != nil {
+		log.Printf("[engine] loading compression dictionaries failed: %v", err)
+		return
+	}
+	for _, dict := range dicts {
+		if err := e.dictionaries.use(dict); err != nil {
+			log.Printf("[engine] dictionary %d of %s unusable: %v", dict.ID, dict.Topic, err)
+			continue
+		}
+		// Count the stored dictionary as trained on everything so far, so a
+		// restart does not retrain every topic at once
+		if latest, err := e.topicStore.LatestOffset(dict.Topic); err == nil {
+			e.dictionaries.trained[dict.Topic] = latest
+		}
+	}
+}
+
+// CurrentDictionary returns the dictionary a topic compresses with, or nil
+func (e *Engine) CurrentDictionary(topic string) *store.Dictionary {
+	td := e.dictionaries.get(topic)
+	if td == nil {
+		return nil
+	}
+	info := td.info
+	return &info
+}
+
+// TopicDictionaries returns the dictionaries trained for a topic, oldest first
+func (e *Engine) TopicDictionaries(ctx context.Context, topic string) ([]store.Dictionary, error) {
+	if !e.topicStore.TopicExists(topic) {
+		return nil, fmt.Errorf("%w: %s", store.ErrTopicNotFound, topic)
+	}
+	ds, ok := e.topicStore.(store.DictionaryStore)
+	if !ok {
+		return nil, ErrDictionariesUnsupported
+	}
+	all, err := ds.Dictionaries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dicts := []store.Dictionary{}
+	for _, d := range all {
+		if d.Topic == topic {
+			d.Data = nil
+			dicts = append(dicts, d)
+		}
+	}
+	return dicts, nil
+}
+
+// TrainDictionary trains a new dictionary for a topic on its most recent
+// messages and switches the topic to it
+func (e *Engine) TrainDictionary(ctx context.Context, topic string) (*store.Dictionary, error) {
+	ds, ok := e.topicStore.(store.DictionaryStore)
+	if !ok {
+		return nil, ErrDictionariesUnsupported
+	}
+	latest, err := e.topicStore.LatestOffset(topic)
+	if err != nil {
+		return nil, err
+	}
+	if !e.dictionaryEnabled(topic) {
+		return nil, fmt.Errorf("%w: %s is not enabled on %s", ErrInvalidConfig, ConfigZstdDictionary, topic)
+	}
+	cfg := e.config.Compression.Dictionaries
+	samples, err := e.sampleValues(ctx, topic, latest, cfg.Samples)
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) < cfg.MinSamples {
+		return nil, fmt.Errorf("%w: %s has %d, need %d", ErrTooFewSamples, topic, len(samples), cfg.MinSamples)
+	}
+
+	data, id, err := kafkaproto.TrainZstdDictionary(samples, cfg.MaxSize)
+	if err != nil {
+		return nil, fmt.Errorf("train dictionary for %s: %w", topic, err)
+	}
+	dict := store.Dictionary{Topic: topic, ID: id, CreatedAt: time.Now(), Samples: len(samples), Data: data}
+	if err := ds.SaveDictionary(ctx, dict); err != nil {
+		return nil, err
+	}
+	if err := e.dictionaries.use(dict); err != nil {
+		return nil, err
+	}
+	e.dictionaries.mu.Lock()
+	e.dictionaries.trained[topic] = latest
+	e.dictionaries.mu.Unlock()
+
+	log.Printf("[engine] trained %d-byte dictionary %d for %s on %d messages", len(data), id, topic, len(samples))
+	dict.Data = nil
+	return &dict, nil
+}
+
+// sampleValues returns the values of up to n messages ending at latest
+func (e *Engine) sampleValues(ctx context.Context, topic string, latest int64, n int) ([][]byte, error) {
+	from := max(latest-int64(n)+1, 0)
+	var values [][]byte
+	for from <= latest && len(values) < n {
+		records, err := e.topicStore.Read(ctx, topic, from, n)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			break
+		}
+		for _, rec := range records {
+			for _, msg := range storedMessages(topic, rec) {
+				values = append(values, msg.Value)
+			}
+			from = max(from, rec.LastOffset+1, rec.Offset+1)
+		}
+	}
+	if len(values) > n {
+		values = values[len(values)-n:]
+	}
+	return values, nil
+}
+
+// dictionaryBatch encodes records as one record batch compressed with the
+// topic's current dictionary. It returns nil when the topic has none.
+func (e *Engine) dictionaryBatch(topic string, records []store.Record) ([]byte, error) {
+	if !e.dictionaryEnabled(topic) {
+		return nil, nil
+	}
+	td := e.dictionaries.get(topic)
+	if td == nil {
+		return nil, nil
+	}
+	now := e.Now().UnixMilli()
+	batch := make([]kafkaproto.Record, len(records))
+	for i, r := range records {
+		ts := r.Timestamp
+		if ts == 0 {
+			ts = now
+		}
+		batch[i] = kafkaproto.Record{Offset: int64(i), Timestamp: ts, Key: r.Key, Value: r.Value}
+		for k, v := range r.Headers {
+			batch[i].Headers = append(batch[i].Headers, kafkaproto.RecordHeader{Key: k, Value: v})
+		}
+	}
+	return kafkaproto.NewRecordBatch(batch, kafkaproto.CompressionZstd, td.codec)
+}
+
+// ClientBatch returns stored batch data as a Kafka client can read it:
+// batches compressed with a dictionary, trained or the one producers were
+// given in compression.codecs.zstd, are re-encoded uncompressed, the rest
+// are returned as they are
+func (e *Engine) ClientBatch(data []byte) []byte {
+	if len(data) <= kafkaproto.RecordBatchHeaderSize || data[16] != 2 {
+		return data
+	}
+	batches, err := kafkaproto.SplitRecordBatches(data)
+	if err != nil {
+		return data
+	}
+	var out []byte
+	reencoded := false
+	for _, b := range batches {
+		raw := b.RawRecords
+		if b.Codec == kafkaproto.CompressionZstd && kafkaproto.ZstdFrameDictionary(raw[kafkaproto.RecordBatchHeaderSize:]) != 0 {
+			if plain, err := b.Recompress(kafkaproto.CompressionNone, noCompression{}); err == nil {
+				raw, reencoded = plain, true
+			}
+		}
+		out = append(out, raw...)
+	}
+	if !reencoded {
+		return data
+	}
+	return out
+}
+
+// noCompression is the identity codec
+type noCompression struct{}
+
+func (noCompression) Compress(data []byte) ([]byte, error)   { return data, nil }
+func (noCompression) Decompress(data []byte) ([]byte, error) { return data, nil }
+
+// DictionaryTrainer retrains the dictionaries of topics that have seen
+// enough new messages, on a timer
+type DictionaryTrainer struct {
+	engine   *Engine
+	ticker   *time.Ticker
+	config   config.DictionaryConfig
+	stopChan chan struct{}
+	monitor  loopMonitor
+}
+
+// NewDictionaryTrainer creates a new DictionaryTrainer
+func NewDictionaryTrainer(engine *Engine, cfg config.DictionaryConfig) *DictionaryTrainer {
+	return &DictionaryTrainer{
+		engine:   engine,
+		config:   cfg,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start starts the trainer. A zero interval disables it.
+func (t *DictionaryTrainer) Start() {
+	if t.config.TrainInterval <= 0 {
+		return
+	}
+	t.ticker = time.NewTicker(t.config.TrainInterval)
+	t.monitor.start(t.config.TrainInterval)
+	go t.loop()
+}
+
+// Stop stops the trainer
+func (t *DictionaryTrainer) Stop() {
+	if t.ticker != nil {
+		t.ticker.Stop()
+	}
+	t.monitor.stop()
+	close(t.stopChan)
+}
+
+func (t *DictionaryTrainer) loop() {
+	for {
+		select {
+		case <-t.ticker.C:
+			t.engine.safely("dictionary trainer", t.train)
+			t.monitor.tick()
+		case <-t.stopChan:
+			return
+		}
+	}
+}
+
+func (t *DictionaryTrainer) train() {
+	e := t.engine
+	for _, topic := range e.topicStore.ListTopics() {
+		if !e.dictionaryEnabled(topic) {
+			continue
+		}
+		latest, err := e.topicStore.LatestOffset(topic)
+		if err != nil {
+			continue
+		}
+		e.dictionaries.mu.RLock()
+		trained, ok := e.dictionaries.trained[topic]
+		e.dictionaries.mu.RUnlock()
+		if !ok {
+			trained = -1
+		}
+		if latest-trained < int64(t.config.MinSamples) {
+			continue
+		}
+		if _, err := e.TrainDictionary(e.ctx, topic); err != nil && e.ctx.Err() == nil {
+			log.Printf("[engine] dictionary training for %s failed: %v", topic, err)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package engine
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// ErrReadOnly is returned for produces while the data directory is too
+// low on disk space to accept writes
+var ErrReadOnly = errors.New("storage is read-only: data directory is low on disk space")
+
+// Disk watchdog levels
+const (
+	DiskOK       = "ok"
+	DiskLow      = "low"      // retention is forced on every check
+	DiskCritical = "critical" // produces are rejected
+)
+
+// fullRecheckInterval is how often free space is checked after a write
+// hit SQLITE_FULL while the watchdog is off, to notice space was freed
+const fullRecheckInterval = 10 * time.Second
+
+// readOnlyHysteresis is how far free space must recover above the
+// read-only threshold before produces are accepted again
+const readOnlyHysteresis = 1.0
+
+// DiskStatus is the watchdog's latest view of the data directory
+type DiskStatus struct {
+	Path        string    `json:"path"`
+	FreeBytes   uint64    `json:"free_bytes"`
+	TotalBytes  uint64    `json:"total_bytes"`
+	FreePercent float64   `json:"free_percent"`
+	Level       string    `json:"level"`
+	ReadOnly    bool      `json:"read_only"`
+	CheckedAt   time.Time `json:"checked_at"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// DiskWatchdog checks free space in the data directory on a timer. Below
+// the retention threshold it forces retention; below the read-only
+// threshold it rejects produces until space recovers. A write that fails
+// with SQLITE_FULL switches to read-only immediately, until a check finds
+// space again; with the watchdog off, checks then run until it does.
+type DiskWatchdog struct {
+	engine   *Engine
+	config   config.DiskWatchdogConfig
+	path     string
+	ticker   *time.Ticker
+	stopChan chan struct{}
+	monitor  loopMonitor
+	readOnly int32 // atomic
+
+	mu     sync.Mutex
+	status DiskStatus
+}
+
+// NewDiskWatchdog creates a new DiskWatchdog
+func NewDiskWatchdog(engine *Engine, cfg config.DiskWatchdogConfig, path string) *DiskWatchdog {
+	return &DiskWatchdog{
+		engine:   engine,
+		config:   cfg,
+		path:     path,
+		stopChan: make(chan struct{}),
+		status:   DiskStatus{Path: path, Level: DiskOK},
+	}
+}
+
+// Start starts the watchdog. In-memory backends have nothing to watch.
+func (w *DiskWatchdog) Start() {
+	if !w.enabled() {
+		return
+	}
+	w.check()
+	w.ticker = time.NewTicker(w.config.Interval)
+	w.monitor.start(w.config.Interval)
+	go w.loop()
+}
+
+// Stop stops the watchdog
+func (w *DiskWatchdog) Stop() {
+	if w.ticker != nil {
+		w.ticker.Stop()
+	}
+	w.monitor.stop()
+	select {
+	case <-w.stopChan:
+	default:
+		close(w.stopChan)
+	}
+}
+
+func (w *DiskWatchdog) enabled() bool {
+	return w.config.Interval > 0 && !store.IsInMemory(w.engine.config.Storage.Backend)
+}
+
+func (w *DiskWatchdog) loop() {
+	for {
+		select {
+		case <-w.ticker.C:
+			w.engine.safely("disk watchdog", w.check)
+			w.monitor.tick()
+		case <-w.stopChan:
+			return
+		}
+	}
+}
+
+// ReadOnly reports whether produces are currently rejected
+func (w *DiskWatchdog) ReadOnly() bool {
+	return atomic.LoadInt32(&w.readOnly) == 1
+}
+
+// Status returns the latest disk status
+func (w *DiskWatchdog) Status() DiskStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	status := w.status
+	status.ReadOnly = w.ReadOnly()
+	return status
+}
+
+// tripFull switches to read-only after a write hit SQLITE_FULL. The next
+// check that finds enough free space switches back.
+func (w *DiskWatchdog) tripFull(err error) {
+	if atomic.CompareAndSwapInt32(&w.readOnly, 0, 1) {
+		log.Printf("[engine] disk full (%v): rejecting produces until space is freed", err)
+		w.mu.Lock()
+		w.status.Level = DiskCritical
+		w.mu.Unlock()
+		if !w.enabled() {
+			go w.recheck()
+		}
+	}
+}
+
+// recheck checks free space until produces are accepted again, standing
+// in for the watchdog's own checks while it is off
+func (w *DiskWatchdog) recheck() {
+	ticker := time.NewTicker(fullRecheckInterval)
+	defer ticker.Stop()
+	for w.ReadOnly() {
+		select {
+		case <-ticker.C:
+			w.engine.safely("disk recheck", w.check)
+		case <-w.stopChan:
+			return
+		}
+	}
+}
+
+func (w *DiskWatchdog) check() {
+	free, total, err := diskSpace(w.path)
+
+	w.mu.Lock()
+	w.status.CheckedAt = time.Now()
+	if err != nil {
+		w.status.Error = err.Error()
+		w.mu.Unlock()
+		return
+	}
+	w.status.Error = ""
+	w.status.FreeBytes, w.status.TotalBytes = free, total
+	w.status.FreePercent = 100
+	if total > 0 {
+		w.status.FreePercent = float64(free) / float64(total) * 100
+	}
+	freePct := w.status.FreePercent
+
+	readOnly := w.ReadOnly()
+	switch {
+	case freePct < w.config.ReadOnlyFreePercent:
+		readOnly = true
+	case readOnly && freePct >= w.config.ReadOnlyFreePercent+readOnlyHysteresis:
+		readOnly = false
+	}
+	level := DiskOK
+	switch {
+	case readOnly:
+		level = DiskCritical
+	case freePct < w.config.RetentionFreePercent:
+		level = DiskLow
+	}
+	prev := w.status.Level
+	w.status.Level = level
+	w.mu.Unlock()
+
+	if readOnly != w.ReadOnly() {
+		if readOnly {
+			atomic.StoreInt32(&w.readOnly, 1)
+			log.Printf("[engine] disk space critical (%.1f%% free in %s): rejecting produces", freePct, w.path)
+		} else {
+			atomic.StoreInt32(&w.readOnly, 0)
+			log.Printf("[engine] disk space recovered (%.1f%% free in %s): accepting produces", freePct, w.path)
+		}
+	}
+	if level != DiskOK {
+		if prev == DiskOK {
+			log.Printf("[engine] forcing retention: %.1f%% free in %s", freePct, w.path)
+		}
+		w.engine.retentionSched.cleanup()
+	}
+}
+
+// DataDirSpace returns the bytes available and the total size of the
+// filesystem holding the data directory
+func (e *Engine) DataDirSpace() (free, total uint64, err error) {
+	return diskSpace(e.config.Storage.DataDir)
+}
+
+// diskSpace returns the bytes available to us and the total size of the
+// filesystem holding path
+func diskSpace(path string) (free, total uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	return st.Bavail * uint64(st.Bsize), st.Blocks * uint64(st.Bsize), nil
+}
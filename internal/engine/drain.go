@@ -0,0 +1,154 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// ============================================================================
+// Drain
+//
+// Drain hands a topic's messages to a Go callback, for programs that embed
+// the engine and for test helpers that assert on what was produced without
+// a Kafka client or a socket in between.
+// ============================================================================
+
+// ErrStopDrain is returned by a DrainHandler to end a drain without error
+var ErrStopDrain = errors.New("stop drain")
+
+// drainPageSize is how many stored records Drain reads at a time
+const drainPageSize = 100
+
+// Message is a single message, decoded from whatever batch it was stored in
+type Message struct {
+	Topic     string
+	Offset    int64
+	Timestamp time.Time
+	Key       []byte
+	Value     []byte
+	Headers   []kafkaproto.RecordHeader // in the order produced; a key may repeat
+}
+
+// DrainHandler receives drained messages. Returning an error stops the
+// drain; ErrStopDrain stops it cleanly.
+type DrainHandler func(msg Message) error
+
+// Drain calls handler with every message of topic from offset from on, in
+// offset order, and keeps following the topic as messages arrive until ctx
+// is done or handler stops it. Nothing further is read until handler
+// returns, so a slow handler holds the drain back instead of messages
+// piling up in memory. It returns nil when stopped with ErrStopDrain, the
+// handler's error otherwise, or ctx's error.
+func (e *Engine) Drain(ctx context.Context, topic string, from int64, handler DrainHandler) error {
+	if from < 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidOffset, from)
+	}
+	ticker := time.NewTicker(e.config.Scheduler.TickInterval)
+	defer ticker.Stop()
+
+	offset := from
+	for {
+		records, err := e.Fetch(ctx, topic, offset, drainPageSize)
+		if err != nil {
+			return err
+		}
+		for _, rec := range records {
+			for _, msg := range storedMessages(topic, rec) {
+				if msg.Offset < offset {
+					continue
+				}
+				if err := handler(msg); err != nil {
+					if errors.Is(err, ErrStopDrain) {
+						return nil
+					}
+					return err
+				}
+				offset = msg.Offset + 1
+			}
+			// A batch may end in offsets taken by transaction markers
+			offset = max(offset, rec.LastOffset+1)
+		}
+		if len(records) > 0 {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// storedMessages decodes the messages in a stored record: a raw record
+// batch holds many, a record produced over HTTP just itself
+func storedMessages(topic string, rec store.Record) []Message {
+	if len(rec.Value) >= kafkaproto.RecordBatchHeaderSize && rec.Value[16] == 2 {
+		if batches, err := kafkaproto.SplitRecordBatches(rec.Value); err == nil {
+			// Stored batches keep the producer's base offset; rebase onto ours
+			base := batches[0].BaseOffset
+			var msgs []Message
+			for _, b := range batches {
+				if b.Attributes&kafkaproto.BatchAttrControl != 0 {
+					continue
+				}
+				records, err := b.DecodeRecords()
+				if err != nil {
+					continue
+				}
+				for _, r := range records {
+					msgs = append(msgs, Message{
+						Topic:     topic,
+						Offset:    rec.Offset + (r.Offset - base),
+						Timestamp: time.UnixMilli(r.Timestamp),
+						Key:       r.Key,
+						Value:     r.Value,
+						Headers:   r.Headers,
+					})
+				}
+			}
+			return msgs
+		}
+	}
+	return []Message{{
+		Topic:     topic,
+		Offset:    rec.Offset,
+		Timestamp: time.UnixMilli(rec.Timestamp),
+		Key:       rec.Key,
+		Value:     rec.Value,
+		Headers:   recordHeaders(rec.Headers),
+	}}
+}
+
+// recordHeaders lists a stored record's headers. Records produced over
+// HTTP keep them by key, so they come out in key order.
+func recordHeaders(headers map[string][]byte) []kafkaproto.RecordHeader {
+	if len(headers) == 0 {
+		return nil
+	}
+	list := make([]kafkaproto.RecordHeader, 0, len(headers))
+	for _, k := range slices.Sorted(maps.Keys(headers)) {
+		list = append(list, kafkaproto.RecordHeader{Key: k, Value: headers[k]})
+	}
+	return list
+}
+
+// headerMap keys headers by name for storing, the last of a repeated key
+// winning
+func headerMap(headers []kafkaproto.RecordHeader) map[string][]byte {
+	if len(headers) == 0 {
+		return nil
+	}
+	m := make(map[string][]byte, len(headers))
+	for _, h := range headers {
+		m[h.Key] = h.Value
+	}
+	return m
+}
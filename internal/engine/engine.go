@@ -1,12 +1,18 @@
 package engine
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"math"
 	"sync"
+	"time"
 
+	"github.com/rizkyandriawan/monolog/internal/capture"
 	"github.com/rizkyandriawan/monolog/internal/config"
-	"github.com/rizkyandriawan/monolog/internal/store"
+	sqlitestore "github.com/rizkyandriawan/monolog/internal/store"
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+	"github.com/rizkyandriawan/monolog/pkg/store"
 )
 
 // Engine is the core business logic layer
@@ -15,10 +21,46 @@ type Engine struct {
 	topicStore   store.TopicStoreInterface
 	groupStore   store.GroupStoreInterface
 	pending      *PendingQueue
+	batcher      *ProduceBatcher
+	txns         *TxnIndex
+	coordinator  *GroupCoordinator
+	events       *EventBus
 	fetchSched   *FetchScheduler
 	retentionSched *RetentionScheduler
+	memberSched  *MemberExpirationScheduler
+	refreshSched *RefreshScheduler
+	heartbeats   *HeartbeatFlusher
+	alerts       *AlertManager
+	metrics      *MetricsRecorder
+	disk         *DiskWatchdog
+	produceLatency LatencyTracker
+	fetchLatency   LatencyTracker
+	commits        *CommitTracker
+	clock          *Clock
+	faults         *Faults
+	waitHooks      *WaitHooks
+	producers      *Producers
+	txnCoord       *TxnCoordinator
+	dictionaries   *Dictionaries
+	dictTrainer    *DictionaryTrainer
+	authorizer     *Authorizer
+	keys           *KeyIndex
+	views          *Views
+	asyncProduces  *AsyncProduces
+	imports        *Imports
+	follower       *Follower
+	scrubber       *Scrubber
+	errorCount     int64 // atomic
+	errorKinds     sync.Map // kind -> *int64
+	panicCount     int64 // atomic
+	topicConfigMu sync.Mutex // serializes topic config changes, each a read then a write
+	captureMu    sync.Mutex
+	capture      *capture.Recorder
+	ctx          context.Context // background work; canceled by Stop
+	cancel       context.CancelFunc
 	stopChan     chan struct{}
 	wg           sync.WaitGroup
+	started      bool // Start ran, so Stop has background tasks to end
 }
 
 // New creates a new Engine
@@ -28,48 +70,171 @@ func New(cfg *config.Config, topicStore store.TopicStoreInterface, groupStore st
 		topicStore: topicStore,
 		groupStore: groupStore,
 		pending:    NewPendingQueue(),
+		batcher:    NewProduceBatcher(topicStore, cfg.Produce.Linger, cfg.Produce.MaxBatchRecords),
+		txns:       NewTxnIndex(topicStore),
+		events:     NewEventBus(),
+		commits:    NewCommitTracker(),
+		clock:      NewClock(cfg.Clock.Skew, cfg.Clock.Drift),
+		dictionaries: NewDictionaries(),
+		faults:     NewFaults(),
 		stopChan:   make(chan struct{}),
 	}
+	if d := cfg.Deterministic; d.Enabled {
+		e.clock.Deterministic(d.Start, d.Step)
+		e.producers = NewProducers(d.Start)
+	} else {
+		e.producers = NewProducers(time.Now())
+	}
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+	if c, ok := topicStore.(store.Clocked); ok {
+		c.SetClock(e.clock.Now)
+	}
 	e.fetchSched = NewFetchScheduler(e, cfg.Scheduler.TickInterval)
+	if h, ok := topicStore.(store.CommitHooked); ok {
+		h.SetCommitHooks(
+			func(topic string) { e.faults.hit(CrashBeforeCommit, topic) },
+			func(topic string) {
+				e.faults.hit(CrashAfterCommit, topic)
+				e.fetchSched.Appended(topic)
+			},
+		)
+	}
+	e.heartbeats = NewHeartbeatFlusher(e, cfg.Groups.HeartbeatFlushInterval)
+	e.coordinator = NewGroupCoordinator(groupStore, cfg.Groups, e.events)
+	if e.heartbeats.enabled() {
+		e.coordinator.heartbeats = e.heartbeats
+	}
 	e.retentionSched = NewRetentionScheduler(e, cfg.Retention)
+	e.memberSched = NewMemberExpirationScheduler(e, cfg.Groups.MinSessionTimeout)
+	e.refreshSched = NewRefreshScheduler(e, cfg.Storage.RefreshInterval)
+	e.dictTrainer = NewDictionaryTrainer(e, cfg.Compression.Dictionaries)
+	e.loadDictionaries(e.ctx)
+	e.alerts = NewAlertManager(e, cfg.Alerts)
+	e.metrics = NewMetricsRecorder(e, cfg.Metrics)
+	e.waitHooks = NewWaitHooks(e)
+	e.txnCoord = NewTxnCoordinator(e)
+	e.txnCoord.load(e.ctx)
+	e.disk = NewDiskWatchdog(e, cfg.Storage.Watchdog, cfg.Storage.DataDir)
+	e.keys = NewKeyIndex(e)
+	e.views = NewViews(e)
+	e.asyncProduces = NewAsyncProduces(e)
+	e.imports = NewImports(e)
+	e.follower = NewFollower(e, cfg.Follower)
+	e.scrubber = NewScrubber(e, cfg.Storage.Scrub)
+	e.authorizer = NewAuthorizer(e, cfg.Security.ACLs)
+	e.authorizer.load(e.ctx)
 	return e
 }
 
 // Start starts the engine's background tasks
 func (e *Engine) Start() {
+	e.started = true
 	e.fetchSched.Start()
 	if e.config.Retention.Enabled {
 		e.retentionSched.Start()
 	}
+	e.memberSched.Start()
+	e.refreshSched.Start()
+	e.heartbeats.Start()
+	e.dictTrainer.Start()
+	e.alerts.Start()
+	e.metrics.Start()
+	e.disk.Start()
+	e.txnCoord.Start()
+	e.views.Start()
+	e.follower.Start()
+	e.scrubber.Start()
 }
 
-// Stop stops the engine
+// Stop stops the engine. An engine that was never started, such as one
+// the benchmarks drive directly, has no background tasks to end.
 func (e *Engine) Stop() {
 	close(e.stopChan)
-	e.fetchSched.Stop()
-	e.retentionSched.Stop()
-	e.wg.Wait()
+	e.cancel()
+	if e.started {
+		e.fetchSched.Stop()
+		e.retentionSched.Stop()
+		e.memberSched.Stop()
+		e.refreshSched.Stop()
+		e.heartbeats.Stop()
+		e.dictTrainer.Stop()
+		e.alerts.Stop()
+		e.metrics.Stop()
+		e.disk.Stop()
+		e.txnCoord.Stop()
+		e.views.Stop()
+		e.follower.Stop()
+		e.scrubber.Stop()
+		e.asyncProduces.Stop()
+		e.wg.Wait()
+	}
+	if e.CaptureStatus() != nil {
+		e.StopCapture()
+	}
 }
 
 // --- Topic Operations ---
 
-// CreateTopic creates a new topic
-func (e *Engine) CreateTopic(name string) error {
+// CreateTopic creates a new topic with the default settings
+func (e *Engine) CreateTopic(ctx context.Context, name string) error {
+	return e.CreateTopicWithConfig(ctx, name, nil)
+}
+
+// CreateTopicWithConfig creates a new topic with per-topic configs, which
+// may select a preset with monolog.preset
+func (e *Engine) CreateTopicWithConfig(ctx context.Context, name string, configs map[string]string) error {
 	if e.topicStore.TopicExists(name) {
-		return fmt.Errorf("topic already exists: %s", name)
+		return fmt.Errorf("%w: %s", store.ErrTopicExists, name)
+	}
+	resolved, err := e.ResolveTopicConfig(configs)
+	if err != nil {
+		return err
 	}
-	return e.topicStore.CreateTopic(name)
+	return e.createTopic(ctx, name, resolved)
 }
 
 // EnsureTopic ensures a topic exists, creating it if auto-create is enabled
-func (e *Engine) EnsureTopic(name string) error {
+func (e *Engine) EnsureTopic(ctx context.Context, name string) error {
 	if e.topicStore.TopicExists(name) {
 		return nil
 	}
 	if !e.config.Topics.AutoCreate {
-		return fmt.Errorf("topic not found: %s", name)
+		return fmt.Errorf("%w: %s", store.ErrTopicNotFound, name)
 	}
-	return e.topicStore.CreateTopic(name)
+	return e.createTopic(ctx, name, nil)
+}
+
+// maxTopicNameLen is the longest topic name Kafka accepts
+const maxTopicNameLen = 249
+
+// validateTopicName checks name against Kafka's rules for topic names
+func validateTopicName(name string) error {
+	if name == "" || name == "." || name == ".." {
+		return fmt.Errorf("%w: %q", ErrInvalidTopic, name)
+	}
+	if len(name) > maxTopicNameLen {
+		return fmt.Errorf("%w: longer than %d characters", ErrInvalidTopic, maxTopicNameLen)
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-') {
+			return fmt.Errorf("%w: %q contains %q", ErrInvalidTopic, name, c)
+		}
+	}
+	return nil
+}
+
+func (e *Engine) createTopic(ctx context.Context, name string, configs map[string]string) error {
+	if err := validateTopicName(name); err != nil {
+		return err
+	}
+	if max := e.config.Limits.MaxTopics; max > 0 && len(e.topicStore.ListTopics()) >= max {
+		return fmt.Errorf("%w: topic limit of %d reached", ErrPolicyViolation, max)
+	}
+	if err := e.topicStore.CreateTopic(ctx, name, configs); err != nil {
+		return err
+	}
+	e.events.Publish(Event{Type: EventTopicCreated, Topic: name})
+	return nil
 }
 
 // ListTopics returns all topic names
@@ -77,9 +242,71 @@ func (e *Engine) ListTopics() []string {
 	return e.topicStore.ListTopics()
 }
 
+// TopicsVersion returns a counter that changes whenever the topic list or
+// any topic's latest offset changes
+func (e *Engine) TopicsVersion() uint64 {
+	return e.topicStore.Version()
+}
+
 // DeleteTopic deletes a topic
-func (e *Engine) DeleteTopic(name string) error {
-	return e.topicStore.DeleteTopic(name)
+func (e *Engine) DeleteTopic(ctx context.Context, name string) error {
+	if err := e.topicStore.DeleteTopic(ctx, name); err != nil {
+		return err
+	}
+	e.dictionaries.forget(name)
+	e.keys.forget(name)
+	if n := e.GetPendingQueue().EvictTopic(name); n > 0 {
+		log.Printf("[engine] woke %d fetches parked on deleted topic %s", n, name)
+	}
+	e.events.Publish(Event{Type: EventTopicDeleted, Topic: name})
+	return nil
+}
+
+// PurgeTopic deletes a topic's messages older than before, or all of them
+// when before is the zero time, returning the number of stored rows removed
+func (e *Engine) PurgeTopic(ctx context.Context, name string, before time.Time) (int, error) {
+	if before.IsZero() {
+		before = time.UnixMilli(math.MaxInt64)
+	}
+	return e.topicStore.DeleteBefore(ctx, name, before)
+}
+
+// DeleteRecords deletes a topic's messages before offset, as Kafka's
+// DeleteRecords does, and returns the new log start offset. Offset -1
+// means the high watermark, emptying the topic.
+func (e *Engine) DeleteRecords(ctx context.Context, topic string, offset int64) (int64, error) {
+	latest, err := e.topicStore.LatestOffset(topic)
+	if err != nil {
+		return 0, err
+	}
+	if offset == -1 {
+		offset = latest + 1
+	}
+	if offset < 0 || offset > latest+1 {
+		return 0, fmt.Errorf("%w: %d is beyond the high watermark %d", ErrInvalidOffset, offset, latest+1)
+	}
+	deleted, err := e.topicStore.DeleteBeforeOffset(ctx, topic, offset)
+	if err != nil {
+		return 0, err
+	}
+	if deleted > 0 {
+		log.Printf("[engine] deleted %d messages from %s before offset %d", deleted, topic, offset)
+	}
+	return e.topicStore.EarliestOffset(ctx, topic)
+}
+
+// AddPartitions grows a topic to count partitions, as Kafka's
+// CreatePartitions does. Topics have a single partition and storage has no
+// place for more, so every count is rejected with ErrInvalidPartitions,
+// saying why.
+func (e *Engine) AddPartitions(topic string, count int32) error {
+	if _, err := e.topicStore.GetMeta(topic); err != nil {
+		return err
+	}
+	if count <= 1 {
+		return fmt.Errorf("%w: topic %s already has 1 partition, requested %d", ErrInvalidPartitions, topic, count)
+	}
+	return fmt.Errorf("%w: topics have a single partition, so %s cannot grow to %d", ErrInvalidPartitions, topic, count)
 }
 
 // GetTopicMeta returns topic metadata
@@ -92,32 +319,301 @@ func (e *Engine) TopicExists(name string) bool {
 	return e.topicStore.TopicExists(name)
 }
 
+// TopicByID returns the name of the topic with a topic ID, or "" if no
+// topic has it
+func (e *Engine) TopicByID(id string) string {
+	for _, name := range e.topicStore.ListTopics() {
+		if meta, err := e.topicStore.GetMeta(name); err == nil && meta != nil && meta.ID == id {
+			return name
+		}
+	}
+	return ""
+}
+
 // --- Message Operations ---
 
 // Produce appends records to a topic
-func (e *Engine) Produce(topic string, records []store.Record) (int64, error) {
+func (e *Engine) Produce(ctx context.Context, topic string, records []store.Record) (int64, error) {
+	if err := e.checkWritable(topic); err != nil {
+		return 0, err
+	}
 	// Ensure topic exists
-	if err := e.EnsureTopic(topic); err != nil {
+	if err := e.EnsureTopic(ctx, topic); err != nil {
 		return 0, err
 	}
-	return e.topicStore.Append(topic, records)
+	if err := e.checkRecordsSize(topic, records); err != nil {
+		return 0, err
+	}
+	records = e.stampRecords(topic, records)
+	if batch, err := e.dictionaryBatch(topic, records); err != nil || batch != nil {
+		if err != nil {
+			return 0, err
+		}
+		return e.ProduceRaw(ctx, topic, batch, kafkaproto.CompressionZstd, len(records))
+	}
+	start := time.Now()
+	offset, err := e.topicStore.Append(ctx, topic, records)
+	e.produceDone(start, err)
+	if err == nil {
+		e.captureRecords(topic, records)
+	}
+	return offset, err
+}
+
+// ProduceBatched appends records through the produce batcher, sharing a
+// store append with concurrent callers. The returned offset is that of the
+// caller's first record.
+func (e *Engine) ProduceBatched(ctx context.Context, topic string, records []store.Record) (int64, error) {
+	if err := e.checkWritable(topic); err != nil {
+		return 0, err
+	}
+	if err := e.EnsureTopic(ctx, topic); err != nil {
+		return 0, err
+	}
+	if err := e.checkRecordsSize(topic, records); err != nil {
+		return 0, err
+	}
+	records = e.stampRecords(topic, records)
+	if batch, err := e.dictionaryBatch(topic, records); err != nil || batch != nil {
+		if err != nil {
+			return 0, err
+		}
+		return e.ProduceRaw(ctx, topic, batch, kafkaproto.CompressionZstd, len(records))
+	}
+	start := time.Now()
+	offset, err := e.batcher.Submit(ctx, topic, records)
+	e.produceDone(start, err)
+	if err == nil {
+		e.captureRecords(topic, records)
+	}
+	return offset, err
+}
+
+// ProduceAtomic appends records to several topics so that either all of
+// them are stored or none are, for side effects such as copying a message
+// to another topic that must not half-apply. It returns the base offset of
+// each append. Records bound for dictionary-compressed topics are stored
+// uncompressed.
+func (e *Engine) ProduceAtomic(ctx context.Context, appends []store.TopicAppend) ([]int64, error) {
+	for _, a := range appends {
+		if err := e.checkWritable(a.Topic); err != nil {
+			return nil, err
+		}
+	}
+	appender, ok := e.topicStore.(store.AtomicAppender)
+	if !ok {
+		return nil, ErrAtomicUnsupported
+	}
+	stamped := make([]store.TopicAppend, len(appends))
+	for i, a := range appends {
+		if err := e.EnsureTopic(ctx, a.Topic); err != nil {
+			return nil, err
+		}
+		if err := e.checkRecordsSize(a.Topic, a.Records); err != nil {
+			return nil, err
+		}
+		stamped[i] = store.TopicAppend{Topic: a.Topic, Records: e.stampRecords(a.Topic, a.Records)}
+	}
+	start := time.Now()
+	offsets, err := appender.AppendAtomic(ctx, stamped)
+	e.produceDone(start, err)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range stamped {
+		e.captureRecords(a.Topic, a.Records)
+	}
+	return offsets, nil
 }
 
 // ProduceRaw appends raw record batch data (passthrough for compression)
-func (e *Engine) ProduceRaw(topic string, data []byte, codec int8, recordCount int) (int64, error) {
+func (e *Engine) ProduceRaw(ctx context.Context, topic string, data []byte, codec int8, recordCount int) (int64, error) {
+	offset, _, err := e.ProduceRawStamped(ctx, topic, data, codec, recordCount)
+	return offset, err
+}
+
+// ProduceRawStamped is ProduceRaw that also returns the log append time
+// the batches were stamped with, or -1 unless the topic's
+// message.timestamp.type is LogAppendTime
+func (e *Engine) ProduceRawStamped(ctx context.Context, topic string, data []byte, codec int8, recordCount int) (int64, int64, error) {
+	return e.produceRaw(ctx, topic, data, []store.RawBatch{{Data: data, Codec: codec, RecordCount: recordCount}})
+}
+
+// ProduceBatches appends the record batches of one partition of a produce
+// request, each at one offset per record, atomically: a failure stores
+// none of them, so the producer's retry cannot duplicate any. Data that
+// is not v2 record batches is stored whole at a single offset.
+func (e *Engine) ProduceBatches(ctx context.Context, topic string, data []byte, codec int8) (int64, int64, error) {
+	batches, err := kafkaproto.SplitRecordBatches(data)
+	if err != nil || len(batches) == 0 {
+		return e.ProduceRawStamped(ctx, topic, data, codec, 1)
+	}
+	raws := make([]store.RawBatch, len(batches))
+	for i, b := range batches {
+		raws[i] = store.RawBatch{Data: b.RawRecords, Codec: b.Codec, RecordCount: b.RecordCount()}
+	}
+	return e.produceRaw(ctx, topic, data, raws)
+}
+
+// produceRaw stores batches, the split of data, in one append. An
+// idempotent producer's sequence numbers are checked across all of data.
+func (e *Engine) produceRaw(ctx context.Context, topic string, data []byte, batches []store.RawBatch) (int64, int64, error) {
+	if err := e.checkWritable(topic); err != nil {
+		return 0, -1, err
+	}
 	// Ensure topic exists
-	if err := e.EnsureTopic(topic); err != nil {
-		return 0, err
+	if err := e.EnsureTopic(ctx, topic); err != nil {
+		return 0, -1, err
+	}
+	if max := e.maxMessageBytes(topic); max > 0 {
+		for _, b := range batches {
+			if len(b.Data) > max {
+				return 0, -1, fmt.Errorf("%w: batch of %d bytes exceeds max.message.bytes %d", ErrMessageTooLarge, len(b.Data), max)
+			}
+		}
+	}
+	var appender store.RawBatchAppender
+	if len(batches) > 1 {
+		var ok bool
+		if appender, ok = e.topicStore.(store.RawBatchAppender); !ok {
+			return 0, -1, fmt.Errorf("%w: storage backend takes one batch per partition", ErrCorruptBatch)
+		}
+	}
+	// Idempotent producers: a retried batch gets its original offset back
+	seq, idempotent := sequenceOf(data)
+	var producer *producerState
+	if idempotent {
+		producer = e.producers.state(topic, seq.producerID)
+		producer.mu.Lock()
+		defer producer.mu.Unlock()
+		offset, duplicate, err := producer.check(seq)
+		if err != nil {
+			return 0, -1, err
+		}
+		if duplicate {
+			log.Printf("[engine] duplicate batch from producer %d on %s (sequence %d), already at offset %d", seq.producerID, topic, seq.firstSeq, offset)
+			return offset, -1, nil
+		}
+		if seq.transactional {
+			if err := e.txnCoord.checkProduce(topic, seq.producerID, seq.epoch); err != nil {
+				return 0, -1, err
+			}
+		}
+	}
+	appendTime := int64(-1)
+	if e.logAppendTime(topic) {
+		appendTime = e.Now().UnixMilli()
+	}
+	for i := range batches {
+		b := &batches[i]
+		if appendTime >= 0 {
+			stamped, err := kafkaproto.SetLogAppendTime(b.Data, appendTime)
+			if err != nil {
+				return 0, -1, fmt.Errorf("stamp log append time: %w", err)
+			}
+			b.Data = stamped
+		}
+		b.MaxTimestamp = kafkaproto.MaxTimestamp(b.Data)
+	}
+
+	start := time.Now()
+	var offset int64
+	var err error
+	if appender != nil {
+		offset, err = appender.AppendRawBatches(ctx, topic, batches)
+	} else {
+		b := batches[0]
+		offset, err = e.topicStore.AppendRaw(ctx, topic, b.Data, b.Codec, b.RecordCount, b.MaxTimestamp)
 	}
-	return e.topicStore.AppendRaw(topic, data, codec, recordCount)
+	e.produceDone(start, err)
+	if err != nil {
+		return 0, appendTime, err
+	}
+	if idempotent {
+		producer.appended(seq, offset)
+		if seq.transactional {
+			e.txnCoord.produced(ctx, topic, seq.producerID, offset)
+		}
+	}
+	for _, b := range batches {
+		e.captureRaw(topic, b.Data, b.Codec, b.RecordCount)
+	}
+	return offset, appendTime, nil
 }
 
 // Fetch reads records from a topic
-func (e *Engine) Fetch(topic string, offset int64, maxRecords int) ([]store.Record, error) {
+func (e *Engine) Fetch(ctx context.Context, topic string, offset int64, maxRecords int) ([]store.Record, error) {
+	if !e.topicStore.TopicExists(topic) {
+		return nil, fmt.Errorf("%w: %s", store.ErrTopicNotFound, topic)
+	}
+	start := time.Now()
+	records, err := e.topicStore.Read(ctx, topic, offset, maxRecords)
+	e.fetchLatency.Since(start)
+	if err != nil {
+		e.CountError(ErrorKindFetch)
+	}
+	if len(records) > 0 {
+		e.faults.hit(CrashMidFetch, topic)
+	}
+	return records, err
+}
+
+// checkWritable refuses appends while the disk is nearly full, and to
+// topics this instance follows from a primary
+func (e *Engine) checkWritable(topic string) error {
+	if e.disk.ReadOnly() {
+		return ErrReadOnly
+	}
+	if e.follower.Replicates(topic) {
+		return fmt.Errorf("%w: %s", ErrReplicaTopic, topic)
+	}
+	return nil
+}
+
+// checkRecordsSize rejects a record over the topic's max.message.bytes
+func (e *Engine) checkRecordsSize(topic string, records []store.Record) error {
+	max := e.maxMessageBytes(topic)
+	if max <= 0 {
+		return nil
+	}
+	for _, r := range records {
+		if size := len(r.Key) + len(r.Value); size > max {
+			return fmt.Errorf("%w: record of %d bytes exceeds max.message.bytes %d", ErrMessageTooLarge, size, max)
+		}
+	}
+	return nil
+}
+
+// produceDone records a produce's latency and outcome
+func (e *Engine) produceDone(start time.Time, err error) {
+	e.produceLatency.Since(start)
+	if err != nil {
+		e.CountError(ErrorKindProduce)
+		if sqlitestore.IsStorageFull(err) {
+			e.disk.tripFull(err)
+		}
+	}
+}
+
+// ReadOnly reports whether produces are being rejected for lack of disk space
+func (e *Engine) ReadOnly() bool {
+	return e.disk.ReadOnly()
+}
+
+// DiskStatus returns the disk watchdog's view of the data directory; ok is
+// false when the watchdog is disabled or the backend keeps no data on disk
+func (e *Engine) DiskStatus() (status DiskStatus, ok bool) {
+	return e.disk.Status(), e.disk.enabled()
+}
+
+// ReadRange reads records appended between from (inclusive) and to
+// (exclusive), resuming at cursor. It returns the next cursor, or -1 when
+// the range is exhausted.
+func (e *Engine) ReadRange(ctx context.Context, topic string, from, to time.Time, cursor int64, maxRecords int) ([]store.Record, int64, error) {
 	if !e.topicStore.TopicExists(topic) {
-		return nil, fmt.Errorf("topic not found: %s", topic)
+		return nil, -1, fmt.Errorf("%w: %s", store.ErrTopicNotFound, topic)
 	}
-	return e.topicStore.Read(topic, offset, maxRecords)
+	return e.topicStore.ReadRange(ctx, topic, from.UnixMilli(), to.UnixMilli(), cursor, maxRecords)
 }
 
 // LatestOffset returns the latest offset for a topic
@@ -126,15 +622,51 @@ func (e *Engine) LatestOffset(topic string) (int64, error) {
 }
 
 // EarliestOffset returns the earliest offset for a topic
-func (e *Engine) EarliestOffset(topic string) (int64, error) {
-	return e.topicStore.EarliestOffset(topic)
+func (e *Engine) EarliestOffset(ctx context.Context, topic string) (int64, error) {
+	return e.topicStore.EarliestOffset(ctx, topic)
+}
+
+// TopicSize returns the bytes a topic's messages take in storage
+func (e *Engine) TopicSize(ctx context.Context, topic string) (int64, error) {
+	return e.topicStore.SizeBytes(ctx, topic)
 }
 
 // --- Pending Fetch Operations ---
 
 // ParkFetch parks a fetch request for later processing
 func (e *Engine) ParkFetch(req *PendingFetch) {
-	e.pending.Add(req)
+	e.GetPendingQueue().Add(req)
+}
+
+// fetchReady reports whether a parked fetch can read MinBytes (at least
+// one message) from its partitions. A partition that cannot be read at
+// all, say because its topic was deleted, wakes the fetch to report it.
+func (e *Engine) fetchReady(p *PendingFetch) (bool, error) {
+	var size int64
+	for _, part := range p.Partitions {
+		latest, err := e.topicStore.LatestOffset(part.Topic)
+		if err != nil {
+			return true, nil
+		}
+		if part.Offset > latest {
+			continue
+		}
+		limit := 100
+		if p.MinBytes <= 1 {
+			limit = 1
+		}
+		records, err := e.FetchIsolated(e.ctx, part.Topic, part.Offset, limit, p.Isolation)
+		if err != nil {
+			return false, err
+		}
+		for _, rec := range records {
+			size += int64(len(rec.Key) + len(rec.Value))
+		}
+		if len(records) > 0 && size >= int64(p.MinBytes) {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 // GetPendingQueue returns the pending queue
@@ -150,8 +682,8 @@ func (e *Engine) GetTopicStore() store.TopicStoreInterface {
 // --- Consumer Group Operations ---
 
 // GetOrCreateGroup gets or creates a consumer group
-func (e *Engine) GetOrCreateGroup(groupID string) (*store.Group, error) {
-	return e.groupStore.GetOrCreateGroup(groupID)
+func (e *Engine) GetOrCreateGroup(ctx context.Context, groupID string) (*store.Group, error) {
+	return e.groupStore.GetOrCreateGroup(ctx, groupID)
 }
 
 // GetGroup gets a consumer group
@@ -164,55 +696,89 @@ func (e *Engine) ListGroups() []string {
 	return e.groupStore.ListGroups()
 }
 
-// JoinGroup handles a consumer joining a group
-func (e *Engine) JoinGroup(groupID, memberID, clientID string, metadata []byte) (*store.Group, error) {
-	group, err := e.groupStore.GetOrCreateGroup(groupID)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := e.groupStore.AddMember(groupID, memberID, clientID, metadata); err != nil {
-		return nil, err
-	}
+// GroupsVersion returns a counter that changes whenever the group list or
+// any group's membership, state or generation changes
+func (e *Engine) GroupsVersion() uint64 {
+	return e.groupStore.Version()
+}
 
-	// Refresh group
-	group, _ = e.groupStore.GetGroup(groupID)
-	return group, nil
+// JoinGroup adds a member to a group and waits for the rebalance to complete
+func (e *Engine) JoinGroup(ctx context.Context, req JoinRequest) (*JoinResult, error) {
+	return e.coordinator.Join(ctx, req)
 }
 
-// SyncGroup handles group sync
-func (e *Engine) SyncGroup(groupID, memberID string, assignment []byte) error {
-	return e.groupStore.SetMemberAssignment(groupID, memberID, assignment)
+// SyncGroup distributes the leader's assignments and returns the member's own
+func (e *Engine) SyncGroup(ctx context.Context, groupID, memberID string, generation int32, assignments map[string][]byte) ([]byte, error) {
+	return e.coordinator.Sync(ctx, groupID, memberID, generation, assignments)
 }
 
 // Heartbeat updates member heartbeat
-func (e *Engine) Heartbeat(groupID, memberID string) error {
-	return e.groupStore.UpdateHeartbeat(groupID, memberID)
+func (e *Engine) Heartbeat(groupID, memberID string, generation int32) error {
+	return e.coordinator.Heartbeat(groupID, memberID, generation)
+}
+
+// ValidateCommit checks that a Kafka offset commit comes from a current
+// member of the group's current generation
+func (e *Engine) ValidateCommit(groupID, memberID string, generation int32) error {
+	return e.coordinator.ValidateCommit(groupID, memberID, generation)
+}
+
+// ExpireMembers removes members whose session timeout has elapsed since
+// their last heartbeat and returns them as "group/member"
+func (e *Engine) ExpireMembers() ([]string, error) {
+	return e.coordinator.Expire(), nil
 }
 
 // LeaveGroup handles a consumer leaving a group
 func (e *Engine) LeaveGroup(groupID, memberID string) error {
-	return e.groupStore.RemoveMember(groupID, memberID)
+	return e.coordinator.Leave(groupID, memberID)
 }
 
-// CommitOffset commits an offset
-func (e *Engine) CommitOffset(groupID, topic string, offset int64) error {
-	return e.groupStore.CommitOffset(groupID, topic, offset)
+// CommitOffset commits an offset for one partition of a topic
+func (e *Engine) CommitOffset(ctx context.Context, groupID, topic string, partition int32, offset int64) error {
+	if err := e.groupStore.CommitOffset(ctx, groupID, topic, partition, offset); err != nil {
+		return err
+	}
+	e.commits.Observe(groupID, topic, time.Now())
+	return nil
+}
+
+// GroupOffsets returns every offset a group has committed
+func (e *Engine) GroupOffsets(groupID string) (map[store.TopicPartition]int64, error) {
+	return e.groupStore.Offsets(groupID)
 }
 
-// FetchOffset fetches the committed offset
-func (e *Engine) FetchOffset(groupID, topic string) (int64, error) {
-	return e.groupStore.FetchOffset(groupID, topic)
+// FetchOffset fetches the committed offset of one partition of a topic
+func (e *Engine) FetchOffset(groupID, topic string, partition int32) (int64, error) {
+	return e.groupStore.FetchOffset(groupID, topic, partition)
 }
 
 // IncrementGeneration increments group generation
-func (e *Engine) IncrementGeneration(groupID string) (int32, error) {
-	return e.groupStore.IncrementGeneration(groupID)
+func (e *Engine) IncrementGeneration(ctx context.Context, groupID string) (int32, error) {
+	return e.groupStore.IncrementGeneration(ctx, groupID)
 }
 
 // DeleteGroup deletes a consumer group
-func (e *Engine) DeleteGroup(groupID string) error {
-	return e.groupStore.DeleteGroup(groupID)
+func (e *Engine) DeleteGroup(ctx context.Context, groupID string) error {
+	e.coordinator.Forget(groupID)
+	if err := e.groupStore.DeleteGroup(ctx, groupID); err != nil {
+		return err
+	}
+	e.commits.Forget(groupID)
+	e.events.Publish(Event{Type: EventGroupDeleted, Group: groupID})
+	return nil
+}
+
+// Watch subscribes to admin object events published after afterSeq (0 =
+// only new ones). The channel is closed if the watcher falls behind; the
+// returned function ends the subscription.
+func (e *Engine) Watch(afterSeq uint64) (<-chan Event, func()) {
+	return e.events.Subscribe(afterSeq, 64)
+}
+
+// NotifyConfigChanged tells watchers that a runtime setting changed
+func (e *Engine) NotifyConfigChanged(name string) {
+	e.events.Publish(Event{Type: EventConfigChanged, Config: name})
 }
 
 // GetConfig returns the config
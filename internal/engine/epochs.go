@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// LeaderEpoch returns the current leader epoch of a topic's partition
+func (e *Engine) LeaderEpoch(topic string) (int32, error) {
+	history, err := e.topicStore.LeaderEpochs(topic)
+	if err != nil {
+		return kafkaproto.UndefinedEpoch, err
+	}
+	if len(history) == 0 {
+		return 0, nil
+	}
+	return history[len(history)-1].Epoch, nil
+}
+
+// LeaderEpochs returns a topic's leader epoch history, oldest first
+func (e *Engine) LeaderEpochs(topic string) ([]store.LeaderEpoch, error) {
+	return e.topicStore.LeaderEpochs(topic)
+}
+
+// EpochEndOffset answers OffsetForLeaderEpoch the way a Kafka leader does:
+// it returns the largest epoch at or below the requested one together with
+// the offset that epoch ended at, which is the start of the next epoch or
+// the log end offset for the current epoch. An epoch newer than any we
+// know, or older than the whole history with nothing after it, is
+// undefined.
+func (e *Engine) EpochEndOffset(topic string, epoch int32) (int32, int64, error) {
+	history, err := e.topicStore.LeaderEpochs(topic)
+	if err != nil {
+		return kafkaproto.UndefinedEpoch, kafkaproto.UndefinedEpochOffset, err
+	}
+	latest, err := e.topicStore.LatestOffset(topic)
+	if err != nil {
+		return kafkaproto.UndefinedEpoch, kafkaproto.UndefinedEpochOffset, err
+	}
+
+	if epoch == kafkaproto.UndefinedEpoch || len(history) == 0 {
+		return kafkaproto.UndefinedEpoch, kafkaproto.UndefinedEpochOffset, nil
+	}
+	if current := history[len(history)-1]; epoch == current.Epoch {
+		return current.Epoch, latest + 1, nil
+	}
+
+	floor := -1
+	for i, h := range history {
+		if h.Epoch > epoch {
+			if floor < 0 {
+				return epoch, h.StartOffset, nil
+			}
+			return history[floor].Epoch, h.StartOffset, nil
+		}
+		floor = i
+	}
+	return kafkaproto.UndefinedEpoch, kafkaproto.UndefinedEpochOffset, nil
+}
+
+// EpochAt returns the leader epoch an offset was written under, given a
+// topic's epoch history
+func EpochAt(history []store.LeaderEpoch, offset int64) int32 {
+	epoch := int32(0)
+	for _, h := range history {
+		if h.StartOffset > offset {
+			break
+		}
+		epoch = h.Epoch
+	}
+	return epoch
+}
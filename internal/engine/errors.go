@@ -0,0 +1,129 @@
+package engine
+
+import "errors"
+
+// Produce and topic errors the Kafka and HTTP layers map to their own codes
+var (
+	// ErrMessageTooLarge rejects a produce over limits.max_message_size
+	ErrMessageTooLarge = errors.New("message too large")
+
+	// ErrPolicyViolation rejects a request the broker's configuration
+	// forbids, such as creating a topic past limits.max_topics
+	ErrPolicyViolation = errors.New("policy violation")
+
+	// ErrInvalidConfig rejects a topic config with an unknown preset or a
+	// value out of range
+	ErrInvalidConfig = errors.New("invalid topic config")
+
+	// ErrInvalidOffset rejects a negative offset in an offset import, or one
+	// past the high watermark in a records deletion
+	ErrInvalidOffset = errors.New("invalid offset")
+
+	// ErrInvalidPartitions rejects a partition count a topic cannot be
+	// given; topics have a single partition
+	ErrInvalidPartitions = errors.New("invalid partition count")
+
+	// ErrInvalidTopic rejects creating a topic whose name Kafka would not
+	// accept: empty, "." or "..", longer than 249 characters, or with a
+	// character besides ASCII letters, digits, '.', '_' and '-'. Keeping
+	// out ':' keeps a topic name apart from the partition in "topic:N".
+	ErrInvalidTopic = errors.New("invalid topic name")
+
+	// ErrOutOfOrderSequence rejects an idempotent producer's batch that
+	// does not follow the last one it appended
+	ErrOutOfOrderSequence = errors.New("out of order sequence number")
+
+	// ErrInvalidProducerEpoch rejects a batch, or an epoch bump, from a
+	// producer fenced by a newer epoch
+	ErrInvalidProducerEpoch = errors.New("invalid producer epoch")
+
+	// ErrInvalidTxnState rejects a transactional request that does not fit
+	// the transaction's state, such as ending one that was never begun
+	ErrInvalidTxnState = errors.New("invalid transaction state")
+
+	// ErrUnknownProducerID rejects a transactional request whose producer
+	// ID is not the one the transactional ID was given
+	ErrUnknownProducerID = errors.New("unknown producer ID for transactional ID")
+
+	// ErrUnsupportedCompression rejects a produced batch in a codec that
+	// is disabled, not linked in or not in compression.accept
+	ErrUnsupportedCompression = errors.New("unsupported compression type")
+
+	// ErrCorruptBatch rejects a produced batch that does not decode with
+	// the codec it declares, when compression.validate is on
+	ErrCorruptBatch = errors.New("corrupt record batch")
+
+	// ErrAtomicUnsupported rejects an atomic produce on a storage backend
+	// that cannot append to several topics in one transaction
+	ErrAtomicUnsupported = errors.New("storage backend does not support atomic multi-topic appends")
+
+	// ErrNotCompacted rejects browsing the keys of a topic whose
+	// cleanup.policy does not include compact
+	ErrNotCompacted = errors.New("topic is not compacted")
+
+	// ErrInvalidACL rejects an ACL binding to create that names an unknown
+	// or wildcard resource type, operation or permission
+	ErrInvalidACL = errors.New("invalid ACL binding")
+
+	// ErrInvalidCopy rejects a copy with no destination, or back into its
+	// source
+	ErrInvalidCopy = errors.New("invalid copy")
+
+	// ErrInvalidView rejects a view definition with a bad name, column or
+	// JSON path
+	ErrInvalidView = errors.New("invalid view")
+
+	// ErrViewExists rejects creating a view under a name already taken
+	ErrViewExists = errors.New("view already exists")
+
+	// ErrUnknownView is returned for a view that was never created
+	ErrUnknownView = errors.New("unknown view")
+
+	// ErrInvalidViewQuery rejects view SQL that does not parse, or that
+	// does anything besides reading
+	ErrInvalidViewQuery = errors.New("invalid view query")
+
+	// ErrProduceBacklog turns away an async produce while too many earlier
+	// ones are still waiting on their appends
+	ErrProduceBacklog = errors.New("too many async produces pending")
+
+	// ErrShuttingDown turns away work that arrives once the engine has
+	// started stopping
+	ErrShuttingDown = errors.New("engine is shutting down")
+
+	// ErrUnknownProduceToken is returned for an async produce token never
+	// issued, or whose outcome has expired
+	ErrUnknownProduceToken = errors.New("unknown produce token")
+
+	// ErrInvalidImport rejects an import chunk with a line that is not a
+	// JSON message
+	ErrInvalidImport = errors.New("invalid import")
+
+	// ErrUnknownImport is returned for an import session never started, or
+	// expired
+	ErrUnknownImport = errors.New("unknown import")
+
+	// ErrImportOffsetMismatch rejects an import chunk that does not start
+	// where the upload left off
+	ErrImportOffsetMismatch = errors.New("import offset mismatch")
+
+	// ErrImportChecksum rejects an import chunk, or completes no import,
+	// whose SHA-256 is not the one given
+	ErrImportChecksum = errors.New("import checksum mismatch")
+
+	// ErrImportConflict rejects a chunk for an import that is complete or
+	// busy with another chunk
+	ErrImportConflict = errors.New("import conflict")
+
+	// ErrWebhookNotAllowed rejects a wait hook whose webhook is not on a
+	// host listed in waits.webhooks
+	ErrWebhookNotAllowed = errors.New("webhook host not allowed")
+
+	// ErrTooManyWaitHooks rejects a wait hook while waits.max_hooks are
+	// already waiting
+	ErrTooManyWaitHooks = errors.New("too many wait hooks")
+
+	// ErrReplicaTopic rejects writes to a topic this instance follows
+	// from a primary
+	ErrReplicaTopic = errors.New("topic is a read-only replica")
+)
@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"sync"
+	"time"
+)
+
+// Watch event types
+const (
+	EventTopicCreated  = "topic.created"
+	EventTopicDeleted  = "topic.deleted"
+	EventGroupState    = "group.state"
+	EventGroupDeleted  = "group.deleted"
+	EventMemberJoined  = "member.joined"
+	EventMemberLeft    = "member.left"
+	EventConfigChanged = "config.changed"
+)
+
+// Reasons a member left its group
+const (
+	LeaveRequested = "leave"
+	LeaveSession   = "session_timeout"
+	LeaveRebalance = "rebalance_timeout"
+)
+
+// Event is a change to an admin object, delivered to watchers
+type Event struct {
+	Seq        uint64    `json:"seq"`
+	Type       string    `json:"type"`
+	Time       time.Time `json:"time"`
+	Topic      string    `json:"topic,omitempty"`
+	Group      string    `json:"group,omitempty"`
+	Member     string    `json:"member,omitempty"`
+	State      string    `json:"state,omitempty"`
+	Generation int32     `json:"generation,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	Config     string    `json:"config,omitempty"` // name of the changed setting
+}
+
+// eventHistory is how many recent events are kept for resuming watchers
+const eventHistory = 256
+
+// EventBus fans events out to watchers. Publishing never blocks: a watcher
+// that falls a full buffer behind is dropped (its channel closed) so it can
+// reconnect and resync instead of silently missing events.
+type EventBus struct {
+	mu      sync.Mutex
+	seq     uint64
+	history []Event
+	subs    map[chan Event]struct{}
+}
+
+// NewEventBus creates a new EventBus
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[chan Event]struct{})}
+}
+
+// Publish stamps ev with a sequence number and time and delivers it
+func (b *EventBus) Publish(ev Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	ev.Seq = b.seq
+	ev.Time = time.Now()
+
+	b.history = append(b.history, ev)
+	if len(b.history) > eventHistory {
+		b.history = b.history[len(b.history)-eventHistory:]
+	}
+
+	for ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns a channel of events published after afterSeq (0 = only
+// new events) and a function that cancels the subscription. Events older
+// than the retained history are not replayed.
+func (b *EventBus) Subscribe(afterSeq uint64, buffer int) (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var backlog []Event
+	if afterSeq > 0 {
+		for _, ev := range b.history {
+			if ev.Seq > afterSeq {
+				backlog = append(backlog, ev)
+			}
+		}
+	}
+
+	ch := make(chan Event, buffer+len(backlog))
+	for _, ev := range backlog {
+		ch <- ev
+	}
+	b.subs[ch] = struct{}{}
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
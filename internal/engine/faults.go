@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+// Crash points
+const (
+	CrashBeforeCommit = "append-pre-commit"  // an append is written but its transaction not committed
+	CrashAfterCommit  = "append-post-commit" // an append is committed but not acknowledged
+	CrashMidFetch     = "fetch"              // records are read but not yet sent
+)
+
+// Crash modes
+const (
+	CrashModeExit = "exit" // os.Exit, skipping graceful shutdown
+	CrashModeKill = "kill" // kill -9: the process gets no say at all
+)
+
+// CrashFault is a crash armed to go off at a point in the broker's work,
+// for testing how monolog and its clients recover from a broker dying there
+type CrashFault struct {
+	Point    string `json:"point"`
+	Mode     string `json:"mode"`
+	Topic    string `json:"topic,omitempty"`     // only appends or fetches on this topic
+	Skip     int    `json:"skip,omitempty"`      // let this many hits pass first
+	ExitCode int    `json:"exit_code,omitempty"` // for exit mode; 0 means 1
+}
+
+// Faults holds the armed crash fault, if any
+type Faults struct {
+	mu    sync.Mutex
+	armed *CrashFault
+	hits  int
+}
+
+// NewFaults creates a fault injector with nothing armed
+func NewFaults() *Faults {
+	return &Faults{}
+}
+
+// Arm arms a crash fault, replacing any armed before
+func (f *Faults) Arm(fault CrashFault) error {
+	switch fault.Point {
+	case CrashBeforeCommit, CrashAfterCommit, CrashMidFetch:
+	default:
+		return fmt.Errorf("crash point must be %s, %s or %s, got %q", CrashBeforeCommit, CrashAfterCommit, CrashMidFetch, fault.Point)
+	}
+	switch fault.Mode {
+	case "":
+		fault.Mode = CrashModeExit
+	case CrashModeExit, CrashModeKill:
+	default:
+		return fmt.Errorf("crash mode must be exit or kill, got %q", fault.Mode)
+	}
+	if fault.Skip < 0 {
+		return errors.New("skip must not be negative")
+	}
+	if fault.ExitCode == 0 {
+		fault.ExitCode = 1
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.armed, f.hits = &fault, 0
+	return nil
+}
+
+// Disarm disarms the armed crash fault
+func (f *Faults) Disarm() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.armed, f.hits = nil, 0
+}
+
+// Armed returns the armed crash fault, or nil
+func (f *Faults) Armed() *CrashFault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.armed == nil {
+		return nil
+	}
+	armed := *f.armed
+	return &armed
+}
+
+// hit crashes the process if a fault is armed at point for topic and has
+// skipped the hits it was told to
+func (f *Faults) hit(point, topic string) {
+	f.mu.Lock()
+	armed := f.armed
+	if armed == nil || armed.Point != point || (armed.Topic != "" && armed.Topic != topic) {
+		f.mu.Unlock()
+		return
+	}
+	f.hits++
+	if f.hits <= armed.Skip {
+		f.mu.Unlock()
+		return
+	}
+	fault := *armed
+	f.armed = nil
+	f.mu.Unlock()
+
+	log.Printf("[engine] crash fault: %s at %s on %s", fault.Mode, point, topic)
+	crashProcess(fault)
+}
+
+// crashProcess ends the process the way fault asks. The log line before it
+// may be the last thing written.
+func crashProcess(fault CrashFault) {
+	if fault.Mode == CrashModeKill {
+		if p, err := os.FindProcess(os.Getpid()); err == nil {
+			p.Kill()
+		}
+	}
+	os.Exit(fault.ExitCode)
+}
+
+// Faults returns the engine's crash fault injector
+func (e *Engine) Faults() *Faults {
+	return e.faults
+}
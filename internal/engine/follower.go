@@ -0,0 +1,330 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+	"github.com/rizkyandriawan/monolog/internal/redact"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// ============================================================================
+// Follower mode
+//
+// A follower is a read replica of topics on another monolog, the primary.
+// Every follower.interval it lists the primary's topics and pulls what each
+// has gained through the primary's export API, as the records are stored
+// there, batches and codecs included, keeping them at the same offsets.
+// Consumers fetch replicated topics here as they would from the primary,
+// so heavy readers can be pointed at the follower; produces to them are
+// refused with ErrReplicaTopic. A topic deleted on the primary is deleted
+// here, and one the primary recreated is copied again from its start.
+// ============================================================================
+
+// followerBatchRecords caps the records stored per replica append
+const followerBatchRecords = 500
+
+// ReplicaStatus is how far a follower has copied one topic
+type ReplicaStatus struct {
+	Topic         string    `json:"topic"`
+	PrimaryID     string    `json:"primary_id"`            // the topic's ID on the primary
+	LatestOffset  int64     `json:"latest_offset"`         // last offset copied
+	PrimaryLatest int64     `json:"primary_latest_offset"` // as of the last pull
+	Lag           int64     `json:"lag"`
+	Synced        time.Time `json:"synced,omitempty"` // when the topic last caught up
+	Error         string    `json:"error,omitempty"`
+}
+
+// FollowerStatus describes follower mode
+type FollowerStatus struct {
+	Primary string          `json:"primary"`
+	Error   string          `json:"error,omitempty"` // why the primary's topics could not be listed
+	Topics  []ReplicaStatus `json:"topics"`
+}
+
+// Follower replicates topics from a primary
+type Follower struct {
+	engine   *Engine
+	config   config.FollowerConfig
+	client   *http.Client
+	stopChan chan struct{}
+	done     chan struct{}
+
+	mu       sync.Mutex
+	replicas map[string]*ReplicaStatus
+	err      string
+}
+
+// primaryTopic is a topic as the primary lists it
+type primaryTopic struct {
+	Name         string `json:"name"`
+	ID           string `json:"id"`
+	LatestOffset int64  `json:"latest_offset"`
+}
+
+// NewFollower creates the follower. It does nothing unless
+// follower.primary is set.
+func NewFollower(engine *Engine, cfg config.FollowerConfig) *Follower {
+	redact.SetSecrets("follower", cfg.Token)
+	return &Follower{
+		engine:   engine,
+		config:   cfg,
+		client:   &http.Client{Timeout: 5 * time.Minute},
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+		replicas: make(map[string]*ReplicaStatus),
+	}
+}
+
+func (f *Follower) enabled() bool {
+	return f.config.Primary != ""
+}
+
+// Replicates reports whether topic is copied from the primary, and so
+// read-only here
+func (f *Follower) Replicates(topic string) bool {
+	return f.enabled() && (len(f.config.Topics) == 0 || slices.Contains(f.config.Topics, topic))
+}
+
+// Start starts pulling from the primary
+func (f *Follower) Start() {
+	if !f.enabled() {
+		close(f.done)
+		return
+	}
+	if _, ok := f.engine.topicStore.(store.ReplicaAppender); !ok {
+		log.Printf("[engine] follower mode unavailable: storage backend cannot store records at given offsets")
+		close(f.done)
+		return
+	}
+	interval := f.config.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	log.Printf("[engine] following %s every %s", f.config.Primary, interval)
+	go func() {
+		defer close(f.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			f.engine.safely("follower", f.sync)
+			select {
+			case <-ticker.C:
+			case <-f.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops pulling and waits for a pull in progress to end
+func (f *Follower) Stop() {
+	select {
+	case <-f.stopChan:
+	default:
+		close(f.stopChan)
+	}
+	<-f.done
+}
+
+// Status returns how far each topic has been copied, or nil when follower
+// mode is off
+func (f *Follower) Status() *FollowerStatus {
+	if !f.enabled() {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status := &FollowerStatus{Primary: f.config.Primary, Error: f.err, Topics: make([]ReplicaStatus, 0, len(f.replicas))}
+	for _, r := range f.replicas {
+		status.Topics = append(status.Topics, *r)
+	}
+	sort.Slice(status.Topics, func(i, j int) bool { return status.Topics[i].Topic < status.Topics[j].Topic })
+	return status
+}
+
+// sync copies what the primary's topics have gained since the last pull
+func (f *Follower) sync() {
+	ctx := f.engine.ctx
+	var topics []primaryTopic
+	err := f.get(ctx, "/api/topics", func(body io.Reader) error {
+		return json.NewDecoder(body).Decode(&topics)
+	})
+	f.mu.Lock()
+	if err != nil && ctx.Err() == nil && f.err != err.Error() {
+		log.Printf("[engine] follower: listing topics on %s: %v", f.config.Primary, err)
+	}
+	if err != nil {
+		f.err = err.Error()
+	} else {
+		f.err = ""
+	}
+	f.mu.Unlock()
+	if err != nil {
+		return
+	}
+
+	listed := make(map[string]bool, len(topics))
+	for _, t := range topics {
+		if !f.Replicates(t.Name) {
+			continue
+		}
+		listed[t.Name] = true
+		f.syncTopic(ctx, t)
+		if ctx.Err() != nil {
+			return
+		}
+	}
+
+	// Replicated topics the primary no longer has go here too
+	for _, name := range f.engine.ListTopics() {
+		if listed[name] || !f.Replicates(name) {
+			continue
+		}
+		if err := f.engine.DeleteTopic(ctx, name); err != nil && !errors.Is(err, store.ErrTopicNotFound) {
+			log.Printf("[engine] follower: deleting %s, gone from the primary: %v", name, err)
+			continue
+		}
+		log.Printf("[engine] follower: deleted %s, gone from the primary", name)
+		f.mu.Lock()
+		delete(f.replicas, name)
+		f.mu.Unlock()
+	}
+}
+
+// syncTopic brings one topic up to the primary's latest offset
+func (f *Follower) syncTopic(ctx context.Context, t primaryTopic) {
+	f.mu.Lock()
+	r := f.replicas[t.Name]
+	if r == nil {
+		r = &ReplicaStatus{Topic: t.Name, LatestOffset: -1}
+		f.replicas[t.Name] = r
+	}
+	recreated := r.PrimaryID != "" && r.PrimaryID != t.ID
+	r.PrimaryID, r.PrimaryLatest = t.ID, t.LatestOffset
+	f.mu.Unlock()
+
+	err := f.copyTopic(ctx, t, recreated)
+	latest, _ := f.engine.LatestOffset(t.Name)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r.LatestOffset = latest
+	r.Lag = max(t.LatestOffset-latest, 0)
+	if err != nil {
+		if ctx.Err() == nil && r.Error != err.Error() {
+			log.Printf("[engine] follower: copying %s: %v", t.Name, err)
+		}
+		r.Error = err.Error()
+		return
+	}
+	r.Error = ""
+	if r.Lag == 0 {
+		r.Synced = time.Now()
+	}
+}
+
+func (f *Follower) copyTopic(ctx context.Context, t primaryTopic, recreated bool) error {
+	e := f.engine
+	latest, err := e.LatestOffset(t.Name)
+	exists := err == nil
+	// Being ahead of the primary means the primary's topic is a new one
+	if exists && (recreated || latest > t.LatestOffset) {
+		log.Printf("[engine] follower: %s was recreated on the primary; copying it again", t.Name)
+		if err := e.DeleteTopic(ctx, t.Name); err != nil {
+			return err
+		}
+		exists = false
+	}
+	if !exists {
+		var primary struct {
+			Config map[string]string `json:"config"`
+		}
+		if err := f.get(ctx, "/api/topics/"+url.PathEscape(t.Name), func(body io.Reader) error {
+			return json.NewDecoder(body).Decode(&primary)
+		}); err != nil {
+			return err
+		}
+		if err := e.createTopic(ctx, t.Name, primary.Config); err != nil && !errors.Is(err, store.ErrTopicExists) {
+			return err
+		}
+		latest = -1
+	}
+	if latest >= t.LatestOffset {
+		return nil
+	}
+	return f.pull(ctx, t.Name, latest+1)
+}
+
+// pull copies the topic's records from offset on, as the primary stores
+// them
+func (f *Follower) pull(ctx context.Context, topic string, offset int64) error {
+	appender := f.engine.topicStore.(store.ReplicaAppender)
+	path := fmt.Sprintf("/api/topics/%s/export?format=records&cursor=%d&to=%d", url.PathEscape(topic), offset, int64(math.MaxInt64))
+	return f.get(ctx, path, func(body io.Reader) error {
+		dec := json.NewDecoder(body)
+		batch := make([]store.Record, 0, followerBatchRecords)
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			err := appender.AppendReplica(ctx, topic, batch)
+			batch = batch[:0]
+			return err
+		}
+		for {
+			var rec store.Record
+			if err := dec.Decode(&rec); err == io.EOF {
+				return flush()
+			} else if err != nil {
+				flush()
+				return fmt.Errorf("reading export: %w", err)
+			}
+			batch = append(batch, rec)
+			if len(batch) == followerBatchRecords {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+	})
+}
+
+// get requests path from the primary and hands a 200 response's body to fn
+func (f *Follower) get(ctx context.Context, path string, fn func(io.Reader) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(f.config.Primary, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	if f.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.config.Token)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return fn(resp.Body)
+}
+
+// Follower returns the engine's follower
+func (e *Engine) Follower() *Follower {
+	return e.follower
+}
@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// stallSlack is added to three scheduler intervals before a loop that has
+// not gone round is reported as stalled, so slow passes are tolerated
+const stallSlack = 30 * time.Second
+
+// loopMonitor records when a scheduler loop last went round, so readiness
+// can tell a running scheduler from a stopped or stuck one
+type loopMonitor struct {
+	interval int64 // nanoseconds, atomic
+	last     int64 // unix nanoseconds of the last round, atomic; 0 = not running
+}
+
+func (m *loopMonitor) start(interval time.Duration) {
+	atomic.StoreInt64(&m.interval, int64(interval))
+	m.tick()
+}
+
+func (m *loopMonitor) tick() {
+	atomic.StoreInt64(&m.last, time.Now().UnixNano())
+}
+
+func (m *loopMonitor) stop() {
+	atomic.StoreInt64(&m.last, 0)
+}
+
+// check returns nil while the loop is running and on schedule
+func (m *loopMonitor) check() error {
+	last := atomic.LoadInt64(&m.last)
+	if last == 0 {
+		return fmt.Errorf("not running")
+	}
+	idle := time.Since(time.Unix(0, last))
+	if idle > 3*time.Duration(atomic.LoadInt64(&m.interval))+stallSlack {
+		return fmt.Errorf("stalled: last ran %s ago", idle.Round(time.Second))
+	}
+	return nil
+}
+
+// PingStorage checks that storage accepts writes and has loaded topic metadata
+func (e *Engine) PingStorage(ctx context.Context) error {
+	return e.topicStore.Ping(ctx)
+}
+
+// Schedulers checks every background scheduler this configuration runs,
+// returning nil for each one that is running on schedule
+func (e *Engine) Schedulers() map[string]error {
+	status := map[string]error{
+		"fetch":             e.fetchSched.monitor.check(),
+		"member_expiration": e.memberSched.monitor.check(),
+	}
+	if e.heartbeats.enabled() {
+		status["heartbeat_flush"] = e.heartbeats.monitor.check()
+	}
+	if e.config.Retention.Enabled {
+		status["retention"] = e.retentionSched.monitor.check()
+	}
+	if len(e.alerts.rules) > 0 && e.alerts.config.Interval > 0 {
+		status["alerts"] = e.alerts.monitor.check()
+	}
+	if e.metrics.enabled() {
+		status["metrics"] = e.metrics.monitor.check()
+	}
+	if e.disk.enabled() {
+		status["disk_watchdog"] = e.disk.monitor.check()
+	}
+	return status
+}
@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// HeartbeatFlusher coalesces member heartbeats in memory and writes the
+// latest one per member to the group store on a timer, in one transaction,
+// instead of a write per heartbeat under the store lock.
+//
+// Loss tolerance: session expiry runs on the coordinator's in-memory state,
+// so the stored last_heartbeat is only what the admin API reports and what
+// survives a restart. It lags by up to one flush interval; a crash loses
+// at most that much, and Stop flushes what is pending. A failed flush is
+// retried on the next tick, unless a newer heartbeat has replaced it.
+type HeartbeatFlusher struct {
+	engine   *Engine
+	interval time.Duration
+	ticker   *time.Ticker
+	stopChan chan struct{}
+	monitor  loopMonitor
+
+	mu      sync.Mutex
+	pending map[heartbeatKey]time.Time
+}
+
+type heartbeatKey struct {
+	group, member string
+}
+
+// NewHeartbeatFlusher creates a new HeartbeatFlusher
+func NewHeartbeatFlusher(engine *Engine, interval time.Duration) *HeartbeatFlusher {
+	return &HeartbeatFlusher{
+		engine:   engine,
+		interval: interval,
+		stopChan: make(chan struct{}),
+		pending:  make(map[heartbeatKey]time.Time),
+	}
+}
+
+// enabled reports whether heartbeats are coalesced. With a zero interval
+// every heartbeat is written through.
+func (f *HeartbeatFlusher) enabled() bool {
+	return f != nil && f.interval > 0
+}
+
+// Record notes a member's heartbeat for the next flush
+func (f *HeartbeatFlusher) Record(groupID, memberID string, at time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pending[heartbeatKey{groupID, memberID}] = at
+}
+
+// Pending returns how many members have heartbeats waiting to be written
+func (f *HeartbeatFlusher) Pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.pending)
+}
+
+// Flush writes the pending heartbeats
+func (f *HeartbeatFlusher) Flush() error {
+	f.mu.Lock()
+	if len(f.pending) == 0 {
+		f.mu.Unlock()
+		return nil
+	}
+	batch := f.pending
+	f.pending = make(map[heartbeatKey]time.Time, len(batch))
+	f.mu.Unlock()
+
+	beats := make([]store.Heartbeat, 0, len(batch))
+	for k, at := range batch {
+		beats = append(beats, store.Heartbeat{GroupID: k.group, MemberID: k.member, At: at})
+	}
+	if err := f.engine.groupStore.UpdateHeartbeats(persistCtx, beats); err != nil {
+		// Put the batch back for the next flush, behind anything newer
+		f.mu.Lock()
+		for k, at := range batch {
+			if _, newer := f.pending[k]; !newer {
+				f.pending[k] = at
+			}
+		}
+		f.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Start starts the flusher
+func (f *HeartbeatFlusher) Start() {
+	if !f.enabled() {
+		return
+	}
+	f.ticker = time.NewTicker(f.interval)
+	f.monitor.start(f.interval)
+	go f.loop()
+}
+
+// Stop stops the flusher and writes what is pending
+func (f *HeartbeatFlusher) Stop() {
+	if f.ticker != nil {
+		f.ticker.Stop()
+	}
+	f.monitor.stop()
+	select {
+	case <-f.stopChan:
+	default:
+		close(f.stopChan)
+	}
+	if f.enabled() {
+		if err := f.Flush(); err != nil {
+			log.Printf("[engine] final heartbeat flush failed: %v", err)
+		}
+	}
+}
+
+func (f *HeartbeatFlusher) loop() {
+	for {
+		select {
+		case <-f.ticker.C:
+			f.engine.safely("heartbeat flusher", f.flush)
+			f.monitor.tick()
+		case <-f.stopChan:
+			return
+		}
+	}
+}
+
+func (f *HeartbeatFlusher) flush() {
+	if err := f.Flush(); err != nil {
+		log.Printf("[engine] heartbeat flush failed, retrying next tick: %v", err)
+	}
+}
@@ -0,0 +1,391 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/capture"
+	"github.com/rizkyandriawan/monolog/internal/ids"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// ============================================================================
+// Imports
+//
+// An import loads a newline-delimited JSON file, in the format the HTTP
+// export writes, into a topic. The file is uploaded in chunks, each sent
+// with the byte offset it starts at and optionally its SHA-256, so an
+// upload that breaks off resumes from the last chunk the broker applied
+// instead of starting over. Only one chunk is held in memory at a time and
+// its messages are appended a batch at a time, each waiting for the one
+// before (and for produce.import_rate), so a multi-gigabyte import cannot
+// run ahead of storage. Messages are scrubbed with the capture.scrub rules
+// before they are appended. Sessions live in memory: a restart forgets
+// them, though what they appended stays.
+// ============================================================================
+
+// importSessionTimeout is how long an import session is kept after its
+// last chunk
+const importSessionTimeout = 24 * time.Hour
+
+// ImportStatus describes an import session
+type ImportStatus struct {
+	ID        string    `json:"id"`
+	Topic     string    `json:"topic"`
+	Offset    int64     `json:"offset"`              // bytes of the upload received; the next chunk starts here
+	Pending   int       `json:"pending"`             // bytes of an unterminated last line, appended by the next chunk or on completion
+	Messages  int64     `json:"messages"`            // messages appended so far
+	Scrubbed  int64     `json:"scrubbed,omitempty"`  // of those, messages the capture.scrub rules changed
+	First     int64     `json:"first_offset"`        // topic offset of the first message appended, -1 before any
+	Last      int64     `json:"last_offset"`         // topic offset of the last message appended, -1 before any
+	SHA256    string    `json:"sha256"`              // of the bytes received so far
+	Completed bool      `json:"completed,omitempty"` // no more chunks are accepted
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+}
+
+// importLine is one message of an import file. Export's offset and codec
+// fields are ignored; a missing timestamp means the append time.
+type importLine struct {
+	Key       *string `json:"key"`
+	Value     *string `json:"value"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+type importSession struct {
+	mu     sync.Mutex // held while a chunk is applied
+	status ImportStatus
+	tail   []byte // received bytes after the last newline
+	hash   hash.Hash
+
+	published ImportStatus // status as of the last change, guarded by Imports.mu
+}
+
+// Imports tracks the import sessions and paces their appends
+type Imports struct {
+	engine   *Engine
+	scrub    *capture.Scrubber // nil when capture.scrub is unset
+	scrubErr error             // why the capture.scrub rules do not compile
+	mu       sync.Mutex
+	sessions map[string]*importSession
+	next     time.Time // when the import rate allows the next append
+}
+
+// NewImports creates an empty Imports
+func NewImports(engine *Engine) *Imports {
+	m := &Imports{engine: engine, sessions: make(map[string]*importSession)}
+	m.scrub, m.scrubErr = capture.NewScrubber(engine.config.Capture.Scrub)
+	return m
+}
+
+// Start opens an import session into topic, creating the topic if
+// topics.auto_create allows. Imports are refused while the capture.scrub
+// rules are invalid, rather than storing data unscrubbed.
+func (m *Imports) Start(ctx context.Context, topic string) (ImportStatus, error) {
+	if m.scrubErr != nil {
+		return ImportStatus{}, fmt.Errorf("%w: capture scrub rules: %v", ErrInvalidImport, m.scrubErr)
+	}
+	if err := m.engine.EnsureTopic(ctx, topic); err != nil {
+		return ImportStatus{}, err
+	}
+	now := time.Now()
+	sess := &importSession{
+		status: ImportStatus{
+			ID:      ids.Hex(16),
+			Topic:   topic,
+			First:   -1,
+			Last:    -1,
+			Created: now,
+			Updated: now,
+		},
+		hash: sha256.New(),
+	}
+	sess.status.SHA256 = hex.EncodeToString(sess.hash.Sum(nil))
+	sess.published = sess.status
+
+	m.mu.Lock()
+	m.expire(now)
+	m.sessions[sess.status.ID] = sess
+	m.mu.Unlock()
+	log.Printf("[engine] import %s into %s started", sess.status.ID, topic)
+	return sess.status, nil
+}
+
+// expire drops sessions idle for longer than importSessionTimeout. Called
+// with m.mu held.
+func (m *Imports) expire(now time.Time) {
+	for id, sess := range m.sessions {
+		if now.Sub(sess.published.Updated) > importSessionTimeout {
+			delete(m.sessions, id)
+		}
+	}
+}
+
+// lock returns the session with id, locked, or an error if there is none
+// or another chunk is being applied to it
+func (m *Imports) lock(id string) (*importSession, error) {
+	m.mu.Lock()
+	sess := m.sessions[id]
+	m.mu.Unlock()
+	if sess == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownImport, id)
+	}
+	if !sess.mu.TryLock() {
+		return nil, fmt.Errorf("%w: another chunk of import %s is being applied", ErrImportConflict, id)
+	}
+	return sess, nil
+}
+
+// Get returns the status of an import session
+func (m *Imports) Get(id string) (ImportStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expire(time.Now())
+	sess := m.sessions[id]
+	if sess == nil {
+		return ImportStatus{}, fmt.Errorf("%w: %s", ErrUnknownImport, id)
+	}
+	return sess.published, nil
+}
+
+// List returns every import session, oldest first
+func (m *Imports) List() []ImportStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expire(time.Now())
+	list := make([]ImportStatus, 0, len(m.sessions))
+	for _, sess := range m.sessions {
+		list = append(list, sess.published)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.Before(list[j].Created) })
+	return list
+}
+
+// Abort closes an import session. Messages it appended stay.
+func (m *Imports) Abort(id string) error {
+	sess, err := m.lock(id)
+	if err != nil {
+		return err
+	}
+	defer sess.mu.Unlock()
+	m.mu.Lock()
+	delete(m.sessions, id)
+	m.mu.Unlock()
+	log.Printf("[engine] import %s into %s aborted after %d messages", id, sess.status.Topic, sess.status.Messages)
+	return nil
+}
+
+// Append applies the chunk of the upload starting at byte offset. A
+// non-empty checksum is the chunk's hex SHA-256, checked before anything
+// is appended. If appending fails part way, the session's offset stops
+// after the last line appended, so resending from there neither skips nor
+// repeats a message.
+func (m *Imports) Append(ctx context.Context, id string, offset int64, chunk []byte, checksum string) (ImportStatus, error) {
+	sess, err := m.lock(id)
+	if err != nil {
+		return ImportStatus{}, err
+	}
+	defer sess.mu.Unlock()
+	st := &sess.status
+
+	if st.Completed {
+		return *st, fmt.Errorf("%w: import %s is complete", ErrImportConflict, id)
+	}
+	if offset != st.Offset {
+		return *st, fmt.Errorf("%w: chunk starts at byte %d, import %s is at byte %d", ErrImportOffsetMismatch, offset, id, st.Offset)
+	}
+	if checksum != "" {
+		sum := sha256.Sum256(chunk)
+		if !strings.EqualFold(checksum, hex.EncodeToString(sum[:])) {
+			return *st, fmt.Errorf("%w: chunk SHA-256 is %x", ErrImportChecksum, sum)
+		}
+	}
+
+	data := append(sess.tail[:len(sess.tail):len(sess.tail)], chunk...)
+	end := bytes.LastIndexByte(data, '\n') + 1
+	if limit := m.engine.config.Produce.ImportChunkSize; limit > 0 && len(data)-end > limit {
+		return *st, fmt.Errorf("%w: line at byte %d is longer than produce.import_chunk_size (%d bytes)", ErrInvalidImport, st.Offset-int64(len(sess.tail)), limit)
+	}
+	records, ends, err := parseImportLines(data[:end], st.Offset-int64(len(sess.tail)))
+	if err != nil {
+		return *st, err
+	}
+
+	applied, err := m.appendRecords(ctx, sess, records, ends)
+	if err != nil {
+		// Keep what was appended; the rest of the chunk is resent
+		if applied > len(sess.tail) {
+			sess.hash.Write(data[len(sess.tail):applied])
+			st.Offset += int64(applied - len(sess.tail))
+			sess.tail = nil
+		}
+		m.touch(sess)
+		return *st, err
+	}
+	sess.hash.Write(chunk)
+	st.Offset += int64(len(chunk))
+	sess.tail = bytes.Clone(data[end:])
+	m.touch(sess)
+	return *st, nil
+}
+
+// Complete appends an unterminated last line, if any, and closes the
+// session for chunks. A non-empty checksum is the hex SHA-256 of the whole
+// upload, checked first.
+func (m *Imports) Complete(ctx context.Context, id, checksum string) (ImportStatus, error) {
+	sess, err := m.lock(id)
+	if err != nil {
+		return ImportStatus{}, err
+	}
+	defer sess.mu.Unlock()
+	st := &sess.status
+
+	if st.Completed {
+		return *st, nil
+	}
+	if checksum != "" && !strings.EqualFold(checksum, st.SHA256) {
+		return *st, fmt.Errorf("%w: upload SHA-256 is %s", ErrImportChecksum, st.SHA256)
+	}
+	if len(bytes.TrimSpace(sess.tail)) > 0 {
+		records, ends, err := parseImportLines(sess.tail, st.Offset-int64(len(sess.tail)))
+		if err != nil {
+			return *st, err
+		}
+		if _, err := m.appendRecords(ctx, sess, records, ends); err != nil {
+			m.touch(sess)
+			return *st, err
+		}
+	}
+	sess.tail = nil
+	st.Completed = true
+	m.touch(sess)
+	log.Printf("[engine] import %s into %s completed: %d messages, %d bytes", id, st.Topic, st.Messages, st.Offset)
+	return *st, nil
+}
+
+// appendRecords scrubs records and appends them in batches of
+// produce.max_batch_records, returning how many bytes of the lines parsed
+// they cover. ends[i] is the end of records[i]'s line. ctx only cuts the
+// wait for the import rate short: an append once started is seen through,
+// so what it stored is always counted as applied.
+func (m *Imports) appendRecords(ctx context.Context, sess *importSession, records []store.Record, ends []int) (int, error) {
+	e := m.engine
+	st := &sess.status
+	scrubbed := make([]bool, len(records))
+	if m.scrub != nil {
+		for i, r := range records {
+			records[i].Key, records[i].Value, scrubbed[i] = m.scrub.Message(r.Key, r.Value)
+		}
+	}
+	batch := max(e.config.Produce.MaxBatchRecords, 1)
+	applied := 0
+	for i := 0; i < len(records); i += batch {
+		n := min(batch, len(records)-i)
+		if err := m.wait(ctx, n); err != nil {
+			return applied, err
+		}
+		base, err := e.ProduceBatched(context.WithoutCancel(ctx), st.Topic, records[i:i+n])
+		if err != nil {
+			return applied, err
+		}
+		if st.First < 0 {
+			st.First = base
+		}
+		st.Last = base + int64(n) - 1
+		st.Messages += int64(n)
+		for _, changed := range scrubbed[i : i+n] {
+			if changed {
+				st.Scrubbed++
+			}
+		}
+		applied = ends[i+n-1]
+	}
+	return applied, nil
+}
+
+// wait holds an append of n records until produce.import_rate allows it
+func (m *Imports) wait(ctx context.Context, n int) error {
+	rate := m.engine.config.Produce.ImportRate
+	if rate <= 0 {
+		return nil
+	}
+	m.mu.Lock()
+	now := time.Now()
+	start := m.next
+	if start.Before(now) {
+		start = now
+	}
+	m.next = start.Add(time.Duration(n) * time.Second / time.Duration(rate))
+	m.mu.Unlock()
+
+	if delay := start.Sub(now); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// touch records that a session changed, publishing its new status
+func (m *Imports) touch(sess *importSession) {
+	sess.status.Pending = len(sess.tail)
+	sess.status.SHA256 = hex.EncodeToString(sess.hash.Sum(nil))
+	sess.status.Updated = time.Now()
+	m.mu.Lock()
+	sess.published = sess.status
+	m.mu.Unlock()
+}
+
+// parseImportLines parses newline-delimited JSON messages, skipping blank
+// lines. ends[i] is the byte just past the line of records[i]; base is
+// where data starts in the upload, for error messages.
+func parseImportLines(data []byte, base int64) ([]store.Record, []int, error) {
+	var records []store.Record
+	var ends []int
+	for pos, lineNo := 0, 1; pos < len(data); lineNo++ {
+		n := bytes.IndexByte(data[pos:], '\n')
+		if n < 0 {
+			n = len(data) - pos
+		}
+		line := bytes.TrimSpace(data[pos : pos+n])
+		start := pos
+		pos += n + 1
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg importLine
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return nil, nil, fmt.Errorf("%w: line at byte %d: %v", ErrInvalidImport, base+int64(start), err)
+		}
+		rec := store.Record{Timestamp: msg.Timestamp}
+		if msg.Key != nil && *msg.Key != "" {
+			rec.Key = []byte(*msg.Key)
+		}
+		if msg.Value != nil {
+			rec.Value = []byte(*msg.Value)
+		}
+		records = append(records, rec)
+		ends = append(ends, min(pos, len(data)))
+	}
+	return records, ends, nil
+}
+
+// Imports returns the engine's import sessions
+func (e *Engine) Imports() *Imports {
+	return e.imports
+}
@@ -0,0 +1,43 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+func (e *Engine) integrityChecker() (store.IntegrityChecker, error) {
+	checker, ok := e.topicStore.(store.IntegrityChecker)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support integrity checks")
+	}
+	return checker, nil
+}
+
+// IntegrityStats returns the store's offset integrity counters
+func (e *Engine) IntegrityStats() (store.IntegrityStats, error) {
+	checker, err := e.integrityChecker()
+	if err != nil {
+		return store.IntegrityStats{}, err
+	}
+	return checker.Integrity(), nil
+}
+
+// CheckIntegrity verifies the offset bookkeeping of a topic
+func (e *Engine) CheckIntegrity(ctx context.Context, topic string) (*store.IntegrityReport, error) {
+	checker, err := e.integrityChecker()
+	if err != nil {
+		return nil, err
+	}
+	return checker.CheckIntegrity(ctx, topic)
+}
+
+// RepairTopic resyncs a topic's latest offset with its stored log
+func (e *Engine) RepairTopic(ctx context.Context, topic string) (*store.IntegrityReport, error) {
+	checker, err := e.integrityChecker()
+	if err != nil {
+		return nil, err
+	}
+	return checker.RepairTopic(ctx, topic)
+}
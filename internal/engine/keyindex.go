@@ -0,0 +1,186 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Key Index
+//
+// For compacted topics, the offset of the latest value of every key: the
+// table a changelog topic describes, as compaction would leave it. Each
+// topic's index is built on first use and caught up with the log on every
+// lookup after that, so it costs nothing for topics nobody browses. A
+// tombstone (null value) removes its key. Records of open and aborted
+// transactions are left out, as read_committed consumers see the topic.
+// ============================================================================
+
+// MaxKeyPage caps how many keys one page of a key listing returns
+const MaxKeyPage = 1000
+
+// keyIndexChunk is how many stored records a catch-up reads at a time
+const keyIndexChunk = 500
+
+// KeyPage is one page of a compacted topic's keys, in key order
+type KeyPage struct {
+	Messages []Message // the latest value of each key
+	Total    int       // keys in the topic
+	Next     string    // key to pass as after for the next page; empty on the last one
+}
+
+// KeyIndex holds the key index of each compacted topic browsed so far
+type KeyIndex struct {
+	engine *Engine
+	mu     sync.Mutex
+	topics map[string]*topicKeys
+}
+
+type topicKeys struct {
+	created time.Time        // the topic indexed; a recreated topic is reindexed
+	next    int64            // first offset not indexed yet
+	offsets map[string]int64 // key -> offset of its latest value
+}
+
+// NewKeyIndex creates an empty KeyIndex
+func NewKeyIndex(engine *Engine) *KeyIndex {
+	return &KeyIndex{engine: engine, topics: make(map[string]*topicKeys)}
+}
+
+// forget drops a deleted topic's index
+func (x *KeyIndex) forget(topic string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	delete(x.topics, topic)
+}
+
+// update brings topic's index up to the end of its log. Called with x.mu
+// held.
+func (x *KeyIndex) update(ctx context.Context, topic string) (*topicKeys, error) {
+	e := x.engine
+	meta, err := e.topicStore.GetMeta(topic)
+	if err != nil {
+		return nil, err
+	}
+	if policy := meta.Config[ConfigCleanupPolicy]; !strings.Contains(policy, CleanupCompact) {
+		return nil, fmt.Errorf("%w: %s has cleanup.policy %q", ErrNotCompacted, topic, policy)
+	}
+
+	tk := x.topics[topic]
+	if tk == nil || !tk.created.Equal(meta.CreatedAt) {
+		tk = &topicKeys{created: meta.CreatedAt, offsets: make(map[string]int64)}
+		x.topics[topic] = tk
+	}
+	latest, err := e.LatestOffset(topic)
+	if err != nil {
+		return nil, err
+	}
+	// Only committed records count: the index stops at the last stable
+	// offset and skips what aborted transactions wrote
+	for tk.next <= latest {
+		records, next, err := e.FetchCommitted(ctx, topic, tk.next, keyIndexChunk)
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range records {
+			for _, msg := range storedMessages(topic, rec) {
+				if msg.Offset < tk.next || msg.Key == nil {
+					continue
+				}
+				if msg.Value == nil {
+					delete(tk.offsets, string(msg.Key))
+				} else {
+					tk.offsets[string(msg.Key)] = msg.Offset
+				}
+			}
+		}
+		if next <= tk.next {
+			break
+		}
+		tk.next = next
+	}
+	return tk, nil
+}
+
+// readAt returns the message stored at offset, or nil if it was deleted
+func (x *KeyIndex) readAt(ctx context.Context, topic string, offset int64) (*Message, error) {
+	records, err := x.engine.Fetch(ctx, topic, offset, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range records {
+		for _, msg := range storedMessages(topic, rec) {
+			if msg.Offset == offset {
+				return &msg, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// TopicKeys returns up to limit keys of a compacted topic after the key
+// after, each with its latest value
+func (e *Engine) TopicKeys(ctx context.Context, topic, after string, limit int) (*KeyPage, error) {
+	limit = min(max(limit, 1), MaxKeyPage)
+	x := e.keys
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	tk, err := x.update(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(tk.offsets))
+	for k := range tk.offsets {
+		if k > after {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+
+	page := &KeyPage{Messages: []Message{}}
+	for _, k := range keys {
+		if len(page.Messages) == limit {
+			page.Next = string(page.Messages[limit-1].Key)
+			break
+		}
+		msg, err := x.readAt(ctx, topic, tk.offsets[k])
+		if err != nil {
+			return nil, err
+		}
+		if msg == nil {
+			// Truncated away by DeleteRecords; the key has no value left
+			delete(tk.offsets, k)
+			continue
+		}
+		page.Messages = append(page.Messages, *msg)
+	}
+	page.Total = len(tk.offsets)
+	return page, nil
+}
+
+// TopicKey returns the latest value of key in a compacted topic, or nil
+// if the key has none
+func (e *Engine) TopicKey(ctx context.Context, topic string, key []byte) (*Message, error) {
+	x := e.keys
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	tk, err := x.update(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+	offset, ok := tk.offsets[string(key)]
+	if !ok {
+		return nil, nil
+	}
+	msg, err := x.readAt(ctx, topic, offset)
+	if err == nil && msg == nil {
+		delete(tk.offsets, string(key))
+	}
+	return msg, err
+}
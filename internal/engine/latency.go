@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencySamples is how many recent observations a LatencyTracker keeps
+const latencySamples = 1024
+
+// LatencyTracker keeps the most recent operation latencies for percentile
+// queries
+type LatencyTracker struct {
+	mu      sync.Mutex
+	samples [latencySamples]time.Duration
+	n       int // samples filled
+	next    int // ring position of the next sample
+	count   int64
+}
+
+// Observe records one operation's latency
+func (t *LatencyTracker) Observe(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.samples[t.next] = d
+	t.next = (t.next + 1) % latencySamples
+	if t.n < latencySamples {
+		t.n++
+	}
+	t.count++
+}
+
+// Since records the latency of an operation that started at start
+func (t *LatencyTracker) Since(start time.Time) {
+	t.Observe(time.Since(start))
+}
+
+// Percentile returns the p-th percentile (0-100) of the recent samples and
+// false if nothing has been observed yet
+func (t *LatencyTracker) Percentile(p float64) (time.Duration, bool) {
+	t.mu.Lock()
+	sorted := make([]time.Duration, t.n)
+	copy(sorted, t.samples[:t.n])
+	t.mu.Unlock()
+
+	if len(sorted) == 0 {
+		return 0, false
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := int(p / 100 * float64(len(sorted)-1))
+	return sorted[idx], true
+}
+
+// Samples returns a copy of the recent samples, in no particular order
+func (t *LatencyTracker) Samples() []time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]time.Duration(nil), t.samples[:t.n]...)
+}
+
+// Count returns how many operations have been observed since startup
+func (t *LatencyTracker) Count() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.count
+}
@@ -0,0 +1,187 @@
+package engine
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// ============================================================================
+// Memory report
+//
+// The memory report sets what the Go runtime holds next to what each
+// subsystem keeps in memory, for right-sizing containers. Subsystem bytes
+// count the data held (record payloads, dictionary data, SQLite pages),
+// not the overhead of the maps and structs around it, so they add up to
+// less than the heap.
+// ============================================================================
+
+// MemoryReport is the process's memory use, in total and by subsystem
+type MemoryReport struct {
+	Limit      int64             `json:"limit"` // soft limit in bytes, from memory.limit or GOMEMLIMIT; 0 for none
+	Runtime    RuntimeMemory     `json:"runtime"`
+	Subsystems []SubsystemMemory `json:"subsystems"`
+}
+
+// RuntimeMemory is what the Go runtime reports, in bytes
+type RuntimeMemory struct {
+	HeapAlloc  uint64 `json:"heap_alloc"` // live objects and garbage not yet collected
+	HeapInuse  uint64 `json:"heap_inuse"`
+	HeapSys    uint64 `json:"heap_sys"`
+	StackInuse uint64 `json:"stack_inuse"`
+	Sys        uint64 `json:"sys"`     // obtained from the OS in all
+	NextGC     uint64 `json:"next_gc"` // heap size the next collection starts at
+	GCCycles   uint32 `json:"gc_cycles"`
+}
+
+// SubsystemMemory is what one subsystem holds
+type SubsystemMemory struct {
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes"`           // data held, where known
+	Items int    `json:"items"`           // records, fetches, groups and so on, as the subsystem counts them
+	Limit int64  `json:"limit,omitempty"` // bytes it may grow to, where capped
+	Error string `json:"error,omitempty"` // why Bytes could not be read
+}
+
+// MemoryReport reports the memory held by the process and each subsystem
+func (e *Engine) MemoryReport(ctx context.Context) MemoryReport {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	report := MemoryReport{
+		Runtime: RuntimeMemory{
+			HeapAlloc:  ms.HeapAlloc,
+			HeapInuse:  ms.HeapInuse,
+			HeapSys:    ms.HeapSys,
+			StackInuse: ms.StackInuse,
+			Sys:        ms.Sys,
+			NextGC:     ms.NextGC,
+			GCCycles:   ms.NumGC,
+		},
+	}
+	// A negative limit reads the current one without changing it
+	if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+		report.Limit = limit
+	}
+
+	report.Subsystems = []SubsystemMemory{
+		e.storageMemory(ctx),
+		e.views.memory(ctx),
+		e.batcher.memory(),
+		e.dictionaries.memory(),
+		e.producers.memory(),
+		e.coordinator.memory(),
+		e.GetPendingQueue().memory(),
+		e.asyncProduces.memory(),
+	}
+	return report
+}
+
+// storageMemory reports the storage backend's page cache and, for
+// in-memory backends, its data
+func (e *Engine) storageMemory(ctx context.Context) SubsystemMemory {
+	m := SubsystemMemory{Name: "storage"}
+	mr, ok := e.topicStore.(store.MemoryReporter)
+	if !ok {
+		m.Error = "storage backend does not report its memory"
+		return m
+	}
+	sm, err := mr.Memory(ctx)
+	if err != nil {
+		m.Error = err.Error()
+		return m
+	}
+	m.Bytes, m.Items, m.Limit = sm.Data, sm.Topics, sm.CacheLimit
+	return m
+}
+
+func (m *Views) memory(ctx context.Context) SubsystemMemory {
+	sm := SubsystemMemory{Name: "views"}
+	m.mu.Lock()
+	sm.Items = len(m.views)
+	m.mu.Unlock()
+	if m.db == nil {
+		sm.Error = m.err.Error()
+		return sm
+	}
+	size, err := m.db.Size(ctx)
+	if err != nil {
+		sm.Error = err.Error()
+	}
+	sm.Bytes = size
+	return sm
+}
+
+// memory counts the records waiting to be appended
+func (b *ProduceBatcher) memory() SubsystemMemory {
+	m := SubsystemMemory{Name: "produce_batcher"}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, tb := range b.topics {
+		m.Items += tb.records
+		for _, req := range tb.pending {
+			m.Bytes += recordsBytes(req.records)
+		}
+	}
+	return m
+}
+
+func (d *Dictionaries) memory() SubsystemMemory {
+	m := SubsystemMemory{Name: "dictionaries"}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, td := range d.current {
+		m.Items++
+		m.Bytes += int64(td.size)
+	}
+	return m
+}
+
+// memory counts the idempotent producer states kept per topic
+func (p *Producers) memory() SubsystemMemory {
+	m := SubsystemMemory{Name: "producers"}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, states := range p.states {
+		m.Items += len(states)
+	}
+	return m
+}
+
+// memory counts the groups the coordinator tracks, with their assignments
+func (c *GroupCoordinator) memory() SubsystemMemory {
+	m := SubsystemMemory{Name: "groups"}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m.Items = len(c.groups)
+	for _, g := range c.groups {
+		for _, a := range g.assignments {
+			m.Bytes += int64(len(a))
+		}
+	}
+	return m
+}
+
+func (q *PendingQueue) memory() SubsystemMemory {
+	return SubsystemMemory{Name: "pending_fetches", Items: q.Len()}
+}
+
+func (a *AsyncProduces) memory() SubsystemMemory {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return SubsystemMemory{Name: "async_produces", Items: len(a.byToken)}
+}
+
+// recordsBytes is the payload of records: keys, values and headers
+func recordsBytes(records []store.Record) int64 {
+	var n int64
+	for _, r := range records {
+		n += int64(len(r.Key) + len(r.Value))
+		for k, v := range r.Headers {
+			n += int64(len(k) + len(v))
+		}
+	}
+	return n
+}
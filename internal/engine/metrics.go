@@ -0,0 +1,245 @@
+package engine
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// Series the metrics recorder keeps besides the alert metrics it shares
+// names with
+const (
+	MetricProduceRate = "produce_requests_per_sec"
+	MetricFetchRate   = "fetch_requests_per_sec"
+	MetricMessagesIn  = "messages_in_per_sec"
+	MetricErrors      = "errors" // failed requests in the interval, labeled by kind
+
+	MetricPendingFetches = "pending_fetches"     // parked fetches, labeled by topic
+	MetricPendingWait    = "pending_wait_p99_ms" // over the last 1024 parked fetches answered, labeled by topic
+)
+
+// metricsPruneInterval is how often samples past retention are deleted
+const metricsPruneInterval = time.Hour
+
+// MetricsSink receives every round of samples the metrics recorder takes
+type MetricsSink interface {
+	Name() string
+	Emit(ctx context.Context, samples []store.MetricSample) error
+}
+
+// historySink keeps samples in the topic store for `monolog report`
+type historySink struct {
+	store store.MetricsStore
+}
+
+func (h historySink) Name() string { return "history" }
+
+func (h historySink) Emit(ctx context.Context, samples []store.MetricSample) error {
+	return h.store.SaveMetrics(ctx, samples)
+}
+
+// MetricsRecorder samples broker metrics on a timer and hands them to its
+// sinks: the history in the topic store that `monolog report`
+// summarizes, and a StatsD agent when one is configured
+type MetricsRecorder struct {
+	engine   *Engine
+	config   config.MetricsConfig
+	history  store.MetricsStore // nil when the topic store cannot keep metrics
+	sinks    []MetricsSink
+	ticker   *time.Ticker
+	stopChan chan struct{}
+	monitor  loopMonitor
+
+	mu        sync.Mutex
+	last      time.Time
+	produces  int64
+	fetches   int64
+	offsets   map[string]int64 // latest offset by topic
+	errors    map[string]int64 // error count by kind
+	lastPrune time.Time
+}
+
+// NewMetricsRecorder creates a new MetricsRecorder. It records nothing
+// when it has no sinks: the topic store cannot keep metrics and no StatsD
+// agent is configured.
+func NewMetricsRecorder(engine *Engine, cfg config.MetricsConfig) *MetricsRecorder {
+	r := &MetricsRecorder{
+		engine:   engine,
+		config:   cfg,
+		stopChan: make(chan struct{}),
+	}
+	if ms, ok := engine.topicStore.(store.MetricsStore); ok {
+		r.history = ms
+		r.sinks = append(r.sinks, historySink{ms})
+	}
+	if cfg.StatsD.Addr != "" {
+		sink, err := NewStatsDSink(cfg.StatsD)
+		if err != nil {
+			log.Printf("[engine] statsd sink disabled: %v", err)
+		} else {
+			r.sinks = append(r.sinks, sink)
+		}
+	}
+	return r
+}
+
+// AddMetricsSink adds a sink the metrics recorder emits every sample to.
+// Call before Start.
+func (e *Engine) AddMetricsSink(sink MetricsSink) {
+	e.metrics.sinks = append(e.metrics.sinks, sink)
+}
+
+func (r *MetricsRecorder) enabled() bool {
+	return len(r.sinks) > 0 && r.config.Interval > 0
+}
+
+// Start starts the scheduler
+func (r *MetricsRecorder) Start() {
+	if !r.enabled() {
+		return
+	}
+	r.baseline(time.Now())
+	r.ticker = time.NewTicker(r.config.Interval)
+	r.monitor.start(r.config.Interval)
+	go r.loop()
+}
+
+// Stop stops the scheduler
+func (r *MetricsRecorder) Stop() {
+	if r.ticker != nil {
+		r.ticker.Stop()
+	}
+	r.monitor.stop()
+	select {
+	case <-r.stopChan:
+	default:
+		close(r.stopChan)
+	}
+}
+
+func (r *MetricsRecorder) loop() {
+	for {
+		select {
+		case <-r.ticker.C:
+			r.engine.safely("metrics recorder", r.Record)
+			r.monitor.tick()
+		case <-r.stopChan:
+			return
+		}
+	}
+}
+
+// baseline remembers the counters rates are measured against
+func (r *MetricsRecorder) baseline(now time.Time) {
+	e := r.engine
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.last = now
+	r.produces = e.produceLatency.Count()
+	r.fetches = e.fetchLatency.Count()
+	r.offsets = e.latestOffsets()
+	r.errors = e.ErrorCounts()
+}
+
+// Record emits one sample of every metric to each sink, then prunes the
+// history past retention at most once per metricsPruneInterval
+func (r *MetricsRecorder) Record() {
+	now := time.Now()
+	samples := r.collect(now)
+	if len(samples) > 0 {
+		for _, sink := range r.sinks {
+			if err := sink.Emit(r.engine.ctx, samples); err != nil {
+				log.Printf("[engine] emitting metrics to %s failed: %v", sink.Name(), err)
+			}
+		}
+	}
+
+	if r.history == nil || r.config.Retention <= 0 || now.Sub(r.lastPrune) < metricsPruneInterval {
+		return
+	}
+	r.lastPrune = now
+	if n, err := r.history.DeleteMetricsBefore(r.engine.ctx, now.Add(-r.config.Retention)); err != nil {
+		log.Printf("[engine] pruning metrics failed: %v", err)
+	} else if n > 0 {
+		log.Printf("[engine] pruned %d metric samples older than %s", n, r.config.Retention)
+	}
+}
+
+// collect measures every metric once; rates and error counts cover the
+// time since the previous call
+func (r *MetricsRecorder) collect(now time.Time) []store.MetricSample {
+	e := r.engine
+	var samples []store.MetricSample
+	add := func(name, label string, value float64) {
+		samples = append(samples, store.MetricSample{Time: now, Name: name, Label: label, Value: value})
+	}
+
+	if p99, ok := e.produceLatency.Percentile(99); ok {
+		add(MetricProduceLatency, "", float64(p99)/float64(time.Millisecond))
+	}
+	if p99, ok := e.fetchLatency.Percentile(99); ok {
+		add(MetricFetchLatency, "", float64(p99)/float64(time.Millisecond))
+	}
+	for group, lag := range e.GroupLags() {
+		add(MetricConsumerLag, group, float64(lag))
+	}
+	for topic, n := range e.scrubber.CorruptCounts() {
+		add(MetricCorruptRecords, topic, float64(n))
+	}
+	for _, t := range e.GetPendingQueue().TopicStats() {
+		add(MetricPendingFetches, t.Topic, float64(t.Waiting))
+		if t.Woken+t.TimedOut > 0 {
+			add(MetricPendingWait, t.Topic, t.WaitP99Ms)
+		}
+	}
+
+	produces := e.produceLatency.Count()
+	fetches := e.fetchLatency.Count()
+	offsets := e.latestOffsets()
+	errors := e.ErrorCounts()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if elapsed := now.Sub(r.last).Seconds(); elapsed > 0 {
+		add(MetricProduceRate, "", float64(produces-r.produces)/elapsed)
+		add(MetricFetchRate, "", float64(fetches-r.fetches)/elapsed)
+
+		// Topics deleted or truncated since the last round count as nothing
+		var in int64
+		for topic, latest := range offsets {
+			before, ok := r.offsets[topic]
+			if !ok {
+				before = -1 // created since the last round
+			}
+			if latest > before {
+				in += latest - before
+			}
+		}
+		add(MetricMessagesIn, "", float64(in)/elapsed)
+	}
+	for kind, n := range errors {
+		if d := n - r.errors[kind]; d > 0 {
+			add(MetricErrors, kind, float64(d))
+		}
+	}
+
+	r.last, r.produces, r.fetches, r.offsets, r.errors = now, produces, fetches, offsets, errors
+	return samples
+}
+
+// latestOffsets returns the latest offset of every topic
+func (e *Engine) latestOffsets() map[string]int64 {
+	offsets := make(map[string]int64)
+	for _, topic := range e.ListTopics() {
+		if latest, err := e.LatestOffset(topic); err == nil {
+			offsets[topic] = latest
+		}
+	}
+	return offsets
+}
@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// ============================================================================
+// Bulk offset resets
+//
+// A reset sets a group's committed offsets for a list of partitions at
+// once, each to an offset or to the first message at or after a timestamp,
+// like kafka-consumer-groups --reset-offsets --from-file. As with Kafka the
+// group must have no active members, and a reset planned against one
+// generation of the group is refused if it has rebalanced since.
+// ============================================================================
+
+// OffsetReset is a bulk reset of a group's committed offsets. A partition
+// is named in Offsets or Timestamps, not both.
+type OffsetReset struct {
+	Generation int32                          `json:"generation"` // generation the reset was planned against; -1 for any
+	Offsets    map[store.TopicPartition]int64 `json:"offsets,omitempty"`
+	Timestamps map[store.TopicPartition]int64 `json:"timestamps,omitempty"` // Unix ms
+	DryRun     bool                           `json:"dry_run,omitempty"`
+}
+
+// OffsetResetResult is what a reset did, or with DryRun would do
+type OffsetResetResult struct {
+	Group      string                         `json:"group"`
+	Generation int32                          `json:"generation"`
+	Previous   map[store.TopicPartition]int64 `json:"previous"` // committed offsets replaced; absent if there were none
+	Offsets    map[store.TopicPartition]int64 `json:"offsets"`
+	DryRun     bool                           `json:"dry_run,omitempty"`
+}
+
+// ResetOffsets resolves a reset's offsets, checks each lies within its
+// partition's log, and commits them together or not at all
+func (e *Engine) ResetOffsets(ctx context.Context, groupID string, reset OffsetReset) (*OffsetResetResult, error) {
+	if len(reset.Offsets)+len(reset.Timestamps) == 0 {
+		return nil, fmt.Errorf("%w: no partitions to reset", ErrInvalidOffset)
+	}
+	offsets := make(map[store.TopicPartition]int64, len(reset.Offsets)+len(reset.Timestamps))
+	var problems []string
+	for tp, offset := range reset.Offsets {
+		if err := e.checkResetOffset(ctx, tp, offset); err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		offsets[tp] = offset
+	}
+	for tp, ts := range reset.Timestamps {
+		if _, ok := reset.Offsets[tp]; ok {
+			problems = append(problems, fmt.Sprintf("%s: both an offset and a timestamp given", tp))
+			continue
+		}
+		offset, err := e.offsetAtTime(ctx, tp, ts)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		offsets[tp] = offset
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("%w: %s", ErrInvalidOffset, strings.Join(problems, "; "))
+	}
+
+	result := &OffsetResetResult{Group: groupID, Offsets: offsets, Previous: map[store.TopicPartition]int64{}, DryRun: reset.DryRun}
+	err := e.coordinator.WithInactiveGroup(groupID, reset.Generation, func(current int32) error {
+		result.Generation = current
+		if committed, err := e.groupStore.Offsets(groupID); err == nil {
+			for tp := range offsets {
+				if prev, ok := committed[tp]; ok {
+					result.Previous[tp] = prev
+				}
+			}
+		}
+		if reset.DryRun {
+			return nil
+		}
+		if _, err := e.groupStore.GetOrCreateGroup(ctx, groupID); err != nil {
+			return err
+		}
+		return e.groupStore.CommitOffsets(ctx, groupID, offsets)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !reset.DryRun {
+		log.Printf("[engine] reset %d offsets of group %s at generation %d", len(offsets), groupID, result.Generation)
+	}
+	return result, nil
+}
+
+// checkResetOffset checks offset lies between the partition's log start
+// and its end, the offset the next message will get
+func (e *Engine) checkResetOffset(ctx context.Context, tp store.TopicPartition, offset int64) error {
+	if !e.TopicExists(tp.Topic) || tp.Partition != 0 {
+		return fmt.Errorf("%s: no such partition", tp)
+	}
+	earliest, err := e.EarliestOffset(ctx, tp.Topic)
+	if err != nil {
+		return fmt.Errorf("%s: %v", tp, err)
+	}
+	latest, err := e.LatestOffset(tp.Topic)
+	if err != nil {
+		return fmt.Errorf("%s: %v", tp, err)
+	}
+	if offset < earliest || offset > latest+1 {
+		return fmt.Errorf("%s: offset %d outside the log (%d to %d)", tp, offset, earliest, latest+1)
+	}
+	return nil
+}
+
+// offsetAtTime returns the offset of the partition's first message at or
+// after ts (Unix ms), or the log end if every message is older
+func (e *Engine) offsetAtTime(ctx context.Context, tp store.TopicPartition, ts int64) (int64, error) {
+	if !e.TopicExists(tp.Topic) || tp.Partition != 0 {
+		return 0, fmt.Errorf("%s: no such partition", tp)
+	}
+	records, _, err := e.topicStore.ReadRange(ctx, tp.Topic, ts, math.MaxInt64, 0, 1)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", tp, err)
+	}
+	if len(records) > 0 {
+		return records[0].Offset, nil
+	}
+	latest, err := e.LatestOffset(tp.Topic)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", tp, err)
+	}
+	return latest + 1, nil
+}
@@ -1,73 +1,310 @@
 package engine
 
 import (
-	"net"
+	"slices"
+	"sort"
 	"sync"
 	"time"
-
-	"github.com/rizkyandriawan/monolog/internal/store"
 )
 
-// PendingFetch represents a parked fetch request
+// PendingFetch represents a parked fetch request: a Fetch that found less
+// than MinBytes and waits, up to its Deadline, for more to arrive. The
+// connection's handler blocks on ResponseChan, so responses on a
+// connection still go out in request order.
 type PendingFetch struct {
-	Conn          net.Conn
+	ConnID        uint64 // connection the fetch arrived on
 	CorrelationID int32
-	Topic         string
-	Partition     int32
-	Offset        int64
-	MaxBytes      int32
+	Partitions    []PendingPartition
+	MinBytes      int32
+	Isolation     int8
 	Deadline      time.Time
-	ResponseChan  chan FetchResult
+	Parked        time.Time        // set by Add
+	ResponseChan  chan FetchResult // buffered; closed if the fetch is dropped
+}
+
+// topics returns the topics the fetch reads, each once
+func (p *PendingFetch) topics() []string {
+	topics := make([]string, 0, 1)
+	for _, part := range p.Partitions {
+		if !slices.Contains(topics, part.Topic) {
+			topics = append(topics, part.Topic)
+		}
+	}
+	return topics
+}
+
+// PendingPartition is a partition a parked fetch reads, from Offset on
+type PendingPartition struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
 }
 
-// FetchResult is the result of a fetch operation
+// FetchResult is why a parked fetch was woken
 type FetchResult struct {
-	Records []store.Record
-	Error   error
+	TimedOut bool  // the deadline passed first
+	Error    error // checking for data failed
+}
+
+// FetchReadyFunc reports whether a parked fetch has enough data to answer
+type FetchReadyFunc func(p *PendingFetch) (bool, error)
+
+// PendingStats describes the pending queue. Orphaned counts fetches
+// dropped because their connection had already gone; evicted counts
+// fetches woken because a topic they read was deleted.
+type PendingStats struct {
+	Waiting     int   `json:"waiting"`
+	Connections int   `json:"connections"`
+	Orphaned    int64 `json:"orphaned"`
+	Evicted     int64 `json:"evicted"`
+}
+
+// pendingBuckets are the upper bounds of the buckets parked fetches' wait
+// times and remaining deadlines are counted in; a last bucket takes the rest
+var pendingBuckets = []time.Duration{
+	10 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond,
+	500 * time.Millisecond, time.Second, 5 * time.Second, 30 * time.Second,
+}
+
+// PendingBucket counts parked fetches up to a duration
+type PendingBucket struct {
+	Le    string `json:"le"` // upper bound, "+Inf" for the last bucket
+	Count int    `json:"count"`
 }
 
-// PendingQueue holds parked fetch requests
+// PendingTopicStats describes the parked fetches reading one topic. A fetch
+// reading several topics counts under each. Waits covers the most recent
+// fetches answered, woken by data or by their deadline; Deadlines covers
+// the fetches waiting now, by the time left until they give up.
+type PendingTopicStats struct {
+	Topic     string          `json:"topic"`
+	Waiting   int             `json:"waiting"`
+	Woken     int64           `json:"woken"`     // answered because data arrived, or the topic went
+	TimedOut  int64           `json:"timed_out"` // answered empty at their deadline
+	WaitP50Ms float64         `json:"wait_p50_ms"`
+	WaitP99Ms float64         `json:"wait_p99_ms"`
+	Waits     []PendingBucket `json:"waits"`
+	Deadlines []PendingBucket `json:"deadlines"`
+}
+
+// topicPending indexes the parked fetches reading a topic and keeps how
+// those answered fared
+type topicPending struct {
+	fetches  map[*PendingFetch]struct{}
+	woken    int64
+	timedOut int64
+	waits    LatencyTracker
+}
+
+// PendingQueue holds parked fetch requests, keyed by the ID of the
+// connection each arrived on. Connections register with Connect and
+// Disconnect, so a fetch whose connection is gone is dropped rather than
+// answered into nothing.
 type PendingQueue struct {
-	mu      sync.Mutex
-	pending []*PendingFetch
+	mu       sync.Mutex
+	waiters  map[uint64][]*PendingFetch // connection ID -> parked fetches
+	conns    map[uint64]bool            // open connections
+	topics   map[string]*topicPending   // topic -> parked fetches reading it
+	orphaned int64
+	evicted  int64
 }
 
 // NewPendingQueue creates a new PendingQueue
 func NewPendingQueue() *PendingQueue {
 	return &PendingQueue{
-		pending: make([]*PendingFetch, 0),
+		waiters: make(map[uint64][]*PendingFetch),
+		conns:   make(map[uint64]bool),
+		topics:  make(map[string]*topicPending),
 	}
 }
 
-// Add adds a fetch request to the queue
-func (q *PendingQueue) Add(req *PendingFetch) {
+// index files a newly parked fetch under its topics. Called with q.mu held.
+func (q *PendingQueue) index(p *PendingFetch) {
+	for _, topic := range p.topics() {
+		t := q.topics[topic]
+		if t == nil {
+			t = &topicPending{fetches: make(map[*PendingFetch]struct{})}
+			q.topics[topic] = t
+		}
+		t.fetches[p] = struct{}{}
+	}
+}
+
+// unindex removes a fetch that is no longer parked from its topics,
+// counting how it was answered: woken, timed out, or neither when it was
+// dropped unanswered. Called with q.mu held.
+func (q *PendingQueue) unindex(p *PendingFetch, woken, timedOut bool, now time.Time) {
+	for _, topic := range p.topics() {
+		t := q.topics[topic]
+		if t == nil {
+			continue
+		}
+		delete(t.fetches, p)
+		if woken || timedOut {
+			if woken {
+				t.woken++
+			} else {
+				t.timedOut++
+			}
+			t.waits.Observe(now.Sub(p.Parked))
+		}
+	}
+}
+
+// Connect registers an open connection
+func (q *PendingQueue) Connect(connID uint64) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
-	q.pending = append(q.pending, req)
+	q.conns[connID] = true
 }
 
-// Remove removes fetch requests for a connection
-func (q *PendingQueue) Remove(conn net.Conn) {
+// Disconnect unregisters a connection and drops its parked fetches,
+// closing their response channels
+func (q *PendingQueue) Disconnect(connID uint64) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
+	delete(q.conns, connID)
+	for _, p := range q.waiters[connID] {
+		q.unindex(p, false, false, time.Time{})
+		close(p.ResponseChan)
+	}
+	delete(q.waiters, connID)
+}
 
-	var remaining []*PendingFetch
-	for _, p := range q.pending {
-		if p.Conn != conn {
-			remaining = append(remaining, p)
-		} else {
-			// Close the response channel
-			close(p.ResponseChan)
-		}
+// Add parks a fetch request. A fetch from a connection that is not open
+// is orphaned: its response channel is closed and it is not parked.
+func (q *PendingQueue) Add(req *PendingFetch) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.conns[req.ConnID] {
+		close(req.ResponseChan)
+		q.orphaned++
+		return
+	}
+	if req.Parked.IsZero() {
+		req.Parked = time.Now()
 	}
-	q.pending = remaining
+	q.waiters[req.ConnID] = append(q.waiters[req.ConnID], req)
+	q.index(req)
 }
 
 // Len returns the number of pending requests
 func (q *PendingQueue) Len() int {
 	q.mu.Lock()
 	defer q.mu.Unlock()
-	return len(q.pending)
+	return q.count()
+}
+
+func (q *PendingQueue) count() int {
+	n := 0
+	for _, waiters := range q.waiters {
+		n += len(waiters)
+	}
+	return n
+}
+
+// Stats returns the queue's counts
+func (q *PendingQueue) Stats() PendingStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return PendingStats{
+		Waiting:     q.count(),
+		Connections: len(q.conns),
+		Orphaned:    q.orphaned,
+		Evicted:     q.evicted,
+	}
+}
+
+// TopicStats returns the parked fetches of every topic fetches have been
+// parked on, by topic name
+func (q *PendingQueue) TopicStats() []PendingTopicStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	stats := make([]PendingTopicStats, 0, len(q.topics))
+	for topic, t := range q.topics {
+		s := PendingTopicStats{
+			Topic:     topic,
+			Waiting:   len(t.fetches),
+			Woken:     t.woken,
+			TimedOut:  t.timedOut,
+			Waits:     pendingHistogram(t.waits.Samples()),
+			Deadlines: pendingHistogram(nil),
+		}
+		if p50, ok := t.waits.Percentile(50); ok {
+			s.WaitP50Ms = float64(p50) / float64(time.Millisecond)
+		}
+		if p99, ok := t.waits.Percentile(99); ok {
+			s.WaitP99Ms = float64(p99) / float64(time.Millisecond)
+		}
+		for p := range t.fetches {
+			s.Deadlines[pendingBucket(p.Deadline.Sub(now))].Count++
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Topic < stats[j].Topic })
+	return stats
+}
+
+// pendingHistogram counts durations into pendingBuckets
+func pendingHistogram(durations []time.Duration) []PendingBucket {
+	buckets := make([]PendingBucket, len(pendingBuckets)+1)
+	for i, le := range pendingBuckets {
+		buckets[i].Le = le.String()
+	}
+	buckets[len(pendingBuckets)].Le = "+Inf"
+	for _, d := range durations {
+		buckets[pendingBucket(d)].Count++
+	}
+	return buckets
+}
+
+// pendingBucket returns the index of the bucket d falls in
+func pendingBucket(d time.Duration) int {
+	return sort.Search(len(pendingBuckets), func(i int) bool { return d <= pendingBuckets[i] })
+}
+
+// EvictTopic wakes the fetches parked on a deleted topic at once, so
+// their handlers read again and report the topic gone instead of waiting
+// out their deadline. It returns how many were woken.
+func (q *PendingQueue) EvictTopic(topic string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	t := q.topics[topic]
+	if t == nil {
+		return 0
+	}
+	now := time.Now()
+	n := 0
+	for p := range t.fetches {
+		q.unpark(p)
+		q.unindex(p, true, false, now)
+		p.ResponseChan <- FetchResult{}
+		n++
+	}
+	delete(q.topics, topic)
+	q.evicted += int64(n)
+	return n
+}
+
+// unpark removes a fetch from its connection's waiters, reporting whether
+// it was there. Called with q.mu held.
+func (q *PendingQueue) unpark(req *PendingFetch) bool {
+	waiters := q.waiters[req.ConnID]
+	for i, p := range waiters {
+		if p == req {
+			waiters = append(waiters[:i:i], waiters[i+1:]...)
+			if len(waiters) == 0 {
+				delete(q.waiters, req.ConnID)
+			} else {
+				q.waiters[req.ConnID] = waiters
+			}
+			return true
+		}
+	}
+	return false
 }
 
 // GetAll returns all pending requests (for inspection)
@@ -75,47 +312,133 @@ func (q *PendingQueue) GetAll() []*PendingFetch {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	result := make([]*PendingFetch, len(q.pending))
-	copy(result, q.pending)
+	result := make([]*PendingFetch, 0, q.count())
+	for _, waiters := range q.waiters {
+		result = append(result, waiters...)
+	}
+	return result
+}
+
+// ForTopic returns the pending requests reading topic
+func (q *PendingQueue) ForTopic(topic string) []*PendingFetch {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	t := q.topics[topic]
+	if t == nil {
+		return nil
+	}
+	result := make([]*PendingFetch, 0, len(t.fetches))
+	for p := range t.fetches {
+		result = append(result, p)
+	}
 	return result
 }
 
-// Process processes all pending requests against the topic store
-// Returns the requests that were completed (either with data or timeout)
-func (q *PendingQueue) Process(topicStore store.TopicStoreInterface) []*PendingFetch {
+// Remove unparks a fetch its handler stopped waiting for. It is false if
+// the fetch was no longer parked.
+func (q *PendingQueue) Remove(req *PendingFetch) bool {
 	q.mu.Lock()
 	defer q.mu.Unlock()
+	if !q.unpark(req) {
+		return false
+	}
+	q.unindex(req, false, false, time.Time{})
+	return true
+}
 
+// Process wakes the parked fetches that ready says have enough data, or
+// whose deadline has passed, and returns them. Every woken fetch gets one
+// result on its ResponseChan.
+func (q *PendingQueue) Process(ready FetchReadyFunc) []*PendingFetch {
+	q.mu.Lock()
 	now := time.Now()
-	var stillPending []*PendingFetch
-	var completed []*PendingFetch
-
-	for _, p := range q.pending {
-		// Check timeout
-		if now.After(p.Deadline) {
-			p.ResponseChan <- FetchResult{Records: nil, Error: nil}
-			completed = append(completed, p)
+	var completed, waiting []*PendingFetch
+
+	for id, waiters := range q.waiters {
+		// Fetches outliving their connection have nobody to answer
+		if !q.conns[id] {
+			for _, p := range waiters {
+				q.unindex(p, false, false, now)
+				close(p.ResponseChan)
+			}
+			q.orphaned += int64(len(waiters))
+			delete(q.waiters, id)
 			continue
 		}
 
-		// Check for data
-		records, err := topicStore.Read(p.Topic, p.Offset, int(p.MaxBytes/1024)) // rough estimate
-		if err != nil {
-			p.ResponseChan <- FetchResult{Records: nil, Error: err}
-			completed = append(completed, p)
-			continue
+		var stillPending []*PendingFetch
+		for _, p := range waiters {
+			// Check timeout
+			if now.After(p.Deadline) {
+				q.unindex(p, false, true, now)
+				p.ResponseChan <- FetchResult{TimedOut: true}
+				completed = append(completed, p)
+				continue
+			}
+
+			stillPending = append(stillPending, p)
+			waiting = append(waiting, p)
 		}
 
-		if len(records) > 0 {
-			p.ResponseChan <- FetchResult{Records: records, Error: nil}
-			completed = append(completed, p)
-			continue
+		if len(stillPending) == 0 {
+			delete(q.waiters, id)
+		} else {
+			q.waiters[id] = stillPending
 		}
+	}
+	q.mu.Unlock()
 
-		// Keep waiting
-		stillPending = append(stillPending, p)
+	return append(completed, q.wake(waiting, ready)...)
+}
+
+// WakeTopic wakes the fetches parked on topic that ready says now have
+// enough data, and returns them. Appends call it through the fetch
+// scheduler, so a fetch is answered without waiting for the next pass.
+func (q *PendingQueue) WakeTopic(topic string, ready FetchReadyFunc) []*PendingFetch {
+	q.mu.Lock()
+	var waiting []*PendingFetch
+	if t := q.topics[topic]; t != nil {
+		waiting = make([]*PendingFetch, 0, len(t.fetches))
+		for p := range t.fetches {
+			waiting = append(waiting, p)
+		}
 	}
+	q.mu.Unlock()
 
-	q.pending = stillPending
+	return q.wake(waiting, ready)
+}
+
+// wake answers the fetches among waiting that ready says have enough
+// data. ready reads the store, so it runs without q.mu held; a fetch
+// unparked meanwhile, by its handler, its connection closing or another
+// wake, is left alone.
+func (q *PendingQueue) wake(waiting []*PendingFetch, ready FetchReadyFunc) []*PendingFetch {
+	type readyFetch struct {
+		p   *PendingFetch
+		err error
+	}
+	var found []readyFetch
+	for _, p := range waiting {
+		if ok, err := ready(p); ok || err != nil {
+			found = append(found, readyFetch{p, err})
+		}
+	}
+	if len(found) == 0 {
+		return nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	completed := make([]*PendingFetch, 0, len(found))
+	for _, f := range found {
+		if !q.unpark(f.p) {
+			continue
+		}
+		q.unindex(f.p, true, false, now)
+		f.p.ResponseChan <- FetchResult{Error: f.err}
+		completed = append(completed, f.p)
+	}
 	return completed
 }
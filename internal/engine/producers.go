@@ -0,0 +1,269 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+)
+
+// producerBatchHistory is how many appended batches are remembered per
+// producer and topic; Kafka clients keep at most 5 requests in flight with
+// idempotence on, so any retry is one of these
+const producerBatchHistory = 5
+
+// producerStateTTL is how long an idle producer's sequence state is kept,
+// Kafka's transactional.id.expiration.ms default
+const producerStateTTL = 24 * time.Hour
+
+// Producers allocates producer IDs and deduplicates idempotent batches:
+// each batch's (producerId, producerEpoch, baseSequence) is checked against
+// what that producer last appended to the topic, so a batch retried after
+// a lost response is answered with its original offset instead of being
+// appended twice. State is kept in memory only.
+type Producers struct {
+	mu            sync.Mutex
+	nextID        int64
+	transactional map[string]producerEpoch            // transactional ID -> producer
+	epochs        map[int64]int16                     // producer ID -> current epoch
+	states        map[string]map[int64]*producerState // topic -> producer ID -> state
+}
+
+type producerEpoch struct {
+	id    int64
+	epoch int16
+}
+
+// producerState is what one producer appended to one topic. Its mutex is
+// held from checking a batch until it is appended.
+type producerState struct {
+	mu       sync.Mutex
+	epoch    int16
+	batches  []producerBatch // oldest first
+	lastUsed time.Time
+}
+
+type producerBatch struct {
+	firstSeq   int32
+	lastSeq    int32
+	baseOffset int64
+}
+
+// NewProducers creates an empty producer registry. IDs start from the
+// time now so a restarted broker does not hand out IDs clients still hold.
+func NewProducers(now time.Time) *Producers {
+	return &Producers{
+		nextID:        now.UnixMilli() * 1000,
+		transactional: make(map[string]producerEpoch),
+		epochs:        make(map[int64]int16),
+		states:        make(map[string]map[int64]*producerState),
+	}
+}
+
+// Init answers InitProducerId. A transactional ID keeps its producer ID and
+// gets the next epoch, fencing older instances. A known producerID with
+// its current epoch also gets the next epoch (KIP-360); an unknown one, or
+// -1, gets a fresh ID at epoch 0.
+func (p *Producers) Init(transactionalID string, producerID int64, epoch int16) (int64, int16, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sweep()
+
+	if transactionalID != "" {
+		pe, ok := p.transactional[transactionalID]
+		if ok && producerID >= 0 && (producerID != pe.id || epoch != pe.epoch) {
+			return 0, 0, fmt.Errorf("%w: transactional ID %s is at producer %d epoch %d", ErrInvalidProducerEpoch, transactionalID, pe.id, pe.epoch)
+		}
+		if ok {
+			pe = p.bump(pe)
+		} else {
+			pe = p.allocate()
+		}
+		p.transactional[transactionalID] = pe
+		return pe.id, pe.epoch, nil
+	}
+
+	if producerID >= 0 {
+		current, ok := p.epochs[producerID]
+		if ok && current != epoch {
+			return 0, 0, fmt.Errorf("%w: producer %d is at epoch %d, not %d", ErrInvalidProducerEpoch, producerID, current, epoch)
+		}
+		if ok {
+			pe := p.bump(producerEpoch{producerID, current})
+			return pe.id, pe.epoch, nil
+		}
+	}
+	pe := p.allocate()
+	return pe.id, pe.epoch, nil
+}
+
+// restore registers a transactional ID's producer as saved before a restart
+func (p *Producers) restore(transactionalID string, producerID int64, epoch int16) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.transactional[transactionalID] = producerEpoch{producerID, epoch}
+	p.epochs[producerID] = epoch
+}
+
+// allocate hands out a new producer ID at epoch 0
+func (p *Producers) allocate() producerEpoch {
+	pe := producerEpoch{id: p.nextID}
+	p.nextID++
+	p.epochs[pe.id] = 0
+	return pe
+}
+
+// bump moves a producer to its next epoch, or to a new ID once the epoch
+// is exhausted
+func (p *Producers) bump(pe producerEpoch) producerEpoch {
+	if pe.epoch == math.MaxInt16-1 {
+		delete(p.epochs, pe.id)
+		return p.allocate()
+	}
+	pe.epoch++
+	p.epochs[pe.id] = pe.epoch
+	return pe
+}
+
+// sweep drops sequence state idle for longer than producerStateTTL
+func (p *Producers) sweep() {
+	cutoff := time.Now().Add(-producerStateTTL)
+	for topic, states := range p.states {
+		for id, st := range states {
+			st.mu.Lock()
+			idle := st.lastUsed.Before(cutoff)
+			st.mu.Unlock()
+			if idle {
+				delete(states, id)
+			}
+		}
+		if len(states) == 0 {
+			delete(p.states, topic)
+		}
+	}
+}
+
+// state returns a producer's state on topic, created empty if new
+func (p *Producers) state(topic string, producerID int64) *producerState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	states, ok := p.states[topic]
+	if !ok {
+		states = make(map[int64]*producerState)
+		p.states[topic] = states
+	}
+	st, ok := states[producerID]
+	if !ok {
+		st = &producerState{}
+		states[producerID] = st
+	}
+	return st
+}
+
+// batchSequence is the idempotence header of a produce request's batches
+type batchSequence struct {
+	producerID    int64
+	epoch         int16
+	firstSeq      int32
+	lastSeq       int32
+	transactional bool
+}
+
+// sequenceOf reads the producer and sequence range of a produce request.
+// It is false for batches not written by an idempotent producer.
+func sequenceOf(data []byte) (batchSequence, bool) {
+	batches, err := kafkaproto.SplitRecordBatches(data)
+	if err != nil || len(batches) == 0 {
+		return batchSequence{}, false
+	}
+	first, last := batches[0], batches[len(batches)-1]
+	if first.ProducerID < 0 || first.BaseSequence < 0 {
+		return batchSequence{}, false
+	}
+	return batchSequence{
+		producerID:    first.ProducerID,
+		epoch:         first.ProducerEpoch,
+		firstSeq:      first.BaseSequence,
+		lastSeq:       addSequence(last.BaseSequence, last.LastOffsetDelta),
+		transactional: first.Attributes&kafkaproto.BatchAttrTransactional != 0,
+	}, true
+}
+
+// addSequence adds n to a sequence number, wrapping past MaxInt32 to 0 as
+// Kafka producers do
+func addSequence(seq, n int32) int32 {
+	if seq > math.MaxInt32-n {
+		return n - (math.MaxInt32 - seq) - 1
+	}
+	return seq + n
+}
+
+// check decides what to do with a batch: append it, or answer it with the
+// offset it was appended at before. The caller holds st.mu.
+func (st *producerState) check(seq batchSequence) (int64, bool, error) {
+	if len(st.batches) == 0 {
+		return 0, false, nil
+	}
+	switch {
+	case seq.epoch < st.epoch:
+		return 0, false, fmt.Errorf("%w: producer %d epoch %d is fenced by epoch %d", ErrInvalidProducerEpoch, seq.producerID, seq.epoch, st.epoch)
+	case seq.epoch > st.epoch:
+		if seq.firstSeq != 0 {
+			return 0, false, fmt.Errorf("%w: producer %d epoch %d must start at sequence 0, got %d", ErrOutOfOrderSequence, seq.producerID, seq.epoch, seq.firstSeq)
+		}
+		return 0, false, nil
+	}
+
+	for _, b := range st.batches {
+		if b.firstSeq == seq.firstSeq && b.lastSeq == seq.lastSeq {
+			return b.baseOffset, true, nil
+		}
+	}
+	last := st.batches[len(st.batches)-1]
+	if want := addSequence(last.lastSeq, 1); seq.firstSeq != want {
+		return 0, false, fmt.Errorf("%w: producer %d expected sequence %d, got %d", ErrOutOfOrderSequence, seq.producerID, want, seq.firstSeq)
+	}
+	return 0, false, nil
+}
+
+// appended records a batch as appended at baseOffset. The caller holds st.mu.
+func (st *producerState) appended(seq batchSequence, baseOffset int64) {
+	if seq.epoch != st.epoch {
+		st.batches = st.batches[:0]
+		st.epoch = seq.epoch
+	}
+	st.batches = append(st.batches, producerBatch{seq.firstSeq, seq.lastSeq, baseOffset})
+	if len(st.batches) > producerBatchHistory {
+		st.batches = st.batches[len(st.batches)-producerBatchHistory:]
+	}
+	st.lastUsed = time.Now()
+}
+
+// --- Engine ---
+
+// InitProducerID allocates a producer ID, or bumps the epoch of an
+// existing one, for InitProducerId. A transactional ID also gets its
+// transaction state, with timeoutMs as the transaction timeout.
+func (e *Engine) InitProducerID(ctx context.Context, transactionalID string, timeoutMs int32, producerID int64, epoch int16) (int64, int16, error) {
+	var id int64
+	var newEpoch int16
+	var err error
+	if transactionalID != "" {
+		id, newEpoch, err = e.txnCoord.Init(ctx, transactionalID, timeoutMs, producerID, epoch)
+	} else {
+		id, newEpoch, err = e.producers.Init("", producerID, epoch)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	if newEpoch == 0 {
+		log.Printf("[engine] allocated producer ID %d", id)
+	} else {
+		log.Printf("[engine] producer %d bumped to epoch %d", id, newEpoch)
+	}
+	return id, newEpoch, nil
+}
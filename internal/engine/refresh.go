@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// RefreshResult is what a store cache refresh found
+type RefreshResult struct {
+	Topics *store.RefreshReport `json:"topics,omitempty"` // nil when the topic store was unchanged
+	Groups *store.RefreshReport `json:"groups,omitempty"` // nil when the group store was unchanged
+}
+
+// Refresh resyncs the stores' in-memory caches with storage. Unless force
+// is set, a store is only reloaded when storage reports a write by someone
+// else since its last refresh.
+func (e *Engine) Refresh(ctx context.Context, force bool) (*RefreshResult, error) {
+	topics, topicsOK := e.topicStore.(store.Refresher)
+	groups, groupsOK := e.groupStore.(store.Refresher)
+	if !topicsOK && !groupsOK {
+		return nil, fmt.Errorf("storage backend does not support cache refresh")
+	}
+
+	result := &RefreshResult{}
+	var err error
+	if topicsOK {
+		if result.Topics, err = refreshStore(ctx, topics, force); err != nil {
+			return nil, fmt.Errorf("refresh topics: %w", err)
+		}
+	}
+	if groupsOK {
+		if result.Groups, err = refreshStore(ctx, groups, force); err != nil {
+			return nil, fmt.Errorf("refresh groups: %w", err)
+		}
+	}
+
+	if r := result.Topics; r != nil {
+		for _, name := range r.Added {
+			e.events.Publish(Event{Type: EventTopicCreated, Topic: name})
+		}
+		for _, name := range r.Removed {
+			e.events.Publish(Event{Type: EventTopicDeleted, Topic: name})
+		}
+		if r.Changed() {
+			log.Printf("[engine] topic cache refreshed: %d added, %d removed, %d updated",
+				len(r.Added), len(r.Removed), len(r.Updated))
+		}
+	}
+	if r := result.Groups; r != nil {
+		for _, id := range r.Removed {
+			e.events.Publish(Event{Type: EventGroupDeleted, Group: id})
+		}
+		if r.Changed() {
+			log.Printf("[engine] group cache refreshed: %d added, %d removed, %d updated",
+				len(r.Added), len(r.Removed), len(r.Updated))
+		}
+	}
+	return result, nil
+}
+
+func refreshStore(ctx context.Context, r store.Refresher, force bool) (*store.RefreshReport, error) {
+	if !force {
+		changed, err := r.Changed(ctx)
+		if err != nil || !changed {
+			return nil, err
+		}
+	}
+	return r.Refresh(ctx)
+}
+
+// RefreshScheduler checks storage for writes by other processes on a timer
+// and reloads the store caches when it finds any
+type RefreshScheduler struct {
+	engine   *Engine
+	ticker   *time.Ticker
+	interval time.Duration
+	stopChan chan struct{}
+	monitor  loopMonitor
+}
+
+// NewRefreshScheduler creates a new RefreshScheduler
+func NewRefreshScheduler(engine *Engine, interval time.Duration) *RefreshScheduler {
+	return &RefreshScheduler{
+		engine:   engine,
+		interval: interval,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start starts the scheduler. A zero interval disables it.
+func (s *RefreshScheduler) Start() {
+	if s.interval <= 0 {
+		return
+	}
+	s.ticker = time.NewTicker(s.interval)
+	s.monitor.start(s.interval)
+	go s.loop()
+}
+
+// Stop stops the scheduler
+func (s *RefreshScheduler) Stop() {
+	if s.ticker != nil {
+		s.ticker.Stop()
+	}
+	s.monitor.stop()
+	select {
+	case <-s.stopChan:
+	default:
+		close(s.stopChan)
+	}
+}
+
+func (s *RefreshScheduler) loop() {
+	for {
+		select {
+		case <-s.ticker.C:
+			s.engine.safely("refresh scheduler", s.refresh)
+			s.monitor.tick()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+func (s *RefreshScheduler) refresh() {
+	if _, err := s.engine.Refresh(s.engine.ctx, false); err != nil && s.engine.ctx.Err() == nil {
+		log.Printf("[engine] cache refresh failed: %v", err)
+	}
+}
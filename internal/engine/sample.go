@@ -0,0 +1,213 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"unicode/utf8"
+)
+
+// ============================================================================
+// Sampling
+//
+// A sample of a topic's messages with a schema inferred from their JSON
+// values, so a developer can see what an unfamiliar topic holds without
+// reading it end to end.
+// ============================================================================
+
+// MaxSampleSize caps how many messages one sample returns
+const MaxSampleSize = 1000
+
+// Sample modes
+const (
+	SampleRecent = "recent" // the last n messages
+	SampleRandom = "random" // n messages at random offsets across the log
+)
+
+// Value encodings a sample reports
+const (
+	EncodingJSON   = "json"
+	EncodingText   = "text"
+	EncodingBinary = "binary"
+)
+
+// SchemaField is one field path seen in sampled JSON values. Paths are
+// dotted; [] stands for any element of an array and $ for the value
+// itself.
+type SchemaField struct {
+	Path      string         `json:"path"`
+	Types     map[string]int `json:"types"`     // JSON type -> occurrences
+	Count     int            `json:"count"`     // JSON values containing the path
+	Frequency float64        `json:"frequency"` // Count over JSON values sampled
+}
+
+// InferredSchema summarizes the values of sampled messages
+type InferredSchema struct {
+	Messages  int            `json:"messages"`
+	Encodings map[string]int `json:"encodings"` // json, text, binary -> messages
+	Fields    []SchemaField  `json:"fields"`    // sorted by path
+}
+
+// SampleMessages returns up to n of topic's messages in offset order, the
+// most recent ones or ones picked at random across the log
+func (e *Engine) SampleMessages(ctx context.Context, topic string, n int, mode string) ([]Message, error) {
+	n = min(n, MaxSampleSize)
+	if n <= 0 {
+		return []Message{}, nil
+	}
+	earliest, err := e.EarliestOffset(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := e.LatestOffset(topic)
+	if err != nil {
+		return nil, err
+	}
+	if latest < earliest {
+		return []Message{}, nil
+	}
+
+	switch mode {
+	case SampleRecent:
+		return e.sampleRange(ctx, topic, max(earliest, latest-int64(n)+1), n)
+	case SampleRandom:
+		if latest-earliest+1 <= int64(n) {
+			return e.sampleRange(ctx, topic, earliest, n)
+		}
+		return e.sampleRandom(ctx, topic, earliest, latest, n)
+	default:
+		return nil, fmt.Errorf("unknown sample mode %q", mode)
+	}
+}
+
+// sampleRange reads up to n messages from offset from on
+func (e *Engine) sampleRange(ctx context.Context, topic string, from int64, n int) ([]Message, error) {
+	msgs := []Message{}
+	for len(msgs) < n {
+		records, err := e.Fetch(ctx, topic, from, n-len(msgs))
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			break
+		}
+		for _, rec := range records {
+			for _, msg := range storedMessages(topic, rec) {
+				if msg.Offset >= from && len(msgs) < n {
+					msgs = append(msgs, msg)
+				}
+			}
+			from = max(from, rec.LastOffset+1)
+		}
+	}
+	return msgs, nil
+}
+
+// sampleRandom reads the message at each of n distinct random offsets in
+// [earliest, latest]. An offset that holds no message, because it was a
+// transaction marker or compacted away, yields the next one.
+func (e *Engine) sampleRandom(ctx context.Context, topic string, earliest, latest int64, n int) ([]Message, error) {
+	picked := make(map[int64]bool, n)
+	for len(picked) < n {
+		picked[earliest+rand.Int64N(latest-earliest+1)] = true
+	}
+	offsets := make([]int64, 0, n)
+	for off := range picked {
+		offsets = append(offsets, off)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+
+	msgs := []Message{}
+	seen := make(map[int64]bool, n)
+	for _, off := range offsets {
+		records, err := e.Fetch(ctx, topic, off, 1)
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range records {
+			for _, msg := range storedMessages(topic, rec) {
+				if msg.Offset >= off && !seen[msg.Offset] {
+					seen[msg.Offset] = true
+					msgs = append(msgs, msg)
+					break
+				}
+			}
+		}
+	}
+	return msgs, nil
+}
+
+// InferSchema infers the structure of the JSON values among msgs. Values
+// that are not JSON are only counted, as text or binary.
+func InferSchema(msgs []Message) InferredSchema {
+	schema := InferredSchema{Messages: len(msgs), Encodings: map[string]int{}, Fields: []SchemaField{}}
+	fields := make(map[string]*SchemaField)
+
+	for _, msg := range msgs {
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader(msg.Value))
+		dec.UseNumber()
+		if len(bytes.TrimSpace(msg.Value)) == 0 || dec.Decode(&v) != nil || dec.More() {
+			if utf8.Valid(msg.Value) {
+				schema.Encodings[EncodingText]++
+			} else {
+				schema.Encodings[EncodingBinary]++
+			}
+			continue
+		}
+		schema.Encodings[EncodingJSON]++
+
+		seen := make(map[string]bool)
+		walkJSON("$", v, func(path, typ string) {
+			f := fields[path]
+			if f == nil {
+				f = &SchemaField{Path: path, Types: map[string]int{}}
+				fields[path] = f
+			}
+			f.Types[typ]++
+			if !seen[path] {
+				seen[path] = true
+				f.Count++
+			}
+		})
+	}
+
+	for _, f := range fields {
+		f.Frequency = float64(f.Count) / float64(schema.Encodings[EncodingJSON])
+		schema.Fields = append(schema.Fields, *f)
+	}
+	sort.Slice(schema.Fields, func(i, j int) bool { return schema.Fields[i].Path < schema.Fields[j].Path })
+	return schema
+}
+
+// walkJSON calls fn with the path and JSON type of v and of everything
+// nested in it
+func walkJSON(path string, v interface{}, fn func(path, typ string)) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		fn(path, "object")
+		for k, child := range v {
+			walkJSON(path+"."+k, child, fn)
+		}
+	case []interface{}:
+		fn(path, "array")
+		for _, child := range v {
+			walkJSON(path+"[]", child, fn)
+		}
+	case string:
+		fn(path, "string")
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			fn(path, "integer")
+		} else {
+			fn(path, "number")
+		}
+	case bool:
+		fn(path, "boolean")
+	case nil:
+		fn(path, "null")
+	}
+}
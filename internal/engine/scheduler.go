@@ -7,12 +7,15 @@ import (
 	"github.com/rizkyandriawan/monolog/internal/config"
 )
 
-// FetchScheduler processes pending fetch requests on a timer
+// FetchScheduler processes pending fetch requests on a timer, and wakes
+// the fetches parked on a topic as soon as an append to it commits
 type FetchScheduler struct {
 	engine   *Engine
 	ticker   *time.Ticker
 	interval time.Duration
+	appended chan string // topics appended to since the loop last looked
 	stopChan chan struct{}
+	monitor  loopMonitor
 }
 
 // NewFetchScheduler creates a new FetchScheduler
@@ -20,13 +23,25 @@ func NewFetchScheduler(engine *Engine, interval time.Duration) *FetchScheduler {
 	return &FetchScheduler{
 		engine:   engine,
 		interval: interval,
+		appended: make(chan string, 256),
 		stopChan: make(chan struct{}),
 	}
 }
 
+// Appended tells the scheduler an append to topic committed. It never
+// blocks: when the loop is behind, the topic's fetches wait for the next
+// tick instead.
+func (s *FetchScheduler) Appended(topic string) {
+	select {
+	case s.appended <- topic:
+	default:
+	}
+}
+
 // Start starts the scheduler
 func (s *FetchScheduler) Start() {
 	s.ticker = time.NewTicker(s.interval)
+	s.monitor.start(s.interval)
 	go s.loop()
 }
 
@@ -35,6 +50,7 @@ func (s *FetchScheduler) Stop() {
 	if s.ticker != nil {
 		s.ticker.Stop()
 	}
+	s.monitor.stop()
 	close(s.stopChan)
 }
 
@@ -42,7 +58,12 @@ func (s *FetchScheduler) loop() {
 	for {
 		select {
 		case <-s.ticker.C:
-			s.process()
+			s.engine.safely("fetch scheduler", s.process)
+			s.monitor.tick()
+		case topic := <-s.appended:
+			s.engine.safely("fetch scheduler", func() {
+				s.engine.GetPendingQueue().WakeTopic(topic, s.engine.fetchReady)
+			})
 		case <-s.stopChan:
 			return
 		}
@@ -51,9 +72,8 @@ func (s *FetchScheduler) loop() {
 
 func (s *FetchScheduler) process() {
 	queue := s.engine.GetPendingQueue()
-	topicStore := s.engine.GetTopicStore()
 
-	completed := queue.Process(topicStore)
+	completed := queue.Process(s.engine.fetchReady)
 	if len(completed) > 0 && s.engine.config.Logging.Level == "debug" {
 		log.Printf("[scheduler] processed %d pending fetch requests", len(completed))
 	}
@@ -65,6 +85,7 @@ type RetentionScheduler struct {
 	ticker   *time.Ticker
 	config   config.RetentionConfig
 	stopChan chan struct{}
+	monitor  loopMonitor
 }
 
 // NewRetentionScheduler creates a new RetentionScheduler
@@ -82,6 +103,7 @@ func (s *RetentionScheduler) Start() {
 		return
 	}
 	s.ticker = time.NewTicker(s.config.CheckInterval)
+	s.monitor.start(s.config.CheckInterval)
 	go s.loop()
 }
 
@@ -90,6 +112,7 @@ func (s *RetentionScheduler) Stop() {
 	if s.ticker != nil {
 		s.ticker.Stop()
 	}
+	s.monitor.stop()
 	select {
 	case <-s.stopChan:
 		// already closed
@@ -102,7 +125,8 @@ func (s *RetentionScheduler) loop() {
 	for {
 		select {
 		case <-s.ticker.C:
-			s.cleanup()
+			s.engine.safely("retention scheduler", s.cleanup)
+			s.monitor.tick()
 		case <-s.stopChan:
 			return
 		}
@@ -110,12 +134,16 @@ func (s *RetentionScheduler) loop() {
 }
 
 func (s *RetentionScheduler) cleanup() {
-	cutoff := time.Now().Add(-s.config.MaxAge)
+	now := s.engine.Clock().Peek()
 	topicStore := s.engine.GetTopicStore()
 
 	topics := s.engine.ListTopics()
 	for _, topic := range topics {
-		deleted, err := topicStore.DeleteBefore(topic, cutoff)
+		maxAge, ok := s.engine.topicRetention(topic)
+		if !ok {
+			continue
+		}
+		deleted, err := topicStore.DeleteBefore(s.engine.ctx, topic, now.Add(-maxAge))
 		if err != nil {
 			log.Printf("[retention] cleanup failed for topic %s: %v", topic, err)
 			continue
@@ -126,12 +154,15 @@ func (s *RetentionScheduler) cleanup() {
 	}
 }
 
-// MemberExpirationScheduler cleans up expired consumer group members
+// MemberExpirationScheduler cleans up expired consumer group members.
+// Each member expires after its own session timeout; the scheduler's
+// timeout (the shortest allowed) only sets how often it checks.
 type MemberExpirationScheduler struct {
 	engine   *Engine
 	ticker   *time.Ticker
 	timeout  time.Duration
 	stopChan chan struct{}
+	monitor  loopMonitor
 }
 
 // NewMemberExpirationScheduler creates a new MemberExpirationScheduler
@@ -151,6 +182,7 @@ func (s *MemberExpirationScheduler) Start() {
 		interval = time.Second
 	}
 	s.ticker = time.NewTicker(interval)
+	s.monitor.start(interval)
 	go s.loop()
 }
 
@@ -159,6 +191,7 @@ func (s *MemberExpirationScheduler) Stop() {
 	if s.ticker != nil {
 		s.ticker.Stop()
 	}
+	s.monitor.stop()
 	select {
 	case <-s.stopChan:
 	default:
@@ -170,7 +203,8 @@ func (s *MemberExpirationScheduler) loop() {
 	for {
 		select {
 		case <-s.ticker.C:
-			s.expire()
+			s.engine.safely("member expiration scheduler", s.expire)
+			s.monitor.tick()
 		case <-s.stopChan:
 			return
 		}
@@ -178,6 +212,12 @@ func (s *MemberExpirationScheduler) loop() {
 }
 
 func (s *MemberExpirationScheduler) expire() {
-	// This would call groupStore.ExpireMembers but we need access to it
-	// For now, this is a placeholder - the engine should expose this
+	expired, err := s.engine.ExpireMembers()
+	if err != nil {
+		log.Printf("[scheduler] member expiration failed: %v", err)
+		return
+	}
+	for _, m := range expired {
+		log.Printf("[scheduler] expired member %s (session timeout)", m)
+	}
 }
@@ -0,0 +1,268 @@
+package engine
+
+import (
+	"errors"
+	"log"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// ============================================================================
+// Scrubbing
+//
+// The scrubber reads every stored record back in the background and checks
+// it against the checksum stored with it, catching data the disk or a
+// stray writer changed before a consumer trips over it. A pass walks the
+// topics one batch at a time, resting storage.scrub.pause between reads so
+// produces and fetches are barely slowed, and the next starts
+// storage.scrub.interval after it finishes. Records found
+// corrupt are logged, counted in the corrupt_records metric and listed in
+// the report until a later pass no longer finds them.
+// ============================================================================
+
+// ErrScrubUnavailable is returned when the storage backend keeps no
+// record checksums
+var ErrScrubUnavailable = errors.New("storage backend does not keep record checksums")
+
+// ScrubPass is one scrub of every topic
+type ScrubPass struct {
+	Started    time.Time         `json:"started"`
+	Finished   *time.Time        `json:"finished,omitempty"`
+	Topics     int               `json:"topics"`
+	Rows       int64             `json:"rows"` // a row holds a record or a whole stored batch
+	Bytes      int64             `json:"bytes"`
+	Backfilled int64             `json:"backfilled"` // rows stored before checksums were kept, given one
+	Corrupt    int               `json:"corrupt"`
+	Errors     map[string]string `json:"errors,omitempty"` // topics that could not be read, by name
+}
+
+// ScrubReport is what the scrubber has found
+type ScrubReport struct {
+	Enabled  bool                  `json:"enabled"`
+	Interval string                `json:"interval,omitempty"` // between scheduled passes; empty when only run on demand
+	Passes   int64                 `json:"passes"`             // completed since startup
+	Current  *ScrubPass            `json:"current,omitempty"`
+	Last     *ScrubPass            `json:"last,omitempty"`
+	Corrupt  []store.CorruptRecord `json:"corrupt"`
+}
+
+// Scrubber verifies stored records against their checksums
+type Scrubber struct {
+	engine   *Engine
+	config   config.StorageScrubConfig
+	verifier store.ChecksumVerifier // nil when the backend keeps no checksums
+	trigger  chan struct{}
+	stopChan chan struct{}
+	done     chan struct{}
+
+	mu      sync.Mutex
+	passes  int64
+	current *ScrubPass
+	last    *ScrubPass
+	corrupt map[string][]store.CorruptRecord // by topic, as its latest scrub found
+}
+
+// NewScrubber creates the scrubber
+func NewScrubber(engine *Engine, cfg config.StorageScrubConfig) *Scrubber {
+	s := &Scrubber{
+		engine:   engine,
+		config:   cfg,
+		trigger:  make(chan struct{}, 1),
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+		corrupt:  make(map[string][]store.CorruptRecord),
+	}
+	s.verifier, _ = engine.topicStore.(store.ChecksumVerifier)
+	return s
+}
+
+// Start starts scrubbing. With no interval it only scrubs when triggered.
+func (s *Scrubber) Start() {
+	if s.verifier == nil {
+		close(s.done)
+		return
+	}
+	go func() {
+		defer close(s.done)
+		var timer <-chan time.Time
+		for {
+			if s.config.Interval > 0 {
+				timer = time.After(s.config.Interval)
+			}
+			select {
+			case <-timer:
+			case <-s.trigger:
+			case <-s.stopChan:
+				return
+			}
+			s.engine.safely("scrubber", s.pass)
+		}
+	}()
+}
+
+// Stop stops scrubbing, abandoning a pass in progress
+func (s *Scrubber) Stop() {
+	select {
+	case <-s.stopChan:
+	default:
+		close(s.stopChan)
+	}
+	<-s.done
+}
+
+// Trigger starts a pass now, unless one is already running or queued
+func (s *Scrubber) Trigger() error {
+	if s.verifier == nil {
+		return ErrScrubUnavailable
+	}
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Report returns the scrubber's progress and the corruption it has found
+func (s *Scrubber) Report() ScrubReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	report := ScrubReport{Enabled: s.verifier != nil, Passes: s.passes, Corrupt: s.corruptLocked()}
+	if report.Enabled && s.config.Interval > 0 {
+		report.Interval = s.config.Interval.String()
+	}
+	if s.current != nil {
+		current := *s.current
+		if current.Errors != nil {
+			current.Errors = maps.Clone(current.Errors)
+		}
+		report.Current = &current
+	}
+	report.Last = s.last
+	return report
+}
+
+// CorruptCounts returns how many corrupt rows each topic has, as of its
+// latest scrub
+func (s *Scrubber) CorruptCounts() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := make(map[string]int, len(s.corrupt))
+	for topic, records := range s.corrupt {
+		counts[topic] = len(records)
+	}
+	return counts
+}
+
+func (s *Scrubber) corruptLocked() []store.CorruptRecord {
+	all := []store.CorruptRecord{}
+	for _, records := range s.corrupt {
+		all = append(all, records...)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Topic != all[j].Topic {
+			return all[i].Topic < all[j].Topic
+		}
+		return all[i].Offset < all[j].Offset
+	})
+	return all
+}
+
+// pass scrubs every topic once
+func (s *Scrubber) pass() {
+	pass := &ScrubPass{Started: time.Now()}
+	s.mu.Lock()
+	s.current = pass
+	s.mu.Unlock()
+
+	topics := s.engine.ListTopics()
+	sort.Strings(topics)
+	for _, topic := range topics {
+		if !s.scrubTopic(topic, pass) {
+			s.mu.Lock()
+			s.current = nil
+			s.mu.Unlock()
+			return
+		}
+	}
+
+	finished := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pass.Finished = &finished
+	// Forget topics deleted since their last scrub
+	for topic := range s.corrupt {
+		if !s.engine.TopicExists(topic) {
+			delete(s.corrupt, topic)
+		}
+	}
+	s.current, s.last = nil, pass
+	s.passes++
+	log.Printf("[engine] scrubbed %d rows in %d topics in %s: %d corrupt",
+		pass.Rows, pass.Topics, finished.Sub(pass.Started).Round(time.Millisecond), pass.Corrupt)
+}
+
+// scrubTopic verifies one topic batch by batch, adding to pass. It returns
+// false if the scrubber was stopped meanwhile.
+func (s *Scrubber) scrubTopic(topic string, pass *ScrubPass) bool {
+	ctx := s.engine.ctx
+	var corrupt []store.CorruptRecord
+	offset := int64(0)
+	for {
+		page, err := s.verifier.VerifyChecksums(ctx, topic, offset, s.config.BatchSize)
+		if ctx.Err() != nil {
+			return false
+		}
+		if err != nil {
+			if !errors.Is(err, store.ErrTopicNotFound) {
+				log.Printf("[engine] scrubbing %s: %v", topic, err)
+				s.mu.Lock()
+				if pass.Errors == nil {
+					pass.Errors = make(map[string]string)
+				}
+				pass.Errors[topic] = err.Error()
+				s.mu.Unlock()
+			}
+			return true
+		}
+		for _, c := range page.Corrupt {
+			log.Printf("[engine] scrub: %s offset %d does not match its checksum (stored %08x, read %08x)",
+				topic, c.Offset, c.Expected, c.Actual)
+		}
+		corrupt = append(corrupt, page.Corrupt...)
+
+		s.mu.Lock()
+		pass.Rows += int64(page.Rows)
+		pass.Bytes += page.Bytes
+		pass.Backfilled += int64(page.Backfilled)
+		pass.Corrupt += len(page.Corrupt)
+		if page.Next < 0 {
+			pass.Topics++
+			if len(corrupt) > 0 {
+				s.corrupt[topic] = corrupt
+			} else {
+				delete(s.corrupt, topic)
+			}
+		}
+		s.mu.Unlock()
+		if page.Next < 0 {
+			return true
+		}
+		offset = page.Next
+
+		select {
+		case <-time.After(s.config.Pause):
+		case <-s.stopChan:
+			return false
+		}
+	}
+}
+
+// Scrubber returns the engine's scrubber
+func (e *Engine) Scrubber() *Scrubber {
+	return e.scrubber
+}
@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// statsdMaxPacket keeps datagrams under a typical path MTU; larger rounds
+// are split across several
+const statsdMaxPacket = 1432
+
+// metricLabelTags names the tag a sample's label becomes under DogStatsD
+var metricLabelTags = map[string]string{
+	MetricConsumerLag:    "group",
+	MetricErrors:         "kind",
+	MetricPendingFetches: "topic",
+	MetricPendingWait:    "topic",
+}
+
+// StatsDSink sends metric samples to a StatsD or DogStatsD agent over UDP.
+// Error counts go out as counters and everything else as gauges. Plain
+// StatsD has no tags, so a sample's label is folded into its name
+// (monolog.consumer_lag.orders); DogStatsD gets it as a tag
+// (monolog.consumer_lag:12|g|#group:orders).
+type StatsDSink struct {
+	config config.StatsDConfig
+	conn   net.Conn
+	tags   string // configured tags, joined
+}
+
+// NewStatsDSink creates a sink sending to cfg.Addr
+func NewStatsDSink(cfg config.StatsDConfig) (*StatsDSink, error) {
+	conn, err := net.Dial("udp", cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("statsd address %s: %w", cfg.Addr, err)
+	}
+	tags := make([]string, len(cfg.Tags))
+	for i, t := range cfg.Tags {
+		tags[i] = statsdTagValue(t)
+	}
+	return &StatsDSink{config: cfg, conn: conn, tags: strings.Join(tags, ",")}, nil
+}
+
+func (s *StatsDSink) Name() string { return "statsd " + s.config.Addr }
+
+// Emit sends samples, as many per datagram as fit
+func (s *StatsDSink) Emit(ctx context.Context, samples []store.MetricSample) error {
+	var packet bytes.Buffer
+	for _, m := range samples {
+		line := s.line(m)
+		if packet.Len() > 0 && packet.Len()+1+len(line) > statsdMaxPacket {
+			if _, err := s.conn.Write(packet.Bytes()); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if packet.Len() == 0 {
+		return nil
+	}
+	_, err := s.conn.Write(packet.Bytes())
+	return err
+}
+
+// line formats one sample in the StatsD line protocol
+func (s *StatsDSink) line(m store.MetricSample) string {
+	kind := "g"
+	if m.Name == MetricErrors {
+		kind = "c"
+	}
+	name := s.config.Prefix + m.Name
+	value := strconv.FormatFloat(m.Value, 'f', -1, 64)
+
+	if !s.config.DogStatsD {
+		if m.Label != "" {
+			name += "." + statsdName(m.Label)
+		}
+		return name + ":" + value + "|" + kind
+	}
+
+	tags := s.tags
+	if m.Label != "" {
+		key := metricLabelTags[m.Name]
+		if key == "" {
+			key = "label"
+		}
+		if tags != "" {
+			tags += ","
+		}
+		tags += key + ":" + statsdTagValue(m.Label)
+	}
+	line := name + ":" + value + "|" + kind
+	if tags != "" {
+		line += "|#" + tags
+	}
+	return line
+}
+
+// statsdName makes s safe as a metric name segment
+func statsdName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// statsdTagValue drops the characters that delimit DogStatsD fields
+func statsdTagValue(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ',', '|', '#', '\n':
+			return '_'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,332 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// Topic config names, spelled the way Kafka spells them
+const (
+	ConfigPreset          = "monolog.preset"
+	ConfigRetentionMs     = "retention.ms"
+	ConfigCleanupPolicy   = "cleanup.policy"
+	ConfigMaxMessageBytes = "max.message.bytes"
+	ConfigTimestampType   = "message.timestamp.type"
+	ConfigZstdDictionary  = "monolog.zstd.dictionary"
+)
+
+// Cleanup policies
+const (
+	CleanupDelete  = "delete"
+	CleanupCompact = "compact"
+)
+
+// Timestamp types
+const (
+	TimestampCreateTime    = "CreateTime"
+	TimestampLogAppendTime = "LogAppendTime"
+)
+
+// topicConfigs are the configs a topic keeps. Anything else a client sends,
+// such as replication settings, is accepted and ignored.
+var topicConfigs = map[string]bool{
+	ConfigPreset:          true,
+	ConfigRetentionMs:     true,
+	ConfigCleanupPolicy:   true,
+	ConfigMaxMessageBytes: true,
+	ConfigTimestampType:   true,
+	ConfigZstdDictionary:  true,
+}
+
+// ResolveTopicConfig turns the configs a topic is created with into what it
+// stores: monolog.preset is expanded into the preset's settings, explicit
+// configs override them, and configs monolog has no use for are dropped
+func (e *Engine) ResolveTopicConfig(configs map[string]string) (map[string]string, error) {
+	resolved := make(map[string]string)
+	if name, ok := configs[ConfigPreset]; ok {
+		preset, ok := e.config.Topics.Presets[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown preset %q (have %s)", ErrInvalidConfig, name, strings.Join(e.PresetNames(), ", "))
+		}
+		resolved[ConfigPreset] = name
+		if preset.Retention != 0 {
+			resolved[ConfigRetentionMs] = strconv.FormatInt(preset.Retention.Milliseconds(), 10)
+		}
+		if preset.CleanupPolicy != "" {
+			resolved[ConfigCleanupPolicy] = preset.CleanupPolicy
+		}
+		if preset.MaxMessageBytes != 0 {
+			resolved[ConfigMaxMessageBytes] = strconv.Itoa(preset.MaxMessageBytes)
+		}
+	}
+
+	for name, value := range configs {
+		if name == ConfigPreset || !topicConfigs[name] {
+			continue
+		}
+		resolved[name] = value
+	}
+	for name, value := range resolved {
+		if err := validateTopicConfig(name, value); err != nil {
+			return nil, err
+		}
+	}
+	if len(resolved) == 0 {
+		return nil, nil
+	}
+	return resolved, nil
+}
+
+func validateTopicConfig(name, value string) error {
+	switch name {
+	case ConfigRetentionMs:
+		if ms, err := strconv.ParseInt(value, 10, 64); err != nil || ms < -1 {
+			return fmt.Errorf("%w: %s must be -1 or a number of milliseconds, got %q", ErrInvalidConfig, name, value)
+		}
+	case ConfigMaxMessageBytes:
+		if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+			return fmt.Errorf("%w: %s must be a positive number of bytes, got %q", ErrInvalidConfig, name, value)
+		}
+	case ConfigZstdDictionary:
+		if value != "true" && value != "false" {
+			return fmt.Errorf("%w: %s must be true or false, got %q", ErrInvalidConfig, name, value)
+		}
+	case ConfigTimestampType:
+		if value != TimestampCreateTime && value != TimestampLogAppendTime {
+			return fmt.Errorf("%w: %s must be CreateTime or LogAppendTime, got %q", ErrInvalidConfig, name, value)
+		}
+	case ConfigCleanupPolicy:
+		for _, p := range strings.Split(value, ",") {
+			if p = strings.TrimSpace(p); p != CleanupDelete && p != CleanupCompact {
+				return fmt.Errorf("%w: %s must be delete, compact or both, got %q", ErrInvalidConfig, name, value)
+			}
+		}
+	}
+	return nil
+}
+
+// PresetNames returns the configured topic presets, sorted
+func (e *Engine) PresetNames() []string {
+	names := make([]string, 0, len(e.config.Topics.Presets))
+	for name := range e.config.Topics.Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TopicConfig returns the configs a topic was created with
+func (e *Engine) TopicConfig(topic string) (map[string]string, error) {
+	meta, err := e.topicStore.GetMeta(topic)
+	if err != nil {
+		return nil, err
+	}
+	return meta.Config, nil
+}
+
+// SetTopicConfig replaces a topic's configs at runtime, as AlterConfigs
+// does: configs left out go back to the broker's defaults. With
+// validateOnly the configs are checked but not applied.
+func (e *Engine) SetTopicConfig(ctx context.Context, topic string, configs map[string]string, validateOnly bool) error {
+	e.topicConfigMu.Lock()
+	defer e.topicConfigMu.Unlock()
+	return e.setTopicConfig(ctx, topic, configs, validateOnly)
+}
+
+func (e *Engine) setTopicConfig(ctx context.Context, topic string, configs map[string]string, validateOnly bool) error {
+	old, err := e.TopicConfig(topic)
+	if err != nil {
+		return err
+	}
+	resolved, err := e.ResolveTopicConfig(configs)
+	if err != nil {
+		return err
+	}
+	if validateOnly {
+		return nil
+	}
+	if err := e.topicStore.SetTopicConfig(ctx, topic, resolved); err != nil {
+		return err
+	}
+
+	var changed []string
+	for name := range topicConfigs {
+		if old[name] != resolved[name] {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	for _, name := range changed {
+		e.events.Publish(Event{Type: EventConfigChanged, Topic: topic, Config: name})
+	}
+	if len(changed) > 0 {
+		log.Printf("[engine] topic %s configs changed: %s", topic, strings.Join(changed, ", "))
+	}
+	return nil
+}
+
+// UpdateTopicConfig changes some of a topic's configs, as
+// IncrementalAlterConfigs does: set adds or overrides configs and unset
+// removes them, putting them back on the broker's defaults. Concurrent
+// updates of the same topic each see the other's changes.
+func (e *Engine) UpdateTopicConfig(ctx context.Context, topic string, set map[string]string, unset []string, validateOnly bool) error {
+	e.topicConfigMu.Lock()
+	defer e.topicConfigMu.Unlock()
+	current, err := e.TopicConfig(topic)
+	if err != nil {
+		return err
+	}
+	configs := maps.Clone(current)
+	if configs == nil {
+		configs = make(map[string]string)
+	}
+	for _, name := range unset {
+		delete(configs, name)
+	}
+	maps.Copy(configs, set)
+	return e.setTopicConfig(ctx, topic, configs, validateOnly)
+}
+
+// topicConfig returns one of a topic's configs, or "" when it has none
+func (e *Engine) topicConfig(topic, name string) string {
+	meta, err := e.topicStore.GetMeta(topic)
+	if err != nil {
+		return ""
+	}
+	return meta.Config[name]
+}
+
+// topicRetention returns the age past which a topic's messages are
+// deleted. It is false for topics time-based retention leaves alone: those
+// with retention.ms -1 or a cleanup policy without delete.
+func (e *Engine) topicRetention(topic string) (time.Duration, bool) {
+	if policy := e.topicConfig(topic, ConfigCleanupPolicy); policy != "" && !strings.Contains(policy, CleanupDelete) {
+		return 0, false
+	}
+	if v := e.topicConfig(topic, ConfigRetentionMs); v != "" {
+		ms, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || ms < 0 {
+			return 0, false
+		}
+		return time.Duration(ms) * time.Millisecond, true
+	}
+	return e.config.Retention.MaxAge, true
+}
+
+// maxMessageBytes returns the largest record or batch a topic accepts
+func (e *Engine) maxMessageBytes(topic string) int {
+	if v := e.topicConfig(topic, ConfigMaxMessageBytes); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return e.config.Limits.MaxMessageSize
+}
+
+// logAppendTime reports whether the broker stamps a topic's messages with
+// the time it appended them, rather than keeping the producer's timestamps
+func (e *Engine) logAppendTime(topic string) bool {
+	return e.topicConfig(topic, ConfigTimestampType) == TimestampLogAppendTime
+}
+
+// stampRecords returns records stamped with the broker's clock where the
+// topic uses LogAppendTime. Records without a timestamp are stamped by the
+// store when appended.
+func (e *Engine) stampRecords(topic string, records []store.Record) []store.Record {
+	if !e.logAppendTime(topic) {
+		return records
+	}
+	now := e.Now().UnixMilli()
+	stamped := make([]store.Record, len(records))
+	for i, r := range records {
+		r.Timestamp = now
+		stamped[i] = r
+	}
+	return stamped
+}
+
+// ConfigEntry is one config as DescribeConfigs reports it
+type ConfigEntry struct {
+	Name    string
+	Value   string
+	Default bool   // not set on the topic, so the broker's value applies
+	Synonym string // the broker config a topic config falls back to
+}
+
+// Broker config names, spelled the way Kafka spells them
+const (
+	BrokerLogRetentionMs           = "log.retention.ms"
+	BrokerLogCleanupPolicy         = "log.cleanup.policy"
+	BrokerMessageMaxBytes          = "message.max.bytes"
+	BrokerLogMessageTimestampType  = "log.message.timestamp.type"
+	BrokerAutoCreateTopics         = "auto.create.topics.enable"
+	BrokerNumPartitions            = "num.partitions"
+	BrokerDefaultReplicationFactor = "default.replication.factor"
+)
+
+// brokerSynonyms maps each topic config with a broker-wide default to the
+// broker config it falls back to
+var brokerSynonyms = map[string]string{
+	ConfigRetentionMs:     BrokerLogRetentionMs,
+	ConfigCleanupPolicy:   BrokerLogCleanupPolicy,
+	ConfigMaxMessageBytes: BrokerMessageMaxBytes,
+	ConfigTimestampType:   BrokerLogMessageTimestampType,
+}
+
+// DescribeTopicConfig returns a topic's effective configs: the ones set on
+// it, and the broker's values for those that have a broker-wide default
+func (e *Engine) DescribeTopicConfig(topic string) ([]ConfigEntry, error) {
+	configs, err := e.TopicConfig(topic)
+	if err != nil {
+		return nil, err
+	}
+	broker := make(map[string]string)
+	for _, c := range e.BrokerConfig() {
+		broker[c.Name] = c.Value
+	}
+
+	var entries []ConfigEntry
+	for name, synonym := range brokerSynonyms {
+		entry := ConfigEntry{Name: name, Synonym: synonym}
+		if v, ok := configs[name]; ok {
+			entry.Value = v
+		} else {
+			entry.Value, entry.Default = broker[synonym], true
+		}
+		entries = append(entries, entry)
+	}
+	for name, value := range configs {
+		if _, ok := brokerSynonyms[name]; !ok {
+			entries = append(entries, ConfigEntry{Name: name, Value: value})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+// BrokerConfig returns the broker-wide settings topics fall back to, under
+// their Kafka names. A single-partition, single-replica broker reports
+// itself as one.
+func (e *Engine) BrokerConfig() []ConfigEntry {
+	retention := int64(-1)
+	if e.config.Retention.Enabled {
+		retention = e.config.Retention.MaxAge.Milliseconds()
+	}
+	return []ConfigEntry{
+		{Name: BrokerAutoCreateTopics, Value: strconv.FormatBool(e.config.Topics.AutoCreate)},
+		{Name: BrokerDefaultReplicationFactor, Value: "1"},
+		{Name: BrokerLogCleanupPolicy, Value: CleanupDelete},
+		{Name: BrokerLogMessageTimestampType, Value: TimestampCreateTime},
+		{Name: BrokerLogRetentionMs, Value: strconv.FormatInt(retention, 10)},
+		{Name: BrokerMessageMaxBytes, Value: strconv.Itoa(e.config.Limits.MaxMessageSize)},
+		{Name: BrokerNumPartitions, Value: "1"},
+	}
+}
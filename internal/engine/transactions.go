@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// Fetch isolation levels (Kafka isolation_level)
+const (
+	ReadUncommitted int8 = 0
+	ReadCommitted   int8 = 1
+)
+
+// TxnIndex tracks open transactions per topic so fetches can stop at the
+// last stable offset, and records aborted ranges in the store so
+// read_committed readers can skip them
+type TxnIndex struct {
+	topicStore store.TopicStoreInterface
+
+	mu   sync.Mutex
+	open map[string]map[int64]int64 // topic -> producerID -> first offset
+}
+
+// NewTxnIndex creates a new TxnIndex
+func NewTxnIndex(topicStore store.TopicStoreInterface) *TxnIndex {
+	return &TxnIndex{
+		topicStore: topicStore,
+		open:       make(map[string]map[int64]int64),
+	}
+}
+
+// Begin marks a producer's transaction open on topic from firstOffset.
+// Later writes in the same transaction keep the original first offset.
+func (x *TxnIndex) Begin(topic string, producerID, firstOffset int64) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	producers, ok := x.open[topic]
+	if !ok {
+		producers = make(map[int64]int64)
+		x.open[topic] = producers
+	}
+	if _, ok := producers[producerID]; !ok {
+		producers[producerID] = firstOffset
+	}
+}
+
+// End closes a producer's transaction on topic. Aborted transactions are
+// persisted with their offset range, up to and including lastOffset.
+func (x *TxnIndex) End(ctx context.Context, topic string, producerID, lastOffset int64, committed bool) error {
+	x.mu.Lock()
+	first, ok := x.open[topic][producerID]
+	delete(x.open[topic], producerID)
+	if len(x.open[topic]) == 0 {
+		delete(x.open, topic)
+	}
+	x.mu.Unlock()
+
+	if !ok || committed {
+		return nil
+	}
+	return x.topicStore.AddAbortedTxn(ctx, topic, store.AbortedTxn{
+		ProducerID:  producerID,
+		FirstOffset: first,
+		LastOffset:  lastOffset,
+	})
+}
+
+// LastStableOffset returns the first offset of the earliest open
+// transaction on topic, or highWatermark when none is open
+func (x *TxnIndex) LastStableOffset(topic string, highWatermark int64) int64 {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	lso := highWatermark
+	for _, first := range x.open[topic] {
+		if first < lso {
+			lso = first
+		}
+	}
+	return lso
+}
+
+// --- Engine ---
+
+// BeginTransaction marks a producer's transaction open on topic
+func (e *Engine) BeginTransaction(topic string, producerID, firstOffset int64) {
+	e.txns.Begin(topic, producerID, firstOffset)
+}
+
+// EndTransaction commits or aborts a producer's transaction on topic
+func (e *Engine) EndTransaction(ctx context.Context, topic string, producerID, lastOffset int64, committed bool) error {
+	return e.txns.End(ctx, topic, producerID, lastOffset, committed)
+}
+
+// LastStableOffset returns the offset below which all transactions on
+// topic are decided (equal to the high watermark when none are open)
+func (e *Engine) LastStableOffset(topic string) (int64, error) {
+	latest, err := e.topicStore.LatestOffset(topic)
+	if err != nil {
+		return 0, err
+	}
+	return e.txns.LastStableOffset(topic, latest+1), nil
+}
+
+// AbortedTransactions returns aborted transactions overlapping
+// [fromOffset, toOffset)
+func (e *Engine) AbortedTransactions(ctx context.Context, topic string, fromOffset, toOffset int64) ([]store.AbortedTxn, error) {
+	return e.topicStore.AbortedTxns(ctx, topic, fromOffset, toOffset)
+}
+
+// FetchIsolated reads records like Fetch, honoring the isolation level.
+// read_committed stops at the last stable offset. Batches come back as
+// stored, markers and aborted ones included: Kafka consumers skip those
+// themselves with the aborted transactions sent alongside, and need the
+// markers to move their position past them.
+func (e *Engine) FetchIsolated(ctx context.Context, topic string, offset int64, maxRecords int, isolation int8) ([]store.Record, error) {
+	records, err := e.Fetch(ctx, topic, offset, maxRecords)
+	if err != nil || isolation != ReadCommitted || len(records) == 0 {
+		return records, err
+	}
+
+	lso, err := e.LastStableOffset(topic)
+	if err != nil {
+		return nil, err
+	}
+	for i, r := range records {
+		if r.Offset >= lso {
+			return records[:i], nil
+		}
+	}
+	return records, nil
+}
+
+// FetchCommitted reads committed records for readers that cannot skip
+// aborted data themselves: it stops at the last stable offset and drops
+// batches written by aborted transactions as well as control batches.
+// next is the offset to read from afterwards, past any batches dropped,
+// so a reader whose records were all dropped still moves on.
+func (e *Engine) FetchCommitted(ctx context.Context, topic string, offset int64, maxRecords int) (records []store.Record, next int64, err error) {
+	records, err = e.FetchIsolated(ctx, topic, offset, maxRecords, ReadCommitted)
+	if err != nil || len(records) == 0 {
+		return records, offset, err
+	}
+	last := records[len(records)-1]
+	next = last.LastOffset + 1
+	if last.LastOffset < last.Offset {
+		next = last.Offset + 1
+	}
+
+	aborted, err := e.AbortedTransactions(ctx, topic, records[0].Offset, next)
+	if err != nil {
+		return nil, 0, err
+	}
+	result := records[:0]
+	for _, r := range records {
+		if !isAbortedOrControl(r, aborted) {
+			result = append(result, r)
+		}
+	}
+	return result, next, nil
+}
+
+// isAbortedOrControl reports whether a stored record is a raw transactional
+// batch belonging to an aborted range, or a control (commit/abort marker) batch
+func isAbortedOrControl(r store.Record, aborted []store.AbortedTxn) bool {
+	if r.Key != nil {
+		return false
+	}
+	b, err := kafkaproto.ParseRecordBatchHeader(r.Value)
+	if err != nil {
+		return false
+	}
+	if b.Attributes&kafkaproto.BatchAttrControl != 0 {
+		return true
+	}
+	if b.Attributes&kafkaproto.BatchAttrTransactional == 0 {
+		return false
+	}
+	for _, t := range aborted {
+		if t.ProducerID == b.ProducerID && r.Offset >= t.FirstOffset && r.Offset <= t.LastOffset {
+			return true
+		}
+	}
+	return false
+}
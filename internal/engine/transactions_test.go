@@ -0,0 +1,224 @@
+package engine
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// newTestEngine starts an engine over an in-memory store
+func newTestEngine(t *testing.T) *Engine {
+	t.Helper()
+	cfg := config.Default()
+	backend, err := store.Open("sqlite:memory", cfg.Storage)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	e := New(cfg, backend.Topics, backend.Groups)
+	e.Start()
+	t.Cleanup(func() {
+		e.Stop()
+		backend.Close()
+	})
+	return e
+}
+
+// txnBatch encodes values as one transactional batch from producerID at
+// epoch, starting at sequence seq
+func txnBatch(t *testing.T, producerID int64, epoch int16, seq int32, values ...string) []byte {
+	t.Helper()
+	records := make([]kafkaproto.Record, len(values))
+	for i, v := range values {
+		records[i] = kafkaproto.Record{Offset: int64(i), Timestamp: 1, Value: []byte(v)}
+	}
+	b, err := kafkaproto.NewRecordBatch(records, kafkaproto.CompressionNone, nil)
+	if err != nil {
+		t.Fatalf("encode batch: %v", err)
+	}
+	binary.BigEndian.PutUint16(b[21:23], uint16(kafkaproto.BatchAttrTransactional))
+	binary.BigEndian.PutUint64(b[43:51], uint64(producerID))
+	binary.BigEndian.PutUint16(b[51:53], uint16(epoch))
+	binary.BigEndian.PutUint32(b[53:57], uint32(seq))
+	binary.BigEndian.PutUint32(b[17:21], crc32.Checksum(b[21:], crc32.MakeTable(crc32.Castagnoli)))
+	return b
+}
+
+// produceTxn adds topic to the transaction of id and appends values to it,
+// returning the offset of the first
+func produceTxn(t *testing.T, e *Engine, id, topic string, producerID int64, epoch int16, seq int32, values ...string) int64 {
+	t.Helper()
+	ctx := context.Background()
+	if err := e.AddPartitionsToTxn(ctx, id, producerID, epoch, []string{topic}); err != nil {
+		t.Fatalf("add partitions: %v", err)
+	}
+	offset, _, err := e.ProduceBatches(ctx, topic, txnBatch(t, producerID, epoch, seq, values...), kafkaproto.CompressionNone)
+	if err != nil {
+		t.Fatalf("produce: %v", err)
+	}
+	return offset
+}
+
+// markerAt returns the control type of the marker stored at offset
+func markerAt(t *testing.T, e *Engine, topic string, offset int64) int16 {
+	t.Helper()
+	records, err := e.Fetch(context.Background(), topic, offset, 1)
+	if err != nil || len(records) == 0 {
+		t.Fatalf("fetch marker at %d: %v", offset, err)
+	}
+	b, err := kafkaproto.ParseRecordBatchHeader(records[0].Value)
+	if err != nil {
+		t.Fatalf("parse batch at %d: %v", offset, err)
+	}
+	if b.Attributes&kafkaproto.BatchAttrControl == 0 {
+		t.Fatalf("batch at %d is not a control batch", offset)
+	}
+	recs, err := b.DecodeRecords()
+	if err != nil || len(recs) != 1 || len(recs[0].Key) < 4 {
+		t.Fatalf("decode marker at %d: %v", offset, err)
+	}
+	return int16(binary.BigEndian.Uint16(recs[0].Key[2:4]))
+}
+
+// lastStable returns the last stable offset of topic
+func lastStable(t *testing.T, e *Engine, topic string) int64 {
+	t.Helper()
+	lso, err := e.LastStableOffset(topic)
+	if err != nil {
+		t.Fatalf("last stable offset: %v", err)
+	}
+	return lso
+}
+
+func TestTxnCommitAndAbortMarkers(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	pid, epoch, err := e.InitProducerID(ctx, "markers", 60000, -1, -1)
+	if err != nil {
+		t.Fatalf("init producer: %v", err)
+	}
+
+	produceTxn(t, e, "markers", "orders", pid, epoch, 0, "a", "b")
+	if err := e.EndTxn(ctx, "markers", pid, epoch, true); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if got := markerAt(t, e, "orders", 2); got != kafkaproto.ControlTypeCommit {
+		t.Errorf("marker after commit has control type %d, want COMMIT", got)
+	}
+
+	first := produceTxn(t, e, "markers", "orders", pid, epoch, 2, "c")
+	if err := e.EndTxn(ctx, "markers", pid, epoch, false); err != nil {
+		t.Fatalf("abort: %v", err)
+	}
+	if got := markerAt(t, e, "orders", first+1); got != kafkaproto.ControlTypeAbort {
+		t.Errorf("marker after abort has control type %d, want ABORT", got)
+	}
+
+	aborted, err := e.AbortedTransactions(ctx, "orders", 0, first+2)
+	if err != nil {
+		t.Fatalf("aborted transactions: %v", err)
+	}
+	if len(aborted) != 1 || aborted[0].ProducerID != pid || aborted[0].FirstOffset != first || aborted[0].LastOffset != first+1 {
+		t.Errorf("aborted transactions %+v, want producer %d over [%d, %d]", aborted, pid, first, first+1)
+	}
+
+	records, _, err := e.FetchCommitted(ctx, "orders", 0, 100)
+	if err != nil {
+		t.Fatalf("fetch committed: %v", err)
+	}
+	if len(records) != 1 || records[0].Offset != 0 {
+		t.Errorf("read_committed got %d batches, want only the committed one at 0", len(records))
+	}
+}
+
+func TestTxnOpenTransactionHoldsLastStableOffset(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	if _, err := e.Produce(ctx, "held", []store.Record{{Value: []byte("plain")}}); err != nil {
+		t.Fatalf("produce: %v", err)
+	}
+	pid, epoch, err := e.InitProducerID(ctx, "held", 60000, -1, -1)
+	if err != nil {
+		t.Fatalf("init producer: %v", err)
+	}
+
+	first := produceTxn(t, e, "held", "held", pid, epoch, 0, "a", "b")
+	if lso := lastStable(t, e, "held"); lso != first {
+		t.Errorf("last stable offset with the transaction open is %d, want its first offset %d", lso, first)
+	}
+	records, err := e.FetchIsolated(ctx, "held", 0, 100, ReadCommitted)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if len(records) != 1 {
+		t.Errorf("read_committed fetch got %d batches past an open transaction, want 1", len(records))
+	}
+
+	if err := e.EndTxn(ctx, "held", pid, epoch, true); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	latest, _ := e.LatestOffset("held")
+	if lso := lastStable(t, e, "held"); lso != latest+1 {
+		t.Errorf("last stable offset after commit is %d, want the high watermark %d", lso, latest+1)
+	}
+}
+
+func TestTxnTimeoutAbortsAndBumpsEpoch(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	pid, epoch, err := e.InitProducerID(ctx, "slow", 1, -1, -1)
+	if err != nil {
+		t.Fatalf("init producer: %v", err)
+	}
+	first := produceTxn(t, e, "slow", "slow", pid, epoch, 0, "a")
+
+	time.Sleep(5 * time.Millisecond)
+	e.txnCoord.expire(ctx)
+
+	states := e.Transactions()
+	if len(states) != 1 || states[0].State != store.TxnCompleteAbort {
+		t.Fatalf("transaction states %+v, want one aborted", states)
+	}
+	if states[0].ProducerID != pid || states[0].ProducerEpoch != epoch+1 {
+		t.Errorf("producer %d at epoch %d after the timeout, want %d at %d", states[0].ProducerID, states[0].ProducerEpoch, pid, epoch+1)
+	}
+	if got := markerAt(t, e, "slow", first+1); got != kafkaproto.ControlTypeAbort {
+		t.Errorf("marker after timeout has control type %d, want ABORT", got)
+	}
+	if lso := lastStable(t, e, "slow"); lso != first+2 {
+		t.Errorf("last stable offset after the timeout is %d, want %d", lso, first+2)
+	}
+	if err := e.EndTxn(ctx, "slow", pid, epoch, true); !errors.Is(err, ErrInvalidProducerEpoch) {
+		t.Errorf("commit from the fenced epoch: %v, want ErrInvalidProducerEpoch", err)
+	}
+}
+
+func TestTxnRetriedEndTxnIsNoOp(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	pid, epoch, err := e.InitProducerID(ctx, "retry", 60000, -1, -1)
+	if err != nil {
+		t.Fatalf("init producer: %v", err)
+	}
+	produceTxn(t, e, "retry", "retry", pid, epoch, 0, "a")
+
+	if err := e.EndTxn(ctx, "retry", pid, epoch, true); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	latest, _ := e.LatestOffset("retry")
+	if err := e.EndTxn(ctx, "retry", pid, epoch, true); err != nil {
+		t.Errorf("retried commit: %v, want no error", err)
+	}
+	if again, _ := e.LatestOffset("retry"); again != latest {
+		t.Errorf("retried commit moved the log end from %d to %d, want no second marker", latest, again)
+	}
+	if err := e.EndTxn(ctx, "retry", pid, epoch, false); !errors.Is(err, ErrInvalidTxnState) {
+		t.Errorf("abort after commit: %v, want ErrInvalidTxnState", err)
+	}
+}
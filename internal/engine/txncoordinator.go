@@ -0,0 +1,411 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// txnExpiryInterval is how often open transactions are checked against
+// their timeout
+const txnExpiryInterval = time.Second
+
+// TxnCoordinator is the transaction coordinator: it tracks each
+// transactional ID's producer, the topics and group offsets in its open
+// transaction, and ends the transaction by writing a commit or abort
+// marker to every topic it wrote to. State is saved to the store after
+// every change when the store can keep it, so open transactions are
+// still fenced and aborted after a restart.
+type TxnCoordinator struct {
+	engine     *Engine
+	mu         sync.Mutex
+	txns       map[string]*store.TxnState // by transactional ID
+	byProducer map[int64]string           // producer ID -> transactional ID
+	ticker     *time.Ticker
+	stopChan   chan struct{}
+}
+
+// NewTxnCoordinator creates a coordinator with no transactions
+func NewTxnCoordinator(engine *Engine) *TxnCoordinator {
+	return &TxnCoordinator{
+		engine:     engine,
+		txns:       make(map[string]*store.TxnState),
+		byProducer: make(map[int64]string),
+		stopChan:   make(chan struct{}),
+	}
+}
+
+// load restores saved transaction states. Run once from New, before any
+// produce; open transactions keep holding back the last stable offset.
+func (c *TxnCoordinator) load(ctx context.Context) {
+	ts, ok := c.engine.topicStore.(store.TxnStateStore)
+	if !ok {
+		return
+	}
+	states, err := ts.TxnStates(ctx)
+	if err != nil {
+		log.Printf("[engine] loading transaction states failed: %v", err)
+		return
+	}
+	for i := range states {
+		st := &states[i]
+		c.txns[st.TransactionalID] = st
+		c.byProducer[st.ProducerID] = st.TransactionalID
+		c.engine.producers.restore(st.TransactionalID, st.ProducerID, st.ProducerEpoch)
+		if st.State != store.TxnOngoing {
+			continue
+		}
+		for topic, first := range st.Partitions {
+			if first >= 0 {
+				c.engine.txns.Begin(topic, st.ProducerID, first)
+			}
+		}
+	}
+	if len(states) > 0 {
+		log.Printf("[engine] restored %d transactional IDs", len(states))
+	}
+}
+
+// Start starts aborting transactions that outlive their timeout
+func (c *TxnCoordinator) Start() {
+	c.ticker = time.NewTicker(txnExpiryInterval)
+	go c.loop()
+}
+
+// Stop stops the expiry loop
+func (c *TxnCoordinator) Stop() {
+	if c.ticker != nil {
+		c.ticker.Stop()
+	}
+	close(c.stopChan)
+}
+
+func (c *TxnCoordinator) loop() {
+	for {
+		select {
+		case <-c.ticker.C:
+			c.engine.safely("transaction expiry", func() { c.expire(c.engine.ctx) })
+		case <-c.stopChan:
+			return
+		}
+	}
+}
+
+// expire aborts open transactions past their timeout and bumps their
+// producer's epoch, fencing the producer that let them time out
+func (c *TxnCoordinator) expire(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for _, st := range c.txns {
+		if st.State != store.TxnOngoing || now.Sub(st.Started) < time.Duration(st.TimeoutMs)*time.Millisecond {
+			continue
+		}
+		if err := c.end(ctx, st, false); err != nil {
+			log.Printf("[engine] aborting timed out transaction %s failed: %v", st.TransactionalID, err)
+			continue
+		}
+		id, epoch, err := c.engine.producers.Init(st.TransactionalID, st.ProducerID, st.ProducerEpoch)
+		if err != nil {
+			continue
+		}
+		c.setProducer(st, id, epoch)
+		c.save(ctx, st)
+		log.Printf("[engine] transaction %s timed out after %dms, aborted; producer %d now at epoch %d", st.TransactionalID, st.TimeoutMs, id, epoch)
+	}
+}
+
+// Init gives a transactional ID its producer, fencing any earlier
+// instance: a transaction it left open is aborted first
+func (c *TxnCoordinator) Init(ctx context.Context, transactionalID string, timeoutMs int32, producerID int64, epoch int16) (int64, int16, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id, newEpoch, err := c.engine.producers.Init(transactionalID, producerID, epoch)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	st, ok := c.txns[transactionalID]
+	if !ok {
+		st = &store.TxnState{TransactionalID: transactionalID, State: store.TxnEmpty}
+		c.txns[transactionalID] = st
+	}
+	if st.State == store.TxnOngoing {
+		if err := c.end(ctx, st, false); err != nil {
+			return 0, 0, fmt.Errorf("abort open transaction of %s: %w", transactionalID, err)
+		}
+		log.Printf("[engine] aborted open transaction of %s for a new producer", transactionalID)
+	}
+	c.setProducer(st, id, newEpoch)
+	st.TimeoutMs = timeoutMs
+	st.State = store.TxnEmpty
+	c.save(ctx, st)
+	return id, newEpoch, nil
+}
+
+// setProducer moves a transactional ID to a producer ID and epoch
+func (c *TxnCoordinator) setProducer(st *store.TxnState, producerID int64, epoch int16) {
+	if st.ProducerID != producerID {
+		delete(c.byProducer, st.ProducerID)
+	}
+	st.ProducerID, st.ProducerEpoch = producerID, epoch
+	c.byProducer[producerID] = st.TransactionalID
+}
+
+// lookup returns a transactional ID's state if producerID and epoch are
+// its current producer. The caller holds c.mu.
+func (c *TxnCoordinator) lookup(transactionalID string, producerID int64, epoch int16) (*store.TxnState, error) {
+	st, ok := c.txns[transactionalID]
+	if !ok || st.ProducerID != producerID {
+		return nil, fmt.Errorf("%w: %s, producer %d", ErrUnknownProducerID, transactionalID, producerID)
+	}
+	if epoch != st.ProducerEpoch {
+		return nil, fmt.Errorf("%w: %s is at epoch %d, not %d", ErrInvalidProducerEpoch, transactionalID, st.ProducerEpoch, epoch)
+	}
+	return st, nil
+}
+
+// begin opens a transaction unless one is open
+func (c *TxnCoordinator) begin(st *store.TxnState) {
+	if st.State == store.TxnOngoing {
+		return
+	}
+	st.State = store.TxnOngoing
+	st.Started = time.Now()
+	st.Partitions = make(map[string]int64)
+	st.Groups, st.Offsets = nil, nil
+}
+
+// AddPartitions adds topics to a producer's transaction, opening it if
+// needed. The producer may only write to topics added this way.
+func (c *TxnCoordinator) AddPartitions(ctx context.Context, transactionalID string, producerID int64, epoch int16, topics []string) error {
+	for _, topic := range topics {
+		if err := c.engine.EnsureTopic(ctx, topic); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, err := c.lookup(transactionalID, producerID, epoch)
+	if err != nil {
+		return err
+	}
+	c.begin(st)
+	for _, topic := range topics {
+		if _, ok := st.Partitions[topic]; !ok {
+			st.Partitions[topic] = -1
+		}
+	}
+	c.save(ctx, st)
+	return nil
+}
+
+// AddOffsets adds a consumer group to a producer's transaction, so its
+// TxnOffsetCommits are applied when the transaction commits
+func (c *TxnCoordinator) AddOffsets(ctx context.Context, transactionalID string, producerID int64, epoch int16, group string) error {
+	if _, err := c.engine.GetOrCreateGroup(ctx, group); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, err := c.lookup(transactionalID, producerID, epoch)
+	if err != nil {
+		return err
+	}
+	c.begin(st)
+	if !slices.Contains(st.Groups, group) {
+		st.Groups = append(st.Groups, group)
+	}
+	c.save(ctx, st)
+	return nil
+}
+
+// CommitOffsets holds group offsets until the transaction ends: they are
+// committed with it, or dropped if it aborts
+func (c *TxnCoordinator) CommitOffsets(ctx context.Context, transactionalID string, producerID int64, epoch int16, offsets []store.TxnOffset) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, err := c.lookup(transactionalID, producerID, epoch)
+	if err != nil {
+		return err
+	}
+	for _, o := range offsets {
+		if st.State != store.TxnOngoing || !slices.Contains(st.Groups, o.Group) {
+			return fmt.Errorf("%w: group %s was not added to the transaction of %s", ErrInvalidTxnState, o.Group, transactionalID)
+		}
+	}
+	for _, o := range offsets {
+		i := slices.IndexFunc(st.Offsets, func(p store.TxnOffset) bool {
+			return p.Group == o.Group && p.Topic == o.Topic && p.Partition == o.Partition
+		})
+		if i >= 0 {
+			st.Offsets[i] = o
+		} else {
+			st.Offsets = append(st.Offsets, o)
+		}
+	}
+	c.save(ctx, st)
+	return nil
+}
+
+// End commits or aborts a producer's transaction. Ending one that has
+// already ended the same way succeeds, so a retried EndTxn is harmless.
+func (c *TxnCoordinator) End(ctx context.Context, transactionalID string, producerID int64, epoch int16, commit bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, err := c.lookup(transactionalID, producerID, epoch)
+	if err != nil {
+		return err
+	}
+	switch {
+	case st.State == store.TxnOngoing:
+		return c.end(ctx, st, commit)
+	case st.State == store.TxnCompleteCommit && commit, st.State == store.TxnCompleteAbort && !commit:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s cannot end a transaction in state %s", ErrInvalidTxnState, transactionalID, st.State)
+	}
+}
+
+// end writes the transaction's markers, applies or drops its offsets and
+// closes it. The caller holds c.mu.
+func (c *TxnCoordinator) end(ctx context.Context, st *store.TxnState, commit bool) error {
+	topics := make([]string, 0, len(st.Partitions))
+	for topic, first := range st.Partitions {
+		if first >= 0 {
+			topics = append(topics, topic)
+		}
+	}
+	sort.Strings(topics)
+
+	now := c.engine.Now().UnixMilli()
+	marker := kafkaproto.NewControlBatch(st.ProducerID, st.ProducerEpoch, commit, now)
+	for _, topic := range topics {
+		offset, err := c.engine.topicStore.AppendRaw(ctx, topic, marker, kafkaproto.CompressionNone, 1, now)
+		if err != nil {
+			return fmt.Errorf("write marker to %s: %w", topic, err)
+		}
+		if err := c.engine.txns.End(ctx, topic, st.ProducerID, offset, commit); err != nil {
+			return fmt.Errorf("end transaction on %s: %w", topic, err)
+		}
+		delete(st.Partitions, topic)
+	}
+	if commit {
+		for _, o := range st.Offsets {
+			if _, err := c.engine.GetOrCreateGroup(ctx, o.Group); err != nil {
+				return fmt.Errorf("commit offset of %s on %s: %w", o.Group, o.Topic, err)
+			}
+			if err := c.engine.CommitOffset(ctx, o.Group, o.Topic, o.Partition, o.Offset); err != nil {
+				return fmt.Errorf("commit offset of %s on %s: %w", o.Group, o.Topic, err)
+			}
+		}
+	}
+
+	st.State = store.TxnCompleteAbort
+	if commit {
+		st.State = store.TxnCompleteCommit
+	}
+	st.Partitions, st.Groups, st.Offsets = nil, nil, nil
+	c.save(ctx, st)
+	if c.engine.config.Logging.Level == "debug" {
+		log.Printf("[engine] transaction %s %s on %d topics", st.TransactionalID, st.State, len(topics))
+	}
+	return nil
+}
+
+// checkProduce rejects a transactional batch from a producer whose open
+// transaction does not include topic
+func (c *TxnCoordinator) checkProduce(topic string, producerID int64, epoch int16) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id, ok := c.byProducer[producerID]
+	if !ok {
+		return fmt.Errorf("%w: producer %d has no transactional ID", ErrUnknownProducerID, producerID)
+	}
+	st, err := c.lookup(id, producerID, epoch)
+	if err != nil {
+		return err
+	}
+	if _, added := st.Partitions[topic]; st.State != store.TxnOngoing || !added {
+		return fmt.Errorf("%w: %s was not added to the transaction of %s", ErrInvalidTxnState, topic, id)
+	}
+	return nil
+}
+
+// produced records a transactional batch appended at offset, holding back
+// the topic's last stable offset until the transaction ends
+func (c *TxnCoordinator) produced(ctx context.Context, topic string, producerID, offset int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, ok := c.txns[c.byProducer[producerID]]
+	if !ok || st.State != store.TxnOngoing {
+		return
+	}
+	c.engine.txns.Begin(topic, producerID, offset)
+	if first, ok := st.Partitions[topic]; ok && first < 0 {
+		st.Partitions[topic] = offset
+		c.save(ctx, st)
+	}
+}
+
+// save stores a transaction state, if the store can. The caller holds c.mu.
+func (c *TxnCoordinator) save(ctx context.Context, st *store.TxnState) {
+	st.Updated = time.Now()
+	ts, ok := c.engine.topicStore.(store.TxnStateStore)
+	if !ok {
+		return
+	}
+	if err := ts.SaveTxnState(ctx, *st); err != nil {
+		log.Printf("[engine] saving transaction state of %s failed: %v", st.TransactionalID, err)
+	}
+}
+
+// List returns every transactional ID's state, by ID
+func (c *TxnCoordinator) List() []store.TxnState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	states := make([]store.TxnState, 0, len(c.txns))
+	for _, st := range c.txns {
+		states = append(states, *st)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].TransactionalID < states[j].TransactionalID })
+	return states
+}
+
+// --- Engine ---
+
+// AddPartitionsToTxn adds topics to a producer's transaction
+func (e *Engine) AddPartitionsToTxn(ctx context.Context, transactionalID string, producerID int64, epoch int16, topics []string) error {
+	return e.txnCoord.AddPartitions(ctx, transactionalID, producerID, epoch, topics)
+}
+
+// AddOffsetsToTxn adds a consumer group to a producer's transaction
+func (e *Engine) AddOffsetsToTxn(ctx context.Context, transactionalID string, producerID int64, epoch int16, group string) error {
+	return e.txnCoord.AddOffsets(ctx, transactionalID, producerID, epoch, group)
+}
+
+// TxnCommitOffsets holds group offsets to commit with a transaction
+func (e *Engine) TxnCommitOffsets(ctx context.Context, transactionalID string, producerID int64, epoch int16, offsets []store.TxnOffset) error {
+	return e.txnCoord.CommitOffsets(ctx, transactionalID, producerID, epoch, offsets)
+}
+
+// EndTxn commits or aborts a producer's transaction
+func (e *Engine) EndTxn(ctx context.Context, transactionalID string, producerID int64, epoch int16, commit bool) error {
+	return e.txnCoord.End(ctx, transactionalID, producerID, epoch, commit)
+}
+
+// Transactions returns the transaction coordinator's state
+func (e *Engine) Transactions() []store.TxnState {
+	return e.txnCoord.List()
+}
@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// ============================================================================
+// Verification
+//
+// Server-side matching and counting, so black-box tests can assert what a
+// topic holds without downloading it. Ranges are by append time, like
+// exports.
+// ============================================================================
+
+// verifyPageSize is how many stored records a scan reads per query
+const verifyPageSize = 1000
+
+// MessageMatch selects messages. Nil fields match anything.
+type MessageMatch struct {
+	Key         *string
+	Value       *string
+	ValueSHA256 string            // hex SHA-256 of the value
+	Headers     map[string]string // headers the message must carry, with these values
+}
+
+// Matches reports whether msg is selected
+func (m MessageMatch) Matches(msg Message) bool {
+	if m.Key != nil && !bytes.Equal(msg.Key, []byte(*m.Key)) {
+		return false
+	}
+	if m.Value != nil && !bytes.Equal(msg.Value, []byte(*m.Value)) {
+		return false
+	}
+	if m.ValueSHA256 != "" {
+		sum := sha256.Sum256(msg.Value)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), m.ValueSHA256) {
+			return false
+		}
+	}
+	for name, value := range m.Headers {
+		if !slices.ContainsFunc(msg.Headers, func(h kafkaproto.RecordHeader) bool {
+			return h.Key == name && string(h.Value) == value
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// scanRange calls fn for every message appended within [from, to) until fn
+// returns false, returning how many messages it looked at. Messages of
+// aborted transactions are skipped.
+func (e *Engine) scanRange(ctx context.Context, topic string, from, to time.Time, fn func(Message) bool) (int, error) {
+	scanned := 0
+	cursor := int64(0)
+	for {
+		records, next, err := e.ReadRange(ctx, topic, from, to, cursor, verifyPageSize)
+		if err != nil {
+			return scanned, err
+		}
+		aborted, err := e.abortedIn(ctx, topic, records)
+		if err != nil {
+			return scanned, err
+		}
+		for _, rec := range records {
+			if isAbortedOrControl(rec, aborted) {
+				continue
+			}
+			for _, msg := range storedMessages(topic, rec) {
+				if msg.Offset < cursor {
+					continue
+				}
+				scanned++
+				if !fn(msg) {
+					return scanned, nil
+				}
+			}
+		}
+		if next < 0 {
+			return scanned, nil
+		}
+		cursor = next
+	}
+}
+
+// abortedIn returns the aborted transactions overlapping records
+func (e *Engine) abortedIn(ctx context.Context, topic string, records []store.Record) ([]store.AbortedTxn, error) {
+	if len(records) == 0 {
+		return nil, nil
+	}
+	last := records[len(records)-1]
+	return e.AbortedTransactions(ctx, topic, records[0].Offset, max(last.Offset, last.LastOffset)+1)
+}
+
+// FindMessage returns the first message appended since the given time that
+// matches, or nil, along with how many messages it scanned
+func (e *Engine) FindMessage(ctx context.Context, topic string, match MessageMatch, since time.Time) (*Message, int, error) {
+	var found *Message
+	scanned, err := e.scanRange(ctx, topic, since, time.UnixMilli(math.MaxInt64), func(msg Message) bool {
+		if match.Matches(msg) {
+			found = &msg
+			return false
+		}
+		return true
+	})
+	return found, scanned, err
+}
+
+// CountMessages counts the matching messages appended within [from, to)
+func (e *Engine) CountMessages(ctx context.Context, topic string, match MessageMatch, from, to time.Time) (int, error) {
+	count := 0
+	_, err := e.scanRange(ctx, topic, from, to, func(msg Message) bool {
+		if match.Matches(msg) {
+			count++
+		}
+		return true
+	})
+	return count, err
+}
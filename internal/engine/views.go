@@ -0,0 +1,282 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+	sqlitestore "github.com/rizkyandriawan/monolog/internal/store"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// ============================================================================
+// Materialized Views
+//
+// A view follows a topic from its first offset and keeps a table in an
+// in-memory SQLite database with a row per message and a column per JSON
+// path it extracts from message values. Views are queried with read-only
+// SQL, a lightweight way to inspect JSON topics during development. The
+// tables are rebuilt from the topics on every start.
+// ============================================================================
+
+// viewRetryInterval is how long a view waits before following its topic
+// again after an error, such as the topic not existing yet
+const viewRetryInterval = 5 * time.Second
+
+// ViewStatus describes a view and how far it has followed its topic
+type ViewStatus struct {
+	config.ViewConfig
+	NextOffset int64  `json:"next_offset"` // first offset not in the view yet
+	Rows       int64  `json:"rows"`        // messages added since the view was (re)built
+	Error      string `json:"error,omitempty"`
+}
+
+// Views maintains the materialized views
+type Views struct {
+	engine *Engine
+	db     *sqlitestore.ViewDB
+	mu     sync.Mutex
+	views  map[string]*view
+	err    error // why db could not be opened
+}
+
+type view struct {
+	config  config.ViewConfig
+	paths   []string
+	cancel  context.CancelFunc
+	done    chan struct{}
+	created time.Time // the topic followed; a recreated topic rebuilds the view
+
+	mu    sync.Mutex
+	next  int64
+	rows  int64
+	error string
+}
+
+// NewViews creates the view manager. Views from the config start with
+// Start.
+func NewViews(engine *Engine) *Views {
+	db, err := sqlitestore.OpenViewDB()
+	if err != nil {
+		log.Printf("[engine] views unavailable: %v", err)
+	}
+	return &Views{engine: engine, db: db, views: make(map[string]*view), err: err}
+}
+
+// Start creates the views in the config. Invalid ones are logged and
+// skipped.
+func (m *Views) Start() {
+	for _, cfg := range m.engine.config.Views {
+		if _, err := m.Create(cfg); err != nil {
+			log.Printf("[engine] view %s: %v", cfg.Name, err)
+		}
+	}
+}
+
+// Stop stops following every topic and drops the tables
+func (m *Views) Stop() {
+	m.mu.Lock()
+	views := m.views
+	m.views = make(map[string]*view)
+	m.mu.Unlock()
+	for _, v := range views {
+		v.cancel()
+		<-v.done
+	}
+	if m.db != nil {
+		m.db.Close()
+	}
+}
+
+// validateView checks a view definition before its table is created
+func validateView(cfg config.ViewConfig) error {
+	if !sqlitestore.ValidViewName(cfg.Name) {
+		return fmt.Errorf("%w: name %q must be letters, digits and underscores, not starting with a digit or underscore", ErrInvalidView, cfg.Name)
+	}
+	if cfg.Topic == "" {
+		return fmt.Errorf("%w: topic is required", ErrInvalidView)
+	}
+	seen := make(map[string]bool)
+	for _, c := range cfg.Columns {
+		if !sqlitestore.ValidViewName(c.Name) {
+			return fmt.Errorf("%w: column name %q must be letters, digits and underscores, not starting with a digit or underscore", ErrInvalidView, c.Name)
+		}
+		if seen[strings.ToLower(c.Name)] {
+			return fmt.Errorf("%w: duplicate column %q", ErrInvalidView, c.Name)
+		}
+		seen[strings.ToLower(c.Name)] = true
+		if !strings.HasPrefix(c.Path, "$") {
+			return fmt.Errorf("%w: column %s: path %q must start with $", ErrInvalidView, c.Name, c.Path)
+		}
+	}
+	return nil
+}
+
+// Create defines a view and starts filling it from the topic's first
+// offset. The topic need not exist yet.
+func (m *Views) Create(cfg config.ViewConfig) (*ViewStatus, error) {
+	if m.db == nil {
+		return nil, fmt.Errorf("views unavailable: %w", m.err)
+	}
+	if err := validateView(cfg); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name := range m.views {
+		if strings.EqualFold(name, cfg.Name) {
+			return nil, fmt.Errorf("%w: %s", ErrViewExists, cfg.Name)
+		}
+	}
+	if err := m.db.CreateTable(m.engine.ctx, cfg.Name, cfg.Columns); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidView, err)
+	}
+
+	ctx, cancel := context.WithCancel(m.engine.ctx)
+	v := &view{config: cfg, cancel: cancel, done: make(chan struct{})}
+	for _, c := range cfg.Columns {
+		v.paths = append(v.paths, c.Path)
+	}
+	m.views[cfg.Name] = v
+	go func() {
+		defer close(v.done)
+		m.follow(ctx, v)
+	}()
+	return v.status(), nil
+}
+
+// Drop stops a view and drops its table
+func (m *Views) Drop(name string) error {
+	m.mu.Lock()
+	v := m.views[name]
+	delete(m.views, name)
+	m.mu.Unlock()
+	if v == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownView, name)
+	}
+	v.cancel()
+	<-v.done
+	return m.db.DropTable(context.Background(), name)
+}
+
+// Get returns the status of a view
+func (m *Views) Get(name string) (*ViewStatus, error) {
+	m.mu.Lock()
+	v := m.views[name]
+	m.mu.Unlock()
+	if v == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownView, name)
+	}
+	return v.status(), nil
+}
+
+// List returns the status of every view, by name
+func (m *Views) List() []*ViewStatus {
+	m.mu.Lock()
+	list := make([]*ViewStatus, 0, len(m.views))
+	for _, v := range m.views {
+		list = append(list, v.status())
+	}
+	m.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Query runs read-only SQL over the view tables on behalf of the named
+// view, returning at most limit rows. An empty query selects the view's
+// latest rows.
+func (m *Views) Query(ctx context.Context, name, query string, limit int) (*sqlitestore.ViewRows, error) {
+	if _, err := m.Get(name); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(query) == "" {
+		query = "SELECT * FROM " + name + " ORDER BY _offset DESC"
+	}
+	rows, err := m.db.Query(ctx, query, limit)
+	if err != nil && ctx.Err() == nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidViewQuery, err)
+	}
+	return rows, err
+}
+
+// follow keeps a view's table up to date with its topic until ctx is done
+func (m *Views) follow(ctx context.Context, v *view) {
+	for {
+		err := m.catchUp(ctx, v)
+		if ctx.Err() != nil {
+			return
+		}
+		v.mu.Lock()
+		if v.error != err.Error() {
+			log.Printf("[engine] view %s: %v; retrying every %s", v.config.Name, err, viewRetryInterval)
+		}
+		v.error = err.Error()
+		v.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(viewRetryInterval):
+		}
+	}
+}
+
+// catchUp drains the view's topic into its table, starting over if the
+// topic was recreated since the view last followed it
+func (m *Views) catchUp(ctx context.Context, v *view) error {
+	e := m.engine
+	meta, err := e.topicStore.GetMeta(v.config.Topic)
+	if err != nil {
+		return err
+	}
+	if meta == nil {
+		return fmt.Errorf("%w: %s", store.ErrTopicNotFound, v.config.Topic)
+	}
+	v.mu.Lock()
+	rebuild := !v.created.IsZero() && !v.created.Equal(meta.CreatedAt)
+	v.created = meta.CreatedAt
+	v.error = ""
+	from := v.next
+	v.mu.Unlock()
+	if rebuild {
+		if err := m.db.CreateTable(ctx, v.config.Name, v.config.Columns); err != nil {
+			return err
+		}
+		v.mu.Lock()
+		v.next, v.rows, from = 0, 0, 0
+		v.mu.Unlock()
+	}
+
+	err = e.Drain(ctx, v.config.Topic, from, func(msg Message) error {
+		if err := m.db.Insert(ctx, v.config.Name, msg.Offset, msg.Timestamp.UnixMilli(), msg.Key, msg.Value, v.paths); err != nil {
+			return err
+		}
+		v.mu.Lock()
+		v.next = msg.Offset + 1
+		v.rows++
+		v.mu.Unlock()
+		return nil
+	})
+	if err == nil {
+		err = errors.New("stopped following topic")
+	}
+	return err
+}
+
+func (v *view) status() *ViewStatus {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return &ViewStatus{ViewConfig: v.config, NextOffset: v.next, Rows: v.rows, Error: v.error}
+}
+
+// Views returns the materialized view manager
+func (e *Engine) Views() *Views {
+	return e.views
+}
@@ -0,0 +1,243 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/ids"
+)
+
+// WaitCondition is something a CI pipeline waits for instead of polling:
+// a topic's high watermark reaching Offset, or with Group set, the group
+// having consumed everything on the topic (lag zero)
+type WaitCondition struct {
+	Topic  string `json:"topic"`
+	Offset int64  `json:"offset,omitempty"` // high watermark to reach; ignored with Group
+	Group  string `json:"group,omitempty"`
+}
+
+// WaitStatus is a wait condition as it stands
+type WaitStatus struct {
+	WaitCondition
+	Met           bool   `json:"met"`
+	HighWatermark int64  `json:"high_watermark"`      // offset the next message will get
+	Committed     *int64 `json:"committed,omitempty"` // the group's committed offset, -1 if none
+	Lag           *int64 `json:"lag,omitempty"`
+}
+
+// Validate checks a wait condition names what it waits for
+func (c WaitCondition) Validate() error {
+	if c.Topic == "" {
+		return errors.New("topic is required")
+	}
+	if c.Group == "" && c.Offset <= 0 {
+		return errors.New("offset must be positive unless waiting for a group")
+	}
+	return nil
+}
+
+// CheckWait evaluates a wait condition once. A topic that does not exist
+// yet has a high watermark of 0, so waits can start before producers do.
+func (e *Engine) CheckWait(c WaitCondition) WaitStatus {
+	st := WaitStatus{WaitCondition: c}
+	if latest, err := e.LatestOffset(c.Topic); err == nil {
+		st.HighWatermark = latest + 1
+	}
+	if c.Group == "" {
+		st.Met = st.HighWatermark >= c.Offset
+		return st
+	}
+
+	committed, err := e.FetchOffset(c.Group, c.Topic, 0)
+	if err != nil {
+		committed = -1
+	}
+	lag := st.HighWatermark
+	if committed >= 0 {
+		lag = max(st.HighWatermark-committed, 0)
+	}
+	st.Committed, st.Lag = &committed, &lag
+	st.Met = lag == 0
+	return st
+}
+
+// WaitFor blocks until a wait condition is met or ctx is done, checking
+// it every scheduler tick. It returns the last status, with ctx's error
+// if the condition was not met.
+func (e *Engine) WaitFor(ctx context.Context, c WaitCondition) (WaitStatus, error) {
+	ticker := time.NewTicker(e.config.Scheduler.TickInterval)
+	defer ticker.Stop()
+	for {
+		st := e.CheckWait(c)
+		if st.Met {
+			return st, nil
+		}
+		select {
+		case <-ctx.Done():
+			return st, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// WaitHook calls a webhook once its wait condition is met, or when it
+// expires unmet
+type WaitHook struct {
+	ID        string        `json:"id"`
+	Condition WaitCondition `json:"condition"`
+	Webhook   string        `json:"webhook"`
+	Created   time.Time     `json:"created"`
+	Expires   time.Time     `json:"expires"`
+	cancel    context.CancelFunc
+}
+
+// WaitHooks tracks the wait hooks still waiting
+type WaitHooks struct {
+	engine *Engine
+	client *http.Client
+	mu     sync.Mutex
+	hooks  map[string]*WaitHook
+}
+
+// NewWaitHooks creates an empty set of wait hooks
+func NewWaitHooks(engine *Engine) *WaitHooks {
+	return &WaitHooks{
+		engine: engine,
+		// Redirects are not followed, so a webhook cannot lead off the
+		// allowed hosts
+		client: &http.Client{
+			Timeout:       5 * time.Second,
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		hooks: make(map[string]*WaitHook),
+	}
+}
+
+// Add registers a hook POSTing to webhook when c is met, giving up after
+// timeout. It waits in the background until then or until the engine stops.
+// The webhook must be on a host listed in waits.webhooks, and at most
+// waits.max_hooks wait at once, each for at most waits.max_timeout.
+func (h *WaitHooks) Add(c WaitCondition, webhook string, timeout time.Duration) (WaitHook, error) {
+	cfg := h.engine.config.Waits
+	if err := c.Validate(); err != nil {
+		return WaitHook{}, err
+	}
+	if webhook == "" {
+		return WaitHook{}, errors.New("webhook is required")
+	}
+	if err := checkWebhook(webhook, cfg.Webhooks); err != nil {
+		return WaitHook{}, err
+	}
+	if timeout <= 0 || timeout > cfg.MaxTimeout {
+		return WaitHook{}, fmt.Errorf("timeout must be positive and at most %v, got %v", cfg.MaxTimeout, timeout)
+	}
+
+	now := time.Now()
+	ctx, cancel := context.WithTimeout(h.engine.ctx, timeout)
+	hook := &WaitHook{
+		ID:        ids.Hex(8),
+		Condition: c,
+		Webhook:   webhook,
+		Created:   now,
+		Expires:   now.Add(timeout),
+		cancel:    cancel,
+	}
+
+	h.mu.Lock()
+	if len(h.hooks) >= cfg.MaxHooks {
+		h.mu.Unlock()
+		cancel()
+		return WaitHook{}, fmt.Errorf("%w: %d waiting", ErrTooManyWaitHooks, cfg.MaxHooks)
+	}
+	h.hooks[hook.ID] = hook
+	h.mu.Unlock()
+
+	go h.run(ctx, hook)
+	return *hook, nil
+}
+
+// checkWebhook checks a webhook is an http or https URL on one of the
+// allowed hosts, each a host name matching any port or a host:port
+func checkWebhook(webhook string, allowed []string) error {
+	u, err := url.Parse(webhook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook must be an http or https URL, got %q", webhook)
+	}
+	for _, host := range allowed {
+		if strings.EqualFold(host, u.Host) || strings.EqualFold(host, u.Hostname()) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrWebhookNotAllowed, u.Host)
+}
+
+func (h *WaitHooks) run(ctx context.Context, hook *WaitHook) {
+	defer hook.cancel()
+	st, err := h.engine.WaitFor(ctx, hook.Condition)
+
+	h.mu.Lock()
+	_, waiting := h.hooks[hook.ID]
+	delete(h.hooks, hook.ID)
+	h.mu.Unlock()
+
+	// Removed hooks and a stopping engine call nobody
+	if !waiting || h.engine.ctx.Err() != nil {
+		return
+	}
+	status := "met"
+	if err != nil {
+		status = "expired"
+	}
+	log.Printf("[engine] wait hook %s %s on %s", hook.ID, status, hook.Condition.Topic)
+
+	body, _ := json.Marshal(map[string]interface{}{"id": hook.ID, "status": status, "wait": st})
+	resp, err := h.client.Post(hook.Webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("[engine] wait hook %s webhook failed: %v", hook.ID, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("[engine] wait hook %s webhook returned %s", hook.ID, resp.Status)
+	}
+}
+
+// List returns the hooks still waiting, oldest first
+func (h *WaitHooks) List() []WaitHook {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hooks := make([]WaitHook, 0, len(h.hooks))
+	for _, hook := range h.hooks {
+		hooks = append(hooks, *hook)
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].Created.Before(hooks[j].Created) })
+	return hooks
+}
+
+// Remove cancels a waiting hook without calling its webhook. It is false
+// if no hook has that ID.
+func (h *WaitHooks) Remove(id string) bool {
+	h.mu.Lock()
+	hook, ok := h.hooks[id]
+	delete(h.hooks, id)
+	h.mu.Unlock()
+	if ok {
+		hook.cancel()
+	}
+	return ok
+}
+
+// WaitHooks returns the engine's wait hooks
+func (e *Engine) WaitHooks() *WaitHooks {
+	return e.waitHooks
+}
@@ -0,0 +1,120 @@
+package generate
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// funcs returns the functions templates can call. They draw from the
+// generator's seeded source, so output is reproducible.
+func (g *Generator) funcs() template.FuncMap {
+	return template.FuncMap{
+		"seq":        func() int64 { return g.spec.SeqStart + int64(g.n) },
+		"mod":        func(a, b int64) int64 { return a % max(b, 1) },
+		"add":        func(a, b int64) int64 { return a + b },
+		"pad":        func() string { return padMarker },
+		"timestamp":  func() string { return g.ts.UTC().Format(time.RFC3339Nano) },
+		"unixmilli":  func() int64 { return g.ts.UnixMilli() },
+		"uuid":       g.uuid,
+		"hex":        func(n int) string { return hex.EncodeToString(g.bytes(n)) },
+		"int":        g.intn,
+		"float":      func(lo, hi float64) float64 { return lo + g.rng.Float64()*(hi-lo) },
+		"bool":       func() bool { return g.rng.Intn(2) == 1 },
+		"pick":       g.pick,
+		"first_name": func() string { return g.from1(firstNames) },
+		"last_name":  func() string { return g.from1(lastNames) },
+		"name":       func() string { return g.from1(firstNames) + " " + g.from1(lastNames) },
+		"email":      g.email,
+		"word":       func() string { return g.from1(words) },
+		"sentence":   g.sentence,
+		"city":       func() string { return g.from1(cities) },
+		"country":    func() string { return g.from1(countries) },
+		"ip":         func() string { b := g.bytes(4); return fmt.Sprintf("%d.%d.%d.%d", b[0], b[1], b[2], b[3]) },
+	}
+}
+
+func (g *Generator) from1(list []string) string {
+	return list[g.rng.Intn(len(list))]
+}
+
+func (g *Generator) bytes(n int) []byte {
+	b := make([]byte, max(n, 0))
+	g.rng.Read(b)
+	return b
+}
+
+// intn returns an integer in [lo, hi]
+func (g *Generator) intn(lo, hi int) (int, error) {
+	if hi < lo {
+		return 0, fmt.Errorf("int: %d is below %d", hi, lo)
+	}
+	return lo + g.rng.Intn(hi-lo+1), nil
+}
+
+func (g *Generator) pick(choices ...string) (string, error) {
+	if len(choices) == 0 {
+		return "", fmt.Errorf("pick needs at least one choice")
+	}
+	return choices[g.rng.Intn(len(choices))], nil
+}
+
+// uuid returns a random (version 4) UUID
+func (g *Generator) uuid() string {
+	b := g.bytes(16)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func (g *Generator) email() string {
+	return strings.ToLower(g.from1(firstNames)+"."+g.from1(lastNames)) + "@" + g.from1(domains)
+}
+
+// sentence returns a capitalized sentence of 4 to 12 words
+func (g *Generator) sentence() string {
+	n := 4 + g.rng.Intn(9)
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = g.from1(words)
+	}
+	s := strings.Join(parts, " ")
+	return strings.ToUpper(s[:1]) + s[1:] + "."
+}
+
+var firstNames = []string{
+	"Ada", "Alan", "Amara", "Ana", "Ben", "Chen", "Dara", "Diego", "Elena", "Eli",
+	"Fatima", "Grace", "Hana", "Ivan", "Jamal", "Julia", "Kai", "Lars", "Leila", "Luca",
+	"Maya", "Mateo", "Nia", "Noah", "Omar", "Priya", "Rafael", "Rina", "Sam", "Sofia",
+	"Tariq", "Uma", "Victor", "Wen", "Yuki", "Zara",
+}
+
+var lastNames = []string{
+	"Adeyemi", "Andersen", "Bauer", "Costa", "Dubois", "Evans", "Fischer", "Garcia", "Hakimi", "Ito",
+	"Jensen", "Kowalski", "Kumar", "Larsen", "Lopez", "Moreau", "Nakamura", "Novak", "Okafor", "Park",
+	"Petrov", "Quinn", "Rossi", "Santos", "Schmidt", "Silva", "Tanaka", "Varga", "Wang", "Yilmaz",
+}
+
+var domains = []string{"example.com", "example.org", "example.net", "mail.test", "corp.test"}
+
+var cities = []string{
+	"Amsterdam", "Bangkok", "Berlin", "Bogota", "Cairo", "Jakarta", "Lagos", "Lima", "Lisbon", "London",
+	"Madrid", "Melbourne", "Mumbai", "Nairobi", "Osaka", "Paris", "Seoul", "Toronto", "Warsaw", "Zurich",
+}
+
+var countries = []string{
+	"Australia", "Brazil", "Canada", "Colombia", "Egypt", "France", "Germany", "India", "Indonesia", "Japan",
+	"Kenya", "Mexico", "Netherlands", "Nigeria", "Peru", "Poland", "Portugal", "Spain", "Thailand", "United Kingdom",
+}
+
+var words = []string{
+	"alpha", "amber", "anchor", "apple", "arrow", "aspen", "atlas", "autumn", "basin", "beacon",
+	"birch", "blaze", "breeze", "bridge", "canyon", "cedar", "cinder", "clover", "comet", "coral",
+	"crest", "delta", "drift", "ember", "falcon", "fern", "field", "flint", "forest", "frost",
+	"garden", "glacier", "granite", "harbor", "hazel", "horizon", "island", "ivory", "jade", "juniper",
+	"lagoon", "lantern", "lemon", "maple", "meadow", "mesa", "mist", "nectar", "oak", "ocean",
+	"orbit", "pebble", "pine", "prairie", "quartz", "raven", "ridge", "river", "saffron", "shadow",
+	"signal", "silver", "spruce", "stone", "summit", "thunder", "timber", "valley", "willow", "zephyr",
+}
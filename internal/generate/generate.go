@@ -0,0 +1,372 @@
+// Package generate produces synthetic messages from templates, so load
+// tests can seed topics without each keeping its own script. Keys, values
+// and headers are text/template templates with fake-data functions
+// ({{name}}, {{email}}, {{int 1 100}}, ...) and a running {{seq}}; value
+// sizes can follow a distribution and timestamps be spread over a window.
+// A spec and its seed always generate the same messages.
+package generate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// MaxCount is the most messages one spec may generate
+const MaxCount = 1_000_000
+
+// ErrInvalidSpec is returned for a spec that cannot generate messages
+var ErrInvalidSpec = errors.New("invalid generator spec")
+
+// Spec describes the messages to generate
+type Spec struct {
+	Count      int               `json:"count"`
+	Seed       int64             `json:"seed,omitempty"`       // 0 picks one at random
+	SeqStart   int64             `json:"seq_start,omitempty"`  // first value of {{seq}}
+	Key        string            `json:"key,omitempty"`        // template; empty for no key
+	Value      string            `json:"value,omitempty"`      // template; empty for random text of the chosen size
+	Headers    map[string]string `json:"headers,omitempty"`    // header name to value template
+	Size       *SizeSpec         `json:"size,omitempty"`       // value size; a value template must use {{pad}}
+	Timestamps *TimestampSpec    `json:"timestamps,omitempty"` // default: the broker's append time
+}
+
+// SizeSpec is the distribution of value sizes in bytes. Sizes are clamped
+// to [Min, Max] when Max is set.
+type SizeSpec struct {
+	Distribution string `json:"distribution"` // fixed, uniform, normal or exponential
+	Min          int    `json:"min,omitempty"`
+	Max          int    `json:"max,omitempty"`
+	Mean         int    `json:"mean,omitempty"`   // fixed, normal and exponential
+	StdDev       int    `json:"stddev,omitempty"` // normal
+}
+
+// TimestampSpec spreads message timestamps over the window of length
+// Spread ending at End
+type TimestampSpec struct {
+	Spread string    `json:"spread"`          // Go duration, e.g. 24h
+	End    time.Time `json:"end,omitempty"`   // default: now
+	Order  string    `json:"order,omitempty"` // even (default, ascending and evenly spaced) or random
+}
+
+// padMarker stands in for {{pad}} until the rest of the value is rendered
+// and its length known
+const padMarker = "\x00pad\x00"
+
+// Generator produces the messages of a spec, in order
+type Generator struct {
+	spec    Spec
+	rng     *rand.Rand
+	key     *template.Template
+	value   *template.Template
+	headers map[string]*template.Template
+	names   []string // header names, sorted so output follows the seed
+
+	from         time.Time // start of the timestamp window
+	spread, step time.Duration
+
+	n   int       // messages generated so far
+	ts  time.Time // timestamp of the message being generated
+	buf bytes.Buffer
+}
+
+// New validates a spec and returns its generator
+func New(spec Spec) (*Generator, error) {
+	if spec.Count <= 0 || spec.Count > MaxCount {
+		return nil, fmt.Errorf("%w: count must be between 1 and %d", ErrInvalidSpec, MaxCount)
+	}
+	if spec.Seed == 0 {
+		spec.Seed = rand.Int63()
+	}
+	g := &Generator{spec: spec, rng: rand.New(rand.NewSource(spec.Seed))}
+
+	var err error
+	if g.key, err = g.parse("key", spec.Key); err != nil {
+		return nil, err
+	}
+	if g.value, err = g.parse("value", spec.Value); err != nil {
+		return nil, err
+	}
+	if len(spec.Headers) > 0 {
+		g.headers = make(map[string]*template.Template, len(spec.Headers))
+		for name, text := range spec.Headers {
+			if g.headers[name], err = g.parse("header "+name, text); err != nil {
+				return nil, err
+			}
+			g.names = append(g.names, name)
+		}
+		sort.Strings(g.names)
+	}
+	for _, tmpl := range g.headers {
+		if usesFunc(tmpl, "pad") {
+			return nil, fmt.Errorf("%w: {{pad}} is only allowed in the value", ErrInvalidSpec)
+		}
+	}
+	if usesFunc(g.key, "pad") {
+		return nil, fmt.Errorf("%w: {{pad}} is only allowed in the value", ErrInvalidSpec)
+	}
+
+	if s := spec.Size; s != nil {
+		if err := s.validate(); err != nil {
+			return nil, err
+		}
+		if g.value != nil && !usesFunc(g.value, "pad") {
+			return nil, fmt.Errorf("%w: a value template with a size must use {{pad}}", ErrInvalidSpec)
+		}
+	}
+
+	if t := spec.Timestamps; t != nil {
+		spread, err := time.ParseDuration(t.Spread)
+		if err != nil || spread <= 0 {
+			return nil, fmt.Errorf("%w: timestamps.spread must be a positive duration", ErrInvalidSpec)
+		}
+		switch t.Order {
+		case "", "even", "random":
+		default:
+			return nil, fmt.Errorf("%w: timestamps.order must be even or random", ErrInvalidSpec)
+		}
+		end := t.End
+		if end.IsZero() {
+			end = time.Now()
+		}
+		g.spread = spread
+		g.from = end.Add(-spread)
+		g.step = spread / time.Duration(spec.Count)
+	}
+	return g, nil
+}
+
+// Seed returns the seed the generator runs with, to reproduce its output
+func (g *Generator) Seed() int64 {
+	return g.spec.Seed
+}
+
+// Remaining returns how many messages are left to generate
+func (g *Generator) Remaining() int {
+	return g.spec.Count - g.n
+}
+
+// Next generates the next message. It returns false once Count messages
+// have been generated.
+func (g *Generator) Next() (store.Record, bool, error) {
+	if g.n >= g.spec.Count {
+		return store.Record{}, false, nil
+	}
+	g.ts = g.timestamp()
+
+	var rec store.Record
+	if g.spec.Timestamps != nil {
+		rec.Timestamp = g.ts.UnixMilli()
+	}
+	if g.key != nil {
+		key, err := g.render(g.key)
+		if err != nil {
+			return rec, false, err
+		}
+		rec.Key = []byte(key)
+	}
+	value, err := g.renderValue()
+	if err != nil {
+		return rec, false, err
+	}
+	rec.Value = value
+	for _, name := range g.names {
+		v, err := g.render(g.headers[name])
+		if err != nil {
+			return rec, false, err
+		}
+		if rec.Headers == nil {
+			rec.Headers = make(map[string][]byte, len(g.headers))
+		}
+		rec.Headers[name] = []byte(v)
+	}
+	g.n++
+	return rec, true, nil
+}
+
+// Batch generates up to n messages
+func (g *Generator) Batch(n int) ([]store.Record, error) {
+	records := make([]store.Record, 0, min(n, g.Remaining()))
+	for len(records) < n {
+		rec, ok, err := g.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+func (g *Generator) parse(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(g.funcs()).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSpec, err)
+	}
+	return tmpl, nil
+}
+
+// usesFunc reports whether a template calls the named function
+func usesFunc(tmpl *template.Template, name string) bool {
+	if tmpl == nil || tmpl.Tree == nil {
+		return false
+	}
+	var walk func(node parse.Node) bool
+	walk = func(node parse.Node) bool {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return false
+			}
+			for _, child := range n.Nodes {
+				if walk(child) {
+					return true
+				}
+			}
+		case *parse.ActionNode:
+			return walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return false
+			}
+			for _, cmd := range n.Cmds {
+				if walk(cmd) {
+					return true
+				}
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				if walk(arg) {
+					return true
+				}
+			}
+		case *parse.IdentifierNode:
+			return n.Ident == name
+		case *parse.IfNode:
+			return walk(n.Pipe) || walk(n.List) || walk(n.ElseList)
+		case *parse.RangeNode:
+			return walk(n.Pipe) || walk(n.List) || walk(n.ElseList)
+		case *parse.WithNode:
+			return walk(n.Pipe) || walk(n.List) || walk(n.ElseList)
+		}
+		return false
+	}
+	return walk(tmpl.Tree.Root)
+}
+
+func (g *Generator) render(tmpl *template.Template) (string, error) {
+	g.buf.Reset()
+	if err := tmpl.Execute(&g.buf, nil); err != nil {
+		return "", fmt.Errorf("message %d: %w", g.n, err)
+	}
+	return g.buf.String(), nil
+}
+
+// renderValue renders the value and fills {{pad}} up to the chosen size
+func (g *Generator) renderValue() ([]byte, error) {
+	size := -1
+	if g.spec.Size != nil {
+		size = g.spec.Size.sample(g.rng)
+	}
+	if g.value == nil {
+		return g.text(max(size, 0)), nil
+	}
+	v, err := g.render(g.value)
+	if err != nil {
+		return nil, err
+	}
+	i := strings.Index(v, padMarker)
+	if i < 0 {
+		return []byte(v), nil
+	}
+	rest := strings.ReplaceAll(v, padMarker, "")
+	fill := max(size-len(rest), 0)
+	out := make([]byte, 0, len(rest)+fill)
+	out = append(out, rest[:i]...)
+	out = append(out, g.text(fill)...)
+	return append(out, rest[i:]...), nil
+}
+
+// text returns n bytes of lowercase words
+func (g *Generator) text(n int) []byte {
+	out := make([]byte, 0, n+16)
+	for len(out) < n {
+		if len(out) > 0 {
+			out = append(out, ' ')
+		}
+		out = append(out, words[g.rng.Intn(len(words))]...)
+	}
+	return out[:n]
+}
+
+func (g *Generator) timestamp() time.Time {
+	if g.spec.Timestamps == nil {
+		return time.Now()
+	}
+	var at time.Duration
+	if g.spec.Timestamps.Order == "random" {
+		at = time.Duration(g.rng.Int63n(int64(g.spread)))
+	} else {
+		at = g.step * time.Duration(g.n)
+	}
+	return g.from.Add(at)
+}
+
+func (s *SizeSpec) validate() error {
+	if s.Min < 0 || s.Max < 0 || s.Mean < 0 || s.StdDev < 0 {
+		return fmt.Errorf("%w: sizes cannot be negative", ErrInvalidSpec)
+	}
+	if s.Max > 0 && s.Min > s.Max {
+		return fmt.Errorf("%w: size.min is above size.max", ErrInvalidSpec)
+	}
+	switch s.Distribution {
+	case "fixed", "exponential":
+		if s.Mean <= 0 {
+			return fmt.Errorf("%w: a %s size needs a mean", ErrInvalidSpec, s.Distribution)
+		}
+	case "uniform":
+		if s.Max <= 0 {
+			return fmt.Errorf("%w: a uniform size needs a max", ErrInvalidSpec)
+		}
+	case "normal":
+		if s.Mean <= 0 || s.StdDev <= 0 {
+			return fmt.Errorf("%w: a normal size needs a mean and stddev", ErrInvalidSpec)
+		}
+	default:
+		return fmt.Errorf("%w: size.distribution must be fixed, uniform, normal or exponential", ErrInvalidSpec)
+	}
+	return nil
+}
+
+// sample draws a size
+func (s *SizeSpec) sample(rng *rand.Rand) int {
+	var size float64
+	switch s.Distribution {
+	case "fixed":
+		size = float64(s.Mean)
+	case "uniform":
+		size = float64(s.Min + rng.Intn(s.Max-s.Min+1))
+	case "normal":
+		size = rng.NormFloat64()*float64(s.StdDev) + float64(s.Mean)
+	case "exponential":
+		size = rng.ExpFloat64() * float64(s.Mean)
+	}
+	size = math.Max(size, float64(s.Min))
+	if s.Max > 0 {
+		size = math.Min(size, float64(s.Max))
+	}
+	return int(math.Round(size))
+}
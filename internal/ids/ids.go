@@ -0,0 +1,67 @@
+// Package ids supplies the random bytes behind the identifiers monolog
+// hands out: topic IDs, group member IDs, request IDs and the tokens of
+// async produces, imports and wait hooks. They come from crypto/rand
+// unless Seed switches to a seeded generator, which makes a run that sends
+// the same requests in the same order get the same identifiers, for golden
+// tests and replays of recorded traffic.
+package ids
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	mrand "math/rand/v2"
+	"sync"
+)
+
+var (
+	mu     sync.Mutex
+	seeded *mrand.ChaCha8 // nil while identifiers come from crypto/rand
+)
+
+// Seed derives every identifier from here on from seed
+func Seed(seed uint64) {
+	var key [32]byte
+	binary.LittleEndian.PutUint64(key[:], seed)
+	mu.Lock()
+	defer mu.Unlock()
+	seeded = mrand.NewChaCha8(key)
+}
+
+// Seeded reports whether identifiers are deterministic
+func Seeded() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return seeded != nil
+}
+
+// Read fills b with random bytes
+func Read(b []byte) {
+	mu.Lock()
+	defer mu.Unlock()
+	if seeded == nil {
+		rand.Read(b)
+		return
+	}
+	for len(b) > 0 {
+		var word [8]byte
+		binary.LittleEndian.PutUint64(word[:], seeded.Uint64())
+		b = b[copy(b, word[:]):]
+	}
+}
+
+// Hex returns n random bytes, hex-encoded
+func Hex(n int) string {
+	b := make([]byte, n)
+	Read(b)
+	return hex.EncodeToString(b)
+}
+
+// UUID returns a random (version 4) UUID
+func UUID() [16]byte {
+	var id [16]byte
+	Read(id[:])
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return id
+}
@@ -0,0 +1,117 @@
+// Package redact keeps record contents and credentials out of log output.
+// Log lines that would show a key, value or other payload render it with
+// Bytes, which gives only its size; credentials registered with SetSecrets
+// are masked in everything written through a Writer. Unsafe logging shows
+// payloads in full, for dumps on a developer's own machine; credentials
+// stay masked and authentication payloads are never shown.
+package redact
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Mask replaces a credential in log output
+const Mask = "[REDACTED]"
+
+// maxUnsafeBytes caps how much of a payload an unsafe log line shows
+const maxUnsafeBytes = 1024
+
+var unsafe atomic.Bool
+
+// SetUnsafe turns unsafe logging on or off
+func SetUnsafe(on bool) {
+	unsafe.Store(on)
+}
+
+// Unsafe reports whether unsafe logging is on
+func Unsafe() bool {
+	return unsafe.Load()
+}
+
+// Bytes renders a payload for a log line: its size, or with unsafe logging
+// on, its contents quoted
+func Bytes(b []byte) string {
+	if b == nil {
+		return "<nil>"
+	}
+	if !Unsafe() {
+		return fmt.Sprintf("<%d bytes>", len(b))
+	}
+	if len(b) > maxUnsafeBytes {
+		return fmt.Sprintf("%q... (%d bytes)", b[:maxUnsafeBytes], len(b))
+	}
+	return fmt.Sprintf("%q", b)
+}
+
+// Size renders a payload that carries credentials, such as a SASL
+// message, as its size alone, whether or not unsafe logging is on
+func Size(b []byte) string {
+	if b == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("<%d bytes>", len(b))
+}
+
+// String is Bytes for a string
+func String(s string) string {
+	return Bytes([]byte(s))
+}
+
+var secrets struct {
+	mu      sync.RWMutex
+	sources map[string][]string
+	all     [][]byte // longest first, so a secret containing another is masked whole
+}
+
+// SetSecrets sets the credentials from one source (security tokens, say)
+// to mask, replacing what that source set before
+func SetSecrets(source string, values ...string) {
+	secrets.mu.Lock()
+	defer secrets.mu.Unlock()
+	if secrets.sources == nil {
+		secrets.sources = make(map[string][]string)
+	}
+	secrets.sources[source] = values
+
+	secrets.all = secrets.all[:0]
+	for _, vs := range secrets.sources {
+		for _, v := range vs {
+			if v != "" {
+				secrets.all = append(secrets.all, []byte(v))
+			}
+		}
+	}
+	sort.Slice(secrets.all, func(i, j int) bool { return len(secrets.all[i]) > len(secrets.all[j]) })
+}
+
+// Writer masks registered secrets in what is written through it. The log
+// package writes each line with one call, so a secret is never split
+// across writes. Secrets are masked with unsafe logging on too.
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter returns a Writer writing to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	out := p
+	secrets.mu.RLock()
+	for _, s := range secrets.all {
+		if bytes.Contains(out, s) {
+			out = bytes.ReplaceAll(out, s, []byte(Mask))
+		}
+	}
+	secrets.mu.RUnlock()
+	if _, err := w.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
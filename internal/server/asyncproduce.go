@@ -0,0 +1,44 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// produceAsync queues records without waiting for them to be stored and
+// answers 202 with a token to poll at /api/produce-status/{token}
+func (s *HTTPServer) produceAsync(w http.ResponseWriter, topicName string, records []store.Record) {
+	st, err := s.engine.AsyncProduces().Submit(topicName, records)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	w.Header().Set("Location", s.basePath+"/api/produce-status/"+st.Token)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(st)
+}
+
+// handleProduceStatus reports the outcome of an async produce:
+// GET /api/produce-status/{token} returns it as pending, done (with the
+// offset of the first record) or failed (with the error)
+func (s *HTTPServer) handleProduceStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/produce-status"), "/")
+	if token == "" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	st, err := s.engine.AsyncProduces().Get(token)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	json.NewEncoder(w).Encode(st)
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+)
+
+// Version is the broker version reported by the API; set by main at startup
+var Version = "dev"
+
+// bootstrapInfo is what client helper libraries need to configure a Kafka
+// client against this instance. It never includes credentials.
+type bootstrapInfo struct {
+	Version      string                `json:"version"`
+	Kafka        bootstrapKafka        `json:"kafka"`
+	HTTP         bootstrapHTTP         `json:"http"`
+	Security     bootstrapSecurity     `json:"security"`
+	Capabilities bootstrapCapabilities `json:"capabilities"`
+}
+
+type bootstrapKafka struct {
+	BootstrapServers string `json:"bootstrap_servers"`
+	Host             string `json:"host"`
+	Port             int32  `json:"port"`
+}
+
+type bootstrapHTTP struct {
+	BaseURL     string   `json:"base_url"` // API root as the caller reaches it, e.g. through a proxy
+	APIVersions []string `json:"api_versions"`
+}
+
+type bootstrapSecurity struct {
+	SecurityProtocol string   `json:"security_protocol"` // librdkafka security.protocol
+	SaslMechanisms   []string `json:"sasl_mechanisms,omitempty"`
+	TokenRequired    bool     `json:"token_required"`
+	TLS              bool     `json:"tls"`
+}
+
+type bootstrapCapabilities struct {
+	AutoCreateTopics   bool           `json:"auto_create_topics"`
+	PartitionsPerTopic int            `json:"partitions_per_topic"`
+	Compression        []string       `json:"compression"`
+	KafkaAPIs          []bootstrapAPI `json:"kafka_apis"`
+}
+
+type bootstrapAPI struct {
+	Key        int16  `json:"key"`
+	Name       string `json:"name"`
+	MinVersion int16  `json:"min_version"`
+	MaxVersion int16  `json:"max_version"`
+}
+
+func (s *HTTPServer) handleBootstrap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	host, port := parseAddr(s.config.Server.KafkaAddr)
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsUnspecified()) {
+		// Listening on all interfaces: advertise the host the caller reached us on
+		reached := forwardedHost(r)
+		if reqHost, _, err := net.SplitHostPort(reached); err == nil && reqHost != "" {
+			host = reqHost
+		} else if reached != "" {
+			host = reached
+		}
+	}
+
+	sec := bootstrapSecurity{
+		SecurityProtocol: "PLAINTEXT",
+		TokenRequired:    s.config.Security.Enabled,
+		TLS:              s.config.Security.TLS.Enabled,
+	}
+	switch {
+	case s.config.Security.Enabled && sec.TLS:
+		sec.SecurityProtocol = "SASL_SSL"
+	case s.config.Security.Enabled:
+		sec.SecurityProtocol = "SASL_PLAINTEXT"
+	case sec.TLS:
+		sec.SecurityProtocol = "SSL"
+	}
+	if s.config.Security.Enabled {
+		sec.SaslMechanisms = []string{"PLAIN"}
+	}
+
+	apis := make([]bootstrapAPI, 0)
+	for _, v := range kafkaproto.DefaultApiVersions() {
+		apis = append(apis, bootstrapAPI{
+			Key:        v.APIKey,
+			Name:       kafkaproto.APIKeyName(v.APIKey),
+			MinVersion: v.MinVersion,
+			MaxVersion: v.MaxVersion,
+		})
+	}
+
+	json.NewEncoder(w).Encode(bootstrapInfo{
+		Version: Version,
+		Kafka: bootstrapKafka{
+			BootstrapServers: net.JoinHostPort(host, fmt.Sprint(port)),
+			Host:             host,
+			Port:             port,
+		},
+		HTTP:     bootstrapHTTP{BaseURL: s.externalURL(r, ""), APIVersions: []string{"v1", "v2"}},
+		Security: sec,
+		Capabilities: bootstrapCapabilities{
+			AutoCreateTopics:   s.config.Topics.AutoCreate,
+			PartitionsPerTopic: 1,
+			Compression:        kafkaproto.AvailableCodecNames(),
+			KafkaAPIs:          apis,
+		},
+	})
+}
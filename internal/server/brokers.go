@@ -0,0 +1,136 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net"
+	"strconv"
+
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+)
+
+// ============================================================================
+// Virtual brokers
+//
+// With server.virtual_brokers > 1 the Kafka server poses as a cluster: node
+// N listens on kafka_addr's port + N and every node shares the one engine.
+// Each topic's partition is led by one node and each group is coordinated
+// by one node, both picked by hashing the name, so clients exercise their
+// connection pooling and leader routing as they would against a real
+// cluster. Produce, Fetch and ListOffsets sent to any other node answer
+// NOT_LEADER_OR_FOLLOWER.
+// ============================================================================
+
+// clusterID is the cluster ID every node reports
+const clusterID = "monolog-cluster"
+
+// advertisedBroker is one Kafka node as clients see it
+type advertisedBroker struct {
+	NodeID int32
+	Host   string
+	Port   int32
+}
+
+// brokerCount returns how many nodes this server advertises
+func (s *KafkaServer) brokerCount() int32 {
+	if n := s.config.Server.VirtualBrokers; n > 1 {
+		return int32(n)
+	}
+	return 1
+}
+
+// brokers returns every advertised node, node 0 being kafka_addr itself
+func (s *KafkaServer) brokers() []advertisedBroker {
+	host, port := parseAddr(s.config.Server.KafkaAddr)
+	brokers := make([]advertisedBroker, s.brokerCount())
+	for i := range brokers {
+		brokers[i] = advertisedBroker{NodeID: int32(i), Host: host, Port: port + int32(i)}
+	}
+	return brokers
+}
+
+// broker returns the advertised node with the given ID
+func (s *KafkaServer) broker(nodeID int32) advertisedBroker {
+	return s.brokers()[nodeID]
+}
+
+// checkBrokerResource checks a broker resource names an advertised node.
+// The empty name is the cluster-wide default, which every node shares.
+func (s *KafkaServer) checkBrokerResource(name string) error {
+	if name == "" {
+		return nil
+	}
+	id, err := strconv.ParseInt(name, 10, 32)
+	if err != nil || id < 0 || int32(id) >= s.brokerCount() {
+		return fmt.Errorf("unknown broker %q", name)
+	}
+	return nil
+}
+
+// leaderFor returns the node leading a topic's partition
+func (s *KafkaServer) leaderFor(topic string, partition int32) int32 {
+	return s.nodeFor(topic + "/" + strconv.Itoa(int(partition)))
+}
+
+// coordinatorFor returns the node coordinating a group (or transaction)
+func (s *KafkaServer) coordinatorFor(key string) int32 {
+	return s.nodeFor(key)
+}
+
+func (s *KafkaServer) nodeFor(name string) int32 {
+	n := s.brokerCount()
+	if n == 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return int32(h.Sum32() % uint32(n))
+}
+
+// notLeader returns NOT_LEADER_OR_FOLLOWER when a connection reached a
+// node other than the partition's leader
+func (s *KafkaServer) notLeader(state *connState, topic string, partition int32) int16 {
+	if s.leaderFor(topic, partition) != state.nodeID {
+		return kafkaproto.ErrNotLeaderOrFollower
+	}
+	return kafkaproto.ErrNone
+}
+
+// listenVirtualBrokers binds the ports of nodes 1..N-1 and serves them
+func (s *KafkaServer) listenVirtualBrokers() error {
+	n := s.brokerCount()
+	if n == 1 {
+		return nil
+	}
+
+	bindHost, _, err := net.SplitHostPort(s.config.Server.KafkaAddr)
+	if err != nil {
+		return fmt.Errorf("virtual brokers: %w", err)
+	}
+	var listeners []net.Listener
+	for _, b := range s.brokers()[1:] {
+		addr := net.JoinHostPort(bindHost, strconv.Itoa(int(b.Port)))
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("virtual broker %d: %w", b.NodeID, err)
+		}
+		if s.tlsConfig != nil {
+			ln = tls.NewListener(ln, s.tlsConfig)
+		}
+		listeners = append(listeners, ln)
+	}
+
+	s.mu.Lock()
+	s.nodeListeners = listeners
+	s.mu.Unlock()
+	for i, ln := range listeners {
+		log.Printf("[kafka] virtual broker %d listening on %s", i+1, ln.Addr())
+		go s.serveNode(ln, int32(i+1))
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/rizkyandriawan/monolog/internal/capture"
+)
+
+// handleCapture controls traffic recording:
+// GET returns the active recording, PUT {"file": ...} starts one in the
+// capture directory and DELETE stops it
+func (s *HTTPServer) handleCapture(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		status := s.engine.CaptureStatus()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"active":  status != nil,
+			"capture": status,
+		})
+
+	case http.MethodPut:
+		var req struct {
+			File string `json:"file"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		path, err := s.capturePath(req.File)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.engine.StartCapture(path); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"active":  true,
+			"capture": s.engine.CaptureStatus(),
+		})
+
+	case http.MethodDelete:
+		status, err := s.engine.StopCapture()
+		if status == nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"active":  false,
+			"capture": status,
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// capturePath resolves the file name of a capture started over HTTP to a
+// path in the capture directory, creating the directory. Only bare file
+// names are accepted, so clients cannot write anywhere else.
+func (s *HTTPServer) capturePath(name string) (string, error) {
+	if name == "" {
+		return "", errors.New("file required")
+	}
+	if filepath.IsAbs(name) || strings.Contains(name, "..") || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("file %q must be a bare file name", name)
+	}
+	dir := s.config.Capture.Dir
+	if dir == "" {
+		dir = filepath.Join(s.config.Storage.DataDir, "captures")
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// handleReplay replays a capture file sent as the request body into this
+// instance. ?speed= scales the original timing (0 = as fast as possible)
+// and ?topics= limits playback to a comma-separated topic list. Entries are
+// scrubbed with the capture.scrub rules before they are stored.
+func (s *HTTPServer) handleReplay(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	opts := capture.ReplayOptions{Scrub: s.scrub}
+	if v := r.URL.Query().Get("speed"); v != "" {
+		speed, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			http.Error(w, "invalid speed: "+v, http.StatusBadRequest)
+			return
+		}
+		opts.Speed = speed
+	}
+	if v := r.URL.Query().Get("topics"); v != "" {
+		opts.Topics = strings.Split(v, ",")
+	}
+
+	stats, err := capture.Replay(r.Context(), r.Body, s.engine.ReplaySink(r.Context()), opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// handleClock shows or changes the broker clock's skew. PUT takes
+// {"skew": "-5m", "drift": "30s"}, drift being gained per hour; DELETE
+// puts the clock back on real time.
+func (s *HTTPServer) handleClock(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	clock := s.engine.Clock()
+
+	switch r.Method {
+	case http.MethodGet:
+
+	case http.MethodPut:
+		var req struct {
+			Skew  string `json:"skew"`
+			Drift string `json:"drift"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		skew, err := parseOptionalDuration(req.Skew)
+		if err != nil {
+			http.Error(w, "skew: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		drift, err := parseOptionalDuration(req.Drift)
+		if err != nil {
+			http.Error(w, "drift: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		clock.Set(skew, drift)
+		log.Printf("[http] clock skew set to %v, drift %v/h", skew, drift)
+
+	case http.MethodDelete:
+		clock.Set(0, 0)
+		log.Printf("[http] clock skew cleared")
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	json.NewEncoder(w).Encode(clock.Status())
+}
+
+func parseOptionalDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
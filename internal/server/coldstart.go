@@ -0,0 +1,64 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// maxColdStartTopics caps the missing topics remembered. Names come from
+// clients, so past the cap the oldest are forgotten.
+const maxColdStartTopics = 10000
+
+// coldStart remembers when clients first asked for topics that did not
+// exist, so Metadata can keep answering LEADER_NOT_AVAILABLE for them
+// during the window a real broker would take to create them
+type coldStart struct {
+	mu     sync.Mutex
+	window time.Duration
+	seen   map[string]time.Time
+}
+
+func newColdStart(window time.Duration) *coldStart {
+	return &coldStart{window: window, seen: make(map[string]time.Time)}
+}
+
+// pending reports whether a missing topic is still within its window,
+// starting the window the first time the topic is asked about
+func (c *coldStart) pending(topic string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	first, ok := c.seen[topic]
+	if !ok {
+		if len(c.seen) >= maxColdStartTopics {
+			c.evict(now)
+		}
+		c.seen[topic] = now
+		return true
+	}
+	return c.window <= 0 || now.Sub(first) < c.window
+}
+
+// forget drops a topic once it exists, so a later deletion starts afresh
+func (c *coldStart) forget(topic string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.seen, topic)
+}
+
+// evict drops expired entries, or the oldest when none have expired
+func (c *coldStart) evict(now time.Time) {
+	var oldest string
+	var oldestAt time.Time
+	for topic, first := range c.seen {
+		if c.window > 0 && now.Sub(first) >= c.window {
+			delete(c.seen, topic)
+			continue
+		}
+		if oldest == "" || first.Before(oldestAt) {
+			oldest, oldestAt = topic, first
+		}
+	}
+	if len(c.seen) >= maxColdStartTopics {
+		delete(c.seen, oldest)
+	}
+}
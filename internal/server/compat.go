@@ -0,0 +1,242 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+)
+
+// ============================================================================
+// Protocol compatibility telemetry
+//
+// Records which API keys and versions each client software actually sends,
+// keyed by the name and version it reports in ApiVersions v3+. /api/compat
+// turns this into a report of what our users' clients need, including the
+// requests we could not serve, so protocol gaps can be closed in order of
+// how much they matter.
+// ============================================================================
+
+// unknownSoftware labels clients that never sent ApiVersions v3+
+const unknownSoftware = "unknown"
+
+// maxCompatClients caps the client software entries kept. Names come from
+// clients, so past the cap they are pooled under "other".
+const maxCompatClients = 200
+
+// maxCompatClientIDs caps the client IDs remembered per client software
+const maxCompatClientIDs = 20
+
+type compatKey struct {
+	name    string
+	version string
+}
+
+type apiVersionKey struct {
+	apiKey  int16
+	version int16
+}
+
+type compatClient struct {
+	firstSeen   time.Time
+	lastSeen    time.Time
+	connections int64
+	clientIDs   map[string]bool
+	requests    map[apiVersionKey]int64
+}
+
+// compatTracker counts requests per client software, API key and version
+type compatTracker struct {
+	mu      sync.Mutex
+	clients map[compatKey]*compatClient
+}
+
+func newCompatTracker() *compatTracker {
+	return &compatTracker{clients: make(map[compatKey]*compatClient)}
+}
+
+// record counts one request from a connection
+func (t *compatTracker) record(state *connState, clientID string, apiKey, version int16) {
+	key := compatKey{name: state.softwareName, version: state.softwareVersion}
+	if key.name == "" {
+		key = compatKey{name: unknownSoftware}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c, ok := t.clients[key]
+	if !ok {
+		if len(t.clients) >= maxCompatClients {
+			key = compatKey{name: "other"}
+			c = t.clients[key]
+		}
+		if c == nil {
+			c = &compatClient{
+				firstSeen: time.Now(),
+				clientIDs: make(map[string]bool),
+				requests:  make(map[apiVersionKey]int64),
+			}
+			t.clients[key] = c
+		}
+	}
+
+	c.lastSeen = time.Now()
+	if !state.counted {
+		state.counted = true
+		c.connections++
+	}
+	if clientID != "" && len(c.clientIDs) < maxCompatClientIDs {
+		c.clientIDs[clientID] = true
+	}
+	c.requests[apiVersionKey{apiKey, version}]++
+}
+
+// CompatVersion is the request count for one API version
+type CompatVersion struct {
+	Version   int16 `json:"version"`
+	Requests  int64 `json:"requests"`
+	Supported bool  `json:"supported"`
+}
+
+// CompatAPI is one API key a client software used
+type CompatAPI struct {
+	APIKey     int16           `json:"api_key"`
+	Name       string          `json:"name"`
+	MinVersion int16           `json:"min_supported_version"` // -1 when the API is not supported at all
+	MaxVersion int16           `json:"max_supported_version"`
+	Versions   []CompatVersion `json:"versions"`
+}
+
+// CompatClient is what one client software sent
+type CompatClient struct {
+	Software    string      `json:"software"`
+	Version     string      `json:"version,omitempty"`
+	ClientIDs   []string    `json:"client_ids"`
+	Connections int64       `json:"connections"`
+	FirstSeen   time.Time   `json:"first_seen"`
+	LastSeen    time.Time   `json:"last_seen"`
+	APIs        []CompatAPI `json:"apis"`
+}
+
+// CompatGap is an API version clients asked for that we do not serve
+type CompatGap struct {
+	APIKey   int16    `json:"api_key"`
+	Name     string   `json:"name"`
+	Version  int16    `json:"version"`
+	Requests int64    `json:"requests"`
+	Clients  []string `json:"clients"` // "software version" of each client that asked
+}
+
+// CompatReport is the /api/compat response
+type CompatReport struct {
+	Clients []CompatClient `json:"clients"`
+	Gaps    []CompatGap    `json:"gaps"` // most requested first
+}
+
+// supportedRange returns the versions we serve for an API key
+func supportedRange(apiKey int16) (min, max int16, ok bool) {
+	for _, v := range append(kafkaproto.DefaultApiVersions(), kafkaproto.ExtensionApiVersions()...) {
+		if v.APIKey == apiKey {
+			return v.MinVersion, v.MaxVersion, true
+		}
+	}
+	return -1, -1, false
+}
+
+// Report summarizes the recorded requests
+func (t *compatTracker) Report() CompatReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	report := CompatReport{Clients: []CompatClient{}, Gaps: []CompatGap{}}
+	gaps := make(map[apiVersionKey]*CompatGap)
+
+	for key, c := range t.clients {
+		label := key.name
+		if key.version != "" {
+			label += " " + key.version
+		}
+
+		client := CompatClient{
+			Software:    key.name,
+			Version:     key.version,
+			ClientIDs:   make([]string, 0, len(c.clientIDs)),
+			Connections: c.connections,
+			FirstSeen:   c.firstSeen,
+			LastSeen:    c.lastSeen,
+		}
+		for id := range c.clientIDs {
+			client.ClientIDs = append(client.ClientIDs, id)
+		}
+		sort.Strings(client.ClientIDs)
+
+		apis := make(map[int16]*CompatAPI)
+		for av, n := range c.requests {
+			api, ok := apis[av.apiKey]
+			if !ok {
+				min, max, _ := supportedRange(av.apiKey)
+				api = &CompatAPI{APIKey: av.apiKey, Name: kafkaproto.APIKeyName(av.apiKey), MinVersion: min, MaxVersion: max}
+				apis[av.apiKey] = api
+			}
+			supported := api.MinVersion >= 0 && av.version >= api.MinVersion && av.version <= api.MaxVersion
+			api.Versions = append(api.Versions, CompatVersion{Version: av.version, Requests: n, Supported: supported})
+
+			if !supported {
+				gap, ok := gaps[av]
+				if !ok {
+					gap = &CompatGap{APIKey: av.apiKey, Name: api.Name, Version: av.version}
+					gaps[av] = gap
+				}
+				gap.Requests += n
+				gap.Clients = append(gap.Clients, label)
+			}
+		}
+		for _, api := range apis {
+			sort.Slice(api.Versions, func(i, j int) bool { return api.Versions[i].Version < api.Versions[j].Version })
+			client.APIs = append(client.APIs, *api)
+		}
+		sort.Slice(client.APIs, func(i, j int) bool { return client.APIs[i].APIKey < client.APIs[j].APIKey })
+		report.Clients = append(report.Clients, client)
+	}
+
+	sort.Slice(report.Clients, func(i, j int) bool {
+		a, b := report.Clients[i], report.Clients[j]
+		if a.Software != b.Software {
+			return a.Software < b.Software
+		}
+		return a.Version < b.Version
+	})
+	for _, gap := range gaps {
+		sort.Strings(gap.Clients)
+		report.Gaps = append(report.Gaps, *gap)
+	}
+	sort.Slice(report.Gaps, func(i, j int) bool {
+		a, b := report.Gaps[i], report.Gaps[j]
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		if a.APIKey != b.APIKey {
+			return a.APIKey < b.APIKey
+		}
+		return a.Version < b.Version
+	})
+	return report
+}
+
+// handleCompat reports the API versions each client software has used
+func (s *HTTPServer) handleCompat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	report := CompatReport{Clients: []CompatClient{}, Gaps: []CompatGap{}}
+	if s.kafka != nil {
+		report = s.kafka.compat.Report()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ============================================================================
+// Per-connection statistics
+//
+// Every Kafka connection counts the bytes it sent and received, its
+// requests and when it was last active. /api/connections lists them so a
+// runaway producer on a shared broker can be found by name. With
+// limits.connection_quota set, a connection sending more than that many
+// bytes per second has its reads paused until it is back under the quota.
+// ============================================================================
+
+// connStats counts one connection's traffic
+type connStats struct {
+	remote      string
+	nodeID      int32
+	connectedAt time.Time
+
+	bytesIn      atomic.Int64
+	bytesOut     atomic.Int64
+	requests     atomic.Int64
+	lastActivity atomic.Int64 // unix nanos
+	throttledMs  atomic.Int64
+
+	mu              sync.Mutex
+	clientID        string
+	softwareName    string
+	softwareVersion string
+	principal       string
+	authID          string
+	sessionExpiry   time.Time
+
+	// quota window, only touched by the connection's reader
+	windowStart time.Time
+	windowBytes int64
+	warned      bool
+}
+
+func newConnStats(remote string, nodeID int32) *connStats {
+	now := time.Now()
+	c := &connStats{remote: remote, nodeID: nodeID, connectedAt: now, windowStart: now}
+	c.lastActivity.Store(now.UnixNano())
+	return c
+}
+
+// received counts a request of n bytes, including its size prefix
+func (c *connStats) received(n int) {
+	c.bytesIn.Add(int64(n))
+	c.requests.Add(1)
+	c.lastActivity.Store(time.Now().UnixNano())
+}
+
+// sent counts a response of n bytes
+func (c *connStats) sent(n int) {
+	c.bytesOut.Add(int64(n))
+	c.lastActivity.Store(time.Now().UnixNano())
+}
+
+// identify records who the client says it is
+func (c *connStats) identify(clientID string, state *connState) {
+	c.mu.Lock()
+	c.clientID = clientID
+	c.softwareName = state.softwareName
+	c.softwareVersion = state.softwareVersion
+	c.principal = state.principal
+	c.authID = state.authID
+	c.sessionExpiry = state.sessionExpiry
+	c.mu.Unlock()
+}
+
+// throttleDelay charges n received bytes against a bytes-per-second quota
+// and returns how long to pause reading to get back under it
+func (c *connStats) throttleDelay(n int, quota int64) time.Duration {
+	if quota <= 0 {
+		return 0
+	}
+	now := time.Now()
+	if now.Sub(c.windowStart) >= time.Second {
+		c.windowStart = now
+		c.windowBytes = 0
+	}
+	c.windowBytes += int64(n)
+	if c.windowBytes <= quota {
+		return 0
+	}
+	allowed := time.Duration(float64(c.windowBytes) / float64(quota) * float64(time.Second))
+	return allowed - now.Sub(c.windowStart)
+}
+
+// ConnectionInfo is one open Kafka connection in /api/connections
+type ConnectionInfo struct {
+	Remote          string     `json:"remote"`
+	NodeID          int32      `json:"node_id"`
+	ClientID        string     `json:"client_id"`
+	Software        string     `json:"software,omitempty"`
+	SoftwareVersion string     `json:"software_version,omitempty"`
+	Principal       string     `json:"principal,omitempty"`      // SASL identity the client acts as
+	AuthID          string     `json:"auth_id,omitempty"`        // who authenticated, when impersonating
+	SessionExpiry   *time.Time `json:"session_expiry,omitempty"` // when the client must re-authenticate by
+	ConnectedAt     time.Time  `json:"connected_at"`
+	LastActivity    time.Time  `json:"last_activity"`
+	BytesIn         int64      `json:"bytes_in"`
+	BytesOut        int64      `json:"bytes_out"`
+	Requests        int64      `json:"requests"`
+	ThrottledMs     int64      `json:"throttled_ms"`
+}
+
+func (c *connStats) info() ConnectionInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	info := ConnectionInfo{
+		Remote:          c.remote,
+		NodeID:          c.nodeID,
+		ClientID:        c.clientID,
+		Software:        c.softwareName,
+		SoftwareVersion: c.softwareVersion,
+		Principal:       c.principal,
+		AuthID:          c.authID,
+		ConnectedAt:     c.connectedAt,
+		LastActivity:    time.Unix(0, c.lastActivity.Load()),
+		BytesIn:         c.bytesIn.Load(),
+		BytesOut:        c.bytesOut.Load(),
+		Requests:        c.requests.Load(),
+		ThrottledMs:     c.throttledMs.Load(),
+	}
+	if !c.sessionExpiry.IsZero() {
+		expiry := c.sessionExpiry
+		info.SessionExpiry = &expiry
+	}
+	return info
+}
+
+// ConnectionList returns every open connection, most bytes received first
+func (s *KafkaServer) ConnectionList() []ConnectionInfo {
+	list := []ConnectionInfo{}
+	s.connections.Range(func(_, value interface{}) bool {
+		if stats, ok := value.(*connStats); ok {
+			list = append(list, stats.info())
+		}
+		return true
+	})
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].BytesIn != list[j].BytesIn {
+			return list[i].BytesIn > list[j].BytesIn
+		}
+		return list[i].Remote < list[j].Remote
+	})
+	return list
+}
+
+// TrafficTotals returns the bytes received and sent and the requests
+// served over all connections since startup
+func (s *KafkaServer) TrafficTotals() (bytesIn, bytesOut, requests int64) {
+	return s.bytesIn.Load(), s.bytesOut.Load(), s.requests.Load()
+}
+
+// countReceived counts a request on its connection and the server totals
+func (s *KafkaServer) countReceived(stats *connStats, n int) {
+	stats.received(n)
+	s.bytesIn.Add(int64(n))
+	s.requests.Add(1)
+}
+
+// countSent counts a response on its connection and the server totals
+func (s *KafkaServer) countSent(stats *connStats, n int) {
+	stats.sent(n)
+	s.bytesOut.Add(int64(n))
+}
+
+// throttle pauses a connection's reads while it is over
+// limits.connection_quota, returning false if the connection went away
+func (s *KafkaServer) throttle(stats *connStats, n int, done <-chan struct{}) bool {
+	delay := stats.throttleDelay(n, s.config.Limits.ConnectionQuota)
+	if delay <= 0 {
+		return true
+	}
+	if !stats.warned {
+		stats.warned = true
+		info := stats.info()
+		log.Printf("[kafka] connection %s (client=%s) over quota of %d bytes/s, throttling",
+			info.Remote, info.ClientID, s.config.Limits.ConnectionQuota)
+	}
+	stats.throttledMs.Add(delay.Milliseconds())
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// handleConnections lists open Kafka connections with their traffic
+func (s *HTTPServer) handleConnections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	list := []ConnectionInfo{}
+	if s.kafka != nil {
+		list = s.kafka.ConnectionList()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
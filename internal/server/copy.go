@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/engine"
+)
+
+// copyRequest is the body of POST /api/topics/{name}/copy
+type copyRequest struct {
+	Destination string            `json:"destination"`
+	StartOffset int64             `json:"start_offset"`
+	EndOffset   int64             `json:"end_offset"` // exclusive; 0 for the end of the log
+	From        string            `json:"from"`       // RFC 3339 or unix millis, like exports
+	To          string            `json:"to"`
+	Key         *string           `json:"key"`
+	Headers     map[string]string `json:"headers"`
+	Limit       int               `json:"limit"`
+	DryRun      bool              `json:"dry_run"`
+}
+
+// handleCopy copies a range of the topic's messages, optionally only those
+// with a given key or headers, to another topic
+func (s *HTTPServer) handleCopy(w http.ResponseWriter, r *http.Request, topicName string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req copyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := parseTimeParam(req.From, time.Time{})
+	if err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseTimeParam(req.To, time.Time{})
+	if err != nil {
+		http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !from.IsZero() && !to.IsZero() && !to.After(from) {
+		http.Error(w, "to must be after from", http.StatusBadRequest)
+		return
+	}
+	if req.Limit < 0 {
+		http.Error(w, "limit must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.engine.CopyRange(r.Context(), topicName, engine.CopyRequest{
+		Destination: req.Destination,
+		StartOffset: req.StartOffset,
+		EndOffset:   req.EndOffset,
+		From:        from,
+		To:          to,
+		Match:       engine.MessageMatch{Key: req.Key, Headers: req.Headers},
+		Limit:       req.Limit,
+		DryRun:      req.DryRun,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
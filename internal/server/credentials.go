@@ -0,0 +1,120 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+	"github.com/rizkyandriawan/monolog/internal/redact"
+)
+
+// Credentials are the tokens clients authenticate with. They are swapped
+// in place when secrets are rotated, without dropping connections.
+type Credentials struct {
+	mu         sync.RWMutex
+	token      string
+	admin      string
+	superusers []string
+	users      map[string]string // SASL username -> password
+}
+
+// NewCredentials creates Credentials from the security config
+func NewCredentials(sec config.SecurityConfig) *Credentials {
+	c := &Credentials{}
+	c.Update(sec)
+	return c
+}
+
+// Update replaces the tokens
+func (c *Credentials) Update(sec config.SecurityConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = sec.Token
+	c.admin = sec.AdminToken
+	c.superusers = append([]string{}, sec.Impersonation.SuperuserTokens...)
+	c.users = make(map[string]string, len(sec.Users))
+	secrets := append([]string{c.token, c.admin}, c.superusers...)
+	for user, password := range sec.Users {
+		c.users[user] = password
+		secrets = append(secrets, password)
+	}
+	redact.SetSecrets("security", secrets...)
+}
+
+// Token returns the token clients authenticate with
+func (c *Credentials) Token() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
+// AdminToken returns the token of admin endpoints, "" while they are off
+func (c *Credentials) AdminToken() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.admin
+}
+
+// Superuser reports whether password is a super-user token
+func (c *Credentials) Superuser(password string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, token := range c.superusers {
+		if secretEqual(token, password) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate reports whether password authenticates user: the user's own
+// password when users are configured, the shared token otherwise
+func (c *Credentials) Authenticate(user, password string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.users) == 0 {
+		return secretEqual(c.token, password)
+	}
+	want, ok := c.users[user]
+	return ok && secretEqual(want, password)
+}
+
+// secretEqual compares secrets in constant time
+func secretEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// handleReloadSecrets re-reads the config file and its master key and
+// swaps in the tokens, so rotated or re-encrypted secrets take effect
+// without a restart. Nothing changes if the file fails to load.
+func (s *HTTPServer) handleReloadSecrets(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.config.Path == "" {
+		http.Error(w, "server was started without a config file", http.StatusConflict)
+		return
+	}
+
+	cfg, err := config.Load(s.config.Path)
+	if err != nil {
+		log.Printf("[http] secrets reload from %s failed: %v", s.config.Path, err)
+		http.Error(w, "reload "+s.config.Path+": "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err := checkPrincipalBinding(cfg.Security); err != nil {
+		http.Error(w, "reload "+s.config.Path+": "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	s.creds.Update(cfg.Security)
+	audit("secrets_reload", "remote", r.RemoteAddr, "config", s.config.Path)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reloaded": true,
+		"config":   s.config.Path,
+	})
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// recentErrorsKept is how many request errors the servers remember
+const recentErrorsKept = 50
+
+// RecentError is a request failure kept for operational views
+type RecentError struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"` // client ID of the failing request
+	Message string    `json:"message"`
+}
+
+// errorLog keeps the most recent request errors
+type errorLog struct {
+	mu      sync.Mutex
+	entries []RecentError
+}
+
+// Add records an error from source
+func (l *errorLog) Add(source, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = append(l.entries, RecentError{
+		Time:    time.Now(),
+		Source:  source,
+		Message: fmt.Sprintf(format, args...),
+	})
+	if len(l.entries) > recentErrorsKept {
+		l.entries = l.entries[len(l.entries)-recentErrorsKept:]
+	}
+}
+
+// Recent returns the remembered errors, newest first
+func (l *errorLog) Recent() []RecentError {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := make([]RecentError, len(l.entries))
+	for i, e := range l.entries {
+		out[len(out)-1-i] = e
+	}
+	return out
+}
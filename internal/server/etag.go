@@ -0,0 +1,66 @@
+package server
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// etagEpoch distinguishes collection versions across restarts, since the
+// counters behind them start over with every process
+var etagEpoch = time.Now().UnixNano()
+
+// collectionETag returns the strong ETag for a collection at version
+func collectionETag(collection string, version uint64) string {
+	return fmt.Sprintf(`"%s-%x-%x"`, collection, etagEpoch, version)
+}
+
+// combinedVersion folds the counters a collection's contents depend on
+// into one version
+func combinedVersion(counters ...uint64) uint64 {
+	h := fnv.New64a()
+	for _, c := range counters {
+		fmt.Fprintf(h, "%x;", c)
+	}
+	return h.Sum64()
+}
+
+// namesVersion hashes a sorted list of names into a counter for
+// combinedVersion, which changes whenever the list does
+func namesVersion(names []string) uint64 {
+	h := fnv.New64a()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s;", name)
+	}
+	return h.Sum64()
+}
+
+// checkNotModified sets the ETag header and answers 304 when the request's
+// If-None-Match already names it. Returns true if the response is complete.
+func checkNotModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag,
+// using the weak comparison RFC 9110 prescribes for it
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/rizkyandriawan/monolog/internal/engine"
+)
+
+// handleCrash shows, arms or disarms the crash fault. PUT takes
+// {"point": "append-post-commit", "mode": "kill", "topic": "orders",
+// "skip": 2}; the broker dies the next time it reaches that point. Off
+// unless server.crash_faults is set.
+func (s *HTTPServer) handleCrash(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !s.config.Server.CrashFaults {
+		http.Error(w, "crash faults are disabled; set server.crash_faults to enable them", http.StatusForbidden)
+		return
+	}
+	faults := s.engine.Faults()
+
+	switch r.Method {
+	case http.MethodGet:
+
+	case http.MethodPut:
+		var fault engine.CrashFault
+		if err := json.NewDecoder(r.Body).Decode(&fault); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := faults.Arm(fault); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("[http] crash fault armed at %s (%s)", fault.Point, r.RemoteAddr)
+
+	case http.MethodDelete:
+		faults.Disarm()
+		log.Printf("[http] crash fault disarmed")
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"armed": faults.Armed()})
+}
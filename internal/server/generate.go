@@ -0,0 +1,58 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/rizkyandriawan/monolog/internal/generate"
+)
+
+// generateBatchSize is how many generated messages are produced per append
+const generateBatchSize = 1000
+
+// handleGenerate produces synthetic messages described by a generate.Spec,
+// for seeding load tests
+func (s *HTTPServer) handleGenerate(w http.ResponseWriter, r *http.Request, topicName string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var spec generate.Spec
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	gen, err := generate.New(spec)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	first := int64(-1)
+	count, size := 0, 0
+	for gen.Remaining() > 0 {
+		records, err := gen.Batch(generateBatchSize)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		offset, err := s.engine.Produce(r.Context(), topicName, records)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("%v (%d messages written before the failure)", err, count), errorStatus(err))
+			return
+		}
+		if first < 0 {
+			first = offset
+		}
+		count += len(records)
+		for _, rec := range records {
+			size += len(rec.Key) + len(rec.Value)
+		}
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"offset": first, "count": count, "bytes": size, "seed": gen.Seed(),
+	})
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/rizkyandriawan/monolog/internal/engine"
+)
+
+// listenerState tracks whether a server's listener is bound, and why not
+type listenerState struct {
+	mu    sync.Mutex
+	bound bool
+	err   error
+}
+
+func (l *listenerState) set(bound bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.bound, l.err = bound, err
+}
+
+// check returns nil while the listener is accepting connections
+func (l *listenerState) check() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case l.bound:
+		return nil
+	case l.err != nil:
+		return l.err
+	}
+	return fmt.Errorf("not listening")
+}
+
+// Component states reported by /readyz
+const (
+	componentOK       = "ok"
+	componentDegraded = "degraded" // working with reduced function; still ready
+	componentDown     = "down"     // not ready
+)
+
+type componentStatus struct {
+	Status string      `json:"status"`
+	Error  string      `json:"error,omitempty"`
+	Detail interface{} `json:"detail,omitempty"`
+}
+
+func componentFromError(err error) componentStatus {
+	if err != nil {
+		return componentStatus{Status: componentDown, Error: err.Error()}
+	}
+	return componentStatus{Status: componentOK}
+}
+
+// handleHealthz is the liveness probe: the process is up and serving HTTP
+func (s *HTTPServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": componentOK})
+}
+
+// handleReadyz is the readiness probe. It answers 503 until storage
+// accepts writes with topic metadata loaded, the Kafka listener (if this
+// server has one) is bound and every scheduler is running. Read-only
+// storage is degraded but ready, since consumers can still drain topics.
+func (s *HTTPServer) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	components := map[string]componentStatus{
+		"storage": componentFromError(s.engine.PingStorage(r.Context())),
+	}
+	if s.kafka != nil {
+		components["kafka_listener"] = componentFromError(s.kafka.listenState.check())
+	}
+
+	if disk, ok := s.engine.DiskStatus(); ok {
+		diskStatus := componentStatus{Status: componentOK, Detail: disk}
+		if disk.ReadOnly {
+			diskStatus.Status = componentDegraded
+			diskStatus.Error = engine.ErrReadOnly.Error()
+		}
+		components["disk"] = diskStatus
+	}
+
+	for name, err := range s.engine.Schedulers() {
+		components["scheduler."+name] = componentFromError(err)
+	}
+
+	status, code := componentOK, http.StatusOK
+	for _, c := range components {
+		switch c.Status {
+		case componentDown:
+			status, code = componentDown, http.StatusServiceUnavailable
+		case componentDegraded:
+			if status == componentOK {
+				status = componentDegraded
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":     status,
+		"components": components,
+	})
+}
@@ -2,66 +2,154 @@ package server
 
 import (
 	"bytes"
-	"compress/gzip"
+	"context"
+	"crypto/tls"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"io"
 	"io/fs"
+	"log"
+	"mime"
+	"net"
 	"net/http"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/klauspost/compress/snappy"
-	"github.com/klauspost/compress/zstd"
-	"github.com/pierrec/lz4/v4"
+	"github.com/rizkyandriawan/monolog/internal/capture"
 	"github.com/rizkyandriawan/monolog/internal/config"
 	"github.com/rizkyandriawan/monolog/internal/engine"
-	"github.com/rizkyandriawan/monolog/internal/store"
+	"github.com/rizkyandriawan/monolog/internal/redact"
+	sqlitestore "github.com/rizkyandriawan/monolog/internal/store"
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+	"github.com/rizkyandriawan/monolog/pkg/store"
 	"github.com/rizkyandriawan/monolog/web"
 )
 
 // HTTPServer handles HTTP API and Web UI
 type HTTPServer struct {
-	config *config.Config
-	engine *engine.Engine
-	server *http.Server
+	config   *config.Config
+	engine   *engine.Engine
+	kafka    *KafkaServer
+	ipFilter *IPFilter
+	creds    *Credentials
+	tls      *tls.Config       // nil unless security.tls is enabled
+	scrub    *capture.Scrubber // capture.scrub rules for replays, nil when unset
+	server   *http.Server
+	basePath string // normalized server.base_path, "" when served at the root
 }
 
 // NewHTTPServer creates a new HTTPServer
-func NewHTTPServer(cfg *config.Config, eng *engine.Engine) *HTTPServer {
+func NewHTTPServer(cfg *config.Config, eng *engine.Engine, kafka *KafkaServer) (*HTTPServer, error) {
+	ipFilter, err := NewIPFilter(cfg.Security.IPRules.HTTP)
+	if err != nil {
+		return nil, fmt.Errorf("http ip rules: %w", err)
+	}
+	scrub, err := capture.NewScrubber(cfg.Capture.Scrub)
+	if err != nil {
+		return nil, fmt.Errorf("capture scrub rules: %w", err)
+	}
+	tlsConfig, err := LoadTLS(cfg.Security.TLS)
+	if err != nil {
+		return nil, err
+	}
+
 	s := &HTTPServer{
-		config: cfg,
-		engine: eng,
+		config:   cfg,
+		engine:   eng,
+		kafka:    kafka,
+		ipFilter: ipFilter,
+		scrub:    scrub,
+		tls:      tlsConfig,
+		basePath: normalizeBasePath(cfg.Server.BasePath),
+	}
+	// Share the Kafka listener's tokens so a reload updates both
+	if kafka != nil {
+		s.creds = kafka.Credentials()
+	} else {
+		s.creds = NewCredentials(cfg.Security)
 	}
 
 	mux := http.NewServeMux()
 
-	// API routes
-	mux.HandleFunc("/api/topics", s.authMiddleware(s.handleTopics))
-	mux.HandleFunc("/api/topics/", s.authMiddleware(s.handleTopic))
-	mux.HandleFunc("/api/groups", s.authMiddleware(s.handleGroups))
-	mux.HandleFunc("/api/groups/", s.authMiddleware(s.handleGroup))
-	mux.HandleFunc("/api/pending", s.authMiddleware(s.handlePending))
-	mux.HandleFunc("/api/stats", s.authMiddleware(s.handleStats))
-
-	// Health check (no auth)
-	mux.HandleFunc("/health", s.handleHealth)
-
-	// Static files (Web UI) - TODO: embed
-	mux.HandleFunc("/", s.handleStatic)
+	// API routes (served under /api and /api/v2)
+	s.handleAPI(mux, "/topics", s.handleTopics)
+	s.handleAPI(mux, "/topics/", s.handleTopic)
+	s.handleAPI(mux, "/groups", s.handleGroups)
+	s.handleAPI(mux, "/groups/", s.handleGroup)
+	s.handleAPI(mux, "/pending", s.handlePending)
+	s.handleAPI(mux, "/pending/topics", s.handlePendingTopics)
+	s.handleAPI(mux, "/follower", s.handleFollower)
+	s.handleAPI(mux, "/transactions", s.handleTransactions)
+	s.handleAPI(mux, "/stats", s.handleStats)
+	s.handleAPI(mux, "/admin/ip-rules", s.handleIPRules)
+	s.handleAdminAPI(mux, "/admin/capture", s.handleCapture)
+	s.handleAPI(mux, "/admin/replay", s.handleReplay)
+	s.handleAPI(mux, "/admin/integrity", s.handleIntegrity)
+	s.handleAPI(mux, "/admin/verify", s.handleVerify)
+	s.handleAPI(mux, "/admin/refresh", s.handleRefresh)
+	s.handleAPI(mux, "/admin/secrets/reload", s.handleReloadSecrets)
+	s.handleAPI(mux, "/admin/clock", s.handleClock)
+	s.handleAPI(mux, "/admin/crash", s.handleCrash)
+	s.handleAPI(mux, "/admin/memory", s.handleMemory)
+	s.handleAPI(mux, "/watch", s.handleWatch)
+	s.handleAPI(mux, "/wait", s.handleWait)
+	s.handleAPI(mux, "/wait/hooks", s.handleWaitHooks)
+	s.handleAPI(mux, "/wait/hooks/", s.handleWaitHooks)
+	s.handleAPI(mux, "/alerts", s.handleAlerts)
+	s.handleAPI(mux, "/compat", s.handleCompat)
+	s.handleAPI(mux, "/connections", s.handleConnections)
+	s.handleAPI(mux, "/views", s.handleViews)
+	s.handleAPI(mux, "/views/", s.handleViews)
+	s.handleAPI(mux, "/produce-status/", s.handleProduceStatus)
+	s.handleAPI(mux, "/imports", s.handleImports)
+	s.handleAPI(mux, "/imports/", s.handleImports)
+
+	// Client bootstrap metadata (no auth: helpers use it to learn auth is required)
+	s.handlePublicAPI(mux, "/bootstrap", s.handleBootstrap)
+
+	// Health checks (no auth). /health predates the split and reports readiness.
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	mux.HandleFunc("/health", s.handleReadyz)
+
+	// CA of the generated certificates (no auth: clients need it to connect)
+	mux.HandleFunc("/api/ca.pem", s.handleCA)
+
+	// Web UI, unless disabled or left out of a headless build
+	if web.Embedded && !cfg.Server.DisableUI {
+		mux.HandleFunc("/", s.handleStatic)
+	} else {
+		mux.HandleFunc("/", s.handleIndex)
+	}
 
 	s.server = &http.Server{
 		Addr:    cfg.Server.HTTPAddr,
-		Handler: mux,
+		Handler: requestIDMiddleware(s.recoverMiddleware(s.timeoutMiddleware(mountBasePath(s.basePath, mux)))),
 	}
 
-	return s
+	return s, nil
 }
 
 // ListenAndServe starts the HTTP server
 func (s *HTTPServer) ListenAndServe() error {
-	return s.server.ListenAndServe()
+	ln, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return err
+	}
+	if s.tls != nil {
+		ln = tls.NewListener(ln, s.tls)
+	}
+	return s.Serve(ln)
+}
+
+// Serve accepts HTTP connections on ln until the server is closed
+func (s *HTTPServer) Serve(ln net.Listener) error {
+	return s.server.Serve(&filteredListener{Listener: ln, filter: s.ipFilter, name: "http"})
 }
 
 // Close closes the HTTP server
@@ -78,7 +166,7 @@ func (s *HTTPServer) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 				return
 			}
 			token := strings.TrimPrefix(auth, "Bearer ")
-			if token != s.config.Security.Token {
+			if token != s.creds.Token() {
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
@@ -87,9 +175,21 @@ func (s *HTTPServer) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-func (s *HTTPServer) handleHealth(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+// adminMiddleware admits only requests bearing security.admin_token, and
+// none while it is unset
+func (s *HTTPServer) adminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		admin := s.creds.AdminToken()
+		if admin == "" {
+			http.Error(w, "admin endpoint disabled: set security.admin_token", http.StatusForbidden)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+admin {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
 }
 
 func (s *HTTPServer) handleTopics(w http.ResponseWriter, r *http.Request) {
@@ -97,6 +197,11 @@ func (s *HTTPServer) handleTopics(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
+		// Read the version first: a change racing the listing then only
+		// costs an extra fetch instead of caching stale data under a new tag
+		if checkNotModified(w, r, collectionETag("topics", s.engine.TopicsVersion())) {
+			return
+		}
 		topics := s.engine.ListTopics()
 		result := make([]map[string]interface{}, 0)
 		for _, name := range topics {
@@ -104,6 +209,7 @@ func (s *HTTPServer) handleTopics(w http.ResponseWriter, r *http.Request) {
 			latest, _ := s.engine.LatestOffset(name)
 			result = append(result, map[string]interface{}{
 				"name":          name,
+				"id":            meta.ID,
 				"latest_offset": latest,
 				"created_at":    meta.CreatedAt,
 			})
@@ -112,18 +218,27 @@ func (s *HTTPServer) handleTopics(w http.ResponseWriter, r *http.Request) {
 
 	case http.MethodPost:
 		var req struct {
-			Name string `json:"name"`
+			Name   string            `json:"name"`
+			Preset string            `json:"preset"` // shorthand for config["monolog.preset"]
+			Config map[string]string `json:"config"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		if err := s.engine.CreateTopic(req.Name); err != nil {
-			http.Error(w, err.Error(), http.StatusConflict)
+		if req.Preset != "" {
+			if req.Config == nil {
+				req.Config = make(map[string]string)
+			}
+			req.Config[engine.ConfigPreset] = req.Preset
+		}
+		if err := s.engine.CreateTopicWithConfig(r.Context(), req.Name, req.Config); err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
+		configs, _ := s.engine.TopicConfig(req.Name)
 		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(map[string]string{"name": req.Name})
+		json.NewEncoder(w).Encode(map[string]interface{}{"name": req.Name, "config": configs})
 
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -138,10 +253,58 @@ func (s *HTTPServer) handleTopic(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(path, "/")
 	topicName := parts[0]
 
+	if len(parts) == 4 && parts[1] == "messages" && parts[3] == "raw" {
+		s.handleRawBatch(w, r, topicName, parts[2])
+		return
+	}
 	if len(parts) > 1 && parts[1] == "messages" {
 		s.handleMessages(w, r, topicName)
 		return
 	}
+	if len(parts) > 1 && parts[1] == "stream" {
+		s.handleStream(w, r, topicName)
+		return
+	}
+	if len(parts) > 1 && parts[1] == "batches" {
+		s.handleBatches(w, r, topicName)
+		return
+	}
+	if len(parts) > 1 && parts[1] == "export" {
+		s.handleExport(w, r, topicName)
+		return
+	}
+	if len(parts) > 1 && parts[1] == "dictionaries" {
+		s.handleDictionaries(w, r, topicName)
+		return
+	}
+	if len(parts) > 1 && parts[1] == "generate" {
+		s.handleGenerate(w, r, topicName)
+		return
+	}
+	if len(parts) > 1 && parts[1] == "contains" {
+		s.handleContains(w, r, topicName)
+		return
+	}
+	if len(parts) > 1 && parts[1] == "count" {
+		s.handleCount(w, r, topicName)
+		return
+	}
+	if len(parts) > 1 && parts[1] == "keys" {
+		s.handleKeys(w, r, topicName)
+		return
+	}
+	if len(parts) > 1 && parts[1] == "sample" {
+		s.handleSample(w, r, topicName)
+		return
+	}
+	if len(parts) > 1 && parts[1] == "copy" {
+		s.handleCopy(w, r, topicName)
+		return
+	}
+	if len(parts) > 1 && parts[1] == "import" {
+		s.handleImportStart(w, r, topicName)
+		return
+	}
 
 	switch r.Method {
 	case http.MethodGet:
@@ -151,17 +314,19 @@ func (s *HTTPServer) handleTopic(w http.ResponseWriter, r *http.Request) {
 		}
 		meta, _ := s.engine.GetTopicMeta(topicName)
 		latest, _ := s.engine.LatestOffset(topicName)
-		earliest, _ := s.engine.EarliestOffset(topicName)
+		earliest, _ := s.engine.EarliestOffset(r.Context(), topicName)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"name":            topicName,
+			"id":              meta.ID,
 			"latest_offset":   latest,
 			"earliest_offset": earliest,
 			"created_at":      meta.CreatedAt,
+			"config":          meta.Config,
 		})
 
 	case http.MethodDelete:
-		if err := s.engine.DeleteTopic(topicName); err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+		if err := s.engine.DeleteTopic(r.Context(), topicName); err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
@@ -182,62 +347,78 @@ func (s *HTTPServer) handleMessages(w http.ResponseWriter, r *http.Request, topi
 		if v := r.URL.Query().Get("limit"); v != "" {
 			limit, _ = strconv.Atoi(v)
 		}
-
-		records, err := s.engine.Fetch(topicName, offset, limit)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+		isolation := engine.ReadUncommitted
+		switch r.URL.Query().Get("isolation") {
+		case "", "read_uncommitted":
+		case "read_committed":
+			isolation = engine.ReadCommitted
+		default:
+			http.Error(w, "isolation must be read_committed or read_uncommitted", http.StatusBadRequest)
 			return
 		}
 
-		result := make([]map[string]interface{}, 0)
-		for _, rec := range records {
-			// Check if this is a raw Kafka record batch (stored via ProduceRaw)
-			// Record batches have magic byte at position 16, should be 2
-			if len(rec.Value) >= 61 && rec.Value[16] == 2 {
-				// Parse Kafka record batch to extract actual messages
-				messages, err := parseRecordBatch(rec.Value)
-				if err == nil && len(messages) > 0 {
-					for _, msg := range messages {
-						result = append(result, map[string]interface{}{
-							"offset":    msg.Offset,
-							"timestamp": msg.Timestamp,
-							"key":       string(msg.Key),
-							"value":     string(msg.Value),
-							"codec":     rec.Codec,
-						})
-					}
-					continue
-				}
+		var records []store.Record
+		var err error
+		if isolation == engine.ReadCommitted {
+			// Dropped batches still count towards where to read next
+			var next int64
+			records, next, err = s.engine.FetchCommitted(r.Context(), topicName, offset, limit)
+			if err == nil {
+				w.Header().Set("X-Next-Offset", strconv.FormatInt(next, 10))
 			}
-
-			// Fallback: treat as simple record (produced via HTTP API)
-			result = append(result, map[string]interface{}{
-				"offset":    rec.Offset,
-				"timestamp": rec.Timestamp,
-				"key":       string(rec.Key),
-				"value":     string(rec.Value),
-				"codec":     rec.Codec,
-			})
+		} else {
+			records, err = s.engine.FetchIsolated(r.Context(), topicName, offset, limit, isolation)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
 		}
-		json.NewEncoder(w).Encode(result)
+
+		json.NewEncoder(w).Encode(expandRecords(records))
 
 	case http.MethodPost:
-		var req struct {
-			Key   string `json:"key"`
-			Value string `json:"value"`
+		// Accept a single {"key","value"} object or an array of them
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var reqs []produceRequest
+		if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+			err = json.Unmarshal(body, &reqs)
+		} else {
+			var req produceRequest
+			err = json.Unmarshal(body, &req)
+			reqs = []produceRequest{req}
+		}
+		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if len(reqs) == 0 {
+			http.Error(w, "no messages", http.StatusBadRequest)
+			return
+		}
 
-		records := []store.Record{{
-			Key:   []byte(req.Key),
-			Value: []byte(req.Value),
-		}}
-		offset, err := s.engine.Produce(topicName, records)
+		records := make([]store.Record, len(reqs))
+		for i, req := range reqs {
+			records[i] = store.Record{
+				Key:   []byte(req.Key),
+				Value: []byte(req.Value),
+			}
+		}
+		if s.config.Logging.Level == "debug" {
+			for _, rec := range records {
+				log.Printf("[http] produce: topic=%s key=%s value=%s", topicName, redact.Bytes(rec.Key), redact.Bytes(rec.Value))
+			}
+		}
+		if async, _ := strconv.ParseBool(r.URL.Query().Get("async")); async {
+			s.produceAsync(w, topicName, records)
+			return
+		}
+		offset, err := s.engine.ProduceBatched(r.Context(), topicName, records)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
 
@@ -249,11 +430,499 @@ func (s *HTTPServer) handleMessages(w http.ResponseWriter, r *http.Request, topi
 	}
 }
 
+// recordBatchContentType is the media type for raw Kafka v2 record batches
+const recordBatchContentType = "application/vnd.kafka.recordbatch"
+
+// handleBatches appends already-encoded v2 record batches byte-for-byte, so
+// captured production traffic can be replayed exactly as it was produced
+func (s *HTTPServer) handleBatches(w http.ResponseWriter, r *http.Request, topicName string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != recordBatchContentType {
+		http.Error(w, "Content-Type must be "+recordBatchContentType, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(s.config.Limits.MaxMessageSize)))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	batches, err := kafkaproto.SplitRecordBatches(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(batches) == 0 {
+		http.Error(w, "no record batches", http.StatusBadRequest)
+		return
+	}
+	for i, b := range batches {
+		if err := s.engine.CheckBatches(b.RawRecords); err != nil {
+			http.Error(w, fmt.Sprintf("batch %d: %v", i, err), errorStatus(err))
+			return
+		}
+	}
+
+	// One append, so a bad request writes nothing and the batches get
+	// contiguous offsets
+	base, _, err := s.engine.ProduceBatches(r.Context(), topicName, body, batches[0].Codec)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	total := 0
+	for _, b := range batches {
+		total += b.RecordCount()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]int64{
+		"offset":  base,
+		"batches": int64(len(batches)),
+		"records": int64(total),
+	})
+}
+
+// handleRawBatch returns the stored row holding an offset exactly as it was
+// written: for a Kafka produce, the record batch bytes the client sent,
+// before the base offset and leader epoch are rewritten for a fetch. What
+// the row holds is described in X-Monolog-* headers.
+func (s *HTTPServer) handleRawBatch(w http.ResponseWriter, r *http.Request, topicName, offsetParam string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	offset, err := strconv.ParseInt(offsetParam, 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+		return
+	}
+
+	records, err := s.engine.Fetch(r.Context(), topicName, offset, 1)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	if len(records) == 0 || records[0].Offset > offset {
+		http.Error(w, fmt.Sprintf("offset %d not found", offset), http.StatusNotFound)
+		return
+	}
+	rec := records[0]
+
+	h := w.Header()
+	h.Set("X-Monolog-Offset", strconv.FormatInt(rec.Offset, 10))
+	h.Set("X-Monolog-Last-Offset", strconv.FormatInt(rec.LastOffset, 10))
+	h.Set("X-Monolog-Timestamp", strconv.FormatInt(rec.Timestamp, 10))
+	h.Set("X-Monolog-Codec", kafkaproto.CodecName(rec.Codec))
+	if batch, err := kafkaproto.ParseRecordBatchHeader(rec.Value); err == nil && rec.Key == nil {
+		h.Set("Content-Type", recordBatchContentType)
+		h.Set("X-Monolog-Format", "record-batch")
+		h.Set("X-Monolog-Record-Count", strconv.Itoa(batch.RecordCount()))
+		h.Set("X-Monolog-Batch-Base-Offset", strconv.FormatInt(batch.BaseOffset, 10))
+		if batch.ProducerID >= 0 {
+			h.Set("X-Monolog-Producer-Id", strconv.FormatInt(batch.ProducerID, 10))
+		}
+	} else {
+		// A single record produced over HTTP; only its value is stored as bytes
+		h.Set("Content-Type", "application/octet-stream")
+		h.Set("X-Monolog-Format", "record")
+		h.Set("X-Monolog-Record-Count", "1")
+	}
+	h.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%s-%d.bin", topicName, rec.Offset)))
+	h.Set("Content-Length", strconv.Itoa(len(rec.Value)))
+	w.Write(rec.Value)
+}
+
+// errorStatus maps an engine or store error to an HTTP status
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, engine.ErrReadOnly), sqlitestore.IsStorageFull(err):
+		return http.StatusInsufficientStorage
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, store.ErrTopicNotFound), errors.Is(err, store.ErrGroupNotFound),
+		errors.Is(err, store.ErrMemberNotFound), errors.Is(err, engine.ErrUnknownView),
+		errors.Is(err, engine.ErrUnknownProduceToken), errors.Is(err, engine.ErrUnknownImport):
+		return http.StatusNotFound
+	case errors.Is(err, store.ErrTopicExists), errors.Is(err, engine.ErrTooFewSamples),
+		errors.Is(err, engine.ErrOutOfOrderSequence), errors.Is(err, engine.ErrInvalidProducerEpoch),
+		errors.Is(err, engine.ErrInvalidTxnState), errors.Is(err, engine.ErrUnknownProducerID),
+		errors.Is(err, engine.ErrNotCompacted), errors.Is(err, engine.ErrViewExists),
+		errors.Is(err, engine.ErrImportOffsetMismatch), errors.Is(err, engine.ErrImportConflict),
+		errors.Is(err, engine.ErrGroupActive), errors.Is(err, engine.ErrIllegalGeneration):
+		return http.StatusConflict
+	case errors.Is(err, engine.ErrProduceBacklog), errors.Is(err, engine.ErrShuttingDown):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, engine.ErrDictionariesUnsupported):
+		return http.StatusNotImplemented
+	case errors.Is(err, engine.ErrMessageTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, engine.ErrPolicyViolation), errors.Is(err, engine.ErrReplicaTopic):
+		return http.StatusForbidden
+	case errors.Is(err, engine.ErrInvalidConfig), errors.Is(err, engine.ErrInvalidOffset),
+		errors.Is(err, engine.ErrInvalidPartitions), errors.Is(err, engine.ErrInvalidTopic),
+		errors.Is(err, engine.ErrCorruptBatch), errors.Is(err, engine.ErrInvalidView),
+		errors.Is(err, engine.ErrInvalidViewQuery), errors.Is(err, engine.ErrInvalidCopy),
+		errors.Is(err, engine.ErrInvalidImport), errors.Is(err, engine.ErrImportChecksum):
+		return http.StatusBadRequest
+	case errors.Is(err, engine.ErrUnsupportedCompression):
+		return http.StatusUnsupportedMediaType
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// produceRequest is one message in an HTTP produce call
+type produceRequest struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// apiMessage is a single message as returned by the HTTP API
+type apiMessage struct {
+	Offset    int64  `json:"offset"`
+	Timestamp int64  `json:"timestamp"`
+	Key       string `json:"key"`
+	Value     string `json:"value"`
+	Codec     int8   `json:"codec"`
+}
+
+// expandRecords flattens stored records into messages, decoding raw
+// Kafka record batches (stored via ProduceRaw) into their individual records
+func expandRecords(records []store.Record) []apiMessage {
+	result := make([]apiMessage, 0, len(records))
+	for _, rec := range records {
+		// Check if this is a raw Kafka record batch (stored via ProduceRaw)
+		// Record batches have magic byte at position 16, should be 2
+		if len(rec.Value) >= 61 && rec.Value[16] == 2 {
+			// Parse Kafka record batch to extract actual messages
+			messages, err := parseRecordBatch(rec.Value)
+			if err == nil && len(messages) > 0 {
+				// Stored batches keep the producer's baseOffset; rebase onto ours
+				batchBase := int64(binary.BigEndian.Uint64(rec.Value[0:8]))
+				for _, msg := range messages {
+					result = append(result, apiMessage{
+						Offset:    rec.Offset + (msg.Offset - batchBase),
+						Timestamp: msg.Timestamp,
+						Key:       string(msg.Key),
+						Value:     string(msg.Value),
+						Codec:     rec.Codec,
+					})
+				}
+				continue
+			}
+		}
+
+		// Fallback: treat as simple record (produced via HTTP API)
+		result = append(result, apiMessage{
+			Offset:    rec.Offset,
+			Timestamp: rec.Timestamp,
+			Key:       string(rec.Key),
+			Value:     string(rec.Value),
+			Codec:     rec.Codec,
+		})
+	}
+	return result
+}
+
+// handleStream streams messages as Server-Sent Events, starting at ?offset=
+// and following the topic as new messages arrive
+func (s *HTTPServer) handleStream(w http.ResponseWriter, r *http.Request, topicName string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.engine.TopicExists(topicName) {
+		http.Error(w, "Topic not found", http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	offset := int64(0)
+	if v := r.URL.Query().Get("offset"); v != "" {
+		offset, _ = strconv.ParseInt(v, 10, 64)
+	}
+	// EventSource reconnects resume after the last delivered event
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		if last, err := strconv.ParseInt(v, 10, 64); err == nil {
+			offset = last + 1
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(s.config.Scheduler.TickInterval)
+	defer ticker.Stop()
+
+	for {
+		records, err := s.engine.Fetch(r.Context(), topicName, offset, 100)
+		if err != nil {
+			fmt.Fprintf(w, "event: error\ndata: %q\n\n", err.Error())
+			flusher.Flush()
+			return
+		}
+		for _, msg := range expandRecords(records) {
+			if msg.Offset < offset {
+				continue
+			}
+			data, _ := json.Marshal(msg)
+			fmt.Fprintf(w, "id: %d\nevent: message\ndata: %s\n\n", msg.Offset, data)
+			offset = msg.Offset + 1
+		}
+		if len(records) > 0 {
+			flusher.Flush()
+			continue
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// exportPageSize is how many stored records handleExport reads per query
+const exportPageSize = 1000
+
+// exportCompression is a codec handleExport can compress with. Each page is
+// compressed as its own frame, so only codecs whose frames concatenate
+// into a valid stream qualify.
+type exportCompression struct {
+	ext         string
+	contentType string
+}
+
+var exportCompressions = map[int8]exportCompression{
+	kafkaproto.CompressionGzip: {".gz", "application/gzip"},
+	kafkaproto.CompressionLz4:  {".lz4", "application/x-lz4"},
+	kafkaproto.CompressionZstd: {".zst", "application/zstd"},
+}
+
+// handleExport streams the messages appended within ?from= and ?to= as
+// newline-delimited JSON. Bounds are RFC 3339 times or unix milliseconds;
+// from defaults to the beginning of the log and to defaults to now.
+// ?cursor= resumes an interrupted export at the given offset and
+// ?compression=gzip|lz4|zstd compresses the file. ?format=records writes
+// the stored records as they are, batches and codecs included, for
+// followers to copy.
+func (s *HTTPServer) handleExport(w http.ResponseWriter, r *http.Request, topicName string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	codec := kafkaproto.CompressionNone
+	compression := exportCompression{contentType: "application/x-ndjson"}
+	if v := q.Get("compression"); v != "" && v != "none" {
+		id, err := kafkaproto.ParseCodec(v)
+		c, ok := exportCompressions[id]
+		if err != nil || !ok {
+			http.Error(w, "compression must be gzip, lz4 or zstd", http.StatusBadRequest)
+			return
+		}
+		if !kafkaproto.CodecAvailable(id) {
+			http.Error(w, kafkaproto.ErrCodecUnavailable.Error()+": "+v, http.StatusBadRequest)
+			return
+		}
+		codec, compression = id, c
+	}
+	from, err := parseTimeParam(q.Get("from"), time.UnixMilli(0))
+	if err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseTimeParam(q.Get("to"), time.Now())
+	if err != nil {
+		http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !to.After(from) {
+		http.Error(w, "to must be after from", http.StatusBadRequest)
+		return
+	}
+	cursor := int64(0)
+	if v := q.Get("cursor"); v != "" {
+		if cursor, err = strconv.ParseInt(v, 10, 64); err != nil || cursor < 0 {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+	}
+	var asRecords bool
+	switch q.Get("format") {
+	case "", "messages":
+	case "records":
+		asRecords = true
+	default:
+		http.Error(w, "format must be messages or records", http.StatusBadRequest)
+		return
+	}
+
+	// Read the first page before committing to a 200 so a missing topic
+	// still gets a proper status code
+	records, next, err := s.engine.ReadRange(r.Context(), topicName, from, to, cursor, exportPageSize)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", compression.contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", topicName+".ndjson"+compression.ext))
+	w.WriteHeader(http.StatusOK)
+
+	var page bytes.Buffer
+	enc := json.NewEncoder(&page)
+	for {
+		page.Reset()
+		if asRecords {
+			for _, rec := range records {
+				enc.Encode(rec)
+			}
+		} else {
+			for _, msg := range expandRecords(records) {
+				if msg.Offset < cursor {
+					continue
+				}
+				enc.Encode(msg)
+			}
+		}
+		if page.Len() > 0 {
+			data, err := kafkaproto.Compress(page.Bytes(), codec)
+			if err != nil {
+				log.Printf("[http] export of %s aborted: %v", topicName, err)
+				return
+			}
+			if _, err := w.Write(data); err != nil {
+				return
+			}
+		}
+		if next < 0 || r.Context().Err() != nil {
+			return
+		}
+		records, next, err = s.engine.ReadRange(r.Context(), topicName, from, to, next, exportPageSize)
+		if err != nil {
+			log.Printf("[http] export of %s aborted: %v", topicName, err)
+			return
+		}
+	}
+}
+
+// handleSample returns ?n= (default 100) of the topic's messages, the most
+// recent or with ?mode=random ones spread across the log, and the schema
+// inferred from their JSON values
+func (s *HTTPServer) handleSample(w http.ResponseWriter, r *http.Request, topicName string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	n := 100
+	if v := q.Get("n"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n <= 0 || n > engine.MaxSampleSize {
+			http.Error(w, fmt.Sprintf("n must be 1-%d", engine.MaxSampleSize), http.StatusBadRequest)
+			return
+		}
+	}
+	mode := q.Get("mode")
+	switch mode {
+	case "":
+		mode = engine.SampleRecent
+	case engine.SampleRecent, engine.SampleRandom:
+	default:
+		http.Error(w, "mode must be recent or random", http.StatusBadRequest)
+		return
+	}
+
+	msgs, err := s.engine.SampleMessages(r.Context(), topicName, n, mode)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	sample := make([]apiMessage, len(msgs))
+	for i, m := range msgs {
+		sample[i] = apiMessage{Offset: m.Offset, Timestamp: m.Timestamp.UnixMilli(), Key: string(m.Key), Value: string(m.Value)}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"topic":    topicName,
+		"mode":     mode,
+		"messages": sample,
+		"schema":   engine.InferSchema(msgs),
+	})
+}
+
+// handleDictionaries lists a topic's trained compression dictionaries, or
+// trains a new one from its recent messages on POST
+func (s *HTTPServer) handleDictionaries(w http.ResponseWriter, r *http.Request, topicName string) {
+	switch r.Method {
+	case http.MethodGet:
+		dicts, err := s.engine.TopicDictionaries(r.Context(), topicName)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"current":      s.engine.CurrentDictionary(topicName),
+			"dictionaries": dicts,
+		})
+
+	case http.MethodPost:
+		dict, err := s.engine.TrainDictionary(r.Context(), topicName)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		json.NewEncoder(w).Encode(dict)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// parseTimeParam parses an RFC 3339 time or unix milliseconds, returning def
+// when v is empty
+func parseTimeParam(v string, def time.Time) (time.Time, error) {
+	if v == "" {
+		return def, nil
+	}
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
 func (s *HTTPServer) handleGroups(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	switch r.Method {
 	case http.MethodGet:
+		// The group store's version covers the list, membership, state and
+		// generation. Commit stats change without the store noticing: a
+		// commit bumps the tracker's version, and groups become stalled as
+		// time passes, so which ones are goes in too.
+		version := combinedVersion(s.engine.GroupsVersion(), s.engine.CommitsVersion(),
+			namesVersion(s.engine.StalledCommitters()))
+		if checkNotModified(w, r, collectionETag("groups", version)) {
+			return
+		}
 		groups := s.engine.ListGroups()
 		result := make([]map[string]interface{}, 0)
 		for _, id := range groups {
@@ -263,6 +932,7 @@ func (s *HTTPServer) handleGroups(w http.ResponseWriter, r *http.Request) {
 				"state":      group.State,
 				"generation": group.Generation,
 				"members":    len(group.Members),
+				"commits":    s.engine.CommitStats(id),
 			})
 		}
 		json.NewEncoder(w).Encode(result)
@@ -275,15 +945,25 @@ func (s *HTTPServer) handleGroups(w http.ResponseWriter, r *http.Request) {
 func (s *HTTPServer) handleGroup(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Parse path: /api/groups/{id} or /api/groups/{id}/offsets/{topic}
+	// Parse path: /api/groups/{id}, /api/groups/{id}/offsets,
+	// /api/groups/{id}/offsets/{topic} or /api/groups/{id}/reset-offsets
 	path := strings.TrimPrefix(r.URL.Path, "/api/groups/")
 	parts := strings.Split(path, "/")
 	groupID := parts[0]
 
+	if len(parts) == 2 && parts[1] == "reset-offsets" {
+		s.handleResetOffsets(w, r, groupID)
+		return
+	}
+
 	if len(parts) > 2 && parts[1] == "offsets" {
 		s.handleGroupOffset(w, r, groupID, parts[2])
 		return
 	}
+	if len(parts) == 2 && parts[1] == "offsets" {
+		s.handleGroupOffsets(w, r, groupID)
+		return
+	}
 
 	switch r.Method {
 	case http.MethodGet:
@@ -295,8 +975,8 @@ func (s *HTTPServer) handleGroup(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(group)
 
 	case http.MethodDelete:
-		if err := s.engine.DeleteGroup(groupID); err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+		if err := s.engine.DeleteGroup(r.Context(), groupID); err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
@@ -306,12 +986,69 @@ func (s *HTTPServer) handleGroup(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleGroupOffsets exports a group's committed offsets as a checkpoint
+// (GET) or imports one (PUT). An import fails if a topic does not exist
+// here unless skip_missing=true is set.
+func (s *HTTPServer) handleGroupOffsets(w http.ResponseWriter, r *http.Request, groupID string) {
+	switch r.Method {
+	case http.MethodGet:
+		checkpoint, err := s.engine.ExportOffsets(groupID)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", groupID+"-offsets.json"))
+		json.NewEncoder(w).Encode(checkpoint)
+
+	case http.MethodPut:
+		var checkpoint engine.OffsetCheckpoint
+		if err := json.NewDecoder(r.Body).Decode(&checkpoint); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		skipMissing := r.URL.Query().Get("skip_missing") == "true"
+		result, err := s.engine.ImportOffsets(r.Context(), groupID, checkpoint.Offsets, skipMissing)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		json.NewEncoder(w).Encode(result)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleResetOffsets sets many of a group's committed offsets at once,
+// each to an offset or the first message at or after a timestamp. The
+// group must be inactive and, if the body names one, at that generation.
+func (s *HTTPServer) handleResetOffsets(w http.ResponseWriter, r *http.Request, groupID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	reset := engine.OffsetReset{Generation: -1}
+	if err := json.NewDecoder(r.Body).Decode(&reset); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("dry_run") == "true" {
+		reset.DryRun = true
+	}
+	result, err := s.engine.ResetOffsets(r.Context(), groupID, reset)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
 func (s *HTTPServer) handleGroupOffset(w http.ResponseWriter, r *http.Request, groupID, topic string) {
 	switch r.Method {
 	case http.MethodGet:
-		offset, err := s.engine.FetchOffset(groupID, topic)
+		offset, err := s.engine.FetchOffset(groupID, topic, 0)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
 		json.NewEncoder(w).Encode(map[string]int64{"offset": offset})
@@ -324,8 +1061,13 @@ func (s *HTTPServer) handleGroupOffset(w http.ResponseWriter, r *http.Request, g
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		if err := s.engine.CommitOffset(groupID, topic, req.Offset); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		// Committing through HTTP implicitly creates the group, as OffsetCommit does
+		if _, err := s.engine.GetOrCreateGroup(r.Context(), groupID); err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		if err := s.engine.CommitOffset(r.Context(), groupID, topic, 0, req.Offset); err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -336,67 +1078,277 @@ func (s *HTTPServer) handleGroupOffset(w http.ResponseWriter, r *http.Request, g
 	}
 }
 
+// handlePending lists the parked fetches, or with ?topic= those reading
+// one topic
 func (s *HTTPServer) handlePending(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	pending := s.engine.GetPendingQueue().GetAll()
+	var pending []*engine.PendingFetch
+	if topic := r.URL.Query().Get("topic"); topic != "" {
+		pending = s.engine.GetPendingQueue().ForTopic(topic)
+	} else {
+		pending = s.engine.GetPendingQueue().GetAll()
+	}
 	result := make([]map[string]interface{}, 0)
 	for _, p := range pending {
 		result = append(result, map[string]interface{}{
-			"topic":          p.Topic,
-			"partition":      p.Partition,
-			"offset":         p.Offset,
+			"partitions":     p.Partitions,
+			"min_bytes":      p.MinBytes,
+			"parked":         p.Parked,
 			"deadline":       p.Deadline,
 			"correlation_id": p.CorrelationID,
+			"conn_id":        p.ConnID,
 		})
 	}
 	json.NewEncoder(w).Encode(result)
 }
 
+// handlePendingTopics summarizes the parked fetches by topic, with how
+// long answered ones waited and how long waiting ones have left
+func (s *HTTPServer) handlePendingTopics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.engine.GetPendingQueue().TopicStats())
+}
+
+// handleMemory reports the memory held by the process and each subsystem
+func (s *HTTPServer) handleMemory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.engine.MemoryReport(r.Context()))
+}
+
+// handleFollower shows how far follower mode has copied each topic from
+// the primary
+func (s *HTTPServer) handleFollower(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	status := s.engine.Follower().Status()
+	if status == nil {
+		http.Error(w, "follower mode is off", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// handleTransactions lists the transaction coordinator's transactional IDs
+// and their open transactions
+func (s *HTTPServer) handleTransactions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.engine.Transactions())
+}
+
 func (s *HTTPServer) handleStats(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	topics := s.engine.ListTopics()
 	groups := s.engine.ListGroups()
-	pending := s.engine.GetPendingQueue().Len()
+	pending := s.engine.GetPendingQueue().Stats()
+
+	connections := 0
+	var bytesIn, bytesOut, requests int64
+	recentErrors := []RecentError{}
+	if s.kafka != nil {
+		connections = s.kafka.Connections()
+		bytesIn, bytesOut, requests = s.kafka.TrafficTotals()
+		recentErrors = s.kafka.RecentErrors()
+	}
 
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"topics":   len(topics),
-		"groups":   len(groups),
-		"pending":  pending,
+		"topics":          len(topics),
+		"groups":          len(groups),
+		"pending":         pending.Waiting,
+		"pending_queue":   pending,
+		"connections":     connections,
+		"bytes_in":        bytesIn,
+		"bytes_out":       bytesOut,
+		"requests":        requests,
+		"recent_errors":   recentErrors,
+		"panics":          s.engine.PanicCount(),
+		"stalled_groups":  s.engine.StalledCommitters(),
+		"corrupt_records": len(s.engine.Scrubber().Report().Corrupt),
 	})
 }
 
-// decompress decompresses data based on Kafka codec
-// 0=none, 1=gzip, 2=snappy, 3=lz4, 4=zstd
-func decompress(data []byte, codec int8) ([]byte, error) {
-	switch codec {
-	case 0: // none
-		return data, nil
-	case 1: // gzip
-		r, err := gzip.NewReader(bytes.NewReader(data))
-		if err != nil {
-			return nil, err
-		}
-		defer r.Close()
-		return io.ReadAll(r)
-	case 2: // snappy
-		return snappy.Decode(nil, data)
-	case 3: // lz4
-		r := lz4.NewReader(bytes.NewReader(data))
-		return io.ReadAll(r)
-	case 4: // zstd
-		decoder, err := zstd.NewReader(nil)
+func (s *HTTPServer) handleIPRules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(s.currentIPRules())
+
+	case http.MethodPut:
+		var req config.ListenerIPRules
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Validate both before applying either so a bad rule never half-applies
+		if _, err := NewIPFilter(req.Kafka); err != nil {
+			http.Error(w, "kafka: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := NewIPFilter(req.HTTP); err != nil {
+			http.Error(w, "http: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if s.kafka != nil {
+			s.kafka.IPFilter().Update(req.Kafka)
+		}
+		s.ipFilter.Update(req.HTTP)
+		s.engine.NotifyConfigChanged("ip_rules")
+		json.NewEncoder(w).Encode(s.currentIPRules())
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleIntegrity reports offset integrity (GET) or repairs a topic's
+// latest offset (POST ?topic=)
+func (s *HTTPServer) handleIntegrity(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		if _, err := s.engine.IntegrityStats(); err != nil {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		topics := s.engine.ListTopics()
+		if t := r.URL.Query().Get("topic"); t != "" {
+			topics = []string{t}
+		}
+		reports := make([]*store.IntegrityReport, 0, len(topics))
+		for _, t := range topics {
+			report, err := s.engine.CheckIntegrity(r.Context(), t)
+			if err != nil {
+				http.Error(w, err.Error(), errorStatus(err))
+				return
+			}
+			reports = append(reports, report)
+		}
+		// Counters include gaps and overlaps found by this check
+		stats, _ := s.engine.IntegrityStats()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"stats":  stats,
+			"topics": reports,
+		})
+
+	case http.MethodPost:
+		topic := r.URL.Query().Get("topic")
+		if topic == "" {
+			http.Error(w, "topic required", http.StatusBadRequest)
+			return
+		}
+		report, err := s.engine.RepairTopic(r.Context(), topic)
 		if err != nil {
-			return nil, err
+			http.Error(w, err.Error(), errorStatus(err))
+			return
 		}
-		defer decoder.Close()
-		return decoder.DecodeAll(data, nil)
+		json.NewEncoder(w).Encode(report)
+
 	default:
-		return data, nil
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
+// handleVerify reports what the checksum scrubber has found (GET) or
+// starts a scrub of every topic now (POST)
+func (s *HTTPServer) handleVerify(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	scrubber := s.engine.Scrubber()
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(scrubber.Report())
+
+	case http.MethodPost:
+		if err := scrubber.Trigger(); err != nil {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(scrubber.Report())
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleRefresh reloads the store caches from storage, for use after the
+// database was changed by another process
+func (s *HTTPServer) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	result, err := s.engine.Refresh(r.Context(), true)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// alertRuleView is an alert rule as returned by /api/alerts
+type alertRuleView struct {
+	Name      string  `json:"name"`
+	Metric    string  `json:"metric"`
+	Op        string  `json:"op"`
+	Threshold float64 `json:"threshold"`
+	For       string  `json:"for"`
+}
+
+// handleAlerts returns the configured alert rules and the alerts that are
+// currently pending or firing
+func (s *HTTPServer) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	alerts := s.engine.Alerts()
+	rules := make([]alertRuleView, 0)
+	for _, rule := range alerts.Rules() {
+		rules = append(rules, alertRuleView{
+			Name:      rule.Name,
+			Metric:    rule.Metric,
+			Op:        rule.Op,
+			Threshold: rule.Threshold,
+			For:       rule.For.String(),
+		})
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rules":  rules,
+		"active": alerts.Active(),
+	})
+}
+
+func (s *HTTPServer) currentIPRules() config.ListenerIPRules {
+	rules := config.ListenerIPRules{HTTP: s.ipFilter.Rules()}
+	if s.kafka != nil {
+		rules.Kafka = s.kafka.IPFilter().Rules()
+	}
+	return rules
+}
+
 // ParsedMessage represents a message extracted from a Kafka record batch
 type ParsedMessage struct {
 	Offset    int64
@@ -432,6 +1384,7 @@ func parseRecordBatch(data []byte) ([]ParsedMessage, error) {
 	// magic := data[16]
 	attributes := int16(binary.BigEndian.Uint16(data[21:23]))
 	firstTimestamp := int64(binary.BigEndian.Uint64(data[27:35]))
+	maxTimestamp := int64(binary.BigEndian.Uint64(data[35:43]))
 	recordCount := int32(binary.BigEndian.Uint32(data[57:61]))
 
 	// Get codec from attributes (bits 0-2)
@@ -442,7 +1395,7 @@ func parseRecordBatch(data []byte) ([]ParsedMessage, error) {
 
 	// Decompress if needed
 	if codec != 0 {
-		decompressed, err := decompress(recordsData, codec)
+		decompressed, err := kafkaproto.Decompress(recordsData, codec)
 		if err != nil {
 			return nil, fmt.Errorf("decompress failed: %w", err)
 		}
@@ -473,6 +1426,10 @@ func parseRecordBatch(data []byte) ([]ParsedMessage, error) {
 		if err != nil {
 			continue
 		}
+		// LogAppendTime batches carry the broker's timestamp for every record
+		if attributes&kafkaproto.BatchAttrLogAppendTime != 0 {
+			msg.Timestamp = maxTimestamp
+		}
 		messages = append(messages, msg)
 	}
 
@@ -569,6 +1526,28 @@ func readVarint(data []byte) (int64, int) {
 	return 0, 0
 }
 
+// handleIndex replaces the web UI when it is disabled with a page that
+// points at the API
+func (s *HTTPServer) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	base := html.EscapeString(s.basePath)
+	fmt.Fprintf(w, `<!doctype html>
+<html><head><meta charset="utf-8"><title>Monolog</title></head>
+<body>
+<h1>Monolog %s</h1>
+<p>The web UI is not available in this build or has been disabled. The HTTP API is served under <a href="%s/api/v2/topics">%s/api/v2</a>.</p>
+<ul>
+<li><a href="%s/api/v2/bootstrap">%s/api/v2/bootstrap</a> &mdash; client connection details</li>
+<li><a href="%s/readyz">%s/readyz</a> &mdash; readiness</li>
+</ul>
+</body></html>
+`, html.EscapeString(Version), base, base, base, base, base, base)
+}
+
 func (s *HTTPServer) handleStatic(w http.ResponseWriter, r *http.Request) {
 	// Serve embedded static files
 	distFS, err := fs.Sub(web.DistFS, "dist")
@@ -587,7 +1566,18 @@ func (s *HTTPServer) handleStatic(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		w.Header().Set("Content-Type", "text/html")
-		w.Write(content)
+		w.Write(s.rewriteAsset("index.html", content))
+		return
+	}
+
+	// Scripts and stylesheets reference assets by absolute path
+	if ext := filepath.Ext(path); s.basePath != "" && (ext == ".js" || ext == ".css") {
+		content, err := fs.ReadFile(distFS, strings.TrimPrefix(path, "/"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, path, time.Time{}, bytes.NewReader(s.rewriteAsset(path, content)))
 		return
 	}
 
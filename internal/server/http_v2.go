@@ -0,0 +1,253 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"runtime/debug"
+	"strings"
+
+	"github.com/rizkyandriawan/monolog/internal/ids"
+)
+
+// ============================================================================
+// API versioning
+//
+// Every API route is served twice: under /api (v1, deprecated) and /api/v2.
+// Both versions share handlers; v2 rewrites plain-text errors into a
+// structured envelope so typed clients get a consistent error model.
+// ============================================================================
+
+type requestIDKey struct{}
+
+// APIError is the v2 error model
+type APIError struct {
+	Code      string                 `json:"code"`
+	Message   string                 `json:"message"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	RequestID string                 `json:"request_id"`
+}
+
+// apiErrorEnvelope wraps APIError in the v2 response body
+type apiErrorEnvelope struct {
+	Error APIError `json:"error"`
+}
+
+// handleAPI registers an authenticated handler for both /api{path} and /api/v2{path}
+func (s *HTTPServer) handleAPI(mux *http.ServeMux, path string, h http.HandlerFunc) {
+	s.handlePublicAPI(mux, path, s.authMiddleware(h))
+}
+
+// handleAdminAPI registers a handler that takes the admin token instead of
+// the API token
+func (s *HTTPServer) handleAdminAPI(mux *http.ServeMux, path string, h http.HandlerFunc) {
+	s.handlePublicAPI(mux, path, s.adminMiddleware(h))
+}
+
+// handlePublicAPI registers a handler that skips authentication
+func (s *HTTPServer) handlePublicAPI(mux *http.ServeMux, path string, h http.HandlerFunc) {
+	mux.HandleFunc("/api"+path, s.deprecatedV1(h))
+	mux.HandleFunc("/api/v2"+path, v2Handler(h))
+}
+
+// requestIDMiddleware assigns every request an ID, honoring X-Request-ID
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" || len(id) > 128 {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// recoverMiddleware answers 500 instead of dropping the connection when a
+// handler panics, reporting the panic with the request's context
+func (s *HTTPServer) recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v) // deliberate abort; let net/http handle it quietly
+			}
+			s.engine.ReportPanic("http", v, debug.Stack(),
+				"request_id", RequestID(r), "method", r.Method, "path", r.URL.Path, "remote", r.RemoteAddr)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// longRunning lists the final path segments of endpoints that stream or
+// ingest for as long as the client keeps going
+var longRunning = map[string]bool{
+	"stream":  true,
+	"export":  true,
+	"watch":   true,
+	"wait":    true,
+	"capture": true,
+	"replay":  true,
+}
+
+// timeoutMiddleware bounds a request's context by limits.request_timeout,
+// so storage work for a slow query is abandoned along with the client.
+// Streaming endpoints are left to run until the client disconnects.
+func (s *HTTPServer) timeoutMiddleware(next http.Handler) http.Handler {
+	timeout := s.config.Limits.RequestTimeout
+	if timeout <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if longRunning[path.Base(r.URL.Path)] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestID returns the request ID assigned by the middleware
+func RequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	return ids.Hex(8)
+}
+
+// deprecatedV1 marks v1 responses as deprecated and points at the v2 successor
+func (s *HTTPServer) deprecatedV1(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		successor := s.externalURL(r, "/api/v2"+strings.TrimPrefix(r.URL.Path, "/api"))
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+">; rel=\"successor-version\"")
+		next(w, r)
+	}
+}
+
+// v2Handler strips the version prefix and converts error responses to the envelope
+func v2Handler(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = "/api" + strings.TrimPrefix(r.URL.Path, "/api/v2")
+
+		ew := &errorEnvelopeWriter{ResponseWriter: w, requestID: RequestID(r), method: r.Method, path: r.URL.Path}
+		next(ew, r2)
+		ew.finish()
+	}
+}
+
+// errorCodeForStatus maps HTTP status codes to v2 error codes
+func errorCodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusMethodNotAllowed:
+		return "method_not_allowed"
+	case http.StatusRequestTimeout:
+		return "timeout"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusRequestEntityTooLarge:
+		return "payload_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limited"
+	case http.StatusServiceUnavailable:
+		return "unavailable"
+	case http.StatusInsufficientStorage:
+		return "insufficient_storage"
+	default:
+		if status >= 500 {
+			return "internal"
+		}
+		return "error"
+	}
+}
+
+// errorEnvelopeWriter buffers error bodies written by v1 handlers and re-emits
+// them as a v2 error envelope. A JSON error body (a wait's status on 408, say)
+// is carried in details. Successful responses pass through.
+type errorEnvelopeWriter struct {
+	http.ResponseWriter
+	requestID string
+	method    string
+	path      string
+	status    int
+	body      bytes.Buffer
+	wrapped   bool
+	bodyType  string // Content-Type the handler set for the error body
+}
+
+func (w *errorEnvelopeWriter) WriteHeader(status int) {
+	if status >= 400 {
+		w.status = status
+		w.bodyType = w.Header().Get("Content-Type")
+		w.wrapped = true
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *errorEnvelopeWriter) Write(p []byte) (int, error) {
+	if w.wrapped {
+		return w.body.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush lets streaming handlers work through the wrapper
+func (w *errorEnvelopeWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && !w.wrapped {
+		f.Flush()
+	}
+}
+
+func (w *errorEnvelopeWriter) finish() {
+	if !w.wrapped {
+		return
+	}
+	h := w.ResponseWriter.Header()
+	h.Set("Content-Type", "application/json")
+	h.Del("X-Content-Type-Options")
+	w.ResponseWriter.WriteHeader(w.status)
+
+	message := strings.TrimSpace(w.body.String())
+	var details map[string]interface{}
+	if isJSON(w.bodyType) {
+		message = http.StatusText(w.status)
+		if json.Unmarshal(w.body.Bytes(), &details) != nil {
+			details = nil
+		}
+	}
+	if w.status == http.StatusNotFound || w.status == http.StatusMethodNotAllowed {
+		if details == nil {
+			details = map[string]interface{}{}
+		}
+		details["method"], details["path"] = w.method, w.path
+	}
+	json.NewEncoder(w.ResponseWriter).Encode(apiErrorEnvelope{Error: APIError{
+		Code:      errorCodeForStatus(w.status),
+		Message:   message,
+		Details:   details,
+		RequestID: w.requestID,
+	}})
+}
+
+func isJSON(contentType string) bool {
+	return strings.HasPrefix(contentType, "application/json")
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// handleImportStart opens a resumable import into a topic:
+// POST /api/topics/{name}/import answers 201 with the session, whose id
+// names it under /api/imports
+func (s *HTTPServer) handleImportStart(w http.ResponseWriter, r *http.Request, topicName string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	st, err := s.engine.Imports().Start(r.Context(), topicName)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	w.Header().Set("Location", s.basePath+"/api/imports/"+st.ID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(st)
+}
+
+// handleImports serves import sessions:
+// GET /api/imports lists them, GET /api/imports/{id} shows one,
+// PUT /api/imports/{id}?offset=&sha256= uploads the chunk starting at byte
+// offset, POST /api/imports/{id}/complete[?sha256=] appends an
+// unterminated last line and closes the session, and DELETE aborts it.
+// sha256 is the hex digest of the chunk, or of the whole upload on
+// completion.
+func (s *HTTPServer) handleImports(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	imports := s.engine.Imports()
+	id, sub, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/imports"), "/"), "/")
+
+	switch {
+	case sub == "complete" && r.Method == http.MethodPost:
+		st, err := imports.Complete(r.Context(), id, r.URL.Query().Get("sha256"))
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		json.NewEncoder(w).Encode(st)
+
+	case sub != "":
+		http.Error(w, "Not found", http.StatusNotFound)
+
+	case r.Method == http.MethodGet && id == "":
+		json.NewEncoder(w).Encode(imports.List())
+
+	case r.Method == http.MethodGet:
+		st, err := imports.Get(id)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		json.NewEncoder(w).Encode(st)
+
+	case r.Method == http.MethodPut && id != "":
+		s.handleImportChunk(w, r, id)
+
+	case r.Method == http.MethodDelete && id != "":
+		if err := imports.Abort(id); err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *HTTPServer) handleImportChunk(w http.ResponseWriter, r *http.Request, id string) {
+	q := r.URL.Query()
+	offset, err := strconv.ParseInt(q.Get("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "offset must be the byte of the upload the chunk starts at", http.StatusBadRequest)
+		return
+	}
+	chunk, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(s.config.Produce.ImportChunkSize)))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "chunk larger than produce.import_chunk_size ("+strconv.Itoa(s.config.Produce.ImportChunkSize)+" bytes)", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	st, err := s.engine.Imports().Append(r.Context(), id, offset, chunk, q.Get("sha256"))
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	json.NewEncoder(w).Encode(st)
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+)
+
+// IPFilter enforces CIDR allow/deny rules for a listener.
+// Deny rules win over allow rules; an empty allow list admits everyone
+// not explicitly denied.
+type IPFilter struct {
+	mu    sync.RWMutex
+	rules config.IPRules
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// NewIPFilter creates an IPFilter from config rules
+func NewIPFilter(rules config.IPRules) (*IPFilter, error) {
+	f := &IPFilter{}
+	if err := f.Update(rules); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Update atomically replaces the filter rules
+func (f *IPFilter) Update(rules config.IPRules) error {
+	allow, err := parseCIDRs(rules.Allow)
+	if err != nil {
+		return fmt.Errorf("allow: %w", err)
+	}
+	deny, err := parseCIDRs(rules.Deny)
+	if err != nil {
+		return fmt.Errorf("deny: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = config.IPRules{
+		Allow: append([]string{}, rules.Allow...),
+		Deny:  append([]string{}, rules.Deny...),
+	}
+	f.allow = allow
+	f.deny = deny
+	return nil
+}
+
+// Rules returns a copy of the current rules
+func (f *IPFilter) Rules() config.IPRules {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return config.IPRules{
+		Allow: append([]string{}, f.rules.Allow...),
+		Deny:  append([]string{}, f.rules.Deny...),
+	}
+}
+
+// Allowed reports whether a remote address passes the rules
+func (f *IPFilter) Allowed(addr net.Addr) bool {
+	ip := addrIP(addr)
+	if ip == nil {
+		// Non-IP transports (e.g. unix sockets) are not filtered
+		return true
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for _, n := range f.deny {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	for _, n := range f.allow {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCIDRs parses CIDR strings; bare IPs are treated as single-host ranges
+func parseCIDRs(entries []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP: %s", entry)
+			}
+			if ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR: %s", entry)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+// filteredListener drops connections rejected by an IPFilter at accept time
+type filteredListener struct {
+	net.Listener
+	filter *IPFilter
+	name   string
+}
+
+func (l *filteredListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.filter.Allowed(conn.RemoteAddr()) {
+			return conn, nil
+		}
+		log.Printf("[%s] rejected connection from %s (ip rules)", l.name, conn.RemoteAddr())
+		conn.Close()
+	}
+}
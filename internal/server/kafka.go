@@ -2,48 +2,144 @@ package server
 
 import (
 	"bytes"
+	"cmp"
+	"context"
+	"crypto/tls"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"maps"
 	"net"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/rizkyandriawan/monolog/internal/config"
 	"github.com/rizkyandriawan/monolog/internal/engine"
-	"github.com/rizkyandriawan/monolog/internal/protocol"
+	"github.com/rizkyandriawan/monolog/internal/redact"
+	sqlitestore "github.com/rizkyandriawan/monolog/internal/store"
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+	"github.com/rizkyandriawan/monolog/pkg/store"
 )
 
 // KafkaServer handles Kafka protocol connections
 type KafkaServer struct {
-	config      *config.Config
-	engine      *engine.Engine
-	listener    net.Listener
-	connections sync.Map
-	connCount   int32
-	stopChan    chan struct{}
-	wg          sync.WaitGroup
+	config        *config.Config
+	engine        *engine.Engine
+	listener      net.Listener
+	mu            sync.Mutex
+	nodeListeners []net.Listener // virtual brokers 1..N-1
+	ipFilter      *IPFilter
+	credentials   *Credentials
+	connections   sync.Map // net.Conn -> *connStats
+	connCount     int32
+	nextConnID    atomic.Uint64 // IDs connections are known by in the pending queue
+	bytesIn       atomic.Int64
+	bytesOut      atomic.Int64
+	requests      atomic.Int64
+	errors        errorLog
+	compat        *compatTracker
+	quirks        map[string]*clientQuirks // by lowercased client software name or client ID
+	coldStart     *coldStart // nil unless topics.cold_start.leader_not_available
+	listenState   listenerState
+	tlsConfig     *tls.Config // nil unless security.tls is enabled
+	stopChan      chan struct{}
+	wg            sync.WaitGroup
 }
 
 // NewKafkaServer creates a new KafkaServer
-func NewKafkaServer(cfg *config.Config, eng *engine.Engine) *KafkaServer {
-	return &KafkaServer{
-		config:   cfg,
-		engine:   eng,
-		stopChan: make(chan struct{}),
+func NewKafkaServer(cfg *config.Config, eng *engine.Engine) (*KafkaServer, error) {
+	ipFilter, err := NewIPFilter(cfg.Security.IPRules.Kafka)
+	if err != nil {
+		return nil, fmt.Errorf("kafka ip rules: %w", err)
+	}
+	quirks, err := newClientQuirks(cfg.Compat)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPrincipalBinding(cfg.Security); err != nil {
+		return nil, err
+	}
+	tlsConfig, err := LoadTLS(cfg.Security.TLS)
+	if err != nil {
+		return nil, err
+	}
+	s := &KafkaServer{
+		config:      cfg,
+		engine:      eng,
+		ipFilter:    ipFilter,
+		credentials: NewCredentials(cfg.Security),
+		compat:      newCompatTracker(),
+		quirks:      quirks,
+		tlsConfig:   tlsConfig,
+		stopChan:    make(chan struct{}),
+	}
+	if cfg.Topics.ColdStart.LeaderNotAvailable {
+		s.coldStart = newColdStart(cfg.Topics.ColdStart.Window)
 	}
+	return s, nil
+}
+
+// Connections returns the number of open client connections
+func (s *KafkaServer) Connections() int {
+	return int(atomic.LoadInt32(&s.connCount))
+}
+
+// RecentErrors returns the latest request errors, newest first
+func (s *KafkaServer) RecentErrors() []RecentError {
+	return s.errors.Recent()
+}
+
+// Credentials returns the tokens clients authenticate with
+func (s *KafkaServer) Credentials() *Credentials {
+	return s.credentials
+}
+
+// IPFilter returns the listener's IP filter
+func (s *KafkaServer) IPFilter() *IPFilter {
+	return s.ipFilter
 }
 
 // ListenAndServe starts the server
 func (s *KafkaServer) ListenAndServe() error {
 	ln, err := net.Listen("tcp", s.config.Server.KafkaAddr)
 	if err != nil {
+		s.listenState.set(false, err)
 		return err
 	}
+	if s.tlsConfig != nil {
+		ln = tls.NewListener(ln, s.tlsConfig)
+	}
+	return s.Serve(ln)
+}
+
+// Serve accepts Kafka connections on ln until the server is closed. With
+// virtual brokers configured, ln serves node 0 and the other nodes get
+// listeners of their own.
+func (s *KafkaServer) Serve(ln net.Listener) error {
 	s.listener = ln
+	if err := s.listenVirtualBrokers(); err != nil {
+		s.listenState.set(false, err)
+		ln.Close()
+		return err
+	}
+	s.listenState.set(true, nil)
+
+	err := s.serveNode(ln, 0)
+	if errors.Is(err, net.ErrClosed) {
+		s.listenState.set(false, err)
+	}
+	return err
+}
 
+// serveNode accepts connections for one advertised node
+func (s *KafkaServer) serveNode(ln net.Listener, nodeID int32) error {
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
@@ -51,9 +147,19 @@ func (s *KafkaServer) ListenAndServe() error {
 			case <-s.stopChan:
 				return nil
 			default:
-				log.Printf("[kafka] accept error: %v", err)
-				continue
 			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			log.Printf("[kafka] accept error: %v", err)
+			continue
+		}
+
+		// Check IP rules
+		if !s.ipFilter.Allowed(conn.RemoteAddr()) {
+			log.Printf("[kafka] rejected connection from %s (ip rules)", conn.RemoteAddr())
+			conn.Close()
+			continue
 		}
 
 		// Check connection limit
@@ -64,19 +170,25 @@ func (s *KafkaServer) ListenAndServe() error {
 		}
 
 		atomic.AddInt32(&s.connCount, 1)
-		s.connections.Store(conn, true)
+		s.connections.Store(conn, newConnStats(conn.RemoteAddr().String(), nodeID))
 
 		s.wg.Add(1)
-		go s.handleConnection(conn)
+		go s.handleConnection(conn, nodeID)
 	}
 }
 
 // Close closes the server
 func (s *KafkaServer) Close() error {
 	close(s.stopChan)
+	s.listenState.set(false, nil)
 	if s.listener != nil {
 		s.listener.Close()
 	}
+	s.mu.Lock()
+	for _, ln := range s.nodeListeners {
+		ln.Close()
+	}
+	s.mu.Unlock()
 
 	// Close all connections
 	s.connections.Range(func(key, value interface{}) bool {
@@ -90,32 +202,123 @@ func (s *KafkaServer) Close() error {
 	return nil
 }
 
-func (s *KafkaServer) handleConnection(conn net.Conn) {
+// connState is the per-connection state requests may read and change
+type connState struct {
+	connID          uint64
+	nodeID          int32  // advertised broker the client connected to
+	host            string // client address, without the port
+	authenticated   bool
+	principal       string    // SASL identity the connection acts as
+	authID          string    // SASL identity that authenticated, if impersonating
+	sessionExpiry   time.Time // the SASL session must be renewed by then; zero if it lasts
+	closing         bool      // close the connection once the response is written
+	softwareName    string    // from ApiVersions v3+
+	softwareVersion string
+	counted         bool      // connection already counted by the compat tracker
+	nextEmptyFetch  time.Time // an empty fetch before this is throttled
+	stats           *connStats
+}
+
+func (s *KafkaServer) handleConnection(conn net.Conn, nodeID int32) {
 	remoteAddr := conn.RemoteAddr().String()
 	log.Printf("[kafka] new connection from %s", remoteAddr)
+
+	stats := newConnStats(remoteAddr, nodeID)
+	if v, ok := s.connections.Load(conn); ok {
+		stats = v.(*connStats)
+	}
+
+	// Canceled once the client goes away, abandoning its requests' work
+	ctx, cancel := context.WithCancel(context.Background())
+	var inFlight int32
+	requests := make(chan []byte, 1)
+	go s.readRequests(ctx, conn, requests, &inFlight, cancel, stats)
+
+	connID := s.nextConnID.Add(1)
+	s.engine.GetPendingQueue().Connect(connID)
+
 	defer func() {
 		log.Printf("[kafka] closing connection from %s", remoteAddr)
+		cancel()
 		conn.Close()
 		s.connections.Delete(conn)
 		atomic.AddInt32(&s.connCount, -1)
-		s.engine.GetPendingQueue().Remove(conn)
+		s.engine.GetPendingQueue().Disconnect(connID)
 		s.wg.Done()
 	}()
 
-	authenticated := !s.config.Security.Enabled
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	state := &connState{connID: connID, nodeID: nodeID, host: host, authenticated: !s.config.Security.Enabled, stats: stats}
 
 	for {
+		var body []byte
 		select {
 		case <-s.stopChan:
 			return
-		default:
+		case b, ok := <-requests:
+			if !ok {
+				return
+			}
+			body = b
+		}
+
+		// Decode and handle request
+		atomic.StoreInt32(&inFlight, 1)
+		response, err := s.handleRequestSafely(ctx, conn, body, state)
+		atomic.StoreInt32(&inFlight, 0)
+		if err == errRequestPanicked || err == errSessionExpired {
+			// Whatever state the request left behind, the client cannot
+			// be answered in sync any more; make it reconnect
+			return
+		}
+		if err != nil {
+			log.Printf("[kafka] handle error: %v", err)
+			continue
+		}
+
+		if response == nil {
+			// No response expected (an acks=0 produce)
+			if state.closing {
+				return
+			}
+			continue
+		}
+
+		// Write response
+		conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+		n, err := conn.Write(response)
+		s.countSent(stats, n)
+		if err != nil {
+			log.Printf("[kafka] write error: %v", err)
+			return
+		}
+		if state.closing {
+			return
 		}
+	}
+}
+
+// readRequests reads framed requests off conn while the previous one is
+// handled, so a client that disconnects mid-request is noticed. It cancels
+// the connection context and closes out when the client goes away.
+func (s *KafkaServer) readRequests(ctx context.Context, conn net.Conn, out chan<- []byte, inFlight *int32, cancel context.CancelFunc, stats *connStats) {
+	defer close(out)
+	defer cancel()
 
+	for {
 		// Read message size (4 bytes)
 		sizeBuf := make([]byte, 4)
 		conn.SetReadDeadline(time.Now().Add(30 * time.Second))
 		n, err := io.ReadFull(conn, sizeBuf)
 		if err != nil {
+			var netErr net.Error
+			if n == 0 && errors.As(err, &netErr) && netErr.Timeout() && atomic.LoadInt32(inFlight) == 1 {
+				// Waiting on our answer is not idling
+				continue
+			}
 			if err == io.EOF {
 				log.Printf("[kafka] client closed connection (EOF)")
 			} else if n > 0 {
@@ -136,30 +339,48 @@ func (s *KafkaServer) handleConnection(conn net.Conn) {
 			log.Printf("[kafka] read body error: %v", err)
 			return
 		}
+		s.countReceived(stats, len(sizeBuf)+len(body))
 
-		// Decode and handle request
-		response, err := s.handleRequest(conn, body, &authenticated)
-		if err != nil {
-			log.Printf("[kafka] handle error: %v", err)
-			continue
-		}
-
-		if response == nil {
-			// No response needed (e.g., async fetch)
-			continue
+		select {
+		case out <- body:
+		case <-ctx.Done():
+			return
 		}
 
-		// Write response
-		conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
-		if _, err := conn.Write(response); err != nil {
-			log.Printf("[kafka] write error: %v", err)
+		if !s.throttle(stats, len(sizeBuf)+len(body), ctx.Done()) {
 			return
 		}
 	}
 }
 
-func (s *KafkaServer) handleRequest(conn net.Conn, body []byte, authenticated *bool) ([]byte, error) {
-	decoder := protocol.NewDecoder(bytes.NewReader(body))
+// errRequestPanicked is returned for a request whose handler panicked
+var errRequestPanicked = errors.New("request handler panicked")
+
+// handleRequestSafely handles a request, turning a handler panic into
+// errRequestPanicked so one malformed request cannot take down the broker
+func (s *KafkaServer) handleRequestSafely(ctx context.Context, conn net.Conn, body []byte, state *connState) (response []byte, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			kv := []string{"remote", conn.RemoteAddr().String()}
+			if len(body) >= 8 {
+				kv = append(kv,
+					"api_key", fmt.Sprint(int16(binary.BigEndian.Uint16(body[0:2]))),
+					"api_version", fmt.Sprint(int16(binary.BigEndian.Uint16(body[2:4]))),
+					"correlation_id", fmt.Sprint(int32(binary.BigEndian.Uint32(body[4:8]))))
+			}
+			s.engine.ReportPanic("kafka", v, debug.Stack(), kv...)
+			s.errors.Add(conn.RemoteAddr().String(), "request panicked: %v", v)
+			response, err = nil, errRequestPanicked
+		}
+	}()
+	return s.handleRequest(ctx, conn, body, state)
+}
+
+// handleRequest decodes and dispatches one request. ctx is the connection's
+// context; all but group membership requests, which wait out a rebalance
+// on their own timeout, are bounded by limits.request_timeout.
+func (s *KafkaServer) handleRequest(ctx context.Context, conn net.Conn, body []byte, state *connState) ([]byte, error) {
+	decoder := kafkaproto.NewDecoder(bytes.NewReader(body))
 
 	// Read header
 	header, err := decoder.ReadHeader()
@@ -169,12 +390,23 @@ func (s *KafkaServer) handleRequest(conn net.Conn, body []byte, authenticated *b
 
 	log.Printf("[kafka] request: api=%d version=%d corr=%d client=%s",
 		header.APIKey, header.APIVersion, header.CorrelationID, header.ClientID)
+	defer state.stats.identify(header.ClientID, state)
 
 	// Check authentication for non-auth APIs
-	if !*authenticated && header.APIKey != protocol.APIKeySaslHandshake &&
-		header.APIKey != protocol.APIKeySaslAuthenticate &&
-		header.APIKey != protocol.APIKeyApiVersions {
-		return s.errorResponse(header.CorrelationID, protocol.ErrSaslAuthenticationFailed), nil
+	if !state.authenticated && header.APIKey != kafkaproto.APIKeySaslHandshake &&
+		header.APIKey != kafkaproto.APIKeySaslAuthenticate &&
+		header.APIKey != kafkaproto.APIKeyApiVersions {
+		return s.errorResponse(header, kafkaproto.ErrSaslAuthenticationFailed), nil
+	}
+	if err := s.checkSession(conn, header, state); err != nil {
+		return nil, err
+	}
+
+	if timeout := s.config.Limits.RequestTimeout; timeout > 0 &&
+		header.APIKey != kafkaproto.APIKeyJoinGroup && header.APIKey != kafkaproto.APIKeySyncGroup {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
 
 	// Dispatch to handler
@@ -182,43 +414,84 @@ func (s *KafkaServer) handleRequest(conn net.Conn, body []byte, authenticated *b
 	var handlerErr error
 
 	switch header.APIKey {
-	case protocol.APIKeyApiVersions:
-		resp, handlerErr = s.handleApiVersions(header, decoder)
-	case protocol.APIKeySaslHandshake:
+	case kafkaproto.APIKeyApiVersions:
+		resp, handlerErr = s.handleApiVersions(header, decoder, state)
+	case kafkaproto.APIKeySaslHandshake:
 		resp, handlerErr = s.handleSaslHandshake(header, decoder)
-	case protocol.APIKeySaslAuthenticate:
-		resp, handlerErr = s.handleSaslAuthenticate(header, decoder, authenticated)
-	case protocol.APIKeyMetadata:
-		resp, handlerErr = s.handleMetadata(header, decoder)
-	case protocol.APIKeyCreateTopics:
-		resp, handlerErr = s.handleCreateTopics(header, decoder)
-	case protocol.APIKeyProduce:
-		resp, handlerErr = s.handleProduce(header, decoder)
-	case protocol.APIKeyFetch:
-		resp, handlerErr = s.handleFetch(conn, header, decoder)
-	case protocol.APIKeyListOffsets:
-		resp, handlerErr = s.handleListOffsets(header, decoder)
-	case protocol.APIKeyFindCoordinator:
+	case kafkaproto.APIKeySaslAuthenticate:
+		resp, handlerErr = s.handleSaslAuthenticate(conn, header, decoder, state)
+	case kafkaproto.APIKeyMetadata:
+		resp, handlerErr = s.handleMetadata(ctx, header, decoder, state)
+	case kafkaproto.APIKeyCreateTopics:
+		resp, handlerErr = s.handleCreateTopics(ctx, header, decoder, state)
+	case kafkaproto.APIKeyCreatePartitions:
+		resp, handlerErr = s.handleCreatePartitions(header, decoder, state)
+	case kafkaproto.APIKeyDeleteRecords:
+		resp, handlerErr = s.handleDeleteRecords(ctx, header, decoder, state)
+	case kafkaproto.APIKeyInitProducerID:
+		resp, handlerErr = s.handleInitProducerID(ctx, header, decoder)
+	case kafkaproto.APIKeyAddPartitionsToTxn:
+		resp, handlerErr = s.handleAddPartitionsToTxn(ctx, header, decoder, state)
+	case kafkaproto.APIKeyAddOffsetsToTxn:
+		resp, handlerErr = s.handleAddOffsetsToTxn(ctx, header, decoder, state)
+	case kafkaproto.APIKeyEndTxn:
+		resp, handlerErr = s.handleEndTxn(ctx, header, decoder)
+	case kafkaproto.APIKeyTxnOffsetCommit:
+		resp, handlerErr = s.handleTxnOffsetCommit(ctx, header, decoder, state)
+	case kafkaproto.APIKeyDescribeConfigs:
+		resp, handlerErr = s.handleDescribeConfigs(header, decoder)
+	case kafkaproto.APIKeyAlterConfigs:
+		resp, handlerErr = s.handleAlterConfigs(ctx, header, decoder, state)
+	case kafkaproto.APIKeyIncrementalAlterConfigs:
+		resp, handlerErr = s.handleIncrementalAlterConfigs(ctx, header, decoder, state)
+	case kafkaproto.APIKeyDescribeCluster:
+		resp, handlerErr = s.handleDescribeCluster(header, decoder)
+	case kafkaproto.APIKeyDescribeLogDirs:
+		resp, handlerErr = s.handleDescribeLogDirs(ctx, header, decoder, state)
+	case kafkaproto.APIKeyDescribeAcls:
+		resp, handlerErr = s.handleDescribeAcls(header, decoder, state)
+	case kafkaproto.APIKeyCreateAcls:
+		resp, handlerErr = s.handleCreateAcls(ctx, header, decoder, state)
+	case kafkaproto.APIKeyDeleteAcls:
+		resp, handlerErr = s.handleDeleteAcls(ctx, header, decoder, state)
+	case kafkaproto.APIKeyProduce:
+		resp, handlerErr = s.handleProduce(ctx, header, decoder, state)
+	case kafkaproto.APIKeyFetch:
+		resp, handlerErr = s.handleFetch(ctx, conn, header, decoder, state)
+	case kafkaproto.APIKeyListOffsets:
+		resp, handlerErr = s.handleListOffsets(ctx, header, decoder, state)
+	case kafkaproto.APIKeyFindCoordinator:
 		resp, handlerErr = s.handleFindCoordinator(header, decoder)
-	case protocol.APIKeyJoinGroup:
-		resp, handlerErr = s.handleJoinGroup(header, decoder)
-	case protocol.APIKeySyncGroup:
-		resp, handlerErr = s.handleSyncGroup(header, decoder)
-	case protocol.APIKeyHeartbeat:
-		resp, handlerErr = s.handleHeartbeat(header, decoder)
-	case protocol.APIKeyLeaveGroup:
-		resp, handlerErr = s.handleLeaveGroup(header, decoder)
-	case protocol.APIKeyOffsetCommit:
-		resp, handlerErr = s.handleOffsetCommit(header, decoder)
-	case protocol.APIKeyOffsetFetch:
-		resp, handlerErr = s.handleOffsetFetch(header, decoder)
+	case kafkaproto.APIKeyJoinGroup:
+		resp, handlerErr = s.handleJoinGroup(ctx, header, decoder, state)
+	case kafkaproto.APIKeySyncGroup:
+		resp, handlerErr = s.handleSyncGroup(ctx, header, decoder, state)
+	case kafkaproto.APIKeyHeartbeat:
+		resp, handlerErr = s.handleHeartbeat(header, decoder, state)
+	case kafkaproto.APIKeyLeaveGroup:
+		resp, handlerErr = s.handleLeaveGroup(header, decoder, state)
+	case kafkaproto.APIKeyOffsetCommit:
+		resp, handlerErr = s.handleOffsetCommit(ctx, header, decoder, state)
+	case kafkaproto.APIKeyOffsetFetch:
+		resp, handlerErr = s.handleOffsetFetch(header, decoder, state)
+	case kafkaproto.APIKeyOffsetForLeaderEpoch:
+		resp, handlerErr = s.handleOffsetForLeaderEpoch(header, decoder, state)
+	case kafkaproto.APIKeyMonologPurge:
+		if !s.config.Server.KafkaPurge {
+			return s.unsupported(header, state), nil
+		}
+		resp, handlerErr = s.handleMonologPurge(ctx, header, decoder, state)
 	default:
-		log.Printf("[kafka] unsupported API key: %d", header.APIKey)
-		return s.errorResponse(header.CorrelationID, protocol.ErrUnsupportedVersion), nil
+		return s.unsupported(header, state), nil
 	}
 
+	// After dispatch, so ApiVersions is counted under the software it names
+	s.compat.record(state, header.ClientID, header.APIKey, header.APIVersion)
+
 	if handlerErr != nil {
 		log.Printf("[kafka] handler error for api=%d: %v", header.APIKey, handlerErr)
+		s.errors.Add(header.ClientID, "api %d v%d: %v", header.APIKey, header.APIVersion, handlerErr)
+		s.engine.CountError(engine.ErrorKindRequest)
 	}
 	return resp, handlerErr
 }
@@ -227,36 +500,44 @@ func (s *KafkaServer) handleRequest(conn net.Conn, body []byte, authenticated *b
 // API Handlers
 // ============================================================================
 
-func (s *KafkaServer) handleApiVersions(header protocol.RequestHeader, dec *protocol.Decoder) ([]byte, error) {
-	// Decode request (we don't really need the contents for our response)
-	protocol.DecodeApiVersionsRequest(dec, header.APIVersion)
+func (s *KafkaServer) handleApiVersions(header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	// The response doesn't depend on the request; v3+ names the client software
+	req, _ := kafkaproto.DecodeApiVersionsRequest(dec, header.APIVersion)
+	if req.ClientSoftwareName != "" {
+		state.softwareName = req.ClientSoftwareName
+		state.softwareVersion = req.ClientSoftwareVersion
+	}
 
 	// Build response
-	resp := &protocol.ApiVersionsResponse{
-		ErrorCode:    protocol.ErrNone,
-		ApiVersions:  protocol.DefaultApiVersions(),
+	resp := &kafkaproto.ApiVersionsResponse{
+		ErrorCode:    kafkaproto.ErrNone,
+		ApiVersions:  kafkaproto.DefaultApiVersions(),
 		ThrottleTimeMs: 0,
 	}
+	if s.config.Server.KafkaPurge {
+		resp.ApiVersions = append(resp.ApiVersions, kafkaproto.ExtensionApiVersions()...)
+	}
+	resp.ApiVersions = s.quirksFor(state, header.ClientID).advertised(resp.ApiVersions)
 
-	enc := protocol.NewEncoder()
-	enc.WriteResponseHeader(header.CorrelationID)
-	protocol.EncodeApiVersionsResponse(enc, header.APIVersion, resp)
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeApiVersionsResponse(enc, header.APIVersion, resp)
 
 	return s.wrapResponse(enc.Bytes()), nil
 }
 
-func (s *KafkaServer) handleSaslHandshake(header protocol.RequestHeader, dec *protocol.Decoder) ([]byte, error) {
+func (s *KafkaServer) handleSaslHandshake(header kafkaproto.RequestHeader, dec *kafkaproto.Decoder) ([]byte, error) {
 	mechanism, _ := dec.ReadString()
 
-	enc := protocol.NewEncoder()
-	enc.WriteResponseHeader(header.CorrelationID)
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
 
 	if mechanism == "PLAIN" {
-		enc.WriteInt16(protocol.ErrNone)
+		enc.WriteInt16(kafkaproto.ErrNone)
 		enc.WriteArrayLen(1)
 		enc.WriteString("PLAIN")
 	} else {
-		enc.WriteInt16(protocol.ErrUnsupportedSaslMechanism)
+		enc.WriteInt16(kafkaproto.ErrUnsupportedSaslMechanism)
 		enc.WriteArrayLen(1)
 		enc.WriteString("PLAIN")
 	}
@@ -264,45 +545,70 @@ func (s *KafkaServer) handleSaslHandshake(header protocol.RequestHeader, dec *pr
 	return s.wrapResponse(enc.Bytes()), nil
 }
 
-func (s *KafkaServer) handleSaslAuthenticate(header protocol.RequestHeader, dec *protocol.Decoder, authenticated *bool) ([]byte, error) {
-	authBytes, _ := dec.ReadBytes()
+func (s *KafkaServer) handleSaslAuthenticate(conn net.Conn, header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	req, err := kafkaproto.DecodeSaslAuthenticateRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode sasl authenticate request: %w", err)
+	}
+	if s.config.Logging.Level == "debug" {
+		log.Printf("[kafka] sasl authenticate: client=%s payload=%s", header.ClientID, redact.Size(req.AuthBytes))
+	}
 
-	enc := protocol.NewEncoder()
-	enc.WriteResponseHeader(header.CorrelationID)
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
 
-	// Parse PLAIN auth: \0username\0password
-	parts := bytes.Split(authBytes, []byte{0})
-	var password string
-	if len(parts) >= 3 {
-		password = string(parts[2])
+	creds, err := parsePlain(req.AuthBytes)
+	var principal string
+	if err == nil {
+		principal, err = s.authenticatePlain(creds)
+	}
+	reauth := state.authenticated && s.config.Security.Enabled
+	if err == nil && reauth {
+		err = checkReauthPrincipal(state, creds, principal)
 	}
 
-	if password == s.config.Security.Token {
-		*authenticated = true
-		enc.WriteInt16(protocol.ErrNone)
-		enc.WriteNullableString(nil)
-		enc.WriteBytes(nil)
-	} else {
-		enc.WriteInt16(protocol.ErrSaslAuthenticationFailed)
-		errMsg := "Authentication failed"
-		enc.WriteNullableString(&errMsg)
-		enc.WriteBytes(nil)
+	kv := []string{"remote", conn.RemoteAddr().String(), "client", header.ClientID,
+		"authcid", creds.authcID, "authzid", creds.authzID}
+	if reauth {
+		kv = append(kv, "reauth", "true")
+	}
+	resp := &kafkaproto.SaslAuthenticateResponse{AuthBytes: []byte{}}
+	if err != nil {
+		audit("sasl_authenticate", append(kv, "result", "denied", "reason", err.Error())...)
+		errMsg := err.Error()
+		resp.ErrorCode = kafkaproto.ErrSaslAuthenticationFailed
+		resp.ErrorMessage = &errMsg
+		// A failed re-authentication ends the connection, as in Kafka
+		state.closing = reauth
+		kafkaproto.EncodeSaslAuthenticateResponse(enc, header.APIVersion, resp)
+		return s.wrapResponse(enc.Bytes()), nil
+	}
+
+	audit("sasl_authenticate", append(kv, "principal", principal, "result", "ok")...)
+	state.authenticated = true
+	state.principal = principal
+	state.authID = ""
+	if principal != creds.authcID {
+		state.authID = creds.authcID
+	}
+	state.sessionExpiry = time.Time{}
+	if lifetime := s.config.Security.ConnectionsMaxReauth; lifetime > 0 {
+		state.sessionExpiry = time.Now().Add(lifetime)
+		resp.SessionLifetimeMs = lifetime.Milliseconds()
 	}
+	kafkaproto.EncodeSaslAuthenticateResponse(enc, header.APIVersion, resp)
 
 	return s.wrapResponse(enc.Bytes()), nil
 }
 
-func (s *KafkaServer) handleMetadata(header protocol.RequestHeader, dec *protocol.Decoder) ([]byte, error) {
-	req, err := protocol.DecodeMetadataRequest(dec, header.APIVersion)
+func (s *KafkaServer) handleMetadata(ctx context.Context, header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	req, err := kafkaproto.DecodeMetadataRequest(dec, header.APIVersion)
 	if err != nil {
 		return nil, fmt.Errorf("decode metadata request: %w", err)
 	}
 
 	log.Printf("[kafka] metadata: topics=%v allowAutoCreate=%v", req.Topics, req.AllowAutoTopicCreation)
 
-	// Get broker info
-	host, port := parseAddr(s.config.Server.KafkaAddr)
-
 	// Determine which topics to return
 	var topicNames []string
 	if req.Topics == nil || len(req.Topics) == 0 {
@@ -313,619 +619,1944 @@ func (s *KafkaServer) handleMetadata(header protocol.RequestHeader, dec *protoco
 	}
 
 	// Build response
-	resp := &protocol.MetadataResponse{
+	resp := &kafkaproto.MetadataResponse{
 		ThrottleTimeMs: 0,
-		Brokers: []protocol.MetadataBroker{
-			{NodeID: 0, Host: host, Port: port, Rack: nil},
-		},
-		ClusterID:         strPtr("monolog-cluster"),
+		ClusterID:         strPtr(clusterID),
 		ControllerID:      0,
 		IncludeClusterOps: req.IncludeClusterAuthorizedOperations,
 		IncludeTopicOps:   req.IncludeTopicAuthorizedOperations,
 	}
+	for _, b := range s.brokers() {
+		resp.Brokers = append(resp.Brokers, kafkaproto.MetadataBroker{NodeID: b.NodeID, Host: b.Host, Port: b.Port, Rack: nil})
+	}
 
-	for _, name := range topicNames {
+	for i, name := range topicNames {
+		if name == "" && i < len(req.TopicIDs) && !req.TopicIDs[i].IsZero() {
+			// Asked for by ID alone (v12+)
+			if name = s.engine.TopicByID(req.TopicIDs[i].String()); name == "" {
+				resp.Topics = append(resp.Topics, kafkaproto.MetadataTopic{
+					ErrorCode:  kafkaproto.ErrUnknownTopicID,
+					TopicID:    req.TopicIDs[i],
+					Partitions: []kafkaproto.MetadataPartition{},
+				})
+				continue
+			}
+		}
 		exists := s.engine.TopicExists(name)
+		created, invalid := false, false
 
-		// Auto-create topic if it doesn't exist and auto-creation is allowed
-		if !exists && req.AllowAutoTopicCreation {
-			err := s.engine.CreateTopic(name)
+		// Auto-create topic if it doesn't exist and auto-creation is allowed,
+		// by the request and by the ACLs
+		if !exists && req.AllowAutoTopicCreation && s.mayCreateTopic(state, name) {
+			err := s.engine.CreateTopic(ctx, name)
 			if err == nil {
-				exists = true
+				exists, created = true, true
 				log.Printf("[kafka] auto-created topic: %s", name)
+			} else if errors.Is(err, store.ErrTopicExists) {
+				exists = true // created concurrently
+			} else if errors.Is(err, engine.ErrInvalidTopic) {
+				invalid = true
 			}
 		}
 
-		topic := protocol.MetadataTopic{
+		topic := kafkaproto.MetadataTopic{
 			Name:       name,
 			IsInternal: false,
 		}
 
-		if exists {
-			topic.ErrorCode = protocol.ErrNone
-			topic.Partitions = []protocol.MetadataPartition{
+		if s.coldStart != nil && exists {
+			s.coldStart.forget(name)
+		}
+
+		if created && s.coldStart != nil {
+			// A real broker has no leader yet for a topic it has just
+			// created; clients retry until it does
+			topic.ErrorCode = kafkaproto.ErrLeaderNotAvailable
+			topic.Partitions = []kafkaproto.MetadataPartition{}
+		} else if exists {
+			leader := s.leaderFor(name, 0)
+			epoch, _ := s.engine.LeaderEpoch(name)
+			topic.ErrorCode = kafkaproto.ErrNone
+			topic.TopicID = s.topicID(name)
+			topic.Partitions = []kafkaproto.MetadataPartition{
 				{
-					ErrorCode:       protocol.ErrNone,
+					ErrorCode:       kafkaproto.ErrNone,
 					PartitionIndex:  0,
-					LeaderID:        0,
-					LeaderEpoch:     0,
-					ReplicaNodes:    []int32{0},
-					IsrNodes:        []int32{0},
+					LeaderID:        leader,
+					LeaderEpoch:     epoch,
+					ReplicaNodes:    []int32{leader},
+					IsrNodes:        []int32{leader},
 					OfflineReplicas: []int32{},
 				},
 			}
+		} else if invalid {
+			topic.ErrorCode = kafkaproto.ErrInvalidTopicException
+			topic.Partitions = []kafkaproto.MetadataPartition{}
+		} else if s.coldStart != nil && s.coldStart.pending(name, time.Now()) {
+			// Clients that cache UNKNOWN_TOPIC_OR_PARTITION would not notice
+			// the topic being created shortly after
+			topic.ErrorCode = kafkaproto.ErrLeaderNotAvailable
+			topic.Partitions = []kafkaproto.MetadataPartition{}
 		} else {
-			topic.ErrorCode = protocol.ErrUnknownTopicOrPartition
-			topic.Partitions = []protocol.MetadataPartition{}
+			topic.ErrorCode = kafkaproto.ErrUnknownTopicOrPartition
+			topic.Partitions = []kafkaproto.MetadataPartition{}
 		}
 
 		resp.Topics = append(resp.Topics, topic)
 	}
 
-	enc := protocol.NewEncoder()
-	enc.WriteResponseHeader(header.CorrelationID)
-	protocol.EncodeMetadataResponse(enc, header.APIVersion, resp)
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeMetadataResponse(enc, header.APIVersion, resp)
 
 	return s.wrapResponse(enc.Bytes()), nil
 }
 
-func (s *KafkaServer) handleCreateTopics(header protocol.RequestHeader, dec *protocol.Decoder) ([]byte, error) {
-	req, err := protocol.DecodeCreateTopicsRequest(dec, header.APIVersion)
-	if err != nil {
-		return nil, fmt.Errorf("decode create topics request: %w", err)
+// topicID returns the ID of a topic as a protocol UUID, zero if the topic
+// does not exist
+func (s *KafkaServer) topicID(name string) kafkaproto.UUID {
+	meta, err := s.engine.GetTopicMeta(name)
+	if err != nil || meta == nil {
+		return kafkaproto.UUID{}
 	}
+	id, _ := kafkaproto.ParseUUID(meta.ID)
+	return id
+}
 
-	resp := &protocol.CreateTopicsResponse{
-		ThrottleTimeMs: 0,
+// handleDescribeCluster answers the AdminClient's cluster description with
+// the same nodes, cluster ID and controller Metadata reports
+func (s *KafkaServer) handleDescribeCluster(header kafkaproto.RequestHeader, dec *kafkaproto.Decoder) ([]byte, error) {
+	req, err := kafkaproto.DecodeDescribeClusterRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode describe cluster request: %w", err)
 	}
 
-	for _, t := range req.Topics {
-		result := protocol.CreateTopicsResponseTopic{
-			Name: t.Name,
-		}
-
-		err := s.engine.CreateTopic(t.Name)
-		if err != nil {
-			result.ErrorCode = protocol.ErrTopicAlreadyExists
-		} else {
-			result.ErrorCode = protocol.ErrNone
-		}
-
-		resp.Topics = append(resp.Topics, result)
+	resp := &kafkaproto.DescribeClusterResponse{
+		ThrottleTimeMs:              0,
+		EndpointType:                req.EndpointType,
+		ClusterID:                   clusterID,
+		ControllerID:                0,
+		ClusterAuthorizedOperations: -2147483648, // INT32_MIN = not requested
+	}
+	if req.IncludeClusterAuthorizedOperations {
+		resp.ClusterAuthorizedOperations = 0
 	}
 
-	enc := protocol.NewEncoder()
-	if header.APIVersion >= 5 {
-		enc.WriteResponseHeaderV1(header.CorrelationID)
+	if req.EndpointType != kafkaproto.EndpointTypeBroker {
+		// There is no separate controller quorum to describe
+		msg := fmt.Sprintf("endpoint type %d is not served; only brokers (1) are", req.EndpointType)
+		resp.ErrorCode = kafkaproto.ErrUnsupportedEndpointType
+		resp.ErrorMessage = &msg
 	} else {
-		enc.WriteResponseHeader(header.CorrelationID)
+		for _, b := range s.brokers() {
+			resp.Brokers = append(resp.Brokers, kafkaproto.DescribeClusterBroker{BrokerID: b.NodeID, Host: b.Host, Port: b.Port})
+		}
 	}
-	protocol.EncodeCreateTopicsResponse(enc, header.APIVersion, resp)
+
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeDescribeClusterResponse(enc, header.APIVersion, resp)
 
 	return s.wrapResponse(enc.Bytes()), nil
 }
 
-func (s *KafkaServer) handleProduce(header protocol.RequestHeader, dec *protocol.Decoder) ([]byte, error) {
-	req, err := protocol.DecodeProduceRequest(dec, header.APIVersion)
+// handleDescribeLogDirs reports the data directory as the one log dir and
+// the storage each topic takes in it. Under virtual brokers a node only
+// lists the partitions it leads.
+func (s *KafkaServer) handleDescribeLogDirs(ctx context.Context, header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	req, err := kafkaproto.DecodeDescribeLogDirsRequest(dec, header.APIVersion)
 	if err != nil {
-		return nil, fmt.Errorf("decode produce request: %w", err)
+		return nil, fmt.Errorf("decode describe log dirs request: %w", err)
 	}
 
-	resp := &protocol.ProduceResponse{
-		ThrottleTimeMs: 0,
+	dir, err := filepath.Abs(s.config.Storage.DataDir)
+	if err != nil {
+		dir = s.config.Storage.DataDir
+	}
+	result := kafkaproto.DescribeLogDirsResult{LogDir: dir, TotalBytes: -1, UsableBytes: -1}
+	if free, total, err := s.engine.DataDirSpace(); err == nil {
+		result.TotalBytes, result.UsableBytes = int64(total), int64(free)
 	}
 
-	for _, t := range req.Topics {
-		topicResp := protocol.ProduceResponseTopic{
-			Name: t.Name,
+	// Only partition 0 exists; unknown topics and partitions are left out
+	wanted := make(map[string]bool)
+	if req.Topics == nil {
+		for _, name := range s.engine.ListTopics() {
+			wanted[name] = true
 		}
-
-		for _, p := range t.Partitions {
-			partResp := protocol.ProduceResponsePartition{
-				Index:           p.Index,
-				LogAppendTimeMs: -1,
-				LogStartOffset:  0,
-			}
-
-			// Extract codec from record batch attributes (bytes 21-22)
-			var codec int8 = 0
-			if len(p.Records) >= 23 {
-				attrs := int16(p.Records[21])<<8 | int16(p.Records[22])
-				codec = int8(attrs & 0x07)
-			}
-
-			// Store raw (passthrough)
-			baseOffset, err := s.engine.ProduceRaw(t.Name, p.Records, codec, 1)
-			if err != nil {
-				partResp.ErrorCode = protocol.ErrUnknownTopicOrPartition
-			} else {
-				partResp.ErrorCode = protocol.ErrNone
-				partResp.BaseOffset = baseOffset
+	} else {
+		for _, t := range req.Topics {
+			if slices.Contains(t.Partitions, 0) {
+				wanted[t.Name] = true
 			}
+		}
+	}
+	names := slices.Sorted(maps.Keys(wanted))
 
-			topicResp.Partitions = append(topicResp.Partitions, partResp)
+	for _, name := range names {
+		if s.leaderFor(name, 0) != state.nodeID {
+			continue
+		}
+		size, err := s.engine.TopicSize(ctx, name)
+		if errors.Is(err, store.ErrTopicNotFound) {
+			continue
+		}
+		if err != nil {
+			result.ErrorCode = kafkaproto.ErrKafkaStorageError
+			result.Topics = nil
+			break
 		}
+		result.Topics = append(result.Topics, kafkaproto.DescribeLogDirsTopic{
+			Name:       name,
+			Partitions: []kafkaproto.DescribeLogDirsPartition{{PartitionIndex: 0, PartitionSize: size}},
+		})
+	}
 
-		resp.Topics = append(resp.Topics, topicResp)
+	resp := &kafkaproto.DescribeLogDirsResponse{
+		ThrottleTimeMs: 0,
+		Results:        []kafkaproto.DescribeLogDirsResult{result},
 	}
 
-	enc := protocol.NewEncoder()
-	enc.WriteResponseHeader(header.CorrelationID)
-	protocol.EncodeProduceResponse(enc, header.APIVersion, resp)
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeDescribeLogDirsResponse(enc, header.APIVersion, resp)
 
 	return s.wrapResponse(enc.Bytes()), nil
 }
 
-func (s *KafkaServer) handleFetch(conn net.Conn, header protocol.RequestHeader, dec *protocol.Decoder) ([]byte, error) {
-	req, err := protocol.DecodeFetchRequest(dec, header.APIVersion)
-	if err != nil {
-		return nil, fmt.Errorf("decode fetch request: %w", err)
+// aclHeader starts an ACL API response; the ACL APIs turn flexible at v2
+func aclHeader(header kafkaproto.RequestHeader) *kafkaproto.Encoder {
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	return enc
+}
+
+// aclRequestError returns the error code and message an ACL request fails
+// with before it is looked at: ACLs are disabled, or the connection may
+// not perform operation on the cluster
+func (s *KafkaServer) aclRequestError(state *connState, operation int8) (int16, *string) {
+	if !s.engine.ACLsEnabled() {
+		return kafkaproto.ErrSecurityDisabled, strPtr("ACLs are not enabled (security.acls.enabled)")
+	}
+	if !s.authorized(state, operation, kafkaproto.AclResourceCluster, engine.ClusterResource) {
+		return kafkaproto.ErrClusterAuthorizationFailed, strPtr("not authorized to manage ACLs")
 	}
+	return kafkaproto.ErrNone, nil
+}
 
-	resp := &protocol.FetchResponse{
-		ThrottleTimeMs: 0,
-		ErrorCode:    protocol.ErrNone,
-		SessionID:    0,
+// aclFilter converts a protocol ACL filter
+func aclFilter(f kafkaproto.AclFilter) engine.ACLFilter {
+	return engine.ACLFilter{
+		ResourceType: f.ResourceType,
+		ResourceName: f.ResourceName,
+		PatternType:  f.PatternType,
+		Principal:    f.Principal,
+		Host:         f.Host,
+		Operation:    f.Operation,
+		Permission:   f.PermissionType,
 	}
+}
 
-	for _, t := range req.Topics {
-		topicResp := protocol.FetchResponseTopic{
-			Name: t.Name,
-		}
+func (s *KafkaServer) handleDescribeAcls(header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	req, err := kafkaproto.DecodeDescribeAclsRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode describe acls request: %w", err)
+	}
 
-		for _, p := range t.Partitions {
-			partResp := protocol.FetchResponsePartition{
-				Index:                p.Index,
-				PreferredReadReplica: -1,
+	resp := &kafkaproto.DescribeAclsResponse{ThrottleTimeMs: 0}
+	resp.ErrorCode, resp.ErrorMessage = s.aclRequestError(state, kafkaproto.AclOperationDescribe)
+	if resp.ErrorCode == kafkaproto.ErrNone {
+		// Bindings come sorted by resource; group them per resource pattern
+		for _, b := range s.engine.DescribeACLs(aclFilter(req.Filter)) {
+			n := len(resp.Resources)
+			if n == 0 || resp.Resources[n-1].ResourceType != b.ResourceType ||
+				resp.Resources[n-1].ResourceName != b.ResourceName || resp.Resources[n-1].PatternType != b.PatternType {
+				resp.Resources = append(resp.Resources, kafkaproto.DescribeAclsResource{
+					ResourceType: b.ResourceType,
+					ResourceName: b.ResourceName,
+					PatternType:  b.PatternType,
+				})
+				n++
 			}
+			resp.Resources[n-1].Acls = append(resp.Resources[n-1].Acls, kafkaproto.AclDescription{
+				Principal:      b.Principal,
+				Host:           b.Host,
+				Operation:      b.Operation,
+				PermissionType: b.Permission,
+			})
+		}
+	}
 
-			if !s.engine.TopicExists(t.Name) {
-				partResp.ErrorCode = protocol.ErrUnknownTopicOrPartition
-			} else {
-				records, _ := s.engine.Fetch(t.Name, p.FetchOffset, 100)
-				latest, _ := s.engine.LatestOffset(t.Name)
-				earliest, _ := s.engine.EarliestOffset(t.Name)
-
-				partResp.ErrorCode = protocol.ErrNone
-				partResp.HighWatermark = latest + 1
-				partResp.LastStableOffset = latest + 1
-				partResp.LogStartOffset = earliest
-
-				if len(records) > 0 {
-					// Return the raw batch data with patched baseOffset
-					batchData := make([]byte, len(records[0].Value))
-					copy(batchData, records[0].Value)
-					// Patch baseOffset (bytes 0-7) to match our assigned offset
-					if len(batchData) >= 8 {
-						binary.BigEndian.PutUint64(batchData[0:8], uint64(records[0].Offset))
-					}
-					partResp.Records = batchData
-				}
-			}
+	enc := aclHeader(header)
+	kafkaproto.EncodeDescribeAclsResponse(enc, header.APIVersion, resp)
 
-			topicResp.Partitions = append(topicResp.Partitions, partResp)
-		}
+	return s.wrapResponse(enc.Bytes()), nil
+}
 
-		resp.Topics = append(resp.Topics, topicResp)
+func (s *KafkaServer) handleCreateAcls(ctx context.Context, header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	req, err := kafkaproto.DecodeCreateAclsRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode create acls request: %w", err)
 	}
 
-	// Long-polling is disabled to avoid out-of-order responses on the same connection.
-	// Clients will retry with a short poll interval.
-	// TODO: Implement proper request pipelining with ordered response delivery.
+	resp := &kafkaproto.CreateAclsResponse{
+		ThrottleTimeMs: 0,
+		Results:        make([]kafkaproto.AclCreationResult, len(req.Creations)),
+	}
+	errCode, errMsg := s.aclRequestError(state, kafkaproto.AclOperationAlter)
+	for i, c := range req.Creations {
+		resp.Results[i] = kafkaproto.AclCreationResult{ErrorCode: errCode, ErrorMessage: errMsg}
+		if errCode != kafkaproto.ErrNone {
+			continue
+		}
+		b := store.ACLBinding{
+			ResourceType: c.ResourceType,
+			ResourceName: c.ResourceName,
+			PatternType:  c.ResourcePatternType,
+			Principal:    c.Principal,
+			Host:         c.Host,
+			Operation:    c.Operation,
+			Permission:   c.PermissionType,
+		}
+		if err := s.engine.CreateACLs(ctx, []store.ACLBinding{b}); err != nil {
+			resp.Results[i] = kafkaproto.AclCreationResult{ErrorCode: errorCode(err), ErrorMessage: strPtr(err.Error())}
+			continue
+		}
+		audit("acl_created", "principal", principalOf(state), "binding", fmt.Sprintf("%+v", b))
+	}
 
-	enc := protocol.NewEncoder()
-	enc.WriteResponseHeader(header.CorrelationID)
-	protocol.EncodeFetchResponse(enc, header.APIVersion, resp)
+	enc := aclHeader(header)
+	kafkaproto.EncodeCreateAclsResponse(enc, header.APIVersion, resp)
 
 	return s.wrapResponse(enc.Bytes()), nil
 }
 
-func (s *KafkaServer) handleAsyncFetch(conn net.Conn, header protocol.RequestHeader, req *engine.PendingFetch, responseChan chan engine.FetchResult) {
-	select {
-	case result := <-responseChan:
-		latest, _ := s.engine.LatestOffset(req.Topic)
-		earliest, _ := s.engine.EarliestOffset(req.Topic)
-
-		resp := &protocol.FetchResponse{
-			ThrottleTimeMs: 0,
-			ErrorCode:    protocol.ErrNone,
-			SessionID:    0,
-			Topics: []protocol.FetchResponseTopic{
-				{
-					Name: req.Topic,
-					Partitions: []protocol.FetchResponsePartition{
-						{
-							Index:                req.Partition,
-							ErrorCode:            protocol.ErrNone,
-							HighWatermark:        latest + 1,
-							LastStableOffset:     latest + 1,
-							LogStartOffset:       earliest,
-							PreferredReadReplica: -1,
-						},
-					},
-				},
-			},
-		}
+func (s *KafkaServer) handleDeleteAcls(ctx context.Context, header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	req, err := kafkaproto.DecodeDeleteAclsRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode delete acls request: %w", err)
+	}
 
-		if len(result.Records) > 0 {
-			resp.Topics[0].Partitions[0].Records = result.Records[0].Value
+	resp := &kafkaproto.DeleteAclsResponse{
+		ThrottleTimeMs: 0,
+		FilterResults:  make([]kafkaproto.DeleteAclsFilterResult, len(req.Filters)),
+	}
+	errCode, errMsg := s.aclRequestError(state, kafkaproto.AclOperationAlter)
+	var deleted [][]store.ACLBinding
+	if errCode == kafkaproto.ErrNone {
+		filters := make([]engine.ACLFilter, len(req.Filters))
+		for i, f := range req.Filters {
+			filters[i] = aclFilter(f)
 		}
+		if deleted, err = s.engine.DeleteACLs(ctx, filters); err != nil {
+			errCode, errMsg = errorCode(err), strPtr(err.Error())
+		}
+	}
+	for i := range resp.FilterResults {
+		resp.FilterResults[i] = kafkaproto.DeleteAclsFilterResult{ErrorCode: errCode, ErrorMessage: errMsg}
+		if errCode != kafkaproto.ErrNone {
+			continue
+		}
+		for _, b := range deleted[i] {
+			resp.FilterResults[i].MatchingAcls = append(resp.FilterResults[i].MatchingAcls, kafkaproto.DeleteAclsMatchingAcl{
+				ResourceType:   b.ResourceType,
+				ResourceName:   b.ResourceName,
+				PatternType:    b.PatternType,
+				Principal:      b.Principal,
+				Host:           b.Host,
+				Operation:      b.Operation,
+				PermissionType: b.Permission,
+			})
+			audit("acl_deleted", "principal", principalOf(state), "binding", fmt.Sprintf("%+v", b))
+		}
+	}
 
-		enc := protocol.NewEncoder()
-		enc.WriteResponseHeader(header.CorrelationID)
-		protocol.EncodeFetchResponse(enc, header.APIVersion, resp)
-
-		conn.Write(s.wrapResponse(enc.Bytes()))
+	enc := aclHeader(header)
+	kafkaproto.EncodeDeleteAclsResponse(enc, header.APIVersion, resp)
 
-	case <-s.stopChan:
-		return
-	}
+	return s.wrapResponse(enc.Bytes()), nil
 }
 
-func (s *KafkaServer) handleListOffsets(header protocol.RequestHeader, dec *protocol.Decoder) ([]byte, error) {
-	req, err := protocol.DecodeListOffsetsRequest(dec, header.APIVersion)
+func (s *KafkaServer) handleCreateTopics(ctx context.Context, header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	req, err := kafkaproto.DecodeCreateTopicsRequest(dec, header.APIVersion)
 	if err != nil {
-		return nil, fmt.Errorf("decode list offsets request: %w", err)
+		return nil, fmt.Errorf("decode create topics request: %w", err)
 	}
 
-	resp := &protocol.ListOffsetsResponse{
+	resp := &kafkaproto.CreateTopicsResponse{
 		ThrottleTimeMs: 0,
 	}
 
 	for _, t := range req.Topics {
-		topicResp := protocol.ListOffsetsResponseTopic{
+		result := kafkaproto.CreateTopicsResponseTopic{
 			Name: t.Name,
 		}
 
-		for _, p := range t.Partitions {
-			partResp := protocol.ListOffsetsResponsePartition{
-				PartitionIndex: p.PartitionIndex,
-				LeaderEpoch:    -1,
-			}
-
-			var offset int64
-			var err error
-
-			if p.Timestamp == protocol.OffsetLatest {
-				offset, err = s.engine.LatestOffset(t.Name)
-				if err == nil {
-					offset++ // next offset
-				}
-			} else if p.Timestamp == protocol.OffsetEarliest {
-				offset, err = s.engine.EarliestOffset(t.Name)
-			}
+		if !s.mayCreateTopic(state, t.Name) {
+			result.ErrorCode = kafkaproto.ErrTopicAuthorizationFailed
+			result.ErrorMessage = strPtr("not authorized to create topic")
+			resp.Topics = append(resp.Topics, result)
+			continue
+		}
 
-			if err != nil {
-				partResp.ErrorCode = protocol.ErrUnknownTopicOrPartition
-			} else {
-				partResp.ErrorCode = protocol.ErrNone
-				partResp.Timestamp = p.Timestamp
-				partResp.Offset = offset
+		err := s.engine.CreateTopicWithConfig(ctx, t.Name, t.Configs)
+		result.ErrorCode = errorCode(err)
+		if err != nil {
+			msg := err.Error()
+			result.ErrorMessage = &msg
+		} else {
+			result.NumPartitions = 1
+			result.ReplicationFactor = 1
+			configs, _ := s.engine.TopicConfig(t.Name)
+			for name, value := range configs {
+				value := value
+				result.Configs = append(result.Configs, kafkaproto.CreateTopicsResponseConfig{
+					Name: name, Value: &value, ConfigSource: kafkaproto.ConfigSourceTopic,
+				})
 			}
-
-			topicResp.Partitions = append(topicResp.Partitions, partResp)
 		}
 
-		resp.Topics = append(resp.Topics, topicResp)
+		resp.Topics = append(resp.Topics, result)
 	}
 
-	enc := protocol.NewEncoder()
-	enc.WriteResponseHeader(header.CorrelationID)
-	protocol.EncodeListOffsetsResponse(enc, header.APIVersion, resp)
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeCreateTopicsResponse(enc, header.APIVersion, resp)
 
 	return s.wrapResponse(enc.Bytes()), nil
 }
 
-func (s *KafkaServer) handleFindCoordinator(header protocol.RequestHeader, dec *protocol.Decoder) ([]byte, error) {
-	req, err := protocol.DecodeFindCoordinatorRequest(dec, header.APIVersion)
+func (s *KafkaServer) handleCreatePartitions(header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	req, err := kafkaproto.DecodeCreatePartitionsRequest(dec, header.APIVersion)
 	if err != nil {
-		return nil, fmt.Errorf("decode find coordinator request: %w", err)
+		return nil, fmt.Errorf("decode create partitions request: %w", err)
 	}
 
-	_ = req // we don't really need the key for our single-node response
-
-	host, port := parseAddr(s.config.Server.KafkaAddr)
-
-	resp := &protocol.FindCoordinatorResponse{
+	resp := &kafkaproto.CreatePartitionsResponse{
 		ThrottleTimeMs: 0,
-		ErrorCode:    protocol.ErrNone,
-		NodeID:       0,
-		Host:         host,
-		Port:         port,
 	}
 
-	enc := protocol.NewEncoder()
-	if header.APIVersion >= 3 {
-		enc.WriteResponseHeaderV1(header.CorrelationID)
-	} else {
-		enc.WriteResponseHeader(header.CorrelationID)
-	}
-	protocol.EncodeFindCoordinatorResponse(enc, header.APIVersion, resp)
+	for _, t := range req.Topics {
+		result := kafkaproto.CreatePartitionsResult{
+			Name: t.Name,
+		}
+
+		if !s.authorized(state, kafkaproto.AclOperationAlter, kafkaproto.AclResourceTopic, t.Name) {
+			result.ErrorCode = kafkaproto.ErrTopicAuthorizationFailed
+			result.ErrorMessage = strPtr("not authorized to alter topic")
+			resp.Results = append(resp.Results, result)
+			continue
+		}
+
+		err := s.engine.AddPartitions(t.Name, t.Count)
+		result.ErrorCode = errorCode(err)
+		if err != nil {
+			msg := err.Error()
+			result.ErrorMessage = &msg
+		}
+
+		resp.Results = append(resp.Results, result)
+	}
+
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeCreatePartitionsResponse(enc, header.APIVersion, resp)
+
+	return s.wrapResponse(enc.Bytes()), nil
+}
+
+func (s *KafkaServer) handleDeleteRecords(ctx context.Context, header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	req, err := kafkaproto.DecodeDeleteRecordsRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode delete records request: %w", err)
+	}
+
+	resp := &kafkaproto.DeleteRecordsResponse{
+		ThrottleTimeMs: 0,
+	}
+
+	for _, t := range req.Topics {
+		topicResp := kafkaproto.DeleteRecordsResponseTopic{
+			Name: t.Name,
+		}
+		allowed := s.authorized(state, kafkaproto.AclOperationDelete, kafkaproto.AclResourceTopic, t.Name)
+
+		for _, p := range t.Partitions {
+			partResp := kafkaproto.DeleteRecordsResponsePartition{
+				Index:        p.Index,
+				LowWatermark: -1,
+			}
+			if !allowed {
+				partResp.ErrorCode = kafkaproto.ErrTopicAuthorizationFailed
+			} else if p.Index != 0 {
+				partResp.ErrorCode = kafkaproto.ErrUnknownTopicOrPartition
+			} else if code := s.notLeader(state, t.Name, p.Index); code != kafkaproto.ErrNone {
+				partResp.ErrorCode = code
+			} else {
+				lowWatermark, err := s.engine.DeleteRecords(ctx, t.Name, p.Offset)
+				partResp.ErrorCode = errorCode(err)
+				if err == nil {
+					partResp.LowWatermark = lowWatermark
+				}
+			}
+			topicResp.Partitions = append(topicResp.Partitions, partResp)
+		}
+
+		resp.Topics = append(resp.Topics, topicResp)
+	}
+
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeDeleteRecordsResponse(enc, header.APIVersion, resp)
+
+	return s.wrapResponse(enc.Bytes()), nil
+}
+
+func (s *KafkaServer) handleInitProducerID(ctx context.Context, header kafkaproto.RequestHeader, dec *kafkaproto.Decoder) ([]byte, error) {
+	req, err := kafkaproto.DecodeInitProducerIDRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode init producer id request: %w", err)
+	}
+
+	var transactionalID string
+	if req.TransactionalID != nil {
+		transactionalID = *req.TransactionalID
+	}
+
+	resp := &kafkaproto.InitProducerIDResponse{
+		ThrottleTimeMs: 0,
+		ProducerID:     -1,
+		ProducerEpoch:  -1,
+	}
+	id, epoch, err := s.engine.InitProducerID(ctx, transactionalID, req.TransactionTimeoutMs, req.ProducerID, req.ProducerEpoch)
+	if err != nil {
+		resp.ErrorCode = errorCode(err)
+		log.Printf("[kafka] init producer id failed: %v", err)
+	} else {
+		resp.ProducerID = id
+		resp.ProducerEpoch = epoch
+	}
+
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeInitProducerIDResponse(enc, header.APIVersion, resp)
+
+	return s.wrapResponse(enc.Bytes()), nil
+}
+
+func (s *KafkaServer) handleAddPartitionsToTxn(ctx context.Context, header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	req, err := kafkaproto.DecodeAddPartitionsToTxnRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode add partitions to txn request: %w", err)
+	}
+
+	// Topics have only partition 0, so a topic joins the transaction only
+	// when that is among the partitions asked for. Writing to a topic needs
+	// WRITE on it, and CREATE as well when adding it would create it; as in
+	// Kafka, one denied topic leaves the others unattempted.
+	var topics []string
+	denied := make(map[string]bool)
+	for _, t := range req.Topics {
+		if !s.authorized(state, kafkaproto.AclOperationWrite, kafkaproto.AclResourceTopic, t.Name) ||
+			!s.engine.TopicExists(t.Name) && !s.mayCreateTopic(state, t.Name) {
+			denied[t.Name] = true
+		} else if slices.Contains(t.Partitions, 0) {
+			topics = append(topics, t.Name)
+		}
+	}
+	if len(topics) > 0 && len(denied) == 0 {
+		err = s.engine.AddPartitionsToTxn(ctx, req.TransactionalID, req.ProducerID, req.ProducerEpoch, topics)
+		if err != nil {
+			log.Printf("[kafka] add partitions to txn %s failed: %v", req.TransactionalID, err)
+		}
+	}
+
+	resp := &kafkaproto.AddPartitionsToTxnResponse{
+		ThrottleTimeMs: 0,
+	}
+	for _, t := range req.Topics {
+		result := kafkaproto.AddPartitionsToTxnTopicResult{
+			Name: t.Name,
+		}
+		for _, p := range t.Partitions {
+			code := errorCode(err)
+			switch {
+			case denied[t.Name]:
+				code = kafkaproto.ErrTopicAuthorizationFailed
+			case len(denied) > 0:
+				code = kafkaproto.ErrOperationNotAttempted
+			case p != 0:
+				code = kafkaproto.ErrUnknownTopicOrPartition
+			}
+			result.Partitions = append(result.Partitions, kafkaproto.AddPartitionsToTxnPartitionResult{
+				Index:     p,
+				ErrorCode: code,
+			})
+		}
+		resp.Topics = append(resp.Topics, result)
+	}
+
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeAddPartitionsToTxnResponse(enc, header.APIVersion, resp)
+
+	return s.wrapResponse(enc.Bytes()), nil
+}
+
+func (s *KafkaServer) handleAddOffsetsToTxn(ctx context.Context, header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	req, err := kafkaproto.DecodeAddOffsetsToTxnRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode add offsets to txn request: %w", err)
+	}
+
+	// Offsets the transaction commits need READ on the group, as OffsetCommit does
+	resp := &kafkaproto.AddOffsetsToTxnResponse{
+		ThrottleTimeMs: 0,
+		ErrorCode:      kafkaproto.ErrGroupAuthorizationFailed,
+	}
+	if s.authorized(state, kafkaproto.AclOperationRead, kafkaproto.AclResourceGroup, req.GroupID) {
+		err = s.engine.AddOffsetsToTxn(ctx, req.TransactionalID, req.ProducerID, req.ProducerEpoch, req.GroupID)
+		if err != nil {
+			log.Printf("[kafka] add offsets to txn %s failed: %v", req.TransactionalID, err)
+		}
+		resp.ErrorCode = errorCode(err)
+	}
+
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeAddOffsetsToTxnResponse(enc, header.APIVersion, resp)
+
+	return s.wrapResponse(enc.Bytes()), nil
+}
+
+func (s *KafkaServer) handleEndTxn(ctx context.Context, header kafkaproto.RequestHeader, dec *kafkaproto.Decoder) ([]byte, error) {
+	req, err := kafkaproto.DecodeEndTxnRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode end txn request: %w", err)
+	}
+
+	err = s.engine.EndTxn(ctx, req.TransactionalID, req.ProducerID, req.ProducerEpoch, req.Committed)
+	if err != nil {
+		log.Printf("[kafka] end txn %s failed: %v", req.TransactionalID, err)
+	}
+	resp := &kafkaproto.EndTxnResponse{
+		ThrottleTimeMs: 0,
+		ErrorCode:      errorCode(err),
+	}
+
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeEndTxnResponse(enc, header.APIVersion, resp)
+
+	return s.wrapResponse(enc.Bytes()), nil
+}
+
+func (s *KafkaServer) handleTxnOffsetCommit(ctx context.Context, header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	req, err := kafkaproto.DecodeTxnOffsetCommitRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode txn offset commit request: %w", err)
+	}
+
+	// As with OffsetCommit, committing needs READ on the group and on
+	// each topic; denied topics are left out of the transaction
+	groupAllowed := s.authorized(state, kafkaproto.AclOperationRead, kafkaproto.AclResourceGroup, req.GroupID)
+	topicAllowed := make(map[string]bool)
+	var offsets []store.TxnOffset
+	for _, t := range req.Topics {
+		topicAllowed[t.Name] = groupAllowed && s.authorized(state, kafkaproto.AclOperationRead, kafkaproto.AclResourceTopic, t.Name)
+		if !topicAllowed[t.Name] {
+			continue
+		}
+		for _, p := range t.Partitions {
+			offsets = append(offsets, store.TxnOffset{
+				Group:     req.GroupID,
+				Topic:     t.Name,
+				Partition: p.Index,
+				Offset:    p.CommittedOffset,
+			})
+		}
+	}
+	if len(offsets) > 0 {
+		err = s.engine.TxnCommitOffsets(ctx, req.TransactionalID, req.ProducerID, req.ProducerEpoch, offsets)
+		if err != nil {
+			log.Printf("[kafka] txn offset commit for %s failed: %v", req.TransactionalID, err)
+		}
+	}
+
+	resp := &kafkaproto.TxnOffsetCommitResponse{
+		ThrottleTimeMs: 0,
+	}
+	for _, t := range req.Topics {
+		topicResp := kafkaproto.TxnOffsetCommitResponseTopic{
+			Name: t.Name,
+		}
+		for _, p := range t.Partitions {
+			code := errorCode(err)
+			if !groupAllowed {
+				code = kafkaproto.ErrGroupAuthorizationFailed
+			} else if !topicAllowed[t.Name] {
+				code = kafkaproto.ErrTopicAuthorizationFailed
+			}
+			topicResp.Partitions = append(topicResp.Partitions, kafkaproto.TxnOffsetCommitResponsePartition{
+				Index:     p.Index,
+				ErrorCode: code,
+			})
+		}
+		resp.Topics = append(resp.Topics, topicResp)
+	}
+
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeTxnOffsetCommitResponse(enc, header.APIVersion, resp)
+
+	return s.wrapResponse(enc.Bytes()), nil
+}
+
+func (s *KafkaServer) handleDescribeConfigs(header kafkaproto.RequestHeader, dec *kafkaproto.Decoder) ([]byte, error) {
+	req, err := kafkaproto.DecodeDescribeConfigsRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode describe configs request: %w", err)
+	}
+
+	resp := &kafkaproto.DescribeConfigsResponse{
+		ThrottleTimeMs: 0,
+	}
+
+	broker := s.engine.BrokerConfig()
+	for _, r := range req.Resources {
+		result := kafkaproto.DescribeConfigsResult{
+			ResourceType: r.ResourceType,
+			ResourceName: r.ResourceName,
+		}
+
+		var entries []engine.ConfigEntry
+		source := kafkaproto.ConfigSourceStaticBroker
+		switch r.ResourceType {
+		case kafkaproto.ResourceTypeTopic:
+			entries, err = s.engine.DescribeTopicConfig(r.ResourceName)
+			source = kafkaproto.ConfigSourceTopic
+		case kafkaproto.ResourceTypeBroker:
+			entries, err = broker, s.checkBrokerResource(r.ResourceName)
+		default:
+			err = fmt.Errorf("unsupported resource type %d", r.ResourceType)
+		}
+		if err != nil {
+			result.ErrorCode = errorCode(err)
+			if result.ErrorCode == kafkaproto.ErrUnknownServerError {
+				result.ErrorCode = kafkaproto.ErrInvalidRequest
+			}
+			msg := err.Error()
+			result.ErrorMessage = &msg
+			resp.Results = append(resp.Results, result)
+			continue
+		}
+
+		for _, c := range entries {
+			if r.ConfigNames != nil && !slices.Contains(r.ConfigNames, c.Name) {
+				continue
+			}
+			value := c.Value
+			entry := kafkaproto.DescribeConfigsEntry{
+				Name:         c.Name,
+				Value:        &value,
+				ReadOnly:     r.ResourceType == kafkaproto.ResourceTypeBroker,
+				ConfigSource: source,
+				ConfigType:   configType(c.Name),
+			}
+			if c.Default {
+				entry.ConfigSource = kafkaproto.ConfigSourceDefault
+			}
+			if req.IncludeSynonyms {
+				if !c.Default {
+					entry.Synonyms = append(entry.Synonyms, kafkaproto.DescribeConfigsSynonym{
+						Name: c.Name, Value: &value, Source: source,
+					})
+				}
+				for _, b := range broker {
+					if c.Synonym == b.Name {
+						brokerValue := b.Value
+						entry.Synonyms = append(entry.Synonyms, kafkaproto.DescribeConfigsSynonym{
+							Name: b.Name, Value: &brokerValue, Source: kafkaproto.ConfigSourceStaticBroker,
+						})
+					}
+				}
+			}
+			result.Configs = append(result.Configs, entry)
+		}
+
+		resp.Results = append(resp.Results, result)
+	}
+
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeDescribeConfigsResponse(enc, header.APIVersion, resp)
+
+	return s.wrapResponse(enc.Bytes()), nil
+}
+
+func (s *KafkaServer) handleAlterConfigs(ctx context.Context, header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	req, err := kafkaproto.DecodeAlterConfigsRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode alter configs request: %w", err)
+	}
+
+	resp := &kafkaproto.AlterConfigsResponse{
+		ThrottleTimeMs: 0,
+	}
+
+	for _, r := range req.Resources {
+		result := kafkaproto.AlterConfigsResult{
+			ResourceType: r.ResourceType,
+			ResourceName: r.ResourceName,
+		}
+
+		if code, msg := s.alterConfigsDenied(state, r.ResourceType, r.ResourceName); code != kafkaproto.ErrNone {
+			result.ErrorCode, result.ErrorMessage = code, msg
+			resp.Responses = append(resp.Responses, result)
+			continue
+		}
+
+		switch r.ResourceType {
+		case kafkaproto.ResourceTypeTopic:
+			configs := make(map[string]string, len(r.Configs))
+			for name, value := range r.Configs {
+				if value != nil {
+					configs[name] = *value
+				}
+			}
+			err = s.engine.SetTopicConfig(ctx, r.ResourceName, configs, req.ValidateOnly)
+			result.ErrorCode = errorCode(err)
+		case kafkaproto.ResourceTypeBroker:
+			err = fmt.Errorf("broker configs are read-only; set them in the config file")
+			result.ErrorCode = kafkaproto.ErrInvalidRequest
+		default:
+			err = fmt.Errorf("unsupported resource type %d", r.ResourceType)
+			result.ErrorCode = kafkaproto.ErrInvalidRequest
+		}
+		if err != nil {
+			msg := err.Error()
+			result.ErrorMessage = &msg
+		}
+
+		resp.Responses = append(resp.Responses, result)
+	}
+
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeAlterConfigsResponse(enc, header.APIVersion, resp)
+
+	return s.wrapResponse(enc.Bytes()), nil
+}
+
+func (s *KafkaServer) handleIncrementalAlterConfigs(ctx context.Context, header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	req, err := kafkaproto.DecodeIncrementalAlterConfigsRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode incremental alter configs request: %w", err)
+	}
+
+	resp := &kafkaproto.IncrementalAlterConfigsResponse{
+		ThrottleTimeMs: 0,
+	}
+
+	for _, r := range req.Resources {
+		result := kafkaproto.IncrementalAlterConfigsResult{
+			ResourceType: r.ResourceType,
+			ResourceName: r.ResourceName,
+		}
+
+		if code, msg := s.alterConfigsDenied(state, r.ResourceType, r.ResourceName); code != kafkaproto.ErrNone {
+			result.ErrorCode, result.ErrorMessage = code, msg
+			resp.Responses = append(resp.Responses, result)
+			continue
+		}
+
+		switch r.ResourceType {
+		case kafkaproto.ResourceTypeTopic:
+			set, unset, opErr := configOperations(r.Configs)
+			if opErr != nil {
+				err = opErr
+				result.ErrorCode = kafkaproto.ErrInvalidRequest
+				break
+			}
+			err = s.engine.UpdateTopicConfig(ctx, r.ResourceName, set, unset, req.ValidateOnly)
+			result.ErrorCode = errorCode(err)
+		case kafkaproto.ResourceTypeBroker:
+			err = fmt.Errorf("broker configs are read-only; set them in the config file")
+			result.ErrorCode = kafkaproto.ErrInvalidRequest
+		default:
+			err = fmt.Errorf("unsupported resource type %d", r.ResourceType)
+			result.ErrorCode = kafkaproto.ErrInvalidRequest
+		}
+		if err != nil {
+			msg := err.Error()
+			result.ErrorMessage = &msg
+		}
+
+		resp.Responses = append(resp.Responses, result)
+	}
+
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeIncrementalAlterConfigsResponse(enc, header.APIVersion, resp)
+
+	return s.wrapResponse(enc.Bytes()), nil
+}
+
+// alterConfigsDenied returns the error a config change to a resource fails
+// with when the ACLs do not allow ALTER_CONFIGS on it, ErrNone if they do
+func (s *KafkaServer) alterConfigsDenied(state *connState, resourceType int8, name string) (int16, *string) {
+	switch resourceType {
+	case kafkaproto.ResourceTypeTopic:
+		if !s.authorized(state, kafkaproto.AclOperationAlterConfigs, kafkaproto.AclResourceTopic, name) {
+			return kafkaproto.ErrTopicAuthorizationFailed, strPtr("not authorized to alter topic configs")
+		}
+	case kafkaproto.ResourceTypeBroker:
+		if !s.authorized(state, kafkaproto.AclOperationAlterConfigs, kafkaproto.AclResourceCluster, engine.ClusterResource) {
+			return kafkaproto.ErrClusterAuthorizationFailed, strPtr("not authorized to alter broker configs")
+		}
+	}
+	return kafkaproto.ErrNone, nil
+}
+
+// configOperations splits incremental config changes into the configs to
+// set and those to delete. APPEND and SUBTRACT are not supported.
+func configOperations(configs []kafkaproto.IncrementalAlterConfig) (map[string]string, []string, error) {
+	set := make(map[string]string)
+	var unset []string
+	for _, c := range configs {
+		switch c.Operation {
+		case kafkaproto.ConfigOpSet:
+			if c.Value == nil {
+				return nil, nil, fmt.Errorf("%s: SET needs a value", c.Name)
+			}
+			set[c.Name] = *c.Value
+		case kafkaproto.ConfigOpDelete:
+			unset = append(unset, c.Name)
+		default:
+			return nil, nil, fmt.Errorf("%s: config operation %d is not supported; use SET or DELETE", c.Name, c.Operation)
+		}
+	}
+	return set, unset, nil
+}
+
+// configType returns the type DescribeConfigs v3+ reports for a config
+func configType(name string) int8 {
+	switch name {
+	case engine.ConfigRetentionMs, engine.BrokerLogRetentionMs:
+		return kafkaproto.ConfigTypeLong
+	case engine.ConfigMaxMessageBytes, engine.BrokerMessageMaxBytes, engine.BrokerNumPartitions:
+		return kafkaproto.ConfigTypeInt
+	case engine.BrokerDefaultReplicationFactor:
+		return kafkaproto.ConfigTypeShort
+	case engine.ConfigCleanupPolicy, engine.BrokerLogCleanupPolicy:
+		return kafkaproto.ConfigTypeList
+	case engine.ConfigZstdDictionary, engine.BrokerAutoCreateTopics:
+		return kafkaproto.ConfigTypeBoolean
+	default:
+		return kafkaproto.ConfigTypeString
+	}
+}
+
+func (s *KafkaServer) handleProduce(ctx context.Context, header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	req, err := kafkaproto.DecodeProduceRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode produce request: %w", err)
+	}
+
+	resp := &kafkaproto.ProduceResponse{
+		ThrottleTimeMs: 0,
+	}
+	failed := false
+
+	for _, t := range req.Topics {
+		topicResp := kafkaproto.ProduceResponseTopic{
+			Name: t.Name,
+		}
+
+		for _, p := range t.Partitions {
+			partResp := kafkaproto.ProduceResponsePartition{
+				Index:           p.Index,
+				LogAppendTimeMs: -1,
+				LogStartOffset:  0,
+			}
+			// Producing to a missing topic creates it, which needs CREATE too
+			if !s.authorized(state, kafkaproto.AclOperationWrite, kafkaproto.AclResourceTopic, t.Name) ||
+				!s.engine.TopicExists(t.Name) && !s.mayCreateTopic(state, t.Name) {
+				partResp.ErrorCode = kafkaproto.ErrTopicAuthorizationFailed
+				topicResp.Partitions = append(topicResp.Partitions, partResp)
+				continue
+			}
+			if code := s.notLeader(state, t.Name, p.Index); code != kafkaproto.ErrNone {
+				partResp.ErrorCode = code
+				topicResp.Partitions = append(topicResp.Partitions, partResp)
+				continue
+			}
+
+			// Extract codec from record batch attributes (bytes 21-22)
+			var codec int8 = 0
+			if len(p.Records) >= 23 {
+				attrs := int16(p.Records[21])<<8 | int16(p.Records[22])
+				codec = int8(attrs & 0x07)
+			}
+
+			if s.config.Logging.Level == "debug" {
+				log.Printf("[kafka] produce: topic=%s partition=%d codec=%s records=%s",
+					t.Name, p.Index, kafkaproto.CodecName(codec), redact.Bytes(p.Records))
+			}
+
+			// Store raw (passthrough)
+			var baseOffset, appendTime int64
+			err := s.engine.CheckBatches(p.Records)
+			if err == nil {
+				baseOffset, appendTime, err = s.engine.ProduceBatches(ctx, t.Name, p.Records, codec)
+			}
+			if err != nil {
+				partResp.ErrorCode = errorCode(err)
+				partResp.ErrorMessage = err.Error()
+				log.Printf("[kafka] produce to %s failed: %v", t.Name, err)
+			} else {
+				partResp.ErrorCode = kafkaproto.ErrNone
+				partResp.BaseOffset = baseOffset
+				partResp.LogAppendTimeMs = appendTime
+			}
+
+			if partResp.ErrorCode != kafkaproto.ErrNone {
+				failed = true
+			}
+			topicResp.Partitions = append(topicResp.Partitions, partResp)
+		}
+
+		resp.Topics = append(resp.Topics, topicResp)
+	}
+
+	// An acks=0 producer reads no response, so none is sent. As in Kafka a
+	// failed append closes the connection instead, the only signal such a
+	// producer gets to refresh its metadata.
+	if req.Acks == 0 {
+		if failed {
+			log.Printf("[kafka] closing connection after a failed acks=0 produce")
+			state.closing = true
+		}
+		return nil, nil
+	}
+
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeProduceResponse(enc, header.APIVersion, resp)
+
+	return s.wrapResponse(enc.Bytes()), nil
+}
+
+// errorCode maps an engine or store error to the Kafka error code clients
+// base their retry decisions on
+func errorCode(err error) int16 {
+	switch {
+	case err == nil:
+		return kafkaproto.ErrNone
+	case errors.Is(err, store.ErrTopicNotFound):
+		return kafkaproto.ErrUnknownTopicOrPartition
+	case errors.Is(err, store.ErrTopicExists):
+		return kafkaproto.ErrTopicAlreadyExists
+	case errors.Is(err, engine.ErrMessageTooLarge):
+		return kafkaproto.ErrMessageTooLarge
+	case errors.Is(err, engine.ErrPolicyViolation), errors.Is(err, engine.ErrReplicaTopic):
+		return kafkaproto.ErrPolicyViolation
+	case errors.Is(err, engine.ErrInvalidConfig):
+		return kafkaproto.ErrInvalidConfig
+	case errors.Is(err, engine.ErrInvalidOffset):
+		return kafkaproto.ErrOffsetOutOfRange
+	case errors.Is(err, engine.ErrInvalidPartitions):
+		return kafkaproto.ErrInvalidPartitions
+	case errors.Is(err, engine.ErrInvalidTopic):
+		return kafkaproto.ErrInvalidTopicException
+	case errors.Is(err, engine.ErrOutOfOrderSequence):
+		return kafkaproto.ErrOutOfOrderSequenceNumber
+	case errors.Is(err, engine.ErrInvalidProducerEpoch):
+		return kafkaproto.ErrInvalidProducerEpoch
+	case errors.Is(err, engine.ErrInvalidTxnState):
+		return kafkaproto.ErrInvalidTxnState
+	case errors.Is(err, engine.ErrUnknownProducerID):
+		return kafkaproto.ErrInvalidProducerIDMapping
+	case errors.Is(err, engine.ErrUnsupportedCompression):
+		return kafkaproto.ErrUnsupportedCompressionType
+	case errors.Is(err, engine.ErrCorruptBatch):
+		return kafkaproto.ErrCorruptMessage
+	case errors.Is(err, engine.ErrInvalidACL):
+		return kafkaproto.ErrInvalidRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		return kafkaproto.ErrRequestTimedOut
+	case errors.Is(err, engine.ErrReadOnly), sqlitestore.IsStorageFull(err), store.IsStorageError(err):
+		return kafkaproto.ErrKafkaStorageError
+	default:
+		return kafkaproto.ErrUnknownServerError
+	}
+}
+
+func (s *KafkaServer) handleFetch(ctx context.Context, conn net.Conn, header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	req, err := kafkaproto.DecodeFetchRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode fetch request: %w", err)
+	}
+	if maxWait := s.config.Limits.MaxFetchWait; maxWait > 0 {
+		req.MaxWaitMs = min(req.MaxWaitMs, int32(maxWait/time.Millisecond))
+	}
+
+	resp := &kafkaproto.FetchResponse{
+		ThrottleTimeMs: 0,
+		ErrorCode:    kafkaproto.ErrNone,
+		SessionID:    0,
+	}
+
+	// Partitions are read concurrently but answered in request order
+	var jobs []fetchJob
+	for _, t := range req.Topics {
+		topicResp := kafkaproto.FetchResponseTopic{
+			Name:       t.Name,
+			TopicID:    t.TopicID,
+			Partitions: make([]kafkaproto.FetchResponsePartition, len(t.Partitions)),
+		}
+		resp.Topics = append(resp.Topics, topicResp)
+
+		name := t.Name
+		if header.APIVersion >= 13 {
+			// Topics are named by ID from v13
+			if name = s.engine.TopicByID(t.TopicID.String()); name == "" {
+				for i, p := range t.Partitions {
+					topicResp.Partitions[i] = kafkaproto.FetchResponsePartition{
+						Index:                p.Index,
+						ErrorCode:            kafkaproto.ErrUnknownTopicID,
+						HighWatermark:        -1,
+						LastStableOffset:     -1,
+						LogStartOffset:       -1,
+						PreferredReadReplica: -1,
+					}
+				}
+				continue
+			}
+		}
+		for i, p := range t.Partitions {
+			jobs = append(jobs, fetchJob{
+				topic:     name,
+				partition: p,
+				out:       &topicResp.Partitions[i],
+				batches:   new([]int),
+			})
+		}
+	}
+
+	read := func(j fetchJob) {
+		*j.out, *j.batches = s.fetchPartition(ctx, state, j.topic, j.partition, req.IsolationLevel)
+	}
+	s.runFetchJobs(jobs, read)
+	limitFetchResponse(jobs, s.fetchMaxBytes(req))
+
+	// Too little data: wait up to MaxWaitMs for MinBytes to arrive, then
+	// read again. The connection handles one request at a time, so
+	// requests behind this one wait too and responses stay in order.
+	if s.parkFetch(ctx, state, header, req, jobs) {
+		s.runFetchJobs(jobs, read)
+		limitFetchResponse(jobs, s.fetchMaxBytes(req))
+	}
+
+	if throttle := s.fetchThrottle(state, resp); throttle > 0 {
+		resp.ThrottleTimeMs = int32(throttle / time.Millisecond)
+		// Clients before KIP-219 (Fetch v8) do not back off on their own
+		if header.APIVersion < 8 {
+			timer := time.NewTimer(throttle)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+			}
+		}
+	}
+
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeFetchResponse(enc, header.APIVersion, resp)
 
 	return s.wrapResponse(enc.Bytes()), nil
 }
 
-func (s *KafkaServer) handleJoinGroup(header protocol.RequestHeader, dec *protocol.Decoder) ([]byte, error) {
-	// Read JoinGroup request fields
-	groupID, _ := dec.ReadString()
-	dec.ReadInt32() // session_timeout
-	if header.APIVersion >= 1 {
-		dec.ReadInt32() // rebalance_timeout
+// parkFetch parks a Fetch whose first read found fewer than MinBytes until
+// enough arrives or MaxWaitMs passes, and reports whether it should be
+// read again. A fetch with a partition error is answered at once.
+func (s *KafkaServer) parkFetch(ctx context.Context, state *connState, header kafkaproto.RequestHeader, req *kafkaproto.FetchRequest, jobs []fetchJob) bool {
+	if req.MaxWaitMs <= 0 || req.MinBytes <= 0 || len(jobs) == 0 {
+		return false
 	}
-	memberID, _ := dec.ReadString()
-	if header.APIVersion >= 5 {
-		dec.ReadNullableString() // group_instance_id
+	var size int32
+	parts := make([]engine.PendingPartition, 0, len(jobs))
+	for _, j := range jobs {
+		if j.out.ErrorCode != kafkaproto.ErrNone {
+			return false
+		}
+		size += int32(len(j.out.Records))
+		parts = append(parts, engine.PendingPartition{Topic: j.topic, Partition: j.partition.Index, Offset: j.partition.FetchOffset})
+	}
+	if size >= req.MinBytes {
+		return false
+	}
+
+	pending := &engine.PendingFetch{
+		ConnID:        state.connID,
+		CorrelationID: header.CorrelationID,
+		Partitions:    parts,
+		MinBytes:      req.MinBytes,
+		Isolation:     req.IsolationLevel,
+		Deadline:      time.Now().Add(time.Duration(req.MaxWaitMs) * time.Millisecond),
+		ResponseChan:  make(chan engine.FetchResult, 1),
+	}
+	s.engine.ParkFetch(pending)
+	select {
+	case result, ok := <-pending.ResponseChan:
+		return ok && !result.TimedOut
+	case <-ctx.Done():
+	case <-s.stopChan:
 	}
-	protocolType, _ := dec.ReadString()
-	protocolCount, _ := dec.ReadInt32()
+	s.engine.GetPendingQueue().Remove(pending)
+	return false
+}
 
-	var protocols []string
-	var firstMetadata []byte
-	for i := int32(0); i < protocolCount; i++ {
-		name, _ := dec.ReadString()
-		metadata, _ := dec.ReadBytes()
-		protocols = append(protocols, name)
-		if i == 0 {
-			firstMetadata = metadata
+// fetchThrottle returns how long a connection must back off when resp is
+// empty and came sooner than limits.min_fetch_interval after its last
+// empty one, counting the time as throttled
+func (s *KafkaServer) fetchThrottle(state *connState, resp *kafkaproto.FetchResponse) time.Duration {
+	interval := s.config.Limits.MinFetchInterval
+	if interval <= 0 {
+		return 0
+	}
+	for _, t := range resp.Topics {
+		for _, p := range t.Partitions {
+			if len(p.Records) > 0 {
+				state.nextEmptyFetch = time.Time{}
+				return 0
+			}
 		}
 	}
 
-	log.Printf("[kafka] join group: group=%s member=%s", groupID, memberID)
+	now := time.Now()
+	throttle := state.nextEmptyFetch.Sub(now).Round(time.Millisecond)
+	if throttle <= 0 {
+		state.nextEmptyFetch = now.Add(interval)
+		return 0
+	}
+	state.nextEmptyFetch = now.Add(throttle + interval)
+	if state.stats != nil {
+		state.stats.throttledMs.Add(throttle.Milliseconds())
+	}
+	return throttle
+}
+
+// fetchJob is one partition read of a Fetch request and where its answer goes
+type fetchJob struct {
+	topic     string
+	partition kafkaproto.FetchRequestPartition
+	out       *kafkaproto.FetchResponsePartition
+	batches   *[]int // sizes of the record batches in out, in order
+}
+
+// fetchMaxBytes is the most record data a Fetch response may carry: the
+// request's MaxBytes (v3+), capped by limits.max_fetch_bytes. 0 is no limit.
+func (s *KafkaServer) fetchMaxBytes(req *kafkaproto.FetchRequest) int {
+	limit := int(req.MaxBytes)
+	if max := s.config.Limits.MaxFetchBytes; max > 0 && (limit <= 0 || limit > max) {
+		limit = max
+	}
+	return max(limit, 0)
+}
 
-	// Generate member ID if empty
-	if memberID == "" {
-		memberID = fmt.Sprintf("%s-%d", groupID, time.Now().UnixNano())
+// limitFetchResponse drops the batches that take a response past maxBytes,
+// going through partitions in request order. As in Kafka the response's
+// first batch is kept however large it is, so a message bigger than the
+// limit still reaches the consumer.
+func limitFetchResponse(jobs []fetchJob, maxBytes int) {
+	if maxBytes <= 0 {
+		return
+	}
+	total := 0
+	for _, j := range jobs {
+		size, kept := 0, 0
+		for _, n := range *j.batches {
+			if total+size+n > maxBytes && total+size > 0 {
+				break
+			}
+			size += n
+			kept++
+		}
+		if kept < len(*j.batches) {
+			*j.batches = (*j.batches)[:kept]
+			if kept == 0 {
+				j.out.Records = nil
+			} else {
+				j.out.Records = j.out.Records[:size]
+			}
+		}
+		total += size
 	}
+}
 
-	enc := protocol.NewEncoder()
-	enc.WriteResponseHeader(header.CorrelationID)
+// runFetchJobs runs fn for every job, at most limits.fetch_concurrency at
+// a time, and returns once all are done
+func (s *KafkaServer) runFetchJobs(jobs []fetchJob, fn func(fetchJob)) {
+	workers := min(s.config.Limits.FetchConcurrency, len(jobs))
+	if workers <= 1 {
+		for _, j := range jobs {
+			fn(j)
+		}
+		return
+	}
 
-	// Throttle time (v2+)
-	if header.APIVersion >= 2 {
-		enc.WriteInt32(0)
+	queue := make(chan fetchJob)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				fn(j)
+			}
+		}()
 	}
+	for _, j := range jobs {
+		queue <- j
+	}
+	close(queue)
+	wg.Wait()
+}
 
-	enc.WriteInt16(protocol.ErrNone)      // error_code
-	enc.WriteInt32(1)                     // generation_id
+// fetchPartition reads one partition of a Fetch request and returns it
+// with the sizes of the record batches it holds. Batches follow one another
+// up to the partition's MaxBytes; the first is returned whatever its size.
+func (s *KafkaServer) fetchPartition(ctx context.Context, state *connState, topic string, p kafkaproto.FetchRequestPartition, isolation int8) (kafkaproto.FetchResponsePartition, []int) {
+	partResp := kafkaproto.FetchResponsePartition{
+		Index:                p.Index,
+		PreferredReadReplica: -1,
+	}
 
-	// protocol_type (v7+ only, nullable string)
-	if header.APIVersion >= 7 {
-		enc.WriteNullableString(&protocolType)
+	if !s.authorized(state, kafkaproto.AclOperationRead, kafkaproto.AclResourceTopic, topic) {
+		partResp.ErrorCode = kafkaproto.ErrTopicAuthorizationFailed
+		return partResp, nil
+	}
+	if !s.engine.TopicExists(topic) {
+		partResp.ErrorCode = kafkaproto.ErrUnknownTopicOrPartition
+		return partResp, nil
+	}
+	if code := s.notLeader(state, topic, p.Index); code != kafkaproto.ErrNone {
+		partResp.ErrorCode = code
+		return partResp, nil
 	}
 
-	// protocol_name
-	if len(protocols) > 0 {
-		enc.WriteString(protocols[0])
-	} else {
-		enc.WriteString("")
+	records, err := s.engine.FetchIsolated(ctx, topic, p.FetchOffset, 100, isolation)
+	latest, latestErr := s.engine.LatestOffset(topic)
+	earliest, earliestErr := s.engine.EarliestOffset(ctx, topic)
+	lso, lsoErr := s.engine.LastStableOffset(topic)
+	// A timed-out or failed read must not pass for an empty partition, or
+	// the client would take it as caught up instead of retrying
+	if err = cmp.Or(err, latestErr, earliestErr, lsoErr); err != nil {
+		partResp.ErrorCode = errorCode(err)
+		return partResp, nil
 	}
 
-	enc.WriteString(memberID)             // leader (this member is leader)
-	enc.WriteString(memberID)             // member_id
+	partResp.ErrorCode = kafkaproto.ErrNone
+	partResp.HighWatermark = latest + 1
+	partResp.LastStableOffset = lso
+	partResp.LogStartOffset = earliest
 
-	// Members array (only leader gets this)
-	enc.WriteArrayLen(1)
-	enc.WriteString(memberID)             // member_id
-	if header.APIVersion >= 5 {
-		enc.WriteNullableString(nil)      // group_instance_id
+	if isolation == engine.ReadCommitted {
+		aborted, err := s.engine.AbortedTransactions(ctx, topic, p.FetchOffset, lso)
+		if err != nil {
+			// Without the aborted list the consumer would read aborted records
+			partResp.ErrorCode = errorCode(err)
+			return partResp, nil
+		}
+		for _, a := range aborted {
+			partResp.AbortedTransactions = append(partResp.AbortedTransactions, kafkaproto.FetchAbortedTransaction{
+				ProducerID:  a.ProducerID,
+				FirstOffset: a.FirstOffset,
+			})
+		}
 	}
-	enc.WriteBytes(firstMetadata)         // metadata (contains subscribed topics)
 
-	return s.wrapResponse(enc.Bytes()), nil
+	var batches []int
+	var history []store.LeaderEpoch
+	for i, rec := range records {
+		// Only stored Kafka batches can follow one another; anything else
+		// is sent on its own as it always was
+		isBatch := len(rec.Value) > kafkaproto.RecordBatchHeaderSize && rec.Value[16] == 2
+		if i > 0 && !isBatch {
+			break
+		}
+		clientData := s.engine.ClientBatch(rec.Value)
+		if i > 0 && p.MaxBytes > 0 && len(partResp.Records)+len(clientData) > int(p.MaxBytes) {
+			break
+		}
+		// Return the raw batch data with its baseOffset patched to our
+		// assigned offset and partitionLeaderEpoch to the epoch it was
+		// written under
+		batchData := make([]byte, len(clientData))
+		copy(batchData, clientData)
+		if history == nil {
+			history, _ = s.engine.LeaderEpochs(topic)
+		}
+		kafkaproto.RebaseBatch(batchData, rec.Offset, engine.EpochAt(history, rec.Offset))
+		partResp.Records = append(partResp.Records, batchData...)
+		batches = append(batches, len(batchData))
+		if !isBatch {
+			break
+		}
+	}
+
+	return partResp, batches
 }
 
-func (s *KafkaServer) handleSyncGroup(header protocol.RequestHeader, dec *protocol.Decoder) ([]byte, error) {
-	dec.ReadString() // group_id
-	dec.ReadInt32()  // generation_id
-	memberID, _ := dec.ReadString()
-	if header.APIVersion >= 3 {
-		dec.ReadNullableString() // group_instance_id
+func (s *KafkaServer) handleListOffsets(ctx context.Context, header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	req, err := kafkaproto.DecodeListOffsetsRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode list offsets request: %w", err)
+	}
+
+	resp := &kafkaproto.ListOffsetsResponse{
+		ThrottleTimeMs: 0,
 	}
 
-	// Read assignments sent by leader
-	var memberAssignment []byte
-	assignmentCount, _ := dec.ReadInt32()
-	for i := int32(0); i < assignmentCount; i++ {
-		assignedMember, _ := dec.ReadString()
-		assignment, _ := dec.ReadBytes()
-		if assignedMember == memberID {
-			memberAssignment = assignment
+	for _, t := range req.Topics {
+		topicResp := kafkaproto.ListOffsetsResponseTopic{
+			Name: t.Name,
+		}
+
+		for _, p := range t.Partitions {
+			partResp := kafkaproto.ListOffsetsResponsePartition{
+				PartitionIndex: p.PartitionIndex,
+				LeaderEpoch:    -1,
+			}
+
+			var offset int64
+			var err error
+
+			if p.Timestamp == kafkaproto.OffsetLatest && req.IsolationLevel == engine.ReadCommitted {
+				// read_committed consumers start no later than the oldest
+				// open transaction, or they would miss it once it commits
+				offset, err = s.engine.LastStableOffset(t.Name)
+			} else if p.Timestamp == kafkaproto.OffsetLatest {
+				offset, err = s.engine.LatestOffset(t.Name)
+				if err == nil {
+					offset++ // next offset
+				}
+			} else if p.Timestamp == kafkaproto.OffsetEarliest {
+				offset, err = s.engine.EarliestOffset(ctx, t.Name)
+			}
+
+			if !s.authorized(state, kafkaproto.AclOperationDescribe, kafkaproto.AclResourceTopic, t.Name) {
+				partResp.ErrorCode = kafkaproto.ErrTopicAuthorizationFailed
+			} else if code := s.notLeader(state, t.Name, p.PartitionIndex); code != kafkaproto.ErrNone {
+				partResp.ErrorCode = code
+			} else if err != nil {
+				partResp.ErrorCode = kafkaproto.ErrUnknownTopicOrPartition
+			} else {
+				partResp.ErrorCode = kafkaproto.ErrNone
+				partResp.Timestamp = p.Timestamp
+				partResp.Offset = offset
+				partResp.LeaderEpoch, _ = s.engine.LeaderEpoch(t.Name)
+			}
+
+			topicResp.Partitions = append(topicResp.Partitions, partResp)
 		}
+
+		resp.Topics = append(resp.Topics, topicResp)
 	}
 
-	enc := protocol.NewEncoder()
-	enc.WriteResponseHeader(header.CorrelationID)
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeListOffsetsResponse(enc, header.APIVersion, resp)
 
-	// Throttle time (v1+)
+	return s.wrapResponse(enc.Bytes()), nil
+}
+
+func (s *KafkaServer) handleFindCoordinator(header kafkaproto.RequestHeader, dec *kafkaproto.Decoder) ([]byte, error) {
+	req, err := kafkaproto.DecodeFindCoordinatorRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode find coordinator request: %w", err)
+	}
+
+	coordinator := s.broker(s.coordinatorFor(req.Key))
+
+	resp := &kafkaproto.FindCoordinatorResponse{
+		ThrottleTimeMs: 0,
+		ErrorCode:    kafkaproto.ErrNone,
+		NodeID:       coordinator.NodeID,
+		Host:         coordinator.Host,
+		Port:         coordinator.Port,
+	}
+
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeFindCoordinatorResponse(enc, header.APIVersion, resp)
+
+	return s.wrapResponse(enc.Bytes()), nil
+}
+
+func (s *KafkaServer) handleJoinGroup(ctx context.Context, header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	req, err := kafkaproto.DecodeJoinGroupRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode join group request: %w", err)
+	}
+
+	log.Printf("[kafka] join group: group=%s member=%s", req.GroupID, req.MemberID)
+	quirks := s.quirksFor(state, header.ClientID)
+
+	joinReq := engine.JoinRequest{
+		GroupID:              req.GroupID,
+		MemberID:             req.MemberID,
+		ClientID:             header.ClientID,
+		ProtocolType:         req.ProtocolType,
+		SessionTimeout:       time.Duration(req.SessionTimeoutMs) * time.Millisecond,
+		RequireKnownMemberID: quirks.requireMemberID(header.APIVersion),
+	}
 	if header.APIVersion >= 1 {
-		enc.WriteInt32(0)
+		joinReq.RebalanceTimeout = time.Duration(req.RebalanceTimeout) * time.Millisecond
+	}
+	for _, p := range req.Protocols {
+		joinReq.Protocols = append(joinReq.Protocols, engine.GroupProtocol{Name: p.Name, Metadata: p.Metadata})
+	}
+
+	// Blocks until every member has rejoined or the rebalance timeout expires
+	var result *engine.JoinResult
+	if s.authorized(state, kafkaproto.AclOperationRead, kafkaproto.AclResourceGroup, req.GroupID) {
+		result, err = s.engine.JoinGroup(ctx, joinReq)
+	} else {
+		err = errGroupAuthorizationFailed
+	}
+
+	resp := &kafkaproto.JoinGroupResponse{
+		ErrorCode:    groupErrorCode(err),
+		GenerationID: -1,
+		ProtocolType: req.ProtocolType,
+		MemberID:     req.MemberID,
+	}
+	if result != nil {
+		resp.MemberID = result.MemberID
+		if err == nil {
+			resp.GenerationID = result.Generation
+			resp.ProtocolName = result.Protocol
+			resp.LeaderID = result.LeaderID
+			for _, m := range result.Members {
+				metadata := m.Metadata
+				if req.ProtocolType == "consumer" {
+					metadata = quirks.groupData(metadata)
+				}
+				resp.Members = append(resp.Members, kafkaproto.JoinGroupResponseMember{
+					MemberID: m.ID,
+					Metadata: metadata,
+				})
+			}
+		}
+	}
+	if err != nil && !errors.Is(err, engine.ErrMemberIDRequired) {
+		log.Printf("[kafka] join group rejected: group=%s member=%s: %v", req.GroupID, resp.MemberID, err)
 	}
 
-	enc.WriteInt16(protocol.ErrNone) // error_code
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeJoinGroupResponse(enc, header.APIVersion, resp)
+
+	return s.wrapResponse(enc.Bytes()), nil
+}
+
+func (s *KafkaServer) handleSyncGroup(ctx context.Context, header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	req, err := kafkaproto.DecodeSyncGroupRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode sync group request: %w", err)
+	}
 
-	// Return the assignment for this member
-	if len(memberAssignment) > 0 {
-		enc.WriteBytes(memberAssignment)
+	// Only the leader sends assignments; followers wait for them
+	assignments := make(map[string][]byte, len(req.Assignments))
+	for _, a := range req.Assignments {
+		assignments[a.MemberID] = a.Assignment
+	}
+	var assignment []byte
+	if s.authorized(state, kafkaproto.AclOperationRead, kafkaproto.AclResourceGroup, req.GroupID) {
+		assignment, err = s.engine.SyncGroup(ctx, req.GroupID, req.MemberID, req.GenerationID, assignments)
 	} else {
-		// No assignment found - return empty
-		enc.WriteBytes([]byte{})
+		err = errGroupAuthorizationFailed
+	}
+
+	resp := &kafkaproto.SyncGroupResponse{
+		ErrorCode:    groupErrorCode(err),
+		ProtocolType: req.ProtocolType,
+		ProtocolName: req.ProtocolName,
+		Assignment:   assignment,
+	}
+	// Protocol type is only sent from v5; clients with quirks run the
+	// consumer protocol
+	if err == nil && (req.ProtocolType == "" || req.ProtocolType == "consumer") {
+		resp.Assignment = s.quirksFor(state, header.ClientID).groupData(resp.Assignment)
 	}
+	if resp.Assignment == nil {
+		resp.Assignment = []byte{}
+	}
+
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeSyncGroupResponse(enc, header.APIVersion, resp)
 
 	return s.wrapResponse(enc.Bytes()), nil
 }
 
-func (s *KafkaServer) handleHeartbeat(header protocol.RequestHeader, dec *protocol.Decoder) ([]byte, error) {
+// groupErrorCode maps a group coordinator error to a Kafka error code
+func groupErrorCode(err error) int16 {
+	switch {
+	case err == nil:
+		return kafkaproto.ErrNone
+	case errors.Is(err, engine.ErrInvalidSessionTimeout):
+		return kafkaproto.ErrInvalidSessionTimeout
+	case errors.Is(err, engine.ErrMemberIDRequired):
+		return kafkaproto.ErrMemberIDRequired
+	case errors.Is(err, engine.ErrUnknownMemberID):
+		return kafkaproto.ErrUnknownMemberID
+	case errors.Is(err, engine.ErrRebalanceInProgress):
+		return kafkaproto.ErrRebalanceInProgress
+	case errors.Is(err, engine.ErrIllegalGeneration):
+		return kafkaproto.ErrIllegalGeneration
+	case errors.Is(err, engine.ErrInconsistentGroupProtocol):
+		return kafkaproto.ErrInconsistentGroupProtocol
+	case errors.Is(err, errGroupAuthorizationFailed):
+		return kafkaproto.ErrGroupAuthorizationFailed
+	case errors.Is(err, engine.ErrCoordinatorNotAvailable):
+		return kafkaproto.ErrCoordinatorNotAvailable
+	default:
+		return kafkaproto.ErrCoordinatorNotAvailable
+	}
+}
+
+func (s *KafkaServer) handleHeartbeat(header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
 	groupID, _ := dec.ReadString()
 	generationID, _ := dec.ReadInt32()
 	memberID, _ := dec.ReadString()
 
 	log.Printf("[kafka] heartbeat: group=%s generation=%d member=%s", groupID, generationID, memberID)
 
-	enc := protocol.NewEncoder()
-	enc.WriteResponseHeader(header.CorrelationID)
+	// Expired, fenced and rebalancing members must rejoin
+	errCode := kafkaproto.ErrGroupAuthorizationFailed
+	if s.authorized(state, kafkaproto.AclOperationRead, kafkaproto.AclResourceGroup, groupID) {
+		errCode = groupErrorCode(s.engine.Heartbeat(groupID, memberID, generationID))
+	}
+
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
 
 	// Throttle time (v1+)
 	if header.APIVersion >= 1 {
 		enc.WriteInt32(0)
 	}
 
-	enc.WriteInt16(protocol.ErrNone) // error_code
+	enc.WriteInt16(errCode) // error_code
 
 	return s.wrapResponse(enc.Bytes()), nil
 }
 
-func (s *KafkaServer) handleLeaveGroup(header protocol.RequestHeader, dec *protocol.Decoder) ([]byte, error) {
+func (s *KafkaServer) handleLeaveGroup(header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
 	groupID, _ := dec.ReadString()
-	memberID, _ := dec.ReadString()
 
-	log.Printf("[kafka] leave group: group=%s member=%s", groupID, memberID)
+	// v0-2 name a single member; v3+ send a members array
+	var memberIDs []string
+	if header.APIVersion >= 3 {
+		count, _ := dec.ReadInt32()
+		for i := int32(0); i < count; i++ {
+			id, _ := dec.ReadString()
+			dec.ReadNullableString() // group_instance_id
+			memberIDs = append(memberIDs, id)
+		}
+	} else {
+		id, _ := dec.ReadString()
+		memberIDs = append(memberIDs, id)
+	}
+
+	allowed := s.authorized(state, kafkaproto.AclOperationRead, kafkaproto.AclResourceGroup, groupID)
+	memberErrs := make([]int16, len(memberIDs))
+	for i, memberID := range memberIDs {
+		if !allowed {
+			memberErrs[i] = kafkaproto.ErrGroupAuthorizationFailed
+			continue
+		}
+		log.Printf("[kafka] leave group: group=%s member=%s", groupID, memberID)
+		memberErrs[i] = groupErrorCode(s.engine.LeaveGroup(groupID, memberID))
+	}
 
-	enc := protocol.NewEncoder()
-	enc.WriteResponseHeader(header.CorrelationID)
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
 
 	// Throttle time (v1+)
 	if header.APIVersion >= 1 {
 		enc.WriteInt32(0)
 	}
 
-	enc.WriteInt16(protocol.ErrNone) // error_code
-
-	// v3+ has members array
 	if header.APIVersion >= 3 {
-		enc.WriteArrayLen(1)
-		enc.WriteString(memberID)
-		if header.APIVersion >= 3 {
+		topErr := kafkaproto.ErrNone
+		if !allowed {
+			topErr = kafkaproto.ErrGroupAuthorizationFailed
+		}
+		enc.WriteInt16(topErr) // error_code
+		enc.WriteArrayLen(len(memberIDs))
+		for i, memberID := range memberIDs {
+			enc.WriteString(memberID)
 			enc.WriteNullableString(nil) // group_instance_id
+			enc.WriteInt16(memberErrs[i]) // error_code
 		}
-		enc.WriteInt16(protocol.ErrNone) // error_code
+	} else {
+		enc.WriteInt16(memberErrs[0]) // error_code
 	}
 
 	return s.wrapResponse(enc.Bytes()), nil
 }
 
-func (s *KafkaServer) handleOffsetCommit(header protocol.RequestHeader, dec *protocol.Decoder) ([]byte, error) {
-	groupID, _ := dec.ReadString()
-	generationID, _ := dec.ReadInt32()
-	memberID, _ := dec.ReadString()
-
-	// v2+ has retention time
-	if header.APIVersion >= 2 && header.APIVersion < 5 {
-		dec.ReadInt64() // retention_time_ms
+func (s *KafkaServer) handleOffsetCommit(ctx context.Context, header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	req, err := kafkaproto.DecodeOffsetCommitRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode offset commit request: %w", err)
+	}
+	if header.APIVersion < 1 {
+		req.GenerationID = -1 // v0 commits carry no member info
 	}
 
-	// v7+ has group_instance_id
-	if header.APIVersion >= 7 {
-		dec.ReadNullableString() // group_instance_id
+	log.Printf("[kafka] offset commit: group=%s gen=%d member=%s topics=%d",
+		req.GroupID, req.GenerationID, req.MemberID, len(req.Topics))
+
+	// Fence zombie members: a replaced member's commits must not land
+	var errCode int16 = kafkaproto.ErrNone
+	if !s.authorized(state, kafkaproto.AclOperationRead, kafkaproto.AclResourceGroup, req.GroupID) {
+		errCode = kafkaproto.ErrGroupAuthorizationFailed
+	} else if err := s.engine.ValidateCommit(req.GroupID, req.MemberID, req.GenerationID); err != nil {
+		log.Printf("[kafka] offset commit rejected: group=%s member=%s gen=%d: %v",
+			req.GroupID, req.MemberID, req.GenerationID, err)
+		errCode = groupErrorCode(err)
+	} else {
+		// Ensure group exists
+		s.engine.GetOrCreateGroup(ctx, req.GroupID)
 	}
 
-	topicCount, _ := dec.ReadInt32()
+	resp := &kafkaproto.OffsetCommitResponse{}
+	for _, t := range req.Topics {
+		topicResp := kafkaproto.OffsetCommitResponseTopic{Name: t.Name}
 
-	log.Printf("[kafka] offset commit: group=%s gen=%d member=%s topics=%d",
-		groupID, generationID, memberID, topicCount)
+		for _, p := range t.Partitions {
+			partErr := errCode
+			if partErr == kafkaproto.ErrNone && !s.authorized(state, kafkaproto.AclOperationRead, kafkaproto.AclResourceTopic, t.Name) {
+				partErr = kafkaproto.ErrTopicAuthorizationFailed
+			}
+			if partErr == kafkaproto.ErrNone && (p.Index != 0 || !s.engine.TopicExists(t.Name)) {
+				partErr = kafkaproto.ErrUnknownTopicOrPartition
+			}
 
-	// Ensure group exists
-	s.engine.GetOrCreateGroup(groupID)
+			if partErr == kafkaproto.ErrNone {
+				if err := s.engine.CommitOffset(ctx, req.GroupID, t.Name, p.Index, p.CommittedOffset); err != nil {
+					log.Printf("[kafka] offset commit error: %v", err)
+					partErr = kafkaproto.ErrCoordinatorNotAvailable
+				}
+			}
 
-	enc := protocol.NewEncoder()
-	enc.WriteResponseHeader(header.CorrelationID)
+			topicResp.Partitions = append(topicResp.Partitions, kafkaproto.OffsetCommitResponsePartition{
+				Index:     p.Index,
+				ErrorCode: partErr,
+			})
+		}
 
-	// Throttle time (v3+)
-	if header.APIVersion >= 3 {
-		enc.WriteInt32(0)
+		resp.Topics = append(resp.Topics, topicResp)
 	}
 
-	// Topics array
-	enc.WriteArrayLen(int(topicCount))
-	for i := int32(0); i < topicCount; i++ {
-		topicName, _ := dec.ReadString()
-		partCount, _ := dec.ReadInt32()
-
-		enc.WriteString(topicName)
-		enc.WriteArrayLen(int(partCount))
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeOffsetCommitResponse(enc, header.APIVersion, resp)
 
-		for j := int32(0); j < partCount; j++ {
-			partIndex, _ := dec.ReadInt32()
-			committedOffset, _ := dec.ReadInt64()
+	return s.wrapResponse(enc.Bytes()), nil
+}
 
-			// v6+ has committed_leader_epoch
-			if header.APIVersion >= 6 {
-				dec.ReadInt32() // committed_leader_epoch
-			}
+// handleOffsetFetch returns a group's committed offsets for the partitions
+// asked for, each looked up on its own, or with null topics (v2+) every
+// offset the group has committed
+func (s *KafkaServer) handleOffsetFetch(header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	req, err := kafkaproto.DecodeOffsetFetchRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode offset fetch request: %w", err)
+	}
 
-			// v1+ has timestamp (deprecated in v2+)
-			if header.APIVersion == 1 {
-				dec.ReadInt64() // commit_timestamp
-			}
+	errCode := kafkaproto.ErrNone
+	if !s.authorized(state, kafkaproto.AclOperationDescribe, kafkaproto.AclResourceGroup, req.GroupID) {
+		errCode = kafkaproto.ErrGroupAuthorizationFailed
+	}
 
-			dec.ReadNullableString() // metadata
+	log.Printf("[kafka] offset fetch: group=%s topics=%d", req.GroupID, len(req.Topics))
 
-			// Commit the offset (we only support partition 0)
-			var errCode int16 = protocol.ErrNone
-			if partIndex == 0 {
-				if err := s.engine.CommitOffset(groupID, topicName, committedOffset); err != nil {
-					log.Printf("[kafka] offset commit error: %v", err)
-					errCode = protocol.ErrCoordinatorNotAvailable
+	resp := &kafkaproto.OffsetFetchResponse{}
+	if req.Topics == nil && header.APIVersion >= 2 {
+		if errCode == kafkaproto.ErrNone {
+			resp.Topics = s.committedOffsets(req.GroupID, state)
+		}
+	}
+	for _, t := range req.Topics {
+		topicResp := kafkaproto.OffsetFetchResponseTopic{Name: t.Name}
+		for _, partition := range t.Partitions {
+			// -1 means nothing committed
+			var committed int64 = -1
+			if errCode == kafkaproto.ErrNone {
+				offset, err := s.engine.FetchOffset(req.GroupID, t.Name, partition)
+				if err == nil && offset >= 0 {
+					committed = offset
 				}
 			}
-
-			enc.WriteInt32(partIndex)
-			enc.WriteInt16(errCode)
+			topicResp.Partitions = append(topicResp.Partitions, kafkaproto.OffsetFetchResponsePartition{
+				Index:           partition,
+				CommittedOffset: committed,
+				LeaderEpoch:     -1,
+				ErrorCode:       errCode,
+			})
 		}
+		resp.Topics = append(resp.Topics, topicResp)
+	}
+	if header.APIVersion >= 2 {
+		resp.ErrorCode = errCode
 	}
 
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeOffsetFetchResponse(enc, header.APIVersion, resp)
+
 	return s.wrapResponse(enc.Bytes()), nil
 }
 
-func (s *KafkaServer) handleOffsetFetch(header protocol.RequestHeader, dec *protocol.Decoder) ([]byte, error) {
-	groupID, _ := dec.ReadString()
-	topicCount, _ := dec.ReadInt32()
-
-	log.Printf("[kafka] offset fetch: group=%s topics=%d", groupID, topicCount)
+// committedOffsets lists every offset a group has committed, by topic and
+// partition, leaving out topics the ACLs do not let the connection describe
+func (s *KafkaServer) committedOffsets(groupID string, state *connState) []kafkaproto.OffsetFetchResponseTopic {
+	offsets, err := s.engine.GroupOffsets(groupID)
+	if err != nil {
+		log.Printf("[kafka] offset fetch: reading offsets of %s: %v", groupID, err)
+		return nil
+	}
+	tps := slices.SortedFunc(maps.Keys(offsets), func(a, b store.TopicPartition) int {
+		if a.Topic != b.Topic {
+			return strings.Compare(a.Topic, b.Topic)
+		}
+		return int(a.Partition - b.Partition)
+	})
 
-	enc := protocol.NewEncoder()
-	enc.WriteResponseHeader(header.CorrelationID)
+	principal := principalOf(state)
+	var topics []kafkaproto.OffsetFetchResponseTopic
+	for _, tp := range tps {
+		if !s.engine.Authorize(principal, state.host, kafkaproto.AclOperationDescribe, kafkaproto.AclResourceTopic, tp.Topic) {
+			continue
+		}
+		if len(topics) == 0 || topics[len(topics)-1].Name != tp.Topic {
+			topics = append(topics, kafkaproto.OffsetFetchResponseTopic{Name: tp.Topic})
+		}
+		t := &topics[len(topics)-1]
+		t.Partitions = append(t.Partitions, kafkaproto.OffsetFetchResponsePartition{
+			Index:           tp.Partition,
+			CommittedOffset: offsets[tp],
+			LeaderEpoch:     -1,
+		})
+	}
+	return topics
+}
 
-	// Throttle time (v3+)
-	if header.APIVersion >= 3 {
-		enc.WriteInt32(0)
+// handleOffsetForLeaderEpoch tells a follower or consumer where an epoch
+// ended, so clients checking for log truncation after a restart find the
+// log intact rather than failing the check
+func (s *KafkaServer) handleOffsetForLeaderEpoch(header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	req, err := kafkaproto.DecodeOffsetForLeaderEpochRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode offset for leader epoch request: %w", err)
 	}
 
-	// Topics array
-	enc.WriteArrayLen(int(topicCount))
-	for i := int32(0); i < topicCount; i++ {
-		topicName, _ := dec.ReadString()
-		partCount, _ := dec.ReadInt32()
+	resp := &kafkaproto.OffsetForLeaderEpochResponse{
+		ThrottleTimeMs: 0,
+	}
 
-		enc.WriteString(topicName)
-		enc.WriteArrayLen(int(partCount))
+	for _, t := range req.Topics {
+		topicResp := kafkaproto.OffsetForLeaderEpochResponseTopic{
+			Name: t.Name,
+		}
 
-		for j := int32(0); j < partCount; j++ {
-			partIndex, _ := dec.ReadInt32()
+		for _, p := range t.Partitions {
+			partResp := kafkaproto.OffsetForLeaderEpochResponsePartition{
+				PartitionIndex: p.PartitionIndex,
+				LeaderEpoch:    kafkaproto.UndefinedEpoch,
+				EndOffset:      kafkaproto.UndefinedEpochOffset,
+			}
 
-			// Fetch committed offset from storage
-			var committedOffset int64 = -1
-			if partIndex == 0 {
-				offset, err := s.engine.FetchOffset(groupID, topicName)
-				if err == nil && offset >= 0 {
-					committedOffset = offset
+			current, err := s.engine.LeaderEpoch(t.Name)
+			if !s.authorized(state, kafkaproto.AclOperationDescribe, kafkaproto.AclResourceTopic, t.Name) {
+				partResp.ErrorCode = kafkaproto.ErrTopicAuthorizationFailed
+			} else if err != nil || p.PartitionIndex != 0 {
+				partResp.ErrorCode = kafkaproto.ErrUnknownTopicOrPartition
+			} else if code := s.notLeader(state, t.Name, p.PartitionIndex); code != kafkaproto.ErrNone {
+				partResp.ErrorCode = code
+			} else if p.CurrentLeaderEpoch != kafkaproto.UndefinedEpoch && p.CurrentLeaderEpoch < current {
+				partResp.ErrorCode = kafkaproto.ErrFencedLeaderEpoch
+			} else if p.CurrentLeaderEpoch > current {
+				partResp.ErrorCode = kafkaproto.ErrUnknownLeaderEpoch
+			} else {
+				epoch, endOffset, err := s.engine.EpochEndOffset(t.Name, p.LeaderEpoch)
+				if err != nil {
+					partResp.ErrorCode = kafkaproto.ErrUnknownTopicOrPartition
+				} else {
+					partResp.ErrorCode = kafkaproto.ErrNone
+					partResp.LeaderEpoch = epoch
+					partResp.EndOffset = endOffset
 				}
 			}
 
-			enc.WriteInt32(partIndex)
-			enc.WriteInt64(committedOffset)
-			if header.APIVersion >= 5 {
-				enc.WriteInt32(-1) // committed_leader_epoch
-			}
-			enc.WriteNullableString(nil) // metadata
-			enc.WriteInt16(protocol.ErrNone)
+			log.Printf("[kafka] offset for leader epoch: topic=%s partition=%d epoch=%d -> epoch=%d end=%d err=%d",
+				t.Name, p.PartitionIndex, p.LeaderEpoch, partResp.LeaderEpoch, partResp.EndOffset, partResp.ErrorCode)
+			topicResp.Partitions = append(topicResp.Partitions, partResp)
 		}
+
+		resp.Topics = append(resp.Topics, topicResp)
 	}
 
-	// Error code (v2+)
-	if header.APIVersion >= 2 {
-		enc.WriteInt16(protocol.ErrNone)
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeOffsetForLeaderEpochResponse(enc, header.APIVersion, resp)
+
+	return s.wrapResponse(enc.Bytes()), nil
+}
+
+// handleMonologPurge deletes topic data for Kafka-only test clients
+func (s *KafkaServer) handleMonologPurge(ctx context.Context, header kafkaproto.RequestHeader, dec *kafkaproto.Decoder, state *connState) ([]byte, error) {
+	req, err := kafkaproto.DecodeMonologPurgeRequest(dec, header.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("decode monolog purge request: %w", err)
+	}
+
+	resp := &kafkaproto.MonologPurgeResponse{}
+	for _, t := range req.Topics {
+		topicResp := kafkaproto.MonologPurgeResponseTopic{Name: t.Name}
+
+		var before time.Time
+		if t.BeforeMs != kafkaproto.PurgeAll {
+			before = time.UnixMilli(t.BeforeMs)
+		}
+
+		// Purging deletes records, so it needs DELETE like DeleteRecords
+		if !s.authorized(state, kafkaproto.AclOperationDelete, kafkaproto.AclResourceTopic, t.Name) {
+			topicResp.ErrorCode = kafkaproto.ErrTopicAuthorizationFailed
+		} else if !s.engine.TopicExists(t.Name) {
+			topicResp.ErrorCode = kafkaproto.ErrUnknownTopicOrPartition
+		} else if deleted, err := s.engine.PurgeTopic(ctx, t.Name, before); err != nil {
+			log.Printf("[kafka] purge %s failed: %v", t.Name, err)
+			topicResp.ErrorCode = errorCode(err)
+		} else {
+			topicResp.DeletedRows = int32(deleted)
+			topicResp.LogStartOffset, _ = s.engine.EarliestOffset(ctx, t.Name)
+			log.Printf("[kafka] purge: topic=%s before=%d deleted=%d", t.Name, t.BeforeMs, deleted)
+		}
+
+		resp.Topics = append(resp.Topics, topicResp)
 	}
 
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
+	kafkaproto.EncodeMonologPurgeResponse(enc, header.APIVersion, resp)
+
 	return s.wrapResponse(enc.Bytes()), nil
 }
 
@@ -933,19 +2564,24 @@ func (s *KafkaServer) handleOffsetFetch(header protocol.RequestHeader, dec *prot
 // Helpers
 // ============================================================================
 
-func (s *KafkaServer) errorResponse(correlationID int32, errorCode int16) []byte {
-	enc := protocol.NewEncoder()
-	enc.WriteResponseHeader(correlationID)
+// unsupported answers a request for an API key we do not serve
+func (s *KafkaServer) unsupported(header kafkaproto.RequestHeader, state *connState) []byte {
+	s.compat.record(state, header.ClientID, header.APIKey, header.APIVersion)
+	log.Printf("[kafka] unsupported API key: %d", header.APIKey)
+	s.errors.Add(header.ClientID, "unsupported API key %d", header.APIKey)
+	s.engine.CountError(engine.ErrorKindUnsupported)
+	return s.errorResponse(header, kafkaproto.ErrUnsupportedVersion)
+}
+
+func (s *KafkaServer) errorResponse(header kafkaproto.RequestHeader, errorCode int16) []byte {
+	enc := kafkaproto.NewEncoder()
+	enc.WriteResponseHeaderFor(header)
 	enc.WriteInt16(errorCode)
 	return s.wrapResponse(enc.Bytes())
 }
 
 func (s *KafkaServer) wrapResponse(body []byte) []byte {
-	size := len(body)
-	result := make([]byte, 4+size)
-	binary.BigEndian.PutUint32(result[:4], uint32(size))
-	copy(result[4:], body)
-	return result
+	return kafkaproto.Frame(body)
 }
 
 func parseAddr(addr string) (string, int32) {
@@ -0,0 +1,265 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+	"github.com/rizkyandriawan/monolog/internal/engine"
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// listLatest sends ListOffsets v2 for the latest offset of partition 0
+func (c *testClient) listLatest(topic string, isolation int8) (int16, int64) {
+	c.t.Helper()
+	dec := c.call(kafkaproto.APIKeyListOffsets, 2, func(e *kafkaproto.Encoder) {
+		e.WriteInt32(-1) // replica ID
+		e.WriteInt8(isolation)
+		e.WriteArrayLen(1)
+		e.WriteString(topic)
+		e.WriteArrayLen(1)
+		e.WriteInt32(0)
+		e.WriteInt64(kafkaproto.OffsetLatest)
+	})
+	dec.ReadInt32() // throttle
+	dec.ReadInt32() // topics
+	dec.ReadString()
+	dec.ReadInt32() // partitions
+	dec.ReadInt32()
+	code, _ := dec.ReadInt16()
+	dec.ReadInt64() // timestamp
+	offset, _ := dec.ReadInt64()
+	return code, offset
+}
+
+func TestListOffsetsLatestReadCommitted(t *testing.T) {
+	addr, eng := startKafkaEngine(t, nil)
+	ctx := context.Background()
+
+	records := []store.Record{{Value: []byte("a")}, {Value: []byte("b")}}
+	if _, err := eng.Produce(ctx, "txn-latest", records); err != nil {
+		t.Fatalf("produce: %v", err)
+	}
+	// A transaction opened at offset 2 and still writing
+	first, err := eng.Produce(ctx, "txn-latest", records)
+	if err != nil {
+		t.Fatalf("produce: %v", err)
+	}
+	eng.BeginTransaction("txn-latest", 7, first)
+
+	c := dial(t, addr, "txn")
+	if code, offset := c.listLatest("txn-latest", engine.ReadUncommitted); code != 0 || offset != 4 {
+		t.Errorf("read_uncommitted LATEST: error %d offset %d, want the high watermark 4", code, offset)
+	}
+	if code, offset := c.listLatest("txn-latest", engine.ReadCommitted); code != 0 || offset != first {
+		t.Errorf("read_committed LATEST: error %d offset %d, want the last stable offset %d", code, offset, first)
+	}
+
+	if err := eng.EndTransaction(ctx, "txn-latest", 7, first+1, true); err != nil {
+		t.Fatalf("end transaction: %v", err)
+	}
+	if code, offset := c.listLatest("txn-latest", engine.ReadCommitted); code != 0 || offset != 4 {
+		t.Errorf("read_committed LATEST after commit: error %d offset %d, want 4", code, offset)
+	}
+}
+
+// startACLKafka serves Kafka, purge extension included, with ACLs on,
+// granting an unauthenticated client, User:ANONYMOUS, only what grants name
+func startACLKafka(t *testing.T, grants ...store.ACLBinding) (string, *engine.Engine) {
+	t.Helper()
+	addr, eng := startKafkaEngine(t, func(cfg *config.Config) {
+		cfg.Security.Users = map[string]string{"admin": "admin-pw"}
+		cfg.Security.ACLs.Enabled = true
+		cfg.Server.KafkaPurge = true
+	})
+	for i := range grants {
+		grants[i].PatternType = kafkaproto.AclPatternLiteral
+		grants[i].Principal = anonymousPrincipal
+		grants[i].Host = "*"
+		grants[i].Permission = kafkaproto.AclPermissionAllow
+	}
+	if len(grants) > 0 {
+		if err := eng.CreateACLs(context.Background(), grants); err != nil {
+			t.Fatalf("create ACLs: %v", err)
+		}
+	}
+	return addr, eng
+}
+
+// initTransactional sends InitProducerId v1 for a transactional ID
+func (c *testClient) initTransactional(id string) (int64, int16) {
+	c.t.Helper()
+	dec := c.call(kafkaproto.APIKeyInitProducerID, 1, func(e *kafkaproto.Encoder) {
+		e.WriteNullableString(&id)
+		e.WriteInt32(60000)
+	})
+	dec.ReadInt32() // throttle
+	if code, _ := dec.ReadInt16(); code != 0 {
+		c.t.Fatalf("InitProducerId: error %d", code)
+	}
+	pid, _ := dec.ReadInt64()
+	epoch, _ := dec.ReadInt16()
+	return pid, epoch
+}
+
+func TestTxnRequestsCheckACLs(t *testing.T) {
+	addr, eng := startACLKafka(t, store.ACLBinding{
+		ResourceType: kafkaproto.AclResourceTopic, ResourceName: "allowed", Operation: kafkaproto.AclOperationWrite,
+	})
+	if err := eng.CreateTopic(context.Background(), "allowed"); err != nil {
+		t.Fatalf("create topic: %v", err)
+	}
+	c := dial(t, addr, "txn")
+	pid, epoch := c.initTransactional("acl-txn")
+
+	// AddPartitionsToTxn v1: a topic it may not write to, and may not
+	// create, fails the request without creating it
+	dec := c.call(kafkaproto.APIKeyAddPartitionsToTxn, 1, func(e *kafkaproto.Encoder) {
+		e.WriteString("acl-txn")
+		e.WriteInt64(pid)
+		e.WriteInt16(epoch)
+		e.WriteArrayLen(2)
+		for _, topic := range []string{"allowed", "missing"} {
+			e.WriteString(topic)
+			e.WriteArrayLen(1)
+			e.WriteInt32(0)
+		}
+	})
+	dec.ReadInt32() // throttle
+	codes := map[string]int16{}
+	n, _ := dec.ReadInt32()
+	for i := int32(0); i < n; i++ {
+		name, _ := dec.ReadString()
+		dec.ReadInt32() // partitions
+		dec.ReadInt32()
+		codes[name], _ = dec.ReadInt16()
+	}
+	if codes["missing"] != kafkaproto.ErrTopicAuthorizationFailed || codes["allowed"] != kafkaproto.ErrOperationNotAttempted {
+		t.Errorf("AddPartitionsToTxn errors %v, want missing TOPIC_AUTHORIZATION_FAILED and allowed OPERATION_NOT_ATTEMPTED", codes)
+	}
+	if eng.TopicExists("missing") {
+		t.Error("AddPartitionsToTxn created a topic without CREATE")
+	}
+
+	// AddOffsetsToTxn v1 and TxnOffsetCommit v1 on a group it may not read
+	dec = c.call(kafkaproto.APIKeyAddOffsetsToTxn, 1, func(e *kafkaproto.Encoder) {
+		e.WriteString("acl-txn")
+		e.WriteInt64(pid)
+		e.WriteInt16(epoch)
+		e.WriteString("billing")
+	})
+	dec.ReadInt32() // throttle
+	if code, _ := dec.ReadInt16(); code != kafkaproto.ErrGroupAuthorizationFailed {
+		t.Errorf("AddOffsetsToTxn: error %d, want GROUP_AUTHORIZATION_FAILED", code)
+	}
+	dec = c.call(kafkaproto.APIKeyTxnOffsetCommit, 1, func(e *kafkaproto.Encoder) {
+		e.WriteString("acl-txn")
+		e.WriteString("billing")
+		e.WriteInt64(pid)
+		e.WriteInt16(epoch)
+		e.WriteArrayLen(1)
+		e.WriteString("allowed")
+		e.WriteArrayLen(1)
+		e.WriteInt32(0)
+		e.WriteInt64(1)
+		e.WriteNullableString(nil)
+	})
+	dec.ReadInt32() // throttle
+	dec.ReadInt32() // topics
+	dec.ReadString()
+	dec.ReadInt32() // partitions
+	dec.ReadInt32()
+	if code, _ := dec.ReadInt16(); code != kafkaproto.ErrGroupAuthorizationFailed {
+		t.Errorf("TxnOffsetCommit: error %d, want GROUP_AUTHORIZATION_FAILED", code)
+	}
+}
+
+func TestProduceNeedsCreateForMissingTopic(t *testing.T) {
+	addr, eng := startACLKafka(t, store.ACLBinding{
+		ResourceType: kafkaproto.AclResourceTopic, ResourceName: "new-topic", Operation: kafkaproto.AclOperationWrite,
+	})
+	batch, err := kafkaproto.NewRecordBatch([]kafkaproto.Record{{Timestamp: 1, Value: []byte("v")}}, kafkaproto.CompressionNone, nil)
+	if err != nil {
+		t.Fatalf("encode batch: %v", err)
+	}
+
+	// Produce v3
+	dec := dial(t, addr, "producer").call(kafkaproto.APIKeyProduce, 3, func(e *kafkaproto.Encoder) {
+		e.WriteNullableString(nil) // transactional ID
+		e.WriteInt16(1)            // acks
+		e.WriteInt32(10000)        // timeout
+		e.WriteArrayLen(1)
+		e.WriteString("new-topic")
+		e.WriteArrayLen(1)
+		e.WriteInt32(0)
+		e.WriteBytes(batch)
+	})
+	dec.ReadInt32() // topics
+	dec.ReadString()
+	dec.ReadInt32() // partitions
+	dec.ReadInt32()
+	if code, _ := dec.ReadInt16(); code != kafkaproto.ErrTopicAuthorizationFailed {
+		t.Errorf("Produce to a missing topic with WRITE but no CREATE: error %d, want TOPIC_AUTHORIZATION_FAILED", code)
+	}
+	if eng.TopicExists("new-topic") {
+		t.Error("Produce created a topic without CREATE")
+	}
+}
+
+func TestOffsetsAndPurgeCheckTopicACLs(t *testing.T) {
+	addr, eng := startACLKafka(t, store.ACLBinding{
+		ResourceType: kafkaproto.AclResourceTopic, ResourceName: "orders", Operation: kafkaproto.AclOperationRead,
+	})
+	ctx := context.Background()
+	for _, topic := range []string{"orders", "secret"} {
+		if _, err := eng.Produce(ctx, topic, []store.Record{{Value: []byte("v")}}); err != nil {
+			t.Fatalf("produce: %v", err)
+		}
+	}
+	c := dial(t, addr, "reader")
+
+	if code, _ := c.listLatest("orders", engine.ReadUncommitted); code != kafkaproto.ErrNone {
+		t.Errorf("ListOffsets on a topic it may read: error %d, want none", code)
+	}
+	if code, offset := c.listLatest("secret", engine.ReadUncommitted); code != kafkaproto.ErrTopicAuthorizationFailed {
+		t.Errorf("ListOffsets on a topic it may not describe: error %d offset %d, want TOPIC_AUTHORIZATION_FAILED", code, offset)
+	}
+
+	// OffsetForLeaderEpoch v2
+	dec := c.call(kafkaproto.APIKeyOffsetForLeaderEpoch, 2, func(e *kafkaproto.Encoder) {
+		e.WriteArrayLen(1)
+		e.WriteString("secret")
+		e.WriteArrayLen(1)
+		e.WriteInt32(0)
+		e.WriteInt32(kafkaproto.UndefinedEpoch) // current leader epoch
+		e.WriteInt32(0)
+	})
+	dec.ReadInt32() // throttle
+	dec.ReadInt32() // topics
+	dec.ReadString()
+	dec.ReadInt32() // partitions
+	if code, _ := dec.ReadInt16(); code != kafkaproto.ErrTopicAuthorizationFailed {
+		t.Errorf("OffsetForLeaderEpoch on a topic it may not describe: error %d, want TOPIC_AUTHORIZATION_FAILED", code)
+	}
+
+	// Reading a topic does not allow purging it
+	dec = c.call(kafkaproto.APIKeyMonologPurge, 0, func(e *kafkaproto.Encoder) {
+		kafkaproto.EncodeMonologPurgeRequest(e, 0, &kafkaproto.MonologPurgeRequest{
+			Topics: []kafkaproto.MonologPurgeRequestTopic{{Name: "orders", BeforeMs: kafkaproto.PurgeAll}},
+		})
+	})
+	resp, err := kafkaproto.DecodeMonologPurgeResponse(dec, 0)
+	if err != nil {
+		t.Fatalf("decode purge response: %v", err)
+	}
+	if len(resp.Topics) != 1 || resp.Topics[0].ErrorCode != kafkaproto.ErrTopicAuthorizationFailed {
+		t.Errorf("purge without DELETE: %+v, want TOPIC_AUTHORIZATION_FAILED", resp.Topics)
+	}
+	if latest, _ := eng.LatestOffset("orders"); latest != 0 {
+		t.Errorf("orders ends at %d after a refused purge, want 0", latest)
+	}
+	if earliest, _ := eng.EarliestOffset(ctx, "orders"); earliest != 0 {
+		t.Errorf("orders starts at %d after a refused purge, want 0", earliest)
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rizkyandriawan/monolog/internal/engine"
+)
+
+// handleKeys serves the table view of a compacted topic:
+// GET /api/topics/{name}/keys?after=&limit= lists keys with their latest
+// values in key order, and GET /api/topics/{name}/keys/{key} returns one
+// key's latest value. Keys in the path are URL-escaped.
+func (s *HTTPServer) handleKeys(w http.ResponseWriter, r *http.Request, topicName string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Taken from the escaped path so keys may contain slashes
+	_, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.EscapedPath(), "/api/topics/"), "/")
+	if rest = strings.TrimPrefix(strings.TrimPrefix(rest, "keys"), "/"); rest != "" {
+		key, err := url.PathUnescape(rest)
+		if err != nil {
+			http.Error(w, "invalid key: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.handleKey(w, r, topicName, key)
+		return
+	}
+
+	q := r.URL.Query()
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 || limit > engine.MaxKeyPage {
+			http.Error(w, fmt.Sprintf("limit must be 1-%d", engine.MaxKeyPage), http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := s.engine.TopicKeys(r.Context(), topicName, q.Get("after"), limit)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	keys := make([]apiMessage, len(page.Messages))
+	for i, m := range page.Messages {
+		keys[i] = apiMessage{Offset: m.Offset, Timestamp: m.Timestamp.UnixMilli(), Key: string(m.Key), Value: string(m.Value)}
+	}
+	resp := map[string]interface{}{
+		"topic": topicName,
+		"keys":  keys,
+		"total": page.Total,
+	}
+	if page.Next != "" {
+		resp["next"] = page.Next
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (s *HTTPServer) handleKey(w http.ResponseWriter, r *http.Request, topicName, key string) {
+	msg, err := s.engine.TopicKey(r.Context(), topicName, []byte(key))
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	if msg == nil {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(apiMessage{Offset: msg.Offset, Timestamp: msg.Timestamp.UnixMilli(), Key: string(msg.Key), Value: string(msg.Value)})
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// sniffTimeout bounds how long a new connection may stay silent before
+// the port mux gives up on routing it
+const sniffTimeout = 10 * time.Second
+
+// PortMux serves HTTP and the Kafka protocol on a single listener. Both
+// clients speak first, so each connection is routed by its first byte:
+// HTTP requests open with an upper-case method name, while a Kafka request
+// opens with a big-endian size that would have to exceed 1 GiB to start
+// with a printable letter. With TLS the mux completes the handshake first
+// and routes by the first decrypted byte.
+type PortMux struct {
+	ln    net.Listener
+	tls   *tls.Config // nil unless security.tls is enabled
+	kafka *muxListener
+	http  *muxListener
+}
+
+// ListenPortMux binds addr for a PortMux, serving TLS when tlsConfig is set
+func ListenPortMux(addr string, tlsConfig *tls.Config) (*PortMux, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &PortMux{
+		ln:    ln,
+		tls:   tlsConfig,
+		kafka: newMuxListener(ln.Addr()),
+		http:  newMuxListener(ln.Addr()),
+	}, nil
+}
+
+// Kafka returns the listener that receives Kafka protocol connections
+func (m *PortMux) Kafka() net.Listener {
+	return m.kafka
+}
+
+// HTTP returns the listener that receives HTTP connections
+func (m *PortMux) HTTP() net.Listener {
+	return m.http
+}
+
+// Serve accepts connections and routes them until the mux is closed
+func (m *PortMux) Serve() error {
+	for {
+		conn, err := m.ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			log.Printf("[mux] accept error: %v", err)
+			continue
+		}
+		go m.route(conn)
+	}
+}
+
+// Close stops accepting connections on the shared port
+func (m *PortMux) Close() error {
+	m.kafka.Close()
+	m.http.Close()
+	return m.ln.Close()
+}
+
+func (m *PortMux) route(conn net.Conn) {
+	if m.tls != nil {
+		tlsConn := tls.Server(conn, m.tls)
+		tlsConn.SetDeadline(time.Now().Add(sniffTimeout))
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return
+		}
+		tlsConn.SetDeadline(time.Time{})
+		conn = tlsConn
+	}
+	r := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(sniffTimeout))
+	first, err := r.Peek(1)
+	conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	target := m.kafka
+	if first[0] >= 'A' && first[0] <= 'Z' {
+		target = m.http
+	}
+	target.deliver(&peekedConn{Conn: conn, r: r})
+}
+
+// peekedConn replays bytes buffered while sniffing before reading on
+type peekedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// muxListener is a net.Listener fed with connections routed by a PortMux
+type muxListener struct {
+	addr  net.Addr
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newMuxListener(addr net.Addr) *muxListener {
+	return &muxListener{
+		addr:  addr,
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+}
+
+func (l *muxListener) deliver(conn net.Conn) {
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+func (l *muxListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *muxListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *muxListener) Addr() net.Addr {
+	return l.addr
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// ============================================================================
+// Reverse proxy support
+//
+// Behind Traefik or nginx the UI and API may live under a URL prefix
+// (server.base_path), and the scheme and host clients see differ from the
+// ones we are reached on. Generated links honor X-Forwarded-Proto and
+// X-Forwarded-Host so they point at the proxy, not at us.
+// ============================================================================
+
+// normalizeBasePath turns "monolog/", "/monolog" or "/" into "/monolog" or ""
+func normalizeBasePath(p string) string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return ""
+	}
+	return path.Clean("/" + p)
+}
+
+// mountBasePath serves h under base. The health probes also answer at the
+// root so orchestrators probing the pod directly need not know the prefix.
+func mountBasePath(base string, h http.Handler) http.Handler {
+	if base == "" {
+		return h
+	}
+	stripped := http.StripPrefix(base, h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == base:
+			target := base + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+		case strings.HasPrefix(r.URL.Path, base+"/"):
+			stripped.ServeHTTP(w, r)
+		case r.URL.Path == "/healthz" || r.URL.Path == "/readyz":
+			h.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// forwardedHost returns the host the client asked for, preferring the
+// proxy's X-Forwarded-Host over our own Host header
+func forwardedHost(r *http.Request) string {
+	if host := firstHeaderValue(r, "X-Forwarded-Host"); host != "" {
+		return host
+	}
+	return r.Host
+}
+
+// forwardedProto returns the scheme the client used to reach the proxy
+func forwardedProto(r *http.Request) string {
+	switch proto := strings.ToLower(firstHeaderValue(r, "X-Forwarded-Proto")); proto {
+	case "http", "https":
+		return proto
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// firstHeaderValue returns the first entry of a comma-separated header that
+// chained proxies may have appended to
+func firstHeaderValue(r *http.Request, name string) string {
+	v, _, _ := strings.Cut(r.Header.Get(name), ",")
+	return strings.TrimSpace(v)
+}
+
+// externalURL returns the absolute URL a client uses to reach p (a path
+// relative to the base path) on this server
+func (s *HTTPServer) externalURL(r *http.Request, p string) string {
+	return forwardedProto(r) + "://" + forwardedHost(r) + s.basePath + p
+}
+
+// rewriteAsset points root-relative references in a built UI file at the
+// base path, and tells index.html where the API lives
+func (s *HTTPServer) rewriteAsset(name string, content []byte) []byte {
+	if s.basePath == "" {
+		return content
+	}
+	switch path.Ext(name) {
+	case ".html":
+		for _, attr := range []string{`src="`, `href="`} {
+			content = bytes.ReplaceAll(content, []byte(attr+"/"), []byte(attr+s.basePath+"/"))
+			// Protocol-relative URLs ("//cdn...") point elsewhere; undo those
+			content = bytes.ReplaceAll(content, []byte(attr+s.basePath+"//"), []byte(attr+"//"))
+		}
+		base, _ := json.Marshal(s.basePath)
+		script := []byte("<script>window.__MONOLOG_BASE_PATH__=" + string(base) + "</script></head>")
+		content = bytes.Replace(content, []byte("</head>"), script, 1)
+	case ".css", ".js":
+		for _, prefix := range []string{`"/assets/`, `'/assets/`, `(/assets/`} {
+			content = bytes.ReplaceAll(content, []byte(prefix), []byte(prefix[:1]+s.basePath+"/assets/"))
+		}
+	}
+	return content
+}
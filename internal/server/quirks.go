@@ -0,0 +1,107 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+)
+
+// ============================================================================
+// Client quirks
+//
+// Workarounds for clients that trip over behaviour the Java client copes
+// with, set per client in compat.clients. A connection gets the quirks of
+// the client software it names in ApiVersions v3+, or, if it never names
+// one, of its client ID. Sarama, which gets stuck rebalancing without
+// them, has these by default:
+//
+//   - JoinGroup is advertised up to v3, so releases that negotiate
+//     versions never see MEMBER_ID_REQUIRED
+//   - releases that do not negotiate still send JoinGroup v4+ from their
+//     configured Kafka version, and some answer MEMBER_ID_REQUIRED by
+//     rejoining without the ID they were given; they are admitted directly
+//   - the leader decodes every member's subscription and each member its
+//     assignment, and an empty one fails the whole rebalance; empty ones
+//     are sent as an encoded subscription or assignment of nothing
+// ============================================================================
+
+// emptyConsumerData is a consumer protocol subscription, or assignment,
+// of nothing: version 0, no topics, null user data. Both encode the same.
+var emptyConsumerData = []byte{0, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff}
+
+// clientQuirks are the workarounds one client gets
+type clientQuirks struct {
+	maxVersions          map[int16]int16 // API key -> highest version advertised
+	skipMemberIDRequired bool
+	fillEmptyGroupData   bool
+}
+
+// newClientQuirks resolves compat.clients, keyed by lowercased name
+func newClientQuirks(cfg config.CompatConfig) (map[string]*clientQuirks, error) {
+	keys := make(map[string]int16)
+	for _, v := range append(kafkaproto.DefaultApiVersions(), kafkaproto.ExtensionApiVersions()...) {
+		keys[kafkaproto.APIKeyName(v.APIKey)] = v.APIKey
+	}
+
+	quirks := make(map[string]*clientQuirks, len(cfg.Clients))
+	for name, c := range cfg.Clients {
+		q := &clientQuirks{
+			maxVersions:          make(map[int16]int16, len(c.MaxVersions)),
+			skipMemberIDRequired: c.SkipMemberIDRequired,
+			fillEmptyGroupData:   c.FillEmptyGroupData,
+		}
+		for api, max := range c.MaxVersions {
+			key, ok := keys[api]
+			if !ok {
+				return nil, fmt.Errorf("compat client %s: unknown API %q", name, api)
+			}
+			if min, _, _ := supportedRange(key); max < min {
+				return nil, fmt.Errorf("compat client %s: %s max version %d is below the lowest supported, %d", name, api, max, min)
+			}
+			q.maxVersions[key] = max
+		}
+		quirks[strings.ToLower(name)] = q
+	}
+	return quirks, nil
+}
+
+// quirksFor returns the quirks of the client on a connection, nil for none
+func (s *KafkaServer) quirksFor(state *connState, clientID string) *clientQuirks {
+	name := state.softwareName
+	if name == "" {
+		name = clientID
+	}
+	return s.quirks[strings.ToLower(name)]
+}
+
+// advertised caps versions at the client's pinned maximums
+func (q *clientQuirks) advertised(versions []kafkaproto.ApiVersion) []kafkaproto.ApiVersion {
+	if q == nil || len(q.maxVersions) == 0 {
+		return versions
+	}
+	capped := make([]kafkaproto.ApiVersion, len(versions))
+	for i, v := range versions {
+		if max, ok := q.maxVersions[v.APIKey]; ok {
+			v.MaxVersion = min(v.MaxVersion, max)
+		}
+		capped[i] = v
+	}
+	return capped
+}
+
+// requireMemberID reports whether a JoinGroup at version must come back
+// with an assigned member ID before joining
+func (q *clientQuirks) requireMemberID(version int16) bool {
+	return version >= 4 && (q == nil || !q.skipMemberIDRequired)
+}
+
+// groupData returns consumer protocol data to send the client, an encoded
+// empty one in place of none if the client needs it
+func (q *clientQuirks) groupData(data []byte) []byte {
+	if len(data) == 0 && q != nil && q.fillEmptyGroupData {
+		return emptyConsumerData
+	}
+	return data
+}
@@ -0,0 +1,276 @@
+package server
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+	"github.com/rizkyandriawan/monolog/internal/engine"
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// These tests speak Sarama's dialect of the group protocol on the wire:
+// the client ID and software name it sends, the JoinGroup it sends from a
+// configured Kafka version without negotiating, and the empty
+// subscriptions and assignments it cannot decode. Each runs against a
+// server with the default compat config, which is what Sarama users get.
+
+const errMemberIDRequired = 79
+
+// startKafka serves the Kafka protocol over an in-memory store on a free
+// port and returns its address
+func startKafka(t *testing.T) string {
+	t.Helper()
+	addr, _ := startKafkaEngine(t, nil)
+	return addr
+}
+
+// startKafkaEngine is startKafka with the config edit makes to the
+// defaults, if any, that also returns the engine behind the server
+func startKafkaEngine(t *testing.T, edit func(cfg *config.Config)) (string, *engine.Engine) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Groups.InitialRebalanceDelay = 0
+	if edit != nil {
+		edit(cfg)
+	}
+
+	backend, err := store.Open("sqlite:memory", cfg.Storage)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	eng := engine.New(cfg, backend.Topics, backend.Groups)
+	eng.Start()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	cfg.Server.KafkaAddr = ln.Addr().String()
+	srv, err := NewKafkaServer(cfg, eng)
+	if err != nil {
+		t.Fatalf("kafka server: %v", err)
+	}
+	go srv.Serve(ln)
+
+	t.Cleanup(func() {
+		srv.Close()
+		eng.Stop()
+		backend.Close()
+	})
+	return ln.Addr().String(), eng
+}
+
+// testClient sends requests under one client ID over one connection
+type testClient struct {
+	t        *testing.T
+	conn     net.Conn
+	clientID string
+	corr     int32
+}
+
+func dial(t *testing.T, addr, clientID string) *testClient {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &testClient{t: t, conn: conn, clientID: clientID}
+}
+
+// call sends a request with the body body writes and returns a decoder
+// over the response body, past its header
+func (c *testClient) call(apiKey, version int16, body func(e *kafkaproto.Encoder)) *kafkaproto.Decoder {
+	c.t.Helper()
+	c.corr++
+	enc := kafkaproto.NewEncoder()
+	enc.WriteRequestHeader(kafkaproto.RequestHeader{
+		APIKey:        apiKey,
+		APIVersion:    version,
+		CorrelationID: c.corr,
+		ClientID:      c.clientID,
+	})
+	body(enc)
+
+	c.conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := c.conn.Write(kafkaproto.Frame(enc.Bytes())); err != nil {
+		c.t.Fatalf("write %s: %v", kafkaproto.APIKeyName(apiKey), err)
+	}
+	resp, err := kafkaproto.ReadFrame(c.conn, 1<<20)
+	if err != nil {
+		c.t.Fatalf("read %s: %v", kafkaproto.APIKeyName(apiKey), err)
+	}
+	dec := kafkaproto.NewDecoder(bytes.NewReader(resp))
+	h, err := dec.ReadResponseHeader(apiKey, version)
+	if err != nil {
+		c.t.Fatalf("read %s header: %v", kafkaproto.APIKeyName(apiKey), err)
+	}
+	if h.CorrelationID != c.corr {
+		c.t.Fatalf("%s: correlation ID %d, want %d", kafkaproto.APIKeyName(apiKey), h.CorrelationID, c.corr)
+	}
+	return dec
+}
+
+// apiVersions sends ApiVersions v3 naming software and returns the
+// highest version advertised per API key
+func (c *testClient) apiVersions(software string) map[int16]int16 {
+	c.t.Helper()
+	dec := c.call(kafkaproto.APIKeyApiVersions, 3, func(e *kafkaproto.Encoder) {
+		e.WriteCompactString(software)
+		e.WriteCompactString("1.0.0")
+		e.WriteEmptyTaggedFields()
+	})
+	if code, _ := dec.ReadInt16(); code != 0 {
+		c.t.Fatalf("ApiVersions: error %d", code)
+	}
+	n, _ := dec.ReadCompactArrayLen()
+	max := make(map[int16]int16, n)
+	for i := 0; i < n; i++ {
+		key, _ := dec.ReadInt16()
+		dec.ReadInt16()
+		v, _ := dec.ReadInt16()
+		dec.SkipTaggedFields()
+		max[key] = v
+	}
+	return max
+}
+
+type joinResult struct {
+	errorCode  int16
+	generation int32
+	leader     string
+	memberID   string
+	members    map[string][]byte // member ID -> subscription, for the leader
+}
+
+// joinGroup sends JoinGroup v5 with one "range" protocol
+func (c *testClient) joinGroup(group, memberID string, subscription []byte) joinResult {
+	c.t.Helper()
+	dec := c.call(kafkaproto.APIKeyJoinGroup, 5, func(e *kafkaproto.Encoder) {
+		e.WriteString(group)
+		e.WriteInt32(10000) // session timeout
+		e.WriteInt32(10000) // rebalance timeout
+		e.WriteString(memberID)
+		e.WriteNullableString(nil) // group instance ID
+		e.WriteString("consumer")
+		e.WriteArrayLen(1)
+		e.WriteString("range")
+		e.WriteBytes(subscription)
+	})
+	var r joinResult
+	dec.ReadInt32() // throttle
+	r.errorCode, _ = dec.ReadInt16()
+	r.generation, _ = dec.ReadInt32()
+	dec.ReadString() // protocol name
+	r.leader, _ = dec.ReadString()
+	r.memberID, _ = dec.ReadString()
+	n, _ := dec.ReadInt32()
+	r.members = make(map[string][]byte, n)
+	for i := int32(0); i < n; i++ {
+		id, _ := dec.ReadString()
+		dec.ReadNullableString()
+		r.members[id], _ = dec.ReadBytes()
+	}
+	return r
+}
+
+// syncGroup sends SyncGroup v3 and returns the member's assignment
+func (c *testClient) syncGroup(group string, generation int32, memberID string, assignments map[string][]byte) (int16, []byte) {
+	c.t.Helper()
+	dec := c.call(kafkaproto.APIKeySyncGroup, 3, func(e *kafkaproto.Encoder) {
+		e.WriteString(group)
+		e.WriteInt32(generation)
+		e.WriteString(memberID)
+		e.WriteNullableString(nil)
+		e.WriteArrayLen(len(assignments))
+		for id, a := range assignments {
+			e.WriteString(id)
+			e.WriteBytes(a)
+		}
+	})
+	dec.ReadInt32() // throttle
+	code, _ := dec.ReadInt16()
+	assignment, _ := dec.ReadBytes()
+	return code, assignment
+}
+
+func TestSaramaJoinGroupPinnedToV3(t *testing.T) {
+	addr := startKafka(t)
+
+	sarama := dial(t, addr, "sarama").apiVersions("sarama")
+	if got := sarama[kafkaproto.APIKeyJoinGroup]; got != 3 {
+		t.Errorf("JoinGroup advertised to sarama up to v%d, want v3", got)
+	}
+
+	other := dial(t, addr, "other").apiVersions("librdkafka")
+	if got := other[kafkaproto.APIKeyJoinGroup]; got <= 3 {
+		t.Errorf("JoinGroup advertised to librdkafka up to v%d, want above v3", got)
+	}
+}
+
+func TestSaramaJoinWithoutMemberID(t *testing.T) {
+	addr := startKafka(t)
+
+	// Sarama's default client ID, without ApiVersions, as releases that
+	// send JoinGroup from their configured Kafka version do
+	r := dial(t, addr, "sarama").joinGroup("sarama-join", "", emptyConsumerData)
+	if r.errorCode != 0 {
+		t.Fatalf("sarama JoinGroup v5: error %d, want none", r.errorCode)
+	}
+	if r.memberID == "" || r.generation < 1 {
+		t.Errorf("sarama JoinGroup v5: member %q generation %d, want a member ID and generation", r.memberID, r.generation)
+	}
+
+	r = dial(t, addr, "other").joinGroup("other-join", "", emptyConsumerData)
+	if r.errorCode != errMemberIDRequired {
+		t.Errorf("JoinGroup v5 from other client: error %d, want MEMBER_ID_REQUIRED", r.errorCode)
+	}
+}
+
+func TestSaramaEmptyGroupData(t *testing.T) {
+	addr := startKafka(t)
+
+	c := dial(t, addr, "sarama")
+	r := c.joinGroup("sarama-empty", "", nil)
+	if r.errorCode != 0 {
+		t.Fatalf("JoinGroup: error %d", r.errorCode)
+	}
+	if r.leader != r.memberID {
+		t.Fatalf("JoinGroup: leader %q, want the only member %q", r.leader, r.memberID)
+	}
+	if sub := r.members[r.memberID]; !bytes.Equal(sub, emptyConsumerData) {
+		t.Errorf("leader got subscription %x for an empty one, want %x", sub, emptyConsumerData)
+	}
+
+	code, assignment := c.syncGroup("sarama-empty", r.generation, r.memberID, nil)
+	if code != 0 {
+		t.Fatalf("SyncGroup: error %d", code)
+	}
+	if !bytes.Equal(assignment, emptyConsumerData) {
+		t.Errorf("SyncGroup: assignment %x for none, want %x", assignment, emptyConsumerData)
+	}
+}
+
+func TestEmptyGroupDataUnfilledForOtherClients(t *testing.T) {
+	addr := startKafka(t)
+
+	c := dial(t, addr, "other")
+	r := c.joinGroup("other-empty", "", nil)
+	if r.errorCode == errMemberIDRequired {
+		r = c.joinGroup("other-empty", r.memberID, nil)
+	}
+	if r.errorCode != 0 {
+		t.Fatalf("JoinGroup: error %d", r.errorCode)
+	}
+	code, assignment := c.syncGroup("other-empty", r.generation, r.memberID, nil)
+	if code != 0 {
+		t.Fatalf("SyncGroup: error %d", code)
+	}
+	if len(assignment) != 0 {
+		t.Errorf("SyncGroup: assignment %x, want none", assignment)
+	}
+}
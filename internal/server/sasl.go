@@ -0,0 +1,168 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+	"github.com/rizkyandriawan/monolog/internal/engine"
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+)
+
+// ============================================================================
+// SASL PLAIN
+//
+// A PLAIN message is authzid NUL authcid NUL password (RFC 4616). The
+// authentication identity (authcid) becomes the connection's principal.
+// With security.users set the password must be authcid's own; otherwise
+// it is checked against security.token and authcid is taken on trust. A
+// client holding one of security.impersonation.superuser_tokens is not
+// tied to a name: it acts as the authorization identity (authzid), or
+// authcid without one, if that principal is on the impersonation allowlist.
+// ============================================================================
+
+// plainCredentials is a decoded SASL PLAIN message
+type plainCredentials struct {
+	authzID  string // who to act as; empty to act as authcid
+	authcID  string // who authenticated
+	password string
+}
+
+var errMalformedPlain = errors.New("malformed PLAIN message")
+
+func parsePlain(msg []byte) (plainCredentials, error) {
+	parts := bytes.Split(msg, []byte{0})
+	if len(parts) != 3 {
+		return plainCredentials{}, errMalformedPlain
+	}
+	return plainCredentials{
+		authzID:  string(parts[0]),
+		authcID:  string(parts[1]),
+		password: string(parts[2]),
+	}, nil
+}
+
+// authenticatePlain checks PLAIN credentials and returns the principal
+// the connection acts as
+func (s *KafkaServer) authenticatePlain(creds plainCredentials) (string, error) {
+	if !s.credentials.Superuser(creds.password) {
+		if !s.credentials.Authenticate(creds.authcID, creds.password) {
+			return "", errors.New("Authentication failed")
+		}
+		if creds.authzID != "" && creds.authzID != creds.authcID {
+			return "", fmt.Errorf("%s is not allowed to act as %s", creds.authcID, creds.authzID)
+		}
+		return creds.authcID, nil
+	}
+
+	principal := creds.authzID
+	if principal == "" {
+		principal = creds.authcID
+	}
+	allow := s.config.Security.Impersonation.Allow
+	if !slices.Contains(allow, "*") && !slices.Contains(allow, principal) {
+		return "", fmt.Errorf("impersonating %s is not allowed", principal)
+	}
+	return principal, nil
+}
+
+// checkPrincipalBinding refuses security settings that trust a principal
+// only a password of its own can vouch for: without security.users any
+// token holder picks its own username, so the impersonation allowlist and
+// ACLs would protect nothing
+func checkPrincipalBinding(sec config.SecurityConfig) error {
+	if len(sec.Users) > 0 {
+		return nil
+	}
+	if len(sec.Impersonation.SuperuserTokens) > 0 {
+		return errors.New("security.impersonation needs security.users: without them any token holder can claim a username")
+	}
+	if sec.ACLs.Enabled {
+		return errors.New("security.acls needs security.users: without them any token holder can claim a principal")
+	}
+	return nil
+}
+
+// errSessionExpired ends a connection that kept sending requests after
+// its SASL session expired instead of re-authenticating
+var errSessionExpired = errors.New("SASL session expired")
+
+// checkSession closes a connection whose SASL session has expired on any
+// request but the handshake and authentication that renew it
+func (s *KafkaServer) checkSession(conn net.Conn, header kafkaproto.RequestHeader, state *connState) error {
+	if state.sessionExpiry.IsZero() || time.Now().Before(state.sessionExpiry) ||
+		header.APIKey == kafkaproto.APIKeySaslHandshake || header.APIKey == kafkaproto.APIKeySaslAuthenticate {
+		return nil
+	}
+	audit("sasl_session_expired", "remote", conn.RemoteAddr().String(), "client", header.ClientID,
+		"principal", state.principal, "expired", state.sessionExpiry.Format(time.RFC3339))
+	return errSessionExpired
+}
+
+// checkReauthPrincipal refuses a re-authentication as anyone other than
+// the principal the connection already acts as
+func checkReauthPrincipal(state *connState, creds plainCredentials, principal string) error {
+	authID := ""
+	if principal != creds.authcID {
+		authID = creds.authcID
+	}
+	if principal != state.principal || authID != state.authID {
+		return fmt.Errorf("re-authentication must keep principal %s", state.principal)
+	}
+	return nil
+}
+
+// anonymousPrincipal is the principal of a connection that did not
+// authenticate
+const anonymousPrincipal = "User:ANONYMOUS"
+
+// errGroupAuthorizationFailed is reported to group members the ACLs do
+// not allow to use the group
+var errGroupAuthorizationFailed = errors.New("not authorized to access group")
+
+// principalOf returns the ACL principal a connection acts as
+func principalOf(state *connState) string {
+	if state.principal == "" {
+		return anonymousPrincipal
+	}
+	return "User:" + state.principal
+}
+
+// authorized reports whether the ACLs allow the connection operation on
+// the named resource, auditing denials
+func (s *KafkaServer) authorized(state *connState, operation, resourceType int8, name string) bool {
+	principal := principalOf(state)
+	if s.engine.Authorize(principal, state.host, operation, resourceType, name) {
+		return true
+	}
+	audit("acl_denied", "principal", principal, "host", state.host,
+		"operation", strconv.Itoa(int(operation)), "resource_type", strconv.Itoa(int(resourceType)), "resource", name)
+	return false
+}
+
+// mayCreateTopic reports whether the ACLs allow the connection to create
+// the topic: CREATE on the cluster covers every topic, CREATE on the topic
+// just that one
+func (s *KafkaServer) mayCreateTopic(state *connState, topic string) bool {
+	if s.engine.Authorize(principalOf(state), state.host, kafkaproto.AclOperationCreate, kafkaproto.AclResourceCluster, engine.ClusterResource) {
+		return true
+	}
+	return s.authorized(state, kafkaproto.AclOperationCreate, kafkaproto.AclResourceTopic, topic)
+}
+
+// audit logs a security-relevant event as key=value pairs under [audit]
+func audit(event string, kv ...string) {
+	var b strings.Builder
+	b.WriteString(event)
+	for i := 0; i+1 < len(kv); i += 2 {
+		fmt.Fprintf(&b, " %s=%q", kv[i], kv[i+1])
+	}
+	log.Printf("[audit] %s", b.String())
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+)
+
+// plainServer returns a server that only authenticates, with the security
+// config edit makes to the defaults
+func plainServer(t *testing.T, edit func(sec *config.SecurityConfig)) *KafkaServer {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Security.Enabled = true
+	cfg.Security.Token = "shared-token"
+	edit(&cfg.Security)
+	if err := checkPrincipalBinding(cfg.Security); err != nil {
+		t.Fatalf("security config: %v", err)
+	}
+	return &KafkaServer{config: cfg, credentials: NewCredentials(cfg.Security)}
+}
+
+func TestPlainUsersBindPrincipals(t *testing.T) {
+	s := plainServer(t, func(sec *config.SecurityConfig) {
+		sec.Users = map[string]string{"alice": "alice-pw", "admin": "admin-pw"}
+	})
+
+	principal, err := s.authenticatePlain(plainCredentials{authcID: "alice", password: "alice-pw"})
+	if err != nil || principal != "alice" {
+		t.Fatalf("alice with her password: principal %q, err %v; want alice", principal, err)
+	}
+	for _, creds := range []plainCredentials{
+		{authcID: "admin", password: "alice-pw"},
+		{authcID: "admin", password: "shared-token"},
+		{authcID: "nobody", password: "shared-token"},
+		{authzID: "admin", authcID: "alice", password: "alice-pw"},
+	} {
+		if principal, err := s.authenticatePlain(creds); err == nil {
+			t.Errorf("authcid %q authzid %q: authenticated as %q, want refused", creds.authcID, creds.authzID, principal)
+		}
+	}
+}
+
+func TestPlainImpersonationAllowlist(t *testing.T) {
+	s := plainServer(t, func(sec *config.SecurityConfig) {
+		sec.Users = map[string]string{"alice": "alice-pw"}
+		sec.Impersonation.SuperuserTokens = []string{"super-token"}
+		sec.Impersonation.Allow = []string{"alice"}
+	})
+
+	principal, err := s.authenticatePlain(plainCredentials{authzID: "alice", authcID: "ops", password: "super-token"})
+	if err != nil || principal != "alice" {
+		t.Fatalf("impersonating alice: principal %q, err %v; want alice", principal, err)
+	}
+	// A super-user token names no one, so acting as its username is
+	// impersonation too
+	for _, creds := range []plainCredentials{
+		{authzID: "admin", authcID: "ops", password: "super-token"},
+		{authcID: "admin", password: "super-token"},
+	} {
+		if principal, err := s.authenticatePlain(creds); err == nil {
+			t.Errorf("authcid %q authzid %q: authenticated as %q, want refused", creds.authcID, creds.authzID, principal)
+		}
+	}
+}
+
+func TestImpersonationNeedsUsers(t *testing.T) {
+	sec := config.Default().Security
+	sec.Impersonation.SuperuserTokens = []string{"super-token"}
+	if checkPrincipalBinding(sec) == nil {
+		t.Error("impersonation without security.users accepted")
+	}
+}
+
+func TestACLPrincipalNotClaimedWithToken(t *testing.T) {
+	s := plainServer(t, func(sec *config.SecurityConfig) {
+		sec.Users = map[string]string{"alice": "alice-pw", "admin": "admin-pw"}
+		sec.ACLs.Enabled = true
+		sec.ACLs.SuperUsers = []string{"User:admin"}
+	})
+
+	// The shared token, or alice's password, must not make anyone admin
+	for _, creds := range []plainCredentials{
+		{authcID: "admin", password: "shared-token"},
+		{authcID: "admin", password: "alice-pw"},
+		{authzID: "admin", authcID: "alice", password: "alice-pw"},
+	} {
+		if principal, err := s.authenticatePlain(creds); err == nil {
+			t.Errorf("authcid %q authzid %q: authenticated as %q, want refused", creds.authcID, creds.authzID, principal)
+		}
+	}
+	principal, err := s.authenticatePlain(plainCredentials{authcID: "alice", password: "alice-pw"})
+	if err != nil {
+		t.Fatalf("alice with her password: %v", err)
+	}
+	if got := principalOf(&connState{principal: principal}); got != "User:alice" {
+		t.Errorf("alice acts as %q, want User:alice", got)
+	}
+}
+
+func TestACLsNeedUsers(t *testing.T) {
+	sec := config.Default().Security
+	sec.Enabled = true
+	sec.Token = "shared-token"
+	sec.ACLs.Enabled = true
+	if checkPrincipalBinding(sec) == nil {
+		t.Error("ACLs without security.users accepted")
+	}
+}
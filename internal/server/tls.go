@@ -0,0 +1,244 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+)
+
+// ============================================================================
+// TLS
+//
+// Both listeners serve TLS with security.tls.cert_file and key_file. For
+// local testing of clients that insist on SSL, security.tls.auto generates
+// a CA and a server certificate signed by it under <data_dir>/tls on first
+// start, reissuing the server certificate when it nears expiry or the
+// names it must cover change. Clients trust the CA, downloaded from
+// /api/ca.pem, and keep trusting it across restarts.
+// ============================================================================
+
+// Files security.tls.auto keeps in <data_dir>/tls
+const (
+	autoTLSDir     = "tls"
+	autoCAFile     = "ca.pem"
+	autoCAKeyFile  = "ca-key.pem"
+	autoCertFile   = "server.pem"
+	autoKeyFile    = "server-key.pem"
+	autoCAValidity = 10 * 365 * 24 * time.Hour
+	autoValidity   = 2 * 365 * 24 * time.Hour
+	autoRenewal    = 30 * 24 * time.Hour // reissue a server certificate this close to expiry
+)
+
+// PrepareAutoTLS generates the certificates for security.tls.auto where
+// missing and points cfg at them. It does nothing unless auto is set.
+func PrepareAutoTLS(cfg *config.Config) error {
+	tc := &cfg.Security.TLS
+	if !tc.Auto {
+		return nil
+	}
+	dir := filepath.Join(cfg.Storage.DataDir, autoTLSDir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	ca, caKey, err := loadOrCreateCA(dir)
+	if err != nil {
+		return fmt.Errorf("tls ca: %w", err)
+	}
+	hosts := autoTLSHosts(cfg)
+	certPath, keyPath := filepath.Join(dir, autoCertFile), filepath.Join(dir, autoKeyFile)
+	if reason := reissueReason(certPath, ca, hosts); reason != "" {
+		if err := issueServerCert(certPath, keyPath, ca, caKey, hosts); err != nil {
+			return fmt.Errorf("tls server certificate: %w", err)
+		}
+		log.Printf("[tls] issued server certificate for %v (%s)", hosts, reason)
+	}
+
+	tc.Enabled = true
+	tc.CertFile, tc.KeyFile = certPath, keyPath
+	return nil
+}
+
+// autoTLSHosts lists the names and addresses the server certificate must
+// cover: loopback, this host, the listen addresses and security.tls.hosts
+func autoTLSHosts(cfg *config.Config) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if name, err := os.Hostname(); err == nil && name != "" {
+		hosts = append(hosts, name)
+	}
+	for _, addr := range []string{cfg.Server.KafkaAddr, cfg.Server.HTTPAddr} {
+		host, _, err := net.SplitHostPort(addr)
+		if ip := net.ParseIP(host); err == nil && host != "" && (ip == nil || !ip.IsUnspecified()) {
+			hosts = append(hosts, host)
+		}
+	}
+	hosts = append(hosts, cfg.Security.TLS.Hosts...)
+	slices.Sort(hosts)
+	return slices.Compact(hosts)
+}
+
+// loadOrCreateCA reads the CA from dir, generating it on first start
+func loadOrCreateCA(dir string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certPath, keyPath := filepath.Join(dir, autoCAFile), filepath.Join(dir, autoCAKeyFile)
+	if _, err := os.Stat(certPath); err == nil {
+		pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, nil, fmt.Errorf("%s: not an ECDSA key", keyPath)
+		}
+		ca, err := x509.ParseCertificate(pair.Certificate[0])
+		return ca, key, err
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          randomSerial(),
+		Subject:               pkix.Name{CommonName: "Monolog local CA", Organization: []string{"Monolog"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(autoCAValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := writeKeyPair(certPath, keyPath, der, key); err != nil {
+		return nil, nil, err
+	}
+	log.Printf("[tls] generated CA in %s", certPath)
+	ca, err := x509.ParseCertificate(der)
+	return ca, key, err
+}
+
+// reissueReason says why the server certificate at path must be issued
+// again, or returns "" if it is still good
+func reissueReason(path string, ca *x509.Certificate, hosts []string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "none yet"
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return "unreadable"
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "unreadable"
+	}
+	if cert.CheckSignatureFrom(ca) != nil {
+		return "signed by another CA"
+	}
+	if time.Until(cert.NotAfter) < autoRenewal {
+		return "expiring"
+	}
+	for _, h := range hosts {
+		if cert.VerifyHostname(h) != nil {
+			return "hosts changed"
+		}
+	}
+	return ""
+}
+
+// issueServerCert signs a server certificate for hosts with the CA
+func issueServerCert(certPath, keyPath string, ca *x509.Certificate, caKey *ecdsa.PrivateKey, hosts []string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: randomSerial(),
+		Subject:      pkix.Name{CommonName: "localhost", Organization: []string{"Monolog"}},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(autoValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+	return writeKeyPair(certPath, keyPath, der, key)
+}
+
+func writeKeyPair(certPath, keyPath string, der []byte, key *ecdsa.PrivateKey) error {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		return err
+	}
+	return os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644)
+}
+
+func randomSerial() *big.Int {
+	serial, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	return serial
+}
+
+// LoadTLS returns the listeners' TLS config, or nil when TLS is off
+func LoadTLS(tc config.TLSConfig) (*tls.Config, error) {
+	if !tc.Enabled {
+		return nil, nil
+	}
+	if tc.CertFile == "" || tc.KeyFile == "" {
+		return nil, fmt.Errorf("tls: cert_file and key_file are required unless auto is set")
+	}
+	cert, err := tls.LoadX509KeyPair(tc.CertFile, tc.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+}
+
+// handleCA serves the CA security.tls.auto generated, for clients to trust
+func (s *HTTPServer) handleCA(w http.ResponseWriter, r *http.Request) {
+	if !s.config.Security.TLS.Auto {
+		http.Error(w, "no generated CA: security.tls.auto is off", http.StatusNotFound)
+		return
+	}
+	data, err := os.ReadFile(filepath.Join(s.config.Storage.DataDir, autoTLSDir, autoCAFile))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.Header().Set("Content-Disposition", `attachment; filename="monolog-ca.pem"`)
+	w.Write(data)
+}
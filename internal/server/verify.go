@@ -0,0 +1,110 @@
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/engine"
+)
+
+// parseMessageMatch reads ?key=, ?value= and ?value_hash= (hex SHA-256).
+// A parameter that is absent matches anything; ?key= with no value
+// matches an empty key.
+func parseMessageMatch(q url.Values) (engine.MessageMatch, error) {
+	var m engine.MessageMatch
+	if q.Has("key") {
+		key := q.Get("key")
+		m.Key = &key
+	}
+	if q.Has("value") {
+		value := q.Get("value")
+		m.Value = &value
+	}
+	if v := q.Get("value_hash"); v != "" {
+		if b, err := hex.DecodeString(v); err != nil || len(b) != 32 {
+			return m, errInvalidValueHash
+		}
+		m.ValueSHA256 = v
+	}
+	return m, nil
+}
+
+var errInvalidValueHash = errors.New("value_hash must be a hex SHA-256")
+
+// handleContains reports whether a message matching ?key=, ?value= or
+// ?value_hash= was appended since ?since= (RFC 3339 or unix millis,
+// default the beginning of the log)
+func (s *HTTPServer) handleContains(w http.ResponseWriter, r *http.Request, topicName string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	match, err := parseMessageMatch(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	since, err := parseTimeParam(q.Get("since"), time.UnixMilli(0))
+	if err != nil {
+		http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	msg, scanned, err := s.engine.FindMessage(r.Context(), topicName, match, since)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	result := map[string]interface{}{
+		"found":   msg != nil,
+		"scanned": scanned,
+	}
+	if msg != nil {
+		result["offset"] = msg.Offset
+		result["timestamp"] = msg.Timestamp.UnixMilli()
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleCount counts the messages appended within ?from= and ?to=, by
+// default all of them, optionally only those matching ?key=, ?value= or
+// ?value_hash=
+func (s *HTTPServer) handleCount(w http.ResponseWriter, r *http.Request, topicName string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	match, err := parseMessageMatch(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := parseTimeParam(q.Get("from"), time.UnixMilli(0))
+	if err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseTimeParam(q.Get("to"), time.UnixMilli(math.MaxInt64))
+	if err != nil {
+		http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !to.After(from) {
+		http.Error(w, "to must be after from", http.StatusBadRequest)
+		return
+	}
+
+	count, err := s.engine.CountMessages(r.Context(), topicName, match, from, to)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"count": count})
+}
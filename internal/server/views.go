@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+	sqlitestore "github.com/rizkyandriawan/monolog/internal/store"
+)
+
+// handleViews serves the materialized views:
+// GET /api/views lists them, POST /api/views creates one,
+// GET and DELETE /api/views/{name} show and drop one, and
+// GET or POST /api/views/{name}/query runs read-only SQL over them, taken
+// from ?sql= or a {"sql": ...} body.
+func (s *HTTPServer) handleViews(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	views := s.engine.Views()
+	name, sub, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/views"), "/"), "/")
+
+	switch {
+	case sub == "query" && (r.Method == http.MethodGet || r.Method == http.MethodPost):
+		s.handleViewQuery(w, r, name)
+
+	case sub != "":
+		http.Error(w, "Not found", http.StatusNotFound)
+
+	case r.Method == http.MethodGet && name == "":
+		json.NewEncoder(w).Encode(views.List())
+
+	case r.Method == http.MethodPost && name == "":
+		var req config.ViewConfig
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		status, err := views.Create(req)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(status)
+
+	case r.Method == http.MethodGet:
+		status, err := views.Get(name)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		json.NewEncoder(w).Encode(status)
+
+	case r.Method == http.MethodDelete && name != "":
+		if err := views.Drop(name); err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *HTTPServer) handleViewQuery(w http.ResponseWriter, r *http.Request, name string) {
+	q := r.URL.Query()
+	query := q.Get("sql")
+	if r.Method == http.MethodPost {
+		var req struct {
+			SQL string `json:"sql"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query = req.SQL
+	}
+	limit := 1000
+	if v := q.Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 || limit > sqlitestore.MaxViewRows {
+			http.Error(w, fmt.Sprintf("limit must be 1-%d", sqlitestore.MaxViewRows), http.StatusBadRequest)
+			return
+		}
+	}
+
+	rows, err := s.engine.Views().Query(r.Context(), name, query, limit)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	json.NewEncoder(w).Encode(rows)
+}
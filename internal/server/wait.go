@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/engine"
+)
+
+// Default and longest time a /wait long-poll is held open
+const (
+	defaultWaitTimeout = 30 * time.Second
+	maxWaitTimeout     = time.Hour
+)
+
+// handleWait long-polls until a topic's high watermark reaches offset, or
+// with group set, until the group has consumed everything on the topic:
+//
+//	GET /api/wait?topic=orders&offset=1000&timeout=60s
+//	GET /api/wait?topic=orders&group=billing
+//
+// It answers 200 once the condition is met and 408 if timeout passes
+// first, both with the condition's status, so `curl -f` can gate a CI step.
+func (s *HTTPServer) handleWait(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	c := engine.WaitCondition{Topic: q.Get("topic"), Group: q.Get("group")}
+	if v := q.Get("offset"); v != "" {
+		offset, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+		c.Offset = offset
+	}
+	if err := c.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	timeout := defaultWaitTimeout
+	if v := q.Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > maxWaitTimeout {
+			http.Error(w, "timeout must be a positive duration up to 1h", http.StatusBadRequest)
+			return
+		}
+		timeout = d
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	st, err := s.engine.WaitFor(ctx, c)
+	if err != nil {
+		if r.Context().Err() != nil {
+			return // client went away
+		}
+		w.WriteHeader(http.StatusRequestTimeout)
+	}
+	json.NewEncoder(w).Encode(st)
+}
+
+// handleWaitHooks registers webhooks called when a wait condition is met,
+// for pipelines that would rather be called back than hold a request open.
+// POST takes {"topic": "orders", "group": "billing", "webhook": "http://ci/hook",
+// "timeout": "10m"}; the webhook gets {"id", "status": "met" or "expired",
+// "wait"}. GET lists hooks still waiting; DELETE /wait/hooks/{id} cancels one.
+func (s *HTTPServer) handleWaitHooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	hooks := s.engine.WaitHooks()
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/wait/hooks"), "/")
+
+	switch {
+	case r.Method == http.MethodGet && id == "":
+		json.NewEncoder(w).Encode(hooks.List())
+
+	case r.Method == http.MethodPost && id == "":
+		var req struct {
+			engine.WaitCondition
+			Webhook string `json:"webhook"`
+			Timeout string `json:"timeout"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		timeout := min(time.Hour, s.config.Waits.MaxTimeout)
+		if req.Timeout != "" {
+			d, err := time.ParseDuration(req.Timeout)
+			if err != nil {
+				http.Error(w, "timeout: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			timeout = d
+		}
+		hook, err := hooks.Add(req.WaitCondition, req.Webhook, timeout)
+		if err != nil {
+			status := http.StatusBadRequest
+			switch {
+			case errors.Is(err, engine.ErrWebhookNotAllowed):
+				status = http.StatusForbidden
+			case errors.Is(err, engine.ErrTooManyWaitHooks):
+				status = http.StatusTooManyRequests
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(hook)
+
+	case r.Method == http.MethodDelete && id != "":
+		if !hooks.Remove(id) {
+			http.Error(w, "wait hook not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// watchKeepAlive is how often an idle watch stream sends a comment so
+// proxies don't close it
+const watchKeepAlive = 15 * time.Second
+
+// handleWatch streams admin object events (topics, groups, members and
+// runtime config) as Server-Sent Events. ?types= limits the stream to a
+// comma-separated list of event types or families ("topic", "group", ...).
+// Reconnects with Last-Event-ID replay recent events they missed.
+func (s *HTTPServer) handleWatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	var types []string
+	if v := r.URL.Query().Get("types"); v != "" {
+		types = strings.Split(v, ",")
+	}
+	var after uint64
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		after, _ = strconv.ParseUint(v, 10, 64)
+	}
+
+	events, cancel := s.engine.Watch(after)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(watchKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+
+		case ev, ok := <-events:
+			if !ok {
+				// Too slow to keep up: the client should reconnect and resync
+				fmt.Fprint(w, "event: overflow\ndata: {}\n\n")
+				flusher.Flush()
+				return
+			}
+			if !watchMatches(types, ev.Type) {
+				continue
+			}
+			data, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.Seq, ev.Type, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// watchMatches reports whether an event type passes the ?types= filter.
+// A filter entry matches its exact type or, without a dot, a whole family.
+func watchMatches(types []string, eventType string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	family, _, _ := strings.Cut(eventType, ".")
+	for _, t := range types {
+		t = strings.TrimSpace(t)
+		if t == eventType || t == family {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// storageErr wraps a database failure in a StorageError. Context errors
+// are the caller giving up, not the storage failing, and pass through.
+func storageErr(op string, err error) error {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return &store.StorageError{Op: op, Err: err}
+}
+
+// Helpers wrapping the sentinels with the name that was not found
+func topicNotFound(topic string) error { return fmt.Errorf("%w: %s", store.ErrTopicNotFound, topic) }
+func topicExists(topic string) error   { return fmt.Errorf("%w: %s", store.ErrTopicExists, topic) }
+func groupNotFound(groupID string) error {
+	return fmt.Errorf("%w: %s", store.ErrGroupNotFound, groupID)
+}
+func memberNotFound(memberID string) error {
+	return fmt.Errorf("%w: %s", store.ErrMemberNotFound, memberID)
+}
@@ -0,0 +1,209 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// ============================================================================
+// Offline inspection
+//
+// Inspector reads a SQLite data directory without opening it as a store: it
+// takes no data directory lock, runs no migrations and starts no leader
+// epoch, so it is safe to point at the data of a crashed or hung broker.
+// ============================================================================
+
+// Inspector reads a data directory read-only
+type Inspector struct {
+	db *sql.DB
+
+	// Immutable is set when the database could only be opened ignoring
+	// SQLite's locks and write-ahead log, so writes not yet checkpointed
+	// into monolog.db are missing
+	Immutable bool
+}
+
+// InspectTopic summarizes one topic as stored on disk
+type InspectTopic struct {
+	Name         string    `json:"name"`
+	CreatedAt    time.Time `json:"created_at"`
+	LatestOffset int64     `json:"latest_offset"` // as recorded in the topics table
+	Earliest     int64     `json:"earliest_offset"`
+	MaxStored    int64     `json:"max_stored_offset"` // highest offset actually present
+	Rows         int64     `json:"rows"`              // stored rows (a row can hold a whole batch)
+	Messages     int64     `json:"messages"`
+	Bytes        int64     `json:"bytes"`
+	Orphaned     bool      `json:"orphaned,omitempty"` // messages without a topics row
+}
+
+// InspectOffset is one committed consumer group offset
+type InspectOffset struct {
+	Group     string `json:"group"`
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+}
+
+// OpenInspector opens the SQLite database in dataDir read-only
+func OpenInspector(dataDir string) (*Inspector, error) {
+	dbPath := filepath.Join(dataDir, "monolog.db")
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("open %s: %w", dbPath, err)
+	}
+
+	db, err := openReadOnly("file:" + dbPath + "?mode=ro&_busy_timeout=1000")
+	if err == nil {
+		return &Inspector{db: db}, nil
+	}
+
+	// Without write access to the -shm file, or with a lock left behind,
+	// read the main database file as it is
+	db, immErr := openReadOnly("file:" + dbPath + "?mode=ro&immutable=1")
+	if immErr != nil {
+		return nil, fmt.Errorf("open %s: %w", dbPath, err)
+	}
+	return &Inspector{db: db, Immutable: true}, nil
+}
+
+func openReadOnly(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM topics").Scan(&n); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Close closes the database
+func (i *Inspector) Close() error {
+	return i.db.Close()
+}
+
+// Topics summarizes every topic, including messages whose topic row is
+// missing
+func (i *Inspector) Topics(ctx context.Context) ([]InspectTopic, error) {
+	rows, err := i.db.QueryContext(ctx, `
+		SELECT t.name, t.created_at, t.latest_offset, 0,
+		       COALESCE(MIN(m.offset), -1), COALESCE(MAX(m.last_offset), -1), COUNT(m.offset),
+		       COALESCE(SUM(m.last_offset - m.offset + 1), 0), COALESCE(SUM(LENGTH(m.value)), 0)
+		FROM topics t LEFT JOIN messages m ON m.topic = t.name
+		GROUP BY t.name
+		UNION ALL
+		SELECT m.topic, 0, -1, 1,
+		       MIN(m.offset), MAX(m.last_offset), COUNT(*),
+		       SUM(m.last_offset - m.offset + 1), COALESCE(SUM(LENGTH(m.value)), 0)
+		FROM messages m WHERE m.topic NOT IN (SELECT name FROM topics)
+		GROUP BY m.topic
+		ORDER BY 1`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	topics := []InspectTopic{}
+	for rows.Next() {
+		var t InspectTopic
+		var createdAt int64
+		if err := rows.Scan(&t.Name, &createdAt, &t.LatestOffset, &t.Orphaned,
+			&t.Earliest, &t.MaxStored, &t.Rows, &t.Messages, &t.Bytes); err != nil {
+			return nil, err
+		}
+		if createdAt > 0 {
+			t.CreatedAt = time.UnixMilli(createdAt)
+		}
+		topics = append(topics, t)
+	}
+	return topics, rows.Err()
+}
+
+// GroupOffsets returns every committed consumer group offset. A database
+// not yet migrated to per-partition offsets has them all on partition 0.
+func (i *Inspector) GroupOffsets(ctx context.Context) ([]InspectOffset, error) {
+	partition := "0"
+	var n int
+	err := i.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM pragma_table_info('group_offsets') WHERE name = 'partition'").Scan(&n)
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		partition = "partition"
+	}
+	rows, err := i.db.QueryContext(ctx,
+		"SELECT group_id, topic, "+partition+", committed_offset FROM group_offsets ORDER BY group_id, topic, 3")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	offsets := []InspectOffset{}
+	for rows.Next() {
+		var o InspectOffset
+		if err := rows.Scan(&o.Group, &o.Topic, &o.Partition, &o.Offset); err != nil {
+			return nil, err
+		}
+		offsets = append(offsets, o)
+	}
+	return offsets, rows.Err()
+}
+
+// Metrics returns the metric samples recorded in [from, to). A database
+// written before metrics were recorded has none.
+func (i *Inspector) Metrics(ctx context.Context, from, to time.Time) ([]store.MetricSample, error) {
+	var n int
+	err := i.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'metrics'").Scan(&n)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return []store.MetricSample{}, nil
+	}
+	return queryMetrics(ctx, i.db, from, to)
+}
+
+// Dump returns up to count stored rows of topic covering offsets from
+// fromOffset on. Unlike Read it reports rows it cannot decode instead of
+// skipping them.
+func (i *Inspector) Dump(ctx context.Context, topic string, fromOffset int64, count int) ([]store.Record, error) {
+	rows, err := i.db.QueryContext(ctx,
+		`SELECT offset, last_offset, timestamp, key, value, codec
+		 FROM messages
+		 WHERE topic = ? AND last_offset >= ?
+		 ORDER BY offset ASC
+		 LIMIT ?`,
+		topic, fromOffset, count,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []store.Record{}
+	for rows.Next() {
+		var rec store.Record
+		if err := rows.Scan(&rec.Offset, &rec.LastOffset, &rec.Timestamp, &rec.Key, &rec.Value, &rec.Codec); err != nil {
+			return records, fmt.Errorf("row after offset %d: %w", lastOffset(records, fromOffset), err)
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+func lastOffset(records []store.Record, def int64) int64 {
+	if len(records) == 0 {
+		return def
+	}
+	return records[len(records)-1].LastOffset
+}
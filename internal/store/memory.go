@@ -0,0 +1,38 @@
+package store
+
+import (
+	"context"
+
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// Memory reports the page cache SQLite may fill and, for an in-memory
+// database, the size of the database itself
+func (s *SQLiteTopicStore) Memory(ctx context.Context) (store.StoreMemory, error) {
+	var cacheSize, pageSize, pageCount int64
+	db := s.db.DB()
+	if err := db.QueryRowContext(ctx, "PRAGMA cache_size").Scan(&cacheSize); err != nil {
+		return store.StoreMemory{}, storageErr("read cache size", err)
+	}
+	if err := db.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize); err != nil {
+		return store.StoreMemory{}, storageErr("read page size", err)
+	}
+
+	m := store.StoreMemory{}
+	// A negative cache_size is in KiB, a positive one in pages
+	if cacheSize < 0 {
+		m.CacheLimit = -cacheSize << 10
+	} else {
+		m.CacheLimit = cacheSize * pageSize
+	}
+	if s.db.inMemory {
+		if err := db.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pageCount); err != nil {
+			return store.StoreMemory{}, storageErr("read page count", err)
+		}
+		m.Data = pageCount * pageSize
+	}
+	s.mu.RLock()
+	m.Topics = len(s.topics)
+	s.mu.RUnlock()
+	return m, nil
+}
@@ -0,0 +1,329 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// ============================================================================
+// Cache refresh
+//
+// The SQLite stores keep topics and groups in memory and write through to
+// the database, so the two only drift when something else writes to the
+// database: a restore into the live file, a migration run in place, or a
+// sqlite3 shell. SQLite bumps PRAGMA data_version whenever another
+// connection commits, which makes Changed a single cheap query, and a
+// restore that replaces the file shows in the file's identity; Refresh
+// then resyncs the cache from the tables.
+// ============================================================================
+
+// dbState is what the cache was last synced with: the database's
+// data_version and, on disk, the database file. A restore that replaces
+// the file, or rewrites it without going through SQLite, leaves
+// data_version alone but not the file's identity or size.
+type dbState struct {
+	version int64
+	file    os.FileInfo // nil in memory
+}
+
+func (a dbState) same(b dbState) bool {
+	if a.version != b.version || (a.file == nil) != (b.file == nil) {
+		return false
+	}
+	return a.file == nil || os.SameFile(a.file, b.file) && a.file.Size() == b.file.Size()
+}
+
+// state returns the database's current state. The pool holds a single
+// connection, so our own writes never change data_version; they can grow
+// the file, which costs a refresh that finds nothing to do.
+func (d *SQLiteDB) state(ctx context.Context) (dbState, error) {
+	var st dbState
+	if d.path != "" {
+		fi, err := os.Stat(d.path)
+		if err != nil {
+			return st, err
+		}
+		d.reopenIfReplaced(fi)
+		st.file = fi
+	}
+	err := d.db.QueryRowContext(ctx, "PRAGMA data_version").Scan(&st.version)
+	return st, err
+}
+
+// reopenIfReplaced drops the pool's connection when another file was put
+// in place of the database, so the next query opens that one
+func (d *SQLiteDB) reopenIfReplaced(fi os.FileInfo) {
+	d.fileMu.Lock()
+	defer d.fileMu.Unlock()
+	if d.file != nil && !os.SameFile(d.file, fi) {
+		log.Printf("[store] %s was replaced, reopening it", d.path)
+		d.db.SetMaxIdleConns(0)
+		d.db.SetMaxIdleConns(1)
+	}
+	d.file = fi
+}
+
+// Changed reports whether the database was written by someone else since
+// the cache was last synced, or whether topic metadata never loaded
+func (s *SQLiteTopicStore) Changed(ctx context.Context) (bool, error) {
+	st, err := s.db.state(ctx)
+	if err != nil {
+		return false, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return !st.same(s.seen) || !s.loaded, nil
+}
+
+// cachedTopic is a cached topic as it stood before a refresh read the
+// database
+type cachedTopic struct {
+	meta   store.TopicMeta
+	epochs []store.LeaderEpoch
+}
+
+// Refresh resyncs cached topics, latest offsets, configs and leader
+// epochs with the database. Topic metadata that failed to load at startup
+// is loaded here, which lets a broker that started on a busy database warm
+// up in the background.
+//
+// The tables are read without holding the store lock. A topic this store
+// wrote to in the meantime keeps its cached entry, which is newer than
+// what was read.
+func (s *SQLiteTopicStore) Refresh(ctx context.Context) (*store.RefreshReport, error) {
+	// Read the state before the tables, so a write landing mid-refresh
+	// is picked up by the next check
+	st, err := s.db.state(ctx)
+	if err != nil {
+		return nil, storageErr("refresh", err)
+	}
+
+	s.mu.RLock()
+	loaded := s.loaded
+	before := make(map[string]cachedTopic, len(s.topics))
+	for name, meta := range s.topics {
+		before[name] = cachedTopic{meta: *meta, epochs: s.epochs[name]}
+	}
+	s.mu.RUnlock()
+
+	report := &store.RefreshReport{}
+	if !loaded {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if !s.loaded {
+			if err := s.loadTopics(); err != nil {
+				return nil, fmt.Errorf("topic metadata not loaded: %w", err)
+			}
+			for name := range s.topics {
+				report.Added = append(report.Added, name)
+			}
+			sort.Strings(report.Added)
+			s.version++
+		}
+		s.seen = st
+		return report, nil
+	}
+
+	stored, err := s.readTopics(ctx)
+	if err != nil {
+		return nil, storageErr("refresh", err)
+	}
+	history, err := s.readEpochs(ctx)
+	if err != nil {
+		return nil, storageErr("refresh", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// untouched reports whether a topic is cached as it was before the
+	// read; a topic created, written or deleted since is left as it is
+	untouched := func(name string) bool {
+		was, ok := before[name]
+		cached, cachedOK := s.topics[name]
+		if !ok || !cachedOK {
+			return ok == cachedOK
+		}
+		return cached.LatestOffset == was.meta.LatestOffset && cached.LogStartOffset == was.meta.LogStartOffset &&
+			maps.Equal(cached.Config, was.meta.Config) && slices.Equal(s.epochs[name], was.epochs)
+	}
+
+	for name, meta := range stored {
+		if !untouched(name) {
+			continue
+		}
+		cached, ok := s.topics[name]
+		if !ok {
+			if h := history[name]; len(h) > 0 {
+				meta.LeaderEpoch = h[len(h)-1].Epoch
+			}
+			s.topics[name] = meta
+			s.epochs[name] = history[name]
+			report.Added = append(report.Added, name)
+			continue
+		}
+
+		updated := false
+		if cached.LatestOffset != meta.LatestOffset {
+			cached.LatestOffset = meta.LatestOffset
+			updated = true
+		}
+		if cached.LogStartOffset != meta.LogStartOffset {
+			cached.LogStartOffset = meta.LogStartOffset
+			updated = true
+		}
+		if !maps.Equal(cached.Config, meta.Config) {
+			cached.Config = meta.Config
+			updated = true
+		}
+		if h := history[name]; len(h) > 0 && !slices.Equal(h, s.epochs[name]) {
+			s.epochs[name] = h
+			cached.LeaderEpoch = h[len(h)-1].Epoch
+			updated = true
+		}
+		if updated {
+			report.Updated = append(report.Updated, name)
+		}
+	}
+	for name := range before {
+		if _, ok := stored[name]; !ok && untouched(name) {
+			delete(s.topics, name)
+			delete(s.epochs, name)
+			report.Removed = append(report.Removed, name)
+		}
+	}
+
+	sortReport(report)
+	if report.Changed() {
+		s.version++
+	}
+	s.seen = st
+	return report, nil
+}
+
+// Changed reports whether the database was written by someone else since
+// the cache was last synced
+func (s *SQLiteGroupStore) Changed(ctx context.Context) (bool, error) {
+	st, err := s.db.state(ctx)
+	if err != nil {
+		return false, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return !st.same(s.seen), nil
+}
+
+// Refresh resyncs cached groups, members and committed offsets with the
+// database. Groups that did not change keep their cached entry. As with
+// topics, the tables are read without the store lock, and a group this
+// store changed in the meantime keeps its cached entry.
+func (s *SQLiteGroupStore) Refresh(ctx context.Context) (*store.RefreshReport, error) {
+	st, err := s.db.state(ctx)
+	if err != nil {
+		return nil, storageErr("refresh", err)
+	}
+
+	s.mu.RLock()
+	before := make(map[string]*store.Group, len(s.groups))
+	for id, group := range s.groups {
+		before[id] = cloneGroup(group)
+	}
+	s.mu.RUnlock()
+
+	stored, err := s.readGroups(ctx)
+	if err != nil {
+		return nil, storageErr("refresh", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	untouched := func(id string) bool {
+		was, ok := before[id]
+		cached, cachedOK := s.groups[id]
+		if !ok || !cachedOK {
+			return ok == cachedOK
+		}
+		return sameGroup(cached, was)
+	}
+
+	report := &store.RefreshReport{}
+	for id, group := range stored {
+		if !untouched(id) {
+			continue
+		}
+		cached, ok := s.groups[id]
+		switch {
+		case !ok:
+			s.groups[id] = group
+			report.Added = append(report.Added, id)
+		case !sameGroup(cached, group):
+			s.groups[id] = group
+			report.Updated = append(report.Updated, id)
+		}
+	}
+	for id := range before {
+		if _, ok := stored[id]; !ok && untouched(id) {
+			delete(s.groups, id)
+			report.Removed = append(report.Removed, id)
+		}
+	}
+
+	sortReport(report)
+	if report.Changed() {
+		s.version++
+	}
+	s.seen = st
+	return report, nil
+}
+
+// sameGroup compares what the database stores of two groups. Heartbeat
+// times are left out: they are written on every heartbeat and only ever
+// move forward.
+func sameGroup(a, b *store.Group) bool {
+	if a.State != b.State || a.Generation != b.Generation || a.LeaderID != b.LeaderID || a.Protocol != b.Protocol {
+		return false
+	}
+	if len(a.Members) != len(b.Members) {
+		return false
+	}
+	for id, m := range a.Members {
+		other, ok := b.Members[id]
+		if !ok || m.ClientID != other.ClientID || m.SessionTimeoutMs != other.SessionTimeoutMs ||
+			!slices.Equal(m.Assignment, other.Assignment) {
+			return false
+		}
+	}
+	if len(a.Offsets) != len(b.Offsets) {
+		return false
+	}
+	for topic, offset := range a.Offsets {
+		if other, ok := b.Offsets[topic]; !ok || offset != other {
+			return false
+		}
+	}
+	return true
+}
+
+// cloneGroup copies a group deeply enough for sameGroup to compare it
+// with the cached group after that changed
+func cloneGroup(g *store.Group) *store.Group {
+	c := *g
+	c.Members = maps.Clone(g.Members)
+	c.Offsets = maps.Clone(g.Offsets)
+	return &c
+}
+
+// sortReport orders a report's names
+func sortReport(r *store.RefreshReport) {
+	sort.Strings(r.Added)
+	sort.Strings(r.Removed)
+	sort.Strings(r.Updated)
+}
@@ -1,25 +1,64 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"maps"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
+	"github.com/rizkyandriawan/monolog/pkg/store"
 )
 
+func init() {
+	store.Register("sqlite", openSQLiteBackend("disk"))
+	store.Register("sqlite:disk", openSQLiteBackend("disk"))
+	store.RegisterInMemory("sqlite:memory", openSQLiteBackend("memory"))
+}
+
+// IsStorageFull reports whether err means the database could not grow
+// because the disk is full (SQLITE_FULL)
+func IsStorageFull(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrFull
+}
+
+func openSQLiteBackend(mode string) store.Factory {
+	return func(cfg store.Config) (*store.Backend, error) {
+		db, err := OpenSQLite(cfg.DataDir, mode, cfg.CacheSize)
+		if err != nil {
+			return nil, err
+		}
+		return &store.Backend{
+			Topics: NewSQLiteTopicStore(db),
+			Groups: NewSQLiteGroupStore(db),
+			Close:  db.Close,
+		}, nil
+	}
+}
+
 // SQLiteDB wraps SQLite database
 type SQLiteDB struct {
 	db       *sql.DB
 	inMemory bool
+	path     string // database file, "" in memory
+
+	fileMu sync.Mutex
+	file   os.FileInfo // the file the connection has open
 }
 
 // OpenSQLite opens or creates a SQLite database
 // mode can be "memory" or "disk" (default)
-func OpenSQLite(dataDir string, mode string) (*SQLiteDB, error) {
+// cacheSize caps SQLite's page cache in bytes; 0 keeps SQLite's default
+func OpenSQLite(dataDir string, mode string, cacheSize int64) (*SQLiteDB, error) {
 	var dsn string
 	var inMemory bool
 
@@ -37,6 +76,10 @@ func OpenSQLite(dataDir string, mode string) (*SQLiteDB, error) {
 		dsn = dbPath + "?_journal_mode=WAL&_synchronous=FULL&_busy_timeout=5000"
 		inMemory = false
 	}
+	if cacheSize > 0 {
+		// Negative sizes are in KiB rather than pages
+		dsn += fmt.Sprintf("&_cache_size=-%d", max(cacheSize>>10, 1))
+	}
 
 	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
@@ -52,6 +95,10 @@ func OpenSQLite(dataDir string, mode string) (*SQLiteDB, error) {
 		db.Close()
 		return nil, err
 	}
+	if !inMemory {
+		s.path = filepath.Join(dataDir, "monolog.db")
+		s.file, _ = os.Stat(s.path)
+	}
 
 	return s, nil
 }
@@ -76,6 +123,59 @@ func (s *SQLiteDB) initSchema() error {
 	);
 	CREATE INDEX IF NOT EXISTS idx_messages_topic_ts ON messages(topic, timestamp);
 
+	CREATE TABLE IF NOT EXISTS aborted_txns (
+		topic TEXT NOT NULL,
+		producer_id INTEGER NOT NULL,
+		first_offset INTEGER NOT NULL,
+		last_offset INTEGER NOT NULL,
+		PRIMARY KEY (topic, producer_id, first_offset)
+	);
+	CREATE INDEX IF NOT EXISTS idx_aborted_txns_last ON aborted_txns(topic, last_offset);
+
+	CREATE TABLE IF NOT EXISTS txn_states (
+		transactional_id TEXT PRIMARY KEY,
+		producer_id INTEGER NOT NULL,
+		producer_epoch INTEGER NOT NULL,
+		state TEXT NOT NULL,
+		updated_at INTEGER NOT NULL,
+		data BLOB NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS metrics (
+		ts INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		label TEXT NOT NULL DEFAULT '',
+		value REAL NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_metrics_ts ON metrics(ts);
+
+	CREATE TABLE IF NOT EXISTS acls (
+		resource_type INTEGER NOT NULL,
+		resource_name TEXT NOT NULL,
+		pattern_type INTEGER NOT NULL,
+		principal TEXT NOT NULL,
+		host TEXT NOT NULL,
+		operation INTEGER NOT NULL,
+		permission INTEGER NOT NULL,
+		PRIMARY KEY (resource_type, resource_name, pattern_type, principal, host, operation, permission)
+	);
+
+	CREATE TABLE IF NOT EXISTS leader_epochs (
+		topic TEXT NOT NULL,
+		epoch INTEGER NOT NULL,
+		start_offset INTEGER NOT NULL,
+		PRIMARY KEY (topic, epoch)
+	);
+
+	CREATE TABLE IF NOT EXISTS dictionaries (
+		topic TEXT NOT NULL,
+		id INTEGER NOT NULL,
+		created_at INTEGER NOT NULL,
+		samples INTEGER NOT NULL,
+		data BLOB NOT NULL,
+		PRIMARY KEY (topic, id)
+	);
+
 	CREATE TABLE IF NOT EXISTS groups (
 		id TEXT PRIMARY KEY,
 		state TEXT NOT NULL DEFAULT 'empty',
@@ -100,15 +200,141 @@ func (s *SQLiteDB) initSchema() error {
 	CREATE TABLE IF NOT EXISTS group_offsets (
 		group_id TEXT NOT NULL,
 		topic TEXT NOT NULL,
+		partition INTEGER NOT NULL DEFAULT 0,
 		committed_offset INTEGER NOT NULL,
-		PRIMARY KEY (group_id, topic),
+		PRIMARY KEY (group_id, topic, partition),
 		FOREIGN KEY (group_id) REFERENCES groups(id) ON DELETE CASCADE
 	);
 	`
-	_, err := s.db.Exec(schema)
+	if _, err := s.db.Exec(schema); err != nil {
+		return err
+	}
+	return s.migrate()
+}
+
+// migrate adds columns introduced after a table was first created
+func (s *SQLiteDB) migrate() error {
+	if err := s.addColumn("group_members", "session_timeout_ms", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := s.addColumn("topics", "config", "TEXT NOT NULL DEFAULT '{}'"); err != nil {
+		return err
+	}
+	if err := s.addColumn("topics", "log_start_offset", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := s.addColumn("topics", "topic_id", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := s.addColumn("messages", "checksum", "INTEGER"); err != nil {
+		return err
+	}
+	if err := s.backfillTopicIDs(); err != nil {
+		return err
+	}
+	return s.migrateGroupOffsets()
+}
+
+// backfillTopicIDs gives topics created before topic IDs existed one
+func (s *SQLiteDB) backfillTopicIDs() error {
+	rows, err := s.db.Query("SELECT name FROM topics WHERE topic_id = ''")
+	if err != nil {
+		return err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if _, err := s.db.Exec("UPDATE topics SET topic_id = ? WHERE name = ? AND topic_id = ''", store.NewTopicID(), name); err != nil {
+			return fmt.Errorf("assign topic ID to %s: %w", name, err)
+		}
+	}
+	if len(names) > 0 {
+		log.Printf("[store] assigned topic IDs to %d existing topics", len(names))
+	}
+	return nil
+}
+
+// migrateGroupOffsets rebuilds a group_offsets table keyed by (group_id,
+// topic) into one keyed by partition too. Existing commits were all made
+// for partition 0.
+func (s *SQLiteDB) migrateGroupOffsets() error {
+	ok, err := s.hasColumn("group_offsets", "partition")
+	if err != nil || ok {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		`CREATE TABLE group_offsets_new (
+			group_id TEXT NOT NULL,
+			topic TEXT NOT NULL,
+			partition INTEGER NOT NULL DEFAULT 0,
+			committed_offset INTEGER NOT NULL,
+			PRIMARY KEY (group_id, topic, partition),
+			FOREIGN KEY (group_id) REFERENCES groups(id) ON DELETE CASCADE
+		)`,
+		`INSERT INTO group_offsets_new (group_id, topic, partition, committed_offset)
+			SELECT group_id, topic, 0, committed_offset FROM group_offsets`,
+		`DROP TABLE group_offsets`,
+		`ALTER TABLE group_offsets_new RENAME TO group_offsets`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("migrate group_offsets: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	log.Printf("[store] migrated group_offsets to per-partition offsets")
+	return nil
+}
+
+// addColumn adds a column to table unless it already exists
+func (s *SQLiteDB) addColumn(table, column, decl string) error {
+	ok, err := s.hasColumn(table, column)
+	if err != nil || ok {
+		return err
+	}
+	_, err = s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, decl))
 	return err
 }
 
+// hasColumn reports whether table has the named column
+func (s *SQLiteDB) hasColumn(table, column string) (bool, error) {
+	rows, err := s.db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
 func (s *SQLiteDB) Close() error {
 	return s.db.Close()
 }
@@ -126,63 +352,295 @@ func (s *SQLiteDB) IsInMemory() bool {
 // ============================================================================
 
 type SQLiteTopicStore struct {
-	db     *SQLiteDB
-	mu     sync.RWMutex
-	topics map[string]*TopicMeta // in-memory cache
+	db        *SQLiteDB
+	mu        sync.RWMutex
+	topics    map[string]*store.TopicMeta // in-memory cache
+	epochs    map[string][]store.LeaderEpoch
+	integrity store.IntegrityStats // updated atomically
+	version   uint64               // bumped on every change to the topic list or a latest offset
+	loaded    bool                 // topic metadata has been read from the database
+	seen      dbState              // database state the cache was last synced at
+	now       func() time.Time     // stamps appended messages
+
+	beforeCommit func(topic string) // called with s.mu held, nil unless set
+	afterCommit  func(topic string)
 }
 
 func NewSQLiteTopicStore(db *SQLiteDB) *SQLiteTopicStore {
 	ts := &SQLiteTopicStore{
 		db:     db,
-		topics: make(map[string]*TopicMeta),
+		topics: make(map[string]*store.TopicMeta),
+		epochs: make(map[string][]store.LeaderEpoch),
+		now:    time.Now,
+	}
+	if err := ts.loadTopics(); err != nil {
+		log.Printf("[store] failed to load topic metadata: %v", err)
 	}
-	ts.loadTopics()
+	ts.seen, _ = db.state(context.Background())
 	return ts
 }
 
-func (s *SQLiteTopicStore) loadTopics() {
-	rows, err := s.db.DB().Query("SELECT name, created_at, latest_offset FROM topics")
+// SetClock replaces the clock appended messages are stamped with. Call it
+// before the store is used.
+func (s *SQLiteTopicStore) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+// SetCommitHooks sets functions called with each appended topic just
+// before and just after the append commits. Call it before the store is
+// used.
+func (s *SQLiteTopicStore) SetCommitHooks(before, after func(topic string)) {
+	s.beforeCommit, s.afterCommit = before, after
+}
+
+// commitAppend commits an append's transaction, calling the commit hooks
+// for each topic it wrote to. Caller must hold s.mu.
+func (s *SQLiteTopicStore) commitAppend(tx *sql.Tx, topics ...string) error {
+	if s.beforeCommit != nil {
+		for _, topic := range topics {
+			s.beforeCommit(topic)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if s.afterCommit != nil {
+		for _, topic := range topics {
+			s.afterCommit(topic)
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteTopicStore) loadTopics() error {
+	topics, err := s.readTopics(context.Background())
 	if err != nil {
-		return
+		return err
+	}
+	for name, meta := range topics {
+		s.topics[name] = meta
+	}
+
+	if err := s.startEpochs(); err != nil {
+		return fmt.Errorf("leader epochs: %w", err)
+	}
+	s.loaded = true
+	return nil
+}
+
+// readTopics reads the topics table
+func (s *SQLiteTopicStore) readTopics(ctx context.Context) (map[string]*store.TopicMeta, error) {
+	rows, err := s.db.DB().QueryContext(ctx, "SELECT name, topic_id, created_at, latest_offset, log_start_offset, config FROM topics")
+	if err != nil {
+		return nil, err
 	}
 	defer rows.Close()
 
+	topics := make(map[string]*store.TopicMeta)
 	for rows.Next() {
-		var name string
-		var createdAtMs, latestOffset int64
-		if err := rows.Scan(&name, &createdAtMs, &latestOffset); err != nil {
+		var name, id, config string
+		var createdAtMs, latestOffset, logStartOffset int64
+		if err := rows.Scan(&name, &id, &createdAtMs, &latestOffset, &logStartOffset, &config); err != nil {
 			continue
 		}
-		s.topics[name] = &TopicMeta{
-			Name:         name,
-			CreatedAt:    time.UnixMilli(createdAtMs),
-			LatestOffset: latestOffset,
+		meta := &store.TopicMeta{
+			Name:           name,
+			ID:             id,
+			CreatedAt:      time.UnixMilli(createdAtMs),
+			LatestOffset:   latestOffset,
+			LogStartOffset: logStartOffset,
+		}
+		if err := json.Unmarshal([]byte(config), &meta.Config); err != nil {
+			log.Printf("[store] topic %s has unreadable config, using defaults: %v", name, err)
+		}
+		if len(meta.Config) == 0 {
+			meta.Config = nil
+		}
+		topics[name] = meta
+	}
+	return topics, rows.Err()
+}
+
+// readEpochs reads every topic's leader epoch history, oldest first
+func (s *SQLiteTopicStore) readEpochs(ctx context.Context) (map[string][]store.LeaderEpoch, error) {
+	rows, err := s.db.DB().QueryContext(ctx, "SELECT topic, epoch, start_offset FROM leader_epochs ORDER BY topic, epoch")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	epochs := make(map[string][]store.LeaderEpoch)
+	for rows.Next() {
+		var topic string
+		var e store.LeaderEpoch
+		if err := rows.Scan(&topic, &e.Epoch, &e.StartOffset); err != nil {
+			return nil, err
+		}
+		epochs[topic] = append(epochs[topic], e)
+	}
+	return epochs, rows.Err()
+}
+
+// startEpochs loads each topic's leader epoch history and opens a new
+// epoch starting at the next offset, as a restarted leader would. A topic
+// nothing was appended to since its last epoch began keeps that epoch, so
+// restarts alone do not grow the history.
+func (s *SQLiteTopicStore) startEpochs() error {
+	epochs, err := s.readEpochs(context.Background())
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.DB().Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for name, meta := range s.topics {
+		next := store.LeaderEpoch{Epoch: 0, StartOffset: meta.LatestOffset + 1}
+		if history := epochs[name]; len(history) > 0 {
+			last := history[len(history)-1]
+			if last.StartOffset == next.StartOffset {
+				meta.LeaderEpoch = last.Epoch
+				continue
+			}
+			next.Epoch = last.Epoch + 1
+		}
+		if _, err := tx.Exec(
+			"INSERT INTO leader_epochs (topic, epoch, start_offset) VALUES (?, ?, ?)",
+			name, next.Epoch, next.StartOffset,
+		); err != nil {
+			return err
+		}
+		epochs[name] = append(epochs[name], next)
+		meta.LeaderEpoch = next.Epoch
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for name := range epochs {
+		if _, ok := s.topics[name]; !ok {
+			delete(epochs, name) // left behind by a topic deleted mid-way
+		}
+	}
+	s.epochs = epochs
+	return nil
+}
+
+// pingTimeout bounds how long Ping waits for a database connection; a
+// locked database still takes up to the busy timeout to report
+const pingTimeout = 2 * time.Second
+
+// Ping checks that the database accepts writes and that topic metadata has
+// been loaded, retrying the load if it failed at startup
+func (s *SQLiteTopicStore) Ping(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.loaded {
+		if err := s.loadTopics(); err != nil {
+			s.mu.Unlock()
+			return fmt.Errorf("topic metadata not loaded: %w", err)
 		}
+		s.version++
+	}
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	conn, err := s.db.DB().Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("database busy: %w", err)
 	}
+	defer conn.Close()
+
+	// Take and release the write lock, so a database locked by another
+	// process or a stuck transaction shows up here
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return fmt.Errorf("database locked: %w", err)
+	}
+	_, err = conn.ExecContext(context.WithoutCancel(ctx), "ROLLBACK")
+	return err
 }
 
-func (s *SQLiteTopicStore) CreateTopic(name string) error {
+func (s *SQLiteTopicStore) CreateTopic(ctx context.Context, name string, config map[string]string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if _, exists := s.topics[name]; exists {
-		return fmt.Errorf("topic already exists: %s", name)
+		return topicExists(name)
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	if len(config) == 0 {
+		configJSON = []byte("{}")
 	}
 
-	now := time.Now()
-	_, err := s.db.DB().Exec(
-		"INSERT INTO topics (name, created_at, latest_offset) VALUES (?, ?, ?)",
-		name, now.UnixMilli(), -1,
-	)
+	tx, err := s.db.DB().BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	id := store.NewTopicID()
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO topics (name, topic_id, created_at, latest_offset, config) VALUES (?, ?, ?, ?, ?)",
+		name, id, now.UnixMilli(), -1, string(configJSON),
+	); err != nil {
+		return err
+	}
+	// Clear any history a half-finished delete left behind
+	if _, err := tx.ExecContext(ctx, "DELETE FROM leader_epochs WHERE topic = ?", name); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM dictionaries WHERE topic = ?", name); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO leader_epochs (topic, epoch, start_offset) VALUES (?, 0, 0)", name,
+	); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
 
-	s.topics[name] = &TopicMeta{
+	s.topics[name] = &store.TopicMeta{
 		Name:         name,
+		ID:           id,
 		CreatedAt:    now,
 		LatestOffset: -1,
+		Config:       config,
+	}
+	s.epochs[name] = []store.LeaderEpoch{{Epoch: 0, StartOffset: 0}}
+	s.version++
+	return nil
+}
+
+func (s *SQLiteTopicStore) SetTopicConfig(ctx context.Context, topic string, config map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	meta, exists := s.topics[topic]
+	if !exists {
+		return topicNotFound(topic)
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	if len(config) == 0 {
+		configJSON = []byte("{}")
 	}
+
+	if _, err := s.db.DB().ExecContext(ctx, "UPDATE topics SET config = ? WHERE name = ?", string(configJSON), topic); err != nil {
+		return storageErr("update topic config", err)
+	}
+	meta.Config = config
+	s.version++
 	return nil
 }
 
@@ -193,6 +651,14 @@ func (s *SQLiteTopicStore) TopicExists(name string) bool {
 	return exists
 }
 
+// Version returns a counter that changes whenever a topic is created or
+// deleted or a latest offset moves
+func (s *SQLiteTopicStore) Version() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.version
+}
+
 func (s *SQLiteTopicStore) ListTopics() []string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -204,24 +670,30 @@ func (s *SQLiteTopicStore) ListTopics() []string {
 	return names
 }
 
-func (s *SQLiteTopicStore) DeleteTopic(name string) error {
+func (s *SQLiteTopicStore) DeleteTopic(ctx context.Context, name string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if _, exists := s.topics[name]; !exists {
-		return fmt.Errorf("topic not found: %s", name)
+		return topicNotFound(name)
 	}
 
-	tx, err := s.db.DB().Begin()
+	tx, err := s.db.DB().BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	if _, err := tx.Exec("DELETE FROM messages WHERE topic = ?", name); err != nil {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM messages WHERE topic = ?", name); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM aborted_txns WHERE topic = ?", name); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM leader_epochs WHERE topic = ?", name); err != nil {
 		return err
 	}
-	if _, err := tx.Exec("DELETE FROM topics WHERE name = ?", name); err != nil {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM topics WHERE name = ?", name); err != nil {
 		return err
 	}
 
@@ -230,29 +702,108 @@ func (s *SQLiteTopicStore) DeleteTopic(name string) error {
 	}
 
 	delete(s.topics, name)
+	delete(s.epochs, name)
+	s.version++
 	return nil
 }
 
-func (s *SQLiteTopicStore) Append(topic string, records []Record) (int64, error) {
+func (s *SQLiteTopicStore) Append(ctx context.Context, topic string, records []store.Record) (int64, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	meta, exists := s.topics[topic]
 	if !exists {
-		return 0, fmt.Errorf("topic not found: %s", topic)
+		return 0, topicNotFound(topic)
+	}
+	if len(records) == 0 {
+		return 0, fmt.Errorf("no records to append")
 	}
 
-	baseOffset := meta.LatestOffset + 1
+	tx, err := s.db.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return 0, storageErr("begin append", err)
+	}
+	defer tx.Rollback()
 
-	tx, err := s.db.DB().Begin()
+	baseOffset, err := s.appendRecords(ctx, tx, meta, records)
 	if err != nil {
 		return 0, err
 	}
+	if err := s.commitAppend(tx, topic); err != nil {
+		return 0, storageErr("commit append", err)
+	}
+
+	meta.LatestOffset = baseOffset + int64(len(records)) - 1
+	s.version++
+	return baseOffset, nil
+}
+
+// AppendAtomic appends to several topics in one transaction. A topic may
+// appear more than once; its appends get consecutive offsets, in order.
+func (s *SQLiteTopicStore) AppendAtomic(ctx context.Context, appends []store.TopicAppend) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Appends are staged on copies of the topics' metadata, so the cache
+	// only moves once the transaction has committed
+	staged := make(map[string]*store.TopicMeta, len(appends))
+	for _, a := range appends {
+		meta, exists := s.topics[a.Topic]
+		if !exists {
+			return nil, topicNotFound(a.Topic)
+		}
+		if len(a.Records) == 0 {
+			return nil, fmt.Errorf("no records to append to %s", a.Topic)
+		}
+		if _, ok := staged[a.Topic]; !ok {
+			copied := *meta
+			staged[a.Topic] = &copied
+		}
+	}
+
+	tx, err := s.db.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return nil, storageErr("begin append", err)
+	}
 	defer tx.Rollback()
 
-	stmt, err := tx.Prepare("INSERT INTO messages (topic, offset, last_offset, timestamp, key, value, codec) VALUES (?, ?, ?, ?, ?, ?, ?)")
+	offsets := make([]int64, len(appends))
+	for i, a := range appends {
+		meta := staged[a.Topic]
+		baseOffset, err := s.appendRecords(ctx, tx, meta, a.Records)
+		if err != nil {
+			return nil, err
+		}
+		meta.LatestOffset = baseOffset + int64(len(a.Records)) - 1
+		offsets[i] = baseOffset
+	}
+	topics := make([]string, 0, len(staged))
+	for topic := range staged {
+		topics = append(topics, topic)
+	}
+	if err := s.commitAppend(tx, topics...); err != nil {
+		return nil, storageErr("commit append", err)
+	}
+
+	for topic, meta := range staged {
+		s.topics[topic].LatestOffset = meta.LatestOffset
+	}
+	s.version++
+	return offsets, nil
+}
+
+// appendRecords inserts records after meta's latest offset within tx and
+// moves the topics row along. It returns the first record's offset; the
+// caller updates meta once tx commits. Caller must hold s.mu.
+func (s *SQLiteTopicStore) appendRecords(ctx context.Context, tx *sql.Tx, meta *store.TopicMeta, records []store.Record) (int64, error) {
+	baseOffset, err := s.nextOffset(ctx, tx, meta)
 	if err != nil {
-		return 0, err
+		return 0, storageErr("next offset", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO messages (topic, offset, last_offset, timestamp, key, value, codec, checksum) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return 0, storageErr("prepare append", err)
 	}
 	defer stmt.Close()
 
@@ -260,166 +811,776 @@ func (s *SQLiteTopicStore) Append(topic string, records []Record) (int64, error)
 		offset := baseOffset + int64(i)
 		ts := rec.Timestamp
 		if ts == 0 {
-			ts = time.Now().UnixMilli()
+			ts = s.now().UnixMilli()
 		}
-		lastOffset := offset
-		if rec.LastOffset > 0 {
-			lastOffset = rec.LastOffset
+		// Each plain record occupies exactly one offset, so its last offset
+		// is its own; raw multi-record batches go through AppendRaw
+		_, err := stmt.ExecContext(ctx, meta.Name, offset, offset, ts, rec.Key, rec.Value, rec.Codec, recordChecksum(rec.Key, rec.Value))
+		if err != nil {
+			return 0, storageErr("insert message", err)
 		}
+	}
 
-		_, err := stmt.Exec(topic, offset, lastOffset, ts, rec.Key, rec.Value, rec.Codec)
-		if err != nil {
-			return 0, err
+	newLatest := baseOffset + int64(len(records)) - 1
+	_, err = tx.ExecContext(ctx, "UPDATE topics SET latest_offset = ? WHERE name = ?", newLatest, meta.Name)
+	if err != nil {
+		return 0, storageErr("update latest offset", err)
+	}
+	return baseOffset, nil
+}
+
+func (s *SQLiteTopicStore) AppendRaw(ctx context.Context, topic string, data []byte, codec int8, recordCount int, maxTimestamp int64) (int64, error) {
+	return s.AppendRawBatches(ctx, topic, []store.RawBatch{{Data: data, Codec: codec, RecordCount: recordCount, MaxTimestamp: maxTimestamp}})
+}
+
+// AppendRawBatches stores raw batches one after another in a single
+// transaction, so either all of them are stored or none
+func (s *SQLiteTopicStore) AppendRawBatches(ctx context.Context, topic string, batches []store.RawBatch) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	meta, exists := s.topics[topic]
+	if !exists {
+		return 0, topicNotFound(topic)
+	}
+	if len(batches) == 0 {
+		return 0, fmt.Errorf("no batches to append")
+	}
+	for _, b := range batches {
+		if b.RecordCount <= 0 {
+			return 0, fmt.Errorf("invalid record count: %d", b.RecordCount)
+		}
+	}
+
+	tx, err := s.db.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return 0, storageErr("begin append", err)
+	}
+	defer tx.Rollback()
+
+	baseOffset, err := s.nextOffset(ctx, tx, meta)
+	if err != nil {
+		return 0, storageErr("next offset", err)
+	}
+	lastOffset := baseOffset - 1
+	for _, b := range batches {
+		offset := lastOffset + 1
+		lastOffset = offset + int64(b.RecordCount) - 1
+		// Retention ages a batch by its newest record, as Kafka does
+		ts := b.MaxTimestamp
+		if ts <= 0 {
+			ts = s.now().UnixMilli()
+		}
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO messages (topic, offset, last_offset, timestamp, key, value, codec, checksum) VALUES (?, ?, ?, ?, NULL, ?, ?, ?)",
+			topic, offset, lastOffset, ts, b.Data, b.Codec, recordChecksum(nil, b.Data),
+		)
+		if err != nil {
+			return 0, storageErr("insert batch", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE topics SET latest_offset = ? WHERE name = ?", lastOffset, topic)
+	if err != nil {
+		return 0, storageErr("update latest offset", err)
+	}
+
+	if err := s.commitAppend(tx, topic); err != nil {
+		return 0, storageErr("commit append", err)
+	}
+
+	meta.LatestOffset = lastOffset
+	s.version++
+	return baseOffset, nil
+}
+
+// AppendReplica stores records copied from a primary at the offsets the
+// primary gave them, leaving any gaps between them as they were there
+func (s *SQLiteTopicStore) AppendReplica(ctx context.Context, topic string, records []store.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	meta, exists := s.topics[topic]
+	if !exists {
+		return topicNotFound(topic)
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("no records to append")
+	}
+
+	tx, err := s.db.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return storageErr("begin append", err)
+	}
+	defer tx.Rollback()
+
+	latest, err := s.nextOffset(ctx, tx, meta)
+	if err != nil {
+		return storageErr("next offset", err)
+	}
+	latest--
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO messages (topic, offset, last_offset, timestamp, key, value, codec, checksum) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return storageErr("prepare append", err)
+	}
+	defer stmt.Close()
+
+	for _, rec := range records {
+		last := max(rec.LastOffset, rec.Offset)
+		if rec.Offset <= latest {
+			return fmt.Errorf("replica record at offset %d of %s is not past latest offset %d", rec.Offset, topic, latest)
+		}
+		if _, err := stmt.ExecContext(ctx, topic, rec.Offset, last, rec.Timestamp, rec.Key, rec.Value, rec.Codec, recordChecksum(rec.Key, rec.Value)); err != nil {
+			return storageErr("insert message", err)
+		}
+		latest = last
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE topics SET latest_offset = ? WHERE name = ?", latest, topic); err != nil {
+		return storageErr("update latest offset", err)
+	}
+	if err := s.commitAppend(tx, topic); err != nil {
+		return storageErr("commit append", err)
+	}
+
+	meta.LatestOffset = latest
+	s.version++
+	return nil
+}
+
+// ============================================================================
+// Offset Integrity
+// ============================================================================
+
+// nextOffset returns the offset to assign to the next append, checking the
+// cached latest offset against what the database says. On divergence the
+// database wins: the cache and topics row are resynced inside tx and the
+// mismatch is counted. Caller must hold s.mu.
+func (s *SQLiteTopicStore) nextOffset(ctx context.Context, tx *sql.Tx, meta *store.TopicMeta) (int64, error) {
+	stored, err := storedLatest(ctx, tx, meta.Name)
+	if err != nil {
+		return 0, err
+	}
+	if stored != meta.LatestOffset {
+		atomic.AddInt64(&s.integrity.OffsetMismatches, 1)
+		log.Printf("[store] offset mismatch on topic %s: cached latest %d, stored %d; resyncing",
+			meta.Name, meta.LatestOffset, stored)
+		if _, err := tx.ExecContext(ctx, "UPDATE topics SET latest_offset = ? WHERE name = ?", stored, meta.Name); err != nil {
+			return 0, err
+		}
+		meta.LatestOffset = stored
+		atomic.AddInt64(&s.integrity.Repairs, 1)
+	}
+	return stored + 1, nil
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// storedLatest returns the latest offset according to the database: the
+// topics row, or the last stored message if that is further ahead
+func storedLatest(ctx context.Context, q querier, topic string) (int64, error) {
+	var latest int64
+	if err := q.QueryRowContext(ctx, "SELECT latest_offset FROM topics WHERE name = ?", topic).Scan(&latest); err != nil {
+		return 0, fmt.Errorf("read latest offset for %s: %w", topic, err)
+	}
+
+	var last int64
+	err := q.QueryRowContext(ctx,
+		"SELECT last_offset FROM messages WHERE topic = ? ORDER BY offset DESC LIMIT 1", topic,
+	).Scan(&last)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	if err == nil && last > latest {
+		latest = last
+	}
+	return latest, nil
+}
+
+// Integrity returns the offset integrity counters
+func (s *SQLiteTopicStore) Integrity() store.IntegrityStats {
+	return store.IntegrityStats{
+		OffsetMismatches: atomic.LoadInt64(&s.integrity.OffsetMismatches),
+		GapsDetected:     atomic.LoadInt64(&s.integrity.GapsDetected),
+		OverlapsDetected: atomic.LoadInt64(&s.integrity.OverlapsDetected),
+		Repairs:          atomic.LoadInt64(&s.integrity.Repairs),
+	}
+}
+
+// CheckIntegrity scans a topic's stored offsets for gaps, overlaps and
+// malformed ranges, and compares the cached latest offset with the database
+func (s *SQLiteTopicStore) CheckIntegrity(ctx context.Context, topic string) (*store.IntegrityReport, error) {
+	s.mu.RLock()
+	meta, exists := s.topics[topic]
+	var cached int64
+	if exists {
+		cached = meta.LatestOffset
+	}
+	s.mu.RUnlock()
+	if !exists {
+		return nil, topicNotFound(topic)
+	}
+
+	report := &store.IntegrityReport{Topic: topic, CachedLatest: cached}
+	var err error
+	if report.StoredLatest, err = storedLatest(ctx, s.db.DB(), topic); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.DB().QueryContext(ctx, "SELECT offset, last_offset FROM messages WHERE topic = ? ORDER BY offset", topic)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prevLast := int64(-1)
+	for rows.Next() {
+		var offset, last int64
+		if err := rows.Scan(&offset, &last); err != nil {
+			return nil, err
+		}
+		report.Rows++
+		if last < offset {
+			report.Malformed = append(report.Malformed, store.OffsetRange{From: offset, To: last})
+		}
+		if report.Rows > 1 {
+			switch {
+			case offset > prevLast+1:
+				report.Gaps = append(report.Gaps, store.OffsetRange{From: prevLast + 1, To: offset - 1})
+			case offset <= prevLast:
+				report.Overlaps = append(report.Overlaps, store.OffsetRange{From: offset, To: prevLast})
+			}
+		}
+		if last > prevLast {
+			prevLast = last
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	atomic.AddInt64(&s.integrity.GapsDetected, int64(len(report.Gaps)))
+	atomic.AddInt64(&s.integrity.OverlapsDetected, int64(len(report.Overlaps)))
+	report.Healthy = report.CachedLatest == report.StoredLatest &&
+		len(report.Gaps) == 0 && len(report.Overlaps) == 0 && len(report.Malformed) == 0
+	return report, nil
+}
+
+// RepairTopic resyncs the cached and stored latest offset of a topic with
+// its last stored message. Gaps and overlaps are reported, not rewritten.
+func (s *SQLiteTopicStore) RepairTopic(ctx context.Context, topic string) (*store.IntegrityReport, error) {
+	s.mu.Lock()
+	meta, exists := s.topics[topic]
+	if !exists {
+		s.mu.Unlock()
+		return nil, topicNotFound(topic)
+	}
+	stored, err := storedLatest(ctx, s.db.DB(), topic)
+	if err == nil && (stored != meta.LatestOffset) {
+		_, err = s.db.DB().ExecContext(ctx, "UPDATE topics SET latest_offset = ? WHERE name = ?", stored, topic)
+		if err == nil {
+			log.Printf("[store] repaired latest offset of topic %s: %d -> %d", topic, meta.LatestOffset, stored)
+			meta.LatestOffset = stored
+			s.version++
+			atomic.AddInt64(&s.integrity.Repairs, 1)
+		}
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return s.CheckIntegrity(ctx, topic)
+}
+
+func (s *SQLiteTopicStore) Read(ctx context.Context, topic string, fromOffset int64, maxRecords int) ([]store.Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, exists := s.topics[topic]; !exists {
+		return nil, topicNotFound(topic)
+	}
+
+	rows, err := s.db.DB().QueryContext(ctx,
+		`SELECT offset, last_offset, timestamp, key, value, codec
+		 FROM messages
+		 WHERE topic = ? AND last_offset >= ?
+		 ORDER BY offset ASC
+		 LIMIT ?`,
+		topic, fromOffset, maxRecords,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []store.Record
+	for rows.Next() {
+		var rec store.Record
+		var key, value []byte
+		if err := rows.Scan(&rec.Offset, &rec.LastOffset, &rec.Timestamp, &key, &value, &rec.Codec); err != nil {
+			continue
+		}
+		rec.Key = key
+		rec.Value = value
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// ReadRange returns up to maxRecords records appended within [fromTs, toTs)
+// (unix millis), starting at offset cursor. The returned cursor resumes the
+// scan on the next call and is -1 once the range is exhausted.
+func (s *SQLiteTopicStore) ReadRange(ctx context.Context, topic string, fromTs, toTs int64, cursor int64, maxRecords int) ([]store.Record, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, exists := s.topics[topic]; !exists {
+		return nil, -1, topicNotFound(topic)
+	}
+	if maxRecords <= 0 {
+		return nil, -1, fmt.Errorf("invalid max records: %d", maxRecords)
+	}
+
+	rows, err := s.db.DB().QueryContext(ctx,
+		`SELECT offset, last_offset, timestamp, key, value, codec
+		 FROM messages
+		 WHERE topic = ? AND timestamp >= ? AND timestamp < ? AND offset >= ?
+		 ORDER BY offset ASC
+		 LIMIT ?`,
+		topic, fromTs, toTs, cursor, maxRecords,
+	)
+	if err != nil {
+		return nil, -1, err
+	}
+	defer rows.Close()
+
+	var records []store.Record
+	for rows.Next() {
+		var rec store.Record
+		if err := rows.Scan(&rec.Offset, &rec.LastOffset, &rec.Timestamp, &rec.Key, &rec.Value, &rec.Codec); err != nil {
+			return nil, -1, err
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, -1, err
+	}
+
+	if len(records) < maxRecords {
+		return records, -1, nil
+	}
+	return records, records[len(records)-1].LastOffset + 1, nil
+}
+
+func (s *SQLiteTopicStore) LatestOffset(topic string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	meta, exists := s.topics[topic]
+	if !exists {
+		return 0, topicNotFound(topic)
+	}
+	return meta.LatestOffset, nil
+}
+
+func (s *SQLiteTopicStore) EarliestOffset(ctx context.Context, topic string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, exists := s.topics[topic]; !exists {
+		return 0, topicNotFound(topic)
+	}
+
+	var earliest sql.NullInt64
+	err := s.db.DB().QueryRowContext(ctx,
+		"SELECT MIN(offset) FROM messages WHERE topic = ?",
+		topic,
+	).Scan(&earliest)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return 0, ctxErr
+	}
+	logStart := s.topics[topic].LogStartOffset
+	if err != nil || !earliest.Valid {
+		return logStart, nil
+	}
+	return max(earliest.Int64, logStart), nil
+}
+
+// rowOverhead estimates what a messages row takes besides its key, value
+// and topic name: four integer columns and SQLite's record header
+const rowOverhead = 40
+
+// SizeBytes estimates the bytes topic's messages take in the database:
+// their keys, values and topic name plus rowOverhead per row. Free space
+// within pages and the indexes are not counted.
+func (s *SQLiteTopicStore) SizeBytes(ctx context.Context, topic string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, exists := s.topics[topic]; !exists {
+		return 0, topicNotFound(topic)
+	}
+
+	var size int64
+	err := s.db.DB().QueryRowContext(ctx,
+		"SELECT COALESCE(SUM(COALESCE(LENGTH(key), 0) + COALESCE(LENGTH(value), 0)), 0) + COUNT(*) * ? FROM messages WHERE topic = ?",
+		len(topic)+rowOverhead, topic,
+	).Scan(&size)
+	if err != nil {
+		return 0, storageErr("measure topic", err)
+	}
+	return size, nil
+}
+
+func (s *SQLiteTopicStore) DeleteBefore(ctx context.Context, topic string, cutoff time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.topics[topic]; !exists {
+		return 0, topicNotFound(topic)
+	}
+
+	// Producers set batch timestamps, which need not grow with the offset,
+	// so only the prefix of the log older than cutoff goes: everything
+	// before the first message at or after it
+	result, err := s.db.DB().ExecContext(ctx,
+		"DELETE FROM messages WHERE topic = ? AND offset < (SELECT COALESCE(MIN(offset), ?) FROM messages WHERE topic = ? AND timestamp >= ?)",
+		topic, s.topics[topic].LatestOffset+1, topic, cutoff.UnixMilli(),
+	)
+	if err != nil {
+		return 0, storageErr("delete messages", err)
+	}
+
+	affected, _ := result.RowsAffected()
+
+	// Aborted ranges entirely below the remaining log are no longer needed
+	s.db.DB().ExecContext(ctx,
+		"DELETE FROM aborted_txns WHERE topic = ? AND last_offset < (SELECT COALESCE(MIN(offset), ?) FROM messages WHERE topic = ?)",
+		topic, s.topics[topic].LatestOffset+1, topic,
+	)
+	return int(affected), nil
+}
+
+// DeleteBeforeOffset deletes messages below offset and makes it the log
+// start offset. A batch straddling offset is kept whole, but the log start
+// offset still moves to offset.
+func (s *SQLiteTopicStore) DeleteBeforeOffset(ctx context.Context, topic string, offset int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	meta, exists := s.topics[topic]
+	if !exists {
+		return 0, topicNotFound(topic)
+	}
+	if offset <= meta.LogStartOffset {
+		return 0, nil
+	}
+
+	tx, err := s.db.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return 0, storageErr("begin delete", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM messages WHERE topic = ? AND last_offset < ?", topic, offset)
+	if err != nil {
+		return 0, storageErr("delete messages", err)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE topics SET log_start_offset = ? WHERE name = ?", offset, topic); err != nil {
+		return 0, storageErr("update log start offset", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM aborted_txns WHERE topic = ? AND last_offset < ?", topic, offset); err != nil {
+		return 0, storageErr("delete aborted transactions", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, storageErr("commit delete", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	meta.LogStartOffset = offset
+	s.version++
+	return int(affected), nil
+}
+
+// SaveDictionary stores a topic's compression dictionary
+func (s *SQLiteTopicStore) SaveDictionary(ctx context.Context, d store.Dictionary) error {
+	s.mu.RLock()
+	_, exists := s.topics[d.Topic]
+	s.mu.RUnlock()
+	if !exists {
+		return topicNotFound(d.Topic)
+	}
+
+	_, err := s.db.DB().ExecContext(ctx,
+		"INSERT OR REPLACE INTO dictionaries (topic, id, created_at, samples, data) VALUES (?, ?, ?, ?, ?)",
+		d.Topic, int64(d.ID), d.CreatedAt.UnixMilli(), d.Samples, d.Data,
+	)
+	if err != nil {
+		return storageErr("save dictionary", err)
+	}
+	return nil
+}
+
+// Dictionaries returns every topic's compression dictionaries, oldest first
+func (s *SQLiteTopicStore) Dictionaries(ctx context.Context) ([]store.Dictionary, error) {
+	rows, err := s.db.DB().QueryContext(ctx,
+		"SELECT topic, id, created_at, samples, data FROM dictionaries ORDER BY created_at, topic")
+	if err != nil {
+		return nil, storageErr("load dictionaries", err)
+	}
+	defer rows.Close()
+
+	var dicts []store.Dictionary
+	for rows.Next() {
+		var d store.Dictionary
+		var id, createdAt int64
+		if err := rows.Scan(&d.Topic, &id, &createdAt, &d.Samples, &d.Data); err != nil {
+			return nil, storageErr("load dictionaries", err)
+		}
+		d.ID = uint32(id)
+		d.CreatedAt = time.UnixMilli(createdAt)
+		dicts = append(dicts, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storageErr("load dictionaries", err)
+	}
+	return dicts, nil
+}
+
+// SaveTxnState stores a transactional ID's coordinator state, replacing
+// what was stored for it
+func (s *SQLiteTopicStore) SaveTxnState(ctx context.Context, st store.TxnState) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.DB().ExecContext(ctx,
+		"INSERT OR REPLACE INTO txn_states (transactional_id, producer_id, producer_epoch, state, updated_at, data) VALUES (?, ?, ?, ?, ?, ?)",
+		st.TransactionalID, st.ProducerID, st.ProducerEpoch, st.State, st.Updated.UnixMilli(), data,
+	)
+	if err != nil {
+		return storageErr("save transaction state", err)
+	}
+	return nil
+}
+
+// TxnStates returns the coordinator state of every transactional ID
+func (s *SQLiteTopicStore) TxnStates(ctx context.Context) ([]store.TxnState, error) {
+	rows, err := s.db.DB().QueryContext(ctx, "SELECT data FROM txn_states ORDER BY transactional_id")
+	if err != nil {
+		return nil, storageErr("load transaction states", err)
+	}
+	defer rows.Close()
+
+	var states []store.TxnState
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, storageErr("load transaction states", err)
+		}
+		var st store.TxnState
+		if err := json.Unmarshal(data, &st); err != nil {
+			return nil, fmt.Errorf("decode transaction state: %w", err)
 		}
+		states = append(states, st)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, storageErr("load transaction states", err)
+	}
+	return states, nil
+}
 
-	newLatest := baseOffset + int64(len(records)) - 1
-	_, err = tx.Exec("UPDATE topics SET latest_offset = ? WHERE name = ?", newLatest, topic)
+// SaveMetrics stores a round of metric samples
+func (s *SQLiteTopicStore) SaveMetrics(ctx context.Context, samples []store.MetricSample) error {
+	tx, err := s.db.DB().BeginTx(ctx, nil)
 	if err != nil {
-		return 0, err
+		return storageErr("save metrics", err)
 	}
+	defer tx.Rollback()
 
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO metrics (ts, name, label, value) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return storageErr("save metrics", err)
+	}
+	defer stmt.Close()
+	for _, m := range samples {
+		if _, err := stmt.ExecContext(ctx, m.Time.UnixMilli(), m.Name, m.Label, m.Value); err != nil {
+			return storageErr("save metrics", err)
+		}
+	}
 	if err := tx.Commit(); err != nil {
-		return 0, err
+		return storageErr("save metrics", err)
 	}
-
-	meta.LatestOffset = newLatest
-	return baseOffset, nil
+	return nil
 }
 
-func (s *SQLiteTopicStore) AppendRaw(topic string, data []byte, codec int8, recordCount int) (int64, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// Metrics returns the samples recorded in [from, to)
+func (s *SQLiteTopicStore) Metrics(ctx context.Context, from, to time.Time) ([]store.MetricSample, error) {
+	samples, err := queryMetrics(ctx, s.db.DB(), from, to)
+	if err != nil {
+		return nil, storageErr("load metrics", err)
+	}
+	return samples, nil
+}
 
-	meta, exists := s.topics[topic]
-	if !exists {
-		return 0, fmt.Errorf("topic not found: %s", topic)
+// DeleteMetricsBefore deletes samples recorded before cutoff
+func (s *SQLiteTopicStore) DeleteMetricsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := s.db.DB().ExecContext(ctx, "DELETE FROM metrics WHERE ts < ?", cutoff.UnixMilli())
+	if err != nil {
+		return 0, storageErr("delete metrics", err)
 	}
+	return res.RowsAffected()
+}
+
+// AddACLs stores ACL bindings, skipping ones already stored
+func (s *SQLiteTopicStore) AddACLs(ctx context.Context, acls []store.ACLBinding) error {
+	return s.execACLs(ctx, "add acls",
+		`INSERT OR IGNORE INTO acls (resource_type, resource_name, pattern_type, principal, host, operation, permission)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`, acls)
+}
 
-	baseOffset := meta.LatestOffset + 1
-	lastOffset := baseOffset + int64(recordCount) - 1
-	ts := time.Now().UnixMilli()
+// DeleteACLs deletes ACL bindings
+func (s *SQLiteTopicStore) DeleteACLs(ctx context.Context, acls []store.ACLBinding) error {
+	return s.execACLs(ctx, "delete acls",
+		`DELETE FROM acls WHERE resource_type = ? AND resource_name = ? AND pattern_type = ?
+		AND principal = ? AND host = ? AND operation = ? AND permission = ?`, acls)
+}
 
-	tx, err := s.db.DB().Begin()
+// execACLs runs query once per binding in one transaction
+func (s *SQLiteTopicStore) execACLs(ctx context.Context, op, query string, acls []store.ACLBinding) error {
+	tx, err := s.db.DB().BeginTx(ctx, nil)
 	if err != nil {
-		return 0, err
+		return storageErr(op, err)
 	}
 	defer tx.Rollback()
 
-	_, err = tx.Exec(
-		"INSERT INTO messages (topic, offset, last_offset, timestamp, key, value, codec) VALUES (?, ?, ?, ?, NULL, ?, ?)",
-		topic, baseOffset, lastOffset, ts, data, codec,
-	)
+	stmt, err := tx.PrepareContext(ctx, query)
 	if err != nil {
-		return 0, err
+		return storageErr(op, err)
 	}
-
-	_, err = tx.Exec("UPDATE topics SET latest_offset = ? WHERE name = ?", lastOffset, topic)
-	if err != nil {
-		return 0, err
+	defer stmt.Close()
+	for _, a := range acls {
+		if _, err := stmt.ExecContext(ctx, a.ResourceType, a.ResourceName, a.PatternType, a.Principal, a.Host, a.Operation, a.Permission); err != nil {
+			return storageErr(op, err)
+		}
 	}
-
 	if err := tx.Commit(); err != nil {
-		return 0, err
+		return storageErr(op, err)
 	}
-
-	meta.LatestOffset = lastOffset
-	return baseOffset, nil
+	return nil
 }
 
-func (s *SQLiteTopicStore) Read(topic string, fromOffset int64, maxRecords int) ([]Record, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// ACLs returns every stored ACL binding
+func (s *SQLiteTopicStore) ACLs(ctx context.Context) ([]store.ACLBinding, error) {
+	rows, err := s.db.DB().QueryContext(ctx,
+		`SELECT resource_type, resource_name, pattern_type, principal, host, operation, permission
+		FROM acls ORDER BY resource_type, resource_name, pattern_type, principal, host, operation, permission`)
+	if err != nil {
+		return nil, storageErr("load acls", err)
+	}
+	defer rows.Close()
 
-	if _, exists := s.topics[topic]; !exists {
-		return nil, fmt.Errorf("topic not found: %s", topic)
+	var acls []store.ACLBinding
+	for rows.Next() {
+		var a store.ACLBinding
+		if err := rows.Scan(&a.ResourceType, &a.ResourceName, &a.PatternType, &a.Principal, &a.Host, &a.Operation, &a.Permission); err != nil {
+			return nil, storageErr("load acls", err)
+		}
+		acls = append(acls, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storageErr("load acls", err)
 	}
+	return acls, nil
+}
 
-	rows, err := s.db.DB().Query(
-		`SELECT offset, last_offset, timestamp, key, value, codec
-		 FROM messages
-		 WHERE topic = ? AND last_offset >= ?
-		 ORDER BY offset ASC
-		 LIMIT ?`,
-		topic, fromOffset, maxRecords,
+// queryMetrics reads the metrics table; shared with the Inspector
+func queryMetrics(ctx context.Context, db *sql.DB, from, to time.Time) ([]store.MetricSample, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT ts, name, label, value FROM metrics WHERE ts >= ? AND ts < ? ORDER BY ts, name, label",
+		from.UnixMilli(), to.UnixMilli(),
 	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var records []Record
+	samples := []store.MetricSample{}
 	for rows.Next() {
-		var rec Record
-		var key, value []byte
-		if err := rows.Scan(&rec.Offset, &rec.LastOffset, &rec.Timestamp, &key, &value, &rec.Codec); err != nil {
-			continue
+		var m store.MetricSample
+		var ts int64
+		if err := rows.Scan(&ts, &m.Name, &m.Label, &m.Value); err != nil {
+			return nil, err
 		}
-		rec.Key = key
-		rec.Value = value
-		records = append(records, rec)
+		m.Time = time.UnixMilli(ts)
+		samples = append(samples, m)
 	}
-
-	return records, nil
+	return samples, rows.Err()
 }
 
-func (s *SQLiteTopicStore) LatestOffset(topic string) (int64, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// AddAbortedTxn records the offset range of an aborted transaction
+func (s *SQLiteTopicStore) AddAbortedTxn(ctx context.Context, topic string, txn store.AbortedTxn) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	meta, exists := s.topics[topic]
-	if !exists {
-		return 0, fmt.Errorf("topic not found: %s", topic)
+	if _, exists := s.topics[topic]; !exists {
+		return topicNotFound(topic)
 	}
-	return meta.LatestOffset, nil
-}
 
-func (s *SQLiteTopicStore) EarliestOffset(topic string) (int64, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	_, err := s.db.DB().ExecContext(ctx,
+		"INSERT OR REPLACE INTO aborted_txns (topic, producer_id, first_offset, last_offset) VALUES (?, ?, ?, ?)",
+		topic, txn.ProducerID, txn.FirstOffset, txn.LastOffset,
+	)
+	return err
+}
 
-	if _, exists := s.topics[topic]; !exists {
-		return 0, fmt.Errorf("topic not found: %s", topic)
+// AbortedTxns returns aborted transactions overlapping [fromOffset, toOffset)
+func (s *SQLiteTopicStore) AbortedTxns(ctx context.Context, topic string, fromOffset, toOffset int64) ([]store.AbortedTxn, error) {
+	rows, err := s.db.DB().QueryContext(ctx,
+		"SELECT producer_id, first_offset, last_offset FROM aborted_txns WHERE topic = ? AND last_offset >= ? AND first_offset < ? ORDER BY first_offset",
+		topic, fromOffset, toOffset,
+	)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	var earliest sql.NullInt64
-	err := s.db.DB().QueryRow(
-		"SELECT MIN(offset) FROM messages WHERE topic = ?",
-		topic,
-	).Scan(&earliest)
-	if err != nil || !earliest.Valid {
-		return 0, nil
+	var txns []store.AbortedTxn
+	for rows.Next() {
+		var t store.AbortedTxn
+		if err := rows.Scan(&t.ProducerID, &t.FirstOffset, &t.LastOffset); err != nil {
+			return nil, err
+		}
+		txns = append(txns, t)
 	}
-	return earliest.Int64, nil
+	return txns, rows.Err()
 }
 
-func (s *SQLiteTopicStore) DeleteBefore(topic string, cutoff time.Time) (int, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// LeaderEpochs returns a topic's leader epoch history, oldest first
+func (s *SQLiteTopicStore) LeaderEpochs(topic string) ([]store.LeaderEpoch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
 	if _, exists := s.topics[topic]; !exists {
-		return 0, fmt.Errorf("topic not found: %s", topic)
-	}
-
-	result, err := s.db.DB().Exec(
-		"DELETE FROM messages WHERE topic = ? AND timestamp < ?",
-		topic, cutoff.UnixMilli(),
-	)
-	if err != nil {
-		return 0, err
+		return nil, topicNotFound(topic)
 	}
-
-	affected, _ := result.RowsAffected()
-	return int(affected), nil
+	return append([]store.LeaderEpoch(nil), s.epochs[topic]...), nil
 }
 
-func (s *SQLiteTopicStore) GetMeta(topic string) (*TopicMeta, error) {
+func (s *SQLiteTopicStore) GetMeta(topic string) (*store.TopicMeta, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	meta, exists := s.topics[topic]
 	if !exists {
-		return nil, fmt.Errorf("topic not found: %s", topic)
+		return nil, topicNotFound(topic)
 	}
 	return meta, nil
 }
@@ -429,29 +1590,44 @@ func (s *SQLiteTopicStore) GetMeta(topic string) (*TopicMeta, error) {
 // ============================================================================
 
 type SQLiteGroupStore struct {
-	db     *SQLiteDB
-	mu     sync.RWMutex
-	groups map[string]*Group // in-memory cache
+	db      *SQLiteDB
+	mu      sync.RWMutex
+	groups  map[string]*store.Group // in-memory cache
+	version uint64                  // bumped on every change to the group list, a state or a generation
+	seen    dbState                 // database state the cache was last synced at
 }
 
 func NewSQLiteGroupStore(db *SQLiteDB) *SQLiteGroupStore {
 	gs := &SQLiteGroupStore{
 		db:     db,
-		groups: make(map[string]*Group),
+		groups: make(map[string]*store.Group),
 	}
 	gs.loadGroups()
+	gs.seen, _ = db.state(context.Background())
 	return gs
 }
 
 func (s *SQLiteGroupStore) loadGroups() {
-	rows, err := s.db.DB().Query("SELECT id, state, generation, leader_id, protocol, created_at, updated_at FROM groups")
+	groups, err := s.readGroups(context.Background())
 	if err != nil {
+		log.Printf("[store] failed to load groups: %v", err)
 		return
 	}
+	s.groups = groups
+}
+
+// readGroups reads every group with its members and offsets from the
+// database
+func (s *SQLiteGroupStore) readGroups(ctx context.Context) (map[string]*store.Group, error) {
+	rows, err := s.db.DB().QueryContext(ctx, "SELECT id, state, generation, leader_id, protocol, created_at, updated_at FROM groups")
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
+	groups := make(map[string]*store.Group)
 	for rows.Next() {
-		var g Group
+		var g store.Group
 		var leaderID, protocol sql.NullString
 		var createdAt, updatedAt int64
 		if err := rows.Scan(&g.ID, &g.State, &g.Generation, &leaderID, &protocol, &createdAt, &updatedAt); err != nil {
@@ -461,34 +1637,43 @@ func (s *SQLiteGroupStore) loadGroups() {
 		g.Protocol = protocol.String
 		g.CreatedAt = time.UnixMilli(createdAt)
 		g.UpdatedAt = time.UnixMilli(updatedAt)
-		g.Members = make(map[string]Member)
-		g.Offsets = make(map[string]int64)
-		s.groups[g.ID] = &g
+		g.Members = make(map[string]store.Member)
+		g.Offsets = make(map[store.TopicPartition]int64)
+		groups[g.ID] = &g
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
 
 	// Load members
-	for groupID, group := range s.groups {
-		s.loadMembers(groupID, group)
-		s.loadOffsets(groupID, group)
+	for groupID, group := range groups {
+		if err := s.loadMembers(ctx, groupID, group); err != nil {
+			return nil, err
+		}
+		if err := s.loadOffsets(ctx, groupID, group); err != nil {
+			return nil, err
+		}
 	}
+	return groups, nil
 }
 
-func (s *SQLiteGroupStore) loadMembers(groupID string, group *Group) {
-	rows, err := s.db.DB().Query(
-		"SELECT member_id, client_id, last_heartbeat, metadata, assignment FROM group_members WHERE group_id = ?",
+func (s *SQLiteGroupStore) loadMembers(ctx context.Context, groupID string, group *store.Group) error {
+	rows, err := s.db.DB().QueryContext(ctx,
+		"SELECT member_id, client_id, last_heartbeat, session_timeout_ms, metadata, assignment FROM group_members WHERE group_id = ?",
 		groupID,
 	)
 	if err != nil {
-		return
+		return err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var m Member
+		var m store.Member
 		var clientID sql.NullString
 		var lastHB int64
 		var metadata, assignment []byte
-		if err := rows.Scan(&m.ID, &clientID, &lastHB, &metadata, &assignment); err != nil {
+		if err := rows.Scan(&m.ID, &clientID, &lastHB, &m.SessionTimeoutMs, &metadata, &assignment); err != nil {
 			continue
 		}
 		m.ClientID = clientID.String
@@ -497,29 +1682,31 @@ func (s *SQLiteGroupStore) loadMembers(groupID string, group *Group) {
 		m.Assignment = assignment
 		group.Members[m.ID] = m
 	}
+	return rows.Err()
 }
 
-func (s *SQLiteGroupStore) loadOffsets(groupID string, group *Group) {
-	rows, err := s.db.DB().Query(
-		"SELECT topic, committed_offset FROM group_offsets WHERE group_id = ?",
+func (s *SQLiteGroupStore) loadOffsets(ctx context.Context, groupID string, group *store.Group) error {
+	rows, err := s.db.DB().QueryContext(ctx,
+		"SELECT topic, partition, committed_offset FROM group_offsets WHERE group_id = ?",
 		groupID,
 	)
 	if err != nil {
-		return
+		return err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var topic string
+		var tp store.TopicPartition
 		var offset int64
-		if err := rows.Scan(&topic, &offset); err != nil {
+		if err := rows.Scan(&tp.Topic, &tp.Partition, &offset); err != nil {
 			continue
 		}
-		group.Offsets[topic] = offset
+		group.Offsets[tp] = offset
 	}
+	return rows.Err()
 }
 
-func (s *SQLiteGroupStore) GetOrCreateGroup(groupID string) (*Group, error) {
+func (s *SQLiteGroupStore) GetOrCreateGroup(ctx context.Context, groupID string) (*store.Group, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -528,33 +1715,42 @@ func (s *SQLiteGroupStore) GetOrCreateGroup(groupID string) (*Group, error) {
 	}
 
 	now := time.Now()
-	_, err := s.db.DB().Exec(
+	_, err := s.db.DB().ExecContext(ctx,
 		"INSERT INTO groups (id, state, generation, created_at, updated_at) VALUES (?, 'empty', 0, ?, ?)",
 		groupID, now.UnixMilli(), now.UnixMilli(),
 	)
 	if err != nil {
-		return nil, err
+		return nil, storageErr("create group", err)
 	}
 
-	group := &Group{
+	group := &store.Group{
 		ID:        groupID,
 		State:     "empty",
-		Members:   make(map[string]Member),
-		Offsets:   make(map[string]int64),
+		Members:   make(map[string]store.Member),
+		Offsets:   make(map[store.TopicPartition]int64),
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
 	s.groups[groupID] = group
+	s.version++
 	return group, nil
 }
 
-func (s *SQLiteGroupStore) GetGroup(groupID string) (*Group, bool) {
+func (s *SQLiteGroupStore) GetGroup(groupID string) (*store.Group, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	group, exists := s.groups[groupID]
 	return group, exists
 }
 
+// Version returns a counter that changes whenever a group is created or
+// deleted or its membership, state or generation changes
+func (s *SQLiteGroupStore) Version() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.version
+}
+
 func (s *SQLiteGroupStore) ListGroups() []string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -566,101 +1762,89 @@ func (s *SQLiteGroupStore) ListGroups() []string {
 	return ids
 }
 
-func (s *SQLiteGroupStore) AddMember(groupID, memberID, clientID string, metadata []byte) error {
+func (s *SQLiteGroupStore) AddMember(ctx context.Context, groupID, memberID, clientID string, sessionTimeoutMs int32, metadata []byte) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	group, exists := s.groups[groupID]
 	if !exists {
-		return fmt.Errorf("group not found: %s", groupID)
+		return groupNotFound(groupID)
 	}
 
 	now := time.Now()
-	_, err := s.db.DB().Exec(
-		`INSERT OR REPLACE INTO group_members (group_id, member_id, client_id, last_heartbeat, metadata)
-		 VALUES (?, ?, ?, ?, ?)`,
-		groupID, memberID, clientID, now.UnixMilli(), metadata,
-	)
-	if err != nil {
-		return err
-	}
-
-	group.Members[memberID] = Member{
-		ID:            memberID,
-		ClientID:      clientID,
-		LastHeartbeat: now,
-		Metadata:      metadata,
-	}
-
-	if len(group.Members) == 1 {
-		group.LeaderID = memberID
-	}
-	if group.State == "empty" {
-		group.State = "forming"
-	}
-	group.UpdatedAt = now
+	return s.mutateGroup(ctx, "add member", group, func(tx *sql.Tx, g *store.Group) error {
+		_, err := tx.ExecContext(ctx,
+			`INSERT OR REPLACE INTO group_members (group_id, member_id, client_id, last_heartbeat, session_timeout_ms, metadata)
+			 VALUES (?, ?, ?, ?, ?, ?)`,
+			groupID, memberID, clientID, now.UnixMilli(), sessionTimeoutMs, metadata,
+		)
+		if err != nil {
+			return err
+		}
 
-	s.updateGroupMeta(group)
-	return nil
+		g.Members[memberID] = store.Member{
+			ID:               memberID,
+			ClientID:         clientID,
+			LastHeartbeat:    now,
+			SessionTimeoutMs: sessionTimeoutMs,
+			Metadata:         metadata,
+		}
+		if len(g.Members) == 1 {
+			g.LeaderID = memberID
+		}
+		if g.State == "empty" {
+			g.State = "forming"
+		}
+		g.UpdatedAt = now
+		return nil
+	})
 }
 
-func (s *SQLiteGroupStore) RemoveMember(groupID, memberID string) error {
+func (s *SQLiteGroupStore) RemoveMember(ctx context.Context, groupID, memberID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	group, exists := s.groups[groupID]
 	if !exists {
-		return fmt.Errorf("group not found: %s", groupID)
+		return groupNotFound(groupID)
 	}
 
-	_, err := s.db.DB().Exec(
-		"DELETE FROM group_members WHERE group_id = ? AND member_id = ?",
-		groupID, memberID,
-	)
-	if err != nil {
-		return err
-	}
-
-	delete(group.Members, memberID)
-	group.UpdatedAt = time.Now()
-
-	if group.LeaderID == memberID && len(group.Members) > 0 {
-		for id := range group.Members {
-			group.LeaderID = id
-			break
+	return s.mutateGroup(ctx, "remove member", group, func(tx *sql.Tx, g *store.Group) error {
+		_, err := tx.ExecContext(ctx,
+			"DELETE FROM group_members WHERE group_id = ? AND member_id = ?",
+			groupID, memberID,
+		)
+		if err != nil {
+			return err
 		}
-	}
-
-	if len(group.Members) == 0 {
-		group.State = "empty"
-		group.LeaderID = ""
-	}
-
-	s.updateGroupMeta(group)
-	return nil
+		delete(g.Members, memberID)
+		g.UpdatedAt = time.Now()
+		fixLeader(g)
+		return nil
+	})
 }
 
-func (s *SQLiteGroupStore) UpdateHeartbeat(groupID, memberID string) error {
+func (s *SQLiteGroupStore) UpdateHeartbeat(ctx context.Context, groupID, memberID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	group, exists := s.groups[groupID]
 	if !exists {
-		return fmt.Errorf("group not found: %s", groupID)
+		return groupNotFound(groupID)
 	}
 
 	member, exists := group.Members[memberID]
 	if !exists {
-		return fmt.Errorf("member not found: %s", memberID)
+		return memberNotFound(memberID)
 	}
 
 	now := time.Now()
-	_, err := s.db.DB().Exec(
+	_, err := s.db.DB().ExecContext(ctx,
 		"UPDATE group_members SET last_heartbeat = ? WHERE group_id = ? AND member_id = ?",
 		now.UnixMilli(), groupID, memberID,
 	)
 	if err != nil {
-		return err
+		return storageErr("update heartbeat", err)
 	}
 
 	member.LastHeartbeat = now
@@ -669,26 +1853,73 @@ func (s *SQLiteGroupStore) UpdateHeartbeat(groupID, memberID string) error {
 	return nil
 }
 
-func (s *SQLiteGroupStore) SetMemberAssignment(groupID, memberID string, assignment []byte) error {
+// UpdateHeartbeats records many members' heartbeats in one transaction.
+// Members that have left the group are skipped.
+func (s *SQLiteGroupStore) UpdateHeartbeats(ctx context.Context, beats []store.Heartbeat) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return storageErr("update heartbeats", err)
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, "UPDATE group_members SET last_heartbeat = ? WHERE group_id = ? AND member_id = ?")
+	if err != nil {
+		return storageErr("update heartbeats", err)
+	}
+	defer stmt.Close()
+
+	var written []store.Heartbeat
+	for _, b := range beats {
+		group, ok := s.groups[b.GroupID]
+		if !ok {
+			continue
+		}
+		if _, ok := group.Members[b.MemberID]; !ok {
+			continue
+		}
+		if _, err := stmt.ExecContext(ctx, b.At.UnixMilli(), b.GroupID, b.MemberID); err != nil {
+			return storageErr("update heartbeats", err)
+		}
+		written = append(written, b)
+	}
+	if err := tx.Commit(); err != nil {
+		return storageErr("update heartbeats", err)
+	}
+
+	for _, b := range written {
+		group := s.groups[b.GroupID]
+		member := group.Members[b.MemberID]
+		member.LastHeartbeat = b.At
+		group.Members[b.MemberID] = member
+		if b.At.After(group.UpdatedAt) {
+			group.UpdatedAt = b.At
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteGroupStore) SetMemberAssignment(ctx context.Context, groupID, memberID string, assignment []byte) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	group, exists := s.groups[groupID]
 	if !exists {
-		return fmt.Errorf("group not found: %s", groupID)
+		return groupNotFound(groupID)
 	}
 
 	member, exists := group.Members[memberID]
 	if !exists {
-		return fmt.Errorf("member not found: %s", memberID)
+		return memberNotFound(memberID)
 	}
 
-	_, err := s.db.DB().Exec(
+	_, err := s.db.DB().ExecContext(ctx,
 		"UPDATE group_members SET assignment = ? WHERE group_id = ? AND member_id = ?",
 		assignment, groupID, memberID,
 	)
 	if err != nil {
-		return err
+		return storageErr("set assignment", err)
 	}
 
 	member.Assignment = assignment
@@ -697,136 +1928,250 @@ func (s *SQLiteGroupStore) SetMemberAssignment(groupID, memberID string, assignm
 	return nil
 }
 
-func (s *SQLiteGroupStore) IncrementGeneration(groupID string) (int32, error) {
+func (s *SQLiteGroupStore) IncrementGeneration(ctx context.Context, groupID string) (int32, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	group, exists := s.groups[groupID]
 	if !exists {
-		return 0, fmt.Errorf("group not found: %s", groupID)
+		return 0, groupNotFound(groupID)
 	}
 
-	group.Generation++
-	group.State = "stable"
-	group.UpdatedAt = time.Now()
-
-	s.updateGroupMeta(group)
+	err := s.mutateGroup(ctx, "increment generation", group, func(tx *sql.Tx, g *store.Group) error {
+		g.Generation++
+		g.State = "stable"
+		g.UpdatedAt = time.Now()
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
 	return group.Generation, nil
 }
 
-func (s *SQLiteGroupStore) CommitOffset(groupID, topic string, offset int64) error {
+func (s *SQLiteGroupStore) CommitOffset(ctx context.Context, groupID, topic string, partition int32, offset int64) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	group, exists := s.groups[groupID]
 	if !exists {
-		return fmt.Errorf("group not found: %s", groupID)
+		return groupNotFound(groupID)
 	}
 
-	_, err := s.db.DB().Exec(
-		`INSERT OR REPLACE INTO group_offsets (group_id, topic, committed_offset) VALUES (?, ?, ?)`,
-		groupID, topic, offset,
+	_, err := s.db.DB().ExecContext(ctx,
+		`INSERT OR REPLACE INTO group_offsets (group_id, topic, partition, committed_offset) VALUES (?, ?, ?, ?)`,
+		groupID, topic, partition, offset,
 	)
 	if err != nil {
-		return err
+		return storageErr("commit offset", err)
 	}
 
-	group.Offsets[topic] = offset
+	group.Offsets[store.TopicPartition{Topic: topic, Partition: partition}] = offset
 	group.UpdatedAt = time.Now()
 	return nil
 }
 
-func (s *SQLiteGroupStore) FetchOffset(groupID, topic string) (int64, error) {
+func (s *SQLiteGroupStore) FetchOffset(groupID, topic string, partition int32) (int64, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	group, exists := s.groups[groupID]
 	if !exists {
-		return -1, fmt.Errorf("group not found: %s", groupID)
+		return -1, groupNotFound(groupID)
 	}
 
-	offset, exists := group.Offsets[topic]
+	offset, exists := group.Offsets[store.TopicPartition{Topic: topic, Partition: partition}]
 	if !exists {
 		return -1, nil
 	}
 	return offset, nil
 }
 
-func (s *SQLiteGroupStore) ExpireMembers(timeout time.Duration) ([]string, error) {
+// Offsets returns a copy of every offset the group has committed
+func (s *SQLiteGroupStore) Offsets(groupID string) (map[store.TopicPartition]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	group, exists := s.groups[groupID]
+	if !exists {
+		return nil, groupNotFound(groupID)
+	}
+	return maps.Clone(group.Offsets), nil
+}
+
+// CommitOffsets commits offsets for several partitions in one transaction,
+// so either all of them are stored or none are
+func (s *SQLiteGroupStore) CommitOffsets(ctx context.Context, groupID string, offsets map[store.TopicPartition]int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	group, exists := s.groups[groupID]
+	if !exists {
+		return groupNotFound(groupID)
+	}
+
+	tx, err := s.db.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return storageErr("commit offsets", err)
+	}
+	defer tx.Rollback()
+
+	for tp, offset := range offsets {
+		_, err := tx.ExecContext(ctx,
+			`INSERT OR REPLACE INTO group_offsets (group_id, topic, partition, committed_offset) VALUES (?, ?, ?, ?)`,
+			groupID, tp.Topic, tp.Partition, offset,
+		)
+		if err != nil {
+			return storageErr("commit offsets", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return storageErr("commit offsets", err)
+	}
+
+	for tp, offset := range offsets {
+		group.Offsets[tp] = offset
+	}
+	group.UpdatedAt = time.Now()
+	return nil
+}
+
+func (s *SQLiteGroupStore) ExpireMembers(ctx context.Context, defaultTimeout time.Duration) ([]string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	cutoff := time.Now().Add(-timeout)
+	now := time.Now()
 	var expired []string
+	var errs []error
 
 	for _, group := range s.groups {
 		var toRemove []string
 		for memberID, member := range group.Members {
-			if member.LastHeartbeat.Before(cutoff) {
+			timeout := defaultTimeout
+			if member.SessionTimeoutMs > 0 {
+				timeout = time.Duration(member.SessionTimeoutMs) * time.Millisecond
+			}
+			if member.LastHeartbeat.Before(now.Add(-timeout)) {
 				toRemove = append(toRemove, memberID)
 			}
 		}
-
-		for _, memberID := range toRemove {
-			s.db.DB().Exec(
-				"DELETE FROM group_members WHERE group_id = ? AND member_id = ?",
-				group.ID, memberID,
-			)
-			delete(group.Members, memberID)
-			expired = append(expired, fmt.Sprintf("%s/%s", group.ID, memberID))
+		if len(toRemove) == 0 {
+			continue
 		}
 
-		if len(toRemove) > 0 {
-			group.UpdatedAt = time.Now()
-			if len(group.Members) == 0 {
-				group.State = "empty"
-				group.LeaderID = ""
-			} else if _, exists := group.Members[group.LeaderID]; !exists {
-				for id := range group.Members {
-					group.LeaderID = id
-					break
+		// Each group is its own transaction, so one failing leaves the
+		// others expired
+		err := s.mutateGroup(ctx, "expire members", group, func(tx *sql.Tx, g *store.Group) error {
+			for _, memberID := range toRemove {
+				_, err := tx.ExecContext(ctx,
+					"DELETE FROM group_members WHERE group_id = ? AND member_id = ?",
+					g.ID, memberID,
+				)
+				if err != nil {
+					return err
 				}
+				delete(g.Members, memberID)
 			}
-			s.updateGroupMeta(group)
+			g.UpdatedAt = now
+			fixLeader(g)
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("group %s: %w", group.ID, err))
+			continue
+		}
+		for _, memberID := range toRemove {
+			expired = append(expired, fmt.Sprintf("%s/%s", group.ID, memberID))
 		}
 	}
 
-	return expired, nil
+	return expired, errors.Join(errs...)
 }
 
-func (s *SQLiteGroupStore) DeleteGroup(groupID string) error {
+func (s *SQLiteGroupStore) DeleteGroup(ctx context.Context, groupID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if _, exists := s.groups[groupID]; !exists {
-		return fmt.Errorf("group not found: %s", groupID)
+		return groupNotFound(groupID)
 	}
 
-	tx, err := s.db.DB().Begin()
+	tx, err := s.db.DB().BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return storageErr("delete group", err)
 	}
 	defer tx.Rollback()
 
-	tx.Exec("DELETE FROM group_offsets WHERE group_id = ?", groupID)
-	tx.Exec("DELETE FROM group_members WHERE group_id = ?", groupID)
-	tx.Exec("DELETE FROM groups WHERE id = ?", groupID)
-
+	for _, query := range []string{
+		"DELETE FROM group_offsets WHERE group_id = ?",
+		"DELETE FROM group_members WHERE group_id = ?",
+		"DELETE FROM groups WHERE id = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, query, groupID); err != nil {
+			return storageErr("delete group", err)
+		}
+	}
 	if err := tx.Commit(); err != nil {
-		return err
+		return storageErr("delete group", err)
 	}
 
 	delete(s.groups, groupID)
+	s.version++
 	return nil
 }
 
-func (s *SQLiteGroupStore) updateGroupMeta(group *Group) {
-	s.db.DB().Exec(
+// mutateGroup applies fn to a copy of group and writes the statements fn
+// runs, along with the copy's state, in one transaction. The cached group
+// only takes the copy's place once that commits, so a failed write leaves
+// the cache matching the database. Caller must hold s.mu.
+func (s *SQLiteGroupStore) mutateGroup(ctx context.Context, op string, group *store.Group, fn func(tx *sql.Tx, g *store.Group) error) error {
+	next := *group
+	next.Members = maps.Clone(group.Members)
+	next.Offsets = maps.Clone(group.Offsets)
+
+	tx, err := s.db.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return storageErr(op, err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx, &next); err != nil {
+		return storageErr(op, err)
+	}
+	_, err = tx.ExecContext(ctx,
 		"UPDATE groups SET state = ?, generation = ?, leader_id = ?, protocol = ?, updated_at = ? WHERE id = ?",
-		group.State, group.Generation, group.LeaderID, group.Protocol, group.UpdatedAt.UnixMilli(), group.ID,
+		next.State, next.Generation, next.LeaderID, next.Protocol, next.UpdatedAt.UnixMilli(), next.ID,
 	)
+	if err != nil {
+		return storageErr(op, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return storageErr(op, err)
+	}
+
+	*group = next
+	s.version++
+	return nil
+}
+
+// fixLeader picks a new leader when the group's has left, and empties the
+// group when no members remain
+func fixLeader(g *store.Group) {
+	if len(g.Members) == 0 {
+		g.State = "empty"
+		g.LeaderID = ""
+		return
+	}
+	if _, ok := g.Members[g.LeaderID]; !ok {
+		for id := range g.Members {
+			g.LeaderID = id
+			break
+		}
+	}
 }
 
 // Ensure implementations satisfy interfaces
-var _ TopicStoreInterface = (*SQLiteTopicStore)(nil)
-var _ GroupStoreInterface = (*SQLiteGroupStore)(nil)
+var _ store.TopicStoreInterface = (*SQLiteTopicStore)(nil)
+var _ store.GroupStoreInterface = (*SQLiteGroupStore)(nil)
+var _ store.Clocked = (*SQLiteTopicStore)(nil)
+var _ store.DictionaryStore = (*SQLiteTopicStore)(nil)
@@ -0,0 +1,244 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/rizkyandriawan/monolog/internal/config"
+)
+
+// ============================================================================
+// Materialized view tables
+//
+// ViewDB is an in-memory SQLite database holding one table per
+// materialized view: a row per message with its offset, timestamp, key
+// and value, plus a column per JSON path the view extracts. Views are
+// rebuilt from their topics on startup, so nothing here is persisted.
+// ============================================================================
+
+// MaxViewRows caps the rows one view query returns
+const MaxViewRows = 10000
+
+// ViewQueryTimeout caps how long one view query runs. Queries share the
+// view database's single connection with view maintenance, so a slow one
+// would hold up every view.
+const ViewQueryTimeout = 10 * time.Second
+
+// viewName is what view and column names must look like, so they can be
+// used as SQL identifiers
+var viewName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidViewName reports whether name can name a view or view column
+func ValidViewName(name string) bool {
+	return viewName.MatchString(name) && !strings.HasPrefix(name, "_")
+}
+
+// ViewRows is the result of a view query
+type ViewRows struct {
+	Columns   []string        `json:"columns"`
+	Rows      [][]interface{} `json:"rows"`
+	Truncated bool            `json:"truncated,omitempty"` // more rows than the limit matched
+}
+
+// ViewDB holds the tables of materialized views
+type ViewDB struct {
+	db      *sql.DB
+	mu      sync.Mutex
+	inserts map[string]*sql.Stmt // by view
+}
+
+// OpenViewDB opens an empty in-memory view database
+func OpenViewDB() (*ViewDB, error) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return nil, err
+	}
+	// One connection: every connection to :memory: is its own database
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxLifetime(0)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &ViewDB{db: db, inserts: make(map[string]*sql.Stmt)}, nil
+}
+
+// Close closes the database, dropping every view table
+func (v *ViewDB) Close() error {
+	return v.db.Close()
+}
+
+// CreateTable creates the table of a view, replacing any table it had.
+// Besides its columns every table has _offset, _timestamp (Unix ms), _key
+// and _value.
+func (v *ViewDB) CreateTable(ctx context.Context, name string, columns []config.ViewColumn) error {
+	if err := v.DropTable(ctx, name); err != nil {
+		return err
+	}
+
+	defs := []string{"_offset INTEGER PRIMARY KEY", "_timestamp INTEGER", "_key TEXT", "_value TEXT"}
+	cols := []string{"_offset", "_timestamp", "_key", "_value"}
+	vals := []string{"?1", "?2", "?3", "?4"}
+	for i, c := range columns {
+		defs = append(defs, c.Name)
+		cols = append(cols, c.Name)
+		// Values that are not JSON leave every extracted column NULL
+		vals = append(vals, fmt.Sprintf("json_extract(CASE WHEN json_valid(?4) THEN ?4 END, ?%d)", i+5))
+	}
+	if _, err := v.db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s (%s)", name, strings.Join(defs, ", "))); err != nil {
+		return fmt.Errorf("create view table %s: %w", name, err)
+	}
+	stmt, err := v.db.PrepareContext(ctx, fmt.Sprintf("INSERT OR REPLACE INTO %s (%s) VALUES (%s)",
+		name, strings.Join(cols, ", "), strings.Join(vals, ", ")))
+	if err != nil {
+		return fmt.Errorf("prepare view table %s: %w", name, err)
+	}
+	v.mu.Lock()
+	v.inserts[name] = stmt
+	v.mu.Unlock()
+	return nil
+}
+
+// DropTable drops a view's table
+func (v *ViewDB) DropTable(ctx context.Context, name string) error {
+	v.mu.Lock()
+	stmt := v.inserts[name]
+	delete(v.inserts, name)
+	v.mu.Unlock()
+	if stmt != nil {
+		stmt.Close()
+	}
+	if _, err := v.db.ExecContext(ctx, "DROP TABLE IF EXISTS "+name); err != nil {
+		return fmt.Errorf("drop view table %s: %w", name, err)
+	}
+	return nil
+}
+
+// Size returns the bytes the view tables take in memory
+func (v *ViewDB) Size(ctx context.Context) (int64, error) {
+	var pageCount, pageSize int64
+	if err := v.db.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pageCount); err != nil {
+		return 0, fmt.Errorf("read view page count: %w", err)
+	}
+	if err := v.db.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize); err != nil {
+		return 0, fmt.Errorf("read view page size: %w", err)
+	}
+	return pageCount * pageSize, nil
+}
+
+// Insert adds a message to a view's table, replacing the row at the same
+// offset. paths are the JSON paths of the view's columns, in order.
+func (v *ViewDB) Insert(ctx context.Context, name string, offset, timestamp int64, key, value []byte, paths []string) error {
+	v.mu.Lock()
+	stmt := v.inserts[name]
+	v.mu.Unlock()
+	if stmt == nil {
+		return fmt.Errorf("view table %s does not exist", name)
+	}
+	args := []interface{}{offset, timestamp, nullableText(key), nullableText(value)}
+	for _, p := range paths {
+		args = append(args, p)
+	}
+	_, err := stmt.ExecContext(ctx, args...)
+	return err
+}
+
+// Query runs a read-only SQL query over the view tables, returning at
+// most limit rows. A query still running after ViewQueryTimeout is
+// interrupted.
+func (v *ViewDB) Query(ctx context.Context, query string, limit int) (*ViewRows, error) {
+	limit = min(max(limit, 1), MaxViewRows)
+	qctx, cancel := context.WithTimeout(ctx, ViewQueryTimeout)
+	defer cancel()
+	rows, err := v.query(qctx, query, limit)
+	if err != nil && ctx.Err() == nil && qctx.Err() != nil {
+		return nil, fmt.Errorf("query ran longer than %v", ViewQueryTimeout)
+	}
+	return rows, err
+}
+
+func (v *ViewDB) query(ctx context.Context, query string, limit int) (*ViewRows, error) {
+	conn, err := v.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := setViewAuthorizer(conn, readOnlyAuthorizer); err != nil {
+		return nil, err
+	}
+	defer setViewAuthorizer(conn, nil)
+
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := &ViewRows{Columns: cols, Rows: [][]interface{}{}}
+	for rows.Next() {
+		if len(result.Rows) == limit {
+			result.Truncated = true
+			break
+		}
+		row := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range row {
+			ptrs[i] = &row[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		for i, val := range row {
+			if b, ok := val.([]byte); ok {
+				row[i] = string(b)
+			}
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result, rows.Err()
+}
+
+// sqliteRecursive is SQLITE_RECURSIVE, which the driver does not export
+const sqliteRecursive = 33
+
+// readOnlyAuthorizer lets statements read tables and call functions and
+// nothing else, so a query cannot write, attach or run pragmas even when
+// it holds several statements
+func readOnlyAuthorizer(op int, _, _, _ string) int {
+	switch op {
+	case sqlite3.SQLITE_SELECT, sqlite3.SQLITE_READ, sqlite3.SQLITE_FUNCTION, sqliteRecursive:
+		return sqlite3.SQLITE_OK
+	}
+	return sqlite3.SQLITE_DENY
+}
+
+// setViewAuthorizer sets or, with nil, clears the authorizer of conn
+func setViewAuthorizer(conn *sql.Conn, auth func(int, string, string, string) int) error {
+	return conn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*sqlite3.SQLiteConn)
+		if !ok {
+			return fmt.Errorf("unexpected driver connection %T", driverConn)
+		}
+		c.RegisterAuthorizer(auth)
+		return nil
+	})
+}
+
+// nullableText stores b as TEXT, or NULL when nil
+func nullableText(b []byte) interface{} {
+	if b == nil {
+		return nil
+	}
+	return string(b)
+}
@@ -0,0 +1,93 @@
+// Package broker runs monolog inside a Go program. A test starts one,
+// points the code under test at its Kafka listener and reads back what
+// was produced with Drain, without a Kafka client or a second process.
+package broker
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+	"github.com/rizkyandriawan/monolog/internal/engine"
+	"github.com/rizkyandriawan/monolog/internal/server"
+	_ "github.com/rizkyandriawan/monolog/internal/store" // registers the SQLite backends
+	"github.com/rizkyandriawan/monolog/pkg/store"
+)
+
+// Config is the broker's configuration, laid out as in the config file
+type Config = config.Config
+
+// Message is a drained message, decoded from the batch it was stored in
+type Message = engine.Message
+
+// DrainHandler receives drained messages. Returning an error stops the
+// drain; ErrStopDrain stops it cleanly.
+type DrainHandler = engine.DrainHandler
+
+// ErrStopDrain is returned by a DrainHandler to end a drain without error
+var ErrStopDrain = engine.ErrStopDrain
+
+// DefaultConfig returns the default configuration, keeping data in memory
+func DefaultConfig() *Config {
+	cfg := config.Default()
+	cfg.Storage.Backend = "sqlite:memory"
+	return cfg
+}
+
+// Broker is an engine running over its own storage
+type Broker struct {
+	config  *Config
+	engine  *engine.Engine
+	backend *store.Backend
+	kafka   *server.KafkaServer
+}
+
+// Start opens the configured storage backend and starts the engine. The
+// data directory of a disk backend is not locked, so give each broker
+// one of its own.
+func Start(cfg *Config) (*Broker, error) {
+	backend, err := store.Open(cfg.Storage.Backend, cfg.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("open storage: %w", err)
+	}
+	eng := engine.New(cfg, backend.Topics, backend.Groups)
+	eng.Start()
+
+	kafka, err := server.NewKafkaServer(cfg, eng)
+	if err != nil {
+		eng.Stop()
+		backend.Close()
+		return nil, fmt.Errorf("kafka server: %w", err)
+	}
+	return &Broker{config: cfg, engine: eng, backend: backend, kafka: kafka}, nil
+}
+
+// ListenKafka serves the Kafka protocol on a free port on the loopback
+// interface, advertising it to clients in place of server.kafka_addr, and
+// returns its address for the code under test to bootstrap from
+func (b *Broker) ListenKafka() (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	b.config.Server.KafkaAddr = ln.Addr().String()
+	go b.kafka.Serve(ln)
+	return ln.Addr().String(), nil
+}
+
+// Drain calls handler with every message of topic from offset from on, in
+// offset order, and keeps following the topic as messages arrive until ctx
+// is done or handler stops it. Nothing further is read until handler
+// returns. It returns nil when stopped with ErrStopDrain, the handler's
+// error otherwise, or ctx's error.
+func (b *Broker) Drain(ctx context.Context, topic string, from int64, handler DrainHandler) error {
+	return b.engine.Drain(ctx, topic, from, handler)
+}
+
+// Close stops serving, stops the engine and closes the storage
+func (b *Broker) Close() error {
+	b.kafka.Close()
+	b.engine.Stop()
+	return b.backend.Close()
+}
@@ -0,0 +1,624 @@
+// Package client is a Go client for the Monolog HTTP API (v2).
+//
+// It covers producing, consuming with consumer-group offset tracking,
+// admin operations and live streaming over Server-Sent Events, so tools
+// don't each have to reimplement the REST calls. PurgeKafka is the
+// exception: it speaks the Kafka protocol, for test suites that cannot
+// reach the HTTP port.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client talks to a Monolog HTTP API
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithToken sets the bearer token sent with every request
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithHTTPClient replaces the underlying http.Client
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// New creates a client for the server at baseURL (e.g. "http://localhost:8080")
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Error is returned for non-2xx responses and mirrors the v2 error envelope
+type Error struct {
+	StatusCode int                    `json:"-"`
+	Code       string                 `json:"code"`
+	Message    string                 `json:"message"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	RequestID  string                 `json:"request_id"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("monolog: %s (%d %s, request %s)", e.Message, e.StatusCode, e.Code, e.RequestID)
+}
+
+// IsNotFound reports whether err is a not-found API error
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*Error)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+// Message is a single message read from a topic
+type Message struct {
+	Offset    int64  `json:"offset"`
+	Timestamp int64  `json:"timestamp"`
+	Key       string `json:"key"`
+	Value     string `json:"value"`
+	Codec     int8   `json:"codec"`
+}
+
+// ProduceMessage is a message to produce
+type ProduceMessage struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Topic describes a topic
+type Topic struct {
+	Name           string            `json:"name"`
+	LatestOffset   int64             `json:"latest_offset"`
+	EarliestOffset int64             `json:"earliest_offset"`
+	CreatedAt      time.Time         `json:"created_at"`
+	Config         map[string]string `json:"config,omitempty"` // set by GetTopic
+}
+
+// GroupSummary is a group as listed by ListGroups
+type GroupSummary struct {
+	ID         string `json:"id"`
+	State      string `json:"state"`
+	Generation int32  `json:"generation"`
+	Members    int    `json:"members"`
+
+	Commits CommitStats `json:"commits"`
+}
+
+// CommitStats describes how often a group commits. Intervals are between
+// commits of the same topic, over the group's last 1024 of them.
+type CommitStats struct {
+	Commits    int64      `json:"commits"` // since the server started
+	LastCommit *time.Time `json:"last_commit,omitempty"`
+	P50Ms      *float64   `json:"interval_p50_ms,omitempty"`
+	P99Ms      *float64   `json:"interval_p99_ms,omitempty"`
+	MaxMs      *float64   `json:"interval_max_ms,omitempty"`
+	Stalled    bool       `json:"stalled"` // has members but no recent commit
+	StalledFor string     `json:"stalled_for,omitempty"`
+}
+
+// Group is the full state of a consumer group
+type Group struct {
+	ID         string            `json:"id"`
+	State      string            `json:"state"`
+	Generation int32             `json:"generation"`
+	LeaderID   string            `json:"leader_id"`
+	Protocol   string            `json:"protocol"`
+	Members    map[string]Member `json:"members"`
+	Offsets    map[string]int64  `json:"offsets"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+// Member is a consumer group member
+type Member struct {
+	ID            string    `json:"id"`
+	ClientID      string    `json:"client_id"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+}
+
+// OffsetCheckpoint is a group's committed offsets, as written by
+// ExportOffsets and read by ImportOffsets
+type OffsetCheckpoint struct {
+	Group      string           `json:"group"`
+	ExportedAt time.Time        `json:"exported_at"`
+	Offsets    map[string]int64 `json:"offsets"`
+}
+
+// OffsetImport reports what ImportOffsets committed
+type OffsetImport struct {
+	Group    string           `json:"group"`
+	Imported map[string]int64 `json:"imported"`
+	Skipped  []string         `json:"skipped,omitempty"` // topics the server does not have
+}
+
+// OffsetReset sets many committed offsets of a group at once, each to an
+// offset or to the first message at or after a timestamp (Unix ms). Keys
+// are "topic" for partition 0 and "topic:N" otherwise.
+type OffsetReset struct {
+	Generation int32            `json:"generation"` // -1 resets whatever the group's generation
+	Offsets    map[string]int64 `json:"offsets,omitempty"`
+	Timestamps map[string]int64 `json:"timestamps,omitempty"`
+	DryRun     bool             `json:"dry_run,omitempty"`
+}
+
+// OffsetResetResult reports what ResetOffsets committed, or would have
+type OffsetResetResult struct {
+	Group      string           `json:"group"`
+	Generation int32            `json:"generation"`
+	Previous   map[string]int64 `json:"previous"`
+	Offsets    map[string]int64 `json:"offsets"`
+	DryRun     bool             `json:"dry_run,omitempty"`
+}
+
+// Import is the state of an import session, as returned by StartImport,
+// ImportChunk, GetImport and CompleteImport
+type Import struct {
+	ID        string    `json:"id"`
+	Topic     string    `json:"topic"`
+	Offset    int64     `json:"offset"`   // bytes received; the next chunk starts here
+	Pending   int       `json:"pending"`  // bytes of an unterminated last line
+	Messages  int64     `json:"messages"` // messages appended so far
+	Scrubbed  int64     `json:"scrubbed,omitempty"`
+	First     int64     `json:"first_offset"` // -1 before any message
+	Last      int64     `json:"last_offset"`
+	SHA256    string    `json:"sha256"` // of the bytes received so far
+	Completed bool      `json:"completed,omitempty"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+}
+
+// GenerateSpec describes synthetic messages for Generate. Keys, values and
+// headers are Go templates with fake-data functions; see the README.
+type GenerateSpec struct {
+	Count      int                 `json:"count"`
+	Seed       int64               `json:"seed,omitempty"`
+	SeqStart   int64               `json:"seq_start,omitempty"`
+	Key        string              `json:"key,omitempty"`
+	Value      string              `json:"value,omitempty"`
+	Headers    map[string]string   `json:"headers,omitempty"`
+	Size       *GenerateSize       `json:"size,omitempty"`
+	Timestamps *GenerateTimestamps `json:"timestamps,omitempty"`
+}
+
+// GenerateSize is the distribution of generated value sizes in bytes
+type GenerateSize struct {
+	Distribution string `json:"distribution"` // fixed, uniform, normal or exponential
+	Min          int    `json:"min,omitempty"`
+	Max          int    `json:"max,omitempty"`
+	Mean         int    `json:"mean,omitempty"`
+	StdDev       int    `json:"stddev,omitempty"`
+}
+
+// GenerateTimestamps spreads generated timestamps over a window ending at End
+type GenerateTimestamps struct {
+	Spread string    `json:"spread"` // Go duration, e.g. 24h
+	End    time.Time `json:"end,omitempty"`
+	Order  string    `json:"order,omitempty"` // even or random
+}
+
+// GenerateResult reports what Generate produced
+type GenerateResult struct {
+	Offset int64 `json:"offset"` // offset of the first message
+	Count  int   `json:"count"`
+	Bytes  int   `json:"bytes"` // keys and values
+	Seed   int64 `json:"seed"`  // reproduces the same messages
+}
+
+// Stats is the server summary returned by /stats
+type Stats struct {
+	Topics        int           `json:"topics"`
+	Groups        int           `json:"groups"`
+	Pending       int           `json:"pending"`
+	Connections   int           `json:"connections"`
+	RecentErrors  []RecentError `json:"recent_errors"`
+	Panics        int64         `json:"panics"`         // recovered request and scheduler panics since startup
+	StalledGroups []string      `json:"stalled_groups"` // groups with members that stopped committing
+}
+
+// RecentError is a recent Kafka request failure reported by /stats
+type RecentError struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"` // client ID of the failing request
+	Message string    `json:"message"`
+}
+
+// ============================================================================
+// Messages
+// ============================================================================
+
+// Produce appends a single message and returns its offset
+func (c *Client) Produce(ctx context.Context, topic string, msg ProduceMessage) (int64, error) {
+	var resp struct {
+		Offset int64 `json:"offset"`
+	}
+	err := c.do(ctx, http.MethodPost, "/topics/"+url.PathEscape(topic)+"/messages", msg, &resp)
+	return resp.Offset, err
+}
+
+// ProduceBatch appends messages in one request and returns the base offset;
+// message i is stored at base+i
+func (c *Client) ProduceBatch(ctx context.Context, topic string, msgs []ProduceMessage) (int64, error) {
+	var resp struct {
+		Offset int64 `json:"offset"`
+	}
+	err := c.do(ctx, http.MethodPost, "/topics/"+url.PathEscape(topic)+"/messages", msgs, &resp)
+	return resp.Offset, err
+}
+
+// ProduceRecordBatches appends already-encoded Kafka v2 record batches
+// byte-for-byte and returns the offset of the first record
+func (c *Client) ProduceRecordBatches(ctx context.Context, topic string, data []byte) (int64, error) {
+	var resp struct {
+		Offset int64 `json:"offset"`
+	}
+	body := rawBody{contentType: "application/vnd.kafka.recordbatch", data: data}
+	err := c.do(ctx, http.MethodPost, "/topics/"+url.PathEscape(topic)+"/batches", body, &resp)
+	return resp.Offset, err
+}
+
+// Generate produces synthetic messages on the server
+func (c *Client) Generate(ctx context.Context, topic string, spec GenerateSpec) (*GenerateResult, error) {
+	var result GenerateResult
+	if err := c.do(ctx, http.MethodPost, "/topics/"+url.PathEscape(topic)+"/generate", spec, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Fetch reads up to limit messages starting at offset
+func (c *Client) Fetch(ctx context.Context, topic string, offset int64, limit int) ([]Message, error) {
+	q := url.Values{}
+	q.Set("offset", fmt.Sprint(offset))
+	q.Set("limit", fmt.Sprint(limit))
+
+	var msgs []Message
+	err := c.do(ctx, http.MethodGet, "/topics/"+url.PathEscape(topic)+"/messages?"+q.Encode(), nil, &msgs)
+	if err != nil {
+		return nil, err
+	}
+
+	// A stored batch can start before the requested offset
+	filtered := msgs[:0]
+	for _, m := range msgs {
+		if m.Offset >= offset {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered, nil
+}
+
+// Match selects messages for Contains and Count. Nil fields match anything.
+type Match struct {
+	Key       *string
+	Value     *string
+	ValueHash string // hex SHA-256 of the value
+}
+
+func (m Match) query() url.Values {
+	q := url.Values{}
+	if m.Key != nil {
+		q.Set("key", *m.Key)
+	}
+	if m.Value != nil {
+		q.Set("value", *m.Value)
+	}
+	if m.ValueHash != "" {
+		q.Set("value_hash", m.ValueHash)
+	}
+	return q
+}
+
+// Contains returns the offset of the first message appended since the given
+// time (zero for the beginning) that matches, or -1 if there is none
+func (c *Client) Contains(ctx context.Context, topic string, match Match, since time.Time) (int64, error) {
+	q := match.query()
+	if !since.IsZero() {
+		q.Set("since", fmt.Sprint(since.UnixMilli()))
+	}
+	var resp struct {
+		Found  bool  `json:"found"`
+		Offset int64 `json:"offset"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/topics/"+url.PathEscape(topic)+"/contains?"+q.Encode(), nil, &resp); err != nil {
+		return -1, err
+	}
+	if !resp.Found {
+		return -1, nil
+	}
+	return resp.Offset, nil
+}
+
+// Count counts the matching messages appended within [from, to); zero times
+// leave that end open
+func (c *Client) Count(ctx context.Context, topic string, match Match, from, to time.Time) (int, error) {
+	q := match.query()
+	if !from.IsZero() {
+		q.Set("from", fmt.Sprint(from.UnixMilli()))
+	}
+	if !to.IsZero() {
+		q.Set("to", fmt.Sprint(to.UnixMilli()))
+	}
+	var resp struct {
+		Count int `json:"count"`
+	}
+	err := c.do(ctx, http.MethodGet, "/topics/"+url.PathEscape(topic)+"/count?"+q.Encode(), nil, &resp)
+	return resp.Count, err
+}
+
+// ============================================================================
+// Admin
+// ============================================================================
+
+// ListTopics lists all topics
+func (c *Client) ListTopics(ctx context.Context) ([]Topic, error) {
+	var topics []Topic
+	err := c.do(ctx, http.MethodGet, "/topics", nil, &topics)
+	return topics, err
+}
+
+// GetTopic returns topic details
+func (c *Client) GetTopic(ctx context.Context, name string) (*Topic, error) {
+	var topic Topic
+	if err := c.do(ctx, http.MethodGet, "/topics/"+url.PathEscape(name), nil, &topic); err != nil {
+		return nil, err
+	}
+	return &topic, nil
+}
+
+// CreateTopic creates a topic
+func (c *Client) CreateTopic(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodPost, "/topics", map[string]string{"name": name}, nil)
+}
+
+// CreateTopicWithPreset creates a topic from a named preset ("queue",
+// "changelog", "metrics" or one from topics.presets); config entries such
+// as retention.ms override the preset's
+func (c *Client) CreateTopicWithPreset(ctx context.Context, name, preset string, config map[string]string) error {
+	body := map[string]interface{}{"name": name, "preset": preset, "config": config}
+	return c.do(ctx, http.MethodPost, "/topics", body, nil)
+}
+
+// DeleteTopic deletes a topic and its messages
+func (c *Client) DeleteTopic(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/topics/"+url.PathEscape(name), nil, nil)
+}
+
+// ListGroups lists consumer groups
+func (c *Client) ListGroups(ctx context.Context) ([]GroupSummary, error) {
+	var groups []GroupSummary
+	err := c.do(ctx, http.MethodGet, "/groups", nil, &groups)
+	return groups, err
+}
+
+// GetGroup returns the full state of a group
+func (c *Client) GetGroup(ctx context.Context, id string) (*Group, error) {
+	var group Group
+	if err := c.do(ctx, http.MethodGet, "/groups/"+url.PathEscape(id), nil, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// DeleteGroup deletes a group and its committed offsets
+func (c *Client) DeleteGroup(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/groups/"+url.PathEscape(id), nil, nil)
+}
+
+// CommittedOffset returns the group's committed offset for a topic, or -1
+func (c *Client) CommittedOffset(ctx context.Context, group, topic string) (int64, error) {
+	var resp struct {
+		Offset int64 `json:"offset"`
+	}
+	err := c.do(ctx, http.MethodGet, "/groups/"+url.PathEscape(group)+"/offsets/"+url.PathEscape(topic), nil, &resp)
+	if IsNotFound(err) {
+		return -1, nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	return resp.Offset, nil
+}
+
+// CommitOffset commits the next offset the group should consume from topic
+func (c *Client) CommitOffset(ctx context.Context, group, topic string, offset int64) error {
+	body := map[string]int64{"offset": offset}
+	return c.do(ctx, http.MethodPost, "/groups/"+url.PathEscape(group)+"/offsets/"+url.PathEscape(topic), body, nil)
+}
+
+// ExportOffsets returns a checkpoint of every offset the group has committed
+func (c *Client) ExportOffsets(ctx context.Context, group string) (*OffsetCheckpoint, error) {
+	var checkpoint OffsetCheckpoint
+	if err := c.do(ctx, http.MethodGet, "/groups/"+url.PathEscape(group)+"/offsets", nil, &checkpoint); err != nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+// ImportOffsets commits a checkpoint's offsets to group, which need not be
+// the group it was exported from. The import fails if a topic is missing
+// on the server unless skipMissing is set, in which case those topics are
+// left out.
+func (c *Client) ImportOffsets(ctx context.Context, group string, checkpoint *OffsetCheckpoint, skipMissing bool) (*OffsetImport, error) {
+	path := "/groups/" + url.PathEscape(group) + "/offsets"
+	if skipMissing {
+		path += "?skip_missing=true"
+	}
+	var result OffsetImport
+	if err := c.do(ctx, http.MethodPut, path, checkpoint, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ResetOffsets commits reset's offsets to group together or not at all.
+// The group must have no active members and, unless reset.Generation is
+// -1, still be at that generation.
+func (c *Client) ResetOffsets(ctx context.Context, group string, reset *OffsetReset) (*OffsetResetResult, error) {
+	var result OffsetResetResult
+	if err := c.do(ctx, http.MethodPost, "/groups/"+url.PathEscape(group)+"/reset-offsets", reset, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// StartImport opens a session importing newline-delimited JSON, in the
+// format the HTTP export writes, into topic
+func (c *Client) StartImport(ctx context.Context, topic string) (*Import, error) {
+	var imp Import
+	if err := c.do(ctx, http.MethodPost, "/topics/"+url.PathEscape(topic)+"/import", nil, &imp); err != nil {
+		return nil, err
+	}
+	return &imp, nil
+}
+
+// ImportChunk uploads the bytes of an import starting at offset. Chunks may
+// split lines. A non-empty sha256 (hex) is checked against the chunk.
+func (c *Client) ImportChunk(ctx context.Context, id string, offset int64, chunk []byte, sha256 string) (*Import, error) {
+	q := url.Values{"offset": {strconv.FormatInt(offset, 10)}}
+	if sha256 != "" {
+		q.Set("sha256", sha256)
+	}
+	var imp Import
+	body := rawBody{contentType: "application/x-ndjson", data: chunk}
+	if err := c.do(ctx, http.MethodPut, "/imports/"+url.PathEscape(id)+"?"+q.Encode(), body, &imp); err != nil {
+		return nil, err
+	}
+	return &imp, nil
+}
+
+// GetImport returns an import session, to learn where a broken upload
+// resumes
+func (c *Client) GetImport(ctx context.Context, id string) (*Import, error) {
+	var imp Import
+	if err := c.do(ctx, http.MethodGet, "/imports/"+url.PathEscape(id), nil, &imp); err != nil {
+		return nil, err
+	}
+	return &imp, nil
+}
+
+// CompleteImport appends an unterminated last line and closes the session.
+// A non-empty sha256 (hex) is checked against the whole upload.
+func (c *Client) CompleteImport(ctx context.Context, id, sha256 string) (*Import, error) {
+	path := "/imports/" + url.PathEscape(id) + "/complete"
+	if sha256 != "" {
+		path += "?sha256=" + url.QueryEscape(sha256)
+	}
+	var imp Import
+	if err := c.do(ctx, http.MethodPost, path, nil, &imp); err != nil {
+		return nil, err
+	}
+	return &imp, nil
+}
+
+// ReloadSecrets makes the server re-read its config file and master key and
+// switch to the tokens found there
+func (c *Client) ReloadSecrets(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/admin/secrets/reload", nil, nil)
+}
+
+// Stats returns server counters
+func (c *Client) Stats(ctx context.Context) (*Stats, error) {
+	var stats Stats
+	if err := c.do(ctx, http.MethodGet, "/stats", nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// ============================================================================
+// Transport
+// ============================================================================
+
+// rawBody is a request body sent as-is instead of JSON-encoded
+type rawBody struct {
+	contentType string
+	data        []byte
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	contentType := "application/json"
+	switch b := body.(type) {
+	case nil:
+	case rawBody:
+		reader = bytes.NewReader(b.data)
+		contentType = b.contentType
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+"/api/v2"+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return req, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	req, err := c.newRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return decodeError(resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func decodeError(resp *http.Response) error {
+	data, _ := io.ReadAll(resp.Body)
+
+	var envelope struct {
+		Error Error `json:"error"`
+	}
+	apiErr := &Error{}
+	if json.Unmarshal(data, &envelope) == nil && envelope.Error.Code != "" {
+		*apiErr = envelope.Error
+	} else {
+		apiErr.Message = strings.TrimSpace(string(data))
+		apiErr.RequestID = resp.Header.Get("X-Request-ID")
+	}
+	apiErr.StatusCode = resp.StatusCode
+	return apiErr
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HandlerFunc processes one message. Returning an error stops consumption;
+// the failing message is not committed.
+type HandlerFunc func(Message) error
+
+// ConsumeOptions tunes Consume
+type ConsumeOptions struct {
+	BatchSize    int           // messages per fetch (default 100)
+	PollInterval time.Duration // wait when caught up (default 500ms)
+}
+
+// Consume reads topic as a member of group, starting at the group's committed
+// offset and committing after each processed batch (at-least-once). It runs
+// until ctx is cancelled or the handler returns an error.
+func (c *Client) Consume(ctx context.Context, topic, group string, opts ConsumeOptions, handler HandlerFunc) error {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 500 * time.Millisecond
+	}
+
+	offset, err := c.CommittedOffset(ctx, group, topic)
+	if err != nil {
+		return err
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	for {
+		msgs, err := c.Fetch(ctx, topic, offset, opts.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		next := offset
+		for _, msg := range msgs {
+			if err := handler(msg); err != nil {
+				if next > offset {
+					c.CommitOffset(ctx, group, topic, next)
+				}
+				return err
+			}
+			next = msg.Offset + 1
+		}
+
+		if next > offset {
+			if err := c.CommitOffset(ctx, group, topic, next); err != nil {
+				return err
+			}
+			offset = next
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(opts.PollInterval):
+		}
+	}
+}
+
+// Stream follows topic from offset over Server-Sent Events, calling handler
+// for each message as it is produced. It returns when ctx is cancelled, the
+// server ends the stream, or the handler returns an error.
+func (c *Client) Stream(ctx context.Context, topic string, offset int64, handler HandlerFunc) error {
+	path := "/topics/" + url.PathEscape(topic) + "/stream?offset=" + fmt.Sprint(offset)
+	req, err := c.newRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	// Streams are long-lived; the client-wide timeout would cut them off
+	hc := *c.httpClient
+	hc.Timeout = 0
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return decodeError(resp)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var event, data string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			// Blank line dispatches the event
+			if err := dispatchEvent(event, data, handler); err != nil {
+				return err
+			}
+			event, data = "", ""
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return scanner.Err()
+}
+
+func dispatchEvent(event, data string, handler HandlerFunc) error {
+	switch event {
+	case "message":
+		var msg Message
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			return fmt.Errorf("decode stream message: %w", err)
+		}
+		return handler(msg)
+	case "error":
+		var text string
+		json.Unmarshal([]byte(data), &text)
+		return fmt.Errorf("monolog: stream error: %s", text)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/pkg/kafkaproto"
+)
+
+// PurgeResult is what PurgeKafka did to one topic
+type PurgeResult struct {
+	Topic          string
+	DeletedRows    int   // stored rows removed; a row can hold a whole batch
+	LogStartOffset int64 // earliest offset left
+}
+
+// purgeClientID is the client ID, and SASL user, purges are sent as
+const purgeClientID = "monolog-go"
+
+// PurgeKafka deletes the messages of topics older than before, or all of
+// them when before is the zero time, over the Kafka protocol. It is meant
+// for test suites that can reach only a broker's Kafka port; the broker
+// must run with server.kafka_purge enabled. It does not authenticate, so
+// against a broker with security enabled use Client.PurgeKafka.
+func PurgeKafka(ctx context.Context, kafkaAddr string, before time.Time, topics ...string) ([]PurgeResult, error) {
+	return purgeKafka(ctx, kafkaAddr, "", before, topics)
+}
+
+// PurgeKafka is the package's PurgeKafka, authenticating with SASL PLAIN
+// first when the client has a token, which must be the broker's
+// security.token
+func (c *Client) PurgeKafka(ctx context.Context, kafkaAddr string, before time.Time, topics ...string) ([]PurgeResult, error) {
+	return purgeKafka(ctx, kafkaAddr, c.token, before, topics)
+}
+
+func purgeKafka(ctx context.Context, kafkaAddr, token string, before time.Time, topics []string) ([]PurgeResult, error) {
+	req := &kafkaproto.MonologPurgeRequest{}
+	for _, t := range topics {
+		beforeMs := kafkaproto.PurgeAll
+		if !before.IsZero() {
+			beforeMs = before.UnixMilli()
+		}
+		req.Topics = append(req.Topics, kafkaproto.MonologPurgeRequestTopic{Name: t, BeforeMs: beforeMs})
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", kafkaAddr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(30 * time.Second))
+	}
+	if token != "" {
+		if err := authenticatePlain(conn, purgeClientID, token); err != nil {
+			return nil, err
+		}
+	}
+
+	const correlationID = 3
+	enc := kafkaproto.NewEncoder()
+	enc.WriteRequestHeader(kafkaproto.RequestHeader{
+		APIKey:        kafkaproto.APIKeyMonologPurge,
+		CorrelationID: correlationID,
+		ClientID:      purgeClientID,
+	})
+	kafkaproto.EncodeMonologPurgeRequest(enc, 0, req)
+	if _, err := conn.Write(kafkaproto.Frame(enc.Bytes())); err != nil {
+		return nil, err
+	}
+
+	body, err := kafkaproto.ReadFrame(conn, 1<<20)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if len(body) < 4 {
+		return nil, fmt.Errorf("invalid response size %d", len(body))
+	}
+	if got := int32(binary.BigEndian.Uint32(body)); got != correlationID {
+		return nil, fmt.Errorf("unexpected correlation id %d", got)
+	}
+	if len(body) == 6 {
+		// A bare error code: the API is disabled or not known at all
+		code := int16(binary.BigEndian.Uint16(body[4:]))
+		return nil, fmt.Errorf("monolog: purge rejected with error code %d (is server.kafka_purge enabled, and a token given if security is?)", code)
+	}
+
+	resp, err := kafkaproto.DecodeMonologPurgeResponse(kafkaproto.NewDecoder(bytes.NewReader(body[4:])), 0)
+	if err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	results := make([]PurgeResult, 0, len(resp.Topics))
+	var failed []string
+	for _, t := range resp.Topics {
+		if t.ErrorCode != kafkaproto.ErrNone {
+			failed = append(failed, fmt.Sprintf("%s (error code %d)", t.Name, t.ErrorCode))
+			continue
+		}
+		results = append(results, PurgeResult{Topic: t.Name, DeletedRows: int(t.DeletedRows), LogStartOffset: t.LogStartOffset})
+	}
+	if len(failed) > 0 {
+		return results, fmt.Errorf("monolog: purge failed for %s", strings.Join(failed, ", "))
+	}
+	return results, nil
+}
+
+// authenticatePlain runs a SASL PLAIN exchange on conn: SaslHandshake v1
+// then SaslAuthenticate v1, both with non-flexible headers
+func authenticatePlain(conn net.Conn, user, password string) error {
+	dec, err := roundTrip(conn, kafkaproto.APIKeySaslHandshake, 1, 1, func(e *kafkaproto.Encoder) {
+		e.WriteString("PLAIN")
+	})
+	if err != nil {
+		return fmt.Errorf("sasl handshake: %w", err)
+	}
+	if code, err := dec.ReadInt16(); err != nil || code != kafkaproto.ErrNone {
+		return fmt.Errorf("sasl handshake: PLAIN rejected with error code %d", code)
+	}
+
+	dec, err = roundTrip(conn, kafkaproto.APIKeySaslAuthenticate, 1, 2, func(e *kafkaproto.Encoder) {
+		e.WriteBytes([]byte("\x00" + user + "\x00" + password))
+	})
+	if err != nil {
+		return fmt.Errorf("sasl authenticate: %w", err)
+	}
+	code, err := dec.ReadInt16()
+	if err != nil {
+		return fmt.Errorf("sasl authenticate: %w", err)
+	}
+	if code != kafkaproto.ErrNone {
+		msg, _ := dec.ReadNullableString()
+		if msg != nil {
+			return fmt.Errorf("monolog: sasl authentication failed: %s", *msg)
+		}
+		return fmt.Errorf("monolog: sasl authentication failed with error code %d", code)
+	}
+	return nil
+}
+
+// roundTrip sends one request with a v1 request header and returns a
+// decoder over the response body, past its v0 header
+func roundTrip(conn net.Conn, apiKey, version int16, correlationID int32, body func(e *kafkaproto.Encoder)) (*kafkaproto.Decoder, error) {
+	enc := kafkaproto.NewEncoder()
+	enc.WriteRequestHeader(kafkaproto.RequestHeader{
+		APIKey:        apiKey,
+		APIVersion:    version,
+		CorrelationID: correlationID,
+		ClientID:      purgeClientID,
+	})
+	body(enc)
+	if _, err := conn.Write(kafkaproto.Frame(enc.Bytes())); err != nil {
+		return nil, err
+	}
+	resp, err := kafkaproto.ReadFrame(conn, 1<<20)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	dec := kafkaproto.NewDecoder(bytes.NewReader(resp))
+	h, err := dec.ReadResponseHeader(apiKey, version)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if h.CorrelationID != correlationID {
+		return nil, fmt.Errorf("unexpected correlation id %d", h.CorrelationID)
+	}
+	return dec, nil
+}
@@ -0,0 +1,92 @@
+package kafkaproto
+
+// ============================================================================
+// AddOffsetsToTxn (API Key 25)
+// Supported versions: 0-3
+// ============================================================================
+
+// ----------------------------------------------------------------------------
+// Request
+// ----------------------------------------------------------------------------
+
+type AddOffsetsToTxnRequest struct {
+	TransactionalID string
+	ProducerID      int64
+	ProducerEpoch   int16
+	GroupID         string
+}
+
+// Request Readers
+
+func (r *AddOffsetsToTxnRequest) readTransactionalID(d *Decoder, flexible bool) {
+	if flexible {
+		r.TransactionalID, _ = d.ReadCompactString()
+	} else {
+		r.TransactionalID, _ = d.ReadString()
+	}
+}
+
+func (r *AddOffsetsToTxnRequest) readProducer(d *Decoder) {
+	r.ProducerID, _ = d.ReadInt64()
+	r.ProducerEpoch, _ = d.ReadInt16()
+}
+
+func (r *AddOffsetsToTxnRequest) readGroupID(d *Decoder, flexible bool) {
+	if flexible {
+		r.GroupID, _ = d.ReadCompactString()
+	} else {
+		r.GroupID, _ = d.ReadString()
+	}
+}
+
+func (r *AddOffsetsToTxnRequest) readTaggedFields(d *Decoder) {
+	d.ReadUVarInt()
+}
+
+// Decode - the recipe
+
+func DecodeAddOffsetsToTxnRequest(d *Decoder, v int16) (*AddOffsetsToTxnRequest, error) {
+	r := &AddOffsetsToTxnRequest{}
+
+	r.readTransactionalID(d, v >= 3)            // v0+
+	r.readProducer(d)                           // v0+
+	r.readGroupID(d, v >= 3)                    // v0+
+	if v >= 3 {
+		r.readTaggedFields(d)                   // v3+
+	}
+
+	return r, d.Err()
+}
+
+// ----------------------------------------------------------------------------
+// Response
+// ----------------------------------------------------------------------------
+
+type AddOffsetsToTxnResponse struct {
+	ThrottleTimeMs int32
+	ErrorCode      int16
+}
+
+// Response Writers
+
+func (r *AddOffsetsToTxnResponse) writeThrottleTime(e *Encoder) {
+	e.WriteInt32(r.ThrottleTimeMs)
+}
+
+func (r *AddOffsetsToTxnResponse) writeErrorCode(e *Encoder) {
+	e.WriteInt16(r.ErrorCode)
+}
+
+func (r *AddOffsetsToTxnResponse) writeTaggedFields(e *Encoder) {
+	e.WriteEmptyTaggedFields()
+}
+
+// Encode - the recipe
+
+func EncodeAddOffsetsToTxnResponse(e *Encoder, v int16, r *AddOffsetsToTxnResponse) {
+	r.writeThrottleTime(e)                      // v0+
+	r.writeErrorCode(e)                         // v0+
+	if v >= 3 {
+		r.writeTaggedFields(e)                  // v3+
+	}
+}
@@ -0,0 +1,159 @@
+package kafkaproto
+
+// ============================================================================
+// AddPartitionsToTxn (API Key 24)
+// Supported versions: 0-3
+// ============================================================================
+
+// ----------------------------------------------------------------------------
+// Request
+// ----------------------------------------------------------------------------
+
+type AddPartitionsToTxnRequest struct {
+	TransactionalID string
+	ProducerID      int64
+	ProducerEpoch   int16
+	Topics          []AddPartitionsToTxnTopic
+}
+
+type AddPartitionsToTxnTopic struct {
+	Name       string
+	Partitions []int32
+}
+
+// Request Readers
+
+func (r *AddPartitionsToTxnRequest) readProducer(d *Decoder, flexible bool) {
+	if flexible {
+		r.TransactionalID, _ = d.ReadCompactString()
+	} else {
+		r.TransactionalID, _ = d.ReadString()
+	}
+	r.ProducerID, _ = d.ReadInt64()
+	r.ProducerEpoch, _ = d.ReadInt16()
+}
+
+func (r *AddPartitionsToTxnRequest) readTopics(d *Decoder, flexible bool) {
+	var count int
+	if flexible {
+		n, _ := d.ReadUVarInt()
+		count = int(n) - 1
+	} else {
+		n, _ := d.ReadInt32()
+		count = int(n)
+	}
+
+	r.Topics = make([]AddPartitionsToTxnTopic, max(count, 0))
+	for i := range r.Topics {
+		r.Topics[i].readFrom(d, flexible)
+	}
+}
+
+func (t *AddPartitionsToTxnTopic) readFrom(d *Decoder, flexible bool) {
+	var count int
+	if flexible {
+		t.Name, _ = d.ReadCompactString()
+		n, _ := d.ReadUVarInt()
+		count = int(n) - 1
+	} else {
+		t.Name, _ = d.ReadString()
+		n, _ := d.ReadInt32()
+		count = int(n)
+	}
+
+	t.Partitions = make([]int32, max(count, 0))
+	for i := range t.Partitions {
+		t.Partitions[i], _ = d.ReadInt32()
+	}
+
+	if flexible {
+		d.ReadUVarInt()                         // topic tagged fields
+	}
+}
+
+func (r *AddPartitionsToTxnRequest) readTaggedFields(d *Decoder) {
+	d.ReadUVarInt()
+}
+
+// Decode - the recipe
+
+func DecodeAddPartitionsToTxnRequest(d *Decoder, v int16) (*AddPartitionsToTxnRequest, error) {
+	r := &AddPartitionsToTxnRequest{}
+
+	r.readProducer(d, v >= 3)                   // v0+
+	r.readTopics(d, v >= 3)                     // v0+
+	if v >= 3 {
+		r.readTaggedFields(d)                   // v3+
+	}
+
+	return r, d.Err()
+}
+
+// ----------------------------------------------------------------------------
+// Response
+// ----------------------------------------------------------------------------
+
+type AddPartitionsToTxnResponse struct {
+	ThrottleTimeMs int32
+	Topics         []AddPartitionsToTxnTopicResult
+}
+
+type AddPartitionsToTxnTopicResult struct {
+	Name       string
+	Partitions []AddPartitionsToTxnPartitionResult
+}
+
+type AddPartitionsToTxnPartitionResult struct {
+	Index     int32
+	ErrorCode int16
+}
+
+// Response Writers
+
+func (r *AddPartitionsToTxnResponse) writeThrottleTime(e *Encoder) {
+	e.WriteInt32(r.ThrottleTimeMs)
+}
+
+func (r *AddPartitionsToTxnResponse) writeTopics(e *Encoder, version int16) {
+	flexible := version >= 3
+
+	if flexible {
+		e.WriteCompactArrayLen(len(r.Topics))
+	} else {
+		e.WriteArrayLen(len(r.Topics))
+	}
+
+	for _, t := range r.Topics {
+		if flexible {
+			e.WriteCompactString(t.Name)
+			e.WriteCompactArrayLen(len(t.Partitions))
+		} else {
+			e.WriteString(t.Name)
+			e.WriteArrayLen(len(t.Partitions))
+		}
+		for _, p := range t.Partitions {
+			e.WriteInt32(p.Index)
+			e.WriteInt16(p.ErrorCode)
+			if flexible {
+				e.WriteEmptyTaggedFields()          // partition tagged fields
+			}
+		}
+		if flexible {
+			e.WriteEmptyTaggedFields()              // topic tagged fields
+		}
+	}
+}
+
+func (r *AddPartitionsToTxnResponse) writeTaggedFields(e *Encoder) {
+	e.WriteEmptyTaggedFields()
+}
+
+// Encode - the recipe
+
+func EncodeAddPartitionsToTxnResponse(e *Encoder, v int16, r *AddPartitionsToTxnResponse) {
+	r.writeThrottleTime(e)                      // v0+
+	r.writeTopics(e, v)                         // v0+
+	if v >= 3 {
+		r.writeTaggedFields(e)                  // v3+
+	}
+}
@@ -0,0 +1,168 @@
+package kafkaproto
+
+// ============================================================================
+// AlterConfigs (API Key 33)
+// Supported versions: 0-2
+// ============================================================================
+
+// ----------------------------------------------------------------------------
+// Request
+// ----------------------------------------------------------------------------
+
+type AlterConfigsRequest struct {
+	Resources    []AlterConfigsResource
+	ValidateOnly bool
+}
+
+type AlterConfigsResource struct {
+	ResourceType int8
+	ResourceName string
+	Configs      map[string]*string // name -> value; the resource's full set, nil values unset
+}
+
+// Request Readers
+
+func (r *AlterConfigsRequest) readResources(d *Decoder, version int16) {
+	flexible := version >= 2
+
+	var count int
+	if flexible {
+		n, _ := d.ReadUVarInt()
+		count = int(n) - 1
+	} else {
+		n, _ := d.ReadInt32()
+		count = int(n)
+	}
+
+	r.Resources = make([]AlterConfigsResource, max(count, 0))
+	for i := range r.Resources {
+		r.Resources[i].readFrom(d, flexible)
+	}
+}
+
+func (res *AlterConfigsResource) readFrom(d *Decoder, flexible bool) {
+	res.ResourceType, _ = d.ReadInt8()
+
+	if flexible {
+		res.ResourceName, _ = d.ReadCompactString()
+	} else {
+		res.ResourceName, _ = d.ReadString()
+	}
+
+	res.readConfigs(d, flexible)
+
+	if flexible {
+		d.ReadUVarInt()                         // resource tagged fields
+	}
+}
+
+func (res *AlterConfigsResource) readConfigs(d *Decoder, flexible bool) {
+	var count int
+	if flexible {
+		n, _ := d.ReadUVarInt()
+		count = int(n) - 1
+	} else {
+		n, _ := d.ReadInt32()
+		count = int(n)
+	}
+
+	res.Configs = make(map[string]*string, max(count, 0))
+	for i := 0; i < count; i++ {
+		var name string
+		var value *string
+
+		if flexible {
+			name, _ = d.ReadCompactString()
+			value, _ = d.ReadCompactNullableString()
+			d.ReadUVarInt()                     // config tagged fields
+		} else {
+			name, _ = d.ReadString()
+			value, _ = d.ReadNullableString()
+		}
+		res.Configs[name] = value
+	}
+}
+
+func (r *AlterConfigsRequest) readValidateOnly(d *Decoder) {
+	r.ValidateOnly, _ = d.ReadBool()
+}
+
+func (r *AlterConfigsRequest) readTaggedFields(d *Decoder) {
+	d.ReadUVarInt()
+}
+
+// Decode - the recipe
+
+func DecodeAlterConfigsRequest(d *Decoder, v int16) (*AlterConfigsRequest, error) {
+	r := &AlterConfigsRequest{}
+
+	r.readResources(d, v)                       // v0+
+	r.readValidateOnly(d)                       // v0+
+	if v >= 2 {
+		r.readTaggedFields(d)                   // v2+
+	}
+
+	return r, d.Err()
+}
+
+// ----------------------------------------------------------------------------
+// Response
+// ----------------------------------------------------------------------------
+
+type AlterConfigsResponse struct {
+	ThrottleTimeMs int32
+	Responses      []AlterConfigsResult
+}
+
+type AlterConfigsResult struct {
+	ErrorCode    int16
+	ErrorMessage *string
+	ResourceType int8
+	ResourceName string
+}
+
+// Response Writers
+
+func (r *AlterConfigsResponse) writeThrottleTime(e *Encoder) {
+	e.WriteInt32(r.ThrottleTimeMs)
+}
+
+func (r *AlterConfigsResponse) writeResponses(e *Encoder, version int16) {
+	flexible := version >= 2
+
+	if flexible {
+		e.WriteCompactArrayLen(len(r.Responses))
+	} else {
+		e.WriteArrayLen(len(r.Responses))
+	}
+
+	for _, res := range r.Responses {
+		e.WriteInt16(res.ErrorCode)
+		if flexible {
+			e.WriteCompactNullableString(res.ErrorMessage)
+		} else {
+			e.WriteNullableString(res.ErrorMessage)
+		}
+		e.WriteInt8(res.ResourceType)
+		if flexible {
+			e.WriteCompactString(res.ResourceName)
+			e.WriteEmptyTaggedFields()          // response tagged fields
+		} else {
+			e.WriteString(res.ResourceName)
+		}
+	}
+}
+
+func (r *AlterConfigsResponse) writeTaggedFields(e *Encoder) {
+	e.WriteEmptyTaggedFields()
+}
+
+// Encode - the recipe
+
+func EncodeAlterConfigsResponse(e *Encoder, v int16, r *AlterConfigsResponse) {
+	r.writeThrottleTime(e)                      // v0+
+	r.writeResponses(e, v)                      // v0+
+	if v >= 2 {
+		r.writeTaggedFields(e)                  // v2+
+	}
+}
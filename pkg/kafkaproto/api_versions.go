@@ -1,4 +1,6 @@
-package protocol
+package kafkaproto
+
+import "fmt"
 
 // ============================================================================
 // ApiVersions (API Key 18)
@@ -32,7 +34,7 @@ func DecodeApiVersionsRequest(d *Decoder, v int16) (*ApiVersionsRequest, error)
 		r.readClientInfo(d)                     // v3+
 	}
 
-	return r, nil
+	return r, d.Err()
 }
 
 // ----------------------------------------------------------------------------
@@ -100,10 +102,10 @@ func EncodeApiVersionsResponse(e *Encoder, v int16, r *ApiVersionsResponse) {
 // DefaultApiVersions returns the list of supported API versions
 func DefaultApiVersions() []ApiVersion {
 	return []ApiVersion{
-		{APIKey: APIKeyProduce, MinVersion: 0, MaxVersion: 8},
-		{APIKey: APIKeyFetch, MinVersion: 0, MaxVersion: 11},
+		{APIKey: APIKeyProduce, MinVersion: 0, MaxVersion: 9},
+		{APIKey: APIKeyFetch, MinVersion: 0, MaxVersion: 13},
 		{APIKey: APIKeyListOffsets, MinVersion: 0, MaxVersion: 5},
-		{APIKey: APIKeyMetadata, MinVersion: 0, MaxVersion: 8},
+		{APIKey: APIKeyMetadata, MinVersion: 0, MaxVersion: 12},
 		{APIKey: APIKeyOffsetCommit, MinVersion: 0, MaxVersion: 8},
 		{APIKey: APIKeyOffsetFetch, MinVersion: 0, MaxVersion: 5},
 		{APIKey: APIKeyFindCoordinator, MinVersion: 0, MaxVersion: 3},
@@ -114,6 +116,102 @@ func DefaultApiVersions() []ApiVersion {
 		{APIKey: APIKeySaslHandshake, MinVersion: 0, MaxVersion: 1},
 		{APIKey: APIKeyApiVersions, MinVersion: 0, MaxVersion: 3},
 		{APIKey: APIKeyCreateTopics, MinVersion: 0, MaxVersion: 5},
+		{APIKey: APIKeyDeleteRecords, MinVersion: 0, MaxVersion: 2},
+		{APIKey: APIKeyInitProducerID, MinVersion: 0, MaxVersion: 4},
+		{APIKey: APIKeyAddPartitionsToTxn, MinVersion: 0, MaxVersion: 3},
+		{APIKey: APIKeyAddOffsetsToTxn, MinVersion: 0, MaxVersion: 3},
+		{APIKey: APIKeyEndTxn, MinVersion: 0, MaxVersion: 3},
+		{APIKey: APIKeyTxnOffsetCommit, MinVersion: 0, MaxVersion: 3},
+		{APIKey: APIKeyOffsetForLeaderEpoch, MinVersion: 0, MaxVersion: 3},
+		{APIKey: APIKeyDescribeAcls, MinVersion: 0, MaxVersion: 3},
+		{APIKey: APIKeyCreateAcls, MinVersion: 0, MaxVersion: 3},
+		{APIKey: APIKeyDeleteAcls, MinVersion: 0, MaxVersion: 3},
+		{APIKey: APIKeyDescribeConfigs, MinVersion: 0, MaxVersion: 4},
+		{APIKey: APIKeyAlterConfigs, MinVersion: 0, MaxVersion: 2},
+		{APIKey: APIKeyDescribeLogDirs, MinVersion: 0, MaxVersion: 4},
 		{APIKey: APIKeySaslAuthenticate, MinVersion: 0, MaxVersion: 2},
+		{APIKey: APIKeyCreatePartitions, MinVersion: 0, MaxVersion: 3},
+		{APIKey: APIKeyIncrementalAlterConfigs, MinVersion: 0, MaxVersion: 1},
+		{APIKey: APIKeyDescribeCluster, MinVersion: 0, MaxVersion: 1},
+	}
+}
+
+// ExtensionApiVersions returns the monolog-specific APIs, advertised only
+// when enabled
+func ExtensionApiVersions() []ApiVersion {
+	return []ApiVersion{
+		{APIKey: APIKeyMonologPurge, MinVersion: 0, MaxVersion: 0},
+	}
+}
+
+// APIKeyName returns the Kafka name of an API key
+func APIKeyName(key int16) string {
+	switch key {
+	case APIKeyProduce:
+		return "Produce"
+	case APIKeyFetch:
+		return "Fetch"
+	case APIKeyListOffsets:
+		return "ListOffsets"
+	case APIKeyMetadata:
+		return "Metadata"
+	case APIKeyOffsetCommit:
+		return "OffsetCommit"
+	case APIKeyOffsetFetch:
+		return "OffsetFetch"
+	case APIKeyFindCoordinator:
+		return "FindCoordinator"
+	case APIKeyJoinGroup:
+		return "JoinGroup"
+	case APIKeyHeartbeat:
+		return "Heartbeat"
+	case APIKeyLeaveGroup:
+		return "LeaveGroup"
+	case APIKeySyncGroup:
+		return "SyncGroup"
+	case APIKeySaslHandshake:
+		return "SaslHandshake"
+	case APIKeyApiVersions:
+		return "ApiVersions"
+	case APIKeyCreateTopics:
+		return "CreateTopics"
+	case APIKeyDeleteRecords:
+		return "DeleteRecords"
+	case APIKeyInitProducerID:
+		return "InitProducerId"
+	case APIKeyAddPartitionsToTxn:
+		return "AddPartitionsToTxn"
+	case APIKeyAddOffsetsToTxn:
+		return "AddOffsetsToTxn"
+	case APIKeyEndTxn:
+		return "EndTxn"
+	case APIKeyTxnOffsetCommit:
+		return "TxnOffsetCommit"
+	case APIKeyOffsetForLeaderEpoch:
+		return "OffsetForLeaderEpoch"
+	case APIKeyDescribeAcls:
+		return "DescribeAcls"
+	case APIKeyCreateAcls:
+		return "CreateAcls"
+	case APIKeyDeleteAcls:
+		return "DeleteAcls"
+	case APIKeyDescribeConfigs:
+		return "DescribeConfigs"
+	case APIKeyAlterConfigs:
+		return "AlterConfigs"
+	case APIKeyDescribeLogDirs:
+		return "DescribeLogDirs"
+	case APIKeySaslAuthenticate:
+		return "SaslAuthenticate"
+	case APIKeyCreatePartitions:
+		return "CreatePartitions"
+	case APIKeyIncrementalAlterConfigs:
+		return "IncrementalAlterConfigs"
+	case APIKeyDescribeCluster:
+		return "DescribeCluster"
+	case APIKeyMonologPurge:
+		return "MonologPurge"
+	default:
+		return fmt.Sprintf("Unknown(%d)", key)
 	}
 }
@@ -1,4 +1,4 @@
-package protocol
+package kafkaproto
 
 import (
 	"encoding/binary"
@@ -11,10 +11,13 @@ var (
 	ErrInvalidData      = errors.New("invalid data")
 )
 
-// Decoder reads Kafka protocol data
+// Decoder reads Kafka protocol data. The first read error sticks: every
+// later read fails with it, and Err returns it, so a message can be read
+// field by field and checked once at the end.
 type Decoder struct {
 	r   io.Reader
 	buf []byte
+	err error
 }
 
 // NewDecoder creates a new decoder
@@ -22,29 +25,63 @@ func NewDecoder(r io.Reader) *Decoder {
 	return &Decoder{r: r, buf: make([]byte, 8)}
 }
 
+// Err returns the error the first failed read returned, if any
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+// read fills p, failing without reading once a read has failed
+func (d *Decoder) read(p []byte) error {
+	if d.err != nil {
+		return d.err
+	}
+	if _, err := io.ReadFull(d.r, p); err != nil {
+		d.err = err
+		return err
+	}
+	return nil
+}
+
+// readN reads n bytes. A length past what a reader that knows its size,
+// such as a bytes.Reader over a frame, has left fails before allocating.
+func (d *Decoder) readN(n uint64) ([]byte, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	if l, ok := d.r.(interface{ Len() int }); ok && n > uint64(l.Len()) {
+		d.err = ErrInsufficientData
+		return nil, d.err
+	}
+	data := make([]byte, n)
+	if err := d.read(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
 func (d *Decoder) ReadInt8() (int8, error) {
-	if _, err := io.ReadFull(d.r, d.buf[:1]); err != nil {
+	if err := d.read(d.buf[:1]); err != nil {
 		return 0, err
 	}
 	return int8(d.buf[0]), nil
 }
 
 func (d *Decoder) ReadInt16() (int16, error) {
-	if _, err := io.ReadFull(d.r, d.buf[:2]); err != nil {
+	if err := d.read(d.buf[:2]); err != nil {
 		return 0, err
 	}
 	return int16(binary.BigEndian.Uint16(d.buf[:2])), nil
 }
 
 func (d *Decoder) ReadInt32() (int32, error) {
-	if _, err := io.ReadFull(d.r, d.buf[:4]); err != nil {
+	if err := d.read(d.buf[:4]); err != nil {
 		return 0, err
 	}
 	return int32(binary.BigEndian.Uint32(d.buf[:4])), nil
 }
 
 func (d *Decoder) ReadInt64() (int64, error) {
-	if _, err := io.ReadFull(d.r, d.buf[:8]); err != nil {
+	if err := d.read(d.buf[:8]); err != nil {
 		return 0, err
 	}
 	return int64(binary.BigEndian.Uint64(d.buf[:8])), nil
@@ -93,8 +130,8 @@ func (d *Decoder) ReadString() (string, error) {
 	if length < 0 {
 		return "", nil // null string
 	}
-	data := make([]byte, length)
-	if _, err := io.ReadFull(d.r, data); err != nil {
+	data, err := d.readN(uint64(length))
+	if err != nil {
 		return "", err
 	}
 	return string(data), nil
@@ -108,8 +145,8 @@ func (d *Decoder) ReadNullableString() (*string, error) {
 	if length < 0 {
 		return nil, nil
 	}
-	data := make([]byte, length)
-	if _, err := io.ReadFull(d.r, data); err != nil {
+	data, err := d.readN(uint64(length))
+	if err != nil {
 		return nil, err
 	}
 	s := string(data)
@@ -124,8 +161,8 @@ func (d *Decoder) ReadCompactString() (string, error) {
 	if length == 0 {
 		return "", nil
 	}
-	data := make([]byte, length-1)
-	if _, err := io.ReadFull(d.r, data); err != nil {
+	data, err := d.readN(length - 1)
+	if err != nil {
 		return "", err
 	}
 	return string(data), nil
@@ -139,8 +176,8 @@ func (d *Decoder) ReadCompactNullableString() (*string, error) {
 	if length == 0 {
 		return nil, nil
 	}
-	data := make([]byte, length-1)
-	if _, err := io.ReadFull(d.r, data); err != nil {
+	data, err := d.readN(length - 1)
+	if err != nil {
 		return nil, err
 	}
 	s := string(data)
@@ -155,8 +192,8 @@ func (d *Decoder) ReadBytes() ([]byte, error) {
 	if length < 0 {
 		return nil, nil
 	}
-	data := make([]byte, length)
-	if _, err := io.ReadFull(d.r, data); err != nil {
+	data, err := d.readN(uint64(length))
+	if err != nil {
 		return nil, err
 	}
 	return data, nil
@@ -170,16 +207,19 @@ func (d *Decoder) ReadCompactBytes() ([]byte, error) {
 	if length == 0 {
 		return nil, nil
 	}
-	data := make([]byte, length-1)
-	if _, err := io.ReadFull(d.r, data); err != nil {
+	data, err := d.readN(length - 1)
+	if err != nil {
 		return nil, err
 	}
 	return data, nil
 }
 
 func (d *Decoder) ReadRaw(n int) ([]byte, error) {
-	data := make([]byte, n)
-	if _, err := io.ReadFull(d.r, data); err != nil {
+	if n < 0 {
+		return nil, ErrInvalidData
+	}
+	data, err := d.readN(uint64(n))
+	if err != nil {
 		return nil, err
 	}
 	return data, nil
@@ -190,6 +230,40 @@ func (d *Decoder) ReadBool() (bool, error) {
 	return b != 0, err
 }
 
+// ReadUUID reads a 16-byte UUID
+func (d *Decoder) ReadUUID() (UUID, error) {
+	var u UUID
+	err := d.read(u[:])
+	return u, err
+}
+
+// ReadCompactArrayLen reads the length of a compact array, -1 for null
+func (d *Decoder) ReadCompactArrayLen() (int, error) {
+	n, err := d.ReadUVarInt()
+	return int(n) - 1, err
+}
+
+// SkipTaggedFields reads past a tagged fields section, whatever it holds
+func (d *Decoder) SkipTaggedFields() error {
+	count, err := d.ReadUVarInt()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < count; i++ {
+		if _, err := d.ReadUVarInt(); err != nil { // tag
+			return err
+		}
+		size, err := d.ReadUVarInt()
+		if err != nil {
+			return err
+		}
+		if _, err := d.ReadRaw(int(size)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Encoder writes Kafka protocol data
 type Encoder struct {
 	buf []byte
@@ -301,6 +375,11 @@ func (e *Encoder) WriteBool(v bool) {
 	}
 }
 
+// WriteUUID writes a 16-byte UUID
+func (e *Encoder) WriteUUID(u UUID) {
+	e.buf = append(e.buf, u[:]...)
+}
+
 // WriteCompactArrayLen writes the length for a compact array
 func (e *Encoder) WriteCompactArrayLen(n int) {
 	e.WriteUVarInt(uint64(n + 1))
@@ -316,6 +395,91 @@ func (e *Encoder) WriteEmptyTaggedFields() {
 	e.WriteUVarInt(0)
 }
 
+// ----------------------------------------------------------------------------
+// Flexible-aware helpers: compact encodings for flexible versions, the
+// classic ones otherwise
+// ----------------------------------------------------------------------------
+
+func readArrayLen(d *Decoder, flexible bool) int {
+	if flexible {
+		n, _ := d.ReadCompactArrayLen()
+		return n
+	}
+	n, _ := d.ReadInt32()
+	return int(n)
+}
+
+func readString(d *Decoder, flexible bool) string {
+	if flexible {
+		s, _ := d.ReadCompactString()
+		return s
+	}
+	s, _ := d.ReadString()
+	return s
+}
+
+func readNullableString(d *Decoder, flexible bool) *string {
+	if flexible {
+		s, _ := d.ReadCompactNullableString()
+		return s
+	}
+	s, _ := d.ReadNullableString()
+	return s
+}
+
+func readBytes(d *Decoder, flexible bool) []byte {
+	if flexible {
+		b, _ := d.ReadCompactBytes()
+		return b
+	}
+	b, _ := d.ReadBytes()
+	return b
+}
+
+func readTaggedFields(d *Decoder, flexible bool) {
+	if flexible {
+		d.SkipTaggedFields()
+	}
+}
+
+func writeArrayLen(e *Encoder, n int, flexible bool) {
+	if flexible {
+		e.WriteCompactArrayLen(n)
+	} else {
+		e.WriteArrayLen(n)
+	}
+}
+
+func writeString(e *Encoder, s string, flexible bool) {
+	if flexible {
+		e.WriteCompactString(s)
+	} else {
+		e.WriteString(s)
+	}
+}
+
+func writeNullableString(e *Encoder, s *string, flexible bool) {
+	if flexible {
+		e.WriteCompactNullableString(s)
+	} else {
+		e.WriteNullableString(s)
+	}
+}
+
+func writeBytes(e *Encoder, b []byte, flexible bool) {
+	if flexible {
+		e.WriteCompactBytes(b)
+	} else {
+		e.WriteBytes(b)
+	}
+}
+
+func writeTaggedFields(e *Encoder, flexible bool) {
+	if flexible {
+		e.WriteEmptyTaggedFields()
+	}
+}
+
 // isFlexibleVersion returns true if the given API and version uses flexible encoding
 func isFlexibleVersion(apiKey, apiVersion int16) bool {
 	switch apiKey {
@@ -343,6 +507,38 @@ func isFlexibleVersion(apiKey, apiVersion int16) bool {
 		return apiVersion >= 3
 	case APIKeyCreateTopics:
 		return apiVersion >= 5
+	case APIKeyDeleteRecords:
+		return apiVersion >= 2
+	case APIKeyInitProducerID:
+		return apiVersion >= 2
+	case APIKeyAddPartitionsToTxn:
+		return apiVersion >= 3
+	case APIKeyAddOffsetsToTxn:
+		return apiVersion >= 3
+	case APIKeyEndTxn:
+		return apiVersion >= 3
+	case APIKeyTxnOffsetCommit:
+		return apiVersion >= 3
+	case APIKeyDescribeAcls:
+		return apiVersion >= 2
+	case APIKeyCreateAcls:
+		return apiVersion >= 2
+	case APIKeyDeleteAcls:
+		return apiVersion >= 2
+	case APIKeyDescribeConfigs:
+		return apiVersion >= 4
+	case APIKeyAlterConfigs:
+		return apiVersion >= 2
+	case APIKeyDescribeLogDirs:
+		return apiVersion >= 2
+	case APIKeyCreatePartitions:
+		return apiVersion >= 2
+	case APIKeyIncrementalAlterConfigs:
+		return apiVersion >= 1
+	case APIKeyDescribeCluster:
+		return true
+	case APIKeySaslAuthenticate:
+		return apiVersion >= 2
 	default:
 		return false
 	}
@@ -370,12 +566,13 @@ func (d *Decoder) ReadHeader() (RequestHeader, error) {
 
 	// Check if this is a flexible version request
 	if isFlexibleVersion(h.APIKey, h.APIVersion) {
-		h.ClientID, err = d.ReadCompactString()
+		// client_id stays a plain nullable string in header v2; only the
+		// tagged fields are new
+		h.ClientID, err = d.ReadString()
 		if err != nil {
 			return h, err
 		}
-		// Skip tagged fields
-		_, err = d.ReadUVarInt()
+		err = d.SkipTaggedFields()
 		if err != nil {
 			return h, err
 		}
@@ -409,13 +606,12 @@ func (d *Decoder) ReadHeaderV2() (RequestHeader, error) {
 		return h, err
 	}
 
-	h.ClientID, err = d.ReadCompactString()
+	h.ClientID, err = d.ReadString()
 	if err != nil {
 		return h, err
 	}
 
-	// Skip tagged fields
-	_, err = d.ReadUVarInt()
+	err = d.SkipTaggedFields()
 	if err != nil {
 		return h, err
 	}
@@ -423,6 +619,36 @@ func (d *Decoder) ReadHeaderV2() (RequestHeader, error) {
 	return h, nil
 }
 
+// WriteRequestHeader writes a request header, v2 with tagged fields for
+// flexible versions
+func (e *Encoder) WriteRequestHeader(h RequestHeader) {
+	e.WriteInt16(h.APIKey)
+	e.WriteInt16(h.APIVersion)
+	e.WriteInt32(h.CorrelationID)
+	e.WriteString(h.ClientID)
+	if isFlexibleVersion(h.APIKey, h.APIVersion) {
+		e.WriteEmptyTaggedFields()
+	}
+}
+
+// ReadResponseHeader reads the header of a response to apiKey at
+// apiVersion, skipping its tagged fields for flexible versions.
+// ApiVersions responses always use the v0 header.
+func (d *Decoder) ReadResponseHeader(apiKey, apiVersion int16) (ResponseHeader, error) {
+	var h ResponseHeader
+	var err error
+
+	h.CorrelationID, err = d.ReadInt32()
+	if err != nil {
+		return h, err
+	}
+
+	if apiKey != APIKeyApiVersions && isFlexibleVersion(apiKey, apiVersion) {
+		_, err = d.ReadUVarInt()
+	}
+	return h, err
+}
+
 // WriteResponseHeader writes a response header
 func (e *Encoder) WriteResponseHeader(correlationID int32) {
 	e.WriteInt32(correlationID)
@@ -434,6 +660,16 @@ func (e *Encoder) WriteResponseHeaderV1(correlationID int32) {
 	e.WriteUVarInt(0) // tagged fields
 }
 
+// WriteResponseHeaderFor writes the response header a request calls for:
+// v1 for flexible versions, v0 otherwise and always for ApiVersions
+func (e *Encoder) WriteResponseHeaderFor(h RequestHeader) {
+	if h.APIKey != APIKeyApiVersions && isFlexibleVersion(h.APIKey, h.APIVersion) {
+		e.WriteResponseHeaderV1(h.CorrelationID)
+	} else {
+		e.WriteResponseHeader(h.CorrelationID)
+	}
+}
+
 // IsFlexibleVersion exports the flexible version check
 func IsFlexibleVersion(apiKey, apiVersion int16) bool {
 	return isFlexibleVersion(apiKey, apiVersion)
@@ -0,0 +1,51 @@
+package kafkaproto
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+func init() {
+	RegisterCodec(CompressionGzip, newGzipCodec)
+}
+
+type gzipCodec struct {
+	level int
+}
+
+func newGzipCodec(settings CodecSettings) (Codec, error) {
+	level := gzip.DefaultCompression
+	if settings.Level != 0 {
+		level = settings.Level
+	}
+	// Validate the level up front rather than on the first batch
+	if _, err := gzip.NewWriterLevel(io.Discard, level); err != nil {
+		return nil, err
+	}
+	return gzipCodec{level: level}, nil
+}
+
+func (c gzipCodec) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCodec) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
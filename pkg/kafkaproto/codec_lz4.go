@@ -0,0 +1,50 @@
+//go:build !nolz4
+
+package kafkaproto
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/pierrec/lz4/v4"
+)
+
+func init() {
+	RegisterCodec(CompressionLz4, newLz4Codec)
+}
+
+type lz4Codec struct {
+	level lz4.CompressionLevel
+}
+
+// newLz4Codec takes levels 1-9; the default is lz4's fast mode
+func newLz4Codec(settings CodecSettings) (Codec, error) {
+	c := lz4Codec{level: lz4.Fast}
+	if settings.Level != 0 {
+		if settings.Level < 1 || settings.Level > 9 {
+			return nil, fmt.Errorf("lz4 level must be 1-9, got %d", settings.Level)
+		}
+		c.level = lz4.CompressionLevel(1 << (8 + settings.Level - 1))
+	}
+	return c, nil
+}
+
+func (c lz4Codec) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := lz4.NewWriter(&buf)
+	if err := w.Apply(lz4.CompressionLevelOption(c.level)); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (lz4Codec) Decompress(data []byte) ([]byte, error) {
+	return io.ReadAll(lz4.NewReader(bytes.NewReader(data)))
+}
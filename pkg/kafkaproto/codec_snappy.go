@@ -0,0 +1,21 @@
+//go:build !nosnappy
+
+package kafkaproto
+
+import "github.com/klauspost/compress/snappy"
+
+func init() {
+	RegisterCodec(CompressionSnappy, func(CodecSettings) (Codec, error) {
+		return snappyCodec{}, nil
+	})
+}
+
+type snappyCodec struct{}
+
+func (snappyCodec) Compress(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
+
+func (snappyCodec) Decompress(data []byte) ([]byte, error) {
+	return snappy.Decode(nil, data)
+}
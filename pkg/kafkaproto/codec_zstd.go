@@ -0,0 +1,152 @@
+//go:build !nozstd
+
+package kafkaproto
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/dict"
+	"github.com/klauspost/compress/zstd"
+)
+
+func init() {
+	RegisterCodec(CompressionZstd, newZstdCodec)
+}
+
+// zstdCodec shares one encoder and decoder; EncodeAll and DecodeAll are
+// safe for concurrent use
+type zstdCodec struct {
+	enc *zstd.Encoder
+	dec *zstd.Decoder
+}
+
+// newZstdCodec takes zstd levels 1-22, mapped onto the nearest level the
+// encoder implements. A dictionary must be in zstd's dictionary format
+// (as written by zstd --train). It is only decoded with: Kafka clients
+// without it could not read batches compressed with it.
+func newZstdCodec(settings CodecSettings) (Codec, error) {
+	var eopts []zstd.EOption
+	var dopts []zstd.DOption
+	if settings.Level != 0 {
+		if settings.Level < 1 || settings.Level > 22 {
+			return nil, fmt.Errorf("zstd level must be 1-22, got %d", settings.Level)
+		}
+		eopts = append(eopts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(settings.Level)))
+	}
+	if settings.Dictionary != nil {
+		dopts = append(dopts, zstd.WithDecoderDicts(settings.Dictionary))
+	}
+	enc, err := zstd.NewWriter(nil, eopts...)
+	if err != nil {
+		return nil, err
+	}
+	dec, err := zstd.NewReader(nil, dopts...)
+	if err != nil {
+		enc.Close()
+		return nil, err
+	}
+	return zstdCodec{enc: enc, dec: dec}, nil
+}
+
+func (c zstdCodec) Compress(data []byte) ([]byte, error) {
+	return c.enc.EncodeAll(data, nil), nil
+}
+
+func (c zstdCodec) Decompress(data []byte) ([]byte, error) {
+	if dec := zstdDictDecoder(data); dec != nil {
+		return dec.DecodeAll(data, nil)
+	}
+	return c.dec.DecodeAll(data, nil)
+}
+
+// ============================================================================
+// Trained dictionaries
+//
+// Dictionaries trained at runtime are added by ID. Frames compressed with
+// one name its ID in their header, which picks the decoder to use.
+// ============================================================================
+
+var (
+	zstdDictsMu sync.RWMutex
+	zstdDicts   = make(map[uint32]*zstd.Decoder)
+)
+
+// TrainZstdDictionary builds a zstd dictionary of at most maxSize bytes from
+// sample messages, returning it and its ID
+func TrainZstdDictionary(samples [][]byte, maxSize int) ([]byte, uint32, error) {
+	d, err := dict.BuildZstdDict(samples, dict.Options{
+		MaxDictSize: maxSize,
+		HashBytes:   6,
+		ZstdLevel:   zstd.SpeedDefault,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	id, err := ZstdDictionaryID(d)
+	if err != nil {
+		return nil, 0, err
+	}
+	return d, id, nil
+}
+
+// ZstdDictionaryID returns the ID of a zstd dictionary
+func ZstdDictionaryID(d []byte) (uint32, error) {
+	info, err := zstd.InspectDictionary(d)
+	if err != nil {
+		return 0, err
+	}
+	return info.ID(), nil
+}
+
+// ZstdFrameDictionary returns the ID of the dictionary a zstd frame was
+// compressed with, or 0 if none
+func ZstdFrameDictionary(frame []byte) uint32 {
+	var h zstd.Header
+	if h.Decode(frame) != nil {
+		return 0
+	}
+	return h.DictionaryID
+}
+
+// AddZstdDictionary lets the zstd codec decode frames compressed with a
+// dictionary. Adding one twice is harmless.
+func AddZstdDictionary(d []byte) error {
+	id, err := ZstdDictionaryID(d)
+	if err != nil {
+		return err
+	}
+	zstdDictsMu.RLock()
+	_, ok := zstdDicts[id]
+	zstdDictsMu.RUnlock()
+	if ok {
+		return nil
+	}
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderDicts(d))
+	if err != nil {
+		return err
+	}
+	zstdDictsMu.Lock()
+	defer zstdDictsMu.Unlock()
+	if _, ok := zstdDicts[id]; ok {
+		dec.Close()
+		return nil
+	}
+	zstdDicts[id] = dec
+	return nil
+}
+
+// NewZstdDictCodec creates a zstd codec that compresses with a dictionary
+func NewZstdDictCodec(d []byte) (Codec, error) {
+	return newZstdCodec(CodecSettings{Dictionary: d})
+}
+
+func zstdDictDecoder(frame []byte) *zstd.Decoder {
+	id := ZstdFrameDictionary(frame)
+	if id == 0 {
+		return nil
+	}
+	zstdDictsMu.RLock()
+	defer zstdDictsMu.RUnlock()
+	return zstdDicts[id]
+}
@@ -0,0 +1,25 @@
+//go:build nozstd
+
+package kafkaproto
+
+// Builds without zstd cannot train or use dictionaries
+
+func TrainZstdDictionary(samples [][]byte, maxSize int) ([]byte, uint32, error) {
+	return nil, 0, ErrCodecUnavailable
+}
+
+func ZstdDictionaryID(d []byte) (uint32, error) {
+	return 0, ErrCodecUnavailable
+}
+
+func ZstdFrameDictionary(frame []byte) uint32 {
+	return 0
+}
+
+func AddZstdDictionary(d []byte) error {
+	return ErrCodecUnavailable
+}
+
+func NewZstdDictCodec(d []byte) (Codec, error) {
+	return nil, ErrCodecUnavailable
+}
@@ -0,0 +1,175 @@
+package kafkaproto
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// ============================================================================
+// Compression codecs
+//
+// Codecs register themselves by Kafka codec ID from init(). gzip is always
+// linked; snappy, lz4 and zstd each live in their own file behind a build
+// tag (nosnappy, nolz4, nozstd), so a deployment can leave the library out
+// of the binary, or register its own implementation in its place. A linked
+// codec can also be switched off at startup with DisableCodec.
+// ============================================================================
+
+// Codec compresses and decompresses the records section of a batch. It
+// must be safe for concurrent use.
+type Codec interface {
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// CodecSettings tune a codec. Zero values keep the codec's defaults; codecs
+// ignore settings they have no use for.
+type CodecSettings struct {
+	Level      int    // compression level, in the codec's own scale
+	Dictionary []byte // zstd only: a dictionary to decode with; nothing is compressed with it
+}
+
+// CodecFactory creates a codec with the given settings
+type CodecFactory func(settings CodecSettings) (Codec, error)
+
+// ErrCodecUnavailable is returned for a codec that is not linked into the
+// binary or was disabled
+var ErrCodecUnavailable = errors.New("compression codec not available")
+
+type codecEntry struct {
+	factory CodecFactory
+	codec   Codec
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = make(map[int8]*codecEntry)
+)
+
+// RegisterCodec makes a codec available under a Kafka codec ID, with
+// default settings. Registering the same ID twice panics.
+func RegisterCodec(id int8, factory CodecFactory) {
+	if !knownCodec(id) {
+		panic(fmt.Sprintf("kafkaproto: RegisterCodec with unknown codec ID %d", id))
+	}
+	if factory == nil {
+		panic("kafkaproto: RegisterCodec factory is nil for " + CodecName(id))
+	}
+	codec, err := factory(CodecSettings{})
+	if err != nil {
+		panic(fmt.Sprintf("kafkaproto: default %s codec: %v", CodecName(id), err))
+	}
+
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	if _, dup := codecs[id]; dup {
+		panic("kafkaproto: RegisterCodec called twice for " + CodecName(id))
+	}
+	codecs[id] = &codecEntry{factory: factory, codec: codec}
+}
+
+// ConfigureCodec recreates a registered codec with new settings
+func ConfigureCodec(id int8, settings CodecSettings) error {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	entry, ok := codecs[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrCodecUnavailable, CodecName(id))
+	}
+	codec, err := entry.factory(settings)
+	if err != nil {
+		return err
+	}
+	entry.codec = codec
+	return nil
+}
+
+// DisableCodec removes a codec, so batches using it can no longer be
+// compressed or decoded here
+func DisableCodec(id int8) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	delete(codecs, id)
+}
+
+// CodecAvailable reports whether a codec can be used. No compression always
+// can.
+func CodecAvailable(id int8) bool {
+	if id == CompressionNone {
+		return true
+	}
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	_, ok := codecs[id]
+	return ok
+}
+
+// AvailableCodecs returns the IDs of the codecs that can be used, no
+// compression first
+func AvailableCodecs() []int8 {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	ids := []int8{CompressionNone}
+	for id := range codecs {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// AvailableCodecNames returns the names of the codecs that can be used
+func AvailableCodecNames() []string {
+	var names []string
+	for _, id := range AvailableCodecs() {
+		names = append(names, CodecName(id))
+	}
+	return names
+}
+
+// knownCodec reports whether id is a compression codec Kafka defines
+func knownCodec(id int8) bool {
+	return id > CompressionNone && int(id) < len(codecNames)
+}
+
+func lookupCodec(id int8) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	entry, ok := codecs[id]
+	if !ok {
+		return nil, false
+	}
+	return entry.codec, true
+}
+
+// Compress encodes the records section of a batch with a codec
+func Compress(data []byte, codec int8) ([]byte, error) {
+	if codec == CompressionNone {
+		return data, nil
+	}
+	c, ok := lookupCodec(codec)
+	if !ok {
+		if knownCodec(codec) {
+			return nil, fmt.Errorf("%w: %s", ErrCodecUnavailable, CodecName(codec))
+		}
+		return nil, fmt.Errorf("unsupported codec %d", codec)
+	}
+	return c.Compress(data)
+}
+
+// Decompress decodes the records section of a batch. Unknown codecs are
+// returned as they are; known ones that are not available are an error.
+func Decompress(data []byte, codec int8) ([]byte, error) {
+	if codec == CompressionNone {
+		return data, nil
+	}
+	c, ok := lookupCodec(codec)
+	if !ok {
+		if knownCodec(codec) {
+			return nil, fmt.Errorf("%w: %s", ErrCodecUnavailable, CodecName(codec))
+		}
+		return data, nil
+	}
+	return c.Decompress(data)
+}
@@ -0,0 +1,131 @@
+package kafkaproto
+
+// ============================================================================
+// CreateAcls (API Key 30)
+// Supported versions: 0-3
+// ============================================================================
+
+// ----------------------------------------------------------------------------
+// Request
+// ----------------------------------------------------------------------------
+
+type CreateAclsRequest struct {
+	Creations []AclCreation
+}
+
+type AclCreation struct {
+	ResourceType        int8
+	ResourceName        string
+	ResourcePatternType int8 // v1+; v0 creates literal ACLs
+	Principal           string
+	Host                string
+	Operation           int8
+	PermissionType      int8
+}
+
+// Request Readers
+
+func (r *CreateAclsRequest) readCreations(d *Decoder, version int16) {
+	flexible := version >= 2
+
+	var count int
+	if flexible {
+		n, _ := d.ReadUVarInt()
+		count = int(n) - 1
+	} else {
+		n, _ := d.ReadInt32()
+		count = int(n)
+	}
+
+	r.Creations = make([]AclCreation, max(count, 0))
+	for i := range r.Creations {
+		r.Creations[i].readFrom(d, version)
+	}
+}
+
+func (c *AclCreation) readFrom(d *Decoder, version int16) {
+	flexible := version >= 2
+
+	c.ResourceType, _ = d.ReadInt8()
+	c.ResourceName = readAclString(d, flexible)
+	c.ResourcePatternType = AclPatternLiteral
+	if version >= 1 {
+		c.ResourcePatternType, _ = d.ReadInt8()
+	}
+	c.Principal = readAclString(d, flexible)
+	c.Host = readAclString(d, flexible)
+	c.Operation, _ = d.ReadInt8()
+	c.PermissionType, _ = d.ReadInt8()
+	if flexible {
+		d.ReadUVarInt()                         // creation tagged fields
+	}
+}
+
+func (r *CreateAclsRequest) readTaggedFields(d *Decoder) {
+	d.ReadUVarInt()
+}
+
+// Decode - the recipe
+
+func DecodeCreateAclsRequest(d *Decoder, v int16) (*CreateAclsRequest, error) {
+	r := &CreateAclsRequest{}
+
+	r.readCreations(d, v)                       // v0+
+	if v >= 2 {
+		r.readTaggedFields(d)                   // v2+
+	}
+
+	return r, d.Err()
+}
+
+// ----------------------------------------------------------------------------
+// Response
+// ----------------------------------------------------------------------------
+
+type CreateAclsResponse struct {
+	ThrottleTimeMs int32
+	Results        []AclCreationResult
+}
+
+type AclCreationResult struct {
+	ErrorCode    int16
+	ErrorMessage *string
+}
+
+// Response Writers
+
+func (r *CreateAclsResponse) writeThrottleTime(e *Encoder) {
+	e.WriteInt32(r.ThrottleTimeMs)
+}
+
+func (r *CreateAclsResponse) writeResults(e *Encoder, version int16) {
+	flexible := version >= 2
+
+	if flexible {
+		e.WriteCompactArrayLen(len(r.Results))
+	} else {
+		e.WriteArrayLen(len(r.Results))
+	}
+
+	for _, res := range r.Results {
+		e.WriteInt16(res.ErrorCode)
+		writeAclNullableString(e, res.ErrorMessage, flexible)
+		if flexible {
+			e.WriteEmptyTaggedFields()          // result tagged fields
+		}
+	}
+}
+
+func (r *CreateAclsResponse) writeTaggedFields(e *Encoder) {
+	e.WriteEmptyTaggedFields()
+}
+
+// Encode - the recipe
+
+func EncodeCreateAclsResponse(e *Encoder, v int16, r *CreateAclsResponse) {
+	r.writeThrottleTime(e)                      // v0+
+	r.writeResults(e, v)                        // v0+
+	if v >= 2 {
+		r.writeTaggedFields(e)                  // v2+
+	}
+}
@@ -0,0 +1,177 @@
+package kafkaproto
+
+// ============================================================================
+// CreatePartitions (API Key 37)
+// Supported versions: 0-3
+// ============================================================================
+
+// ----------------------------------------------------------------------------
+// Request
+// ----------------------------------------------------------------------------
+
+type CreatePartitionsRequest struct {
+	Topics       []CreatePartitionsTopic
+	TimeoutMs    int32
+	ValidateOnly bool
+}
+
+type CreatePartitionsTopic struct {
+	Name        string
+	Count       int32     // total partitions wanted, not the number to add
+	Assignments [][]int32 // broker IDs of each new partition; nil lets the broker choose
+}
+
+// Request Readers
+
+func (r *CreatePartitionsRequest) readTopics(d *Decoder, version int16) {
+	flexible := version >= 2
+
+	var count int
+	if flexible {
+		n, _ := d.ReadUVarInt()
+		count = int(n) - 1
+	} else {
+		n, _ := d.ReadInt32()
+		count = int(n)
+	}
+
+	r.Topics = make([]CreatePartitionsTopic, max(count, 0))
+	for i := range r.Topics {
+		r.Topics[i].readFrom(d, flexible)
+	}
+}
+
+func (t *CreatePartitionsTopic) readFrom(d *Decoder, flexible bool) {
+	if flexible {
+		t.Name, _ = d.ReadCompactString()
+	} else {
+		t.Name, _ = d.ReadString()
+	}
+	t.Count, _ = d.ReadInt32()
+
+	t.readAssignments(d, flexible)
+
+	if flexible {
+		d.ReadUVarInt()                         // topic tagged fields
+	}
+}
+
+func (t *CreatePartitionsTopic) readAssignments(d *Decoder, flexible bool) {
+	var count int
+	if flexible {
+		n, _ := d.ReadUVarInt()
+		count = int(n) - 1
+	} else {
+		n, _ := d.ReadInt32()
+		count = int(n)
+	}
+	if count < 0 {
+		return
+	}
+
+	t.Assignments = make([][]int32, count)
+	for i := range t.Assignments {
+		var brokers int
+		if flexible {
+			n, _ := d.ReadUVarInt()
+			brokers = int(n) - 1
+		} else {
+			n, _ := d.ReadInt32()
+			brokers = int(n)
+		}
+		t.Assignments[i] = make([]int32, max(brokers, 0))
+		for j := range t.Assignments[i] {
+			t.Assignments[i][j], _ = d.ReadInt32()
+		}
+		if flexible {
+			d.ReadUVarInt()                     // assignment tagged fields
+		}
+	}
+}
+
+func (r *CreatePartitionsRequest) readTimeout(d *Decoder) {
+	r.TimeoutMs, _ = d.ReadInt32()
+}
+
+func (r *CreatePartitionsRequest) readValidateOnly(d *Decoder) {
+	r.ValidateOnly, _ = d.ReadBool()
+}
+
+func (r *CreatePartitionsRequest) readTaggedFields(d *Decoder) {
+	d.ReadUVarInt()
+}
+
+// Decode - the recipe
+
+func DecodeCreatePartitionsRequest(d *Decoder, v int16) (*CreatePartitionsRequest, error) {
+	r := &CreatePartitionsRequest{}
+
+	r.readTopics(d, v)                          // v0+
+	r.readTimeout(d)                            // v0+
+	r.readValidateOnly(d)                       // v0+
+	if v >= 2 {
+		r.readTaggedFields(d)                   // v2+
+	}
+
+	return r, d.Err()
+}
+
+// ----------------------------------------------------------------------------
+// Response
+// ----------------------------------------------------------------------------
+
+type CreatePartitionsResponse struct {
+	ThrottleTimeMs int32
+	Results        []CreatePartitionsResult
+}
+
+type CreatePartitionsResult struct {
+	Name         string
+	ErrorCode    int16
+	ErrorMessage *string
+}
+
+// Response Writers
+
+func (r *CreatePartitionsResponse) writeThrottleTime(e *Encoder) {
+	e.WriteInt32(r.ThrottleTimeMs)
+}
+
+func (r *CreatePartitionsResponse) writeResults(e *Encoder, version int16) {
+	flexible := version >= 2
+
+	if flexible {
+		e.WriteCompactArrayLen(len(r.Results))
+	} else {
+		e.WriteArrayLen(len(r.Results))
+	}
+
+	for _, res := range r.Results {
+		if flexible {
+			e.WriteCompactString(res.Name)
+		} else {
+			e.WriteString(res.Name)
+		}
+		e.WriteInt16(res.ErrorCode)
+		if flexible {
+			e.WriteCompactNullableString(res.ErrorMessage)
+			e.WriteEmptyTaggedFields()          // result tagged fields
+		} else {
+			e.WriteNullableString(res.ErrorMessage)
+		}
+	}
+}
+
+func (r *CreatePartitionsResponse) writeTaggedFields(e *Encoder) {
+	e.WriteEmptyTaggedFields()
+}
+
+// Encode - the recipe
+
+func EncodeCreatePartitionsResponse(e *Encoder, v int16, r *CreatePartitionsResponse) {
+	r.writeThrottleTime(e)                      // v0+
+	r.writeResults(e, v)                        // v0+
+	if v >= 2 {
+		r.writeTaggedFields(e)                  // v2+
+	}
+}
@@ -1,4 +1,4 @@
-package protocol
+package kafkaproto
 
 // ============================================================================
 // CreateTopics (API Key 19)
@@ -161,7 +161,7 @@ func DecodeCreateTopicsRequest(d *Decoder, v int16) (*CreateTopicsRequest, error
 		r.readTaggedFields(d)                   // v5+
 	}
 
-	return r, nil
+	return r, d.Err()
 }
 
 // ----------------------------------------------------------------------------
@@ -0,0 +1,146 @@
+package kafkaproto
+
+// ============================================================================
+// DeleteAcls (API Key 31)
+// Supported versions: 0-3
+// ============================================================================
+
+// ----------------------------------------------------------------------------
+// Request
+// ----------------------------------------------------------------------------
+
+type DeleteAclsRequest struct {
+	Filters []AclFilter
+}
+
+// Request Readers
+
+func (r *DeleteAclsRequest) readFilters(d *Decoder, version int16) {
+	flexible := version >= 2
+
+	var count int
+	if flexible {
+		n, _ := d.ReadUVarInt()
+		count = int(n) - 1
+	} else {
+		n, _ := d.ReadInt32()
+		count = int(n)
+	}
+
+	r.Filters = make([]AclFilter, max(count, 0))
+	for i := range r.Filters {
+		r.Filters[i].readFrom(d, version)
+		if flexible {
+			d.ReadUVarInt()                     // filter tagged fields
+		}
+	}
+}
+
+func (r *DeleteAclsRequest) readTaggedFields(d *Decoder) {
+	d.ReadUVarInt()
+}
+
+// Decode - the recipe
+
+func DecodeDeleteAclsRequest(d *Decoder, v int16) (*DeleteAclsRequest, error) {
+	r := &DeleteAclsRequest{}
+
+	r.readFilters(d, v)                         // v0+
+	if v >= 2 {
+		r.readTaggedFields(d)                   // v2+
+	}
+
+	return r, d.Err()
+}
+
+// ----------------------------------------------------------------------------
+// Response
+// ----------------------------------------------------------------------------
+
+type DeleteAclsResponse struct {
+	ThrottleTimeMs int32
+	FilterResults  []DeleteAclsFilterResult
+}
+
+type DeleteAclsFilterResult struct {
+	ErrorCode    int16
+	ErrorMessage *string
+	MatchingAcls []DeleteAclsMatchingAcl
+}
+
+type DeleteAclsMatchingAcl struct {
+	ErrorCode      int16
+	ErrorMessage   *string
+	ResourceType   int8
+	ResourceName   string
+	PatternType    int8 // v1+
+	Principal      string
+	Host           string
+	Operation      int8
+	PermissionType int8
+}
+
+// Response Writers
+
+func (r *DeleteAclsResponse) writeThrottleTime(e *Encoder) {
+	e.WriteInt32(r.ThrottleTimeMs)
+}
+
+func (r *DeleteAclsResponse) writeFilterResults(e *Encoder, version int16) {
+	flexible := version >= 2
+
+	if flexible {
+		e.WriteCompactArrayLen(len(r.FilterResults))
+	} else {
+		e.WriteArrayLen(len(r.FilterResults))
+	}
+
+	for _, fr := range r.FilterResults {
+		e.WriteInt16(fr.ErrorCode)
+		writeAclNullableString(e, fr.ErrorMessage, flexible)
+		if flexible {
+			e.WriteCompactArrayLen(len(fr.MatchingAcls))
+		} else {
+			e.WriteArrayLen(len(fr.MatchingAcls))
+		}
+		for _, m := range fr.MatchingAcls {
+			m.writeTo(e, version)
+		}
+		if flexible {
+			e.WriteEmptyTaggedFields()          // filter result tagged fields
+		}
+	}
+}
+
+func (m *DeleteAclsMatchingAcl) writeTo(e *Encoder, version int16) {
+	flexible := version >= 2
+
+	e.WriteInt16(m.ErrorCode)
+	writeAclNullableString(e, m.ErrorMessage, flexible)
+	e.WriteInt8(m.ResourceType)
+	writeAclString(e, m.ResourceName, flexible)
+	if version >= 1 {
+		e.WriteInt8(m.PatternType)              // v1+
+	}
+	writeAclString(e, m.Principal, flexible)
+	writeAclString(e, m.Host, flexible)
+	e.WriteInt8(m.Operation)
+	e.WriteInt8(m.PermissionType)
+	if flexible {
+		e.WriteEmptyTaggedFields()              // matching acl tagged fields
+	}
+}
+
+func (r *DeleteAclsResponse) writeTaggedFields(e *Encoder) {
+	e.WriteEmptyTaggedFields()
+}
+
+// Encode - the recipe
+
+func EncodeDeleteAclsResponse(e *Encoder, v int16, r *DeleteAclsResponse) {
+	r.writeThrottleTime(e)                      // v0+
+	r.writeFilterResults(e, v)                  // v0+
+	if v >= 2 {
+		r.writeTaggedFields(e)                  // v2+
+	}
+}
@@ -0,0 +1,170 @@
+package kafkaproto
+
+// ============================================================================
+// DeleteRecords (API Key 21)
+// Supported versions: 0-2
+// ============================================================================
+
+// ----------------------------------------------------------------------------
+// Request
+// ----------------------------------------------------------------------------
+
+type DeleteRecordsRequest struct {
+	Topics    []DeleteRecordsTopic
+	TimeoutMs int32
+}
+
+type DeleteRecordsTopic struct {
+	Name       string
+	Partitions []DeleteRecordsPartition
+}
+
+type DeleteRecordsPartition struct {
+	Index  int32
+	Offset int64 // delete everything before this; -1 means the high watermark
+}
+
+// Request Readers
+
+func (r *DeleteRecordsRequest) readTopics(d *Decoder, version int16) {
+	flexible := version >= 2
+
+	var count int
+	if flexible {
+		n, _ := d.ReadUVarInt()
+		count = int(n) - 1
+	} else {
+		n, _ := d.ReadInt32()
+		count = int(n)
+	}
+
+	r.Topics = make([]DeleteRecordsTopic, max(count, 0))
+	for i := range r.Topics {
+		r.Topics[i].readFrom(d, flexible)
+	}
+}
+
+func (t *DeleteRecordsTopic) readFrom(d *Decoder, flexible bool) {
+	if flexible {
+		t.Name, _ = d.ReadCompactString()
+	} else {
+		t.Name, _ = d.ReadString()
+	}
+
+	var count int
+	if flexible {
+		n, _ := d.ReadUVarInt()
+		count = int(n) - 1
+	} else {
+		n, _ := d.ReadInt32()
+		count = int(n)
+	}
+
+	t.Partitions = make([]DeleteRecordsPartition, max(count, 0))
+	for i := range t.Partitions {
+		t.Partitions[i].Index, _ = d.ReadInt32()
+		t.Partitions[i].Offset, _ = d.ReadInt64()
+		if flexible {
+			d.ReadUVarInt()                     // partition tagged fields
+		}
+	}
+
+	if flexible {
+		d.ReadUVarInt()                         // topic tagged fields
+	}
+}
+
+func (r *DeleteRecordsRequest) readTimeout(d *Decoder) {
+	r.TimeoutMs, _ = d.ReadInt32()
+}
+
+func (r *DeleteRecordsRequest) readTaggedFields(d *Decoder) {
+	d.ReadUVarInt()
+}
+
+// Decode - the recipe
+
+func DecodeDeleteRecordsRequest(d *Decoder, v int16) (*DeleteRecordsRequest, error) {
+	r := &DeleteRecordsRequest{}
+
+	r.readTopics(d, v)                          // v0+
+	r.readTimeout(d)                            // v0+
+	if v >= 2 {
+		r.readTaggedFields(d)                   // v2+
+	}
+
+	return r, d.Err()
+}
+
+// ----------------------------------------------------------------------------
+// Response
+// ----------------------------------------------------------------------------
+
+type DeleteRecordsResponse struct {
+	ThrottleTimeMs int32
+	Topics         []DeleteRecordsResponseTopic
+}
+
+type DeleteRecordsResponseTopic struct {
+	Name       string
+	Partitions []DeleteRecordsResponsePartition
+}
+
+type DeleteRecordsResponsePartition struct {
+	Index        int32
+	LowWatermark int64
+	ErrorCode    int16
+}
+
+// Response Writers
+
+func (r *DeleteRecordsResponse) writeThrottleTime(e *Encoder) {
+	e.WriteInt32(r.ThrottleTimeMs)
+}
+
+func (r *DeleteRecordsResponse) writeTopics(e *Encoder, version int16) {
+	flexible := version >= 2
+
+	if flexible {
+		e.WriteCompactArrayLen(len(r.Topics))
+	} else {
+		e.WriteArrayLen(len(r.Topics))
+	}
+
+	for _, t := range r.Topics {
+		if flexible {
+			e.WriteCompactString(t.Name)
+			e.WriteCompactArrayLen(len(t.Partitions))
+		} else {
+			e.WriteString(t.Name)
+			e.WriteArrayLen(len(t.Partitions))
+		}
+
+		for _, p := range t.Partitions {
+			e.WriteInt32(p.Index)
+			e.WriteInt64(p.LowWatermark)
+			e.WriteInt16(p.ErrorCode)
+			if flexible {
+				e.WriteEmptyTaggedFields()      // partition tagged fields
+			}
+		}
+
+		if flexible {
+			e.WriteEmptyTaggedFields()          // topic tagged fields
+		}
+	}
+}
+
+func (r *DeleteRecordsResponse) writeTaggedFields(e *Encoder) {
+	e.WriteEmptyTaggedFields()
+}
+
+// Encode - the recipe
+
+func EncodeDeleteRecordsResponse(e *Encoder, v int16, r *DeleteRecordsResponse) {
+	r.writeThrottleTime(e)                      // v0+
+	r.writeTopics(e, v)                         // v0+
+	if v >= 2 {
+		r.writeTaggedFields(e)                  // v2+
+	}
+}
@@ -0,0 +1,224 @@
+package kafkaproto
+
+// ============================================================================
+// DescribeAcls (API Key 29)
+// Supported versions: 0-3
+// ============================================================================
+
+// ACL resource types
+const (
+	AclResourceAny             int8 = 1
+	AclResourceTopic           int8 = 2
+	AclResourceGroup           int8 = 3
+	AclResourceCluster         int8 = 4
+	AclResourceTransactionalID int8 = 5
+)
+
+// ACL pattern types
+const (
+	AclPatternAny      int8 = 1
+	AclPatternMatch    int8 = 2 // filters only: every pattern that applies to the name
+	AclPatternLiteral  int8 = 3
+	AclPatternPrefixed int8 = 4
+)
+
+// ACL operations
+const (
+	AclOperationAny             int8 = 1
+	AclOperationAll             int8 = 2
+	AclOperationRead            int8 = 3
+	AclOperationWrite           int8 = 4
+	AclOperationCreate          int8 = 5
+	AclOperationDelete          int8 = 6
+	AclOperationAlter           int8 = 7
+	AclOperationDescribe        int8 = 8
+	AclOperationClusterAction   int8 = 9
+	AclOperationDescribeConfigs int8 = 10
+	AclOperationAlterConfigs    int8 = 11
+	AclOperationIdempotentWrite int8 = 12
+)
+
+// ACL permission types
+const (
+	AclPermissionAny   int8 = 1
+	AclPermissionDeny  int8 = 2
+	AclPermissionAllow int8 = 3
+)
+
+// ----------------------------------------------------------------------------
+// Request
+// ----------------------------------------------------------------------------
+
+// AclFilter selects ACL bindings; nil strings and the Any values match
+// everything
+type AclFilter struct {
+	ResourceType   int8
+	ResourceName   *string
+	PatternType    int8 // v1+; v0 filters match literal ACLs
+	Principal      *string
+	Host           *string
+	Operation      int8
+	PermissionType int8
+}
+
+type DescribeAclsRequest struct {
+	Filter AclFilter
+}
+
+// Request Readers
+
+func (f *AclFilter) readFrom(d *Decoder, version int16) {
+	flexible := version >= 2
+
+	f.ResourceType, _ = d.ReadInt8()
+	f.ResourceName = readAclNullableString(d, flexible)
+	f.PatternType = AclPatternLiteral
+	if version >= 1 {
+		f.PatternType, _ = d.ReadInt8()
+	}
+	f.Principal = readAclNullableString(d, flexible)
+	f.Host = readAclNullableString(d, flexible)
+	f.Operation, _ = d.ReadInt8()
+	f.PermissionType, _ = d.ReadInt8()
+}
+
+func (r *DescribeAclsRequest) readTaggedFields(d *Decoder) {
+	d.ReadUVarInt()
+}
+
+// Decode - the recipe
+
+func DecodeDescribeAclsRequest(d *Decoder, v int16) (*DescribeAclsRequest, error) {
+	r := &DescribeAclsRequest{}
+
+	r.Filter.readFrom(d, v)                     // v0+
+	if v >= 2 {
+		r.readTaggedFields(d)                   // v2+
+	}
+
+	return r, d.Err()
+}
+
+// ----------------------------------------------------------------------------
+// Response
+// ----------------------------------------------------------------------------
+
+type DescribeAclsResponse struct {
+	ThrottleTimeMs int32
+	ErrorCode      int16
+	ErrorMessage   *string
+	Resources      []DescribeAclsResource
+}
+
+type DescribeAclsResource struct {
+	ResourceType int8
+	ResourceName string
+	PatternType  int8 // v1+
+	Acls         []AclDescription
+}
+
+type AclDescription struct {
+	Principal      string
+	Host           string
+	Operation      int8
+	PermissionType int8
+}
+
+// Response Writers
+
+func (r *DescribeAclsResponse) writeThrottleTime(e *Encoder) {
+	e.WriteInt32(r.ThrottleTimeMs)
+}
+
+func (r *DescribeAclsResponse) writeError(e *Encoder, flexible bool) {
+	e.WriteInt16(r.ErrorCode)
+	writeAclNullableString(e, r.ErrorMessage, flexible)
+}
+
+func (r *DescribeAclsResponse) writeResources(e *Encoder, version int16) {
+	flexible := version >= 2
+
+	if flexible {
+		e.WriteCompactArrayLen(len(r.Resources))
+	} else {
+		e.WriteArrayLen(len(r.Resources))
+	}
+
+	for _, res := range r.Resources {
+		e.WriteInt8(res.ResourceType)
+		writeAclString(e, res.ResourceName, flexible)
+		if version >= 1 {
+			e.WriteInt8(res.PatternType)        // v1+
+		}
+		if flexible {
+			e.WriteCompactArrayLen(len(res.Acls))
+		} else {
+			e.WriteArrayLen(len(res.Acls))
+		}
+		for _, a := range res.Acls {
+			writeAclString(e, a.Principal, flexible)
+			writeAclString(e, a.Host, flexible)
+			e.WriteInt8(a.Operation)
+			e.WriteInt8(a.PermissionType)
+			if flexible {
+				e.WriteEmptyTaggedFields()      // acl tagged fields
+			}
+		}
+		if flexible {
+			e.WriteEmptyTaggedFields()          // resource tagged fields
+		}
+	}
+}
+
+func (r *DescribeAclsResponse) writeTaggedFields(e *Encoder) {
+	e.WriteEmptyTaggedFields()
+}
+
+// Encode - the recipe
+
+func EncodeDescribeAclsResponse(e *Encoder, v int16, r *DescribeAclsResponse) {
+	r.writeThrottleTime(e)                      // v0+
+	r.writeError(e, v >= 2)                     // v0+
+	r.writeResources(e, v)                      // v0+
+	if v >= 2 {
+		r.writeTaggedFields(e)                  // v2+
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Shared by the ACL APIs, which turn flexible at v2
+// ----------------------------------------------------------------------------
+
+func readAclString(d *Decoder, flexible bool) string {
+	if flexible {
+		s, _ := d.ReadCompactString()
+		return s
+	}
+	s, _ := d.ReadString()
+	return s
+}
+
+func readAclNullableString(d *Decoder, flexible bool) *string {
+	if flexible {
+		s, _ := d.ReadCompactNullableString()
+		return s
+	}
+	s, _ := d.ReadNullableString()
+	return s
+}
+
+func writeAclString(e *Encoder, s string, flexible bool) {
+	if flexible {
+		e.WriteCompactString(s)
+	} else {
+		e.WriteString(s)
+	}
+}
+
+func writeAclNullableString(e *Encoder, s *string, flexible bool) {
+	if flexible {
+		e.WriteCompactNullableString(s)
+	} else {
+		e.WriteNullableString(s)
+	}
+}
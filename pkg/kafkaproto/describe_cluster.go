@@ -0,0 +1,124 @@
+package kafkaproto
+
+// ============================================================================
+// DescribeCluster (API Key 60)
+// Supported versions: 0-1
+// ============================================================================
+
+// Endpoint types a DescribeCluster v1+ request can ask for
+const (
+	EndpointTypeBroker     int8 = 1
+	EndpointTypeController int8 = 2
+)
+
+// ----------------------------------------------------------------------------
+// Request
+// ----------------------------------------------------------------------------
+
+type DescribeClusterRequest struct {
+	IncludeClusterAuthorizedOperations bool
+	EndpointType                       int8 // v1+
+}
+
+// Request Readers
+
+func (r *DescribeClusterRequest) readIncludeClusterAuthorizedOperations(d *Decoder) {
+	r.IncludeClusterAuthorizedOperations, _ = d.ReadBool()
+}
+
+func (r *DescribeClusterRequest) readEndpointType(d *Decoder) {
+	r.EndpointType, _ = d.ReadInt8()
+}
+
+func (r *DescribeClusterRequest) readTaggedFields(d *Decoder) {
+	d.ReadUVarInt()
+}
+
+// Decode - the recipe
+
+func DecodeDescribeClusterRequest(d *Decoder, v int16) (*DescribeClusterRequest, error) {
+	r := &DescribeClusterRequest{EndpointType: EndpointTypeBroker}
+
+	r.readIncludeClusterAuthorizedOperations(d) // v0+
+	if v >= 1 {
+		r.readEndpointType(d)                   // v1+
+	}
+	r.readTaggedFields(d)                       // v0+
+
+	return r, d.Err()
+}
+
+// ----------------------------------------------------------------------------
+// Response
+// ----------------------------------------------------------------------------
+
+type DescribeClusterResponse struct {
+	ThrottleTimeMs              int32
+	ErrorCode                   int16
+	ErrorMessage                *string
+	EndpointType                int8 // v1+
+	ClusterID                   string
+	ControllerID                int32
+	Brokers                     []DescribeClusterBroker
+	ClusterAuthorizedOperations int32
+}
+
+type DescribeClusterBroker struct {
+	BrokerID int32
+	Host     string
+	Port     int32
+	Rack     *string
+}
+
+// Response Writers
+
+func (r *DescribeClusterResponse) writeThrottleTime(e *Encoder) {
+	e.WriteInt32(r.ThrottleTimeMs)
+}
+
+func (r *DescribeClusterResponse) writeError(e *Encoder) {
+	e.WriteInt16(r.ErrorCode)
+	e.WriteCompactNullableString(r.ErrorMessage)
+}
+
+func (r *DescribeClusterResponse) writeEndpointType(e *Encoder) {
+	e.WriteInt8(r.EndpointType)
+}
+
+func (r *DescribeClusterResponse) writeCluster(e *Encoder) {
+	e.WriteCompactString(r.ClusterID)
+	e.WriteInt32(r.ControllerID)
+}
+
+func (r *DescribeClusterResponse) writeBrokers(e *Encoder) {
+	e.WriteCompactArrayLen(len(r.Brokers))
+	for _, b := range r.Brokers {
+		e.WriteInt32(b.BrokerID)
+		e.WriteCompactString(b.Host)
+		e.WriteInt32(b.Port)
+		e.WriteCompactNullableString(b.Rack)
+		e.WriteEmptyTaggedFields()              // broker tagged fields
+	}
+}
+
+func (r *DescribeClusterResponse) writeClusterAuthorizedOps(e *Encoder) {
+	e.WriteInt32(r.ClusterAuthorizedOperations)
+}
+
+func (r *DescribeClusterResponse) writeTaggedFields(e *Encoder) {
+	e.WriteEmptyTaggedFields()
+}
+
+// Encode - the recipe
+
+func EncodeDescribeClusterResponse(e *Encoder, v int16, r *DescribeClusterResponse) {
+	r.writeThrottleTime(e)                      // v0+
+	r.writeError(e)                             // v0+
+	if v >= 1 {
+		r.writeEndpointType(e)                  // v1+
+	}
+	r.writeCluster(e)                           // v0+
+	r.writeBrokers(e)                           // v0+
+	r.writeClusterAuthorizedOps(e)              // v0+
+	r.writeTaggedFields(e)                      // v0+
+}
@@ -0,0 +1,276 @@
+package kafkaproto
+
+// ============================================================================
+// DescribeConfigs (API Key 32)
+// Supported versions: 0-4
+// ============================================================================
+
+// Config resource types
+const (
+	ResourceTypeTopic  int8 = 2
+	ResourceTypeBroker int8 = 4
+)
+
+// Config value types, reported from v3
+const (
+	ConfigTypeUnknown int8 = 0
+	ConfigTypeBoolean int8 = 1
+	ConfigTypeString  int8 = 2
+	ConfigTypeInt     int8 = 3
+	ConfigTypeShort   int8 = 4
+	ConfigTypeLong    int8 = 5
+	ConfigTypeList    int8 = 7
+)
+
+// ----------------------------------------------------------------------------
+// Request
+// ----------------------------------------------------------------------------
+
+type DescribeConfigsRequest struct {
+	Resources            []DescribeConfigsResource
+	IncludeSynonyms      bool // v1+
+	IncludeDocumentation bool // v3+
+}
+
+type DescribeConfigsResource struct {
+	ResourceType int8
+	ResourceName string
+	ConfigNames  []string // nil for every config
+}
+
+// Request Readers
+
+func (r *DescribeConfigsRequest) readResources(d *Decoder, version int16) {
+	flexible := version >= 4
+
+	var count int
+	if flexible {
+		n, _ := d.ReadUVarInt()
+		count = int(n) - 1
+	} else {
+		n, _ := d.ReadInt32()
+		count = int(n)
+	}
+
+	r.Resources = make([]DescribeConfigsResource, max(count, 0))
+	for i := range r.Resources {
+		r.Resources[i].readFrom(d, flexible)
+	}
+}
+
+func (res *DescribeConfigsResource) readFrom(d *Decoder, flexible bool) {
+	res.ResourceType, _ = d.ReadInt8()
+
+	if flexible {
+		res.ResourceName, _ = d.ReadCompactString()
+	} else {
+		res.ResourceName, _ = d.ReadString()
+	}
+
+	// Config names; a null array asks for all of them
+	var count int
+	if flexible {
+		n, _ := d.ReadUVarInt()
+		count = int(n) - 1
+	} else {
+		n, _ := d.ReadInt32()
+		count = int(n)
+	}
+	if count >= 0 {
+		res.ConfigNames = make([]string, count)
+		for i := range res.ConfigNames {
+			if flexible {
+				res.ConfigNames[i], _ = d.ReadCompactString()
+			} else {
+				res.ConfigNames[i], _ = d.ReadString()
+			}
+		}
+	}
+
+	if flexible {
+		d.ReadUVarInt()                         // resource tagged fields
+	}
+}
+
+func (r *DescribeConfigsRequest) readIncludeSynonyms(d *Decoder) {
+	r.IncludeSynonyms, _ = d.ReadBool()
+}
+
+func (r *DescribeConfigsRequest) readIncludeDocumentation(d *Decoder) {
+	r.IncludeDocumentation, _ = d.ReadBool()
+}
+
+func (r *DescribeConfigsRequest) readTaggedFields(d *Decoder) {
+	d.ReadUVarInt()
+}
+
+// Decode - the recipe
+
+func DecodeDescribeConfigsRequest(d *Decoder, v int16) (*DescribeConfigsRequest, error) {
+	r := &DescribeConfigsRequest{}
+
+	r.readResources(d, v)                       // v0+
+	if v >= 1 {
+		r.readIncludeSynonyms(d)                // v1+
+	}
+	if v >= 3 {
+		r.readIncludeDocumentation(d)           // v3+
+	}
+	if v >= 4 {
+		r.readTaggedFields(d)                   // v4+
+	}
+
+	return r, d.Err()
+}
+
+// ----------------------------------------------------------------------------
+// Response
+// ----------------------------------------------------------------------------
+
+type DescribeConfigsResponse struct {
+	ThrottleTimeMs int32
+	Results        []DescribeConfigsResult
+}
+
+type DescribeConfigsResult struct {
+	ErrorCode    int16
+	ErrorMessage *string
+	ResourceType int8
+	ResourceName string
+	Configs      []DescribeConfigsEntry
+}
+
+type DescribeConfigsEntry struct {
+	Name          string
+	Value         *string
+	ReadOnly      bool
+	ConfigSource  int8 // v1+; v0 reports only whether it is the default
+	IsSensitive   bool
+	Synonyms      []DescribeConfigsSynonym // v1+
+	ConfigType    int8                     // v3+
+	Documentation *string                  // v3+
+}
+
+type DescribeConfigsSynonym struct {
+	Name   string
+	Value  *string
+	Source int8
+}
+
+// Response Writers
+
+func (r *DescribeConfigsResponse) writeThrottleTime(e *Encoder) {
+	e.WriteInt32(r.ThrottleTimeMs)
+}
+
+func (r *DescribeConfigsResponse) writeResults(e *Encoder, version int16) {
+	flexible := version >= 4
+
+	if flexible {
+		e.WriteCompactArrayLen(len(r.Results))
+	} else {
+		e.WriteArrayLen(len(r.Results))
+	}
+
+	for _, res := range r.Results {
+		res.writeTo(e, version)
+	}
+}
+
+func (res *DescribeConfigsResult) writeTo(e *Encoder, version int16) {
+	flexible := version >= 4
+
+	e.WriteInt16(res.ErrorCode)
+	if flexible {
+		e.WriteCompactNullableString(res.ErrorMessage)
+	} else {
+		e.WriteNullableString(res.ErrorMessage)
+	}
+	e.WriteInt8(res.ResourceType)
+	if flexible {
+		e.WriteCompactString(res.ResourceName)
+		e.WriteCompactArrayLen(len(res.Configs))
+	} else {
+		e.WriteString(res.ResourceName)
+		e.WriteArrayLen(len(res.Configs))
+	}
+
+	for _, c := range res.Configs {
+		c.writeTo(e, version)
+	}
+
+	if flexible {
+		e.WriteEmptyTaggedFields()              // result tagged fields
+	}
+}
+
+func (c *DescribeConfigsEntry) writeTo(e *Encoder, version int16) {
+	flexible := version >= 4
+
+	if flexible {
+		e.WriteCompactString(c.Name)
+		e.WriteCompactNullableString(c.Value)
+	} else {
+		e.WriteString(c.Name)
+		e.WriteNullableString(c.Value)
+	}
+	e.WriteBool(c.ReadOnly)
+	if version == 0 {
+		e.WriteBool(c.ConfigSource == ConfigSourceDefault) // v0 is_default
+	} else {
+		e.WriteInt8(c.ConfigSource)             // v1+
+	}
+	e.WriteBool(c.IsSensitive)
+
+	if version >= 1 {
+		c.writeSynonyms(e, flexible)            // v1+
+	}
+	if version >= 3 {
+		e.WriteInt8(c.ConfigType)               // v3+
+		if flexible {
+			e.WriteCompactNullableString(c.Documentation)
+		} else {
+			e.WriteNullableString(c.Documentation)
+		}
+	}
+
+	if flexible {
+		e.WriteEmptyTaggedFields()              // config tagged fields
+	}
+}
+
+func (c *DescribeConfigsEntry) writeSynonyms(e *Encoder, flexible bool) {
+	if flexible {
+		e.WriteCompactArrayLen(len(c.Synonyms))
+	} else {
+		e.WriteArrayLen(len(c.Synonyms))
+	}
+
+	for _, s := range c.Synonyms {
+		if flexible {
+			e.WriteCompactString(s.Name)
+			e.WriteCompactNullableString(s.Value)
+		} else {
+			e.WriteString(s.Name)
+			e.WriteNullableString(s.Value)
+		}
+		e.WriteInt8(s.Source)
+		if flexible {
+			e.WriteEmptyTaggedFields()          // synonym tagged fields
+		}
+	}
+}
+
+func (r *DescribeConfigsResponse) writeTaggedFields(e *Encoder) {
+	e.WriteEmptyTaggedFields()
+}
+
+// Encode - the recipe
+
+func EncodeDescribeConfigsResponse(e *Encoder, v int16, r *DescribeConfigsResponse) {
+	r.writeThrottleTime(e)                      // v0+
+	r.writeResults(e, v)                        // v0+
+	if v >= 4 {
+		r.writeTaggedFields(e)                  // v4+
+	}
+}
@@ -0,0 +1,190 @@
+package kafkaproto
+
+// ============================================================================
+// DescribeLogDirs (API Key 35)
+// Supported versions: 0-4
+// ============================================================================
+
+// ----------------------------------------------------------------------------
+// Request
+// ----------------------------------------------------------------------------
+
+type DescribeLogDirsRequest struct {
+	Topics []DescribeLogDirsRequestTopic // nil = all topics
+}
+
+type DescribeLogDirsRequestTopic struct {
+	Name       string
+	Partitions []int32
+}
+
+// Request Readers
+
+func (r *DescribeLogDirsRequest) readTopics(d *Decoder, flexible bool) {
+	var count int
+	if flexible {
+		n, _ := d.ReadUVarInt()
+		count = int(n) - 1
+	} else {
+		n, _ := d.ReadInt32()
+		count = int(n)
+	}
+
+	if count < 0 {
+		r.Topics = nil                          // null = all topics
+		return
+	}
+	r.Topics = make([]DescribeLogDirsRequestTopic, count)
+	for i := range r.Topics {
+		r.Topics[i].readFrom(d, flexible)
+	}
+}
+
+func (t *DescribeLogDirsRequestTopic) readFrom(d *Decoder, flexible bool) {
+	var count int
+	if flexible {
+		t.Name, _ = d.ReadCompactString()
+		n, _ := d.ReadUVarInt()
+		count = int(n) - 1
+	} else {
+		t.Name, _ = d.ReadString()
+		n, _ := d.ReadInt32()
+		count = int(n)
+	}
+
+	t.Partitions = make([]int32, max(count, 0))
+	for i := range t.Partitions {
+		t.Partitions[i], _ = d.ReadInt32()
+	}
+
+	if flexible {
+		d.ReadUVarInt()                         // topic tagged fields
+	}
+}
+
+func (r *DescribeLogDirsRequest) readTaggedFields(d *Decoder) {
+	d.ReadUVarInt()
+}
+
+// Decode - the recipe
+
+func DecodeDescribeLogDirsRequest(d *Decoder, v int16) (*DescribeLogDirsRequest, error) {
+	r := &DescribeLogDirsRequest{}
+
+	r.readTopics(d, v >= 2)                     // v0+
+	if v >= 2 {
+		r.readTaggedFields(d)                   // v2+
+	}
+
+	return r, d.Err()
+}
+
+// ----------------------------------------------------------------------------
+// Response
+// ----------------------------------------------------------------------------
+
+type DescribeLogDirsResponse struct {
+	ThrottleTimeMs int32
+	ErrorCode      int16 // v3+
+	Results        []DescribeLogDirsResult
+}
+
+type DescribeLogDirsResult struct {
+	ErrorCode   int16
+	LogDir      string
+	Topics      []DescribeLogDirsTopic
+	TotalBytes  int64 // v4+, -1 = unknown
+	UsableBytes int64 // v4+, -1 = unknown
+}
+
+type DescribeLogDirsTopic struct {
+	Name       string
+	Partitions []DescribeLogDirsPartition
+}
+
+type DescribeLogDirsPartition struct {
+	PartitionIndex int32
+	PartitionSize  int64
+	OffsetLag      int64
+	IsFutureKey    bool
+}
+
+// Response Writers
+
+func (r *DescribeLogDirsResponse) writeThrottleTime(e *Encoder) {
+	e.WriteInt32(r.ThrottleTimeMs)
+}
+
+func (r *DescribeLogDirsResponse) writeErrorCode(e *Encoder) {
+	e.WriteInt16(r.ErrorCode)
+}
+
+func (r *DescribeLogDirsResponse) writeResults(e *Encoder, version int16) {
+	flexible := version >= 2
+
+	if flexible {
+		e.WriteCompactArrayLen(len(r.Results))
+	} else {
+		e.WriteArrayLen(len(r.Results))
+	}
+
+	for _, res := range r.Results {
+		e.WriteInt16(res.ErrorCode)
+		if flexible {
+			e.WriteCompactString(res.LogDir)
+			e.WriteCompactArrayLen(len(res.Topics))
+		} else {
+			e.WriteString(res.LogDir)
+			e.WriteArrayLen(len(res.Topics))
+		}
+		for _, t := range res.Topics {
+			t.writeTo(e, flexible)
+		}
+		if version >= 4 {
+			e.WriteInt64(res.TotalBytes)        // v4+
+			e.WriteInt64(res.UsableBytes)       // v4+
+		}
+		if flexible {
+			e.WriteEmptyTaggedFields()          // result tagged fields
+		}
+	}
+}
+
+func (t *DescribeLogDirsTopic) writeTo(e *Encoder, flexible bool) {
+	if flexible {
+		e.WriteCompactString(t.Name)
+		e.WriteCompactArrayLen(len(t.Partitions))
+	} else {
+		e.WriteString(t.Name)
+		e.WriteArrayLen(len(t.Partitions))
+	}
+	for _, p := range t.Partitions {
+		e.WriteInt32(p.PartitionIndex)
+		e.WriteInt64(p.PartitionSize)
+		e.WriteInt64(p.OffsetLag)
+		e.WriteBool(p.IsFutureKey)
+		if flexible {
+			e.WriteEmptyTaggedFields()          // partition tagged fields
+		}
+	}
+	if flexible {
+		e.WriteEmptyTaggedFields()              // topic tagged fields
+	}
+}
+
+func (r *DescribeLogDirsResponse) writeTaggedFields(e *Encoder) {
+	e.WriteEmptyTaggedFields()
+}
+
+// Encode - the recipe
+
+func EncodeDescribeLogDirsResponse(e *Encoder, v int16, r *DescribeLogDirsResponse) {
+	r.writeThrottleTime(e)                      // v0+
+	if v >= 3 {
+		r.writeErrorCode(e)                     // v3+
+	}
+	r.writeResults(e, v)                        // v0+
+	if v >= 2 {
+		r.writeTaggedFields(e)                  // v2+
+	}
+}
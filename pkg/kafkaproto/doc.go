@@ -0,0 +1,20 @@
+// Package kafkaproto encodes and decodes the Kafka wire protocol as
+// monolog speaks it, for Go programs and test tools that want the codec
+// without the broker.
+//
+// For each supported API a file holds its request and response types,
+// DecodeXxxRequest and EncodeXxxResponse for the broker side, and the
+// supported version range in DefaultApiVersions. Frames are read and
+// written with ReadFrame and Frame, headers with Decoder.ReadHeader,
+// Encoder.WriteRequestHeader and the response header methods. Record
+// batches are parsed with ParseRecordBatchHeader and SplitRecordBatches
+// and built with NewRecordBatch, compressed with any registered Codec.
+//
+// Only MonologPurge has the client side as well, EncodeMonologPurgeRequest
+// and DecodeMonologPurgeResponse; a client of any other API writes its
+// request with the Encoder and reads the response with the Decoder. A
+// Decoder keeps the first error a read fails with and Err returns it.
+//
+// The package changes with the broker and makes no compatibility promise
+// beyond that of the monolog release it comes with.
+package kafkaproto
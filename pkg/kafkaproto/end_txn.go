@@ -0,0 +1,88 @@
+package kafkaproto
+
+// ============================================================================
+// EndTxn (API Key 26)
+// Supported versions: 0-3
+// ============================================================================
+
+// ----------------------------------------------------------------------------
+// Request
+// ----------------------------------------------------------------------------
+
+type EndTxnRequest struct {
+	TransactionalID string
+	ProducerID      int64
+	ProducerEpoch   int16
+	Committed       bool // false aborts
+}
+
+// Request Readers
+
+func (r *EndTxnRequest) readTransactionalID(d *Decoder, flexible bool) {
+	if flexible {
+		r.TransactionalID, _ = d.ReadCompactString()
+	} else {
+		r.TransactionalID, _ = d.ReadString()
+	}
+}
+
+func (r *EndTxnRequest) readProducer(d *Decoder) {
+	r.ProducerID, _ = d.ReadInt64()
+	r.ProducerEpoch, _ = d.ReadInt16()
+}
+
+func (r *EndTxnRequest) readCommitted(d *Decoder) {
+	r.Committed, _ = d.ReadBool()
+}
+
+func (r *EndTxnRequest) readTaggedFields(d *Decoder) {
+	d.ReadUVarInt()
+}
+
+// Decode - the recipe
+
+func DecodeEndTxnRequest(d *Decoder, v int16) (*EndTxnRequest, error) {
+	r := &EndTxnRequest{}
+
+	r.readTransactionalID(d, v >= 3)            // v0+
+	r.readProducer(d)                           // v0+
+	r.readCommitted(d)                          // v0+
+	if v >= 3 {
+		r.readTaggedFields(d)                   // v3+
+	}
+
+	return r, d.Err()
+}
+
+// ----------------------------------------------------------------------------
+// Response
+// ----------------------------------------------------------------------------
+
+type EndTxnResponse struct {
+	ThrottleTimeMs int32
+	ErrorCode      int16
+}
+
+// Response Writers
+
+func (r *EndTxnResponse) writeThrottleTime(e *Encoder) {
+	e.WriteInt32(r.ThrottleTimeMs)
+}
+
+func (r *EndTxnResponse) writeErrorCode(e *Encoder) {
+	e.WriteInt16(r.ErrorCode)
+}
+
+func (r *EndTxnResponse) writeTaggedFields(e *Encoder) {
+	e.WriteEmptyTaggedFields()
+}
+
+// Encode - the recipe
+
+func EncodeEndTxnResponse(e *Encoder, v int16, r *EndTxnResponse) {
+	r.writeThrottleTime(e)                      // v0+
+	r.writeErrorCode(e)                         // v0+
+	if v >= 3 {
+		r.writeTaggedFields(e)                  // v3+
+	}
+}
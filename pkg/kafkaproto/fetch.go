@@ -1,8 +1,8 @@
-package protocol
+package kafkaproto
 
 // ============================================================================
 // Fetch (API Key 1)
-// Supported versions: 0-11
+// Supported versions: 0-13 (flexible from v12)
 // ============================================================================
 
 // ----------------------------------------------------------------------------
@@ -22,7 +22,8 @@ type FetchRequest struct {
 }
 
 type FetchRequestTopic struct {
-	Name       string
+	Name       string // v0-12
+	TopicID    UUID   // v13+
 	Partitions []FetchRequestPartition
 }
 
@@ -30,6 +31,7 @@ type FetchRequestPartition struct {
 	Index              int32
 	CurrentLeaderEpoch int32 // v9+
 	FetchOffset        int64
+	LastFetchedEpoch   int32 // v12+
 	LogStartOffset     int64 // v5+
 	MaxBytes           int32
 }
@@ -59,8 +61,8 @@ func (r *FetchRequest) readSessionInfo(d *Decoder) {
 }
 
 func (r *FetchRequest) readTopics(d *Decoder, version int16) {
-	count, _ := d.ReadInt32()
-	r.Topics = make([]FetchRequestTopic, count)
+	count := readArrayLen(d, version >= 12)
+	r.Topics = make([]FetchRequestTopic, max(count, 0))
 
 	for i := range r.Topics {
 		r.Topics[i].readFrom(d, version)
@@ -68,14 +70,20 @@ func (r *FetchRequest) readTopics(d *Decoder, version int16) {
 }
 
 func (t *FetchRequestTopic) readFrom(d *Decoder, version int16) {
-	t.Name, _ = d.ReadString()
+	flexible := version >= 12
+	if version >= 13 {
+		t.TopicID, _ = d.ReadUUID()             // v13+
+	} else {
+		t.Name = readString(d, flexible)        // v0-12
+	}
 
-	count, _ := d.ReadInt32()
-	t.Partitions = make([]FetchRequestPartition, count)
+	count := readArrayLen(d, flexible)
+	t.Partitions = make([]FetchRequestPartition, max(count, 0))
 
 	for i := range t.Partitions {
 		t.Partitions[i].readFrom(d, version)
 	}
+	readTaggedFields(d, flexible)               // v12+
 }
 
 func (p *FetchRequestPartition) readFrom(d *Decoder, version int16) {
@@ -89,32 +97,49 @@ func (p *FetchRequestPartition) readFrom(d *Decoder, version int16) {
 
 	p.FetchOffset, _ = d.ReadInt64()
 
+	if version >= 12 {
+		p.LastFetchedEpoch, _ = d.ReadInt32()   // v12+
+	} else {
+		p.LastFetchedEpoch = -1
+	}
 	if version >= 5 {
 		p.LogStartOffset, _ = d.ReadInt64()     // v5+
 	}
 
 	p.MaxBytes, _ = d.ReadInt32()
+	readTaggedFields(d, version >= 12)          // v12+
 }
 
-func (r *FetchRequest) readForgottenTopics(d *Decoder) {
-	count, _ := d.ReadInt32()
-	for i := int32(0); i < count; i++ {
-		d.ReadString() // topic name
-		partCount, _ := d.ReadInt32()
-		for j := int32(0); j < partCount; j++ {
-			d.ReadInt32() // partition index
+func (r *FetchRequest) readForgottenTopics(d *Decoder, version int16) {
+	flexible := version >= 12
+	count := readArrayLen(d, flexible)
+	for i := 0; i < count; i++ {
+		if version >= 13 {
+			d.ReadUUID()                        // topic ID, v13+
+		} else {
+			readString(d, flexible)             // topic name, v0-12
+		}
+		partCount := readArrayLen(d, flexible)
+		for j := 0; j < partCount; j++ {
+			d.ReadInt32()                       // partition index
 		}
+		readTaggedFields(d, flexible)
 	}
 }
 
-func (r *FetchRequest) readRackID(d *Decoder) {
-	r.RackID, _ = d.ReadString()
+func (r *FetchRequest) readRackID(d *Decoder, flexible bool) {
+	r.RackID = readString(d, flexible)
+}
+
+func (r *FetchRequest) readTaggedFields(d *Decoder) {
+	d.SkipTaggedFields()
 }
 
 // Decode - the recipe
 
 func DecodeFetchRequest(d *Decoder, v int16) (*FetchRequest, error) {
 	r := &FetchRequest{}
+	flexible := v >= 12
 
 	r.readReplicaID(d)                          // v0+
 	r.readWaitAndBytes(d)                       // v0+
@@ -129,13 +154,16 @@ func DecodeFetchRequest(d *Decoder, v int16) (*FetchRequest, error) {
 	}
 	r.readTopics(d, v)                          // v0+
 	if v >= 7 {
-		r.readForgottenTopics(d)                // v7+
+		r.readForgottenTopics(d, v)             // v7+
 	}
 	if v >= 11 {
-		r.readRackID(d)                         // v11+
+		r.readRackID(d, flexible)               // v11+
+	}
+	if flexible {
+		r.readTaggedFields(d)                   // v12+
 	}
 
-	return r, nil
+	return r, d.Err()
 }
 
 // ----------------------------------------------------------------------------
@@ -150,7 +178,8 @@ type FetchResponse struct {
 }
 
 type FetchResponseTopic struct {
-	Name       string
+	Name       string // v0-12
+	TopicID    UUID   // v13+
 	Partitions []FetchResponsePartition
 }
 
@@ -160,10 +189,16 @@ type FetchResponsePartition struct {
 	HighWatermark        int64
 	LastStableOffset     int64 // v4+
 	LogStartOffset       int64 // v5+
-	PreferredReadReplica int32 // v11+
+	AbortedTransactions  []FetchAbortedTransaction // v4+
+	PreferredReadReplica int32                     // v11+
 	Records              []byte
 }
 
+type FetchAbortedTransaction struct {
+	ProducerID  int64
+	FirstOffset int64
+}
+
 // Response Writers
 
 func (r *FetchResponse) writeThrottleTime(e *Encoder) {
@@ -179,7 +214,7 @@ func (r *FetchResponse) writeSessionID(e *Encoder) {
 }
 
 func (r *FetchResponse) writeTopics(e *Encoder, version int16) {
-	e.WriteArrayLen(len(r.Topics))
+	writeArrayLen(e, len(r.Topics), version >= 12)
 
 	for _, t := range r.Topics {
 		t.writeTo(e, version)
@@ -187,15 +222,22 @@ func (r *FetchResponse) writeTopics(e *Encoder, version int16) {
 }
 
 func (t *FetchResponseTopic) writeTo(e *Encoder, version int16) {
-	e.WriteString(t.Name)
-	e.WriteArrayLen(len(t.Partitions))
+	flexible := version >= 12
+	if version >= 13 {
+		e.WriteUUID(t.TopicID)                  // v13+
+	} else {
+		writeString(e, t.Name, flexible)        // v0-12
+	}
+	writeArrayLen(e, len(t.Partitions), flexible)
 
 	for _, p := range t.Partitions {
 		p.writeTo(e, version)
 	}
+	writeTaggedFields(e, flexible)              // v12+
 }
 
 func (p *FetchResponsePartition) writeTo(e *Encoder, version int16) {
+	flexible := version >= 12
 	e.WriteInt32(p.Index)
 	e.WriteInt16(p.ErrorCode)
 	e.WriteInt64(p.HighWatermark)
@@ -207,13 +249,28 @@ func (p *FetchResponsePartition) writeTo(e *Encoder, version int16) {
 		e.WriteInt64(p.LogStartOffset)          // v5+
 	}
 	if version >= 4 {
-		e.WriteArrayLen(0)                      // v4+ aborted_transactions (empty)
+		p.writeAbortedTransactions(e, flexible) // v4+
 	}
 	if version >= 11 {
 		e.WriteInt32(p.PreferredReadReplica)    // v11+
 	}
 
-	e.WriteBytes(p.Records)                     // v0+
+	writeBytes(e, p.Records, flexible)          // v0+
+	writeTaggedFields(e, flexible)              // v12+
+}
+
+func (p *FetchResponsePartition) writeAbortedTransactions(e *Encoder, flexible bool) {
+	writeArrayLen(e, len(p.AbortedTransactions), flexible)
+
+	for _, t := range p.AbortedTransactions {
+		e.WriteInt64(t.ProducerID)
+		e.WriteInt64(t.FirstOffset)
+		writeTaggedFields(e, flexible)
+	}
+}
+
+func (r *FetchResponse) writeTaggedFields(e *Encoder) {
+	e.WriteEmptyTaggedFields()
 }
 
 // Encode - the recipe
@@ -227,4 +284,7 @@ func EncodeFetchResponse(e *Encoder, v int16, r *FetchResponse) {
 		r.writeSessionID(e)                     // v7+
 	}
 	r.writeTopics(e, v)                         // v0+
+	if v >= 12 {
+		r.writeTaggedFields(e)                  // v12+
+	}
 }
@@ -1,4 +1,4 @@
-package protocol
+package kafkaproto
 
 // ============================================================================
 // FindCoordinator (API Key 10)
@@ -56,7 +56,7 @@ func DecodeFindCoordinatorRequest(d *Decoder, v int16) (*FindCoordinatorRequest,
 		}
 	}
 
-	return r, nil
+	return r, d.Err()
 }
 
 // ----------------------------------------------------------------------------
@@ -0,0 +1,32 @@
+package kafkaproto
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Frame prefixes a request or response with its size, ready to write
+func Frame(body []byte) []byte {
+	frame := make([]byte, 4+len(body))
+	binary.BigEndian.PutUint32(frame[:4], uint32(len(body)))
+	copy(frame[4:], body)
+	return frame
+}
+
+// ReadFrame reads one size-prefixed request or response, without the size,
+// rejecting one larger than maxSize
+func ReadFrame(r io.Reader, maxSize int32) ([]byte, error) {
+	var size int32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	if size < 0 || size > maxSize {
+		return nil, fmt.Errorf("%w: frame size %d", ErrInvalidData, size)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
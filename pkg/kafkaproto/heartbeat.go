@@ -1,4 +1,4 @@
-package protocol
+package kafkaproto
 
 // ============================================================================
 // Heartbeat (API Key 12)
@@ -49,7 +49,7 @@ func DecodeHeartbeatRequest(d *Decoder, v int16) (*HeartbeatRequest, error) {
 		r.readGroupInstanceID(d)                // v3+
 	}
 
-	return r, nil
+	return r, d.Err()
 }
 
 // ----------------------------------------------------------------------------
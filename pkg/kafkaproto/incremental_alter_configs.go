@@ -0,0 +1,183 @@
+package kafkaproto
+
+// ============================================================================
+// IncrementalAlterConfigs (API Key 44)
+// Supported versions: 0-1
+// ============================================================================
+
+// Config operations
+const (
+	ConfigOpSet      int8 = 0
+	ConfigOpDelete   int8 = 1
+	ConfigOpAppend   int8 = 2
+	ConfigOpSubtract int8 = 3
+)
+
+// ----------------------------------------------------------------------------
+// Request
+// ----------------------------------------------------------------------------
+
+type IncrementalAlterConfigsRequest struct {
+	Resources    []IncrementalAlterConfigsResource
+	ValidateOnly bool
+}
+
+type IncrementalAlterConfigsResource struct {
+	ResourceType int8
+	ResourceName string
+	Configs      []IncrementalAlterConfig
+}
+
+type IncrementalAlterConfig struct {
+	Name      string
+	Operation int8
+	Value     *string
+}
+
+// Request Readers
+
+func (r *IncrementalAlterConfigsRequest) readResources(d *Decoder, version int16) {
+	flexible := version >= 1
+
+	var count int
+	if flexible {
+		n, _ := d.ReadUVarInt()
+		count = int(n) - 1
+	} else {
+		n, _ := d.ReadInt32()
+		count = int(n)
+	}
+
+	r.Resources = make([]IncrementalAlterConfigsResource, max(count, 0))
+	for i := range r.Resources {
+		r.Resources[i].readFrom(d, flexible)
+	}
+}
+
+func (res *IncrementalAlterConfigsResource) readFrom(d *Decoder, flexible bool) {
+	res.ResourceType, _ = d.ReadInt8()
+
+	if flexible {
+		res.ResourceName, _ = d.ReadCompactString()
+	} else {
+		res.ResourceName, _ = d.ReadString()
+	}
+
+	res.readConfigs(d, flexible)
+
+	if flexible {
+		d.ReadUVarInt()                         // resource tagged fields
+	}
+}
+
+func (res *IncrementalAlterConfigsResource) readConfigs(d *Decoder, flexible bool) {
+	var count int
+	if flexible {
+		n, _ := d.ReadUVarInt()
+		count = int(n) - 1
+	} else {
+		n, _ := d.ReadInt32()
+		count = int(n)
+	}
+
+	res.Configs = make([]IncrementalAlterConfig, max(count, 0))
+	for i := range res.Configs {
+		c := &res.Configs[i]
+		if flexible {
+			c.Name, _ = d.ReadCompactString()
+		} else {
+			c.Name, _ = d.ReadString()
+		}
+		c.Operation, _ = d.ReadInt8()
+		if flexible {
+			c.Value, _ = d.ReadCompactNullableString()
+			d.ReadUVarInt()                     // config tagged fields
+		} else {
+			c.Value, _ = d.ReadNullableString()
+		}
+	}
+}
+
+func (r *IncrementalAlterConfigsRequest) readValidateOnly(d *Decoder) {
+	r.ValidateOnly, _ = d.ReadBool()
+}
+
+func (r *IncrementalAlterConfigsRequest) readTaggedFields(d *Decoder) {
+	d.ReadUVarInt()
+}
+
+// Decode - the recipe
+
+func DecodeIncrementalAlterConfigsRequest(d *Decoder, v int16) (*IncrementalAlterConfigsRequest, error) {
+	r := &IncrementalAlterConfigsRequest{}
+
+	r.readResources(d, v)                       // v0+
+	r.readValidateOnly(d)                       // v0+
+	if v >= 1 {
+		r.readTaggedFields(d)                   // v1+
+	}
+
+	return r, d.Err()
+}
+
+// ----------------------------------------------------------------------------
+// Response
+// ----------------------------------------------------------------------------
+
+type IncrementalAlterConfigsResponse struct {
+	ThrottleTimeMs int32
+	Responses      []IncrementalAlterConfigsResult
+}
+
+type IncrementalAlterConfigsResult struct {
+	ErrorCode    int16
+	ErrorMessage *string
+	ResourceType int8
+	ResourceName string
+}
+
+// Response Writers
+
+func (r *IncrementalAlterConfigsResponse) writeThrottleTime(e *Encoder) {
+	e.WriteInt32(r.ThrottleTimeMs)
+}
+
+func (r *IncrementalAlterConfigsResponse) writeResponses(e *Encoder, version int16) {
+	flexible := version >= 1
+
+	if flexible {
+		e.WriteCompactArrayLen(len(r.Responses))
+	} else {
+		e.WriteArrayLen(len(r.Responses))
+	}
+
+	for _, res := range r.Responses {
+		e.WriteInt16(res.ErrorCode)
+		if flexible {
+			e.WriteCompactNullableString(res.ErrorMessage)
+		} else {
+			e.WriteNullableString(res.ErrorMessage)
+		}
+		e.WriteInt8(res.ResourceType)
+		if flexible {
+			e.WriteCompactString(res.ResourceName)
+			e.WriteEmptyTaggedFields()          // response tagged fields
+		} else {
+			e.WriteString(res.ResourceName)
+		}
+	}
+}
+
+func (r *IncrementalAlterConfigsResponse) writeTaggedFields(e *Encoder) {
+	e.WriteEmptyTaggedFields()
+}
+
+// Encode - the recipe
+
+func EncodeIncrementalAlterConfigsResponse(e *Encoder, v int16, r *IncrementalAlterConfigsResponse) {
+	r.writeThrottleTime(e)                      // v0+
+	r.writeResponses(e, v)                      // v0+
+	if v >= 1 {
+		r.writeTaggedFields(e)                  // v1+
+	}
+}
@@ -0,0 +1,98 @@
+package kafkaproto
+
+// ============================================================================
+// InitProducerId (API Key 22)
+// Supported versions: 0-4
+// ============================================================================
+
+// ----------------------------------------------------------------------------
+// Request
+// ----------------------------------------------------------------------------
+
+type InitProducerIDRequest struct {
+	TransactionalID      *string // nil for an idempotent producer
+	TransactionTimeoutMs int32
+	ProducerID           int64 // v3+; -1, or the ID whose epoch to bump
+	ProducerEpoch        int16 // v3+
+}
+
+// Request Readers
+
+func (r *InitProducerIDRequest) readTransactionalID(d *Decoder, flexible bool) {
+	if flexible {
+		r.TransactionalID, _ = d.ReadCompactNullableString()
+	} else {
+		r.TransactionalID, _ = d.ReadNullableString()
+	}
+}
+
+func (r *InitProducerIDRequest) readTransactionTimeout(d *Decoder) {
+	r.TransactionTimeoutMs, _ = d.ReadInt32()
+}
+
+func (r *InitProducerIDRequest) readProducer(d *Decoder) {
+	r.ProducerID, _ = d.ReadInt64()
+	r.ProducerEpoch, _ = d.ReadInt16()
+}
+
+func (r *InitProducerIDRequest) readTaggedFields(d *Decoder) {
+	d.ReadUVarInt()
+}
+
+// Decode - the recipe
+
+func DecodeInitProducerIDRequest(d *Decoder, v int16) (*InitProducerIDRequest, error) {
+	r := &InitProducerIDRequest{ProducerID: -1, ProducerEpoch: -1}
+
+	r.readTransactionalID(d, v >= 2)            // v0+
+	r.readTransactionTimeout(d)                 // v0+
+	if v >= 3 {
+		r.readProducer(d)                       // v3+
+	}
+	if v >= 2 {
+		r.readTaggedFields(d)                   // v2+
+	}
+
+	return r, d.Err()
+}
+
+// ----------------------------------------------------------------------------
+// Response
+// ----------------------------------------------------------------------------
+
+type InitProducerIDResponse struct {
+	ThrottleTimeMs int32
+	ErrorCode      int16
+	ProducerID     int64
+	ProducerEpoch  int16
+}
+
+// Response Writers
+
+func (r *InitProducerIDResponse) writeThrottleTime(e *Encoder) {
+	e.WriteInt32(r.ThrottleTimeMs)
+}
+
+func (r *InitProducerIDResponse) writeErrorCode(e *Encoder) {
+	e.WriteInt16(r.ErrorCode)
+}
+
+func (r *InitProducerIDResponse) writeProducer(e *Encoder) {
+	e.WriteInt64(r.ProducerID)
+	e.WriteInt16(r.ProducerEpoch)
+}
+
+func (r *InitProducerIDResponse) writeTaggedFields(e *Encoder) {
+	e.WriteEmptyTaggedFields()
+}
+
+// Encode - the recipe
+
+func EncodeInitProducerIDResponse(e *Encoder, v int16, r *InitProducerIDResponse) {
+	r.writeThrottleTime(e)                      // v0+
+	r.writeErrorCode(e)                         // v0+
+	r.writeProducer(e)                          // v0+
+	if v >= 2 {
+		r.writeTaggedFields(e)                  // v2+
+	}
+}
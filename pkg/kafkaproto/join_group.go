@@ -1,4 +1,4 @@
-package protocol
+package kafkaproto
 
 // ============================================================================
 // JoinGroup (API Key 11)
@@ -80,7 +80,7 @@ func DecodeJoinGroupRequest(d *Decoder, v int16) (*JoinGroupRequest, error) {
 	r.readProtocolType(d)                       // v0+
 	r.readProtocols(d)                          // v0+
 
-	return r, nil
+	return r, d.Err()
 }
 
 // ----------------------------------------------------------------------------
@@ -142,7 +142,11 @@ func (r *JoinGroupResponse) writeMembers(e *Encoder, version int16) {
 func (m *JoinGroupResponseMember) writeTo(e *Encoder, version int16) {
 	e.WriteString(m.MemberID)
 	if version >= 5 {
-		e.WriteNullableString(&m.GroupInstanceID)   // v5+
+		if m.GroupInstanceID == "" {
+			e.WriteNullableString(nil)              // v5+ (dynamic member)
+		} else {
+			e.WriteNullableString(&m.GroupInstanceID) // v5+
+		}
 	}
 	e.WriteBytes(m.Metadata)
 }
@@ -1,4 +1,4 @@
-package protocol
+package kafkaproto
 
 // ============================================================================
 // LeaveGroup (API Key 13)
@@ -55,7 +55,7 @@ func DecodeLeaveGroupRequest(d *Decoder, v int16) (*LeaveGroupRequest, error) {
 		r.readMembers(d)                        // v3+
 	}
 
-	return r, nil
+	return r, d.Err()
 }
 
 // ----------------------------------------------------------------------------
@@ -1,4 +1,4 @@
-package protocol
+package kafkaproto
 
 // ============================================================================
 // ListOffsets (API Key 2)
@@ -89,7 +89,7 @@ func DecodeListOffsetsRequest(d *Decoder, v int16) (*ListOffsetsRequest, error)
 	}
 	r.readTopics(d, v)                          // v0+
 
-	return r, nil
+	return r, d.Err()
 }
 
 // ----------------------------------------------------------------------------
@@ -1,8 +1,8 @@
-package protocol
+package kafkaproto
 
 // ============================================================================
 // Metadata (API Key 3)
-// Supported versions: 0-8
+// Supported versions: 0-12 (flexible from v9)
 // ============================================================================
 
 // ----------------------------------------------------------------------------
@@ -10,21 +10,32 @@ package protocol
 // ----------------------------------------------------------------------------
 
 type MetadataRequest struct {
-	Topics                             []string // nil = all topics
+	Topics                             []string // nil = all topics; "" where only an ID was given
+	TopicIDs                           []UUID   // v10+: parallel to Topics, zero where only a name was given
 	AllowAutoTopicCreation             bool     // v4+
-	IncludeClusterAuthorizedOperations bool     // v8+
+	IncludeClusterAuthorizedOperations bool     // v8-10
 	IncludeTopicAuthorizedOperations   bool     // v8+
 }
 
 // Request Readers
 
-func (r *MetadataRequest) readTopics(d *Decoder) {
-	count, _ := d.ReadInt32()
+func (r *MetadataRequest) readTopics(d *Decoder, version int16) {
+	flexible := version >= 9
+	count := readArrayLen(d, flexible)
 
 	if count > 0 {
 		r.Topics = make([]string, count)
+		r.TopicIDs = make([]UUID, count)
 		for i := range r.Topics {
-			r.Topics[i], _ = d.ReadString()
+			if version >= 10 {
+				r.TopicIDs[i], _ = d.ReadUUID()  // v10+
+				if name := readNullableString(d, flexible); name != nil {
+					r.Topics[i] = *name
+				}
+			} else {
+				r.Topics[i] = readString(d, flexible)
+			}
+			readTaggedFields(d, flexible)       // v9+
 		}
 	} else if count == -1 {
 		r.Topics = nil // all topics
@@ -35,25 +46,39 @@ func (r *MetadataRequest) readAllowAutoTopicCreation(d *Decoder) {
 	r.AllowAutoTopicCreation, _ = d.ReadBool()
 }
 
-func (r *MetadataRequest) readAuthorizedOpsFlags(d *Decoder) {
+func (r *MetadataRequest) readClusterAuthorizedOpsFlag(d *Decoder) {
 	r.IncludeClusterAuthorizedOperations, _ = d.ReadBool()
+}
+
+func (r *MetadataRequest) readTopicAuthorizedOpsFlag(d *Decoder) {
 	r.IncludeTopicAuthorizedOperations, _ = d.ReadBool()
 }
 
+func (r *MetadataRequest) readTaggedFields(d *Decoder) {
+	d.SkipTaggedFields()
+}
+
 // Decode - the recipe
 
 func DecodeMetadataRequest(d *Decoder, v int16) (*MetadataRequest, error) {
 	r := &MetadataRequest{}
+	flexible := v >= 9
 
-	r.readTopics(d)                             // v0+
+	r.readTopics(d, v)                          // v0+
 	if v >= 4 {
 		r.readAllowAutoTopicCreation(d)         // v4+
 	}
+	if v >= 8 && v <= 10 {
+		r.readClusterAuthorizedOpsFlag(d)       // v8-10
+	}
 	if v >= 8 {
-		r.readAuthorizedOpsFlags(d)             // v8+
+		r.readTopicAuthorizedOpsFlag(d)         // v8+
+	}
+	if flexible {
+		r.readTaggedFields(d)                   // v9+
 	}
 
-	return r, nil
+	return r, d.Err()
 }
 
 // ----------------------------------------------------------------------------
@@ -66,7 +91,7 @@ type MetadataResponse struct {
 	ClusterID            *string // v2+
 	ControllerID         int32   // v1+
 	Topics               []MetadataTopic
-	ClusterAuthorizedOps int32 // v8+
+	ClusterAuthorizedOps int32 // v8-10
 	IncludeClusterOps    bool  // internal: whether to include cluster ops
 	IncludeTopicOps      bool  // internal: whether to include topic ops
 }
@@ -80,8 +105,9 @@ type MetadataBroker struct {
 
 type MetadataTopic struct {
 	ErrorCode          int16
-	Name               string
-	IsInternal         bool // v1+
+	Name               string // null from v12 when empty: a topic asked for by an unknown ID
+	TopicID            UUID   // v10+
+	IsInternal         bool   // v1+
 	Partitions         []MetadataPartition
 	TopicAuthorizedOps int32 // v8+
 }
@@ -103,7 +129,7 @@ func (r *MetadataResponse) writeThrottleTime(e *Encoder) {
 }
 
 func (r *MetadataResponse) writeBrokers(e *Encoder, version int16) {
-	e.WriteArrayLen(len(r.Brokers))
+	writeArrayLen(e, len(r.Brokers), version >= 9)
 
 	for _, b := range r.Brokers {
 		b.writeTo(e, version)
@@ -111,17 +137,19 @@ func (r *MetadataResponse) writeBrokers(e *Encoder, version int16) {
 }
 
 func (b *MetadataBroker) writeTo(e *Encoder, version int16) {
+	flexible := version >= 9
 	e.WriteInt32(b.NodeID)
-	e.WriteString(b.Host)
+	writeString(e, b.Host, flexible)
 	e.WriteInt32(b.Port)
 
 	if version >= 1 {
-		e.WriteNullableString(b.Rack)           // v1+
+		writeNullableString(e, b.Rack, flexible) // v1+
 	}
+	writeTaggedFields(e, flexible)              // v9+
 }
 
-func (r *MetadataResponse) writeClusterID(e *Encoder) {
-	e.WriteNullableString(r.ClusterID)
+func (r *MetadataResponse) writeClusterID(e *Encoder, flexible bool) {
+	writeNullableString(e, r.ClusterID, flexible)
 }
 
 func (r *MetadataResponse) writeControllerID(e *Encoder) {
@@ -129,7 +157,7 @@ func (r *MetadataResponse) writeControllerID(e *Encoder) {
 }
 
 func (r *MetadataResponse) writeTopics(e *Encoder, version int16) {
-	e.WriteArrayLen(len(r.Topics))
+	writeArrayLen(e, len(r.Topics), version >= 9)
 
 	for _, t := range r.Topics {
 		t.writeTo(e, version, r.IncludeTopicOps)
@@ -137,14 +165,22 @@ func (r *MetadataResponse) writeTopics(e *Encoder, version int16) {
 }
 
 func (t *MetadataTopic) writeTo(e *Encoder, version int16, includeOps bool) {
+	flexible := version >= 9
 	e.WriteInt16(t.ErrorCode)
-	e.WriteString(t.Name)
+	if version >= 12 && t.Name == "" {
+		writeNullableString(e, nil, flexible)   // v12+
+	} else {
+		writeString(e, t.Name, flexible)
+	}
+	if version >= 10 {
+		e.WriteUUID(t.TopicID)                  // v10+
+	}
 
 	if version >= 1 {
 		e.WriteBool(t.IsInternal)               // v1+
 	}
 
-	e.WriteArrayLen(len(t.Partitions))
+	writeArrayLen(e, len(t.Partitions), flexible)
 	for _, p := range t.Partitions {
 		p.writeTo(e, version)
 	}
@@ -156,9 +192,11 @@ func (t *MetadataTopic) writeTo(e *Encoder, version int16, includeOps bool) {
 			e.WriteInt32(-2147483648)           // INT32_MIN = not requested
 		}
 	}
+	writeTaggedFields(e, flexible)              // v9+
 }
 
 func (p *MetadataPartition) writeTo(e *Encoder, version int16) {
+	flexible := version >= 9
 	e.WriteInt16(p.ErrorCode)
 	e.WriteInt32(p.PartitionIndex)
 	e.WriteInt32(p.LeaderID)
@@ -167,22 +205,23 @@ func (p *MetadataPartition) writeTo(e *Encoder, version int16) {
 		e.WriteInt32(p.LeaderEpoch)             // v7+
 	}
 
-	e.WriteArrayLen(len(p.ReplicaNodes))
+	writeArrayLen(e, len(p.ReplicaNodes), flexible)
 	for _, r := range p.ReplicaNodes {
 		e.WriteInt32(r)
 	}
 
-	e.WriteArrayLen(len(p.IsrNodes))
+	writeArrayLen(e, len(p.IsrNodes), flexible)
 	for _, r := range p.IsrNodes {
 		e.WriteInt32(r)
 	}
 
 	if version >= 5 {
-		e.WriteArrayLen(len(p.OfflineReplicas)) // v5+
+		writeArrayLen(e, len(p.OfflineReplicas), flexible) // v5+
 		for _, r := range p.OfflineReplicas {
 			e.WriteInt32(r)
 		}
 	}
+	writeTaggedFields(e, flexible)              // v9+
 }
 
 func (r *MetadataResponse) writeClusterAuthorizedOps(e *Encoder) {
@@ -193,6 +232,10 @@ func (r *MetadataResponse) writeClusterAuthorizedOps(e *Encoder) {
 	}
 }
 
+func (r *MetadataResponse) writeTaggedFields(e *Encoder) {
+	e.WriteEmptyTaggedFields()
+}
+
 // Encode - the recipe
 
 func EncodeMetadataResponse(e *Encoder, v int16, r *MetadataResponse) {
@@ -201,13 +244,16 @@ func EncodeMetadataResponse(e *Encoder, v int16, r *MetadataResponse) {
 	}
 	r.writeBrokers(e, v)                        // v0+
 	if v >= 2 {
-		r.writeClusterID(e)                     // v2+
+		r.writeClusterID(e, v >= 9)             // v2+
 	}
 	if v >= 1 {
 		r.writeControllerID(e)                  // v1+
 	}
 	r.writeTopics(e, v)                         // v0+
-	if v >= 8 {
-		r.writeClusterAuthorizedOps(e)          // v8+
+	if v >= 8 && v <= 10 {
+		r.writeClusterAuthorizedOps(e)          // v8-10
+	}
+	if v >= 9 {
+		r.writeTaggedFields(e)                  // v9+
 	}
 }
@@ -0,0 +1,168 @@
+package kafkaproto
+
+// ============================================================================
+// MonologPurge (API Key 32000, monolog extension)
+// Supported versions: 0
+//
+// Deletes a topic's messages older than a timestamp, or all of them, for
+// test suites that only speak the Kafka protocol. The key sits far above
+// Kafka's own range so it cannot collide with a future Kafka API.
+// ============================================================================
+
+// PurgeAll as a topic's BeforeMs deletes every message
+const PurgeAll int64 = -1
+
+// ----------------------------------------------------------------------------
+// Request
+// ----------------------------------------------------------------------------
+
+type MonologPurgeRequest struct {
+	Topics []MonologPurgeRequestTopic
+}
+
+type MonologPurgeRequestTopic struct {
+	Name     string
+	BeforeMs int64 // delete messages with an earlier timestamp; PurgeAll for every message
+}
+
+// Request Readers
+
+func (r *MonologPurgeRequest) readTopics(d *Decoder) error {
+	count, err := d.ReadInt32()
+	if err != nil {
+		return err
+	}
+	if count < 0 {
+		return ErrInvalidData
+	}
+	r.Topics = make([]MonologPurgeRequestTopic, 0, min(int(count), 64))
+
+	for i := int32(0); i < count; i++ {
+		var t MonologPurgeRequestTopic
+		if t.Name, err = d.ReadString(); err != nil {
+			return err
+		}
+		if t.BeforeMs, err = d.ReadInt64(); err != nil {
+			return err
+		}
+		r.Topics = append(r.Topics, t)
+	}
+	return nil
+}
+
+// Request Writers
+
+func (r *MonologPurgeRequest) writeTopics(e *Encoder) {
+	e.WriteArrayLen(len(r.Topics))
+
+	for _, t := range r.Topics {
+		e.WriteString(t.Name)
+		e.WriteInt64(t.BeforeMs)
+	}
+}
+
+// Decode / Encode - the recipes
+
+func DecodeMonologPurgeRequest(d *Decoder, v int16) (*MonologPurgeRequest, error) {
+	r := &MonologPurgeRequest{}
+
+	if err := r.readTopics(d); err != nil {    // v0+
+		return nil, err
+	}
+
+	return r, d.Err()
+}
+
+func EncodeMonologPurgeRequest(e *Encoder, v int16, r *MonologPurgeRequest) {
+	r.writeTopics(e)                            // v0+
+}
+
+// ----------------------------------------------------------------------------
+// Response
+// ----------------------------------------------------------------------------
+
+type MonologPurgeResponse struct {
+	ThrottleTimeMs int32
+	Topics         []MonologPurgeResponseTopic
+}
+
+type MonologPurgeResponseTopic struct {
+	Name           string
+	ErrorCode      int16
+	DeletedRows    int32 // stored rows removed; a row can hold a whole batch
+	LogStartOffset int64 // earliest offset left
+}
+
+// Response Writers
+
+func (r *MonologPurgeResponse) writeThrottleTime(e *Encoder) {
+	e.WriteInt32(r.ThrottleTimeMs)
+}
+
+func (r *MonologPurgeResponse) writeTopics(e *Encoder) {
+	e.WriteArrayLen(len(r.Topics))
+
+	for _, t := range r.Topics {
+		e.WriteString(t.Name)
+		e.WriteInt16(t.ErrorCode)
+		e.WriteInt32(t.DeletedRows)
+		e.WriteInt64(t.LogStartOffset)
+	}
+}
+
+// Response Readers
+
+func (r *MonologPurgeResponse) readThrottleTime(d *Decoder) error {
+	var err error
+	r.ThrottleTimeMs, err = d.ReadInt32()
+	return err
+}
+
+func (r *MonologPurgeResponse) readTopics(d *Decoder) error {
+	count, err := d.ReadInt32()
+	if err != nil {
+		return err
+	}
+	if count < 0 {
+		return ErrInvalidData
+	}
+	r.Topics = make([]MonologPurgeResponseTopic, 0, min(int(count), 64))
+
+	for i := int32(0); i < count; i++ {
+		var t MonologPurgeResponseTopic
+		if t.Name, err = d.ReadString(); err != nil {
+			return err
+		}
+		if t.ErrorCode, err = d.ReadInt16(); err != nil {
+			return err
+		}
+		if t.DeletedRows, err = d.ReadInt32(); err != nil {
+			return err
+		}
+		if t.LogStartOffset, err = d.ReadInt64(); err != nil {
+			return err
+		}
+		r.Topics = append(r.Topics, t)
+	}
+	return nil
+}
+
+// Encode / Decode - the recipes
+
+func EncodeMonologPurgeResponse(e *Encoder, v int16, r *MonologPurgeResponse) {
+	r.writeThrottleTime(e)                      // v0+
+	r.writeTopics(e)                            // v0+
+}
+
+func DecodeMonologPurgeResponse(d *Decoder, v int16) (*MonologPurgeResponse, error) {
+	r := &MonologPurgeResponse{}
+
+	if err := r.readThrottleTime(d); err != nil { // v0+
+		return nil, err
+	}
+	if err := r.readTopics(d); err != nil {    // v0+
+		return nil, err
+	}
+
+	return r, d.Err()
+}
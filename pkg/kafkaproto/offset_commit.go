@@ -1,4 +1,4 @@
-package protocol
+package kafkaproto
 
 // ============================================================================
 // OffsetCommit (API Key 8)
@@ -105,7 +105,7 @@ func DecodeOffsetCommitRequest(d *Decoder, v int16) (*OffsetCommitRequest, error
 	}
 	r.readTopics(d, v)                          // v0+
 
-	return r, nil
+	return r, d.Err()
 }
 
 // ----------------------------------------------------------------------------
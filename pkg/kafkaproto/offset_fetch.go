@@ -1,4 +1,4 @@
-package protocol
+package kafkaproto
 
 // ============================================================================
 // OffsetFetch (API Key 9)
@@ -55,7 +55,7 @@ func DecodeOffsetFetchRequest(d *Decoder, v int16) (*OffsetFetchRequest, error)
 	r.readGroupID(d)                            // v0+
 	r.readTopics(d)                             // v0+
 
-	return r, nil
+	return r, d.Err()
 }
 
 // ----------------------------------------------------------------------------
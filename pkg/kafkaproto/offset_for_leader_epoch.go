@@ -0,0 +1,177 @@
+package kafkaproto
+
+// ============================================================================
+// OffsetForLeaderEpoch (API Key 23)
+// Supported versions: 0-3
+// ============================================================================
+
+// Leader epoch sentinels
+const (
+	UndefinedEpoch       int32 = -1
+	UndefinedEpochOffset int64 = -1
+)
+
+// ----------------------------------------------------------------------------
+// Request
+// ----------------------------------------------------------------------------
+
+type OffsetForLeaderEpochRequest struct {
+	ReplicaID int32 // v3+
+	Topics    []OffsetForLeaderEpochRequestTopic
+}
+
+type OffsetForLeaderEpochRequestTopic struct {
+	Name       string
+	Partitions []OffsetForLeaderEpochRequestPartition
+}
+
+type OffsetForLeaderEpochRequestPartition struct {
+	PartitionIndex     int32
+	CurrentLeaderEpoch int32 // v2+
+	LeaderEpoch        int32
+}
+
+// Request Readers
+
+func (r *OffsetForLeaderEpochRequest) readReplicaID(d *Decoder) {
+	r.ReplicaID, _ = d.ReadInt32()
+}
+
+func (r *OffsetForLeaderEpochRequest) readTopics(d *Decoder, version int16) error {
+	count, err := d.ReadInt32()
+	if err != nil {
+		return err
+	}
+	if count < 0 {
+		return ErrInvalidData
+	}
+	r.Topics = make([]OffsetForLeaderEpochRequestTopic, 0, min(int(count), 64))
+
+	for i := int32(0); i < count; i++ {
+		var t OffsetForLeaderEpochRequestTopic
+		if err := t.readFrom(d, version); err != nil {
+			return err
+		}
+		r.Topics = append(r.Topics, t)
+	}
+	return nil
+}
+
+func (t *OffsetForLeaderEpochRequestTopic) readFrom(d *Decoder, version int16) error {
+	var err error
+	if t.Name, err = d.ReadString(); err != nil {
+		return err
+	}
+
+	count, err := d.ReadInt32()
+	if err != nil {
+		return err
+	}
+	if count < 0 {
+		return ErrInvalidData
+	}
+	t.Partitions = make([]OffsetForLeaderEpochRequestPartition, 0, min(int(count), 64))
+
+	for i := int32(0); i < count; i++ {
+		var p OffsetForLeaderEpochRequestPartition
+		if err := p.readFrom(d, version); err != nil {
+			return err
+		}
+		t.Partitions = append(t.Partitions, p)
+	}
+	return nil
+}
+
+func (p *OffsetForLeaderEpochRequestPartition) readFrom(d *Decoder, version int16) error {
+	var err error
+	if p.PartitionIndex, err = d.ReadInt32(); err != nil {
+		return err
+	}
+
+	p.CurrentLeaderEpoch = UndefinedEpoch
+	if version >= 2 {
+		if p.CurrentLeaderEpoch, err = d.ReadInt32(); err != nil { // v2+
+			return err
+		}
+	}
+
+	p.LeaderEpoch, err = d.ReadInt32()
+	return err
+}
+
+// Decode - the recipe
+
+func DecodeOffsetForLeaderEpochRequest(d *Decoder, v int16) (*OffsetForLeaderEpochRequest, error) {
+	r := &OffsetForLeaderEpochRequest{ReplicaID: -1}
+
+	if v >= 3 {
+		r.readReplicaID(d)                      // v3+
+	}
+	if err := r.readTopics(d, v); err != nil {  // v0+
+		return nil, err
+	}
+
+	return r, d.Err()
+}
+
+// ----------------------------------------------------------------------------
+// Response
+// ----------------------------------------------------------------------------
+
+type OffsetForLeaderEpochResponse struct {
+	ThrottleTimeMs int32 // v2+
+	Topics         []OffsetForLeaderEpochResponseTopic
+}
+
+type OffsetForLeaderEpochResponseTopic struct {
+	Name       string
+	Partitions []OffsetForLeaderEpochResponsePartition
+}
+
+type OffsetForLeaderEpochResponsePartition struct {
+	ErrorCode      int16
+	PartitionIndex int32
+	LeaderEpoch    int32 // v1+
+	EndOffset      int64
+}
+
+// Response Writers
+
+func (r *OffsetForLeaderEpochResponse) writeThrottleTime(e *Encoder) {
+	e.WriteInt32(r.ThrottleTimeMs)
+}
+
+func (r *OffsetForLeaderEpochResponse) writeTopics(e *Encoder, version int16) {
+	e.WriteArrayLen(len(r.Topics))
+
+	for _, t := range r.Topics {
+		t.writeTo(e, version)
+	}
+}
+
+func (t *OffsetForLeaderEpochResponseTopic) writeTo(e *Encoder, version int16) {
+	e.WriteString(t.Name)
+	e.WriteArrayLen(len(t.Partitions))
+
+	for _, p := range t.Partitions {
+		p.writeTo(e, version)
+	}
+}
+
+func (p *OffsetForLeaderEpochResponsePartition) writeTo(e *Encoder, version int16) {
+	e.WriteInt16(p.ErrorCode)
+	e.WriteInt32(p.PartitionIndex)
+	if version >= 1 {
+		e.WriteInt32(p.LeaderEpoch)             // v1+
+	}
+	e.WriteInt64(p.EndOffset)
+}
+
+// Encode - the recipe
+
+func EncodeOffsetForLeaderEpochResponse(e *Encoder, v int16, r *OffsetForLeaderEpochResponse) {
+	if v >= 2 {
+		r.writeThrottleTime(e)                  // v2+
+	}
+	r.writeTopics(e, v)                         // v0+
+}
@@ -1,8 +1,8 @@
-package protocol
+package kafkaproto
 
 // ============================================================================
 // Produce (API Key 0)
-// Supported versions: 0-8
+// Supported versions: 0-9 (flexible from v9)
 // ============================================================================
 
 // ----------------------------------------------------------------------------
@@ -28,8 +28,8 @@ type ProduceRequestPartition struct {
 
 // Request Readers
 
-func (r *ProduceRequest) readTransactionalID(d *Decoder) {
-	r.TransactionalID, _ = d.ReadNullableString()
+func (r *ProduceRequest) readTransactionalID(d *Decoder, flexible bool) {
+	r.TransactionalID = readNullableString(d, flexible)
 }
 
 func (r *ProduceRequest) readAcks(d *Decoder) {
@@ -40,40 +40,50 @@ func (r *ProduceRequest) readTimeout(d *Decoder) {
 	r.TimeoutMs, _ = d.ReadInt32()
 }
 
-func (r *ProduceRequest) readTopics(d *Decoder) {
-	count, _ := d.ReadInt32()
-	r.Topics = make([]ProduceRequestTopic, count)
+func (r *ProduceRequest) readTopics(d *Decoder, flexible bool) {
+	count := readArrayLen(d, flexible)
+	r.Topics = make([]ProduceRequestTopic, max(count, 0))
 
 	for i := range r.Topics {
-		r.Topics[i].readFrom(d)
+		r.Topics[i].readFrom(d, flexible)
 	}
 }
 
-func (t *ProduceRequestTopic) readFrom(d *Decoder) {
-	t.Name, _ = d.ReadString()
+func (t *ProduceRequestTopic) readFrom(d *Decoder, flexible bool) {
+	t.Name = readString(d, flexible)
 
-	count, _ := d.ReadInt32()
-	t.Partitions = make([]ProduceRequestPartition, count)
+	count := readArrayLen(d, flexible)
+	t.Partitions = make([]ProduceRequestPartition, max(count, 0))
 
 	for i := range t.Partitions {
 		t.Partitions[i].Index, _ = d.ReadInt32()
-		t.Partitions[i].Records, _ = d.ReadBytes()
+		t.Partitions[i].Records = readBytes(d, flexible)
+		readTaggedFields(d, flexible)           // partition tagged fields
 	}
+	readTaggedFields(d, flexible)               // topic tagged fields
+}
+
+func (r *ProduceRequest) readTaggedFields(d *Decoder) {
+	d.SkipTaggedFields()
 }
 
 // Decode - the recipe
 
 func DecodeProduceRequest(d *Decoder, v int16) (*ProduceRequest, error) {
 	r := &ProduceRequest{}
+	flexible := v >= 9
 
 	if v >= 3 {
-		r.readTransactionalID(d)                // v3+
+		r.readTransactionalID(d, flexible)      // v3+
 	}
 	r.readAcks(d)                               // v0+
 	r.readTimeout(d)                            // v0+
-	r.readTopics(d)                             // v0+
+	r.readTopics(d, flexible)                   // v0+
+	if flexible {
+		r.readTaggedFields(d)                   // v9+
+	}
 
-	return r, nil
+	return r, d.Err()
 }
 
 // ----------------------------------------------------------------------------
@@ -94,14 +104,15 @@ type ProduceResponsePartition struct {
 	Index           int32
 	ErrorCode       int16
 	BaseOffset      int64
-	LogAppendTimeMs int64 // v2+
-	LogStartOffset  int64 // v5+
+	LogAppendTimeMs int64  // v2+
+	LogStartOffset  int64  // v5+
+	ErrorMessage    string // v8+, empty for none
 }
 
 // Response Writers
 
 func (r *ProduceResponse) writeTopics(e *Encoder, version int16) {
-	e.WriteArrayLen(len(r.Topics))
+	writeArrayLen(e, len(r.Topics), version >= 9)
 
 	for _, t := range r.Topics {
 		t.writeTo(e, version)
@@ -109,15 +120,18 @@ func (r *ProduceResponse) writeTopics(e *Encoder, version int16) {
 }
 
 func (t *ProduceResponseTopic) writeTo(e *Encoder, version int16) {
-	e.WriteString(t.Name)
-	e.WriteArrayLen(len(t.Partitions))
+	flexible := version >= 9
+	writeString(e, t.Name, flexible)
+	writeArrayLen(e, len(t.Partitions), flexible)
 
 	for _, p := range t.Partitions {
 		p.writeTo(e, version)
 	}
+	writeTaggedFields(e, flexible)              // v9+
 }
 
 func (p *ProduceResponsePartition) writeTo(e *Encoder, version int16) {
+	flexible := version >= 9
 	e.WriteInt32(p.Index)
 	e.WriteInt16(p.ErrorCode)
 	e.WriteInt64(p.BaseOffset)
@@ -129,15 +143,28 @@ func (p *ProduceResponsePartition) writeTo(e *Encoder, version int16) {
 		e.WriteInt64(p.LogStartOffset)          // v5+
 	}
 	if version >= 8 {
-		e.WriteArrayLen(0)                      // v8+ record_errors (empty)
-		e.WriteNullableString(nil)              // v8+ error_message (null)
+		writeArrayLen(e, 0, flexible)           // v8+ record_errors (empty)
+		p.writeErrorMessage(e, flexible)        // v8+ error_message
 	}
+	writeTaggedFields(e, flexible)              // v9+
+}
+
+func (p *ProduceResponsePartition) writeErrorMessage(e *Encoder, flexible bool) {
+	if p.ErrorMessage == "" {
+		writeNullableString(e, nil, flexible)
+		return
+	}
+	writeNullableString(e, &p.ErrorMessage, flexible)
 }
 
 func (r *ProduceResponse) writeThrottleTime(e *Encoder) {
 	e.WriteInt32(r.ThrottleTimeMs)
 }
 
+func (r *ProduceResponse) writeTaggedFields(e *Encoder) {
+	e.WriteEmptyTaggedFields()
+}
+
 // Encode - the recipe
 
 func EncodeProduceResponse(e *Encoder, v int16, r *ProduceResponse) {
@@ -145,4 +172,7 @@ func EncodeProduceResponse(e *Encoder, v int16, r *ProduceResponse) {
 	if v >= 1 {
 		r.writeThrottleTime(e)                  // v1+
 	}
+	if v >= 9 {
+		r.writeTaggedFields(e)                  // v9+
+	}
 }
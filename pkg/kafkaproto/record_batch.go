@@ -0,0 +1,383 @@
+package kafkaproto
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// ============================================================================
+// Record Batch (magic v2)
+//
+// Offset 0:  baseOffset (8 bytes)
+// Offset 8:  batchLength (4 bytes)
+// Offset 12: partitionLeaderEpoch (4 bytes)
+// Offset 16: magic (1 byte) - should be 2
+// Offset 17: crc (4 bytes)
+// Offset 21: attributes (2 bytes) - bits 0-2 = codec
+// Offset 23: lastOffsetDelta (4 bytes)
+// Offset 27: firstTimestamp (8 bytes)
+// Offset 35: maxTimestamp (8 bytes)
+// Offset 43: producerId (8 bytes)
+// Offset 51: producerEpoch (2 bytes)
+// Offset 53: baseSequence (4 bytes)
+// Offset 57: recordCount (4 bytes)
+// Offset 61: records start
+// ============================================================================
+
+// RecordBatchHeaderSize is the size of a v2 record batch header
+const RecordBatchHeaderSize = 61
+
+// Record batch attribute bits
+const (
+	BatchAttrCodecMask     int16 = 0x07
+	BatchAttrLogAppendTime int16 = 0x08
+	BatchAttrTransactional int16 = 0x10
+	BatchAttrControl       int16 = 0x20
+)
+
+// batchLengthOffset is where the length-prefixed part of a batch starts
+const batchLengthOffset = 12
+
+// batchCRCOffset is where the part of a batch covered by its CRC starts
+const batchCRCOffset = 21
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// ParseRecordBatchHeader decodes the header of the v2 record batch at the
+// start of data. RawRecords is set to the full batch, including the header.
+func ParseRecordBatchHeader(data []byte) (*RecordBatch, error) {
+	if len(data) < RecordBatchHeaderSize {
+		return nil, fmt.Errorf("record batch too short: %d bytes", len(data))
+	}
+
+	b := &RecordBatch{
+		BaseOffset:           int64(binary.BigEndian.Uint64(data[0:8])),
+		BatchLength:          int32(binary.BigEndian.Uint32(data[8:12])),
+		PartitionLeaderEpoch: int32(binary.BigEndian.Uint32(data[12:16])),
+		Magic:                int8(data[16]),
+		CRC:                  int32(binary.BigEndian.Uint32(data[17:21])),
+		Attributes:           int16(binary.BigEndian.Uint16(data[21:23])),
+		LastOffsetDelta:      int32(binary.BigEndian.Uint32(data[23:27])),
+		FirstTimestamp:       int64(binary.BigEndian.Uint64(data[27:35])),
+		MaxTimestamp:         int64(binary.BigEndian.Uint64(data[35:43])),
+		ProducerID:           int64(binary.BigEndian.Uint64(data[43:51])),
+		ProducerEpoch:        int16(binary.BigEndian.Uint16(data[51:53])),
+		BaseSequence:         int32(binary.BigEndian.Uint32(data[53:57])),
+	}
+	b.Codec = int8(b.Attributes & BatchAttrCodecMask)
+
+	if b.Magic != 2 {
+		return nil, fmt.Errorf("unsupported record batch magic: %d", b.Magic)
+	}
+	size := int(b.BatchLength) + batchLengthOffset
+	if b.BatchLength < RecordBatchHeaderSize-batchLengthOffset || size > len(data) {
+		return nil, fmt.Errorf("invalid record batch length: %d (have %d bytes)", b.BatchLength, len(data))
+	}
+	if b.LastOffsetDelta < 0 {
+		return nil, fmt.Errorf("invalid last offset delta: %d", b.LastOffsetDelta)
+	}
+
+	b.RawRecords = data[:size]
+	return b, nil
+}
+
+// RecordCount returns the number of records declared in the batch header
+func (b *RecordBatch) RecordCount() int {
+	return int(binary.BigEndian.Uint32(b.RawRecords[57:61]))
+}
+
+// Size returns the total encoded size of the batch in bytes
+func (b *RecordBatch) Size() int {
+	return int(b.BatchLength) + batchLengthOffset
+}
+
+// ValidCRC reports whether the batch's CRC-32C matches its contents from
+// the attributes on
+func (b *RecordBatch) ValidCRC() bool {
+	return crc32.Checksum(b.RawRecords[batchCRCOffset:], crc32c) == uint32(b.CRC)
+}
+
+// RebaseBatch sets the base offset and partition leader epoch of the batch
+// at the start of data, in place. Both precede the part of the batch its
+// CRC covers, so the CRC stays valid without being recomputed.
+func RebaseBatch(data []byte, baseOffset int64, leaderEpoch int32) {
+	if len(data) >= 8 {
+		binary.BigEndian.PutUint64(data[0:8], uint64(baseOffset))
+	}
+	if len(data) >= 16 {
+		binary.BigEndian.PutUint32(data[12:16], uint32(leaderEpoch))
+	}
+}
+
+// SplitRecordBatches parses consecutive v2 record batches from data
+func SplitRecordBatches(data []byte) ([]*RecordBatch, error) {
+	var batches []*RecordBatch
+	for len(data) > 0 {
+		b, err := ParseRecordBatchHeader(data)
+		if err != nil {
+			return nil, fmt.Errorf("batch %d: %w", len(batches), err)
+		}
+		batches = append(batches, b)
+		data = data[b.Size():]
+	}
+	return batches, nil
+}
+
+// MaxTimestamp returns the latest maxTimestamp of the v2 record batches in
+// data, or -1 if data holds none or does not parse
+func MaxTimestamp(data []byte) int64 {
+	batches, err := SplitRecordBatches(data)
+	if err != nil {
+		return -1
+	}
+	ts := int64(-1)
+	for _, b := range batches {
+		ts = max(ts, b.MaxTimestamp)
+	}
+	return ts
+}
+
+// SetLogAppendTime returns a copy of the batches in data stamped with the
+// broker's append time, as Kafka does for topics with
+// message.timestamp.type=LogAppendTime: the timestamp type bit is set and
+// maxTimestamp, which consumers then report for every record, becomes ts.
+func SetLogAppendTime(data []byte, ts int64) ([]byte, error) {
+	batches, err := SplitRecordBatches(data)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(data))
+	for _, b := range batches {
+		raw := append([]byte{}, b.RawRecords...)
+		binary.BigEndian.PutUint16(raw[21:23], uint16(b.Attributes|BatchAttrLogAppendTime))
+		binary.BigEndian.PutUint64(raw[35:43], uint64(ts))
+		binary.BigEndian.PutUint32(raw[17:21], crc32.Checksum(raw[batchCRCOffset:], crc32c))
+		out = append(out, raw...)
+	}
+	return out, nil
+}
+
+// DecodeRecords decompresses and decodes the batch's records. Offsets and
+// timestamps are absolute; keys and values of -1 length are nil.
+func (b *RecordBatch) DecodeRecords() ([]Record, error) {
+	data, err := Decompress(b.RawRecords[RecordBatchHeaderSize:], b.Codec)
+	if err != nil {
+		return nil, fmt.Errorf("decompress: %w", err)
+	}
+
+	records := make([]Record, 0, min(b.RecordCount(), 4096))
+	for len(data) > 0 {
+		length, n := binary.Varint(data)
+		if n <= 0 || length < 0 || int64(len(data)-n) < length {
+			return nil, fmt.Errorf("record %d: invalid length", len(records))
+		}
+		rec, err := b.decodeRecord(data[n : n+int(length)])
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", len(records), err)
+		}
+		records = append(records, rec)
+		data = data[n+int(length):]
+	}
+	if len(records) != b.RecordCount() {
+		return nil, fmt.Errorf("decoded %d records, header declares %d", len(records), b.RecordCount())
+	}
+	return records, nil
+}
+
+func (b *RecordBatch) decodeRecord(data []byte) (Record, error) {
+	var rec Record
+	if len(data) < 1 {
+		return rec, fmt.Errorf("record too short")
+	}
+	data = data[1:] // attributes, unused in v2
+
+	varint := func() (int64, error) {
+		v, n := binary.Varint(data)
+		if n <= 0 {
+			return 0, fmt.Errorf("invalid varint")
+		}
+		data = data[n:]
+		return v, nil
+	}
+	bytesField := func() ([]byte, error) {
+		length, err := varint()
+		if err != nil || length < 0 {
+			return nil, err
+		}
+		if int64(len(data)) < length {
+			return nil, fmt.Errorf("field overflows record")
+		}
+		v := data[:length:length]
+		data = data[length:]
+		return v, nil
+	}
+
+	timestampDelta, err := varint()
+	if err != nil {
+		return rec, err
+	}
+	offsetDelta, err := varint()
+	if err != nil {
+		return rec, err
+	}
+	rec.Timestamp = b.FirstTimestamp + timestampDelta
+	if b.Attributes&BatchAttrLogAppendTime != 0 {
+		rec.Timestamp = b.MaxTimestamp
+	}
+	rec.Offset = b.BaseOffset + offsetDelta
+	if rec.Key, err = bytesField(); err != nil {
+		return rec, err
+	}
+	if rec.Value, err = bytesField(); err != nil {
+		return rec, err
+	}
+
+	count, err := varint()
+	if err != nil {
+		return rec, err
+	}
+	for i := int64(0); i < count; i++ {
+		key, err := bytesField()
+		if err != nil {
+			return rec, err
+		}
+		value, err := bytesField()
+		if err != nil {
+			return rec, err
+		}
+		rec.Headers = append(rec.Headers, RecordHeader{Key: string(key), Value: value})
+	}
+	return rec, nil
+}
+
+// EncodeRecords returns a copy of the batch holding records instead of its
+// own, compressed with the batch's codec and with the length and CRC
+// recomputed. The rest of the header is kept, so records must keep the
+// batch's offsets and timestamps.
+func (b *RecordBatch) EncodeRecords(records []Record) ([]byte, error) {
+	body, err := Compress(encodeRecords(records, b.BaseOffset, b.FirstTimestamp), b.Codec)
+	if err != nil {
+		return nil, fmt.Errorf("compress: %w", err)
+	}
+	out := make([]byte, RecordBatchHeaderSize, RecordBatchHeaderSize+len(body))
+	copy(out, b.RawRecords[:RecordBatchHeaderSize])
+	binary.BigEndian.PutUint32(out[57:61], uint32(len(records)))
+	return sealBatch(out, body), nil
+}
+
+// Recompress returns a copy of the batch with its records section
+// re-encoded by c and marked as codec
+func (b *RecordBatch) Recompress(codec int8, c Codec) ([]byte, error) {
+	data, err := Decompress(b.RawRecords[RecordBatchHeaderSize:], b.Codec)
+	if err != nil {
+		return nil, fmt.Errorf("decompress: %w", err)
+	}
+	body, err := c.Compress(data)
+	if err != nil {
+		return nil, fmt.Errorf("compress: %w", err)
+	}
+	out := make([]byte, RecordBatchHeaderSize, RecordBatchHeaderSize+len(body))
+	copy(out, b.RawRecords[:RecordBatchHeaderSize])
+	binary.BigEndian.PutUint16(out[21:23], uint16(b.Attributes&^BatchAttrCodecMask|int16(codec)&BatchAttrCodecMask))
+	return sealBatch(out, body), nil
+}
+
+// NewRecordBatch encodes records as a batch with no producer ID, compressed
+// by c and marked as codec; c is nil for an uncompressed batch. Records
+// carry offsets counting from 0 and absolute timestamps.
+func NewRecordBatch(records []Record, codec int8, c Codec) ([]byte, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no records to encode")
+	}
+	first, maxTimestamp := records[0].Timestamp, records[0].Timestamp
+	for _, r := range records {
+		first, maxTimestamp = min(first, r.Timestamp), max(maxTimestamp, r.Timestamp)
+	}
+	body := encodeRecords(records, 0, first)
+	if c != nil {
+		var err error
+		if body, err = c.Compress(body); err != nil {
+			return nil, fmt.Errorf("compress: %w", err)
+		}
+	}
+	out := make([]byte, RecordBatchHeaderSize, RecordBatchHeaderSize+len(body))
+	out[16] = 2 // magic
+	binary.BigEndian.PutUint16(out[21:23], uint16(int16(codec)&BatchAttrCodecMask))
+	binary.BigEndian.PutUint32(out[23:27], uint32(records[len(records)-1].Offset))
+	binary.BigEndian.PutUint64(out[27:35], uint64(first))
+	binary.BigEndian.PutUint64(out[35:43], uint64(maxTimestamp))
+	binary.BigEndian.PutUint64(out[43:51], ^uint64(0)) // no producer ID
+	binary.BigEndian.PutUint16(out[51:53], ^uint16(0))
+	binary.BigEndian.PutUint32(out[53:57], ^uint32(0))
+	binary.BigEndian.PutUint32(out[57:61], uint32(len(records)))
+	return sealBatch(out, body), nil
+}
+
+// encodeRecords encodes the records section of a batch, uncompressed
+func encodeRecords(records []Record, baseOffset, firstTimestamp int64) []byte {
+	var data []byte
+	var rec []byte
+	for _, r := range records {
+		rec = append(rec[:0], 0) // attributes
+		rec = binary.AppendVarint(rec, r.Timestamp-firstTimestamp)
+		rec = binary.AppendVarint(rec, r.Offset-baseOffset)
+		rec = appendVarintBytes(rec, r.Key)
+		rec = appendVarintBytes(rec, r.Value)
+		rec = binary.AppendVarint(rec, int64(len(r.Headers)))
+		for _, h := range r.Headers {
+			rec = appendVarintBytes(rec, []byte(h.Key))
+			rec = appendVarintBytes(rec, h.Value)
+		}
+		data = binary.AppendVarint(data, int64(len(rec)))
+		data = append(data, rec...)
+	}
+	return data
+}
+
+// sealBatch appends body to a batch header and fills in the length and CRC
+func sealBatch(header, body []byte) []byte {
+	out := append(header, body...)
+	binary.BigEndian.PutUint32(out[8:12], uint32(len(out)-batchLengthOffset))
+	binary.BigEndian.PutUint32(out[17:21], crc32.Checksum(out[batchCRCOffset:], crc32c))
+	return out
+}
+
+// appendVarintBytes appends a varint length and b, or -1 for nil
+func appendVarintBytes(dst, b []byte) []byte {
+	if b == nil {
+		return binary.AppendVarint(dst, -1)
+	}
+	dst = binary.AppendVarint(dst, int64(len(b)))
+	return append(dst, b...)
+}
+
+// Control record types, the key of a transaction marker
+const (
+	ControlTypeAbort  int16 = 0
+	ControlTypeCommit int16 = 1
+)
+
+// NewControlBatch encodes the marker that ends a producer's transaction on
+// a partition: a transactional control batch holding one control record,
+// commit or abort, as the transaction coordinator writes it
+func NewControlBatch(producerID int64, producerEpoch int16, commit bool, ts int64) []byte {
+	controlType := ControlTypeAbort
+	if commit {
+		controlType = ControlTypeCommit
+	}
+	key := binary.BigEndian.AppendUint16([]byte{0, 0}, uint16(controlType)) // version 0, type
+	value := make([]byte, 6)                                                // version 0, coordinator epoch 0
+
+	body := encodeRecords([]Record{{Timestamp: ts, Key: key, Value: value}}, 0, ts)
+	out := make([]byte, RecordBatchHeaderSize, RecordBatchHeaderSize+len(body))
+	out[16] = 2 // magic
+	binary.BigEndian.PutUint16(out[21:23], uint16(BatchAttrTransactional|BatchAttrControl))
+	binary.BigEndian.PutUint64(out[27:35], uint64(ts))
+	binary.BigEndian.PutUint64(out[35:43], uint64(ts))
+	binary.BigEndian.PutUint64(out[43:51], uint64(producerID))
+	binary.BigEndian.PutUint16(out[51:53], uint16(producerEpoch))
+	binary.BigEndian.PutUint32(out[53:57], ^uint32(0)) // no sequence
+	binary.BigEndian.PutUint32(out[57:61], 1)
+	return sealBatch(out, body)
+}
@@ -0,0 +1,96 @@
+package kafkaproto
+
+// ============================================================================
+// SaslAuthenticate (API Key 36)
+// Supported versions: 0-2
+// ============================================================================
+
+// ----------------------------------------------------------------------------
+// Request
+// ----------------------------------------------------------------------------
+
+type SaslAuthenticateRequest struct {
+	AuthBytes []byte
+}
+
+// Request Readers
+
+func (r *SaslAuthenticateRequest) readAuthBytes(d *Decoder, version int16) {
+	if version >= 2 {
+		r.AuthBytes, _ = d.ReadCompactBytes()
+	} else {
+		r.AuthBytes, _ = d.ReadBytes()
+	}
+}
+
+func (r *SaslAuthenticateRequest) readTaggedFields(d *Decoder) {
+	d.ReadUVarInt()
+}
+
+// Decode - the recipe
+
+func DecodeSaslAuthenticateRequest(d *Decoder, v int16) (*SaslAuthenticateRequest, error) {
+	r := &SaslAuthenticateRequest{}
+
+	r.readAuthBytes(d, v)                       // v0+
+	if v >= 2 {
+		r.readTaggedFields(d)                   // v2+
+	}
+
+	return r, d.Err()
+}
+
+// ----------------------------------------------------------------------------
+// Response
+// ----------------------------------------------------------------------------
+
+type SaslAuthenticateResponse struct {
+	ErrorCode         int16
+	ErrorMessage      *string
+	AuthBytes         []byte
+	SessionLifetimeMs int64 // v1+; 0 if the session does not expire (KIP-368)
+}
+
+// Response Writers
+
+func (r *SaslAuthenticateResponse) writeErrorCode(e *Encoder) {
+	e.WriteInt16(r.ErrorCode)
+}
+
+func (r *SaslAuthenticateResponse) writeErrorMessage(e *Encoder, version int16) {
+	if version >= 2 {
+		e.WriteCompactNullableString(r.ErrorMessage)
+	} else {
+		e.WriteNullableString(r.ErrorMessage)
+	}
+}
+
+func (r *SaslAuthenticateResponse) writeAuthBytes(e *Encoder, version int16) {
+	if version >= 2 {
+		e.WriteCompactBytes(r.AuthBytes)
+	} else {
+		e.WriteBytes(r.AuthBytes)
+	}
+}
+
+func (r *SaslAuthenticateResponse) writeSessionLifetime(e *Encoder) {
+	e.WriteInt64(r.SessionLifetimeMs)
+}
+
+func (r *SaslAuthenticateResponse) writeTaggedFields(e *Encoder) {
+	e.WriteEmptyTaggedFields()
+}
+
+// Encode - the recipe
+
+func EncodeSaslAuthenticateResponse(e *Encoder, v int16, r *SaslAuthenticateResponse) {
+	r.writeErrorCode(e)                         // v0+
+	r.writeErrorMessage(e, v)                   // v0+
+	r.writeAuthBytes(e, v)                      // v0+
+	if v >= 1 {
+		r.writeSessionLifetime(e)               // v1+
+	}
+	if v >= 2 {
+		r.writeTaggedFields(e)                  // v2+
+	}
+}
@@ -1,4 +1,4 @@
-package protocol
+package kafkaproto
 
 // ============================================================================
 // SyncGroup (API Key 14)
@@ -82,7 +82,7 @@ func DecodeSyncGroupRequest(d *Decoder, v int16) (*SyncGroupRequest, error) {
 	}
 	r.readAssignments(d)                        // v0+
 
-	return r, nil
+	return r, d.Err()
 }
 
 // ----------------------------------------------------------------------------
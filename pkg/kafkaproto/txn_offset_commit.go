@@ -0,0 +1,204 @@
+package kafkaproto
+
+// ============================================================================
+// TxnOffsetCommit (API Key 28)
+// Supported versions: 0-3
+// ============================================================================
+
+// ----------------------------------------------------------------------------
+// Request
+// ----------------------------------------------------------------------------
+
+type TxnOffsetCommitRequest struct {
+	TransactionalID string
+	GroupID         string
+	ProducerID      int64
+	ProducerEpoch   int16
+	GenerationID    int32   // v3+
+	MemberID        string  // v3+
+	GroupInstanceID *string // v3+
+	Topics          []TxnOffsetCommitRequestTopic
+}
+
+type TxnOffsetCommitRequestTopic struct {
+	Name       string
+	Partitions []TxnOffsetCommitRequestPartition
+}
+
+type TxnOffsetCommitRequestPartition struct {
+	Index           int32
+	CommittedOffset int64
+	LeaderEpoch     int32 // v2+
+	Metadata        *string
+}
+
+// Request Readers
+
+func (r *TxnOffsetCommitRequest) readIDs(d *Decoder, flexible bool) {
+	if flexible {
+		r.TransactionalID, _ = d.ReadCompactString()
+		r.GroupID, _ = d.ReadCompactString()
+	} else {
+		r.TransactionalID, _ = d.ReadString()
+		r.GroupID, _ = d.ReadString()
+	}
+}
+
+func (r *TxnOffsetCommitRequest) readProducer(d *Decoder) {
+	r.ProducerID, _ = d.ReadInt64()
+	r.ProducerEpoch, _ = d.ReadInt16()
+}
+
+func (r *TxnOffsetCommitRequest) readMember(d *Decoder) {
+	r.GenerationID, _ = d.ReadInt32()
+	r.MemberID, _ = d.ReadCompactString()
+	r.GroupInstanceID, _ = d.ReadCompactNullableString()
+}
+
+func (r *TxnOffsetCommitRequest) readTopics(d *Decoder, version int16) {
+	flexible := version >= 3
+
+	var count int
+	if flexible {
+		n, _ := d.ReadUVarInt()
+		count = int(n) - 1
+	} else {
+		n, _ := d.ReadInt32()
+		count = int(n)
+	}
+
+	r.Topics = make([]TxnOffsetCommitRequestTopic, max(count, 0))
+	for i := range r.Topics {
+		r.Topics[i].readFrom(d, version)
+	}
+}
+
+func (t *TxnOffsetCommitRequestTopic) readFrom(d *Decoder, version int16) {
+	flexible := version >= 3
+
+	var count int
+	if flexible {
+		t.Name, _ = d.ReadCompactString()
+		n, _ := d.ReadUVarInt()
+		count = int(n) - 1
+	} else {
+		t.Name, _ = d.ReadString()
+		n, _ := d.ReadInt32()
+		count = int(n)
+	}
+
+	t.Partitions = make([]TxnOffsetCommitRequestPartition, max(count, 0))
+	for i := range t.Partitions {
+		t.Partitions[i].readFrom(d, version)
+	}
+
+	if flexible {
+		d.ReadUVarInt()                         // topic tagged fields
+	}
+}
+
+func (p *TxnOffsetCommitRequestPartition) readFrom(d *Decoder, version int16) {
+	p.Index, _ = d.ReadInt32()
+	p.CommittedOffset, _ = d.ReadInt64()
+	p.LeaderEpoch = -1
+	if version >= 2 {
+		p.LeaderEpoch, _ = d.ReadInt32()
+	}
+	if version >= 3 {
+		p.Metadata, _ = d.ReadCompactNullableString()
+		d.ReadUVarInt()                         // partition tagged fields
+	} else {
+		p.Metadata, _ = d.ReadNullableString()
+	}
+}
+
+func (r *TxnOffsetCommitRequest) readTaggedFields(d *Decoder) {
+	d.ReadUVarInt()
+}
+
+// Decode - the recipe
+
+func DecodeTxnOffsetCommitRequest(d *Decoder, v int16) (*TxnOffsetCommitRequest, error) {
+	r := &TxnOffsetCommitRequest{GenerationID: -1}
+
+	r.readIDs(d, v >= 3)                        // v0+
+	r.readProducer(d)                           // v0+
+	if v >= 3 {
+		r.readMember(d)                         // v3+
+	}
+	r.readTopics(d, v)                          // v0+
+	if v >= 3 {
+		r.readTaggedFields(d)                   // v3+
+	}
+
+	return r, d.Err()
+}
+
+// ----------------------------------------------------------------------------
+// Response
+// ----------------------------------------------------------------------------
+
+type TxnOffsetCommitResponse struct {
+	ThrottleTimeMs int32
+	Topics         []TxnOffsetCommitResponseTopic
+}
+
+type TxnOffsetCommitResponseTopic struct {
+	Name       string
+	Partitions []TxnOffsetCommitResponsePartition
+}
+
+type TxnOffsetCommitResponsePartition struct {
+	Index     int32
+	ErrorCode int16
+}
+
+// Response Writers
+
+func (r *TxnOffsetCommitResponse) writeThrottleTime(e *Encoder) {
+	e.WriteInt32(r.ThrottleTimeMs)
+}
+
+func (r *TxnOffsetCommitResponse) writeTopics(e *Encoder, version int16) {
+	flexible := version >= 3
+
+	if flexible {
+		e.WriteCompactArrayLen(len(r.Topics))
+	} else {
+		e.WriteArrayLen(len(r.Topics))
+	}
+
+	for _, t := range r.Topics {
+		if flexible {
+			e.WriteCompactString(t.Name)
+			e.WriteCompactArrayLen(len(t.Partitions))
+		} else {
+			e.WriteString(t.Name)
+			e.WriteArrayLen(len(t.Partitions))
+		}
+		for _, p := range t.Partitions {
+			e.WriteInt32(p.Index)
+			e.WriteInt16(p.ErrorCode)
+			if flexible {
+				e.WriteEmptyTaggedFields()          // partition tagged fields
+			}
+		}
+		if flexible {
+			e.WriteEmptyTaggedFields()              // topic tagged fields
+		}
+	}
+}
+
+func (r *TxnOffsetCommitResponse) writeTaggedFields(e *Encoder) {
+	e.WriteEmptyTaggedFields()
+}
+
+// Encode - the recipe
+
+func EncodeTxnOffsetCommitResponse(e *Encoder, v int16, r *TxnOffsetCommitResponse) {
+	r.writeThrottleTime(e)                      // v0+
+	r.writeTopics(e, v)                         // v0+
+	if v >= 3 {
+		r.writeTaggedFields(e)                  // v3+
+	}
+}
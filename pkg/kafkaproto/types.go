@@ -0,0 +1,217 @@
+package kafkaproto
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// API Keys
+const (
+	APIKeyProduce                 int16 = 0
+	APIKeyFetch                   int16 = 1
+	APIKeyListOffsets             int16 = 2
+	APIKeyMetadata                int16 = 3
+	APIKeyOffsetCommit            int16 = 8
+	APIKeyOffsetFetch             int16 = 9
+	APIKeyFindCoordinator         int16 = 10
+	APIKeyJoinGroup               int16 = 11
+	APIKeyHeartbeat               int16 = 12
+	APIKeyLeaveGroup              int16 = 13
+	APIKeySyncGroup               int16 = 14
+	APIKeySaslHandshake           int16 = 17
+	APIKeyApiVersions             int16 = 18
+	APIKeyCreateTopics            int16 = 19
+	APIKeyDeleteRecords           int16 = 21
+	APIKeyInitProducerID          int16 = 22
+	APIKeyAddPartitionsToTxn      int16 = 24
+	APIKeyAddOffsetsToTxn         int16 = 25
+	APIKeyEndTxn                  int16 = 26
+	APIKeyTxnOffsetCommit         int16 = 28
+	APIKeyOffsetForLeaderEpoch    int16 = 23
+	APIKeyDescribeAcls            int16 = 29
+	APIKeyCreateAcls              int16 = 30
+	APIKeyDeleteAcls              int16 = 31
+	APIKeyDescribeConfigs         int16 = 32
+	APIKeyAlterConfigs            int16 = 33
+	APIKeyDescribeLogDirs         int16 = 35
+	APIKeySaslAuthenticate        int16 = 36
+	APIKeyCreatePartitions        int16 = 37
+	APIKeyIncrementalAlterConfigs int16 = 44
+	APIKeyDescribeCluster         int16 = 60
+
+	// monolog extensions
+	APIKeyMonologPurge int16 = 32000
+)
+
+// Error Codes
+const (
+	ErrUnknownServerError          int16 = -1
+	ErrNone                        int16 = 0
+	ErrOffsetOutOfRange            int16 = 1
+	ErrCorruptMessage              int16 = 2
+	ErrUnknownTopicOrPartition     int16 = 3
+	ErrInvalidMessage              int16 = 4
+	ErrLeaderNotAvailable          int16 = 5
+	ErrNotLeaderOrFollower         int16 = 6
+	ErrRequestTimedOut             int16 = 7
+	ErrMessageTooLarge             int16 = 10
+	ErrCoordinatorNotAvailable     int16 = 15
+	ErrNotCoordinator              int16 = 16
+	ErrIllegalGeneration           int16 = 22
+	ErrInconsistentGroupProtocol   int16 = 23
+	ErrUnknownMemberID             int16 = 25
+	ErrInvalidSessionTimeout       int16 = 26
+	ErrRebalanceInProgress         int16 = 27
+	ErrUnsupportedVersion          int16 = 35
+	ErrTopicAlreadyExists          int16 = 36
+	ErrInvalidPartitions           int16 = 37
+	ErrInvalidTopicException       int16 = 17
+	ErrTopicAuthorizationFailed    int16 = 29
+	ErrGroupAuthorizationFailed    int16 = 30
+	ErrClusterAuthorizationFailed  int16 = 31
+	ErrSaslAuthenticationFailed    int16 = 31
+	ErrUnsupportedSaslMechanism    int16 = 33
+	ErrInvalidConfig               int16 = 40
+	ErrInvalidRequest              int16 = 42
+	ErrPolicyViolation             int16 = 44
+	ErrOutOfOrderSequenceNumber    int16 = 45
+	ErrInvalidProducerEpoch        int16 = 47
+	ErrInvalidTxnState             int16 = 48
+	ErrInvalidProducerIDMapping    int16 = 49
+	ErrSecurityDisabled            int16 = 54
+	ErrOperationNotAttempted       int16 = 55
+	ErrKafkaStorageError           int16 = 56
+	ErrFencedLeaderEpoch           int16 = 74
+	ErrUnknownLeaderEpoch          int16 = 75
+	ErrUnsupportedCompressionType  int16 = 76
+	ErrMemberIDRequired            int16 = 79
+	ErrUnknownTopicID              int16 = 100
+	ErrUnsupportedEndpointType     int16 = 119
+)
+
+// Config sources, as reported for a config entry
+const (
+	ConfigSourceTopic        int8 = 1 // set on the topic
+	ConfigSourceStaticBroker int8 = 4 // set in the broker's config file
+	ConfigSourceDefault      int8 = 5 // the broker's default
+)
+
+// Compression Codecs
+const (
+	CompressionNone   int8 = 0
+	CompressionGzip   int8 = 1
+	CompressionSnappy int8 = 2
+	CompressionLz4    int8 = 3
+	CompressionZstd   int8 = 4
+)
+
+// codecNames maps compression codec IDs to their names
+var codecNames = []string{"none", "gzip", "snappy", "lz4", "zstd"}
+
+// CodecName returns the name of a compression codec ID
+func CodecName(codec int8) string {
+	if codec >= 0 && int(codec) < len(codecNames) {
+		return codecNames[codec]
+	}
+	return fmt.Sprintf("codec(%d)", codec)
+}
+
+// ParseCodec returns the compression codec ID for a name
+func ParseCodec(name string) (int8, error) {
+	for i, n := range codecNames {
+		if strings.EqualFold(name, n) {
+			return int8(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown codec %q (want %s)", name, strings.Join(codecNames, ", "))
+}
+
+// UUID is a Kafka UUID, such as a topic ID
+type UUID [16]byte
+
+// ParseUUID parses a UUID in the base64url form Kafka prints
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) != len(u) {
+		return u, fmt.Errorf("invalid UUID %q", s)
+	}
+	copy(u[:], b)
+	return u, nil
+}
+
+// String returns the UUID in the base64url form Kafka prints
+func (u UUID) String() string {
+	return base64.RawURLEncoding.EncodeToString(u[:])
+}
+
+// IsZero reports whether u is the zero UUID, which means no ID
+func (u UUID) IsZero() bool {
+	return u == UUID{}
+}
+
+// RequestHeader represents the common request header
+type RequestHeader struct {
+	APIKey        int16
+	APIVersion    int16
+	CorrelationID int32
+	ClientID      string
+}
+
+// ResponseHeader represents the common response header
+type ResponseHeader struct {
+	CorrelationID int32
+}
+
+// ApiVersion represents a supported API version range
+type ApiVersion struct {
+	APIKey     int16
+	MinVersion int16
+	MaxVersion int16
+}
+
+// SaslHandshakeRequest represents a SASL handshake request
+type SaslHandshakeRequest struct {
+	Mechanism string
+}
+
+// SaslHandshakeResponse represents a SASL handshake response
+type SaslHandshakeResponse struct {
+	ErrorCode  int16
+	Mechanisms []string
+}
+
+// Record represents a single Kafka record (message)
+type Record struct {
+	Offset    int64
+	Timestamp int64
+	Key       []byte
+	Value     []byte
+	Headers   []RecordHeader
+}
+
+// RecordHeader represents a record header
+type RecordHeader struct {
+	Key   string
+	Value []byte
+}
+
+// RecordBatch represents a batch of records
+type RecordBatch struct {
+	BaseOffset           int64
+	BatchLength          int32
+	PartitionLeaderEpoch int32
+	Magic                int8
+	CRC                  int32
+	Attributes           int16
+	LastOffsetDelta      int32
+	FirstTimestamp       int64
+	MaxTimestamp         int64
+	ProducerID           int64
+	ProducerEpoch        int16
+	BaseSequence         int32
+	Records              []Record
+	RawRecords           []byte // For passthrough
+	Codec                int8   // Compression codec
+}
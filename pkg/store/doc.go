@@ -0,0 +1,10 @@
+// Package store is the contract between the broker and its storage: the
+// records, topics and groups a backend keeps, the interfaces it
+// implements, and the registry backends are opened from.
+//
+// A backend registers a Factory under a name from init(), with Register,
+// or RegisterInMemory if it keeps nothing on disk, and the broker opens
+// the one storage.backend (or -storage) names with Open. Optional
+// interfaces, such as AtomicAppender or Refresher, add what the broker
+// uses when a backend has it and works around when not.
+package store
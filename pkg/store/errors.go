@@ -0,0 +1,28 @@
+package store
+
+import "errors"
+
+// Sentinel errors returned, wrapped with the name involved, by every
+// store; match them with errors.Is
+var (
+	ErrTopicNotFound  = errors.New("topic not found")
+	ErrTopicExists    = errors.New("topic already exists")
+	ErrGroupNotFound  = errors.New("group not found")
+	ErrMemberNotFound = errors.New("member not found")
+)
+
+// StorageError is a failure of the underlying database rather than of the
+// request, such as an I/O error or a full disk
+type StorageError struct {
+	Op  string
+	Err error
+}
+
+func (e *StorageError) Error() string { return e.Op + ": " + e.Err.Error() }
+func (e *StorageError) Unwrap() error { return e.Err }
+
+// IsStorageError reports whether err is a failure of the underlying database
+func IsStorageError(err error) bool {
+	var se *StorageError
+	return errors.As(err, &se)
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rizkyandriawan/monolog/internal/config"
+)
+
+// Backend is an opened storage backend
+type Backend struct {
+	Topics TopicStoreInterface
+	Groups GroupStoreInterface
+	Close  func() error
+}
+
+// Config is the storage section of the config file, as a Factory gets it
+type Config = config.StorageConfig
+
+// Factory opens a storage backend from the storage config
+type Factory func(cfg Config) (*Backend, error)
+
+type registration struct {
+	factory  Factory
+	inMemory bool
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]registration)
+)
+
+// Register makes a disk-backed storage backend available under name.
+// External modules call this from init() to provide their own backends.
+// Registering the same name twice panics.
+func Register(name string, factory Factory) {
+	register(name, registration{factory: factory})
+}
+
+// RegisterInMemory registers a backend that keeps no data on disk, so the
+// data directory is neither created nor locked
+func RegisterInMemory(name string, factory Factory) {
+	register(name, registration{factory: factory, inMemory: true})
+}
+
+func register(name string, reg registration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if reg.factory == nil {
+		panic("store: Register factory is nil for " + name)
+	}
+	if _, dup := registry[name]; dup {
+		panic("store: Register called twice for backend " + name)
+	}
+	registry[name] = reg
+}
+
+// Open opens the named backend
+func Open(name string, cfg Config) (*Backend, error) {
+	registryMu.RLock()
+	reg, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown storage backend: %s (available: %s)", name, strings.Join(Backends(), ", "))
+	}
+	return reg.factory(cfg)
+}
+
+// IsInMemory reports whether the named backend keeps no data on disk
+func IsInMemory(name string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return registry[name].inMemory
+}
+
+// Backends returns the sorted names of all registered backends
+func Backends() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,392 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rizkyandriawan/monolog/internal/ids"
+)
+
+// TopicMeta contains topic metadata
+type TopicMeta struct {
+	Name           string            `json:"name"`
+	ID             string            `json:"id"` // topic UUID, base64url-encoded as Kafka prints it
+	CreatedAt      time.Time         `json:"created_at"`
+	LatestOffset   int64             `json:"latest_offset"`
+	LogStartOffset int64             `json:"log_start_offset"` // raised by DeleteRecords; older messages may outlive it inside a batch
+	LeaderEpoch    int32             `json:"leader_epoch"`     // current partition leader epoch
+	Config         map[string]string `json:"config,omitempty"` // per-topic settings by Kafka config name; replaced, never modified
+}
+
+// NewTopicID returns a random (version 4) topic UUID in Kafka's
+// base64url form. It is never the zero UUID or the one Kafka reserves for
+// its metadata topic.
+func NewTopicID() string {
+	id := ids.UUID()
+	return base64.RawURLEncoding.EncodeToString(id[:])
+}
+
+// LeaderEpoch records the first offset written under a partition leader
+// epoch. Monolog starts a new epoch for every topic each time the store is
+// opened, so clients can tell a restart from a log that kept growing.
+type LeaderEpoch struct {
+	Epoch       int32 `json:"epoch"`
+	StartOffset int64 `json:"start_offset"`
+}
+
+// Record represents a stored message
+type Record struct {
+	Offset     int64             `json:"offset"`
+	LastOffset int64             `json:"last_offset"` // for batches, the last offset in the batch
+	Timestamp  int64             `json:"timestamp"`
+	Key        []byte            `json:"key,omitempty"`
+	Value      []byte            `json:"value"`
+	Headers    map[string][]byte `json:"headers,omitempty"`
+	Codec      int8              `json:"codec"` // compression codec (passthrough)
+}
+
+// AbortedTxn is the offset range written by an aborted transaction
+type AbortedTxn struct {
+	ProducerID  int64 `json:"producer_id"`
+	FirstOffset int64 `json:"first_offset"`
+	LastOffset  int64 `json:"last_offset"`
+}
+
+// Transaction states kept by the transaction coordinator
+const (
+	TxnEmpty          = "empty"           // no transaction open
+	TxnOngoing        = "ongoing"         // partitions or offsets added, not yet ended
+	TxnCompleteCommit = "complete_commit" // last transaction committed
+	TxnCompleteAbort  = "complete_abort"  // last transaction aborted
+)
+
+// TxnState is what the transaction coordinator knows of one
+// transactional ID
+type TxnState struct {
+	TransactionalID string           `json:"transactional_id"`
+	ProducerID      int64            `json:"producer_id"`
+	ProducerEpoch   int16            `json:"producer_epoch"`
+	State           string           `json:"state"`
+	TimeoutMs       int32            `json:"timeout_ms"`
+	Partitions      map[string]int64 `json:"partitions,omitempty"` // topic -> first offset written in the transaction, -1 if none yet
+	Groups          []string         `json:"groups,omitempty"`
+	Offsets         []TxnOffset      `json:"offsets,omitempty"` // committed with the transaction
+	Started         time.Time        `json:"started"`
+	Updated         time.Time        `json:"updated"`
+}
+
+// TxnOffset is a consumer group offset committed with a transaction
+type TxnOffset struct {
+	Group     string `json:"group"`
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+}
+
+// Group represents a consumer group
+type Group struct {
+	ID         string                   `json:"id"`
+	State      string                   `json:"state"` // empty, forming, stable
+	Generation int32                    `json:"generation"`
+	LeaderID   string                   `json:"leader_id"`
+	Protocol   string                   `json:"protocol"`
+	Members    map[string]Member        `json:"members"`
+	Offsets    map[TopicPartition]int64 `json:"offsets"`
+	CreatedAt  time.Time                `json:"created_at"`
+	UpdatedAt  time.Time                `json:"updated_at"`
+}
+
+// TopicPartition identifies one partition of a topic. As text, and so as
+// a JSON map key, it is "topic" for partition 0 and "topic:N" otherwise;
+// topic names cannot contain a colon.
+type TopicPartition struct {
+	Topic     string
+	Partition int32
+}
+
+func (tp TopicPartition) String() string {
+	if tp.Partition == 0 {
+		return tp.Topic
+	}
+	return tp.Topic + ":" + strconv.FormatInt(int64(tp.Partition), 10)
+}
+
+func (tp TopicPartition) MarshalText() ([]byte, error) {
+	return []byte(tp.String()), nil
+}
+
+func (tp *TopicPartition) UnmarshalText(text []byte) error {
+	s := string(text)
+	tp.Topic, tp.Partition = s, 0
+	if i := strings.LastIndexByte(s, ':'); i >= 0 {
+		p, err := strconv.ParseInt(s[i+1:], 10, 32)
+		if err != nil || p < 0 {
+			return fmt.Errorf("invalid topic partition %q", s)
+		}
+		tp.Topic, tp.Partition = s[:i], int32(p)
+	}
+	return nil
+}
+
+// Member represents a consumer group member
+type Member struct {
+	ID               string    `json:"id"`
+	ClientID         string    `json:"client_id"`
+	LastHeartbeat    time.Time `json:"last_heartbeat"`
+	SessionTimeoutMs int32     `json:"session_timeout_ms"` // 0 = broker default
+	Metadata         []byte    `json:"metadata,omitempty"`
+	Assignment       []byte    `json:"assignment,omitempty"`
+}
+
+// TopicStoreInterface defines topic store operations. Methods taking a
+// context abandon their database work once it is done; the others only
+// read in-memory state.
+type TopicStoreInterface interface {
+	CreateTopic(ctx context.Context, name string, config map[string]string) error
+	TopicExists(name string) bool
+	ListTopics() []string
+	Version() uint64
+	DeleteTopic(ctx context.Context, name string) error
+	Append(ctx context.Context, topic string, records []Record) (int64, error)
+	AppendRaw(ctx context.Context, topic string, data []byte, codec int8, recordCount int, maxTimestamp int64) (int64, error) // maxTimestamp <= 0 takes the store's clock
+	Read(ctx context.Context, topic string, fromOffset int64, maxRecords int) ([]Record, error)
+	ReadRange(ctx context.Context, topic string, fromTs, toTs int64, cursor int64, maxRecords int) ([]Record, int64, error)
+	LatestOffset(topic string) (int64, error)
+	EarliestOffset(ctx context.Context, topic string) (int64, error)
+	SizeBytes(ctx context.Context, topic string) (int64, error)                      // storage taken by the topic's messages
+	DeleteBefore(ctx context.Context, topic string, cutoff time.Time) (int, error)   // the prefix of the log older than cutoff
+	DeleteBeforeOffset(ctx context.Context, topic string, offset int64) (int, error) // and moves the log start offset up to offset
+	GetMeta(topic string) (*TopicMeta, error)
+	SetTopicConfig(ctx context.Context, topic string, config map[string]string) error // replaces the topic's configs
+	AddAbortedTxn(ctx context.Context, topic string, txn AbortedTxn) error
+	AbortedTxns(ctx context.Context, topic string, fromOffset, toOffset int64) ([]AbortedTxn, error)
+	LeaderEpochs(topic string) ([]LeaderEpoch, error) // oldest first
+	Ping(ctx context.Context) error                   // storage accepts writes and topic metadata is loaded
+}
+
+// IntegrityChecker is implemented by topic stores that can verify and
+// repair their offset bookkeeping
+type IntegrityChecker interface {
+	Integrity() IntegrityStats
+	CheckIntegrity(ctx context.Context, topic string) (*IntegrityReport, error)
+	RepairTopic(ctx context.Context, topic string) (*IntegrityReport, error)
+}
+
+// ChecksumVerifier is implemented by topic stores that keep a checksum of
+// every stored record and can check the data against it
+type ChecksumVerifier interface {
+	VerifyChecksums(ctx context.Context, topic string, fromOffset int64, limit int) (*ChecksumPage, error)
+}
+
+// StoreMemory is what a topic store holds in memory
+type StoreMemory struct {
+	CacheLimit int64 `json:"cache_limit"`    // bytes the page cache may grow to
+	Data       int64 `json:"data,omitempty"` // the whole database, for in-memory backends
+	Topics     int   `json:"topics"`         // topics with metadata cached
+}
+
+// MemoryReporter is implemented by topic stores that can say how much
+// memory they hold
+type MemoryReporter interface {
+	Memory(ctx context.Context) (StoreMemory, error)
+}
+
+// Clocked is implemented by topic stores that stamp messages with the time
+// they were appended, letting the engine substitute its own clock
+type Clocked interface {
+	SetClock(now func() time.Time)
+}
+
+// CommitHooked is implemented by topic stores that can call back just
+// before and just after an append's transaction commits, letting the
+// engine crash the process there for recovery tests
+type CommitHooked interface {
+	SetCommitHooks(before, after func(topic string))
+}
+
+// TopicAppend is one topic's records in an atomic append
+type TopicAppend struct {
+	Topic   string
+	Records []Record
+}
+
+// AtomicAppender is implemented by topic stores that can append to several
+// topics at once, storing either every append or none of them
+type AtomicAppender interface {
+	AppendAtomic(ctx context.Context, appends []TopicAppend) ([]int64, error) // base offset of each append
+}
+
+// RawBatch is raw Kafka batch data to store as one row
+type RawBatch struct {
+	Data         []byte
+	Codec        int8
+	RecordCount  int
+	MaxTimestamp int64 // <= 0 takes the store's clock
+}
+
+// RawBatchAppender is implemented by topic stores that can store several
+// raw batches atomically, each at its own offsets
+type RawBatchAppender interface {
+	AppendRawBatches(ctx context.Context, topic string, batches []RawBatch) (int64, error) // offset of the first batch
+}
+
+// ReplicaAppender is implemented by topic stores that can store records
+// at the offsets another instance gave them, for follower mode
+type ReplicaAppender interface {
+	// AppendReplica stores records, in offset order and past the topic's
+	// latest offset, each at its own Offset through LastOffset
+	AppendReplica(ctx context.Context, topic string, records []Record) error
+}
+
+// Dictionary is a compression dictionary trained on a topic's messages
+type Dictionary struct {
+	Topic     string    `json:"topic"`
+	ID        uint32    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Samples   int       `json:"samples"` // messages it was trained on
+	Data      []byte    `json:"-"`
+}
+
+// DictionaryStore is implemented by topic stores that can keep compression
+// dictionaries. A topic's dictionaries are deleted with it.
+type DictionaryStore interface {
+	SaveDictionary(ctx context.Context, d Dictionary) error
+	Dictionaries(ctx context.Context) ([]Dictionary, error) // oldest first
+}
+
+// TxnStateStore is implemented by topic stores that can keep the
+// transaction coordinator's state across restarts
+type TxnStateStore interface {
+	SaveTxnState(ctx context.Context, st TxnState) error
+	TxnStates(ctx context.Context) ([]TxnState, error)
+}
+
+// ACLBinding allows or denies a principal an operation on resources. The
+// int8 fields hold the Kafka protocol codes for resource type, pattern
+// type, operation and permission.
+type ACLBinding struct {
+	ResourceType int8   `json:"resource_type"`
+	ResourceName string `json:"resource_name"`
+	PatternType  int8   `json:"pattern_type"`
+	Principal    string `json:"principal"` // e.g. "User:alice"; "User:*" for everyone
+	Host         string `json:"host"`      // "*" for any host
+	Operation    int8   `json:"operation"`
+	Permission   int8   `json:"permission"`
+}
+
+// ACLStore is implemented by topic stores that can keep ACL bindings
+type ACLStore interface {
+	AddACLs(ctx context.Context, acls []ACLBinding) error // bindings already stored are ignored
+	DeleteACLs(ctx context.Context, acls []ACLBinding) error
+	ACLs(ctx context.Context) ([]ACLBinding, error)
+}
+
+// MetricSample is one value of a broker metric, recorded at Time
+type MetricSample struct {
+	Time  time.Time `json:"time"`
+	Name  string    `json:"name"`
+	Label string    `json:"label,omitempty"` // e.g. the group of a consumer_lag sample
+	Value float64   `json:"value"`
+}
+
+// MetricsStore is implemented by topic stores that can keep a history of
+// broker metrics
+type MetricsStore interface {
+	SaveMetrics(ctx context.Context, samples []MetricSample) error
+	Metrics(ctx context.Context, from, to time.Time) ([]MetricSample, error) // oldest first
+	DeleteMetricsBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// Refresher is implemented by stores that cache storage in memory and can
+// reload that cache when something else changed the storage underneath
+type Refresher interface {
+	Changed(ctx context.Context) (bool, error) // storage was written by another connection since the last refresh
+	Refresh(ctx context.Context) (*RefreshReport, error)
+}
+
+// RefreshReport lists what a refresh changed in a store's cache
+type RefreshReport struct {
+	Added   []string `json:"added,omitempty"`   // topics or groups found only in storage
+	Removed []string `json:"removed,omitempty"` // gone from storage, dropped from the cache
+	Updated []string `json:"updated,omitempty"` // cached state differed from storage
+}
+
+// Changed reports whether the refresh changed anything
+func (r *RefreshReport) Changed() bool {
+	return len(r.Added)+len(r.Removed)+len(r.Updated) > 0
+}
+
+// IntegrityStats counts offset integrity problems since startup
+type IntegrityStats struct {
+	OffsetMismatches int64 `json:"offset_mismatches"` // cache disagreed with the database on append
+	GapsDetected     int64 `json:"gaps_detected"`
+	OverlapsDetected int64 `json:"overlaps_detected"`
+	Repairs          int64 `json:"repairs"`
+}
+
+// IntegrityReport is the result of checking one topic
+type IntegrityReport struct {
+	Topic        string        `json:"topic"`
+	Healthy      bool          `json:"healthy"`
+	Rows         int64         `json:"rows"`
+	CachedLatest int64         `json:"cached_latest"`
+	StoredLatest int64         `json:"stored_latest"`
+	Gaps         []OffsetRange `json:"gaps,omitempty"`
+	Overlaps     []OffsetRange `json:"overlaps,omitempty"`
+	Malformed    []OffsetRange `json:"malformed,omitempty"` // rows whose last offset precedes their offset
+}
+
+// ChecksumPage is the result of verifying one run of a topic's rows
+type ChecksumPage struct {
+	Rows       int   // rows read
+	Bytes      int64 // key and value bytes read
+	Backfilled int   // rows stored without a checksum that now have one
+	Corrupt    []CorruptRecord
+	Next       int64 // offset to resume at, or -1 at the end of the topic
+}
+
+// CorruptRecord is a stored row whose data no longer matches its checksum
+type CorruptRecord struct {
+	Topic      string `json:"topic"`
+	Offset     int64  `json:"offset"`
+	LastOffset int64  `json:"last_offset"`
+	Expected   uint32 `json:"expected_crc"` // as stored with the row
+	Actual     uint32 `json:"actual_crc"`   // of the data read back
+}
+
+// OffsetRange is an inclusive range of offsets
+type OffsetRange struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// Heartbeat is the time a group member last heartbeated
+type Heartbeat struct {
+	GroupID  string
+	MemberID string
+	At       time.Time
+}
+
+// GroupStoreInterface defines group store operations
+type GroupStoreInterface interface {
+	GetOrCreateGroup(ctx context.Context, groupID string) (*Group, error)
+	GetGroup(groupID string) (*Group, bool)
+	ListGroups() []string
+	Version() uint64
+	AddMember(ctx context.Context, groupID, memberID, clientID string, sessionTimeoutMs int32, metadata []byte) error
+	RemoveMember(ctx context.Context, groupID, memberID string) error
+	UpdateHeartbeat(ctx context.Context, groupID, memberID string) error
+	UpdateHeartbeats(ctx context.Context, beats []Heartbeat) error
+	SetMemberAssignment(ctx context.Context, groupID, memberID string, assignment []byte) error
+	IncrementGeneration(ctx context.Context, groupID string) (int32, error)
+	CommitOffset(ctx context.Context, groupID, topic string, partition int32, offset int64) error
+	FetchOffset(groupID, topic string, partition int32) (int64, error)
+	Offsets(groupID string) (map[TopicPartition]int64, error)
+	CommitOffsets(ctx context.Context, groupID string, offsets map[TopicPartition]int64) error
+	ExpireMembers(ctx context.Context, defaultTimeout time.Duration) ([]string, error)
+	DeleteGroup(ctx context.Context, groupID string) error
+}
@@ -1,6 +1,11 @@
+//go:build !headless
+
 package web
 
 import "embed"
 
+// Embedded reports whether the built UI is compiled into the binary
+const Embedded = true
+
 //go:embed dist/*
 var DistFS embed.FS
@@ -0,0 +1,12 @@
+//go:build headless
+
+package web
+
+import "embed"
+
+// Embedded reports whether the built UI is compiled into the binary. The
+// headless build (-tags headless) leaves it out for a smaller binary.
+const Embedded = false
+
+// DistFS is empty in headless builds
+var DistFS embed.FS
@@ -1,4 +1,8 @@
-const API_BASE = '/api'
+// Set by the server in index.html when it is mounted under server.base_path
+export const BASE_PATH: string =
+  (window as { __MONOLOG_BASE_PATH__?: string }).__MONOLOG_BASE_PATH__ ?? ''
+
+const API_BASE = `${BASE_PATH}/api`
 
 export interface Topic {
   name: string
@@ -154,7 +158,7 @@ class ApiClient {
 
   async checkHealth(): Promise<boolean> {
     try {
-      const res = await fetch('/health')
+      const res = await fetch(`${BASE_PATH}/health`)
       return res.ok
     } catch {
       return false
@@ -3,6 +3,7 @@ import { createRoot } from 'react-dom/client'
 import { BrowserRouter } from 'react-router-dom'
 import { ChakraProvider, extendTheme } from '@chakra-ui/react'
 import App from './App'
+import { BASE_PATH } from './api/client'
 
 const theme = extendTheme({
   config: {
@@ -35,7 +36,7 @@ const theme = extendTheme({
 
 createRoot(document.getElementById('root')!).render(
   <StrictMode>
-    <BrowserRouter>
+    <BrowserRouter basename={BASE_PATH || undefined}>
       <ChakraProvider theme={theme}>
         <App />
       </ChakraProvider>